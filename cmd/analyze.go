@@ -0,0 +1,129 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bunny/hops"
+)
+
+type AnalyzeOpts struct {
+	// The bunnyfile with the references to the build context
+	File string
+	// The local build context to analyze
+	Context string
+	// The size from which an unused path gets a warning
+	LargeSize int64
+	// Check the bunnyfile against the schema too
+	Strict bool
+}
+
+func parseAnalyzeOpts(args []string) (AnalyzeOpts, error) {
+	var opts AnalyzeOpts
+
+	fs := flag.NewFlagSet("analyze-context", flag.ContinueOnError)
+	fs.StringVar(&opts.File, "file", "", "Path to the bunnyfile")
+	fs.StringVar(&opts.File, "f", "", "Path to the bunnyfile")
+	fs.StringVar(&opts.Context, "context", ".", "Path to the local build context")
+	fs.Int64Var(&opts.LargeSize, "large-size", hops.DefaultLargeUnusedSize, "Size in bytes from which an unused path gets a warning")
+	fs.BoolVar(&opts.Strict, "strict", false, "Check the bunnyfile against the schema too")
+	fs.Usage = func() {
+		fmt.Println("Usage of bunny analyze-context")
+		fmt.Printf("%s analyze-context [<args>]\n\n", os.Args[0])
+		fmt.Println("Validate a bunnyfile and suggest .dockerignore patterns for the unused paths of the build context")
+		fmt.Println("Supported command line arguments")
+		fmt.Println("\t-f, --file filename \t\tPath to the bunnyfile")
+		fmt.Println("\t--context path \t\t\tPath to the local build context (default: .)")
+		fmt.Println("\t--large-size bytes \t\tSize in bytes from which an unused path gets a warning (default: 10485760)")
+		fmt.Println("\t--strict bool \t\t\tCheck the bunnyfile against the schema too")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+	if opts.File == "" {
+		return opts, fmt.Errorf("The --file argument is necessary")
+	}
+	if opts.LargeSize <= 0 {
+		return opts, fmt.Errorf("Invalid --large-size %d, expected a positive number of bytes", opts.LargeSize)
+	}
+
+	return opts, nil
+}
+
+// contextKeep returns the path of the file relative to the build context,
+// if the context has it, so the analysis does not suggest to ignore it
+func contextKeep(file string, buildContext string) []string {
+	rel, err := filepath.Rel(buildContext, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+
+	return []string{filepath.ToSlash(rel)}
+}
+
+// analyzeContextCommand validates the bunnyfile as the validate endpoint of
+// bunny serve does and then checks the build context against it. The
+// suggested patterns go to the standard output, so they can be appended to
+// the .dockerignore, and everything else to the standard error.
+func analyzeContextCommand(args []string) error {
+	opts, err := parseAnalyzeOpts(args)
+	if err != nil {
+		return err
+	}
+	file, err := os.ReadFile(opts.File)
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %v", opts.File, err)
+	}
+	h, err := hops.ParseBunnyfile(file)
+	if err != nil {
+		return fmt.Errorf("%s is not valid: %v", opts.File, err)
+	}
+	if warning := hops.SyntaxWarning(file); warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if opts.Strict {
+		err = hops.ValidateSchema(file)
+		if err != nil {
+			return fmt.Errorf("%s does not match the schema:\n%v", opts.File, err)
+		}
+	}
+
+	report, err := hops.AnalyzeContext(h, opts.Context, contextKeep(opts.File, opts.Context), opts.LargeSize)
+	if err != nil {
+		return err
+	}
+	for _, large := range report.Large {
+		fmt.Fprintf(os.Stderr, "Warning: %s has %d bytes and %s does not use it\n", large.Path, large.Size, opts.File)
+	}
+	if len(report.Ignore) != 0 {
+		fmt.Fprintf(os.Stderr, "Suggested patterns for %s:\n", filepath.Join(opts.Context, ".dockerignore"))
+		for _, pattern := range report.Ignore {
+			fmt.Println(pattern)
+		}
+	}
+	if len(report.Missing) != 0 {
+		return fmt.Errorf("The build context %s does not have the paths of %s: %s", opts.Context, opts.File, strings.Join(report.Missing, ", "))
+	}
+	fmt.Fprintf(os.Stderr, "%s is valid for the build context %s\n", opts.File, opts.Context)
+
+	return nil
+}
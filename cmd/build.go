@@ -0,0 +1,396 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"bunny/hops"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+type BuildOpts struct {
+	// The bunnyfile or Containerfile to build
+	File string
+	// The local build context to use for building
+	Context string
+	// The output of the build, as buildctl expects it
+	Output string
+	// The target to build (image, kernel or rootfs)
+	Target string
+	// The variant of a bunnyfile with many documents
+	Variant string
+	// The build caches to import from and export to
+	Cache hops.CacheOpts
+	// Print a summary of the build
+	Summary bool
+	// Where to write a Kubernetes manifest for the built image
+	K8sManifest string
+	// The kind of the Kubernetes manifest (Deployment or Pod)
+	K8sKind string
+	// Where to write the Helm values of the built image
+	HelmValues string
+	// Where buildctl writes the metadata of the build
+	MetadataFile string
+	// Where to write the HTML report of the build graph
+	Graph string
+}
+
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// addCacheFlags adds the flags of the build caches to the given flag set
+func addCacheFlags(fs *flag.FlagSet, cache *hops.CacheOpts) {
+	fs.Var((*stringList)(&cache.From), "cache-from", "Build cache to import from (image, directory or buildctl cache spec)")
+	fs.Var((*stringList)(&cache.To), "cache-to", "Build cache to export to (image, directory or buildctl cache spec)")
+}
+
+func parseBuildOpts(args []string) (BuildOpts, error) {
+	var opts BuildOpts
+
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	fs.StringVar(&opts.File, "file", "", "Path to the bunnyfile or Containerfile to build")
+	fs.StringVar(&opts.File, "f", "", "Path to the bunnyfile or Containerfile to build")
+	fs.StringVar(&opts.Context, "context", ".", "Path to the local build context")
+	fs.StringVar(&opts.Output, "output", "type=oci,tar=false,dest=image", "Output of the build, as in buildctl or containerd,name=image")
+	fs.StringVar(&opts.Target, "target", hops.TargetImage, "Build only the kernel, the rootfs or the image")
+	fs.StringVar(&opts.Variant, "variant", "", "The variant of a bunnyfile with many documents")
+	fs.BoolVar(&opts.Summary, "summary", false, "Print a summary of the build with cache statistics")
+	fs.StringVar(&opts.K8sManifest, "k8s-manifest", "", "Write a Kubernetes manifest for the built image in the given file")
+	fs.StringVar(&opts.K8sKind, "k8s-kind", hops.KubernetesKindDeployment, "The kind of the Kubernetes manifest (Deployment or Pod)")
+	fs.StringVar(&opts.Graph, "graph", "", "Write an HTML report of the build graph in the given file")
+	fs.StringVar(&opts.HelmValues, "helm-values", "", "Write the Helm values of the built image in the given file")
+	addCacheFlags(fs, &opts.Cache)
+	fs.Usage = func() {
+		fmt.Println("Usage of bunny build")
+		fmt.Printf("%s build [<args>]\n\n", os.Args[0])
+		fmt.Println("Build an image locally with buildctl")
+		fmt.Println("Supported command line arguments")
+		fmt.Println("\t-f, --file filename \t\tPath to the bunnyfile or Containerfile to build")
+		fmt.Println("\t--context path \t\t\tPath to the local build context (default: .)")
+		fmt.Println("\t--output spec \t\t\tOutput of the build, as in buildctl or containerd,name=image (default: type=oci,tar=false,dest=image)")
+		fmt.Println("\t--target name \t\t\tBuild only the kernel, the rootfs or the image (default: image)")
+		fmt.Println("\t--variant name \t\t\tThe variant of a bunnyfile with many documents")
+		fmt.Println("\t--cache-from cache \t\tBuild cache to import from (image, directory or buildctl cache spec)")
+		fmt.Println("\t--cache-to cache \t\tBuild cache to export to (image, directory or buildctl cache spec)")
+		fmt.Println("\t--summary bool \t\t\tPrint a summary of the build with cache statistics")
+		fmt.Println("\t--graph filename \t\tWrite an HTML report of the build graph in the given file")
+		fmt.Println("\t--k8s-manifest filename \tWrite a Kubernetes manifest for the built image in the given file")
+		fmt.Println("\t--k8s-kind kind \t\tThe kind of the Kubernetes manifest, Deployment or Pod (default: Deployment)")
+		fmt.Println("\t--helm-values filename \t\tWrite the Helm values of the built image in the given file")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+	if opts.File == "" {
+		return opts, fmt.Errorf("The --file argument is necessary")
+	}
+	_, err = hops.ParseContainerdOutput(opts.Output)
+	if err != nil {
+		return opts, err
+	}
+	if opts.K8sManifest != "" {
+		_, err = hops.ParseKubernetesKind(opts.K8sKind)
+		if err != nil {
+			return opts, err
+		}
+		if hops.OutputImageName(opts.Output) == "" {
+			return opts, fmt.Errorf("The --k8s-manifest argument requires a name in --output")
+		}
+	}
+	if opts.HelmValues != "" && hops.OutputImageName(opts.Output) == "" {
+		return opts, fmt.Errorf("The --helm-values argument requires a name in --output")
+	}
+
+	return opts, nil
+}
+
+// buildImage builds the given file with buildctl for the host. If summary is
+// set, the progress of the build is collected and a summary of the build gets
+// printed.
+func buildImage(opts BuildOpts) error {
+	// The image boots on the host, so build it for the host
+	dt, err := fileToLLB(opts.File, opts.Target, opts.Variant, "", hops.SourceOpts{})
+	if err != nil {
+		return err
+	}
+	var llbBuf bytes.Buffer
+	err = llb.WriteTo(dt, &llbBuf)
+	if err != nil {
+		return fmt.Errorf("Could not write LLB: %v", err)
+	}
+
+	cacheArgs, err := opts.Cache.BuildctlArgs()
+	if err != nil {
+		return err
+	}
+	output := opts.Output
+	ctrOutput, err := hops.ParseContainerdOutput(opts.Output)
+	if err != nil {
+		return err
+	}
+	if ctrOutput != nil {
+		output = ctrOutput.BuildctlOutput()
+	}
+	buildArgs := []string{"build",
+		"--local", buildContextName + "=" + opts.Context,
+		"--output", output}
+	buildArgs = append(buildArgs, cacheArgs...)
+	if opts.MetadataFile != "" {
+		buildArgs = append(buildArgs, "--metadata-file", opts.MetadataFile)
+	}
+	// The summary and the graph need the progress of the build
+	collectProgress := opts.Summary || opts.Graph != ""
+	var progress bytes.Buffer
+	if collectProgress {
+		buildArgs = append(buildArgs, "--progress", "rawjson")
+	}
+	cmd := exec.Command("buildctl", buildArgs...)
+	cmd.Stdin = &llbBuf
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if collectProgress {
+		cmd.Stderr = &progress
+	}
+	// Stream the image from buildctl to ctr, so it gets imported in
+	// containerd without a registry
+	var ctrCmd *exec.Cmd
+	if ctrOutput != nil {
+		ctrCmd = exec.Command("ctr", ctrOutput.CtrArgs()...)
+		err = startImport(cmd, ctrCmd)
+		if err != nil {
+			return err
+		}
+	}
+	err = cmd.Run()
+	if err != nil {
+		// The error of buildctl is in the collected output
+		if collectProgress {
+			os.Stderr.Write(progress.Bytes())
+		}
+		// A failed ctr also fails buildctl, since nothing reads the image
+		importErr := waitImport(ctrCmd)
+		// The graph shows which step failed
+		if opts.Graph != "" {
+			graphErr := writeGraph(opts, dt, progress.Bytes())
+			if graphErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", graphErr)
+			}
+		}
+		return errors.Join(fmt.Errorf("Failed to build image with buildctl: %v", err), importErr)
+	}
+	err = waitImport(ctrCmd)
+	if err != nil {
+		return err
+	}
+	if opts.Graph != "" {
+		err = writeGraph(opts, dt, progress.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	if !opts.Summary {
+		return nil
+	}
+
+	buildSummary, err := hops.SummarizeProgress(&progress, 5)
+	if err != nil {
+		return err
+	}
+	fmt.Print(buildSummary.String())
+
+	return nil
+}
+
+// writeGraph writes the HTML report of the graph of the build, with the
+// information of the given progress of buildctl
+// startImport starts ctr, which reads the image that the build writes to its
+// standard output and imports it in containerd
+func startImport(cmd *exec.Cmd, ctrCmd *exec.Cmd) error {
+	var err error
+	cmd.Stdout = nil
+	ctrCmd.Stdin, err = cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("Could not create pipe to ctr: %v", err)
+	}
+	ctrCmd.Stdout = os.Stdout
+	ctrCmd.Stderr = os.Stderr
+	err = ctrCmd.Start()
+	if err != nil {
+		return fmt.Errorf("Failed to start ctr: %v", err)
+	}
+
+	return nil
+}
+
+// waitImport waits for ctr, if there is one, and returns the error of the
+// import, so a failed import never reports success
+func waitImport(ctrCmd *exec.Cmd) error {
+	if ctrCmd == nil {
+		return nil
+	}
+	err := ctrCmd.Wait()
+	if err != nil {
+		return fmt.Errorf("Failed to import image in containerd: %v", err)
+	}
+
+	return nil
+}
+
+func writeGraph(opts BuildOpts, dt *llb.Definition, progress []byte) error {
+	graph, err := hops.NewBuildGraph(dt)
+	if err != nil {
+		return err
+	}
+	err = graph.AddProgress(bytes.NewReader(progress))
+	if err != nil {
+		return err
+	}
+	report, err := graph.HTML("Build graph of " + opts.File)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(opts.Graph, report, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not write %s: %v", opts.Graph, err)
+	}
+
+	return nil
+}
+
+// deploymentHints returns the monitor and the resources of the unikernel in
+// the given file. A Containerfile has no resources, so it gets none.
+func deploymentHints(filename string) (string, hops.Resources, error) {
+	var res hops.Resources
+	fileBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return "", res, fmt.Errorf("Could not read %s: %v", filename, err)
+	}
+	packInst, err := hops.ParseFile(context.Background(), fileBytes, buildContextName, nil, hops.SourceOpts{})
+	if err != nil {
+		return "", res, fmt.Errorf("Could not parse building instructions: %v", err)
+	}
+	bunnyHops, err := hops.ParseBunnyfile(fileBytes)
+	if err == nil {
+		res = bunnyHops.Resources
+	}
+
+	return packInst.Annots["com.urunc.unikernel.hypervisor"], res, nil
+}
+
+// writeK8sManifest writes a Kubernetes manifest that runs the built image
+// with urunc, with the resources of the bunnyfile.
+func writeK8sManifest(opts BuildOpts, res hops.Resources) error {
+	manifest, err := hops.KubernetesManifest(hops.OutputImageName(opts.Output), opts.K8sKind, res)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(opts.K8sManifest, manifest, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not write %s: %v", opts.K8sManifest, err)
+	}
+
+	return nil
+}
+
+// writeHelmValues writes the Helm values of the built image, with the
+// digest that buildctl reported in the metadata of the build.
+func writeHelmValues(opts BuildOpts, monitor string, res hops.Resources) error {
+	metadata, err := os.ReadFile(opts.MetadataFile)
+	if err != nil {
+		return fmt.Errorf("Could not read the metadata of the build: %v", err)
+	}
+	digest, err := hops.ImageDigestFromMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	values, err := hops.NewHelmValues(hops.OutputImageName(opts.Output), digest, monitor, res)
+	if err != nil {
+		return err
+	}
+	out, err := values.YAML()
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(opts.HelmValues, out, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not write %s: %v", opts.HelmValues, err)
+	}
+
+	return nil
+}
+
+func buildCommand(args []string) error {
+	opts, err := parseBuildOpts(args)
+	if err != nil {
+		return err
+	}
+
+	if opts.HelmValues != "" {
+		metadataDir, err := os.MkdirTemp("", "bunny-build")
+		if err != nil {
+			return fmt.Errorf("Could not create a temporary directory: %v", err)
+		}
+		defer os.RemoveAll(metadataDir)
+		opts.MetadataFile = filepath.Join(metadataDir, "metadata.json")
+	}
+
+	err = buildImage(opts)
+	if err != nil {
+		return err
+	}
+	if opts.K8sManifest == "" && opts.HelmValues == "" {
+		return nil
+	}
+
+	monitor, res, err := deploymentHints(opts.File)
+	if err != nil {
+		return err
+	}
+	if opts.K8sManifest != "" {
+		err = writeK8sManifest(opts, res)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.HelmValues != "" {
+		err = writeHelmValues(opts, monitor, res)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os/exec"
+	"testing"
+
+	"bunny/hops"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildImport(t *testing.T) {
+	tests := []struct {
+		name      string
+		build     string
+		ctr       string
+		buildFail bool
+		errorText string
+	}{
+		{name: "Imported image", build: "echo image", ctr: "grep -q image"},
+		{
+			name:      "Failed import",
+			build:     "echo image",
+			ctr:       "cat >/dev/null; exit 3",
+			errorText: "Failed to import image in containerd: exit status 3",
+		},
+		{
+			name:      "Failed import and build",
+			build:     "echo image; exit 1",
+			ctr:       "cat >/dev/null; exit 3",
+			buildFail: true,
+			errorText: "Failed to import image in containerd: exit status 3",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			build := exec.Command("/bin/sh", "-c", tc.build)
+			ctr := exec.Command("/bin/sh", "-c", tc.ctr)
+			require.NoError(t, startImport(build, ctr))
+			err := build.Run()
+			if tc.buildFail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			err = waitImport(ctr)
+			if tc.errorText != "" {
+				require.ErrorContains(t, err, tc.errorText)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+	t.Run("Missing ctr", func(t *testing.T) {
+		err := startImport(exec.Command("/bin/true"), exec.Command("/nonexistent/ctr"))
+		require.ErrorContains(t, err, "Failed to start ctr")
+	})
+	t.Run("No import", func(t *testing.T) {
+		require.NoError(t, waitImport(nil))
+	})
+}
+
+func TestBuildUruncVersion(t *testing.T) {
+	packInst := func() *hops.PackInstructions {
+		return &hops.PackInstructions{
+			Annots: map[string]string{
+				"com.urunc.unikernel.binary": hops.DefaultKernelPath,
+				hops.DtbAnnotation:           hops.DefaultDtbPath,
+			},
+			UruncVersion: "v0.5.0",
+		}
+	}
+
+	err := applyUruncVersion(packInst(), hops.UruncCompatModeFail)
+	require.ErrorContains(t, err, "Incompatible with the targeted urunc: urunc v0.5.0 does not understand io.bunny.dtb")
+
+	i := packInst()
+	require.NoError(t, applyUruncVersion(i, hops.UruncCompatModeWarn))
+	require.Equal(t, hops.DefaultDtbPath, i.Annots[hops.DtbAnnotation])
+
+	i = packInst()
+	delete(i.Annots, hops.DtbAnnotation)
+	require.NoError(t, applyUruncVersion(i, hops.UruncCompatModeFail))
+}
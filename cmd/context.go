@@ -0,0 +1,102 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"bunny/hops"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+// contextFiles reads the files that the frontend itself needs from the build
+// context, e.g. the bunnyfile, the annotation policy and the OCI layouts. All
+// of them come with a single local source, so the client transfers the
+// context once, and only when the first file gets read.
+type contextFiles struct {
+	c client.Client
+	// The include patterns of the files and directories to transfer
+	patterns []string
+	// The maximum size of a file to read
+	maxSize int64
+	// The reference of the transferred files, once solved
+	ref client.Reference
+}
+
+// reference solves the local source of the files, the first time it gets
+// called, and returns its reference
+func (cf *contextFiles) reference(ctx context.Context) (client.Reference, error) {
+	if cf.ref != nil {
+		return cf.ref, nil
+	}
+	src := llb.Local(buildContextName, llb.IncludePatterns(cf.patterns),
+		llb.WithCustomName("Internal:Read build context files"))
+	def, err := src.Marshal(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal state for fetching build context files: %w", err)
+	}
+	res, err := cf.c.Solve(ctx, client.SolveRequest{
+		Definition: def.ToPB(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to solve state for fetching build context files: %w", err)
+	}
+	cf.ref, err = res.SingleRef()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get reference of result for fetching build context files: %w", err)
+	}
+
+	return cf.ref, nil
+}
+
+// readFile reads a file of the build context, which one of the include
+// patterns should cover
+func (cf *contextFiles) readFile(ctx context.Context, filename string) ([]byte, error) {
+	ref, err := cf.reference(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := hops.ReadRefFile(ctx, ref, filename, cf.maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %w", filename, err)
+	}
+
+	return content, nil
+}
+
+// readOptionalFile reads a file of the build context, like readFile, or
+// returns nil if the build context does not have it
+func (cf *contextFiles) readOptionalFile(ctx context.Context, filename string) ([]byte, error) {
+	ref, err := cf.reference(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ref.ReadDir(ctx, client.ReadDirRequest{
+		Path:           path.Dir(filename),
+		IncludePattern: path.Base(filename),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %w", filename, err)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	return cf.readFile(ctx, filename)
+}
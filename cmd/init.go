@@ -0,0 +1,291 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"bunny/hops"
+)
+
+const (
+	defaultSyntaxImage string = "harbor.nbfc.io/nubificus/bunny:latest"
+)
+
+type InitOpts struct {
+	// The file to write the bunnyfile
+	Output string
+	// Overwrite the output file if it exists
+	Force bool
+	// Do not ask anything and use the defaults for any unset value
+	Yes bool
+	// Create a .bunnyignore next to the bunnyfile
+	Ignore bool
+	// The answers to the questions of the wizard
+	Framework  string
+	Monitor    string
+	Arch       string
+	KernelFrom string
+	KernelPath string
+	RootfsFrom string
+	RootfsPath string
+	Includes   string
+	Cmd        string
+}
+
+// prompter asks the user a question and returns the answer or the
+// default value, if the answer was empty.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	yes bool
+}
+
+func (p *prompter) ask(question string, preset string, def string) (string, error) {
+	if preset != "" {
+		return preset, nil
+	}
+	if p.yes {
+		return def, nil
+	}
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	answer, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("Failed to read answer: %v", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def, nil
+	}
+
+	return answer, nil
+}
+
+func parseInitOpts(args []string) (InitOpts, error) {
+	var opts InitOpts
+
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.StringVar(&opts.Output, "file", "bunnyfile", "Path of the bunnyfile to create")
+	fs.StringVar(&opts.Output, "f", "bunnyfile", "Path of the bunnyfile to create")
+	fs.BoolVar(&opts.Force, "force", false, "Overwrite the bunnyfile if it exists")
+	fs.BoolVar(&opts.Yes, "yes", false, "Do not ask and use the default values")
+	fs.BoolVar(&opts.Ignore, "ignore", false, "Create a .bunnyignore next to the bunnyfile")
+	fs.StringVar(&opts.Framework, "framework", "", "The unikernel framework")
+	fs.StringVar(&opts.Monitor, "monitor", "", "The monitor to run the unikernel")
+	fs.StringVar(&opts.Arch, "arch", "", "The target architecture")
+	fs.StringVar(&opts.KernelFrom, "kernel-from", "", "The source of the kernel (local or an OCI image)")
+	fs.StringVar(&opts.KernelPath, "kernel-path", "", "The path of the kernel in its source")
+	fs.StringVar(&opts.RootfsFrom, "rootfs-from", "", "The source of the rootfs (none, local, scratch or an OCI image)")
+	fs.StringVar(&opts.RootfsPath, "rootfs-path", "", "The path of the rootfs in its source")
+	fs.StringVar(&opts.Includes, "include", "", "Comma separated src:dst files to include in a rootfs from scratch")
+	fs.StringVar(&opts.Cmd, "cmd", "", "The command line of the application")
+	fs.Usage = func() {
+		fmt.Println("Usage of bunny init")
+		fmt.Printf("%s init [<args>]\n\n", os.Args[0])
+		fmt.Println("Create a new bunnyfile asking for the necessary information")
+		fmt.Println("Supported command line arguments")
+		fmt.Println("\t-f, --file filename \t\tPath of the bunnyfile to create (default: bunnyfile)")
+		fmt.Println("\t--force bool \t\t\tOverwrite the bunnyfile if it exists")
+		fmt.Println("\t--yes bool \t\t\tDo not ask and use the default values")
+		fmt.Println("\t--ignore bool \t\t\tCreate a .bunnyignore next to the bunnyfile")
+		fmt.Println("\t--framework name \t\tThe unikernel framework")
+		fmt.Println("\t--monitor name \t\t\tThe monitor to run the unikernel")
+		fmt.Println("\t--arch name \t\t\tThe target architecture")
+		fmt.Println("\t--kernel-from source \t\tThe source of the kernel (local or an OCI image)")
+		fmt.Println("\t--kernel-path path \t\tThe path of the kernel in its source")
+		fmt.Println("\t--rootfs-from source \t\tThe source of the rootfs (none, local, scratch or an OCI image)")
+		fmt.Println("\t--rootfs-path path \t\tThe path of the rootfs in its source")
+		fmt.Println("\t--include list \t\t\tComma separated src:dst files to include in a rootfs from scratch")
+		fmt.Println("\t--cmd cmdline \t\t\tThe command line of the application")
+	}
+
+	err := fs.Parse(args)
+	return opts, err
+}
+
+// askBunnyfile asks the user all the necessary information and returns the
+// contents of the bunnyfile.
+func askBunnyfile(p *prompter, opts InitOpts) (string, error) {
+	var b strings.Builder
+	var err error
+
+	ans := map[string]string{}
+	questions := []struct {
+		key      string
+		question string
+		preset   string
+		def      string
+	}{
+		{"framework", "Unikernel framework (e.g. unikraft, rumprun, mirage, linux, or auto)", opts.Framework, "unikraft"},
+		{"monitor", "Monitor (e.g. qemu, firecracker, cloud-hypervisor)", opts.Monitor, "qemu"},
+		{"arch", "Target architecture (empty for host architecture)", opts.Arch, ""},
+		{"kernelFrom", "Kernel source (local or an OCI image)", opts.KernelFrom, "local"},
+		{"kernelPath", "Path of the kernel in its source", opts.KernelPath, "kernel"},
+		{"rootfsFrom", "Rootfs source (none, local, scratch or an OCI image)", opts.RootfsFrom, "none"},
+	}
+	for _, q := range questions {
+		ans[q.key], err = p.ask(q.question, q.preset, q.def)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch ans["rootfsFrom"] {
+	case "none":
+	case "scratch":
+		ans["includes"], err = p.ask("Files to include (comma separated src:dst)", opts.Includes, "")
+	default:
+		ans["rootfsPath"], err = p.ask("Path of the rootfs in its source", opts.RootfsPath, "rootfs")
+	}
+	if err != nil {
+		return "", err
+	}
+	ans["cmd"], err = p.ask("Command line of the application", opts.Cmd, "")
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(&b, "#syntax=%s\n", defaultSyntaxImage)
+	fmt.Fprintf(&b, "version: %s\n\n", hops.Version)
+	fmt.Fprintf(&b, "platforms:\n")
+	fmt.Fprintf(&b, "  framework: %s\n", ans["framework"])
+	fmt.Fprintf(&b, "  monitor: %s\n", ans["monitor"])
+	if ans["arch"] != "" {
+		fmt.Fprintf(&b, "  architecture: %s\n", ans["arch"])
+	}
+	if ans["rootfsFrom"] != "none" {
+		fmt.Fprintf(&b, "\nrootfs:\n")
+		fmt.Fprintf(&b, "  from: %s\n", ans["rootfsFrom"])
+		if ans["rootfsPath"] != "" {
+			fmt.Fprintf(&b, "  path: %s\n", ans["rootfsPath"])
+		}
+		if ans["includes"] != "" {
+			fmt.Fprintf(&b, "  include:\n")
+			for _, inc := range strings.Split(ans["includes"], ",") {
+				fmt.Fprintf(&b, "    - %s\n", strconv.Quote(strings.TrimSpace(inc)))
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\nkernel:\n")
+	fmt.Fprintf(&b, "  from: %s\n", ans["kernelFrom"])
+	fmt.Fprintf(&b, "  path: %s\n", ans["kernelPath"])
+	if ans["cmd"] != "" {
+		var quoted []string
+		for _, arg := range strings.Fields(ans["cmd"]) {
+			quoted = append(quoted, strconv.Quote(arg))
+		}
+		fmt.Fprintf(&b, "\ncmd: [%s]\n", strings.Join(quoted, ", "))
+	}
+
+	return b.String(), nil
+}
+
+// askBunnyignore asks the user whether to create a .bunnyignore next to the
+// bunnyfile and returns its path, or an empty one if the user did not opt in.
+func askBunnyignore(p *prompter, opts InitOpts) (string, error) {
+	preset := ""
+	if opts.Ignore {
+		preset = "y"
+	}
+	answer, err := p.ask("Create a "+hops.BunnyignoreFile+" (y/n)", preset, "n")
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return filepath.Join(filepath.Dir(opts.Output), hops.BunnyignoreFile), nil
+	case "n", "no":
+		return "", nil
+	default:
+		return "", fmt.Errorf("Invalid answer %q, expected y or n", answer)
+	}
+}
+
+// checkOutput fails if the given file exists, unless it can be overwritten
+func checkOutput(file string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", file)
+	}
+
+	return nil
+}
+
+func initCommand(args []string) error {
+	opts, err := parseInitOpts(args)
+	if err != nil {
+		return err
+	}
+
+	err = checkOutput(opts.Output, opts.Force)
+	if err != nil {
+		return err
+	}
+
+	p := &prompter{
+		in:  bufio.NewReader(os.Stdin),
+		out: os.Stdout,
+		yes: opts.Yes,
+	}
+	content, err := askBunnyfile(p, opts)
+	if err != nil {
+		return err
+	}
+	ignoreFile, err := askBunnyignore(p, opts)
+	if err != nil {
+		return err
+	}
+	if ignoreFile != "" {
+		err = checkOutput(ignoreFile, opts.Force)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Make sure that we produce a valid bunnyfile
+	_, err = hops.ParseBunnyfile([]byte(content))
+	if err != nil {
+		return fmt.Errorf("The given answers do not produce a valid bunnyfile: %v", err)
+	}
+
+	err = os.WriteFile(opts.Output, []byte(content), 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %v", opts.Output, err)
+	}
+	fmt.Printf("Created %s\n", opts.Output)
+	if ignoreFile != "" {
+		err = os.WriteFile(ignoreFile, []byte(hops.BunnyignoreScaffold), 0644)
+		if err != nil {
+			return fmt.Errorf("Failed to write %s: %v", ignoreFile, err)
+		}
+		fmt.Printf("Created %s\n", ignoreFile)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bunny/hops"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitBunnyignore(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		opts      InitOpts
+		expected  string
+		errorText string
+	}{
+		{name: "Opt in", input: "y\n", opts: InitOpts{Output: "app/bunnyfile"}, expected: "app/.bunnyignore"},
+		{name: "Opt in with yes", input: "Yes\n", opts: InitOpts{Output: "bunnyfile"}, expected: ".bunnyignore"},
+		{name: "Default", input: "\n", opts: InitOpts{Output: "bunnyfile"}, expected: ""},
+		{name: "Opt out", input: "n\n", opts: InitOpts{Output: "bunnyfile"}, expected: ""},
+		{name: "Flag", input: "", opts: InitOpts{Output: "bunnyfile", Ignore: true}, expected: ".bunnyignore"},
+		{name: "No questions", input: "", opts: InitOpts{Output: "bunnyfile", Yes: true}, expected: ""},
+		{
+			name:      "Invalid answer",
+			input:     "maybe\n",
+			opts:      InitOpts{Output: "bunnyfile"},
+			errorText: `Invalid answer "maybe", expected y or n`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &prompter{
+				in:  bufio.NewReader(strings.NewReader(tc.input)),
+				out: io.Discard,
+				yes: tc.opts.Yes,
+			}
+			file, err := askBunnyignore(p, tc.opts)
+			if tc.errorText != "" {
+				require.ErrorContains(t, err, tc.errorText)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, file)
+		})
+	}
+}
+
+func TestInitCommandBunnyignore(t *testing.T) {
+	dir := t.TempDir()
+	bunnyfile := filepath.Join(dir, "bunnyfile")
+	ignoreFile := filepath.Join(dir, hops.BunnyignoreFile)
+
+	require.NoError(t, initCommand([]string{"-f", bunnyfile, "--yes", "--ignore"}))
+	content, err := os.ReadFile(ignoreFile)
+	require.NoError(t, err)
+	require.Equal(t, hops.BunnyignoreScaffold, string(content))
+	_, err = os.Stat(bunnyfile)
+	require.NoError(t, err)
+
+	// An existing .bunnyignore is kept, unless forced
+	require.NoError(t, os.Remove(bunnyfile))
+	require.NoError(t, os.WriteFile(ignoreFile, []byte("build/\n"), 0644))
+	err = initCommand([]string{"-f", bunnyfile, "--yes", "--ignore"})
+	require.ErrorContains(t, err, ignoreFile+" already exists, use --force to overwrite it")
+	_, err = os.Stat(bunnyfile)
+	require.True(t, os.IsNotExist(err))
+	require.NoError(t, initCommand([]string{"-f", bunnyfile, "--yes", "--ignore", "--force"}))
+	content, err = os.ReadFile(ignoreFile)
+	require.NoError(t, err)
+	require.Equal(t, hops.BunnyignoreScaffold, string(content))
+
+	// Without opting in, there is no .bunnyignore
+	other := filepath.Join(t.TempDir(), "bunnyfile")
+	require.NoError(t, initCommand([]string{"-f", other, "--yes"}))
+	_, err = os.Stat(filepath.Join(filepath.Dir(other), hops.BunnyignoreFile))
+	require.True(t, os.IsNotExist(err))
+}
@@ -16,13 +16,22 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
 
 	"bunny/hops"
 
 	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerui"
 	"github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/moby/buildkit/frontend/gateway/grpcclient"
 	"github.com/moby/buildkit/util/appcontext"
@@ -31,6 +40,36 @@ import (
 const (
 	buildContextName  string = "context"
 	clientOptFilename string = "filename"
+	clientOptInline   string = "inline-bunnyfile"
+	clientOptVerify   string = "verify"
+	clientOptStrict   string = "strict-labels"
+	clientOptAllAnnot string = "urunc-json-all-annotations"
+	clientOptMirrors  string = "mirrors"
+	clientOptLayouts  string = "oci-layouts"
+	clientOptTarget   string = "target"
+	clientOptRetries  string = "network-retries"
+	clientOptTimeout  string = "network-timeout"
+	clientOptInitrd   string = "initrd-mode"
+	clientOptHardened string = "hardened"
+	clientOptPolicy   string = "annotation-policy"
+	clientOptCompare  string = "compare-with"
+	clientOptCmpMode  string = "compare-mode"
+	clientOptMetadata string = "publish-metadata"
+	clientOptUnikraft string = "unikraft-pull"
+	clientOptSchema   string = "strict-schema"
+	clientOptFileKey  string = "dockerfilekey"
+	clientOptResRetry string = "resolve-retries"
+	clientOptResConc  string = "resolve-concurrency"
+	clientOptMaxSize  string = "max-file-size"
+	clientOptIncludes string = "check-includes"
+	clientOptEmbed    string = "embed-bunnyfile"
+	clientOptPinTools string = "pin-tools"
+	clientOptToolList string = "tool-allowlist"
+	clientOptVariant  string = "variant"
+	clientOptNames    string = "name-template"
+	clientOptCrash    string = "crash-report"
+	clientOptUrunc    string = "urunc-compat-mode"
+	buildArgPrefix    string = "build-arg:"
 )
 
 type CLIOpts struct {
@@ -41,18 +80,77 @@ type CLIOpts struct {
 	// Choose the execution mode. If set, then bunny will not act as a
 	// buidlkit frontend. Instead it will just print the LLB.
 	PrintLLB bool
+	// Print the LLB operations that each field of the bunnyfile generated,
+	// instead of the LLB
+	Explain bool
+	// The target to build (image, kernel or rootfs)
+	Target string
+	// The platform of the buildkit worker that will run the LLB
+	Platform string
+	// The directory of the cache of image metadata, empty to not cache
+	MetaCache string
+	// How long the cached digest of a tag is considered current
+	MetaCacheTTL time.Duration
+	// The build arguments, as with --build-arg of buildx
+	BuildArgs []string
+	// Files with build arguments in the KEY=VALUE form
+	EnvFiles []string
+	// The variant of a bunnyfile with many documents
+	Variant string
 }
 
 var version string
 
+// The subcommands of bunny. Each one gets the arguments after its name.
+var subcommands = map[string]func([]string) error{
+	"analyze-context": analyzeContextCommand,
+	"build":           buildCommand,
+	"init":            initCommand,
+	"pin":             pinCommand,
+	"run":             runCommand,
+	"schema":          schemaCommand,
+	"serve":           serveCommand,
+	"watch":           watchCommand,
+}
+
 func usage() {
 
 	fmt.Println("Usage of bunny")
-	fmt.Printf("%s [<args>]\n\n", os.Args[0])
+	fmt.Printf("%s [<args>]\n", os.Args[0])
+	fmt.Printf("%s <command> [<args>]\n\n", os.Args[0])
+	fmt.Println("Supported commands")
+	fmt.Println("\tanalyze-context \t\tValidate a bunnyfile and suggest .dockerignore patterns for the build context")
+	fmt.Println("\tbuild \t\t\t\tBuild an image locally with buildctl")
+	fmt.Println("\tinit \t\t\t\tCreate a new bunnyfile asking for the necessary information")
+	fmt.Println("\tpin \t\t\t\tPin the syntax directive of a file to the digest of the frontend image")
+	fmt.Println("\trun \t\t\t\tBuild (or take an existing image) and boot it locally")
+	fmt.Println("\tschema \t\t\t\tPrint the CUE schema of the bunnyfile or check a bunnyfile against it")
+	fmt.Println("\tserve \t\t\t\tServe the validate, plan and LLB endpoints over HTTP")
+	fmt.Println("\twatch \t\t\t\tBuild an image locally and build it again on every change")
+	fmt.Println("")
 	fmt.Println("Supported command line arguments")
 	fmt.Println("\t-v, --version bool \t\tPrint the version and exit")
 	fmt.Println("\t-f, --file filename \t\tPath to the Containerfile")
 	fmt.Println("\t--LLB bool \t\t\tPrint the LLB instead of acting as a frontend")
+	fmt.Println("\t--explain bool \t\t\tPrint the LLB operations of each field of the bunnyfile")
+	fmt.Println("\t--target name \t\t\tBuild only the kernel, the rootfs or the image (default: image)")
+	fmt.Println("\t--platform os/arch \t\tPlatform of the buildkit worker for the LLB (default: linux/<host arch>)")
+	fmt.Println("\t--meta-cache path \t\tDirectory of the cache of image metadata for the LLB, empty to not cache (default: <user cache>/bunny/meta)")
+	fmt.Println("\t--meta-cache-ttl duration \tHow long the cached digest of a tag is current (default: 24h)")
+	fmt.Println("\t--build-arg key=value \t\tBuild argument for the Containerfile, can be given multiple times")
+	fmt.Println("\t--env-file path \t\tFile with a build argument in each line, can be given multiple times")
+	fmt.Println("\t--variant name \t\t\tThe variant of a bunnyfile with many documents")
+}
+
+// defaultMetaCache returns the directory of the cache of image metadata in
+// the cache directory of the user, or an empty string if there is none
+func defaultMetaCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "bunny", "meta")
 }
 
 func parseCLIOpts() CLIOpts {
@@ -63,6 +161,14 @@ func parseCLIOpts() CLIOpts {
 	flag.StringVar(&opts.ContainerFile, "file", "", "Path to the Containerfile")
 	flag.StringVar(&opts.ContainerFile, "f", "", "Path to the Containerfile")
 	flag.BoolVar(&opts.PrintLLB, "LLB", false, "Print the LLB, instead of acting as a frontend")
+	flag.BoolVar(&opts.Explain, "explain", false, "Print the LLB operations of each field of the bunnyfile")
+	flag.StringVar(&opts.Target, "target", hops.TargetImage, "Build only the kernel, the rootfs or the image")
+	flag.StringVar(&opts.Platform, "platform", "", "Platform of the buildkit worker for the LLB")
+	flag.StringVar(&opts.MetaCache, "meta-cache", defaultMetaCache(), "Directory of the cache of image metadata for the LLB, empty to not cache")
+	flag.DurationVar(&opts.MetaCacheTTL, "meta-cache-ttl", 24*time.Hour, "How long the cached digest of a tag is current")
+	flag.Var((*stringList)(&opts.BuildArgs), "build-arg", "Build argument for the Containerfile")
+	flag.Var((*stringList)(&opts.EnvFiles), "env-file", "File with a build argument in each line")
+	flag.StringVar(&opts.Variant, "variant", "", "The variant of a bunnyfile with many documents")
 
 	flag.Usage = usage
 	flag.Parse()
@@ -70,65 +176,707 @@ func parseCLIOpts() CLIOpts {
 	return opts
 }
 
-func readFileFromLLB(ctx context.Context, c client.Client, filename string) ([]byte, error) {
-	// Get the file from client's context
-	fileSrc := llb.Local(buildContextName, llb.IncludePatterns([]string{filename}),
-		llb.WithCustomName("Internal:Read-"+filename))
+// readBunnyfile reads the file with the instructions. As with the Dockerfile
+// frontend, the "dockerfile" named context and then the local of the
+// dockerfilekey option take precedence over the build context, so clients
+// that send the file separately from the context work too.
+func readBunnyfile(ctx context.Context, c client.Client, files *contextFiles, filename string) ([]byte, error) {
+	dc, err := dockerui.NewClient(c)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read build options: %w", err)
+	}
+	named, err := dc.NamedContext(dockerui.DefaultLocalNameDockerfile, dockerui.ContextOpt{
+		NoDockerignore: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s named context: %w", dockerui.DefaultLocalNameDockerfile, err)
+	}
+	if named != nil {
+		fileSrc, _, err := named.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load %s named context: %w", dockerui.DefaultLocalNameDockerfile, err)
+		}
+		return readFileFromState(ctx, c, *fileSrc, filename, files.maxSize)
+	}
+	if localName := c.BuildOpts().Opts[clientOptFileKey]; localName != "" {
+		fileSrc := llb.Local(localName, llb.IncludePatterns([]string{filename}),
+			llb.SharedKeyHint(localName),
+			llb.WithCustomName("Internal:Read-"+filename))
+		return readFileFromState(ctx, c, fileSrc, filename, files.maxSize)
+	}
+
+	return files.readFile(ctx, filename)
+}
+
+// readFileFromState reads the given file of a state, if it is not larger
+// than maxSize
+func readFileFromState(ctx context.Context, c client.Client, fileSrc llb.State, filename string, maxSize int64) ([]byte, error) {
 	fileDef, err := fileSrc.Marshal(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to marshal state for fetching %s: %w", clientOptFilename, err)
+		return nil, fmt.Errorf("Failed to marshal state for fetching %s: %w", filename, err)
 	}
 	fileRes, err := c.Solve(ctx, client.SolveRequest{
 		Definition: fileDef.ToPB(),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to solve state for fetching %s: %w", clientOptFilename, err)
+		return nil, fmt.Errorf("Failed to solve state for fetching %s: %w", filename, err)
 	}
 	fileRef, err := fileRes.SingleRef()
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get reference of result for fetching %s: %w", clientOptFilename, err)
+		return nil, fmt.Errorf("Failed to get reference of result for fetching %s: %w", filename, err)
 	}
 
 	// Read the content of the file
-	fileBytes, err := fileRef.ReadFile(ctx, client.ReadRequest{
-		Filename: filename,
-	})
+	fileBytes, err := hops.ReadRefFile(ctx, fileRef, filename, maxSize)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read %s: %w", clientOptFilename, err)
+		return nil, fmt.Errorf("Failed to read %s: %w", filename, err)
 	}
 
 	return fileBytes, nil
 }
 
-func bunnyBuilder(ctx context.Context, c client.Client) (*client.Result, error) {
+// readLayouts reads the OCI layouts from the build context
+func readLayouts(ctx context.Context, files *contextFiles, layouts hops.Layouts) (hops.LayoutImages, error) {
+	if len(layouts) == 0 {
+		return nil, nil
+	}
+
+	return hops.LoadLayouts(layouts, buildContextName, func(filename string) ([]byte, error) {
+		return files.readFile(ctx, filename)
+	})
+}
+
+func runSmokeTest(ctx context.Context, c client.Client, res *client.Result, packInst hops.PackInstructions) error {
+	ref, err := res.SingleRef()
+	if err != nil {
+		return fmt.Errorf("Failed to get reference of result: %v", err)
+	}
+	imageState, err := ref.ToState()
+	if err != nil {
+		return fmt.Errorf("Failed to get state of result: %v", err)
+	}
+	testDef, err := hops.SmokeTestLLB(ctx, packInst, imageState)
+	if err != nil {
+		return fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	_, err = c.Solve(ctx, client.SolveRequest{
+		Definition: testDef.ToPB(),
+		Evaluate:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to run smoke test: %v", err)
+	}
+
+	return nil
+}
+
+// runScan scans the rootfs of the final image for vulnerabilities and returns
+// the result of the scan, which contains its report.
+func runScan(ctx context.Context, c client.Client, res *client.Result, packInst hops.PackInstructions) (client.Reference, error) {
+	ref, err := res.SingleRef()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get reference of result: %v", err)
+	}
+	imageState, err := ref.ToState()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get state of result: %v", err)
+	}
+	scanDef, err := hops.ScanLLB(ctx, packInst, imageState)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	scanRes, err := c.Solve(ctx, client.SolveRequest{
+		Definition: scanDef.ToPB(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run scan: %v", err)
+	}
+	scanRef, err := scanRes.SingleRef()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get reference of scan: %v", err)
+	}
+	err = hops.CheckScan(ctx, scanRef, packInst.Scan)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRef, nil
+}
+
+func runKernelCheck(ctx context.Context, c client.Client, packInst hops.PackInstructions) error {
+	// A kernel from the build context gets inspected in the frontend
+	if packInst.KernelCheck.Ref == "" {
+		return hops.CheckLocalKernel(ctx, c, packInst)
+	}
+	checkDef, err := hops.KernelCheckLLB(ctx, packInst)
+	if err != nil {
+		return fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	_, err = c.Solve(ctx, client.SolveRequest{
+		Definition: checkDef.ToPB(),
+		Evaluate:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to inspect kernel: %v", err)
+	}
+
+	return nil
+}
+
+func runKernelBuild(ctx context.Context, c client.Client, packInst hops.PackInstructions) error {
+	buildDef, err := hops.KernelBuildLLB(ctx, packInst)
+	if err != nil {
+		return fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	res, err := c.Solve(ctx, client.SolveRequest{
+		Definition: buildDef.ToPB(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to build kernel: %v", err)
+	}
+	ref, err := res.SingleRef()
+	if err != nil {
+		return fmt.Errorf("Failed to get reference of kernel build: %v", err)
+	}
+
+	return hops.CheckKernelBuild(ctx, ref)
+}
+
+// createInitrdFile creates the initrd of the rootfs in the frontend and
+// replaces the exec that would create it with file operations.
+func createInitrdFile(ctx context.Context, c client.Client, packInst *hops.PackInstructions) error {
+	contentDef, err := hops.InitrdContentLLB(ctx, *packInst)
+	if err != nil {
+		return fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	res, err := c.Solve(ctx, client.SolveRequest{
+		Definition: contentDef.ToPB(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to resolve the files of the initrd: %v", err)
+	}
+	ref, err := res.SingleRef()
+	if err != nil {
+		return fmt.Errorf("Failed to get reference of the files of the initrd: %v", err)
+	}
+	cpio, err := hops.CpioFromRef(ctx, ref, packInst.Rootfs.InitrdOwner)
+	if err != nil {
+		return fmt.Errorf("Failed to create initrd: %v", err)
+	}
+	packInst.Rootfs.SourceState = hops.InitrdFileLLB(cpio)
+
+	return nil
+}
+
+// compareWithImage checks the image that the build produces against a
+// previous image and fails or warns about any incompatible change, depending
+// on the mode.
+func compareWithImage(ctx context.Context, c client.Client, packInst hops.PackInstructions, prevImage string, mode string) error {
+	changes, err := hops.CompareWithImage(ctx, c, packInst, prevImage)
+	if err == nil && len(changes) == 0 {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("Incompatible changes from %s:\n%s", prevImage, strings.Join(changes, "\n"))
+	}
+	if mode == hops.CompareModeWarn {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+
+	return fmt.Errorf("Comparison with previous image failed: %v", err)
+}
+
+// applyUruncVersion rewrites the annotations of the image for the release of
+// urunc that it targets, if any, and fails or warns about the annotations
+// that the release does not understand, depending on the mode.
+func applyUruncVersion(packInst *hops.PackInstructions, mode string) error {
+	unsupported, err := packInst.ApplyUruncVersion()
+	if err == nil && len(unsupported) == 0 {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("urunc %s does not understand %s", packInst.UruncVersion, strings.Join(unsupported, ", "))
+		if mode == hops.UruncCompatModeWarn {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Incompatible with the targeted urunc: %v", err)
+}
+
+// buildTarget solves only the kernel or the rootfs of the image, without any
+// of the checks and the configuration of the final image.
+func buildTarget(ctx context.Context, c client.Client, packInst hops.PackInstructions, target string) (*client.Result, error) {
+	dt, err := hops.TargetLLB(ctx, packInst, target)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	res, err := c.Solve(ctx, client.SolveRequest{
+		Definition: dt.ToPB(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve LLB of %s target: %v", target, err)
+	}
+
+	return res, nil
+}
+
+// addArtifacts solves the intermediate artifacts of the build and adds them
+// to the result of the final image.
+func addArtifacts(ctx context.Context, c client.Client, res *client.Result, packInst hops.PackInstructions) error {
+	defs, err := hops.ArtifactsLLB(ctx, packInst)
+	if err != nil {
+		return fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	refs := map[string]client.Reference{}
+	for target, def := range defs {
+		artifactRes, err := c.Solve(ctx, client.SolveRequest{
+			Definition: def.ToPB(),
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to resolve LLB of %s artifact: %v", target, err)
+		}
+		refs[target], err = artifactRes.SingleRef()
+		if err != nil {
+			return fmt.Errorf("Failed to get reference of %s artifact: %v", target, err)
+		}
+	}
+
+	err = hops.ApplyArtifacts(res, refs, packInst.Img)
+	if err != nil {
+		return err
+	}
+
+	// Link the artifacts to the image with an attestation
+	attDef, err := hops.ArtifactsAttestationLLB(packInst.Img, defs)
+	if err != nil {
+		return fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	attRes, err := c.Solve(ctx, client.SolveRequest{
+		Definition: attDef.ToPB(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to resolve LLB of attestation of artifacts: %v", err)
+	}
+	attRef, err := attRes.SingleRef()
+	if err != nil {
+		return fmt.Errorf("Failed to get reference of attestation of artifacts: %v", err)
+	}
+
+	return hops.AddArtifactsAttestation(res, attRef)
+}
+
+// publishMetadata attaches the urunc.json and the build report to the image
+// of the result as attestations.
+func publishMetadata(ctx context.Context, c client.Client, res *client.Result, uruncJSON []byte, report []byte) error {
+	def, err := hops.MetadataAttestationLLB(uruncJSON, report)
+	if err != nil {
+		return fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+	metaRes, err := c.Solve(ctx, client.SolveRequest{
+		Definition: def.ToPB(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to resolve LLB of metadata: %v", err)
+	}
+	ref, err := metaRes.SingleRef()
+	if err != nil {
+		return fmt.Errorf("Failed to get reference of metadata: %v", err)
+	}
+
+	return hops.AddMetadataAttestations(res, ref)
+}
+
+// withBuildTimeout returns a context that gets cancelled once the timeout of
+// build in the bunnyfile passes since the start of the build. The
+// cancellation aborts any solve in progress, e.g. of a kernel build.
+func withBuildTimeout(ctx context.Context, fileBytes []byte, start time.Time) (context.Context, context.CancelFunc) {
+	timeout := hops.BuildTimeout(fileBytes)
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadlineCause(ctx, start.Add(timeout),
+		fmt.Errorf("The build did not finish within the timeout of %s", timeout))
+}
+
+func bunnyBuilder(ctx context.Context, c client.Client) (result *client.Result, err error) {
+	start := time.Now()
+
 	// Get the Build options from buildkit
 	buildOpts := c.BuildOpts().Opts
 
-	// Get the file that contains the instructions
+	// Turn a crash into an error with what a bug report needs, instead of
+	// an opaque failure of the frontend, or optionally into a result with
+	// the report in its metadata
+	crash := hops.NewCrashReport(version, buildOpts)
+	defer func() {
+		if r := recover(); r != nil {
+			bundle, _ := strconv.ParseBool(buildOpts[clientOptCrash])
+			result, err = crash.Recover(r, debug.Stack(), bundle)
+			fmt.Fprintln(os.Stderr, crash.Error())
+		}
+	}()
+
+	// Get the file that contains the instructions, unless they are given
+	// inline, e.g. for builds without a build context
 	bunnyFile := buildOpts[clientOptFilename]
-	if bunnyFile == "" {
-		return nil, fmt.Errorf("Could not find %s", clientOptFilename)
+	inlineFile := buildOpts[clientOptInline]
+	if bunnyFile == "" && inlineFile == "" {
+		return nil, fmt.Errorf("Could not find %s or %s", clientOptFilename, clientOptInline)
+	}
+
+	// Get the target to build, the final image by default
+	target, err := hops.ParseTarget(buildOpts[clientOptTarget])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptTarget, err)
+	}
+
+	// Get how to create an initrd, with an exec by default
+	initrdMode, err := hops.ParseInitrdMode(buildOpts[clientOptInitrd])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptInitrd, err)
 	}
 
-	// Fetch and read contents of user-specified file in build context
-	fileBytes, err := readFileFromLLB(ctx, c, bunnyFile)
+	// Get how to handle incompatible changes from a previous image, if any
+	compareMode, err := hops.ParseCompareMode(buildOpts[clientOptCmpMode])
 	if err != nil {
-		return nil, fmt.Errorf("Failed to fetch and read %s: %w", clientOptFilename, err)
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptCmpMode, err)
+	}
+
+	// Get how to handle annotations that the targeted urunc does not
+	// understand
+	uruncCompatMode, err := hops.ParseUruncCompatMode(buildOpts[clientOptUrunc])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptUrunc, err)
+	}
+
+	// Limit and retry the resolutions of image metadata, so the rate limits
+	// of registries do not fail the build
+	limits, err := hops.ParseResolveLimits(buildOpts[clientOptResRetry], buildOpts[clientOptResConc])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s or %s option: %v", clientOptResRetry, clientOptResConc, err)
+	}
+	c = limits.Client(c)
+
+	// Get how to record the bunnyfile in the image, not at all by default
+	embedMode, err := hops.ParseEmbedMode(buildOpts[clientOptEmbed])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptEmbed, err)
+	}
+
+	// Get the maximum size of the files to read from the build context
+	maxSize, err := hops.ParseMaxFileSize(buildOpts[clientOptMaxSize])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptMaxSize, err)
+	}
+
+	// Get the registry mirrors and the OCI layouts to use, if any
+	var sources hops.SourceOpts
+	sources.Mirrors, err = hops.ParseMirrors(buildOpts[clientOptMirrors])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptMirrors, err)
+	}
+	layouts, err := hops.ParseLayouts(buildOpts[clientOptLayouts])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptLayouts, err)
+	}
+
+	// Transfer the bunnyfile, its .bunnyignore, the annotation policy, the
+	// allowlist of the tools and the OCI layouts from the build context at
+	// once
+	policyFile := buildOpts[clientOptPolicy]
+	allowlistFile := buildOpts[clientOptToolList]
+	var contextPaths []string
+	if inlineFile == "" {
+		contextPaths = append(contextPaths, bunnyFile, hops.BunnyignoreFile)
+	}
+	contextPaths = append(contextPaths, policyFile, allowlistFile)
+	for _, dir := range layouts {
+		contextPaths = append(contextPaths, dir)
+	}
+	files := &contextFiles{
+		c:        c,
+		patterns: hops.ContextPatterns(contextPaths...),
+		maxSize:  maxSize,
 	}
 
+	// Fetch and read contents of user-specified file in build context, if
+	// the instructions are not inline
+	var fileBytes []byte
+	if inlineFile != "" {
+		fileBytes, err = hops.DecodeInlineBunnyfile(inlineFile)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s option: %v", clientOptInline, err)
+		}
+	} else {
+		fileBytes, err = readBunnyfile(ctx, c, files, bunnyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to fetch and read %s: %w", clientOptFilename, err)
+		}
+	}
+	crash.SetFile(fileBytes)
+
+	// Abort the rest of the build, once the timeout of the bunnyfile passes
+	ctx, cancel := withBuildTimeout(ctx, fileBytes, start)
+	defer cancel()
+	defer func() {
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%w: %v", context.Cause(ctx), err)
+		}
+	}()
+
+	// Leave the files of the .bunnyignore out of the build context, if the
+	// bunnyfile comes from it
+	if inlineFile == "" {
+		ignoreBytes, err := files.readOptionalFile(ctx, hops.BunnyignoreFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to fetch and read %s: %w", hops.BunnyignoreFile, err)
+		}
+		sources.Exclude = hops.ParseIgnoreFile(ignoreBytes)
+	}
+
+	sources.Layouts, err = readLayouts(ctx, files, layouts)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read OCI layouts: %w", err)
+	}
+	sources.Network, err = hops.ParseNetworkPolicy(buildOpts[clientOptRetries], buildOpts[clientOptTimeout])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s or %s option: %v", clientOptRetries, clientOptTimeout, err)
+	}
+
+	// Pass the proxies of the build arguments to every step of the build
+	buildArgs := map[string]string{}
+	for k, v := range buildOpts {
+		if strings.HasPrefix(k, buildArgPrefix) {
+			buildArgs[strings.TrimPrefix(k, buildArgPrefix)] = v
+		}
+	}
+	sources.Network.Proxy = hops.ProxyFromBuildArgs(buildArgs)
+	sources.BuildArgs = buildArgs
+
+	// Get the annotations that the operators expect in every image, if any
+	var policy *hops.AnnotationPolicy
+	if policyFile != "" {
+		policyBytes, err := files.readFile(ctx, policyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to fetch and read %s: %w", clientOptPolicy, err)
+		}
+		p, err := hops.ParseAnnotationPolicy(policyBytes)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s option: %v", clientOptPolicy, err)
+		}
+		policy = &p
+	}
+
+	// Optionally harden the tools that run inside the build
+	sources.Hardened, _ = strconv.ParseBool(buildOpts[clientOptHardened])
+	sources.Resolver = c
+
+	// Record the digests of the tool images, which get pinned, and
+	// optionally allow only the digests of the allowlist
+	pinTools, _ := strconv.ParseBool(buildOpts[clientOptPinTools])
+	if allowlistFile != "" {
+		allowlistBytes, err := files.readFile(ctx, allowlistFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to fetch and read %s: %w", clientOptToolList, err)
+		}
+		allowed, err := hops.ParseToolAllowlist(allowlistBytes)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s option: %v", clientOptToolList, err)
+		}
+		sources.Tools = hops.NewToolDigests(allowed)
+	} else if pinTools || sources.Hardened {
+		sources.Tools = hops.NewToolDigests(nil)
+	}
+
+	// Optionally check bunnyfiles against the stricter typed schema
+	sources.StrictSchema, _ = strconv.ParseBool(buildOpts[clientOptSchema])
+
+	// Optionally fail early for local files to include that do not exist
+	sources.CheckIncludes, _ = strconv.ParseBool(buildOpts[clientOptIncludes])
+
+	// Get how to pull images from the unikraft.org catalog
+	sources.UnikraftPull, err = hops.ParseUnikraftPull(buildOpts[clientOptUnikraft])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s option: %v", clientOptUnikraft, err)
+	}
+
+	opts := imageOpts{
+		target:          target,
+		initrdMode:      initrdMode,
+		compareMode:     compareMode,
+		uruncCompatMode: uruncCompatMode,
+		embedMode:       embedMode,
+		policy:          policy,
+	}
+
+	// Build every variant of a bunnyfile with many documents, unless the
+	// options request just one of them
+	variants, err := hops.ParseVariants(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing building instructions: %v", err)
+	}
+	if name := buildOpts[clientOptVariant]; name != "" {
+		fileBytes, err = hops.SelectVariant(fileBytes, name)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s option: %v", clientOptVariant, err)
+		}
+		variants = nil
+	}
+	if len(variants) == 0 {
+		return buildFile(ctx, c, fileBytes, sources, opts)
+	}
+	if target != hops.TargetImage {
+		return nil, fmt.Errorf("The %s target requires the %s option for a bunnyfile with variants", target, clientOptVariant)
+	}
+	names := make([]string, len(variants))
+	results := make([]*client.Result, len(variants))
+	for j, v := range variants {
+		// Each variant records its own tools
+		variantSources := sources
+		variantSources.Tools = sources.Tools.Empty()
+		res, err := buildFile(ctx, c, v.File, variantSources, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Variant %s: %w", v.Name, err)
+		}
+		names[j] = v.Name
+		results[j] = res
+	}
+
+	return hops.ApplyVariants(names, results)
+}
+
+// imageOpts are the options of the frontend that apply to every image that
+// a file builds
+type imageOpts struct {
+	// The target to build
+	target string
+	// How to create an initrd
+	initrdMode string
+	// How to handle incompatible changes from a previous image
+	compareMode string
+	// How to handle annotations that the targeted urunc does not understand
+	uruncCompatMode string
+	// How to record the bunnyfile in the image
+	embedMode string
+	// The annotations that the operators expect in every image, if any
+	policy *hops.AnnotationPolicy
+}
+
+// buildFile builds the image of the given file, which is a Containerfile, a
+// bunnyfile or a variant of a bunnyfile, with the given options
+func buildFile(ctx context.Context, c client.Client, fileBytes []byte, sources hops.SourceOpts, opts imageOpts) (*client.Result, error) {
+	buildOpts := c.BuildOpts().Opts
+
 	// Parse packaging/building instructions
-	packInst, err := hops.ParseFile(ctx, fileBytes, buildContextName, c)
+	packInst, err := hops.ParseFile(ctx, fileBytes, buildContextName, c, sources)
 	if err != nil {
 		return nil, fmt.Errorf("Error parsing building instructions: %v", err)
 	}
 
+	// Apply the annotation policy before any check of the annotations
+	if opts.policy != nil {
+		err = packInst.ApplyPolicy(*opts.policy)
+		if err != nil {
+			return nil, fmt.Errorf("Annotations violate the policy: %v", err)
+		}
+	}
+
+	// Name the image after the templates of the options, if any, before
+	// the build starts
+	var imageNames string
+	if tmpl := buildOpts[clientOptNames]; tmpl != "" {
+		imageNames, err = packInst.ImageNames(tmpl, packInst.Sources.BuildArgs)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s option: %v", clientOptNames, err)
+		}
+	}
+
+	// Optionally reject unknown urunc annotations, which are most likely typos
+	if strict, _ := strconv.ParseBool(buildOpts[clientOptStrict]); strict {
+		err = hops.ValidateAnnotations(packInst.Annots)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid annotations: %v", err)
+		}
+	}
+
+	// Guard the rollouts of a tag against incompatible changes
+	if prevImage := buildOpts[clientOptCompare]; prevImage != "" {
+		err = compareWithImage(ctx, c, *packInst, prevImage, opts.compareMode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Rewrite the annotations for the release of urunc that runs the image,
+	// after the checks that expect the annotations of the latest one
+	err = applyUruncVersion(packInst, opts.uruncCompatMode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure that a prebuilt kernel matches the declared architecture
+	if packInst.KernelCheck != nil {
+		err = runKernelCheck(ctx, c, *packInst)
+		if err != nil {
+			return nil, fmt.Errorf("Kernel inspection failed: %v", err)
+		}
+	}
+
+	// Keep the old behavior of storing every annotation in urunc.json
+	packInst.AllAnnotsInUruncJSON, _ = strconv.ParseBool(buildOpts[clientOptAllAnnot])
+
+	// Create the initrd without any exec, e.g. for rootless buildkitd
+	if opts.initrdMode == hops.InitrdModeFile && packInst.Rootfs != nil && packInst.Rootfs.InitrdContent != nil {
+		err = createInitrdFile(ctx, c, packInst)
+		if err != nil {
+			return nil, fmt.Errorf("Initrd creation failed: %v", err)
+		}
+	}
+
+	// Build just the kernel or the rootfs, if requested
+	if opts.target != hops.TargetImage {
+		return buildTarget(ctx, c, *packInst, opts.target)
+	}
+
+	// Fail early with the log of the kernel build, if it failed
+	if packInst.Kernel != nil && packInst.Kernel.SourceRef == hops.KernelFromBuild {
+		err = runKernelBuild(ctx, c, *packInst)
+		if err != nil {
+			return nil, fmt.Errorf("Kernel build failed: %v", err)
+		}
+	}
+
+	// Record the bunnyfile that produced the image, if requested
+	err = packInst.EmbedBunnyfile(fileBytes, opts.embedMode)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to embed the bunnyfile in the image: %v", err)
+	}
+
 	// Create the LLB definition of packing the final image
-	dt, err := hops.PackLLB(*packInst)
+	dt, err := hops.PackLLB(ctx, *packInst)
 	if err != nil {
 		return nil, fmt.Errorf("Could not create LLB definition: %v", err)
 	}
 
+	// Keep the urunc.json of the image, before the build information
+	// reaches the annotations
+	uruncJSON, err := hops.UruncJSON(*packInst)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create urunc.json: %v", err)
+	}
+
+	// Record the versions and the LLB digest that produced the image
+	err = packInst.SetBuildInfo(version, dt)
+	if err != nil {
+		return nil, fmt.Errorf("Could not set build information: %v", err)
+	}
+
 	// Pass LLB to buildkit
+	solveStart := time.Now()
 	buildkitRes, err := c.Solve(ctx, client.SolveRequest{
 		Definition: dt.ToPB(),
 	})
@@ -136,18 +884,206 @@ func bunnyBuilder(ctx context.Context, c client.Client) (*client.Result, error)
 		return nil, fmt.Errorf("Failed to resolve LLB: %v", err)
 	}
 
+	// Keep a summary of the build in the result's metadata and the logs
+	summary := hops.DefinitionSummary(dt, time.Since(solveStart))
+	summary.Base = packInst.BaseDecision
+	if packInst.Sources.Tools != nil {
+		summary.Tools = packInst.Sources.Tools.Pinned()
+	}
+	summaryBytes, err := json.Marshal(summary)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal build summary: %v", err)
+	}
+	buildkitRes.AddMeta(hops.BuildSummaryMetaKey, summaryBytes)
+	fmt.Fprint(os.Stderr, summary.String())
+
+	// Optionally verify that the result contains everything urunc needs
+	if verify, _ := strconv.ParseBool(buildOpts[clientOptVerify]); verify {
+		ref, err := buildkitRes.SingleRef()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get reference of result for verification: %v", err)
+		}
+		err = hops.VerifyResult(ctx, ref, packInst.Annots)
+		if err != nil {
+			return nil, fmt.Errorf("Verification of final image failed: %v", err)
+		}
+	}
+
+	// Boot the final image and check for the boot marker, if requested
+	if packInst.Test.Enabled() {
+		err = runSmokeTest(ctx, c, buildkitRes, *packInst)
+		if err != nil {
+			return nil, fmt.Errorf("Smoke test of final image failed: %v", err)
+		}
+	}
+
+	// Scan the rootfs for vulnerabilities, if requested
+	var scanRef client.Reference
+	if packInst.Scan.Enabled {
+		scanRef, err = runScan(ctx, c, buildkitRes, *packInst)
+		if err != nil {
+			return nil, fmt.Errorf("Vulnerability scan of final image failed: %v", err)
+		}
+	}
+
+	// Record the tools of every step, including the smoke test and the scan
+	packInst.SetToolsAnnotation(packInst.Sources.Tools)
+
+	// Set when the image was created, before its history gets the time
+	err = packInst.SetCreated(buildOpts[buildArgPrefix+hops.SourceDateEpochArg], time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("Invalid build argument: %v", err)
+	}
+
+	// Describe the layers of packing in the history of the image
+	packInst.SetHistory()
+
 	// Apply annotations and the new config to the solver's result
 	err = hops.ApplyConfig(buildkitRes, packInst.Annots, packInst.Img)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to annotate final image: %v", err)
 	}
 
+	// The image exporter uses these names, if its name is *
+	if imageNames != "" {
+		hops.SetImageNames(buildkitRes, imageNames)
+	}
+
+	// Export the kernel and the rootfs alongside the image, if requested
+	if packInst.Artifacts.Enabled() {
+		err = addArtifacts(ctx, c, buildkitRes, *packInst)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to add artifacts: %v", err)
+		}
+	}
+
+	// Attach the report of the scan to the image
+	if scanRef != nil {
+		err = hops.AddScanAttestation(buildkitRes, scanRef)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to attach the report of the scan: %v", err)
+		}
+	}
+
+	// Optionally attach the metadata of the image for runtime agents
+	if publish, _ := strconv.ParseBool(buildOpts[clientOptMetadata]); publish {
+		err = publishMetadata(ctx, c, buildkitRes, uruncJSON, summaryBytes)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to publish metadata: %v", err)
+		}
+	}
+
 	return buildkitRes, nil
 }
 
+// fileToLLB reads the given file and creates the LLB definition of the given
+// target and variant for a worker of the given platform, without access to a
+// buildkit client. An empty platform means a linux worker with the host architecture.
+// The resolver of the sources, if set, resolves the metadata of images (e.g.
+// to pin the tool images of hardened builds).
+func fileToLLB(filename string, target string, variant string, platform string, sources hops.SourceOpts) (*llb.Definition, error) {
+	var err error
+	sources.Arch, err = hops.ParsePlatform(platform)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid platform: %v", err)
+	}
+	fileBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read %s: %v", filename, err)
+	}
+	fileBytes, err = hops.SelectVariant(fileBytes, variant)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse file with packaging/building instructions
+	ctx := context.Background()
+	packInst, err := hops.ParseFile(ctx, fileBytes, buildContextName, nil, sources)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse building instructions: %v", err)
+	}
+	err = applyUruncVersion(packInst, hops.UruncCompatModeFail)
+	if err != nil {
+		return nil, err
+	}
+	if packInst.BaseDecision != nil {
+		fmt.Fprint(os.Stderr, packInst.BaseDecision.String())
+	}
+
+	// Create the LLB definition of the target
+	dt, err := hops.TargetLLB(ctx, *packInst, target)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create LLB definition: %v", err)
+	}
+
+	return dt, nil
+}
+
+// readBuildArgs returns the build arguments of the env files and then of
+// --build-arg, so the latter take precedence, as in buildx
+func readBuildArgs(envFiles []string, buildArgs []string) (map[string]string, error) {
+	args := map[string]string{}
+	for _, envFile := range envFiles {
+		data, err := os.ReadFile(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read %s: %v", envFile, err)
+		}
+		fileArgs, err := hops.ParseEnvFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid env file %s: %v", envFile, err)
+		}
+		maps.Copy(args, fileArgs)
+	}
+	cliArgs, err := hops.ParseBuildArgs(buildArgs)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(args, cliArgs)
+
+	return args, nil
+}
+
+// explainFile prints the operations of the given LLB definition that each
+// field of the bunnyfile (or of its given variant) generated, with the given
+// build arguments
+func explainFile(filename string, variant string, buildArgs map[string]string, dt *llb.Definition) error {
+	fileBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %v", filename, err)
+	}
+	fileBytes, err = hops.SelectVariant(fileBytes, variant)
+	if err != nil {
+		return err
+	}
+	bunnyHops, err := hops.ParseBunnyfileArgs(fileBytes, buildArgs)
+	if err != nil {
+		return fmt.Errorf("The --explain argument requires a bunnyfile: %v", err)
+	}
+	provenance, err := hops.Explain(bunnyHops, buildContextName, dt)
+	if err != nil {
+		return err
+	}
+	fmt.Print(hops.FormatExplain(provenance))
+
+	return nil
+}
+
 func main() {
 	var cliOpts CLIOpts
-	var packInst *hops.PackInstructions
+
+	// Handle subcommands before the global flags
+	if len(os.Args) > 1 {
+		if subCmd, ok := subcommands[os.Args[1]]; ok {
+			if err := subCmd(os.Args[2:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return
+				}
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 
 	cliOpts = parseCLIOpts()
 
@@ -157,7 +1093,7 @@ func main() {
 		return
 	}
 
-	if !cliOpts.PrintLLB {
+	if !cliOpts.PrintLLB && !cliOpts.Explain {
 		// Run as buildkit frontend
 		ctx := appcontext.Context()
 		if err := grpcclient.RunFromEnvironment(ctx, bunnyBuilder); err != nil {
@@ -175,25 +1111,33 @@ func main() {
 		os.Exit(1)
 	}
 
-	CntrFileContent, err := os.ReadFile(cliOpts.ContainerFile)
+	var sources hops.SourceOpts
+	limits := hops.NewResolveLimits(hops.DefaultResolveRetries, hops.DefaultResolveConcurrency)
+	sources.Resolver = limits.MetaResolver(registryResolver{})
+	if cliOpts.MetaCache != "" {
+		sources.Resolver = hops.MetaCache{Dir: cliOpts.MetaCache, TTL: cliOpts.MetaCacheTTL}.MetaResolver(sources.Resolver)
+	}
+	// Pass the build arguments as buildx would, proxies included
+	buildArgs, err := readBuildArgs(cliOpts.EnvFiles, cliOpts.BuildArgs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Could not read %s: %v\n", cliOpts.ContainerFile, err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Parse file with packaging/building instructions
-	ctx := context.Background()
-	packInst, err = hops.ParseFile(ctx, CntrFileContent, buildContextName, nil)
+	sources.BuildArgs = buildArgs
+	sources.Network.Proxy = hops.ProxyFromBuildArgs(buildArgs)
+	dt, err := fileToLLB(cliOpts.ContainerFile, cliOpts.Target, cliOpts.Variant, cliOpts.Platform, sources)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Could not parse building instructions: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Create the LLB definition of packing the final image
-	dt, err := hops.PackLLB(*packInst)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Could not create LLB definition: %v\n", err)
-		os.Exit(1)
+	if cliOpts.Explain {
+		err = explainFile(cliOpts.ContainerFile, cliOpts.Variant, buildArgs, dt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Print the LLB to give it as input in buildctl
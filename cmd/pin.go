@@ -0,0 +1,132 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"bunny/hops"
+
+	"github.com/distribution/reference"
+)
+
+type PinOpts struct {
+	// The bunnyfile or Containerfile with the syntax directive
+	File string
+	// The frontend image, the one of the file by default
+	Image string
+	// Set the tag of the frontend image, instead of pinning its digest
+	Tag string
+	// Only check if the file pins the current frontend image
+	Check bool
+}
+
+func parsePinOpts(args []string) (PinOpts, error) {
+	var opts PinOpts
+
+	fs := flag.NewFlagSet("pin", flag.ContinueOnError)
+	fs.StringVar(&opts.File, "file", "", "Path to the bunnyfile or Containerfile")
+	fs.StringVar(&opts.File, "f", "", "Path to the bunnyfile or Containerfile")
+	fs.StringVar(&opts.Image, "image", "", "The frontend image (default: the one of the file)")
+	fs.StringVar(&opts.Tag, "tag", "", "Set the tag of the frontend image, instead of pinning its digest")
+	fs.BoolVar(&opts.Check, "check", false, "Only check if the file pins the current frontend image")
+	fs.Usage = func() {
+		fmt.Println("Usage of bunny pin")
+		fmt.Printf("%s pin [<args>]\n\n", os.Args[0])
+		fmt.Println("Pin the syntax directive of a file to the digest of the frontend image")
+		fmt.Println("Supported command line arguments")
+		fmt.Println("\t-f, --file filename \t\tPath to the bunnyfile or Containerfile")
+		fmt.Println("\t--image name \t\t\tThe frontend image (default: the one of the file)")
+		fmt.Println("\t--tag tag \t\t\tSet the tag of the frontend image, instead of pinning its digest")
+		fmt.Println("\t--check bool \t\t\tOnly check if the file pins the current frontend image")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+	if opts.File == "" {
+		return opts, fmt.Errorf("The --file argument is necessary")
+	}
+	if opts.Check && opts.Tag != "" {
+		return opts, fmt.Errorf("Only one of --check or --tag can be set")
+	}
+
+	return opts, nil
+}
+
+func pinCommand(args []string) error {
+	opts, err := parsePinOpts(args)
+	if err != nil {
+		return err
+	}
+	file, err := os.ReadFile(opts.File)
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %v", opts.File, err)
+	}
+
+	if warning := hops.SchemaWarning(file); warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if warning := hops.SyntaxWarning(file); warning != "" && opts.Tag == "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	current, hasSyntax := hops.SyntaxImage(file)
+	image := opts.Image
+	if image == "" {
+		image = current
+	}
+	if image == "" {
+		image = defaultSyntaxImage
+	}
+	if opts.Tag != "" {
+		named, err := reference.ParseNormalizedNamed(image)
+		if err != nil {
+			return fmt.Errorf("Failed to parse image name %s: %v", image, err)
+		}
+		pinned := reference.FamiliarName(named) + ":" + opts.Tag
+		out := hops.SetSyntaxImage(file, pinned)
+		if warning := hops.SyntaxWarning(out); warning != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+		return os.WriteFile(opts.File, out, 0644)
+	}
+
+	dgst, err := resolveDigest(image)
+	if err != nil {
+		return err
+	}
+	pinned, err := hops.PinnedSyntaxImage(image, dgst)
+	if err != nil {
+		return err
+	}
+	if !opts.Check {
+		return os.WriteFile(opts.File, hops.SetSyntaxImage(file, pinned), 0644)
+	}
+
+	switch {
+	case !hasSyntax:
+		fmt.Printf("%s has no syntax directive, the current frontend is %s\n", opts.File, pinned)
+	case current == pinned:
+		fmt.Printf("%s pins the current frontend %s\n", opts.File, pinned)
+	default:
+		fmt.Printf("%s uses %s, while the current frontend is %s\n", opts.File, current, pinned)
+	}
+
+	return nil
+}
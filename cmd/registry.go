@@ -0,0 +1,263 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// The media types of the manifests that the registry can return for an
+// image, with the multi-platform ones first
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var authParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// The maximum size of a manifest or a config that bunny reads
+const maxRegistryObjectSize int64 = 4 << 20
+
+// registryToken gets an anonymous token from the authorization service that
+// the challenge of the registry points to.
+func registryToken(challenge string) (string, error) {
+	params := map[string]string{}
+	for _, m := range authParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	if !strings.HasPrefix(challenge, "Bearer ") || params["realm"] == "" {
+		return "", fmt.Errorf("Unsupported authentication challenge %q", challenge)
+	}
+	query := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			query.Set(k, params[k])
+		}
+	}
+	resp, err := http.Get(params["realm"] + "?" + query.Encode())
+	if err != nil {
+		return "", fmt.Errorf("Failed to get token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to get token: %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode token: %v", err)
+	}
+	if token.Token == "" {
+		return token.AccessToken, nil
+	}
+
+	return token.Token, nil
+}
+
+// registryRepo is a repository of a registry that bunny talks to with the
+// HTTP API of the registry
+type registryRepo struct {
+	host  string
+	path  string
+	token string
+}
+
+func newRegistryRepo(named reference.Named) *registryRepo {
+	host := reference.Domain(named)
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+
+	return &registryRepo{host: host, path: reference.Path(named)}
+}
+
+// do sends a request for the given object (e.g. manifests/latest) of the
+// repository. An anonymous token gets requested, if the registry asks for
+// one. The caller should close the body of the response.
+func (r *registryRepo) do(ctx context.Context, method string, object string, accept []string) (*http.Response, error) {
+	objectURL := fmt.Sprintf("https://%s/v2/%s/%s", r.host, r.path, object)
+	for range 2 {
+		req, err := http.NewRequestWithContext(ctx, method, objectURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || r.token != "" {
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return nil, fmt.Errorf("%s", resp.Status)
+			}
+			return resp, nil
+		}
+		resp.Body.Close()
+		r.token, err = registryToken(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to authenticate to %s: %v", r.host, err)
+		}
+	}
+
+	return nil, fmt.Errorf("Failed to authenticate to %s", r.host)
+}
+
+// get returns the given object of the repository, along with its media type
+// and its digest. If expected is set, the content should match it.
+func (r *registryRepo) get(ctx context.Context, object string, accept []string, expected digest.Digest) ([]byte, string, digest.Digest, error) {
+	resp, err := r.do(ctx, http.MethodGet, object, accept)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRegistryObjectSize))
+	if err != nil {
+		return nil, "", "", err
+	}
+	dgst := digest.FromBytes(data)
+	if expected != "" && expected != dgst {
+		return nil, "", "", fmt.Errorf("The content of %s does not match its digest", object)
+	}
+
+	return data, resp.Header.Get("Content-Type"), dgst, nil
+}
+
+// resolveDigest returns the digest of the manifest of the given image, as the
+// registry reports it, without pulling the image.
+func resolveDigest(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse image name %s: %v", image, err)
+	}
+	tag := "latest"
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+
+	resp, err := newRegistryRepo(named).do(context.Background(), http.MethodHead, "manifests/"+tag, manifestMediaTypes)
+	if err != nil {
+		return "", fmt.Errorf("Failed to resolve the digest of %s: %v", image, err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Docker-Content-Digest") == "" {
+		return "", fmt.Errorf("The registry did not return the digest of %s", image)
+	}
+
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// registryResolver resolves the metadata of images directly from their
+// registries, for the local CLI mode where there is no buildkit to ask
+type registryResolver struct{}
+
+// matchPlatform returns the manifest of the index for the given platform
+func matchPlatform(index ocispecs.Index, platform ocispecs.Platform) (ocispecs.Descriptor, bool) {
+	for _, m := range index.Manifests {
+		if m.Platform == nil || m.Platform.OS != platform.OS || m.Platform.Architecture != platform.Architecture {
+			continue
+		}
+		if platform.Variant != "" && m.Platform.Variant != platform.Variant {
+			continue
+		}
+		return m, true
+	}
+
+	return ocispecs.Descriptor{}, false
+}
+
+func (registryResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("Failed to parse image name %s: %v", ref, err)
+	}
+	named = reference.TagNameOnly(named)
+	object := "manifests/"
+	var expected digest.Digest
+	if digested, ok := named.(reference.Digested); ok {
+		expected = digested.Digest()
+		object += expected.String()
+	} else {
+		object += named.(reference.Tagged).Tag()
+	}
+	platform := ocispecs.Platform{OS: "linux", Architecture: runtime.GOARCH}
+	if opt.ImageOpt != nil && opt.ImageOpt.Platform != nil {
+		platform = *opt.ImageOpt.Platform
+	}
+
+	repo := newRegistryRepo(named)
+	data, mediaType, dgst, err := repo.get(ctx, object, manifestMediaTypes, expected)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("Failed to get the manifest of %s: %v", ref, err)
+	}
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+		ocispecs.Index
+		Config ocispecs.Descriptor `json:"config"`
+	}
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("Failed to decode the manifest of %s: %v", ref, err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = mediaType
+	}
+	// The digest of the reference is the one of the index, so it matches
+	// every platform, as with the resolver of buildkit
+	if manifest.MediaType == manifestMediaTypes[0] || manifest.MediaType == manifestMediaTypes[1] {
+		desc, ok := matchPlatform(manifest.Index, platform)
+		if !ok {
+			return "", "", nil, fmt.Errorf("The image %s has no manifest for %s/%s", ref, platform.OS, platform.Architecture)
+		}
+		data, _, _, err = repo.get(ctx, "manifests/"+desc.Digest.String(), manifestMediaTypes, desc.Digest)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("Failed to get the manifest of %s: %v", ref, err)
+		}
+		err = json.Unmarshal(data, &manifest)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("Failed to decode the manifest of %s: %v", ref, err)
+		}
+	}
+
+	config, _, _, err := repo.get(ctx, "blobs/"+manifest.Config.Digest.String(), nil, manifest.Config.Digest)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("Failed to get the config of %s: %v", ref, err)
+	}
+
+	return ref, dgst, config, nil
+}
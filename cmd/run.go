@@ -0,0 +1,184 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"bunny/hops"
+)
+
+type RunOpts struct {
+	// The bunnyfile or Containerfile to build before running
+	File string
+	// An existing image in OCI layout (directory or tar archive) to run
+	Image string
+	// The local build context to use for building
+	Context string
+	// Override the monitor of the image
+	Monitor string
+	// The memory of the VM in MiB
+	Memory int
+	// Use KVM acceleration
+	KVM bool
+	// Just print the monitor's command instead of running it
+	DryRun bool
+	// Print a summary of the build
+	Summary bool
+	// The build caches to import from and export to
+	Cache hops.CacheOpts
+}
+
+func kvmAvailable() bool {
+	_, err := os.Stat("/dev/kvm")
+	return err == nil
+}
+
+func parseRunOpts(args []string) (RunOpts, error) {
+	var opts RunOpts
+
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.StringVar(&opts.File, "file", "", "Path to the bunnyfile or Containerfile to build and run")
+	fs.StringVar(&opts.File, "f", "", "Path to the bunnyfile or Containerfile to build and run")
+	fs.StringVar(&opts.Image, "image", "", "Path to an existing image in OCI layout (directory or tar)")
+	fs.StringVar(&opts.Context, "context", ".", "Path to the local build context")
+	fs.StringVar(&opts.Monitor, "monitor", "", "Override the monitor of the image (qemu, firecracker, cloud-hypervisor)")
+	fs.IntVar(&opts.Memory, "memory", 0, "Memory of the VM in MiB")
+	fs.BoolVar(&opts.KVM, "kvm", kvmAvailable(), "Use KVM acceleration")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Print the monitor command instead of running it")
+	fs.BoolVar(&opts.Summary, "summary", false, "Print a summary of the build with cache statistics")
+	addCacheFlags(fs, &opts.Cache)
+	fs.Usage = func() {
+		fmt.Println("Usage of bunny run")
+		fmt.Printf("%s run [<args>]\n\n", os.Args[0])
+		fmt.Println("Build (or take an existing image) and boot it locally")
+		fmt.Println("Supported command line arguments")
+		fmt.Println("\t-f, --file filename \t\tPath to the bunnyfile or Containerfile to build")
+		fmt.Println("\t--image path \t\t\tPath to an existing image in OCI layout (directory or tar)")
+		fmt.Println("\t--context path \t\t\tPath to the local build context (default: .)")
+		fmt.Println("\t--monitor name \t\t\tOverride the monitor of the image (qemu, firecracker, cloud-hypervisor)")
+		fmt.Println("\t--memory MiB \t\t\tMemory of the VM (default: 512)")
+		fmt.Println("\t--kvm bool \t\t\tUse KVM acceleration (default: true if /dev/kvm exists)")
+		fmt.Println("\t--dry-run bool \t\t\tPrint the monitor command instead of running it")
+		fmt.Println("\t--summary bool \t\t\tPrint a summary of the build with cache statistics")
+		fmt.Println("\t--cache-from cache \t\tBuild cache to import from (image, directory or buildctl cache spec)")
+		fmt.Println("\t--cache-to cache \t\tBuild cache to export to (image, directory or buildctl cache spec)")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+	if opts.File == "" && opts.Image == "" {
+		return opts, fmt.Errorf("One of --file or --image is necessary")
+	}
+	if opts.File != "" && opts.Image != "" {
+		return opts, fmt.Errorf("Only one of --file or --image can be set")
+	}
+
+	return opts, nil
+}
+
+func monitorCommand(annots map[string]string, opts RunOpts, workDir string, rootDir string) ([]string, error) {
+	monitor := opts.Monitor
+	if monitor == "" {
+		monitor = annots["com.urunc.unikernel.hypervisor"]
+	}
+	monOpts := hops.MonitorOpts{
+		RootDir: rootDir,
+		Memory:  opts.Memory,
+		KVM:     opts.KVM,
+	}
+
+	switch hops.NormalizeMonitor(monitor) {
+	case "qemu":
+		return hops.QemuCmd(annots, monOpts)
+	case "firecracker":
+		cfg, err := hops.FirecrackerConfig(annots, monOpts)
+		if err != nil {
+			return nil, err
+		}
+		cfgPath := filepath.Join(workDir, "firecracker.json")
+		err = os.WriteFile(cfgPath, cfg, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to write firecracker config: %v", err)
+		}
+		return []string{"firecracker", "--no-api", "--config-file", cfgPath}, nil
+	case hops.MonitorCloudHypervisor:
+		return hops.CloudHypervisorCmd(annots, monOpts)
+	default:
+		return nil, fmt.Errorf("Running with monitor %q is not supported", monitor)
+	}
+}
+
+func runCommand(args []string) error {
+	opts, err := parseRunOpts(args)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := os.MkdirTemp("", "bunny-run-")
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary directory: %v", err)
+	}
+	if !opts.DryRun {
+		defer os.RemoveAll(workDir)
+	}
+
+	image := opts.Image
+	if image == "" {
+		image = filepath.Join(workDir, "image")
+		err = buildImage(BuildOpts{
+			File:    opts.File,
+			Context: opts.Context,
+			Output:  "type=oci,tar=false,dest=" + image,
+			Target:  hops.TargetImage,
+			Cache:   opts.Cache,
+			Summary: opts.Summary,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	rootDir := filepath.Join(workDir, "rootfs")
+	annots, err := hops.ExtractOCIImage(image, rootDir)
+	if err != nil {
+		return fmt.Errorf("Failed to extract image: %v", err)
+	}
+
+	monCmd, err := monitorCommand(annots, opts, workDir, rootDir)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		fmt.Println(strings.Join(monCmd, " "))
+		fmt.Fprintf(os.Stderr, "The image was extracted in %s\n", workDir)
+		return nil
+	}
+
+	cmd := exec.Command(monCmd[0], monCmd[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"bunny/hops"
+)
+
+func schemaCommand(args []string) error {
+	var file string
+
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.StringVar(&file, "file", "", "Check the bunnyfile against the schema, instead of printing it")
+	fs.StringVar(&file, "f", "", "Check the bunnyfile against the schema, instead of printing it")
+	fs.Usage = func() {
+		fmt.Println("Usage of bunny schema")
+		fmt.Printf("%s schema [<args>]\n\n", os.Args[0])
+		fmt.Println("Print the CUE schema of the bunnyfile or check a bunnyfile against it")
+		fmt.Println("Supported command line arguments")
+		fmt.Println("\t-f, --file filename \t\tCheck the bunnyfile against the schema, instead of printing it")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		fmt.Print(hops.BunnyfileSchema)
+		return nil
+	}
+
+	fileBytes, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %v", file, err)
+	}
+	err = hops.ValidateSchema(fileBytes)
+	if err != nil {
+		return fmt.Errorf("%s does not match the schema:\n%v", file, err)
+	}
+	fmt.Printf("%s matches the schema\n", file)
+
+	return nil
+}
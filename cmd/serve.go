@@ -0,0 +1,53 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"bunny/hops"
+)
+
+func serveCommand(args []string) error {
+	var listen string
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.StringVar(&listen, "listen", "127.0.0.1:8080", "Address to listen on")
+	fs.Usage = func() {
+		fmt.Println("Usage of bunny serve")
+		fmt.Printf("%s serve [<args>]\n\n", os.Args[0])
+		fmt.Println("Serve the validate, plan and LLB endpoints over HTTP")
+		fmt.Println("Supported command line arguments")
+		fmt.Println("\t--listen address \t\tAddress to listen on (default: 127.0.0.1:8080)")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           hops.NewService(buildContextName),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", listen)
+
+	return server.ListenAndServe()
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bunny/hops"
+)
+
+type WatchOpts struct {
+	// The options of every build
+	Build BuildOpts
+	// How often to check for changes
+	Interval time.Duration
+	// Paths of the build context that do not trigger a build
+	Exclude []string
+}
+
+func parseWatchOpts(args []string) (WatchOpts, error) {
+	var opts WatchOpts
+
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.StringVar(&opts.Build.File, "file", "", "Path to the bunnyfile or Containerfile to build")
+	fs.StringVar(&opts.Build.File, "f", "", "Path to the bunnyfile or Containerfile to build")
+	fs.StringVar(&opts.Build.Context, "context", ".", "Path to the local build context")
+	fs.StringVar(&opts.Build.Output, "output", "type=oci,tar=false,dest=image", "Output of the build, as in buildctl or containerd,name=image")
+	fs.StringVar(&opts.Build.Target, "target", hops.TargetImage, "Build only the kernel, the rootfs or the image")
+	fs.BoolVar(&opts.Build.Summary, "summary", false, "Print a summary of every build with cache statistics")
+	fs.DurationVar(&opts.Interval, "interval", time.Second, "How often to check for changes")
+	fs.Var((*stringList)(&opts.Exclude), "exclude", "Path in the build context that does not trigger a build")
+	addCacheFlags(fs, &opts.Build.Cache)
+	fs.Usage = func() {
+		fmt.Println("Usage of bunny watch")
+		fmt.Printf("%s watch [<args>]\n\n", os.Args[0])
+		fmt.Println("Build an image locally with buildctl and build it again on every change")
+		fmt.Println("Supported command line arguments")
+		fmt.Println("\t-f, --file filename \t\tPath to the bunnyfile or Containerfile to build")
+		fmt.Println("\t--context path \t\t\tPath to the local build context (default: .)")
+		fmt.Println("\t--output spec \t\t\tOutput of the build, as in buildctl or containerd,name=image (default: type=oci,tar=false,dest=image)")
+		fmt.Println("\t--target name \t\t\tBuild only the kernel, the rootfs or the image (default: image)")
+		fmt.Println("\t--interval duration \t\tHow often to check for changes (default: 1s)")
+		fmt.Println("\t--exclude path \t\t\tPath in the build context that does not trigger a build")
+		fmt.Println("\t--cache-from cache \t\tBuild cache to import from (image, directory or buildctl cache spec)")
+		fmt.Println("\t--cache-to cache \t\tBuild cache to export to (image, directory or buildctl cache spec)")
+		fmt.Println("\t--summary bool \t\t\tPrint a summary of every build with cache statistics")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Build.File == "" {
+		return opts, fmt.Errorf("The --file argument is necessary")
+	}
+	if opts.Interval <= 0 {
+		return opts, fmt.Errorf("The --interval argument should be positive")
+	}
+	_, err = hops.ParseContainerdOutput(opts.Build.Output)
+	if err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// outputDest returns the local destination of the given output of buildctl,
+// or an empty string if the output is not stored locally
+func outputDest(output string) string {
+	for field := range strings.SplitSeq(output, ",") {
+		dest, ok := strings.CutPrefix(strings.TrimSpace(field), "dest=")
+		if ok && dest != "-" {
+			return dest
+		}
+	}
+
+	return ""
+}
+
+// watchBuild builds the image and prints the error of a failed build, so
+// watching goes on until the next change
+func watchBuild(opts BuildOpts) {
+	start := time.Now()
+	err := buildImage(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Built %s in %s\n", opts.File, time.Since(start).Round(time.Millisecond))
+}
+
+func watchCommand(args []string) error {
+	opts, err := parseWatchOpts(args)
+	if err != nil {
+		return err
+	}
+
+	var exclude []string
+	for _, p := range opts.Exclude {
+		exclude = append(exclude, filepath.Join(opts.Build.Context, p))
+	}
+	// The output of the builds should not trigger a new build
+	if dest := outputDest(opts.Build.Output); dest != "" {
+		exclude = append(exclude, dest)
+	}
+	paths := []string{opts.Build.File, opts.Build.Context}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	last, err := hops.TakeSnapshot(paths, exclude)
+	if err != nil {
+		return err
+	}
+	// buildkitd keeps the cache of the previous builds, so only the steps
+	// that depend on the changed files run again
+	watchBuild(opts.Build)
+	fmt.Printf("Watching %s and %s for changes\n", opts.Build.File, opts.Build.Context)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current, err := hops.TakeSnapshot(paths, exclude)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			continue
+		}
+		changed := last.Changed(current)
+		if len(changed) == 0 {
+			continue
+		}
+		last = current
+		fmt.Printf("Changed: %s\n", strings.Join(changed, ", "))
+		watchBuild(opts.Build)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireAnnotations checks that the manifest and the config of the image
+// carry the given annotations and that urunc.json holds them too
+func requireAnnotations(t *testing.T, img *ociImage, annots map[string]string) {
+	t.Helper()
+	require.Contains(t, img.Files, "/urunc.json")
+	var uruncJSON map[string]string
+	require.NoError(t, json.Unmarshal(img.Files["/urunc.json"], &uruncJSON))
+
+	for k, v := range annots {
+		require.Equal(t, v, img.Manifest.Annotations[k], "annotation %s of the manifest", k)
+		require.Equal(t, v, img.Config.Config.Labels[k], "label %s of the config", k)
+		decoded, err := base64.StdEncoding.DecodeString(uruncJSON[k])
+		require.NoError(t, err)
+		require.Equal(t, v, string(decoded), "%s in urunc.json", k)
+	}
+}
+
+// fixtureFile returns the content of a file of a fixture
+func fixtureFile(t *testing.T, fixture string, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", fixture, name))
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestE2EBunnyfile(t *testing.T) {
+	img := build(t, "local-kernel", "bunnyfile", "verify=true")
+
+	requireAnnotations(t, img, map[string]string{
+		"com.urunc.unikernel.unikernelType":    "unikraft",
+		"com.urunc.unikernel.unikernelVersion": "v0.15.0",
+		"com.urunc.unikernel.hypervisor":       "qemu",
+		"com.urunc.unikernel.binary":           "/.boot/kernel",
+		"com.urunc.unikernel.cmdline":          "-c /nginx/conf/nginx.conf",
+	})
+	require.Equal(t, fixtureFile(t, "local-kernel", "kernel"), img.Files["/.boot/kernel"])
+	require.Equal(t, []string{"-c", "/nginx/conf/nginx.conf"}, img.Config.Config.Cmd)
+}
+
+func TestE2EInitrd(t *testing.T) {
+	img := build(t, "initrd", "bunnyfile")
+
+	requireAnnotations(t, img, map[string]string{
+		"com.urunc.unikernel.binary": "/.boot/kernel",
+		"com.urunc.unikernel.initrd": "/.boot/rootfs",
+	})
+	initrd := string(img.Files["/.boot/rootfs"])
+	// A newc cpio archive with the included file
+	require.Contains(t, initrd, "070701")
+	require.Contains(t, initrd, "nginx/conf/nginx.conf")
+	require.Contains(t, initrd, string(fixtureFile(t, "initrd", "nginx.conf")))
+}
+
+func TestE2EContainerfile(t *testing.T) {
+	img := build(t, "containerfile", "Containerfile")
+
+	requireAnnotations(t, img, map[string]string{
+		"com.urunc.unikernel.unikernelType": "unikraft",
+		"com.urunc.unikernel.hypervisor":    "qemu",
+		"com.urunc.unikernel.binary":        "/.boot/kernel",
+		"com.urunc.unikernel.cmdline":       "-c /nginx/conf/nginx.conf",
+	})
+	require.Equal(t, fixtureFile(t, "containerfile", "kernel"), img.Files["/.boot/kernel"])
+}
+
+func TestE2EInlineBunnyfile(t *testing.T) {
+	inline := base64.StdEncoding.EncodeToString(fixtureFile(t, "local-kernel", "bunnyfile"))
+	img := build(t, "local-kernel", "missing", "inline-bunnyfile="+inline)
+
+	requireAnnotations(t, img, map[string]string{
+		"com.urunc.unikernel.unikernelType": "unikraft",
+		"com.urunc.unikernel.binary":        "/.boot/kernel",
+	})
+	require.Equal(t, fixtureFile(t, "local-kernel", "kernel"), img.Files["/.boot/kernel"])
+}
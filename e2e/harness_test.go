@@ -0,0 +1,335 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+
+// Package e2e runs bunny as a frontend of a real buildkitd and checks the
+// images that it produces. The harness needs only docker: it starts a
+// registry for the image of the frontend and buildkitd in containers, which
+// get removed at the end.
+package e2e
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// The buildkit image to test against, the version of the dependency by
+	// default
+	defaultBuildkitImage string = "moby/buildkit:v0.28.1"
+	// The registry that serves the image of the frontend to buildkitd
+	defaultRegistryImage string = "registry:2"
+)
+
+// harness is the environment that every test of the package shares
+type harness struct {
+	// The prefix of the names of the containers and the network
+	name string
+	// The image of the frontend, as buildkitd pulls it
+	frontend string
+	// The container of buildkitd
+	buildkitd string
+}
+
+var (
+	env *harness
+	// Why the harness could not start, if it did not
+	envErr error
+	// Skip the tests, instead of failing them, e.g. without docker
+	envSkip bool
+)
+
+// getenv returns the value of the variable or the given default value
+func getenv(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// docker runs a docker command and returns its standard output
+func docker(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// setup starts the registry and buildkitd and pushes the image of the
+// frontend, as built from the Dockerfile of the repository
+func setup(h *harness) error {
+	_, err := docker("network", "create", h.name)
+	if err != nil {
+		return err
+	}
+	registry := h.name + "-registry"
+	_, err = docker("run", "-d", "--name", registry, "--network", h.name,
+		"-p", "127.0.0.1::5000", getenv("BUNNY_E2E_REGISTRY_IMAGE", defaultRegistryImage))
+	if err != nil {
+		return err
+	}
+	out, err := docker("port", registry, "5000/tcp")
+	if err != nil {
+		return err
+	}
+	hostPort := strings.Fields(string(out))[0]
+
+	// Docker pushes to registries on the loopback without TLS, while
+	// buildkitd reaches the same registry through the network
+	local := hostPort + "/bunny:e2e"
+	_, err = docker("build", "-t", local, "..")
+	if err != nil {
+		return err
+	}
+	_, err = docker("push", local)
+	if err != nil {
+		return err
+	}
+	h.frontend = registry + ":5000/bunny:e2e"
+
+	config := fmt.Sprintf("[registry.%q]\n  http = true\n  insecure = true\n", registry+":5000")
+	configDir, err := os.MkdirTemp("", h.name)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(configDir, "buildkitd.toml"), []byte(config), 0644)
+	if err != nil {
+		return err
+	}
+	h.buildkitd = h.name + "-buildkitd"
+	_, err = docker("run", "-d", "--name", h.buildkitd, "--network", h.name, "--privileged",
+		"-v", configDir+":/etc/buildkit:ro", getenv("BUNNY_E2E_BUILDKIT_IMAGE", defaultBuildkitImage))
+	if err != nil {
+		return err
+	}
+	for range 60 {
+		_, err = docker("exec", h.buildkitd, "buildctl", "debug", "workers")
+		if err == nil {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("buildkitd did not start: %v", err)
+}
+
+// teardown removes the containers and the network of the harness
+func teardown(h *harness) {
+	_, _ = docker("rm", "-f", h.name+"-buildkitd", h.name+"-registry")
+	_, _ = docker("network", "rm", h.name)
+}
+
+func TestMain(m *testing.M) {
+	_, err := exec.LookPath("docker")
+	if err != nil {
+		envErr = fmt.Errorf("docker is not available: %v", err)
+		envSkip = true
+		os.Exit(m.Run())
+	}
+
+	h := &harness{name: fmt.Sprintf("bunny-e2e-%d", os.Getpid())}
+	envErr = setup(h)
+	if envErr == nil {
+		env = h
+	}
+	code := m.Run()
+	if os.Getenv("BUNNY_E2E_KEEP") == "" {
+		teardown(h)
+	}
+	os.Exit(code)
+}
+
+// ociImage is an image of an exported OCI layout
+type ociImage struct {
+	Manifest ocispecs.Manifest
+	Config   ocispecs.Image
+	// The files of the image, with the layers applied in order
+	Files map[string][]byte
+}
+
+// build builds the given fixture of testdata with bunny as the frontend and
+// returns the exported image. The options are passed to the frontend.
+func build(t *testing.T, fixture string, filename string, opts ...string) *ociImage {
+	t.Helper()
+	if envSkip {
+		t.Skipf("Skipping e2e test: %v", envErr)
+	}
+	if env == nil {
+		t.Fatalf("Failed to start the e2e harness: %v", envErr)
+	}
+
+	dir := "/fixtures/" + fixture
+	_, err := docker("exec", env.buildkitd, "mkdir", "-p", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = docker("cp", filepath.Join("testdata", fixture)+"/.", env.buildkitd+":"+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"exec", env.buildkitd, "buildctl", "build",
+		"--frontend", "gateway.v0",
+		"--opt", "source=" + env.frontend,
+		"--opt", "filename=" + filename,
+		"--local", "context=" + dir,
+		"--local", "dockerfile=" + dir,
+		"--output", "type=oci,dest=-",
+	}
+	for _, opt := range opts {
+		args = append(args, "--opt", opt)
+	}
+	out, err := docker(args...)
+	if err != nil {
+		t.Fatalf("Failed to build %s: %v", fixture, err)
+	}
+	img, err := readOCILayout(out)
+	if err != nil {
+		t.Fatalf("Failed to read the image of %s: %v", fixture, err)
+	}
+
+	return img
+}
+
+// readOCILayout reads the image of an OCI layout in a tar archive
+func readOCILayout(archive []byte) (*ociImage, error) {
+	blobs := map[string][]byte{}
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		blobs[path.Clean(hdr.Name)] = data
+	}
+	blob := func(dgst digest.Digest) ([]byte, error) {
+		data, ok := blobs[path.Join("blobs", dgst.Algorithm().String(), dgst.Encoded())]
+		if !ok {
+			return nil, fmt.Errorf("Missing blob %s", dgst)
+		}
+		return data, nil
+	}
+	decode := func(dgst digest.Digest, v any) error {
+		data, err := blob(dgst)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, v)
+	}
+
+	var index ocispecs.Index
+	err := json.Unmarshal(blobs["index.json"], &index)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid index.json: %v", err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("The layout has no image")
+	}
+	desc := index.Manifests[0]
+	// The image may be in an index, e.g. along with attestations
+	if desc.MediaType == ocispecs.MediaTypeImageIndex {
+		var nested ocispecs.Index
+		err = decode(desc.Digest, &nested)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range nested.Manifests {
+			if m.Platform != nil && m.Platform.OS != "unknown" {
+				desc = m
+				break
+			}
+		}
+	}
+
+	img := &ociImage{Files: map[string][]byte{}}
+	err = decode(desc.Digest, &img.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(img.Manifest.Config.Digest, &img.Config)
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range img.Manifest.Layers {
+		data, err := blob(layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		err = readLayer(data, layer.MediaType, img.Files)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read layer %s: %v", layer.Digest, err)
+		}
+	}
+
+	return img, nil
+}
+
+// readLayer adds the regular files of the layer to the given files
+func readLayer(data []byte, mediaType string, files map[string][]byte) error {
+	var r io.Reader = bytes.NewReader(data)
+	if strings.HasSuffix(mediaType, "gzip") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		files[path.Join("/", hdr.Name)] = content
+	}
+}
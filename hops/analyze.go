@@ -0,0 +1,318 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// The size from which an unused file or directory of the build context
+	// gets a warning
+	DefaultLargeUnusedSize int64 = 10 << 20
+	// The file of the build context with the ignore patterns
+	dockerignoreFile string = ".dockerignore"
+	// The file of the build context with the ignore patterns of bunny, e.g.
+	// the scaffold of bunny init, which the builds leave out of the context
+	BunnyignoreFile string = ".bunnyignore"
+)
+
+// BunnyignoreScaffold is the .bunnyignore that bunny init creates next to the
+// bunnyfile
+const BunnyignoreScaffold = `# The paths of the build context that the bunnyfile does not need, with the
+# syntax of .dockerignore. The builds leave them out of the build context,
+# bunny analyze-context does not suggest them again and its suggestions can
+# get appended here.
+.git
+`
+
+// UnusedPath is a file or directory of the build context that the bunnyfile
+// does not reference
+type UnusedPath struct {
+	// The path relative to the build context, with a trailing / for a
+	// directory
+	Path string
+	// The size of the file or the total size of the files of the directory
+	Size int64
+}
+
+// ContextReport is the analysis of a build context against the paths that
+// a bunnyfile references
+type ContextReport struct {
+	// The referenced paths that the build context does not have
+	Missing []string
+	// The suggested patterns of .dockerignore, for the unused paths that
+	// neither the .dockerignore nor the .bunnyignore already has
+	Ignore []string
+	// The unused paths of at least the given size, the largest first
+	Large []UnusedPath
+}
+
+// ContextReferences returns the paths of the build context that the
+// bunnyfile references: the local kernels, rootfs and device tree blob, the
+// source of a kernel that gets built, the local files to include and the
+// certificates, including the ones of the overrides for architectures. The optional includes come separately, since the context
+// does not need to have them.
+func ContextReferences(h *Hops) ([]string, []string) {
+	var required []string
+	var optional []string
+	if h.Kernel.From == "local" {
+		required = append(required, h.Kernel.Path)
+	}
+	for _, v := range h.Kernel.Variants {
+		if v.From == "local" && v.Monitor != h.Platform.Monitor {
+			required = append(required, v.Path)
+		}
+	}
+	if h.Kernel.Source != "" && !isGitSource(h.Kernel.Source) {
+		required = append(required, h.Kernel.Source)
+	}
+	if h.Rootfs.From == "local" {
+		required = append(required, h.Rootfs.Path)
+	}
+	if h.Dtb.From == "local" {
+		required = append(required, h.Dtb.Path)
+	}
+	add := func(includes []FileToInclude) {
+		for _, f := range includes {
+			switch {
+			case f.From != "local":
+			case f.Optional:
+				optional = append(optional, f.Src)
+			default:
+				required = append(required, f.Src)
+			}
+		}
+	}
+	add(h.Rootfs.Includes)
+	for _, initrd := range h.Rootfs.Initrds {
+		add(initrd.Includes)
+	}
+	// The build context serves every architecture with an override
+	for _, arch := range overrideArches(h) {
+		kernel := h.Kernel.ForArch(arch)
+		if kernel.From == "local" {
+			required = append(required, kernel.Path)
+		}
+		if kernel.Source != "" && !isGitSource(kernel.Source) {
+			required = append(required, kernel.Source)
+		}
+		rootfs := h.Rootfs.ForArch(arch)
+		if rootfs.From == "local" {
+			required = append(required, rootfs.Path)
+		}
+		add(rootfs.Includes)
+	}
+	required = append(required, h.Certificates...)
+
+	return contextPaths(required), contextPaths(optional)
+}
+
+// contextPaths returns the given paths relative to the root of the build
+// context, without duplicates
+func contextPaths(paths []string) []string {
+	var cleaned []string
+	for _, p := range paths {
+		cleaned = append(cleaned, strings.TrimPrefix(path.Clean("/"+p), "/"))
+	}
+
+	return ContextPatterns(cleaned...)
+}
+
+// pathUse tells how a path of the build context relates to the referenced
+// paths: used if a reference covers it, on the way if it is a directory
+// with referenced paths under it, or unused otherwise
+type pathUse int
+
+const (
+	pathUnused pathUse = iota
+	pathOnTheWay
+	pathUsed
+)
+
+// usePath returns the use of the given path of the build context, relative
+// to its root. References can have the wildcards of path.Match.
+func usePath(rel string, refs []string) pathUse {
+	use := pathUnused
+	relParts := strings.Split(rel, "/")
+	for _, ref := range refs {
+		if ref == "" {
+			// The whole build context
+			return pathUsed
+		}
+		refParts := strings.Split(ref, "/")
+		n := min(len(relParts), len(refParts))
+		matched := true
+		for i := 0; i < n && matched; i++ {
+			matched, _ = path.Match(refParts[i], relParts[i])
+		}
+		switch {
+		case !matched:
+		case len(relParts) >= len(refParts):
+			return pathUsed
+		default:
+			use = pathOnTheWay
+		}
+	}
+
+	return use
+}
+
+// ParseIgnoreFile returns the patterns of an ignore file, e.g. of the
+// .bunnyignore file, relative to the root of the build context and without
+// the comments and the empty lines
+func ParseIgnoreFile(content []byte) []string {
+	var patterns []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, strings.TrimPrefix(path.Clean("/"+line), "/"))
+	}
+
+	return patterns
+}
+
+// readIgnoreFile returns the patterns of the given ignore file of the build
+// context, if it has one
+func readIgnoreFile(contextDir string, name string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(contextDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return ParseIgnoreFile(content), nil
+}
+
+// dirSize returns the total size of the files under the given directory
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+// AnalyzeContext walks the build context in contextDir and compares it with
+// the paths that the bunnyfile references. The keep paths (e.g. the
+// bunnyfile itself) are used, even if the bunnyfile does not reference
+// them. Every unused file or directory gets a suggested ignore pattern,
+// unless the .dockerignore or the .bunnyignore of the context already has it,
+// and the ones of at least largeSize bytes get reported as large. The .git
+// directory is always unused, while the ignore files are always used.
+func AnalyzeContext(h *Hops, contextDir string, keep []string, largeSize int64) (ContextReport, error) {
+	var report ContextReport
+
+	required, optional := ContextReferences(h)
+	for _, ref := range required {
+		matches, err := filepath.Glob(filepath.Join(contextDir, filepath.FromSlash(ref)))
+		if err != nil || len(matches) == 0 {
+			report.Missing = append(report.Missing, ref)
+		}
+	}
+	ignoreFiles := []string{dockerignoreFile, BunnyignoreFile}
+	refs := slices.Concat(required, optional, contextPaths(keep), ignoreFiles)
+	var ignored []string
+	for _, name := range ignoreFiles {
+		patterns, err := readIgnoreFile(contextDir, name)
+		if err != nil {
+			return report, fmt.Errorf("Failed to read %s: %v", name, err)
+		}
+		ignored = append(ignored, patterns...)
+	}
+
+	root := filepath.Clean(contextDir)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		use := usePath(rel, refs)
+		if d.Name() == ".git" && d.IsDir() {
+			use = pathUnused
+		}
+		switch {
+		case use == pathUsed:
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case use == pathOnTheWay && d.IsDir():
+			return nil
+		}
+
+		unused := UnusedPath{Path: rel}
+		if d.IsDir() {
+			unused.Path += "/"
+			unused.Size, err = dirSize(p)
+		} else {
+			var info fs.FileInfo
+			info, err = d.Info()
+			if err == nil {
+				unused.Size = info.Size()
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(ignored, rel) {
+			report.Ignore = append(report.Ignore, rel)
+		}
+		if unused.Size >= largeSize {
+			report.Large = append(report.Large, unused)
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("Failed to analyze the build context %s: %v", contextDir, err)
+	}
+	slices.SortStableFunc(report.Large, func(a, b UnusedPath) int {
+		return cmp.Compare(b.Size, a.Size)
+	})
+
+	return report, nil
+}
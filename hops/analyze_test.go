@@ -0,0 +1,153 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+// writeContext creates the given files, with the given sizes, in a new
+// build context
+func writeContext(t *testing.T, files map[string]int) string {
+	dir := t.TempDir()
+	for name, size := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, make([]byte, size), 0644))
+	}
+
+	return dir
+}
+
+func TestAnalyzeContextReferences(t *testing.T) {
+	h := &Hops{
+		Kernel: Kernel{From: "local", Path: "./kernel/vmlinux"},
+		Rootfs: Rootfs{
+			From: "scratch",
+			Includes: []FileToInclude{
+				{From: "local", Src: "/app", Dst: "/app"},
+				{From: "local", Src: "conf.d/*.conf", Dst: "/etc/"},
+				{From: "local", Src: "extra", Dst: "/extra", Optional: true},
+				{From: "alpine:3.20", Src: "/bin/sh", Dst: "/bin/sh"},
+			},
+		},
+		Certificates: []string{"certs/ca.pem", "app"},
+	}
+	required, optional := ContextReferences(h)
+	require.Equal(t, []string{"kernel/vmlinux", "app", "conf.d/*.conf", "certs/ca.pem"}, required)
+	require.Equal(t, []string{"extra"}, optional)
+
+	require.Equal(t, pathUsed, usePath("app/bin/server", required))
+	require.Equal(t, pathUsed, usePath("conf.d/web.conf", required))
+	require.Equal(t, pathOnTheWay, usePath("kernel", required))
+	require.Equal(t, pathUnused, usePath("kernel/config", required))
+	require.Equal(t, pathUnused, usePath("conf.d/README", required))
+	require.Equal(t, pathUsed, usePath("anything", []string{""}))
+}
+
+func TestAnalyzeContextReport(t *testing.T) {
+	h := &Hops{
+		Kernel: Kernel{From: "local", Path: "kernel"},
+		Rootfs: Rootfs{
+			From: "scratch",
+			Includes: []FileToInclude{
+				{From: "local", Src: "app/bin", Dst: "/bin"},
+				{From: "local", Src: "missing", Dst: "/missing"},
+				{From: "local", Src: "extra", Dst: "/extra", Optional: true},
+			},
+		},
+	}
+	dir := writeContext(t, map[string]int{
+		"kernel":          10,
+		"bunnyfile":       10,
+		"app/bin/server":  10,
+		"app/src/main.go": 10,
+		"build/cache/a":   600,
+		"build/cache/b":   600,
+		"video.mp4":       2000,
+		"notes.txt":       10,
+		".git/HEAD":       10,
+		".dockerignore":   0,
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".dockerignore"), []byte("# Notes\n/notes.txt\n"), 0644))
+
+	report, err := AnalyzeContext(h, dir, []string{"bunnyfile"}, 1000)
+	require.NoError(t, err)
+	require.Equal(t, []string{"missing"}, report.Missing)
+	require.Equal(t, []string{".git", "app/src", "build", "video.mp4"}, report.Ignore)
+	require.Equal(t, []UnusedPath{
+		{Path: "video.mp4", Size: 2000},
+		{Path: "build/", Size: 1200},
+	}, report.Large)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, BunnyignoreFile), []byte(BunnyignoreScaffold+"build/\n"), 0644))
+	report, err = AnalyzeContext(h, dir, []string{"bunnyfile"}, 1000)
+	require.NoError(t, err)
+	require.Equal(t, []string{"app/src", "video.mp4"}, report.Ignore)
+
+	_, err = AnalyzeContext(h, filepath.Join(dir, "none"), nil, 1000)
+	require.ErrorContains(t, err, "Failed to analyze the build context")
+}
+
+func TestAnalyzeBunnyignoreExclude(t *testing.T) {
+	file := []byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+  architecture: amd64
+kernel:
+  from: local
+  path: kernel
+rootfs:
+  from: scratch
+  type: initrd
+  include:
+    - app:/app
+`)
+	exclude := ParseIgnoreFile([]byte(BunnyignoreScaffold + "\n# Build outputs\n/build/\n"))
+	require.Equal(t, []string{".git", "build"}, exclude)
+
+	// localExcludes returns the exclude patterns of every local source of
+	// the build context in the LLB of the image
+	localExcludes := func(opts SourceOpts) []string {
+		i, err := ParseFile(context.TODO(), file, "context", nil, opts)
+		require.NoError(t, err)
+		require.Equal(t, opts.Exclude, i.Sources.Exclude)
+		def, err := PackLLB(context.TODO(), *i)
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var excludes []string
+		for _, op := range arr {
+			if src := op.GetSource(); src != nil && src.Identifier == "local://context" {
+				excludes = append(excludes, src.Attrs[pb.AttrExcludePatterns])
+			}
+		}
+		require.NotEmpty(t, excludes)
+		return excludes
+	}
+
+	for _, e := range localExcludes(SourceOpts{Arch: "amd64", Exclude: exclude}) {
+		require.JSONEq(t, `[".git","build"]`, e)
+	}
+	for _, e := range localExcludes(SourceOpts{Arch: "amd64"}) {
+		require.Empty(t, e)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	uruncAnnotPrefix string = "com.urunc.unikernel."
+)
+
+// uruncAnnotations is the list of annotations that urunc understands
+var uruncAnnotations = []string{
+	"com.urunc.unikernel.unikernelType",
+	"com.urunc.unikernel.unikernelVersion",
+	"com.urunc.unikernel.hypervisor",
+	"com.urunc.unikernel.binary",
+	"com.urunc.unikernel.cmdline",
+	"com.urunc.unikernel.initrd",
+	"com.urunc.unikernel.block",
+	"com.urunc.unikernel.blkMntPoint",
+	"com.urunc.unikernel.mountRootfs",
+}
+
+// legacyAnnotations maps annotations of older tools (e.g. pun, bima) to the
+// ones that urunc currently understands.
+var legacyAnnotations = map[string]string{
+	"com.urunc.unikernel.useDMBlock": "com.urunc.unikernel.mountRootfs",
+}
+
+// boolAnnotations is the list of annotations with a boolean value
+var boolAnnotations = []string{
+	"com.urunc.unikernel.mountRootfs",
+}
+
+// canonicalAnnotation returns the annotation that urunc understands for the
+// given key, ignoring the case. It returns an empty string, if urunc
+// does not know the key.
+func canonicalAnnotation(key string) string {
+	for _, annot := range uruncAnnotations {
+		if strings.EqualFold(annot, key) {
+			return annot
+		}
+	}
+	for legacy, annot := range legacyAnnotations {
+		if strings.EqualFold(legacy, key) {
+			return annot
+		}
+	}
+
+	return ""
+}
+
+// NormalizeAnnotations rewrites urunc annotations written in a legacy
+// form (older names, different case, non-canonical boolean values, other
+// names of monitors) to the form that urunc understands. It returns an error,
+// if two different annotations end up in the same key with different values.
+func NormalizeAnnotations(annots map[string]string) error {
+	// Collect the keys first, since we modify the map
+	keys := make([]string, 0, len(annots))
+	for k := range annots {
+		keys = append(keys, k)
+	}
+
+	for _, k := range keys {
+		if !strings.HasPrefix(strings.ToLower(k), uruncAnnotPrefix) {
+			continue
+		}
+		canonical := canonicalAnnotation(k)
+		if canonical == "" || canonical == k {
+			continue
+		}
+		val := annots[k]
+		if existing, ok := annots[canonical]; ok && existing != val {
+			return fmt.Errorf("Conflicting values for %s: %q from %s and %q", canonical, val, k, existing)
+		}
+		delete(annots, k)
+		annots[canonical] = val
+	}
+
+	if monitor, ok := annots["com.urunc.unikernel.hypervisor"]; ok {
+		annots["com.urunc.unikernel.hypervisor"] = NormalizeMonitor(monitor)
+	}
+
+	for _, k := range boolAnnotations {
+		val := annots[k]
+		if val == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("Invalid value %q for %s: expected true or false", val, k)
+		}
+		annots[k] = strconv.FormatBool(b)
+	}
+
+	return nil
+}
+
+// IsUruncAnnotation returns true, if the key is one of the annotations that
+// urunc understands.
+func IsUruncAnnotation(key string) bool {
+	for _, annot := range uruncAnnotations {
+		if annot == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// suggestAnnotation returns the urunc annotation closest to the given key,
+// or an empty string if none is close enough to be a typo.
+func suggestAnnotation(key string) string {
+	const maxDistance = 2
+	best := ""
+	bestDistance := maxDistance + 1
+	for _, annot := range uruncAnnotations {
+		d := editDistance(strings.ToLower(key), strings.ToLower(annot))
+		if d < bestDistance {
+			best = annot
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+// suggestMonitor returns the monitor closest to the given one, or an empty
+// string if none is close enough to be a typo.
+func suggestMonitor(monitor string) string {
+	const maxDistance = 2
+	best := ""
+	bestDistance := maxDistance + 1
+	for _, m := range schemaMonitors {
+		d := editDistance(strings.ToLower(monitor), m)
+		if d < bestDistance {
+			best = m
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+// ValidateAnnotations checks that all annotations with the urunc prefix are
+// known to urunc and that the kernel of each monitor belongs to a known
+// monitor. It should be called after NormalizeAnnotations and it returns an
+// error with all unknown keys, suggesting the closest valid annotation or
+// monitor when the unknown key looks like a typo.
+func ValidateAnnotations(annots map[string]string) error {
+	var errs []error
+
+	keys := make([]string, 0, len(annots))
+	for k := range annots {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if monitor, ok := strings.CutPrefix(k, KernelVariantAnnotPrefix); ok {
+			if isKernelVariantAnnotation(k) {
+				continue
+			}
+			if s := suggestMonitor(monitor); s != "" {
+				errs = append(errs, fmt.Errorf("Unknown monitor %q in %s, did you mean %s?", monitor, k, s))
+			} else {
+				errs = append(errs, fmt.Errorf("Unknown monitor %q in %s", monitor, k))
+			}
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(k), uruncAnnotPrefix) {
+			continue
+		}
+		if IsUruncAnnotation(k) {
+			continue
+		}
+		if s := suggestAnnotation(k); s != "" {
+			errs = append(errs, fmt.Errorf("Unknown urunc annotation %s, did you mean %s?", k, s))
+		} else {
+			errs = append(errs, fmt.Errorf("Unknown urunc annotation %s", k))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationsNormalize(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       map[string]string
+		expected    map[string]string
+		expectError bool
+		errorText   string
+	}{
+		{
+			name: "Valid canonical annotations",
+			input: map[string]string{
+				"com.urunc.unikernel.binary":      "/kernel",
+				"com.urunc.unikernel.mountRootfs": "true",
+				"foo":                             "bar",
+			},
+			expected: map[string]string{
+				"com.urunc.unikernel.binary":      "/kernel",
+				"com.urunc.unikernel.mountRootfs": "true",
+				"foo":                             "bar",
+			},
+		},
+		{
+			name: "Valid legacy useDMBlock",
+			input: map[string]string{
+				"com.urunc.unikernel.useDMBlock": "True",
+			},
+			expected: map[string]string{
+				"com.urunc.unikernel.mountRootfs": "true",
+			},
+		},
+		{
+			name: "Valid alias of cloud-hypervisor",
+			input: map[string]string{
+				"com.urunc.unikernel.hypervisor": "clh",
+			},
+			expected: map[string]string{
+				"com.urunc.unikernel.hypervisor": MonitorCloudHypervisor,
+			},
+		},
+		{
+			name: "Valid different case",
+			input: map[string]string{
+				"com.urunc.unikernel.unikerneltype": "rumprun",
+				"COM.URUNC.UNIKERNEL.CMDLINE":       "redis-server",
+			},
+			expected: map[string]string{
+				"com.urunc.unikernel.unikernelType": "rumprun",
+				"com.urunc.unikernel.cmdline":       "redis-server",
+			},
+		},
+		{
+			name: "Valid unknown urunc annotation stays as is",
+			input: map[string]string{
+				"com.urunc.unikernel.foo": "bar",
+			},
+			expected: map[string]string{
+				"com.urunc.unikernel.foo": "bar",
+			},
+		},
+		{
+			name: "Valid legacy and canonical with same value",
+			input: map[string]string{
+				"com.urunc.unikernel.useDMBlock":  "false",
+				"com.urunc.unikernel.mountRootfs": "false",
+			},
+			expected: map[string]string{
+				"com.urunc.unikernel.mountRootfs": "false",
+			},
+		},
+		{
+			name: "Invalid legacy and canonical with different value",
+			input: map[string]string{
+				"com.urunc.unikernel.useDMBlock":  "true",
+				"com.urunc.unikernel.mountRootfs": "false",
+			},
+			expectError: true,
+			errorText:   "Conflicting values for com.urunc.unikernel.mountRootfs",
+		},
+		{
+			name: "Invalid boolean value",
+			input: map[string]string{
+				"com.urunc.unikernel.mountRootfs": "yes",
+			},
+			expectError: true,
+			errorText:   "Invalid value \"yes\"",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NormalizeAnnotations(tc.input)
+			if tc.expectError {
+				require.ErrorContains(t, err, tc.errorText)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, tc.input)
+			}
+		})
+	}
+}
+
+func TestAnnotationsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       map[string]string
+		expectError bool
+		errorText   string
+	}{
+		{
+			name: "Valid urunc and user annotations",
+			input: map[string]string{
+				"com.urunc.unikernel.binary":  "/kernel",
+				"com.urunc.unikernel.cmdline": "foo",
+				"foo":                         "bar",
+				"bunny.urunit":                "false",
+			},
+		},
+		{
+			name:  "Valid empty",
+			input: map[string]string{},
+		},
+		{
+			name: "Invalid typo",
+			input: map[string]string{
+				"com.urunc.unikernel.cmdlinee": "foo",
+			},
+			expectError: true,
+			errorText:   "Unknown urunc annotation com.urunc.unikernel.cmdlinee, did you mean com.urunc.unikernel.cmdline?",
+		},
+		{
+			name: "Invalid unknown annotation",
+			input: map[string]string{
+				"com.urunc.unikernel.foobar": "foo",
+			},
+			expectError: true,
+			errorText:   "Unknown urunc annotation com.urunc.unikernel.foobar",
+		},
+		{
+			name: "Invalid not normalized annotation",
+			input: map[string]string{
+				"com.urunc.unikernel.useDMBlock": "true",
+			},
+			expectError: true,
+			errorText:   "Unknown urunc annotation com.urunc.unikernel.useDMBlock",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateAnnotations(tc.input)
+			if tc.expectError {
+				require.ErrorContains(t, err, tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
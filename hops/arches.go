@@ -0,0 +1,161 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KernelOverride holds the fields of the kernel field that differ for a
+// single architecture, e.g. the kernel.arm64 field
+type KernelOverride struct {
+	From   string   `yaml:"from"`
+	Path   string   `yaml:"path"`
+	Source string   `yaml:"source"`
+	Config []string `yaml:"config"`
+}
+
+// RootfsOverride holds the fields of the rootfs field that differ for a
+// single architecture, e.g. the rootfs.arm64 field
+type RootfsOverride struct {
+	From     string          `yaml:"from"`
+	Path     string          `yaml:"path"`
+	Type     string          `yaml:"type"`
+	Includes []FileToInclude `yaml:"include"`
+	Owner    string          `yaml:"owner"`
+}
+
+// decodeArchOverrides decodes the fields of the given mapping that are named
+// after an architecture (e.g. arm64 or aarch64) into overrides, keyed by the
+// normalized architecture. Any other field is left as it is.
+func decodeArchOverrides[T any](node *yaml.Node, field string) (map[string]T, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	var overrides map[string]T
+	for j := 0; j+1 < len(node.Content); j += 2 {
+		key := node.Content[j].Value
+		if !slices.Contains(schemaArches, key) {
+			continue
+		}
+		arch := normalizeArch(key)
+		if _, ok := overrides[arch]; ok {
+			return nil, fmt.Errorf("The %s field has more than one override for %s at line %d", field, arch, node.Content[j].Line)
+		}
+		var o T
+		err := node.Content[j+1].Decode(&o)
+		if err != nil {
+			return nil, err
+		}
+		if overrides == nil {
+			overrides = map[string]T{}
+		}
+		overrides[arch] = o
+	}
+
+	return overrides, nil
+}
+
+// UnmarshalYAML decodes the rootfs field along with its overrides for each
+// architecture
+func (r *Rootfs) UnmarshalYAML(node *yaml.Node) error {
+	type plainRootfs Rootfs
+	err := node.Decode((*plainRootfs)(r))
+	if err != nil {
+		return err
+	}
+	r.Arches, err = decodeArchOverrides[RootfsOverride](node, "rootfs")
+
+	return err
+}
+
+// ForArch returns the kernel of the given architecture, i.e. the kernel
+// with the fields of the override of the architecture, if any. The fields of
+// the override replace the ones of the kernel, except for config, whose
+// entries get appended.
+func (k Kernel) ForArch(arch string) Kernel {
+	o, ok := k.Arches[normalizeArch(arch)]
+	k.Arches = nil
+	if !ok {
+		return k
+	}
+
+	if o.From != "" {
+		k.From = o.From
+	}
+	if o.Path != "" {
+		k.Path = o.Path
+	}
+	if o.Source != "" {
+		k.Source = o.Source
+	}
+	k.Config = append(slices.Clone(k.Config), o.Config...)
+
+	return k
+}
+
+// ForArch returns the rootfs of the given architecture, i.e. the rootfs
+// with the fields of the override of the architecture, if any. The fields of
+// the override replace the ones of the rootfs, except for include, whose
+// entries get appended.
+func (r Rootfs) ForArch(arch string) Rootfs {
+	o, ok := r.Arches[normalizeArch(arch)]
+	r.Arches = nil
+	if !ok {
+		return r
+	}
+
+	if o.From != "" {
+		r.From = o.From
+	}
+	if o.Path != "" {
+		r.Path = o.Path
+	}
+	if o.Type != "" {
+		r.Type = o.Type
+	}
+	if o.Owner != "" {
+		r.Owner = o.Owner
+	}
+	r.Includes = append(slices.Clone(r.Includes), o.Includes...)
+
+	return r
+}
+
+// overrideArches returns the architectures with an override of the kernel
+// or the rootfs, sorted
+func overrideArches(h *Hops) []string {
+	arches := slices.Collect(maps.Keys(h.Kernel.Arches))
+	for arch := range h.Rootfs.Arches {
+		if !slices.Contains(arches, arch) {
+			arches = append(arches, arch)
+		}
+	}
+	slices.Sort(arches)
+
+	return arches
+}
+
+// ApplyArchOverrides merges the overrides of the kernel and the rootfs for
+// the architecture of platforms, the one of the worker if it is not set.
+func ApplyArchOverrides(h *Hops) {
+	h.Kernel = h.Kernel.ForArch(h.Platform.Arch)
+	h.Rootfs = h.Rootfs.ForArch(h.Platform.Arch)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const archesBunnyfile = `version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: vmlinux-amd64
+  aarch64:
+    path: vmlinux-arm64
+rootfs:
+  from: scratch
+  type: initrd
+  include:
+    - app.conf:/app.conf
+  arm64:
+    include:
+      - app-arm64:/app
+  amd64:
+    include:
+      - app-amd64:/app
+cmd: ["/app"]
+`
+
+func TestArchesParse(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(archesBunnyfile))
+	require.NoError(t, err)
+	require.Equal(t, map[string]KernelOverride{"arm64": {Path: "vmlinux-arm64"}}, h.Kernel.Arches)
+	require.Equal(t, []string{"amd64", "arm64"}, overrideArches(h))
+	require.ElementsMatch(t, []string{"vmlinux-amd64", "vmlinux-arm64", "app.conf", "app-arm64", "app-amd64"},
+		func() []string { r, _ := ContextReferences(h); return r }())
+
+	arm := h.Kernel.ForArch("arm64")
+	require.Equal(t, "local", arm.From)
+	require.Equal(t, "vmlinux-arm64", arm.Path)
+	require.Nil(t, arm.Arches)
+	require.Equal(t, "vmlinux-amd64", h.Kernel.ForArch("arm").Path)
+	require.Equal(t, []FileToInclude{
+		{From: "local", Src: "app.conf", Dst: "/app.conf"},
+		{From: "local", Src: "app-arm64", Dst: "/app"},
+	}, h.Rootfs.ForArch("aarch64").Includes)
+	// The rootfs itself stays as it is
+	require.Len(t, h.Rootfs.Includes, 1)
+
+	t.Run("Architecture of platforms", func(t *testing.T) {
+		h, err := ParseBunnyfile([]byte(strings.Replace(archesBunnyfile, "monitor: qemu\n", "monitor: qemu\n  architecture: arm64\n", 1)))
+		require.NoError(t, err)
+		require.Equal(t, "vmlinux-arm64", h.Kernel.Path)
+		require.Nil(t, h.Kernel.Arches)
+		require.Equal(t, "app-arm64", h.Rootfs.Includes[1].Src)
+	})
+	t.Run("Duplicate override", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte("version: v0.1\nkernel:\n  from: local\n  path: a\n  arm64:\n    path: b\n  aarch64:\n    path: c\n"))
+		require.ErrorContains(t, err, "The kernel field has more than one override for arm64 at line 7")
+	})
+	t.Run("Invalid override", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: vmlinux
+rootfs:
+  from: scratch
+  arm64:
+    from: local
+`))
+		require.ErrorContains(t, err, "Invalid rootfs for arm64: The path field of rootfs is necessary, if from is local")
+	})
+}
+
+func TestArchesPack(t *testing.T) {
+	for _, arch := range []string{"amd64", "arm64"} {
+		t.Run(arch, func(t *testing.T) {
+			instr, err := ParseFile(context.TODO(), []byte(archesBunnyfile), "context", nil, SourceOpts{Arch: arch})
+			require.NoError(t, err)
+			require.Equal(t, "vmlinux-"+arch, instr.Kernel.FilePath)
+		})
+	}
+}
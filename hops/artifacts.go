@@ -0,0 +1,210 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// The label with the target of an artifact in its own config
+	ArtifactTypeLabel string = "io.bunny.artifact.type"
+	// The predicate type of the attestation that lists the artifacts of an
+	// image
+	ArtifactsPredicateType string = "https://github.com/nubificus/bunny/artifacts/v1"
+	artifactsPredicatePath string = "/artifacts.json"
+)
+
+// The labels of the image that the artifacts share, so tools can tell which
+// image an artifact belongs to
+var artifactBuildLabels = []string{
+	BunnyVersionAnnotation,
+	HopsVersionAnnotation,
+	BunnyfileVersionAnnotation,
+	LLBDigestAnnotation,
+}
+
+// Artifacts defines the intermediate artifacts of the build that get
+// exported alongside the final image.
+type Artifacts struct {
+	Kernel bool `yaml:"kernel"`
+	Rootfs bool `yaml:"rootfs"`
+}
+
+// Enabled returns true if any artifact needs to get exported
+func (a Artifacts) Enabled() bool {
+	return a.Kernel || a.Rootfs
+}
+
+// Targets returns the targets of the enabled artifacts
+func (a Artifacts) Targets() []string {
+	var targets []string
+	if a.Kernel {
+		targets = append(targets, TargetKernel)
+	}
+	if a.Rootfs {
+		targets = append(targets, TargetRootfs)
+	}
+
+	return targets
+}
+
+// ArtifactsLLB creates the LLB definition of every enabled artifact, keyed
+// by the respective target.
+func ArtifactsLLB(ctx context.Context, instr PackInstructions) (map[string]*llb.Definition, error) {
+	defs := map[string]*llb.Definition{}
+	for _, target := range instr.Artifacts.Targets() {
+		def, err := TargetLLB(ctx, instr, target)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create LLB of %s artifact: %v", target, err)
+		}
+		defs[target] = def
+	}
+
+	return defs, nil
+}
+
+// artifactConfig creates the OCI config of an artifact, with its target and
+// the build information of the given image as labels. Without it, the
+// artifacts would get the config of the image.
+func artifactConfig(img ocispecs.Image, target string, platform ocispecs.Platform) ([]byte, error) {
+	labels := map[string]string{ArtifactTypeLabel: target}
+	for _, k := range artifactBuildLabels {
+		if v, ok := img.Config.Labels[k]; ok {
+			labels[k] = v
+		}
+	}
+	config := ocispecs.Image{
+		Platform: platform,
+		RootFS:   ocispecs.RootFS{Type: "layers", DiffIDs: []digest.Digest{}},
+		Config:   ocispecs.ImageConfig{Labels: labels},
+	}
+
+	return json.Marshal(config)
+}
+
+// ApplyArtifacts turns the result of the final image into a result with
+// multiple references: the image and each artifact under the name of its
+// target. Exporters that support multiple references (e.g. local) store
+// each one in a separate directory, while the image exporter labels each
+// artifact with its target and the build information of the given image.
+func ApplyArtifacts(res *client.Result, artifacts map[string]client.Reference, img ocispecs.Image) error {
+	if len(artifacts) == 0 {
+		return nil
+	}
+	ref, err := res.SingleRef()
+	if err != nil {
+		return fmt.Errorf("Failed to get reference build result: %v", err)
+	}
+
+	// Buildkit uses platforms to tell the references apart. All of them
+	// are built for the host.
+	platform := ocispecs.Platform{
+		OS:           "linux",
+		Architecture: runtime.GOARCH,
+	}
+	ps := exptypes.Platforms{
+		Platforms: []exptypes.Platform{{ID: TargetImage, Platform: platform}},
+	}
+	res.AddRef(TargetImage, ref)
+	for _, target := range []string{TargetKernel, TargetRootfs} {
+		artifact, ok := artifacts[target]
+		if !ok {
+			continue
+		}
+		res.AddRef(target, artifact)
+		ps.Platforms = append(ps.Platforms, exptypes.Platform{ID: target, Platform: platform})
+		config, err := artifactConfig(img, target, platform)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal config of %s artifact: %v", target, err)
+		}
+		res.AddMeta(fmt.Sprintf("%s/%s", exptypes.ExporterImageConfigKey, target), config)
+	}
+	res.Ref = nil
+
+	// The config of the image is per reference now
+	if config, ok := res.Metadata[exptypes.ExporterImageConfigKey]; ok {
+		res.AddMeta(fmt.Sprintf("%s/%s", exptypes.ExporterImageConfigKey, TargetImage), config)
+	}
+	psBytes, err := json.Marshal(ps)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal platforms of result: %v", err)
+	}
+	res.AddMeta(exptypes.ExporterPlatformsKey, psBytes)
+
+	return nil
+}
+
+// ArtifactsPredicate is the predicate of the attestation that links an image
+// to its artifacts
+type ArtifactsPredicate struct {
+	// The digest of the LLB definition of the image
+	Image string `json:"image,omitempty"`
+	// The artifacts that were built along with the image
+	Artifacts []ArtifactInfo `json:"artifacts"`
+}
+
+// ArtifactInfo describes an artifact in the attestation of an image
+type ArtifactInfo struct {
+	// The target of the artifact
+	Target string `json:"target"`
+	// The digest of the LLB definition of the artifact
+	LLBDigest string `json:"llbDigest"`
+}
+
+// ArtifactsAttestationLLB creates the LLB definition of a file with the
+// predicate of the attestation that lists the given artifacts of the image.
+func ArtifactsAttestationLLB(img ocispecs.Image, defs map[string]*llb.Definition) (*llb.Definition, error) {
+	predicate := ArtifactsPredicate{
+		Image:     img.Config.Labels[LLBDigestAnnotation],
+		Artifacts: []ArtifactInfo{},
+	}
+	for _, target := range []string{TargetKernel, TargetRootfs} {
+		def, ok := defs[target]
+		if !ok {
+			continue
+		}
+		dgst, err := def.Head()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get digest of LLB of %s artifact: %v", target, err)
+		}
+		predicate.Artifacts = append(predicate.Artifacts, ArtifactInfo{
+			Target:    target,
+			LLBDigest: dgst.String(),
+		})
+	}
+	predicateBytes, err := json.Marshal(predicate)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal predicate of artifacts: %v", err)
+	}
+
+	return attestationLLB(map[string][]byte{artifactsPredicatePath: predicateBytes})
+}
+
+// AddArtifactsAttestation attaches the attestation of the artifacts, as
+// created by ArtifactsAttestationLLB, to the image of the result, so
+// registries and tools can discover the artifacts of an image.
+func AddArtifactsAttestation(res *client.Result, ref client.Reference) error {
+	return AddAttestation(res, ref, artifactsPredicatePath, ArtifactsPredicateType)
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactsTargets(t *testing.T) {
+	require.False(t, Artifacts{}.Enabled())
+	require.Nil(t, Artifacts{}.Targets())
+	require.True(t, Artifacts{Rootfs: true}.Enabled())
+	require.Equal(t, []string{TargetRootfs}, Artifacts{Rootfs: true}.Targets())
+	require.Equal(t, []string{TargetKernel, TargetRootfs}, Artifacts{Kernel: true, Rootfs: true}.Targets())
+}
+
+func TestArtifactsLLB(t *testing.T) {
+	t.Run("Kernel and rootfs", func(t *testing.T) {
+		h := targetHops()
+		h.Artifacts = Artifacts{Kernel: true, Rootfs: true}
+		i, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		defs, err := ArtifactsLLB(context.TODO(), *i)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(defs))
+		require.Equal(t, "/app_qemu-x86_64", targetCopy(t, defs[TargetKernel].Def).Dest)
+		require.Equal(t, "/rootfs.cpio", targetCopy(t, defs[TargetRootfs].Def).Dest)
+	})
+	t.Run("No artifacts", func(t *testing.T) {
+		i, err := ToPack(context.TODO(), targetHops(), "context")
+		require.NoError(t, err)
+		defs, err := ArtifactsLLB(context.TODO(), *i)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(defs))
+	})
+	t.Run("Missing rootfs", func(t *testing.T) {
+		h := targetHops()
+		h.Rootfs = Rootfs{}
+		h.Artifacts = Artifacts{Rootfs: true}
+		i, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		_, err = ArtifactsLLB(context.TODO(), *i)
+		require.ErrorContains(t, err, "Failed to create LLB of rootfs artifact")
+	})
+}
+
+func TestArtifactsApply(t *testing.T) {
+	t.Run("Kernel artifact", func(t *testing.T) {
+		image := &fakeRef{}
+		kernel := &fakeRef{}
+		res := client.NewResult()
+		res.SetRef(image)
+		res.AddMeta(exptypes.ExporterImageConfigKey, []byte("{}"))
+
+		var img ocispecs.Image
+		img.Config.Labels = map[string]string{
+			LLBDigestAnnotation:              "sha256:abcd",
+			"com.urunc.unikernel.hypervisor": "qemu",
+		}
+		err := ApplyArtifacts(res, map[string]client.Reference{TargetKernel: kernel}, img)
+		require.NoError(t, err)
+		require.Nil(t, res.Ref)
+		require.Equal(t, map[string]client.Reference{
+			TargetImage:  image,
+			TargetKernel: kernel,
+		}, res.Refs)
+		require.Equal(t, []byte("{}"), res.Metadata[exptypes.ExporterImageConfigKey+"/"+TargetImage])
+
+		var ps exptypes.Platforms
+		err = json.Unmarshal(res.Metadata[exptypes.ExporterPlatformsKey], &ps)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(ps.Platforms))
+		require.Equal(t, TargetImage, ps.Platforms[0].ID)
+		require.Equal(t, TargetKernel, ps.Platforms[1].ID)
+		require.Equal(t, ocispecs.Platform{OS: "linux", Architecture: ps.Platforms[0].Platform.Architecture}, ps.Platforms[1].Platform)
+
+		// The artifact gets its own config with the build information
+		var config ocispecs.Image
+		err = json.Unmarshal(res.Metadata[exptypes.ExporterImageConfigKey+"/"+TargetKernel], &config)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			ArtifactTypeLabel:   TargetKernel,
+			LLBDigestAnnotation: "sha256:abcd",
+		}, config.Config.Labels)
+		require.Equal(t, "layers", config.RootFS.Type)
+		require.Equal(t, ps.Platforms[1].Platform, config.Platform)
+	})
+	t.Run("No artifacts", func(t *testing.T) {
+		image := &fakeRef{}
+		res := client.NewResult()
+		res.SetRef(image)
+
+		err := ApplyArtifacts(res, nil, ocispecs.Image{})
+		require.NoError(t, err)
+		require.Equal(t, client.Reference(image), res.Ref)
+		require.Nil(t, res.Refs)
+	})
+}
+
+func TestArtifactsAttestation(t *testing.T) {
+	h := targetHops()
+	h.Artifacts = Artifacts{Kernel: true, Rootfs: true}
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	defs, err := ArtifactsLLB(context.TODO(), *i)
+	require.NoError(t, err)
+
+	var img ocispecs.Image
+	img.Config.Labels = map[string]string{LLBDigestAnnotation: "sha256:abcd"}
+	def, err := ArtifactsAttestationLLB(img, defs)
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var data []byte
+	for _, op := range arr {
+		if file := op.GetFile(); file != nil {
+			mkfile := file.Actions[0].GetMkfile()
+			require.Equal(t, "/artifacts.json", mkfile.Path)
+			data = mkfile.Data
+		}
+	}
+	var predicate ArtifactsPredicate
+	err = json.Unmarshal(data, &predicate)
+	require.NoError(t, err)
+	require.Equal(t, "sha256:abcd", predicate.Image)
+	require.Equal(t, 2, len(predicate.Artifacts))
+	require.Equal(t, TargetKernel, predicate.Artifacts[0].Target)
+	kernelDgst, err := defs[TargetKernel].Head()
+	require.NoError(t, err)
+	require.Equal(t, kernelDgst.String(), predicate.Artifacts[0].LLBDigest)
+	require.Equal(t, TargetRootfs, predicate.Artifacts[1].Target)
+
+	res := client.NewResult()
+	res.SetRef(&fakeRef{})
+	err = ApplyArtifacts(res, map[string]client.Reference{TargetKernel: &fakeRef{}}, img)
+	require.NoError(t, err)
+	ref := &fakeRef{}
+	err = AddArtifactsAttestation(res, ref)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res.Attestations[TargetImage]))
+	att := res.Attestations[TargetImage][0]
+	require.Equal(t, client.Reference(ref), att.Ref)
+	require.Equal(t, "/artifacts.json", att.Path)
+	require.Equal(t, ArtifactsPredicateType, att.InToto.PredicateType)
+}
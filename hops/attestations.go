@@ -0,0 +1,99 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	gwpb "github.com/moby/buildkit/frontend/gateway/pb"
+	"github.com/moby/buildkit/solver/result"
+)
+
+const (
+	// The predicate type of the attestation with the urunc.json of an image
+	UruncPredicateType string = "https://github.com/nubificus/bunny/urunc/v1"
+	// The predicate type of the attestation with the build report of an
+	// image
+	BuildReportPredicateType string = "https://github.com/nubificus/bunny/build-report/v1"
+	uruncPredicatePath       string = "/urunc.json"
+	buildReportPredicatePath string = "/build-report.json"
+)
+
+// attestationLLB creates the LLB definition of the files with the predicates
+// of attestations, keyed by their path.
+func attestationLLB(files map[string][]byte) (*llb.Definition, error) {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	st := llb.Scratch()
+	for _, p := range paths {
+		st = st.File(llb.Mkfile(p, 0644, files[p]),
+			llb.WithCustomName("Internal:Create attestation "+p))
+	}
+
+	return st.Marshal(context.TODO())
+}
+
+// AddAttestation attaches an in-toto attestation to the image of the result.
+// The predicate is the file in the given path of the reference. The image
+// exporter stores the attestation in a manifest whose subject is the image,
+// so registries and tools can discover it through the image.
+func AddAttestation(res *client.Result, ref client.Reference, path string, predicateType string) error {
+	// Buildkit matches the attestations to the references by the ID of
+	// their platform, which is the first one for the image
+	ps, err := exptypes.ParsePlatforms(res.Metadata)
+	if err != nil {
+		return fmt.Errorf("Failed to get platforms of result: %v", err)
+	}
+	res.AddAttestation(ps.Platforms[0].ID, client.Attestation{
+		Kind: gwpb.AttestationKind_InToto,
+		Ref:  ref,
+		Path: path,
+		InToto: result.InTotoAttestation{
+			PredicateType: predicateType,
+		},
+	})
+
+	return nil
+}
+
+// MetadataAttestationLLB creates the LLB definition of the predicates of the
+// attestations with the urunc.json and the build report of the image.
+func MetadataAttestationLLB(uruncJSON []byte, report []byte) (*llb.Definition, error) {
+	return attestationLLB(map[string][]byte{
+		uruncPredicatePath:       uruncJSON,
+		buildReportPredicatePath: report,
+	})
+}
+
+// AddMetadataAttestations attaches the urunc.json and the build report, as
+// created by MetadataAttestationLLB, to the image of the result, so runtime
+// agents can fetch them without pulling the image.
+func AddMetadataAttestations(res *client.Result, ref client.Reference) error {
+	err := AddAttestation(res, ref, uruncPredicatePath, UruncPredicateType)
+	if err != nil {
+		return err
+	}
+
+	return AddAttestation(res, ref, buildReportPredicatePath, BuildReportPredicateType)
+}
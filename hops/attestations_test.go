@@ -0,0 +1,75 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttestationsMetadataLLB(t *testing.T) {
+	def, err := MetadataAttestationLLB([]byte(`{"a":"b"}`), []byte(`{"steps":1}`))
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	files := map[string]string{}
+	for _, op := range arr {
+		if file := op.GetFile(); file != nil {
+			mkfile := file.Actions[0].GetMkfile()
+			files[mkfile.Path] = string(mkfile.Data)
+		}
+	}
+	require.Equal(t, map[string]string{
+		"/urunc.json":        `{"a":"b"}`,
+		"/build-report.json": `{"steps":1}`,
+	}, files)
+}
+
+func TestAttestationsAdd(t *testing.T) {
+	t.Run("Single image", func(t *testing.T) {
+		res := client.NewResult()
+		res.SetRef(&fakeRef{})
+		res.AddMeta(exptypes.ExporterImageConfigKey, []byte(`{"os":"linux","architecture":"arm64"}`))
+		ref := &fakeRef{}
+		err := AddMetadataAttestations(res, ref)
+		require.NoError(t, err)
+		atts := res.Attestations["linux/arm64"]
+		require.Equal(t, 2, len(atts))
+		require.Equal(t, UruncPredicateType, atts[0].InToto.PredicateType)
+		require.Equal(t, "/urunc.json", atts[0].Path)
+		require.Equal(t, BuildReportPredicateType, atts[1].InToto.PredicateType)
+		require.Equal(t, "/build-report.json", atts[1].Path)
+		require.Equal(t, client.Reference(ref), atts[1].Ref)
+	})
+	t.Run("Image with artifacts", func(t *testing.T) {
+		res := client.NewResult()
+		res.SetRef(&fakeRef{})
+		err := ApplyArtifacts(res, map[string]client.Reference{TargetRootfs: &fakeRef{}}, ocispecs.Image{})
+		require.NoError(t, err)
+		err = AddAttestation(res, &fakeRef{}, "/urunc.json", UruncPredicateType)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(res.Attestations[TargetImage]))
+		require.Empty(t, res.Attestations[TargetRootfs])
+	})
+	t.Run("Invalid platforms", func(t *testing.T) {
+		res := client.NewResult()
+		res.AddMeta(exptypes.ExporterPlatformsKey, []byte(`{"platforms":[]}`))
+		err := AddAttestation(res, &fakeRef{}, "/urunc.json", UruncPredicateType)
+		require.ErrorContains(t, err, "Failed to get platforms of result")
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+// BaseDecision explains which state became the base of the final image and
+// which files got copied on top of it
+type BaseDecision struct {
+	// The base of the final image, e.g. scratch or an image reference
+	Base string `json:"base"`
+	// Why this base was chosen
+	Reason string `json:"reason"`
+	// The files copied on top of the base
+	Copies []CopyDecision `json:"copies,omitempty"`
+}
+
+// CopyDecision describes a file that got copied on top of the base
+type CopyDecision struct {
+	// The file that got copied, kernel, rootfs or dtb
+	Name string `json:"name"`
+	// The reference of the state where the file resides
+	From string `json:"from"`
+	// The path of the file inside its state
+	Src string `json:"src"`
+	// The path of the file inside the final image
+	Dst string `json:"dst"`
+}
+
+// setBase sets the base of the final image and records the reason in the
+// decision of the base. The name describes the base to users.
+func (i *PackInstructions) setBase(state llb.State, ref string, name string, reason string) {
+	i.Base = state
+	i.BaseRef = ref
+	if i.BaseDecision == nil {
+		i.BaseDecision = &BaseDecision{}
+	}
+	i.BaseDecision.Base = name
+	i.BaseDecision.Reason = reason
+}
+
+// copyEntry copies the file of the given entry to dst in the final image and
+// records the copy in the decision of the base.
+func (i *PackInstructions) copyEntry(name string, entry PackEntry, dst string) {
+	aCopy := makeCopy(entry, dst)
+	aCopy.Name = name
+	i.Copies = append(i.Copies, aCopy)
+	if i.BaseDecision == nil {
+		i.BaseDecision = &BaseDecision{}
+	}
+	i.BaseDecision.Copies = append(i.BaseDecision.Copies, CopyDecision{
+		Name: name,
+		From: entry.SourceRef,
+		Src:  entry.FilePath,
+		Dst:  dst,
+	})
+}
+
+// String returns a human readable form of the decision
+func (d BaseDecision) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Base of the final image: %s (%s)\n", d.Base, d.Reason)
+	for _, c := range d.Copies {
+		fmt.Fprintf(&b, "\tcopy %s %s from %s to %s\n", c.Name, c.Src, c.From, c.Dst)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseDecision(t *testing.T) {
+	localKernel := &PackEntry{
+		SourceRef:   "local",
+		SourceState: llb.Local("context"),
+		FilePath:    "kernel",
+	}
+	imageKernel := &PackEntry{
+		SourceRef:   "harbor.nbfc.io/foo",
+		SourceState: llb.Image("harbor.nbfc.io/foo"),
+		FilePath:    "/kernel",
+	}
+	t.Run("Kernel local Rootfs local", func(t *testing.T) {
+		r := &PackEntry{
+			SourceRef:   "local",
+			SourceState: llb.Local("context"),
+			FilePath:    "rootfs",
+		}
+		i := &PackInstructions{}
+		_, _, err := i.SetBaseAndGetPaths(localKernel, r)
+		require.NoError(t, err)
+		require.Equal(t, &BaseDecision{
+			Base:   "scratch",
+			Reason: "the kernel does not come from an image",
+			Copies: []CopyDecision{
+				{Name: "kernel", From: "local", Src: "kernel", Dst: DefaultKernelPath},
+				{Name: "rootfs", From: "local", Src: "rootfs", Dst: DefaultRootfsPath},
+			},
+		}, i.BaseDecision)
+	})
+	t.Run("Kernel registry Rootfs none", func(t *testing.T) {
+		i := &PackInstructions{}
+		_, _, err := i.SetBaseAndGetPaths(imageKernel, &PackEntry{})
+		require.NoError(t, err)
+		require.Equal(t, &BaseDecision{
+			Base:   "harbor.nbfc.io/foo",
+			Reason: "the kernel image already contains the kernel",
+		}, i.BaseDecision)
+	})
+	t.Run("Kernel registry Rootfs raw", func(t *testing.T) {
+		r := &PackEntry{
+			SourceRef:   "harbor.nbfc.io/rootfs",
+			SourceState: llb.Image("harbor.nbfc.io/rootfs"),
+		}
+		i := &PackInstructions{}
+		_, _, err := i.SetBaseAndGetPaths(imageKernel, r)
+		require.NoError(t, err)
+		require.Equal(t, "harbor.nbfc.io/rootfs", i.BaseDecision.Base)
+		require.Equal(t, "a raw rootfs is the filesystem of the image", i.BaseDecision.Reason)
+		require.Equal(t, []CopyDecision{
+			{Name: "kernel", From: "harbor.nbfc.io/foo", Src: "/kernel", Dst: DefaultKernelPath},
+		}, i.BaseDecision.Copies)
+	})
+	t.Run("Rootfs created raw", func(t *testing.T) {
+		r := &PackEntry{
+			SourceRef:   "scratch",
+			SourceState: llb.Local("context"),
+		}
+		i := &PackInstructions{}
+		_, _, err := i.SetBaseAndGetPaths(localKernel, r)
+		require.NoError(t, err)
+		require.Equal(t, "the created rootfs", i.BaseDecision.Base)
+		require.Len(t, i.BaseDecision.Copies, 1)
+	})
+	t.Run("User base", func(t *testing.T) {
+		b := &PackEntry{
+			SourceRef:   "scratch",
+			SourceState: llb.Scratch(),
+		}
+		i := &PackInstructions{}
+		_, _, err := i.SetUserBaseAndGetPaths(b, imageKernel, &PackEntry{})
+		require.NoError(t, err)
+		require.Equal(t, "scratch", i.BaseDecision.Base)
+		require.Equal(t, "set by the base field", i.BaseDecision.Reason)
+		require.Len(t, i.BaseDecision.Copies, 1)
+	})
+}
+
+func TestBaseDecisionString(t *testing.T) {
+	d := BaseDecision{
+		Base:   "scratch",
+		Reason: "the kernel does not come from an image",
+		Copies: []CopyDecision{
+			{Name: "kernel", From: "local", Src: "kernel", Dst: DefaultKernelPath},
+		},
+	}
+	require.Equal(t, "Base of the final image: scratch (the kernel does not come from an image)\n"+
+		"\tcopy kernel kernel from local to /.boot/kernel\n", d.String())
+}
+
+// baseCase is a kernel or a rootfs entry of the generated cases, along with
+// whether its state can become the base of the final image
+type baseCase struct {
+	name  string
+	entry PackEntry
+	// The state is an image (or a created raw rootfs) and holds the file
+	image bool
+}
+
+// baseKernelCases returns every kind of kernel source with a few paths
+func baseKernelCases() []baseCase {
+	var cases []baseCase
+	for _, p := range []string{"kernel", "build/kernel", DefaultKernelPath} {
+		cases = append(cases, baseCase{
+			name:  "local " + p,
+			entry: PackEntry{SourceRef: "local", SourceState: llb.Local("context"), FilePath: p},
+		})
+	}
+	cases = append(cases, baseCase{
+		name:  "build",
+		entry: PackEntry{SourceRef: KernelFromBuild, SourceState: llb.Image("harbor.nbfc.io/kernel-builder"), FilePath: "/out/kernel"},
+	})
+	for _, p := range []string{"/kernel", DefaultKernelPath} {
+		cases = append(cases, baseCase{
+			name:  "image " + p,
+			entry: PackEntry{SourceRef: "harbor.nbfc.io/kernel", SourceState: llb.Image("harbor.nbfc.io/kernel"), FilePath: p},
+			image: true,
+		})
+	}
+	cases = append(cases, baseCase{
+		name: "unikraft image",
+		entry: PackEntry{SourceRef: "unikraft.org/nginx:1.15",
+			SourceState: GetSourceState("unikraft.org/nginx:1.15", "qemu", "amd64"), FilePath: "/unikraft/bin/kernel"},
+		image: true,
+	})
+
+	return cases
+}
+
+// baseRootfsCases returns every kind of rootfs source with a few paths
+func baseRootfsCases() []baseCase {
+	cases := []baseCase{{name: "none"}}
+	for _, p := range []string{"rootfs", "out/rootfs.cpio"} {
+		cases = append(cases, baseCase{
+			name:  "local " + p,
+			entry: PackEntry{SourceRef: "local", SourceState: llb.Local("context"), FilePath: p},
+		})
+	}
+	cases = append(cases,
+		baseCase{
+			name:  "created",
+			entry: PackEntry{SourceRef: "scratch", SourceState: llb.Image("harbor.nbfc.io/rootfs-builder"), FilePath: DefaultRootfsPath},
+		},
+		baseCase{
+			name:  "created raw",
+			entry: PackEntry{SourceRef: "scratch", SourceState: llb.Image("harbor.nbfc.io/raw-builder")},
+			image: true,
+		},
+		baseCase{
+			name:  "image raw",
+			entry: PackEntry{SourceRef: "harbor.nbfc.io/rootfs", SourceState: llb.Image("harbor.nbfc.io/rootfs")},
+			image: true,
+		},
+	)
+	for _, p := range []string{"/rootfs", DefaultRootfsPath} {
+		cases = append(cases, baseCase{
+			name:  "image " + p,
+			entry: PackEntry{SourceRef: "harbor.nbfc.io/rootfs", SourceState: llb.Image("harbor.nbfc.io/rootfs"), FilePath: p},
+			image: true,
+		})
+	}
+
+	return cases
+}
+
+// baseStateKey identifies a state, the empty key being scratch
+func baseStateKey(t *testing.T, state llb.State) string {
+	t.Helper()
+	key, err := stateKey(state)
+	require.NoError(t, err)
+
+	return key.String()
+}
+
+// minBaseCopies returns the fewest copies that any base needs to hold both
+// files, a raw rootfs being the base itself
+func minBaseCopies(k baseCase, r baseCase) int {
+	copies := func(kernelInBase bool, rootfsInBase bool) int {
+		n := 0
+		if !kernelInBase {
+			n++
+		}
+		if r.entry.SourceRef != "" && !rootfsInBase {
+			n++
+		}
+		return n
+	}
+	if r.entry.SourceRef != "" && r.entry.FilePath == "" {
+		return copies(false, true)
+	}
+	best := copies(false, false)
+	if k.image {
+		best = min(best, copies(true, false))
+	}
+	if r.image {
+		best = min(best, copies(false, true))
+	}
+
+	return best
+}
+
+func TestBaseProperties(t *testing.T) {
+	for _, k := range baseKernelCases() {
+		for _, r := range baseRootfsCases() {
+			t.Run(fmt.Sprintf("Kernel %s Rootfs %s", k.name, r.name), func(t *testing.T) {
+				kEntry, rEntry := k.entry, r.entry
+				i := &PackInstructions{}
+				kPath, rPath, err := i.SetBaseAndGetPaths(&kEntry, &rEntry)
+				require.NoError(t, err)
+				// The entries stay as they were
+				require.Equal(t, k.entry.SourceRef+" "+k.entry.FilePath, kEntry.SourceRef+" "+kEntry.FilePath)
+				require.Equal(t, r.entry.SourceRef+" "+r.entry.FilePath, rEntry.SourceRef+" "+rEntry.FilePath)
+
+				baseKey := baseStateKey(t, i.Base)
+				// lookup returns the state and the path where a file of
+				// the final image comes from
+				lookup := func(p string) (string, string, bool) {
+					for j := len(i.Copies) - 1; j >= 0; j-- {
+						if i.Copies[j].DstPath == p {
+							return baseStateKey(t, i.Copies[j].SrcState), i.Copies[j].SrcPath, true
+						}
+					}
+					return baseKey, p, baseKey != ""
+				}
+
+				// The kernel is always reachable in the final image
+				require.NotEmpty(t, kPath)
+				key, src, ok := lookup(kPath)
+				require.True(t, ok, "no kernel at %s", kPath)
+				require.Equal(t, baseStateKey(t, kEntry.SourceState), key)
+				require.Equal(t, kEntry.FilePath, src)
+
+				// So is the rootfs, unless it is the base itself
+				switch {
+				case rEntry.SourceRef == "":
+					require.Empty(t, rPath)
+				case rEntry.FilePath == "":
+					require.Empty(t, rPath)
+					require.Equal(t, baseStateKey(t, rEntry.SourceState), baseKey)
+				default:
+					require.NotEmpty(t, rPath)
+					key, src, ok := lookup(rPath)
+					require.True(t, ok, "no rootfs at %s", rPath)
+					require.Equal(t, baseStateKey(t, rEntry.SourceState), key)
+					require.Equal(t, rEntry.FilePath, src)
+					require.NotEqual(t, kPath, rPath)
+				}
+
+				// No copy overwrites another one or the file itself
+				dsts := map[string]bool{}
+				for _, c := range i.Copies {
+					require.False(t, dsts[c.DstPath], "duplicate copy to %s", c.DstPath)
+					dsts[c.DstPath] = true
+					require.False(t, baseStateKey(t, c.SrcState) == baseKey && c.SrcPath == c.DstPath,
+						"copy of %s onto itself", c.DstPath)
+				}
+
+				// The base needs as few copies as possible
+				require.Len(t, i.Copies, minBaseCopies(k, r))
+
+				// The decision describes what happened
+				require.NotNil(t, i.BaseDecision)
+				require.NotEmpty(t, i.BaseDecision.Base)
+				require.NotEmpty(t, i.BaseDecision.Reason)
+				require.Len(t, i.BaseDecision.Copies, len(i.Copies))
+				for j, c := range i.Copies {
+					require.Equal(t, c.SrcPath, i.BaseDecision.Copies[j].Src)
+					require.Equal(t, c.DstPath, i.BaseDecision.Copies[j].Dst)
+				}
+			})
+		}
+	}
+
+	t.Run("Kernel without source", func(t *testing.T) {
+		for _, r := range baseRootfsCases() {
+			i := &PackInstructions{}
+			_, _, err := i.SetBaseAndGetPaths(&PackEntry{FilePath: "kernel"}, &r.entry)
+			require.ErrorContains(t, err, "Source of kernel State is empty")
+			require.Empty(t, i.Copies)
+		}
+	})
+}
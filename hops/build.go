@@ -0,0 +1,99 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+)
+
+const (
+	// Building a unikraft kernel with kraft
+	BuildStepKernel string = "kernel"
+	// Creating an initrd rootfs
+	BuildStepInitrd string = "initrd"
+	// Running the smoke test
+	BuildStepTest string = "test"
+	// Inspecting the architecture of the kernel
+	BuildStepCheck string = "check"
+	// Scanning the rootfs for vulnerabilities
+	BuildStepScan string = "scan"
+	// Packaging the kernel modules
+	BuildStepModules string = "modules"
+	// Running the hooks of the bunnyfile
+	BuildStepHooks string = "hooks"
+)
+
+// The network modes of exec operations, as buildkit names them
+var netModes = map[string]pb.NetMode{
+	"sandbox": llb.NetModeSandbox,
+	"host":    llb.NetModeHost,
+	"none":    llb.NetModeNone,
+}
+
+// BuildOptions configures the exec operations that bunny generates for each
+// step of the build
+type BuildOptions struct {
+	// The network mode of each step, the default of the step if missing
+	Network map[string]string `yaml:"network"`
+	// The deadline of the whole build, e.g. 30m, without one if empty
+	Timeout string `yaml:"timeout"`
+}
+
+// GetTimeout returns the deadline of the whole build, which is 0 without a
+// timeout
+func (b BuildOptions) GetTimeout() (time.Duration, error) {
+	if b.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(b.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid timeout %s: %v", b.Timeout, err)
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("Timeout %s should be at least 1s", b.Timeout)
+	}
+
+	return d, nil
+}
+
+// BuildTimeout returns the deadline of the whole build that the given
+// bunnyfile defines, before the rest of the parsing, so the deadline covers
+// the parsing too. Files that are not bunnyfiles (e.g. Containerfiles) and
+// invalid timeouts have no deadline, since the parsing reports their errors.
+func BuildTimeout(fileBytes []byte) time.Duration {
+	var h Hops
+	_, err := unmarshalBunnyfile(fileBytes, nil, &h)
+	if err != nil {
+		return 0
+	}
+	timeout, _ := h.Build.GetTimeout()
+
+	return timeout
+}
+
+// NetworkOptions returns the options that set the network mode of the given
+// step, if the user defined one.
+func (b BuildOptions) NetworkOptions(step string) []llb.RunOption {
+	mode, ok := netModes[b.Network[step]]
+	if !ok {
+		return nil
+	}
+
+	return []llb.RunOption{llb.Network(mode)}
+}
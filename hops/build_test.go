@@ -0,0 +1,182 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildNetworkOptions(t *testing.T) {
+	b := BuildOptions{Network: map[string]string{
+		BuildStepKernel: "host",
+		BuildStepInitrd: "sandbox",
+	}}
+	require.Len(t, b.NetworkOptions(BuildStepKernel), 1)
+	require.Len(t, b.NetworkOptions(BuildStepInitrd), 1)
+	require.Nil(t, b.NetworkOptions(BuildStepTest))
+	require.Nil(t, BuildOptions{}.NetworkOptions(BuildStepKernel))
+}
+
+func TestBuildNetworkKraft(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    BuildOptions
+		hardened bool
+		expected pb.NetMode
+	}{
+		{
+			name:     "Default",
+			expected: pb.NetMode_UNSET,
+		},
+		{
+			name:     "Host",
+			build:    BuildOptions{Network: map[string]string{BuildStepKernel: "host"}},
+			expected: pb.NetMode_HOST,
+		},
+		{
+			name:     "None in hardened mode",
+			build:    BuildOptions{Network: map[string]string{BuildStepKernel: "none"}},
+			hardened: true,
+			expected: pb.NetMode_NONE,
+		},
+		{
+			name:     "Other step",
+			build:    BuildOptions{Network: map[string]string{BuildStepTest: "none"}},
+			expected: pb.NetMode_UNSET,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			unikraft := &UnikraftInfo{Arch: "x86_64"}
+			state, err := unikraft.BuildKernel(context.TODO(), BuildInput{
+				BuildContext: "context",
+				Monitor:      "qemu",
+				Kernel:       Kernel{From: KernelFromBuild, Source: "app"},
+				Hardened:     tc.hardened,
+				Build:        tc.build,
+			})
+			require.NoError(t, err)
+			exec, _, _ := kraftExec(t, state)
+			require.Equal(t, tc.expected, exec.Network)
+		})
+	}
+}
+
+func TestBuildNetworkInitrd(t *testing.T) {
+	generic := &GenericInfo{Rootfs: Rootfs{Type: "initrd", Includes: []FileToInclude{{Src: "app", Dst: "/app"}}}}
+	t.Run("Default", func(t *testing.T) {
+		state, err := generic.CreateRootfs(context.TODO(), BuildInput{BuildContext: "context"})
+		require.NoError(t, err)
+		exec, _, _ := kraftExec(t, state)
+		require.Equal(t, pb.NetMode_NONE, exec.Network)
+	})
+	t.Run("Sandbox in hardened mode", func(t *testing.T) {
+		state, err := generic.CreateRootfs(context.TODO(), BuildInput{
+			BuildContext: "context",
+			Hardened:     true,
+			Build:        BuildOptions{Network: map[string]string{BuildStepInitrd: "sandbox"}},
+		})
+		require.NoError(t, err)
+		exec, _, _ := kraftExec(t, state)
+		require.Equal(t, pb.NetMode_UNSET, exec.Network)
+		requireHardened(t, exec)
+	})
+}
+
+func TestBuildNetworkSmokeTest(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("Smoke tests are not supported in", runtime.GOARCH)
+	}
+	instr := PackInstructions{
+		Annots: map[string]string{
+			"com.urunc.unikernel.hypervisor": "qemu",
+			"com.urunc.unikernel.binary":     DefaultKernelPath,
+		},
+		Test: SmokeTest{Marker: "Hello"},
+		Sources: SourceOpts{
+			Build: BuildOptions{Network: map[string]string{BuildStepTest: "none"}},
+		},
+	}
+	def, err := SmokeTestLLB(context.TODO(), instr, llb.Image("foo"))
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var exec *pb.ExecOp
+	for _, op := range arr {
+		if e := op.GetExec(); e != nil {
+			exec = e
+		}
+	}
+	require.NotNil(t, exec)
+	require.Equal(t, pb.NetMode_NONE, exec.Network)
+}
+
+func TestBuildTimeout(t *testing.T) {
+	timeout, err := BuildOptions{}.GetTimeout()
+	require.NoError(t, err)
+	require.Zero(t, timeout)
+	timeout, err = BuildOptions{Timeout: "1h30m"}.GetTimeout()
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Minute, timeout)
+	_, err = BuildOptions{Timeout: "soon"}.GetTimeout()
+	require.ErrorContains(t, err, "Invalid timeout soon")
+	_, err = BuildOptions{Timeout: "500ms"}.GetTimeout()
+	require.ErrorContains(t, err, "Timeout 500ms should be at least 1s")
+	require.ErrorContains(t, ValidateBuild(BuildOptions{Timeout: "-1m"}), "Invalid timeout field of build")
+
+	bunnyfile := []byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+build:
+  timeout: 20m
+`)
+	require.Equal(t, 20*time.Minute, BuildTimeout(bunnyfile))
+	h, err := ParseBunnyfile(bunnyfile)
+	require.NoError(t, err)
+	require.Equal(t, "20m", h.Build.Timeout)
+	require.Zero(t, BuildTimeout([]byte("FROM scratch\nCOPY kernel /kernel\n")))
+	require.Zero(t, BuildTimeout([]byte("build:\n  timeout: never\n")))
+}
+
+func TestBuildTimeoutCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	instr := PackInstructions{
+		Base:   llb.Image("alpine:3.20").Run(llb.Shlex("true")).Root(),
+		Annots: map[string]string{},
+		Sources: SourceOpts{
+			Hardened: true,
+			Resolver: &pinResolver{},
+		},
+	}
+	// The pinning of the tool images stops with the cancelled context
+	_, err := PackLLB(ctx, instr)
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = TargetLLB(ctx, instr, TargetImage)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = PackLLB(context.Background(), instr)
+	require.NoError(t, err)
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseBuildArg parses a build argument in the KEY=VALUE form. As with
+// docker, a KEY without a value takes the value of the respective
+// environment variable and it is skipped, if the variable is not set.
+func parseBuildArg(arg string, args map[string]string) error {
+	key, value, found := strings.Cut(arg, "=")
+	key = strings.TrimSpace(key)
+	if key == "" || strings.ContainsAny(key, " \t") {
+		return fmt.Errorf("Invalid build argument %q", arg)
+	}
+	if !found {
+		v, ok := os.LookupEnv(key)
+		if !ok {
+			return nil
+		}
+		value = v
+	}
+	args[key] = value
+
+	return nil
+}
+
+// ParseBuildArgs parses the given build arguments, as they are given to
+// --build-arg. A later argument overrides an earlier one with the same key.
+func ParseBuildArgs(list []string) (map[string]string, error) {
+	args := map[string]string{}
+	for _, arg := range list {
+		err := parseBuildArg(arg, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return args, nil
+}
+
+// ParseEnvFile parses a file with a KEY=VALUE pair in each line, like the
+// --env-file of docker. Empty lines and lines starting with # are skipped,
+// while the values are taken as they are, without removing any quotes.
+func ParseEnvFile(data []byte) (map[string]string, error) {
+	args := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(NormalizeFile(data)))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		err := parseBuildArg(line, args)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+// BuildArg declares a build argument of a bunnyfile, as ARG does in a
+// Containerfile
+type BuildArg struct {
+	// The name of the build argument
+	Name string `yaml:"name"`
+	// The value of the build argument, if it is not given
+	Default string `yaml:"default"`
+	// Fail, if the build argument is not given
+	Required bool `yaml:"required"`
+}
+
+// ResolveBuildArgs returns the given build arguments along with the default
+// values of the declared ones that are not given. Without any build
+// arguments (nil), e.g. when the bunnyfile is only inspected, the required
+// ones are not checked.
+func ResolveBuildArgs(decls []BuildArg, args map[string]string) (map[string]string, error) {
+	if len(decls) == 0 {
+		return args, nil
+	}
+
+	resolved := map[string]string{}
+	maps.Copy(resolved, args)
+	var missing []string
+	for _, d := range decls {
+		if _, ok := resolved[d.Name]; ok {
+			continue
+		}
+		if d.Required {
+			if args != nil {
+				missing = append(missing, d.Name)
+			}
+			continue
+		}
+		resolved[d.Name] = d.Default
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Missing required build arguments: %s", strings.Join(missing, ", "))
+	}
+
+	return resolved, nil
+}
+
+// buildArgNames returns the names of the build arguments that s refers to
+// with ${NAME}. Any invalid reference is skipped, since expandBuildArgs
+// reports it anyway.
+func buildArgNames(s string) []string {
+	var names []string
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			return names
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return names
+		}
+		names = append(names, s[start+2:start+end])
+		s = s[start+end+1:]
+	}
+}
+
+// checkDeclaredArgs returns an error, if a condition of the bunnyfile
+// refers to a build argument that is not declared in args.
+func checkDeclaredArgs(node *yaml.Node, decls []BuildArg) error {
+	if node.Kind == yaml.MappingNode {
+		if j := conditionOf(node); j >= 0 {
+			cond := node.Content[j+1]
+			for _, name := range buildArgNames(cond.Value) {
+				if !slices.ContainsFunc(decls, func(d BuildArg) bool { return d.Name == name }) {
+					return fmt.Errorf("The build argument %s at line %d is not declared in args", name, cond.Line)
+				}
+			}
+		}
+	}
+	for _, n := range node.Content {
+		err := checkDeclaredArgs(n, decls)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeBuildArgs decodes the declarations of the build arguments of the
+// given document, before its conditions get evaluated.
+func decodeBuildArgs(doc *yaml.Node) ([]BuildArg, error) {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	for j := 0; j+1 < len(root.Content); j += 2 {
+		if root.Content[j].Value != "args" {
+			continue
+		}
+		node := root.Content[j+1]
+		for _, entry := range node.Content {
+			if k := conditionOf(entry); k >= 0 {
+				return nil, fmt.Errorf("The %s field at line %d is not supported in args", conditionKey, entry.Content[k].Line)
+			}
+		}
+		var decls []BuildArg
+		err := node.Decode(&decls)
+		if err != nil {
+			return nil, err
+		}
+		return decls, nil
+	}
+
+	return nil, nil
+}
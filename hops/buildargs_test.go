@@ -0,0 +1,202 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildArgsParse(t *testing.T) {
+	t.Setenv("BUNNY_TEST_VERSION", "1.2")
+
+	args, err := ParseBuildArgs([]string{"FOO=bar", "EMPTY=", "EQ=a=b", "BUNNY_TEST_VERSION", "BUNNY_TEST_UNSET", "FOO=baz"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"FOO":                "baz",
+		"EMPTY":              "",
+		"EQ":                 "a=b",
+		"BUNNY_TEST_VERSION": "1.2",
+	}, args)
+
+	_, err = ParseBuildArgs([]string{"=bar"})
+	require.ErrorContains(t, err, `Invalid build argument "=bar"`)
+	_, err = ParseBuildArgs([]string{"FOO BAR=baz"})
+	require.ErrorContains(t, err, `Invalid build argument "FOO BAR=baz"`)
+}
+
+func TestBuildArgsEnvFile(t *testing.T) {
+	t.Setenv("BUNNY_TEST_VERSION", "1.2")
+
+	args, err := ParseEnvFile([]byte(`# The version of the app
+VERSION="1.0"
+
+  HTTP_PROXY=http://proxy:3128
+BUNNY_TEST_VERSION
+BUNNY_TEST_UNSET
+`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"VERSION":            `"1.0"`,
+		"HTTP_PROXY":         "http://proxy:3128",
+		"BUNNY_TEST_VERSION": "1.2",
+	}, args)
+
+	_, err = ParseEnvFile([]byte("FOO=bar\n=baz\n"))
+	require.ErrorContains(t, err, `line 2: Invalid build argument "=baz"`)
+
+	// Files from Windows editors
+	args, err = ParseEnvFile([]byte("\uFEFFVERSION=1.0\r\nMODE=debug\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"VERSION": "1.0", "MODE": "debug"}, args)
+}
+
+func TestBuildArgsContainerfile(t *testing.T) {
+	file := []byte(`FROM scratch
+ARG VERSION=dev
+LABEL com.urunc.unikernel.cmdline="app --version ${VERSION}"
+COPY app /app
+`)
+	packInst, err := ParseFile(context.TODO(), file, "context", nil, SourceOpts{})
+	require.NoError(t, err)
+	require.Equal(t, "app --version dev", packInst.Annots["com.urunc.unikernel.cmdline"])
+
+	packInst, err = ParseFile(context.TODO(), file, "context", nil, SourceOpts{
+		BuildArgs: map[string]string{"VERSION": "1.2"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "app --version 1.2", packInst.Annots["com.urunc.unikernel.cmdline"])
+
+	// The build arguments take precedence over the proxies
+	packInst, err = ParseFile(context.TODO(), []byte(`FROM scratch
+ARG HTTP_PROXY
+LABEL com.urunc.unikernel.cmdline="app ${HTTP_PROXY}"
+COPY app /app
+`), "context", nil, SourceOpts{
+		Network:   NetworkPolicy{Proxy: &llb.ProxyEnv{HTTPProxy: "http://proxy:3128"}},
+		BuildArgs: map[string]string{"HTTP_PROXY": "http://other:3128"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "app http://other:3128", packInst.Annots["com.urunc.unikernel.cmdline"])
+}
+
+func TestBuildArgsResolve(t *testing.T) {
+	decls := []BuildArg{
+		{Name: "MODE", Default: "release"},
+		{Name: "TAG", Required: true},
+		{Name: "DEBUG"},
+	}
+
+	args, err := ResolveBuildArgs(decls, map[string]string{"TAG": "v1", "EXTRA": "1"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"MODE": "release", "TAG": "v1", "DEBUG": "", "EXTRA": "1"}, args)
+
+	args, err = ResolveBuildArgs(decls, map[string]string{"TAG": "v1", "MODE": "debug"})
+	require.NoError(t, err)
+	require.Equal(t, "debug", args["MODE"])
+
+	_, err = ResolveBuildArgs(append(decls, BuildArg{Name: "ARCH", Required: true}), map[string]string{})
+	require.ErrorContains(t, err, "Missing required build arguments: TAG, ARCH")
+
+	// Without build arguments, only the defaults apply
+	args, err = ResolveBuildArgs(decls, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"MODE": "release", "DEBUG": ""}, args)
+
+	args, err = ResolveBuildArgs(nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, args)
+}
+
+const buildArgsBunnyfile = `version: v0.1
+args:
+  - name: MODE
+    default: release
+  - name: TAG
+    required: true
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+cmd:
+  - /init
+  - value: --debug
+    when: ${MODE} == debug
+`
+
+func TestBuildArgsDeclarations(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		h, err := ParseBunnyfileArgs([]byte(buildArgsBunnyfile), map[string]string{"TAG": "v1"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"/init"}, h.Cmd)
+		require.Equal(t, map[string]string{"MODE": "release", "TAG": "v1"}, h.BuildArgs)
+	})
+	t.Run("Given", func(t *testing.T) {
+		h, err := ParseBunnyfileArgs([]byte(buildArgsBunnyfile), map[string]string{"TAG": "v1", "MODE": "debug"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"/init", "--debug"}, h.Cmd)
+	})
+	t.Run("Missing required", func(t *testing.T) {
+		_, err := ParseBunnyfileArgs([]byte(buildArgsBunnyfile), map[string]string{})
+		require.ErrorContains(t, err, "Missing required build arguments: TAG")
+	})
+	t.Run("Without build arguments", func(t *testing.T) {
+		h, err := ParseBunnyfile([]byte(buildArgsBunnyfile))
+		require.NoError(t, err)
+		require.Equal(t, []string{"/init"}, h.Cmd)
+	})
+	t.Run("Undeclared", func(t *testing.T) {
+		_, err := ParseBunnyfileArgs([]byte(buildArgsBunnyfile+"  - value: --verbose\n    when: ${VERBOSE}\n"), map[string]string{"TAG": "v1"})
+		require.ErrorContains(t, err, "The build argument VERBOSE at line 18 is not declared in args")
+	})
+	t.Run("Condition in args", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte("version: v0.1\nargs:\n  - name: MODE\n    when: ${DEBUG}\n"))
+		require.ErrorContains(t, err, "The when field at line 4 is not supported in args")
+	})
+	t.Run("Name template", func(t *testing.T) {
+		instr, err := ParseFile(context.TODO(), []byte(buildArgsBunnyfile), "context", nil,
+			SourceOpts{BuildArgs: map[string]string{"TAG": "v1"}})
+		require.NoError(t, err)
+		names, err := instr.ImageNames("harbor.nbfc.io/app:${TAG}-${MODE}", instr.Sources.BuildArgs)
+		require.NoError(t, err)
+		require.Equal(t, "harbor.nbfc.io/app:v1-release", names)
+	})
+}
+
+func TestBuildArgsValidate(t *testing.T) {
+	tests := []struct {
+		decls []BuildArg
+		err   string
+	}{
+		{[]BuildArg{{Name: "MODE", Default: "release"}, {Name: "TAG", Required: true}}, ""},
+		{[]BuildArg{{Name: ""}}, `Invalid name "" of build argument in args`},
+		{[]BuildArg{{Name: "A B"}}, `Invalid name "A B" of build argument in args`},
+		{[]BuildArg{{Name: "MODE"}, {Name: "MODE"}}, "The build argument MODE is declared more than once in args"},
+		{[]BuildArg{{Name: "TAG", Default: "v1", Required: true}}, "The required build argument TAG can not have a default value"},
+	}
+	for _, tt := range tests {
+		err := ValidateArgs(tt.decls)
+		if tt.err == "" {
+			require.NoError(t, err)
+		} else {
+			require.ErrorContains(t, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"slices"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+// Builder constructs the instructions of a bunnyfile in Go, so tools can
+// compose builds without generating a bunnyfile. For example:
+//
+//	def, err := hops.NewBuild().
+//		Framework("unikraft").
+//		Monitor("qemu").
+//		KernelFromImage("unikraft.org/nginx:1.15", "/unikraft/bin/kernel").
+//		Include("nginx.conf", "/nginx/conf/nginx.conf").
+//		LLB(ctx, "context", hops.TargetImage, hops.SourceOpts{})
+type Builder struct {
+	h Hops
+}
+
+// NewBuild returns a builder for the current version of the bunnyfile
+func NewBuild() *Builder {
+	return &Builder{
+		h: Hops{Version: Version},
+	}
+}
+
+// Framework sets the framework of the unikernel
+func (b *Builder) Framework(framework string) *Builder {
+	b.h.Platform.Framework = framework
+	return b
+}
+
+// Monitor sets the monitor that executes the unikernel
+func (b *Builder) Monitor(monitor string) *Builder {
+	b.h.Platform.Monitor = monitor
+	return b
+}
+
+// Arch sets the architecture of the unikernel
+func (b *Builder) Arch(arch string) *Builder {
+	b.h.Platform.Arch = arch
+	return b
+}
+
+// UnikernelVersion sets the version of the unikernel
+func (b *Builder) UnikernelVersion(version string) *Builder {
+	b.h.Platform.Version = version
+	return b
+}
+
+// KernelFromImage takes the kernel from the given path of an OCI image
+func (b *Builder) KernelFromImage(ref string, path string) *Builder {
+	b.h.Kernel = Kernel{From: ref, Path: path}
+	return b
+}
+
+// KernelFromLocal takes the kernel from the given path of the build context
+func (b *Builder) KernelFromLocal(path string) *Builder {
+	b.h.Kernel = Kernel{From: "local", Path: path}
+	return b
+}
+
+// RootfsFromImage takes the rootfs from the given path of an OCI image
+func (b *Builder) RootfsFromImage(ref string, path string) *Builder {
+	b.h.Rootfs.From = ref
+	b.h.Rootfs.Path = path
+	return b
+}
+
+// RootfsFromLocal takes the rootfs from the given path of the build context
+func (b *Builder) RootfsFromLocal(path string) *Builder {
+	b.h.Rootfs.From = "local"
+	b.h.Rootfs.Path = path
+	return b
+}
+
+// RootfsType sets the type of the rootfs (e.g. initrd, raw or block)
+func (b *Builder) RootfsType(rootfsType string) *Builder {
+	b.h.Rootfs.Type = rootfsType
+	return b
+}
+
+// Include adds a file of the build context to the rootfs
+func (b *Builder) Include(src string, dst string) *Builder {
+	return b.IncludeFrom("local", src, dst)
+}
+
+// IncludeFrom adds a file of the given source to the rootfs
+func (b *Builder) IncludeFrom(from string, src string, dst string) *Builder {
+	b.h.Rootfs.Includes = append(b.h.Rootfs.Includes, FileToInclude{From: from, Src: src, Dst: dst})
+	return b
+}
+
+// Cmd sets the command line of the application
+func (b *Builder) Cmd(args ...string) *Builder {
+	b.h.Cmd = args
+	return b
+}
+
+// Entrypoint sets the entrypoint of the image
+func (b *Builder) Entrypoint(args ...string) *Builder {
+	b.h.Entrypoint = args
+	return b
+}
+
+// Env adds environment variables in the form of KEY=VALUE
+func (b *Builder) Env(envs ...string) *Builder {
+	b.h.Envs = append(b.h.Envs, envs...)
+	return b
+}
+
+// Base sets the base image of the final image
+func (b *Builder) Base(ref string) *Builder {
+	b.h.Base = ref
+	return b
+}
+
+// Flavors sets the flavors of the final image
+func (b *Builder) Flavors(flavors ...string) *Builder {
+	b.h.Flavors = flavors
+	return b
+}
+
+// Hardened runs the tools inside the build in hardened mode
+func (b *Builder) Hardened(hardened bool) *Builder {
+	b.h.Hardened = hardened
+	return b
+}
+
+// Hops returns the validated instructions, as ParseBunnyfile would return
+// them for the respective bunnyfile. The builder can be reused afterwards.
+func (b *Builder) Hops() (*Hops, error) {
+	h := b.h
+	h.Rootfs.Includes = slices.Clone(b.h.Rootfs.Includes)
+	h.Cmd = slices.Clone(b.h.Cmd)
+	h.Entrypoint = slices.Clone(b.h.Entrypoint)
+	h.Envs = slices.Clone(b.h.Envs)
+	h.Flavors = slices.Clone(b.h.Flavors)
+
+	err := ValidateHops(&h)
+	if err != nil {
+		return nil, err
+	}
+
+	return &h, nil
+}
+
+// ToPack returns the packing instructions of the build, as ParseFile would
+// return them for the respective bunnyfile. Without a client, the steps that
+// need one (e.g. detecting the framework) fail and the checks that need one
+// (e.g. of the kernel image) get skipped.
+func (b *Builder) ToPack(ctx context.Context, buildContext string, c client.Client, opts SourceOpts) (*PackInstructions, error) {
+	h, err := b.Hops()
+	if err != nil {
+		return nil, err
+	}
+
+	return packHops(ctx, h, buildContext, c, opts)
+}
+
+// LLB returns the LLB definition of the given target of the build, without
+// access to a buildkit client.
+func (b *Builder) LLB(ctx context.Context, buildContext string, target string, opts SourceOpts) (*llb.Definition, error) {
+	instr, err := b.ToPack(ctx, buildContext, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return TargetLLB(ctx, *instr, target)
+}
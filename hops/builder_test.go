@@ -0,0 +1,109 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilderHops(t *testing.T) {
+	t.Run("Same as the bunnyfile", func(t *testing.T) {
+		built, err := NewBuild().
+			Framework("unikraft").
+			Monitor("clh").
+			Arch("amd64").
+			KernelFromImage("unikraft.org/nginx:1.15", "/unikraft/bin/kernel").
+			RootfsType("initrd").
+			Include("nginx.conf", "/nginx/conf/nginx.conf").
+			Cmd("-c", "/nginx/conf/nginx.conf").
+			Env("FOO=bar").
+			Hops()
+		require.ErrorContains(t, err, "The cloud-hypervisor monitor is not supported for unikraft")
+		require.Nil(t, built)
+
+		built, err = NewBuild().
+			Framework("unikraft").
+			Monitor("qemu").
+			Arch("amd64").
+			KernelFromImage("unikraft.org/nginx:1.15", "/unikraft/bin/kernel").
+			RootfsType("initrd").
+			Include("nginx.conf", "/nginx/conf/nginx.conf").
+			Cmd("-c", "/nginx/conf/nginx.conf").
+			Env("FOO=bar").
+			Hops()
+		require.NoError(t, err)
+		parsed, err := ParseBunnyfile([]byte(`
+version: ` + Version + `
+platforms:
+  framework: unikraft
+  monitor: qemu
+  architecture: amd64
+kernel:
+  from: unikraft.org/nginx:1.15
+  path: /unikraft/bin/kernel
+rootfs:
+  type: initrd
+  include:
+    - nginx.conf:/nginx/conf/nginx.conf
+cmd: ["-c", "/nginx/conf/nginx.conf"]
+envs: ["FOO=bar"]
+`))
+		require.NoError(t, err)
+		require.Equal(t, parsed, built)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewBuild().Monitor("qemu").KernelFromLocal("kernel").Hops()
+		require.ErrorIs(t, err, errInvalidBunnyfile)
+		require.ErrorContains(t, err, "The framework field of platforms is necessary")
+	})
+	t.Run("Reusable", func(t *testing.T) {
+		b := NewBuild().Framework("linux").Monitor("qemu").KernelFromLocal("kernel").
+			RootfsType("initrd").Include("app", "/app")
+		first, err := b.Hops()
+		require.NoError(t, err)
+		b.Include("lib", "/lib")
+		second, err := b.Hops()
+		require.NoError(t, err)
+		require.Len(t, first.Rootfs.Includes, 1)
+		require.Len(t, second.Rootfs.Includes, 2)
+	})
+}
+
+func TestBuilderLLB(t *testing.T) {
+	b := NewBuild().
+		Framework("linux").
+		Monitor("qemu").
+		Arch("amd64").
+		KernelFromLocal("kernel").
+		RootfsType("initrd").
+		Include("app", "/app").
+		Cmd("/app")
+
+	instr, err := b.ToPack(context.TODO(), "context", nil, SourceOpts{Arch: "amd64"})
+	require.NoError(t, err)
+	require.Equal(t, "linux", instr.Annots["com.urunc.unikernel.unikernelType"])
+	require.Equal(t, "qemu", instr.Annots["com.urunc.unikernel.hypervisor"])
+
+	def, err := b.LLB(context.TODO(), "context", TargetImage, SourceOpts{Arch: "amd64"})
+	require.NoError(t, err)
+	require.NotEmpty(t, def.Def)
+	require.Contains(t, sourceIdentifiers(t, def), "local://context")
+
+	_, err = b.LLB(context.TODO(), "context", "foo", SourceOpts{})
+	require.Error(t, err)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CacheOpts contains the build caches to import from and export to, when
+// bunny builds with buildctl. Each cache is either a buildctl cache spec
+// (e.g. type=registry,ref=registry.local/cache), a directory (starting with
+// . or /) for a local cache or an image reference for a registry cache.
+type CacheOpts struct {
+	// The caches to import from
+	From []string
+	// The caches to export to
+	To []string
+}
+
+// cacheSpec converts the given cache to a buildctl cache spec. An exported
+// cache contains all the intermediate steps, so the expensive builds of the
+// frameworks can be reused.
+func cacheSpec(cache string, export bool) (string, error) {
+	switch {
+	case cache == "":
+		return "", fmt.Errorf("Empty cache")
+	case strings.HasPrefix(cache, "type="):
+		return cache, nil
+	case strings.HasPrefix(cache, ".") || strings.HasPrefix(cache, "/"):
+		if export {
+			return "type=local,dest=" + cache + ",mode=max", nil
+		}
+		return "type=local,src=" + cache, nil
+	case strings.ContainsAny(cache, ",="):
+		return "", fmt.Errorf("Invalid cache %s, expected a buildctl cache spec, a directory or an image", cache)
+	default:
+		if export {
+			return "type=registry,ref=" + cache + ",mode=max", nil
+		}
+		return "type=registry,ref=" + cache, nil
+	}
+}
+
+// BuildctlArgs returns the arguments of buildctl that import and export the
+// caches.
+func (o CacheOpts) BuildctlArgs() ([]string, error) {
+	var args []string
+	for _, cache := range o.From {
+		spec, err := cacheSpec(cache, false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--import-cache", spec)
+	}
+	for _, cache := range o.To {
+		spec, err := cacheSpec(cache, true)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--export-cache", spec)
+	}
+
+	return args, nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheBuildctlArgs(t *testing.T) {
+	t.Run("No caches", func(t *testing.T) {
+		args, err := CacheOpts{}.BuildctlArgs()
+		require.NoError(t, err)
+		require.Empty(t, args)
+	})
+	t.Run("Registry and local caches", func(t *testing.T) {
+		opts := CacheOpts{
+			From: []string{"registry.local/bunny/cache", "./cache"},
+			To:   []string{"registry.local/bunny/cache", "/tmp/cache"},
+		}
+		args, err := opts.BuildctlArgs()
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"--import-cache", "type=registry,ref=registry.local/bunny/cache",
+			"--import-cache", "type=local,src=./cache",
+			"--export-cache", "type=registry,ref=registry.local/bunny/cache,mode=max",
+			"--export-cache", "type=local,dest=/tmp/cache,mode=max",
+		}, args)
+	})
+	t.Run("Buildctl specs", func(t *testing.T) {
+		opts := CacheOpts{
+			From: []string{"type=gha"},
+			To:   []string{"type=inline"},
+		}
+		args, err := opts.BuildctlArgs()
+		require.NoError(t, err)
+		require.Equal(t, []string{"--import-cache", "type=gha", "--export-cache", "type=inline"}, args)
+	})
+	t.Run("Invalid caches", func(t *testing.T) {
+		_, err := CacheOpts{From: []string{""}}.BuildctlArgs()
+		require.ErrorContains(t, err, "Empty cache")
+		_, err = CacheOpts{To: []string{"ref=registry.local/cache"}}.BuildctlArgs()
+		require.ErrorContains(t, err, "Invalid cache ref=registry.local/cache")
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+const (
+	certificatesDir string = "/etc/bunny/certs"
+)
+
+// The shell function that adds the extra CA certificates to the system
+// bundle of the tool image and points the usual environment variables to
+// the new bundle.
+const certificatesFunction = `setup_certificates() {
+	if [ -z "$(ls -A ` + certificatesDir + ` 2>/dev/null)" ]; then
+		return 0
+	fi
+	bundle=/tmp/bunny-ca-certificates.crt
+	: > "$bundle"
+	for system in /etc/ssl/certs/ca-certificates.crt /etc/pki/tls/certs/ca-bundle.crt /etc/ssl/cert.pem; do
+		if [ -f "$system" ]; then
+			cat "$system" >> "$bundle"
+			break
+		fi
+	done
+	for cert in ` + certificatesDir + `/*; do
+		cat "$cert" >> "$bundle"
+		echo >> "$bundle"
+	done
+	export SSL_CERT_FILE="$bundle" CURL_CA_BUNDLE="$bundle" GIT_SSL_CAINFO="$bundle"
+}
+`
+
+// CertificatesLLB creates a LLB State that contains the given certificates of
+// the build context in its root directory.
+func CertificatesLLB(certs []string, buildContext string) llb.State {
+	local := llb.Local(buildContext, llb.IncludePatterns(certs),
+		llb.WithCustomName("Internal:Read CA certificates"))
+	st := llb.Scratch()
+	for i, cert := range certs {
+		// Keep the order of the certificates and avoid name clashes
+		st = CopyLLB(st, PackCopies{
+			SrcState: local,
+			SrcPath:  cert,
+			DstPath:  fmt.Sprintf("/%02d-%s", i, path.Base(cert)),
+		})
+	}
+
+	return st
+}
+
+// certificateOptions returns the options that mount the certificates in an
+// exec operation, which uses the certificates function.
+func certificateOptions(certs []string, buildContext string) []llb.RunOption {
+	if len(certs) == 0 {
+		return nil
+	}
+
+	return []llb.RunOption{
+		llb.AddMount(certificatesDir, CertificatesLLB(certs, buildContext), llb.Readonly),
+	}
+}
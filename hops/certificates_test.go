@@ -0,0 +1,73 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificatesLLB(t *testing.T) {
+	st := CertificatesLLB([]string{"certs/corp.crt", "other/corp.crt"}, "context")
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+
+	var dests []string
+	for _, op := range arr {
+		if file := op.GetFile(); file != nil {
+			for _, action := range file.Actions {
+				if cp := action.GetCopy(); cp != nil {
+					dests = append(dests, cp.Dest)
+				}
+			}
+		}
+		if src := op.GetSource(); src != nil {
+			require.Equal(t, "local://context", src.Identifier)
+			require.Contains(t, src.Attrs["local.includepattern"], "certs/corp.crt")
+		}
+	}
+	require.Equal(t, []string{"/00-corp.crt", "/01-corp.crt"}, dests)
+}
+
+func TestCertificatesKraftBuild(t *testing.T) {
+	t.Run("No certificates", func(t *testing.T) {
+		require.Nil(t, certificateOptions(nil, "context"))
+		unikraft := &UnikraftInfo{Arch: "x86_64"}
+		state, err := unikraft.BuildKernel(context.TODO(), BuildInput{
+			BuildContext: "context",
+			Monitor:      "qemu",
+			Kernel:       Kernel{From: KernelFromBuild, Source: "app"},
+		})
+		require.NoError(t, err)
+		_, mounts, _ := kraftExec(t, state)
+		require.NotContains(t, mounts, "/etc/bunny/certs")
+	})
+	t.Run("With certificates", func(t *testing.T) {
+		unikraft := &UnikraftInfo{Arch: "x86_64"}
+		state, err := unikraft.BuildKernel(context.TODO(), BuildInput{
+			BuildContext: "context",
+			Monitor:      "qemu",
+			Kernel:       Kernel{From: KernelFromBuild, Source: "app"},
+			Certificates: []string{"certs/corp.crt"},
+		})
+		require.NoError(t, err)
+		_, mounts, _ := kraftExec(t, state)
+		require.Contains(t, mounts, "/etc/bunny/certs")
+		require.True(t, mounts["/etc/bunny/certs"].Readonly)
+	})
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/gateway/client"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// Fail the build on incompatible changes
+	CompareModeFail string = "fail"
+	// Only print the incompatible changes
+	CompareModeWarn string = "warn"
+)
+
+// compareAnnotations is the list of urunc annotations that can not change
+// between two images of the same tag, without changing how the unikernel
+// runs. The paths, the command line and the version can change freely.
+var compareAnnotations = []string{
+	"com.urunc.unikernel.unikernelType",
+	"com.urunc.unikernel.hypervisor",
+	"com.urunc.unikernel.mountRootfs",
+	"com.urunc.unikernel.blkMntPoint",
+}
+
+// ParseCompareMode checks that the given mode of handling incompatible
+// changes is supported. An empty mode means the fail mode.
+func ParseCompareMode(mode string) (string, error) {
+	switch mode {
+	case "", CompareModeFail:
+		return CompareModeFail, nil
+	case CompareModeWarn:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Unknown compare mode %s, expected one of %s, %s",
+			mode, CompareModeFail, CompareModeWarn)
+	}
+}
+
+// rootfsKind returns how urunc gets the rootfs of the unikernel, based on
+// the annotations.
+func rootfsKind(annots map[string]string) string {
+	switch {
+	case annots["com.urunc.unikernel.initrd"] != "":
+		return "initrd"
+	case annots["com.urunc.unikernel.block"] != "":
+		return "block"
+	default:
+		return "none"
+	}
+}
+
+// CompareImages returns the incompatible changes between the previous image
+// and the annotations and the config of the new one. The annotations of the
+// previous image are read from its labels, where bunny stores them too.
+func CompareImages(prev ocispecs.Image, annots map[string]string, img ocispecs.Image) ([]string, error) {
+	prevAnnots := map[string]string{}
+	for k, v := range prev.Config.Labels {
+		if strings.HasPrefix(k, uruncAnnotPrefix) {
+			prevAnnots[k] = v
+		}
+	}
+	if len(prevAnnots) == 0 {
+		return nil, fmt.Errorf("The previous image has no urunc labels to compare with")
+	}
+	err := NormalizeAnnotations(prevAnnots)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid urunc labels in the previous image: %v", err)
+	}
+
+	var changes []string
+	if prev.Architecture != "" && img.Architecture != "" && prev.Architecture != img.Architecture {
+		changes = append(changes, fmt.Sprintf("architecture changed from %s to %s", prev.Architecture, img.Architecture))
+	}
+	for _, k := range compareAnnotations {
+		if prevAnnots[k] != annots[k] {
+			changes = append(changes, fmt.Sprintf("%s changed from %q to %q", k, prevAnnots[k], annots[k]))
+		}
+	}
+	if prevKind, kind := rootfsKind(prevAnnots), rootfsKind(annots); prevKind != kind {
+		changes = append(changes, fmt.Sprintf("rootfs changed from %s to %s", prevKind, kind))
+	}
+
+	return changes, nil
+}
+
+// CompareWithImage fetches the config of the given image, e.g. the previous
+// image of the tag that the build produces, and returns the incompatible
+// changes of the image that the instructions describe.
+func CompareWithImage(ctx context.Context, c client.Client, instr PackInstructions, ref string) ([]string, error) {
+	prev, err := instr.Sources.imageConfig(ctx, c, ref, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get OCI config of %s: %w", ref, err)
+	}
+
+	return CompareImages(prev, instr.Annots, instr.Img)
+}
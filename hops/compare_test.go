@@ -0,0 +1,114 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareParseMode(t *testing.T) {
+	mode, err := ParseCompareMode("")
+	require.NoError(t, err)
+	require.Equal(t, CompareModeFail, mode)
+	mode, err = ParseCompareMode("warn")
+	require.NoError(t, err)
+	require.Equal(t, CompareModeWarn, mode)
+	_, err = ParseCompareMode("ignore")
+	require.ErrorContains(t, err, "Unknown compare mode ignore")
+}
+
+// comparedImage returns an image with the given architecture and labels
+func comparedImage(arch string, labels map[string]string) ocispecs.Image {
+	var img ocispecs.Image
+	img.Architecture = arch
+	img.Config.Labels = labels
+
+	return img
+}
+
+func TestCompareImages(t *testing.T) {
+	prevLabels := map[string]string{
+		"com.urunc.unikernel.unikernelType":    "unikraft",
+		"com.urunc.unikernel.unikernelVersion": "0.17.0",
+		"com.urunc.unikernel.hypervisor":       "qemu",
+		"com.urunc.unikernel.binary":           "/unikraft/bin/kernel",
+		"com.urunc.unikernel.cmdline":          "nginx",
+		"com.urunc.unikernel.initrd":           "/rootfs.cpio",
+		"com.urunc.unikernel.useDMBlock":       "False",
+	}
+	prev := comparedImage("amd64", prevLabels)
+	t.Run("Compatible changes", func(t *testing.T) {
+		annots := map[string]string{
+			"com.urunc.unikernel.unikernelType":    "unikraft",
+			"com.urunc.unikernel.unikernelVersion": "0.18.0",
+			"com.urunc.unikernel.hypervisor":       "qemu",
+			"com.urunc.unikernel.binary":           "/.boot/kernel",
+			"com.urunc.unikernel.cmdline":          "nginx -c /nginx.conf",
+			"com.urunc.unikernel.initrd":           "/.boot/rootfs",
+			"com.urunc.unikernel.mountRootfs":      "false",
+		}
+		changes, err := CompareImages(prev, annots, comparedImage("amd64", nil))
+		require.NoError(t, err)
+		require.Empty(t, changes)
+	})
+	t.Run("Incompatible changes", func(t *testing.T) {
+		annots := map[string]string{
+			"com.urunc.unikernel.unikernelType": "unikraft",
+			"com.urunc.unikernel.hypervisor":    "firecracker",
+			"com.urunc.unikernel.binary":        "/unikraft/bin/kernel",
+			"com.urunc.unikernel.mountRootfs":   "false",
+		}
+		changes, err := CompareImages(prev, annots, comparedImage("arm64", nil))
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"architecture changed from amd64 to arm64",
+			`com.urunc.unikernel.hypervisor changed from "qemu" to "firecracker"`,
+			"rootfs changed from initrd to none",
+		}, changes)
+	})
+	t.Run("Invalid previous image without labels", func(t *testing.T) {
+		_, err := CompareImages(comparedImage("amd64", map[string]string{"foo": "bar"}), nil, ocispecs.Image{})
+		require.ErrorContains(t, err, "The previous image has no urunc labels to compare with")
+	})
+}
+
+func TestCompareWithImage(t *testing.T) {
+	config, err := json.Marshal(comparedImage("amd64", map[string]string{
+		"com.urunc.unikernel.unikernelType": "linux",
+		"com.urunc.unikernel.hypervisor":    "qemu",
+		"com.urunc.unikernel.mountRootfs":   "true",
+	}))
+	require.NoError(t, err)
+	instr := PackInstructions{
+		Annots: map[string]string{
+			"com.urunc.unikernel.unikernelType": "linux",
+			"com.urunc.unikernel.hypervisor":    "qemu",
+			"com.urunc.unikernel.mountRootfs":   "false",
+		},
+		Sources: SourceOpts{
+			Layouts: LayoutImages{
+				"docker.io/library/app:latest": LayoutImage{Config: config},
+			},
+		},
+	}
+	changes, err := CompareWithImage(context.TODO(), nil, instr, "app")
+	require.NoError(t, err)
+	require.Equal(t, []string{`com.urunc.unikernel.mountRootfs changed from "true" to "false"`}, changes)
+}
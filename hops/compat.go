@@ -0,0 +1,179 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-version"
+)
+
+// oldestUruncVersion is the oldest release of urunc that images can target
+const oldestUruncVersion string = "0.3.0"
+
+const (
+	// Fail the build on annotations that the targeted urunc does not
+	// understand
+	UruncCompatModeFail string = "fail"
+	// Only print the annotations that the targeted urunc does not understand
+	UruncCompatModeWarn string = "warn"
+)
+
+// Target describes where the image runs, for images that should run on
+// older releases of urunc
+type Target struct {
+	// The release of urunc that runs the image, e.g. v0.5.0. Empty means
+	// the latest one.
+	UruncVersion string `yaml:"uruncVersion"`
+}
+
+// uruncChange is the release of urunc that introduced an annotation
+type uruncChange struct {
+	// The first release of urunc that understands the annotation. Empty
+	// means that no release of urunc understands it yet.
+	since string
+	// The name of the annotation in the releases before since, if any
+	before string
+}
+
+// uruncCompat is the compatibility table of the annotations that the
+// runtime of the image should understand. It holds the annotations that
+// urunc defines, with the release that introduced them or oldestUruncVersion
+// if every release that images can target understands them, and the
+// annotations of bunny that urunc does not read, which no release
+// understands. The kernels per monitor are under the key of their prefix.
+// Any other annotation, e.g. the build information, is not for the runtime.
+var uruncCompat = map[string]uruncChange{
+	"com.urunc.unikernel.unikernelType":    {since: oldestUruncVersion},
+	"com.urunc.unikernel.unikernelVersion": {since: oldestUruncVersion},
+	"com.urunc.unikernel.hypervisor":       {since: oldestUruncVersion},
+	"com.urunc.unikernel.binary":           {since: oldestUruncVersion},
+	"com.urunc.unikernel.cmdline":          {since: oldestUruncVersion},
+	"com.urunc.unikernel.initrd":           {since: oldestUruncVersion},
+	"com.urunc.unikernel.block":            {since: oldestUruncVersion},
+	"com.urunc.unikernel.blkMntPoint":      {since: oldestUruncVersion},
+	"com.urunc.unikernel.mountRootfs":      {since: "0.5.0", before: "com.urunc.unikernel.useDMBlock"},
+	DtbAnnotation:                          {},
+	KernelVariantAnnotPrefix:               {},
+	ShutdownAnnotation:                     {},
+	StopSignalAnnotation:                   {},
+}
+
+// ParseUruncCompatMode checks that the given mode of handling annotations
+// that the targeted urunc does not understand is supported. An empty mode
+// means the fail mode.
+func ParseUruncCompatMode(mode string) (string, error) {
+	switch mode {
+	case "", UruncCompatModeFail:
+		return UruncCompatModeFail, nil
+	case UruncCompatModeWarn:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Unknown urunc compat mode %s, expected one of %s, %s",
+			mode, UruncCompatModeFail, UruncCompatModeWarn)
+	}
+}
+
+// parseUruncVersion parses a release of urunc, with or without the v prefix,
+// and checks that images can target it
+func parseUruncVersion(v string) (*version.Version, error) {
+	ver, err := version.NewVersion(v)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid version %q of urunc: %v", v, err)
+	}
+	if ver.LessThan(version.Must(version.NewVersion(oldestUruncVersion))) {
+		return nil, fmt.Errorf("urunc %s is older than the oldest supported release v%s", v, oldestUruncVersion)
+	}
+
+	return ver, nil
+}
+
+// uruncChangeOf returns the entry of the compatibility table for the given
+// annotation, if any
+func uruncChangeOf(annot string) (uruncChange, bool) {
+	if isKernelVariantAnnotation(annot) {
+		return uruncCompat[KernelVariantAnnotPrefix], true
+	}
+	c, ok := uruncCompat[annot]
+
+	return c, ok
+}
+
+// isLegacyUruncAnnotation returns true, if the key is the name of an
+// annotation in older releases of urunc
+func isLegacyUruncAnnotation(key string) bool {
+	for _, c := range uruncCompat {
+		if c.before != "" && c.before == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UruncCompat rewrites the given annotations for the given release of
+// urunc, so the annotations that the release knows under an older name get
+// renamed. It returns the annotations that the release does not understand,
+// sorted, which stay as they are. An empty release leaves the annotations as
+// they are.
+func UruncCompat(annots map[string]string, uruncVersion string) ([]string, error) {
+	if uruncVersion == "" {
+		return nil, nil
+	}
+	ver, err := parseUruncVersion(uruncVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(annots))
+	for k := range annots {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var unsupported []string
+	for _, k := range keys {
+		c, ok := uruncChangeOf(k)
+		switch {
+		case !ok:
+			continue
+		case c.since != "" && !ver.LessThan(version.Must(version.NewVersion(c.since))):
+			continue
+		case c.before != "":
+			annots[c.before] = annots[k]
+			delete(annots, k)
+		default:
+			unsupported = append(unsupported, k)
+		}
+	}
+
+	return unsupported, nil
+}
+
+// ApplyUruncVersion rewrites the annotations and labels of the image for the
+// release of urunc that the bunnyfile targets, if any (see UruncCompat). It
+// returns the annotations that the release does not understand.
+func (i *PackInstructions) ApplyUruncVersion() ([]string, error) {
+	unsupported, err := UruncCompat(i.Annots, i.UruncVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// The labels hold the same annotations, so they can not fail
+	_, _ = UruncCompat(i.Img.Config.Labels, i.UruncVersion)
+
+	return unsupported, nil
+}
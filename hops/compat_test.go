@@ -0,0 +1,147 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompatUrunc(t *testing.T) {
+	annots := func() map[string]string {
+		return map[string]string{
+			"com.urunc.unikernel.hypervisor":  "qemu",
+			"com.urunc.unikernel.mountRootfs": "true",
+			"foo":                             "bar",
+		}
+	}
+
+	t.Run("Latest", func(t *testing.T) {
+		for _, v := range []string{"", "v0.5.0", "0.7.1"} {
+			a := annots()
+			unsupported, err := UruncCompat(a, v)
+			require.NoError(t, err)
+			require.Empty(t, unsupported)
+			require.Equal(t, annots(), a)
+		}
+	})
+	t.Run("Renamed", func(t *testing.T) {
+		a := annots()
+		unsupported, err := UruncCompat(a, "v0.4.2")
+		require.NoError(t, err)
+		require.Empty(t, unsupported)
+		require.NotContains(t, a, "com.urunc.unikernel.mountRootfs")
+		require.Equal(t, "true", a["com.urunc.unikernel.useDMBlock"])
+		// The annotations that are not for the runtime stay as they are
+		require.Equal(t, "bar", a["foo"])
+
+		// The older name still reaches urunc.json
+		uruncJSON, err := UruncJSON(PackInstructions{Annots: a})
+		require.NoError(t, err)
+		var content map[string]string
+		require.NoError(t, json.Unmarshal(uruncJSON, &content))
+		require.Equal(t, base64.StdEncoding.EncodeToString([]byte("true")), content["com.urunc.unikernel.useDMBlock"])
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		for _, v := range []string{"v0.3.0", "v0.5.0", "0.7.1"} {
+			a := annots()
+			a[DtbAnnotation] = DefaultDtbPath
+			a[KernelVariantAnnotPrefix+"firecracker"] = "/.boot/kernel-firecracker"
+			a[ShutdownAnnotation] = ShutdownACPI
+			unsupported, err := UruncCompat(a, v)
+			require.NoError(t, err)
+			require.Equal(t, []string{KernelVariantAnnotPrefix + "firecracker", DtbAnnotation, ShutdownAnnotation}, unsupported, v)
+			// They stay in the image, e.g. for bunny run
+			require.Equal(t, DefaultDtbPath, a[DtbAnnotation])
+		}
+		// Without a target, the latest urunc is not checked
+		a := annots()
+		a[DtbAnnotation] = DefaultDtbPath
+		unsupported, err := UruncCompat(a, "")
+		require.NoError(t, err)
+		require.Empty(t, unsupported)
+	})
+	t.Run("Invalid version", func(t *testing.T) {
+		_, err := UruncCompat(annots(), "v0.2.0")
+		require.ErrorContains(t, err, "urunc v0.2.0 is older than the oldest supported release v0.3.0")
+		_, err = UruncCompat(annots(), "latest")
+		require.ErrorContains(t, err, `Invalid version "latest" of urunc`)
+	})
+}
+
+func TestCompatTable(t *testing.T) {
+	// Every annotation that urunc defines has the release that introduced it
+	for _, annot := range uruncAnnotations {
+		c, ok := uruncCompat[annot]
+		require.True(t, ok, annot)
+		require.NotEmpty(t, c.since, annot)
+	}
+	for annot, c := range uruncCompat {
+		if c.since != "" {
+			_, err := parseUruncVersion(c.since)
+			require.NoError(t, err, annot)
+		}
+	}
+}
+
+func TestCompatMode(t *testing.T) {
+	for mode, expected := range map[string]string{
+		"":                  UruncCompatModeFail,
+		UruncCompatModeFail: UruncCompatModeFail,
+		UruncCompatModeWarn: UruncCompatModeWarn,
+	} {
+		m, err := ParseUruncCompatMode(mode)
+		require.NoError(t, err)
+		require.Equal(t, expected, m)
+	}
+	_, err := ParseUruncCompatMode("ignore")
+	require.ErrorContains(t, err, "Unknown urunc compat mode ignore")
+}
+
+func TestCompatBunnyfile(t *testing.T) {
+	file := metadataBunnyfile + "target:\n  uruncVersion: v0.4.0\n"
+	h, err := ParseBunnyfile([]byte(file))
+	require.NoError(t, err)
+	require.Equal(t, "v0.4.0", h.Target.UruncVersion)
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Contains(t, i.Annots, "com.urunc.unikernel.mountRootfs")
+	i.Img.Config.Labels = map[string]string{"com.urunc.unikernel.mountRootfs": "false"}
+
+	unsupported, err := i.ApplyUruncVersion()
+	require.NoError(t, err)
+	require.Empty(t, unsupported)
+	require.NotContains(t, i.Annots, "com.urunc.unikernel.mountRootfs")
+	require.Contains(t, i.Annots, "com.urunc.unikernel.useDMBlock")
+	require.Equal(t, map[string]string{"com.urunc.unikernel.useDMBlock": "false"}, i.Img.Config.Labels)
+
+	t.Run("Shutdown", func(t *testing.T) {
+		h, err := ParseBunnyfile([]byte(file + "shutdown:\n  method: acpi\n"))
+		require.NoError(t, err)
+		i, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		unsupported, err := i.ApplyUruncVersion()
+		require.NoError(t, err)
+		require.Equal(t, []string{ShutdownAnnotation}, unsupported)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte(metadataBunnyfile + "target:\n  uruncVersion: v0.1.0\n"))
+		require.ErrorContains(t, err, "The uruncVersion field of target is invalid")
+	})
+}
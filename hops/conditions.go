@@ -0,0 +1,195 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The key of the condition of an entry in a list of the bunnyfile
+	conditionKey string = "when"
+	// The key of the value of a conditional entry that is not a mapping,
+	// e.g. a string of cmd or of the config of the kernel
+	conditionValueKey string = "value"
+)
+
+// expandBuildArgs replaces every ${NAME} in s with the value of the build
+// argument NAME, or with an empty string if it is not set.
+func expandBuildArgs(s string, args map[string]string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			b.WriteString(s)
+			break
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", s)
+		}
+		name := s[start+2 : start+end]
+		if name == "" || strings.ContainsAny(name, " \t${") {
+			return "", fmt.Errorf("invalid build argument name %q", name)
+		}
+		b.WriteString(s[:start])
+		b.WriteString(args[name])
+		s = s[start+end+1:]
+	}
+
+	return b.String(), nil
+}
+
+// conditionOperand returns the value of an operand of a condition, without
+// the surrounding spaces and quotes.
+func conditionOperand(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// EvalCondition evaluates the condition of an entry with the given build
+// arguments. A condition compares two operands with == or !=, e.g.
+// "${DEBUG} == 1", or checks a single operand, e.g. "${DEBUG}", which is true
+// if it is neither empty nor false (e.g. 0, false, no). A leading ! negates
+// the condition. Build arguments that are not set are empty.
+func EvalCondition(cond string, args map[string]string) (bool, error) {
+	expr := strings.TrimSpace(cond)
+	negate := false
+	if rest, ok := strings.CutPrefix(expr, "!"); ok && !strings.HasPrefix(rest, "=") {
+		negate = true
+		expr = strings.TrimSpace(rest)
+	}
+	if expr == "" {
+		return false, fmt.Errorf("Invalid condition %q: the condition is empty", cond)
+	}
+	expanded, err := expandBuildArgs(expr, args)
+	if err != nil {
+		return false, fmt.Errorf("Invalid condition %q: %v", cond, err)
+	}
+
+	var result bool
+	if lhs, rhs, ok := strings.Cut(expanded, "!="); ok {
+		result = conditionOperand(lhs) != conditionOperand(rhs)
+	} else if lhs, rhs, ok := strings.Cut(expanded, "=="); ok {
+		result = conditionOperand(lhs) == conditionOperand(rhs)
+	} else if strings.Contains(expanded, "=") {
+		return false, fmt.Errorf("Invalid condition %q: expected == or !=", cond)
+	} else {
+		value := conditionOperand(expanded)
+		switch strings.ToLower(value) {
+		case "", "no", "off":
+			result = false
+		default:
+			b, err := strconv.ParseBool(value)
+			result = err != nil || b
+		}
+	}
+
+	return result != negate, nil
+}
+
+// conditionOf returns the index of the key of the condition in the given
+// mapping, or -1 if it has no condition.
+func conditionOf(node *yaml.Node) int {
+	if node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for j := 0; j+1 < len(node.Content); j += 2 {
+		if node.Content[j].Value == conditionKey {
+			return j
+		}
+	}
+
+	return -1
+}
+
+// applyConditions removes the entries of the lists of a bunnyfile with a
+// false condition and the conditions of the rest of them. An entry with
+// only a value and a condition becomes its value, so entries that are not
+// mappings (e.g. strings) can have a condition too. Conditions are only
+// supported in the entries of lists.
+func applyConditions(node *yaml.Node, args map[string]string) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			err := applyConditions(n, args)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		if j := conditionOf(node); j >= 0 {
+			return fmt.Errorf("The %s field at line %d is only supported in the entries of lists", conditionKey, node.Content[j].Line)
+		}
+		for j := 1; j < len(node.Content); j += 2 {
+			err := applyConditions(node.Content[j], args)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		entries := make([]*yaml.Node, 0, len(node.Content))
+		for _, entry := range node.Content {
+			conditional := conditionOf(entry) >= 0
+			keep, err := applyEntryCondition(entry, args)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue
+			}
+			if conditional && len(entry.Content) == 2 && entry.Content[0].Value == conditionValueKey {
+				entry = entry.Content[1]
+			}
+			err = applyConditions(entry, args)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		node.Content = entries
+	}
+
+	return nil
+}
+
+// applyEntryCondition evaluates the condition of an entry of a list, if any,
+// and removes it from the entry. It returns false, if the entry should be
+// removed from the list.
+func applyEntryCondition(entry *yaml.Node, args map[string]string) (bool, error) {
+	j := conditionOf(entry)
+	if j < 0 {
+		return true, nil
+	}
+	cond := entry.Content[j+1]
+	if cond.Kind != yaml.ScalarNode {
+		return false, fmt.Errorf("The %s field at line %d should be a string", conditionKey, cond.Line)
+	}
+	keep, err := EvalCondition(cond.Value, args)
+	if err != nil {
+		return false, fmt.Errorf("line %d: %v", cond.Line, err)
+	}
+	entry.Content = append(entry.Content[:j], entry.Content[j+2:]...)
+
+	return keep, nil
+}
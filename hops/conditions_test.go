@@ -0,0 +1,154 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const conditionsBunnyfile = `version: v0.1
+platforms:
+  framework: unikraft
+  monitor: qemu
+kernel:
+  from: build
+  source: app
+  config:
+    - CONFIG_LIBUKBOOT=y
+    - value: CONFIG_LIBUKDEBUG_PRINTK_INFO=y
+      when: "${DEBUG} == 1"
+rootfs:
+  from: scratch
+  include:
+    - from: local
+      source: app
+      destination: /app
+    - from: local
+      source: app.debug
+      destination: /app.debug
+      when: ${DEBUG}
+    - from: local
+      source: app.release
+      destination: /app.release
+      when: "!${DEBUG}"
+cmd: ["/app"]
+`
+
+func TestConditionsEval(t *testing.T) {
+	args := map[string]string{"DEBUG": "1", "MODE": "release", "OFF": "false"}
+	tests := []struct {
+		cond   string
+		result bool
+	}{
+		{"${DEBUG} == 1", true},
+		{"${DEBUG} != 1", false},
+		{`${MODE} == "release"`, true},
+		{"${MODE} == 'debug'", false},
+		{"${DEBUG}", true},
+		{"${MODE}", true},
+		{"${OFF}", false},
+		{"${UNSET}", false},
+		{"!${UNSET}", true},
+		{"${UNSET} == ''", true},
+		{"! ${DEBUG} == 1", false},
+		{"${MODE}-${DEBUG} == release-1", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.cond, func(t *testing.T) {
+			result, err := EvalCondition(tc.cond, args)
+			require.NoError(t, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+
+	for cond, errorText := range map[string]string{
+		"":             "the condition is empty",
+		"!":            "the condition is empty",
+		"${DEBUG} = 1": "expected == or !=",
+		"${DEBUG == 1": "unterminated ${",
+		"${} == 1":     "invalid build argument name",
+		"${A B} == 1":  "invalid build argument name",
+	} {
+		_, err := EvalCondition(cond, args)
+		require.ErrorContains(t, err, errorText, cond)
+	}
+}
+
+func TestConditionsParse(t *testing.T) {
+	t.Run("Without build arguments", func(t *testing.T) {
+		h, err := ParseBunnyfile([]byte(conditionsBunnyfile))
+		require.NoError(t, err)
+		require.Equal(t, []string{"CONFIG_LIBUKBOOT=y"}, h.Kernel.Config)
+		require.Equal(t, []FileToInclude{
+			{From: "local", Src: "app", Dst: "/app"},
+			{From: "local", Src: "app.release", Dst: "/app.release"},
+		}, h.Rootfs.Includes)
+	})
+	t.Run("Debug", func(t *testing.T) {
+		h, err := ParseBunnyfileArgs([]byte(conditionsBunnyfile), map[string]string{"DEBUG": "1"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"CONFIG_LIBUKBOOT=y", "CONFIG_LIBUKDEBUG_PRINTK_INFO=y"}, h.Kernel.Config)
+		require.Equal(t, []FileToInclude{
+			{From: "local", Src: "app", Dst: "/app"},
+			{From: "local", Src: "app.debug", Dst: "/app.debug"},
+		}, h.Rootfs.Includes)
+	})
+	t.Run("JSON", func(t *testing.T) {
+		h, err := ParseBunnyfileArgs([]byte(`{"version": "v0.1",
+"platforms": {"framework": "linux", "monitor": "qemu"},
+"kernel": {"from": "local", "path": "kernel"},
+"cmd": ["/init", {"value": "--verbose", "when": "${DEBUG}"}]}`), map[string]string{"DEBUG": "yes"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"/init", "--verbose"}, h.Cmd)
+	})
+	t.Run("Invalid condition outside of a list", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte("version: v0.1\nrootfs:\n  from: scratch\n  when: ${DEBUG}\n"))
+		require.ErrorContains(t, err, "The when field at line 4 is only supported in the entries of lists")
+	})
+	t.Run("Invalid condition", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte("version: v0.1\ncmd:\n  - value: app\n    when: ${DEBUG} = 1\n"))
+		require.ErrorContains(t, err, `line 4: Invalid condition "${DEBUG} = 1": expected == or !=`)
+	})
+	t.Run("Invalid condition that is not a string", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte("version: v0.1\ncmd:\n  - value: app\n    when: [a]\n"))
+		require.ErrorContains(t, err, "The when field at line 4 should be a string")
+	})
+}
+
+func TestConditionsParseFile(t *testing.T) {
+	file := []byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+cmd:
+  - /init
+  - value: debug
+    when: ${DEBUG} == 1
+`)
+	instr, err := ParseFile(context.TODO(), file, "context", nil, SourceOpts{})
+	require.NoError(t, err)
+	require.Equal(t, "/init", instr.Annots["com.urunc.unikernel.cmdline"])
+
+	instr, err = ParseFile(context.TODO(), file, "context", nil,
+		SourceOpts{BuildArgs: map[string]string{"DEBUG": "1"}})
+	require.NoError(t, err)
+	require.Equal(t, "/init debug", instr.Annots["com.urunc.unikernel.cmdline"])
+}
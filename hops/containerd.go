@@ -0,0 +1,88 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// The namespace of containerd that Kubernetes uses
+	DefaultContainerdNamespace string = "k8s.io"
+	// The type of the output that imports the image in containerd
+	containerdOutputType string = "containerd"
+)
+
+// ContainerdOutput defines where a local build imports the image in the
+// image store of containerd, without pushing it to a registry
+type ContainerdOutput struct {
+	// The name of the image
+	Name string
+	// The namespace of containerd
+	Namespace string
+	// The address of the socket of containerd, empty for the default one
+	Address string
+}
+
+// ParseContainerdOutput parses an output of the form
+// containerd[,name=<image>][,namespace=<namespace>][,address=<socket>] (or
+// type=containerd,...). It returns nil if the output is not for containerd.
+func ParseContainerdOutput(output string) (*ContainerdOutput, error) {
+	fields := strings.Split(output, ",")
+	if fields[0] != containerdOutputType && fields[0] != "type="+containerdOutputType {
+		return nil, nil
+	}
+
+	o := ContainerdOutput{Namespace: DefaultContainerdNamespace}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("Invalid field %s of containerd output, expected key=value", field)
+		}
+		switch key {
+		case "name":
+			o.Name = value
+		case "namespace":
+			o.Namespace = value
+		case "address":
+			o.Address = value
+		default:
+			return nil, fmt.Errorf("Unknown field %s of containerd output, expected one of name, namespace, address", key)
+		}
+	}
+	if o.Name == "" {
+		return nil, fmt.Errorf("The containerd output requires the name of the image")
+	}
+
+	return &o, nil
+}
+
+// BuildctlOutput returns the output of buildctl that writes the image as an
+// OCI tarball in the standard output, for the import in containerd
+func (o ContainerdOutput) BuildctlOutput() string {
+	return "type=oci,name=" + o.Name + ",dest=-"
+}
+
+// CtrArgs returns the arguments of ctr that import the OCI tarball of the
+// standard input in containerd
+func (o ContainerdOutput) CtrArgs() []string {
+	var args []string
+	if o.Address != "" {
+		args = append(args, "--address", o.Address)
+	}
+
+	return append(args, "--namespace", o.Namespace, "images", "import", "-")
+}
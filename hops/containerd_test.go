@@ -0,0 +1,84 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerdOutput(t *testing.T) {
+	tests := []testInfo{
+		{
+			name:        "Valid default namespace",
+			input:       "containerd,name=registry.local/app:v1",
+			expectError: false,
+		},
+		{
+			name:        "Valid with type",
+			input:       "type=containerd,name=app,namespace=default,address=/run/k3s/containerd/containerd.sock",
+			expectError: false,
+		},
+		{
+			name:        "Invalid without name",
+			input:       "containerd",
+			expectError: true,
+			errorText:   "The containerd output requires the name of the image",
+		},
+		{
+			name:        "Invalid field",
+			input:       "containerd,name=app,push",
+			expectError: true,
+			errorText:   "Invalid field push of containerd output, expected key=value",
+		},
+		{
+			name:        "Invalid unknown field",
+			input:       "containerd,name=app,push=true",
+			expectError: true,
+			errorText:   "Unknown field push of containerd output",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := ParseContainerdOutput(tc.input)
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, o)
+			}
+		})
+	}
+
+	t.Run("Not containerd", func(t *testing.T) {
+		o, err := ParseContainerdOutput("type=image,name=containerd,push=true")
+		require.NoError(t, err)
+		require.Nil(t, o)
+	})
+	t.Run("Arguments", func(t *testing.T) {
+		o, err := ParseContainerdOutput("containerd,name=registry.local/app:v1")
+		require.NoError(t, err)
+		require.Equal(t, "type=oci,name=registry.local/app:v1,dest=-", o.BuildctlOutput())
+		require.Equal(t, []string{"--namespace", "k8s.io", "images", "import", "-"}, o.CtrArgs())
+
+		o, err = ParseContainerdOutput("type=containerd,name=app,namespace=default,address=/run/k3s/containerd/containerd.sock")
+		require.NoError(t, err)
+		require.Equal(t, []string{"--address", "/run/k3s/containerd/containerd.sock",
+			"--namespace", "default", "images", "import", "-"}, o.CtrArgs())
+	})
+}
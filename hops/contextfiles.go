@@ -0,0 +1,77 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+const (
+	// The default maximum size of a file that bunny reads from the build
+	// context, e.g. the bunnyfile, as for the files of the service
+	DefaultMaxFileSize int64 = serviceMaxFileSize
+)
+
+// ParseMaxFileSize parses the maximum size in bytes of the files that bunny
+// reads from the build context, which is DefaultMaxFileSize if empty.
+func ParseMaxFileSize(value string) (int64, error) {
+	if value == "" {
+		return DefaultMaxFileSize, nil
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("Invalid size %q, expected a positive number of bytes", value)
+	}
+
+	return size, nil
+}
+
+// ReadRefFile reads the given file of the reference, after making sure that
+// it is a regular file of at most maxSize bytes. The file is read in chunks,
+// so a large file does not exceed the size of a single message.
+func ReadRefFile(ctx context.Context, ref client.Reference, name string, maxSize int64) ([]byte, error) {
+	st, err := ref.StatFile(ctx, client.StatRequest{Path: name})
+	if err != nil {
+		return nil, err
+	}
+	if !os.FileMode(st.Mode).IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	if st.Size > maxSize {
+		return nil, fmt.Errorf("%s has %d bytes, more than the maximum of %d bytes", name, st.Size, maxSize)
+	}
+
+	return readRefFile(ctx, ref, name, st.Size)
+}
+
+// ContextPatterns returns the include patterns of a local source with all
+// the given files and directories of the build context, so a single transfer
+// of the context brings all of them.
+func ContextPatterns(paths ...string) []string {
+	var patterns []string
+	for _, p := range paths {
+		if p != "" && !slices.Contains(patterns, p) {
+			patterns = append(patterns, p)
+		}
+	}
+
+	return patterns
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRef counts the reads of the files of a fakeRef
+type countingRef struct {
+	*fakeRef
+	reads int
+}
+
+func (r *countingRef) ReadFile(ctx context.Context, req client.ReadRequest) ([]byte, error) {
+	r.reads++
+	return r.fakeRef.ReadFile(ctx, req)
+}
+
+func TestContextFilesMaxSize(t *testing.T) {
+	size, err := ParseMaxFileSize("")
+	require.NoError(t, err)
+	require.Equal(t, DefaultMaxFileSize, size)
+	size, err = ParseMaxFileSize("4194304")
+	require.NoError(t, err)
+	require.Equal(t, int64(4<<20), size)
+
+	for _, value := range []string{"0", "-1", "1MB", "1.5"} {
+		_, err = ParseMaxFileSize(value)
+		require.ErrorContains(t, err, "expected a positive number of bytes", value)
+	}
+}
+
+func TestContextFilesRead(t *testing.T) {
+	large := bytes.Repeat([]byte("bunny"), 1<<20)
+	ref := &countingRef{fakeRef: &fakeRef{
+		files: map[string][]byte{
+			"bunnyfile":  []byte("version: v0.1\n"),
+			"large.yaml": large,
+		},
+		dirs: map[string]bool{"layouts": true},
+	}}
+
+	content, err := ReadRefFile(context.TODO(), ref, "bunnyfile", DefaultMaxFileSize)
+	require.NoError(t, err)
+	require.Equal(t, "version: v0.1\n", string(content))
+
+	// Files larger than a message get read in chunks
+	ref.reads = 0
+	content, err = ReadRefFile(context.TODO(), ref, "large.yaml", int64(len(large)))
+	require.NoError(t, err)
+	require.Equal(t, large, content)
+	require.Equal(t, 5, ref.reads)
+
+	t.Run("Invalid", func(t *testing.T) {
+		ref.reads = 0
+		_, err := ReadRefFile(context.TODO(), ref, "large.yaml", DefaultMaxFileSize)
+		require.ErrorContains(t, err, "large.yaml has 5242880 bytes, more than the maximum of 1048576 bytes")
+		require.Zero(t, ref.reads)
+
+		_, err = ReadRefFile(context.TODO(), ref, "layouts", DefaultMaxFileSize)
+		require.ErrorContains(t, err, "layouts is not a regular file")
+		_, err = ReadRefFile(context.TODO(), ref, "missing", DefaultMaxFileSize)
+		require.ErrorContains(t, err, "missing: no such file or directory")
+	})
+}
+
+func TestContextFilesPatterns(t *testing.T) {
+	require.Equal(t, []string{"bunnyfile", "policy.yaml", "layouts/nginx"},
+		ContextPatterns("bunnyfile", "", "policy.yaml", "layouts/nginx", "bunnyfile"))
+	require.Empty(t, ContextPatterns("", ""))
+}
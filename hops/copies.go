@@ -0,0 +1,152 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// cleanCopyPath cleans the given path of a copy, keeping it relative if it
+// was relative. It returns an error, if the path is empty or it escapes the
+// root of its state through "..".
+func cleanCopyPath(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("Empty path")
+	}
+	depth := 0
+	for _, elem := range strings.Split(p, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			depth--
+			if depth < 0 {
+				return "", fmt.Errorf("Path %s escapes the root", p)
+			}
+		default:
+			depth++
+		}
+	}
+
+	return path.Clean(p), nil
+}
+
+// stateKey returns a digest that identifies the output of the given state.
+// Two states with the same operations have the same key. Buildkit marks each
+// marshaling of a local source as unique, so a fixed mark is used instead.
+func stateKey(st llb.State) (digest.Digest, error) {
+	def, err := st.Marshal(context.TODO(), llb.LocalUniqueID("bunny"))
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal LLB state: %v", err)
+	}
+	if len(def.Def) == 0 {
+		// Scratch
+		return "", nil
+	}
+
+	// The last operation just points to the output of the state
+	return digest.FromBytes(def.Def[len(def.Def)-1]), nil
+}
+
+// sameCopySource reports whether the two copies copy the same file of the
+// same state.
+func sameCopySource(a PackCopies, b PackCopies) (bool, error) {
+	if a.SrcPath != b.SrcPath {
+		return false, nil
+	}
+	aKey, err := stateKey(a.SrcState)
+	if err != nil {
+		return false, err
+	}
+	bKey, err := stateKey(b.SrcState)
+	if err != nil {
+		return false, err
+	}
+
+	return aKey == bKey, nil
+}
+
+// NormalizeCopies cleans the paths of the copies of the final image and
+// removes any duplicate copies. It returns an error if a path escapes the
+// root of its state, or if two copies, including the ones of the flavors,
+// write different files to the same destination.
+func (i *PackInstructions) NormalizeCopies() error {
+	dsts := map[string]PackCopies{}
+
+	copies := make([]PackCopies, 0, len(i.Copies))
+	for _, c := range i.Copies {
+		var err error
+		c.SrcPath, err = cleanCopyPath(c.SrcPath)
+		if err != nil {
+			return fmt.Errorf("Invalid source of copy to %s: %v", c.DstPath, err)
+		}
+		c.DstPath, err = cleanCopyPath(c.DstPath)
+		if err != nil {
+			return fmt.Errorf("Invalid destination of copy from %s: %v", c.SrcPath, err)
+		}
+		c.DstPath = path.Join("/", c.DstPath)
+		if prev, ok := dsts[c.DstPath]; ok {
+			// Marshaling the states is slow for large ones (e.g. a rootfs
+			// of many files), so only the copies to the same destination
+			// get compared
+			same, err := sameCopySource(prev, c)
+			if err != nil {
+				return err
+			}
+			if !same {
+				return fmt.Errorf("Conflicting copies to %s", c.DstPath)
+			}
+			continue
+		}
+		dsts[c.DstPath] = c
+		copies = append(copies, c)
+	}
+	i.Copies = copies
+
+	// The copies of flavors copy files of the final image itself
+	flavorCopies := make([]PackCopies, 0, len(i.FlavorCopies))
+	flavorDsts := map[string]string{}
+	for _, c := range i.FlavorCopies {
+		var err error
+		c.SrcPath, err = cleanCopyPath(c.SrcPath)
+		if err != nil {
+			return fmt.Errorf("Invalid source of copy to %s: %v", c.DstPath, err)
+		}
+		c.DstPath, err = cleanCopyPath(c.DstPath)
+		if err != nil {
+			return fmt.Errorf("Invalid destination of copy from %s: %v", c.SrcPath, err)
+		}
+		c.DstPath = path.Join("/", c.DstPath)
+		if _, ok := dsts[c.DstPath]; ok {
+			return fmt.Errorf("Conflicting copies to %s", c.DstPath)
+		}
+		if src, ok := flavorDsts[c.DstPath]; ok {
+			if src != c.SrcPath {
+				return fmt.Errorf("Conflicting copies to %s", c.DstPath)
+			}
+			continue
+		}
+		flavorDsts[c.DstPath] = c.SrcPath
+		flavorCopies = append(flavorCopies, c)
+	}
+	i.FlavorCopies = flavorCopies
+
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopiesCleanPath(t *testing.T) {
+	tests := []testInfo{
+		{name: "Valid relative", input: "kernel", errorText: "kernel"},
+		{name: "Valid absolute", input: "/.boot//kernel", errorText: "/.boot/kernel"},
+		{name: "Valid dot dot inside", input: "/build/../app/./kernel", errorText: "/app/kernel"},
+		{name: "Invalid escape", input: "/app/../../etc/passwd", expectError: true, errorText: "escapes the root"},
+		{name: "Invalid relative escape", input: "../kernel", expectError: true, errorText: "escapes the root"},
+		{name: "Invalid empty", input: "", expectError: true, errorText: "Empty path"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := cleanCopyPath(tc.input)
+			if tc.expectError {
+				require.ErrorContains(t, err, tc.errorText)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.errorText, p)
+			}
+		})
+	}
+}
+
+func TestCopiesNormalize(t *testing.T) {
+	t.Run("Duplicate copies", func(t *testing.T) {
+		i := &PackInstructions{
+			Copies: []PackCopies{
+				{SrcState: llb.Local("context"), SrcPath: "kernel", DstPath: "/.boot/kernel"},
+				{SrcState: llb.Local("context"), SrcPath: "./kernel", DstPath: ".boot/kernel"},
+				{SrcState: llb.Image("foo"), SrcPath: "/rootfs", DstPath: "/.boot/rootfs"},
+			},
+		}
+		require.NoError(t, i.NormalizeCopies())
+		require.Len(t, i.Copies, 2)
+		require.Equal(t, "kernel", i.Copies[0].SrcPath)
+		require.Equal(t, DefaultKernelPath, i.Copies[0].DstPath)
+		require.Equal(t, DefaultRootfsPath, i.Copies[1].DstPath)
+	})
+	t.Run("Conflicting sources", func(t *testing.T) {
+		i := &PackInstructions{
+			Copies: []PackCopies{
+				{SrcState: llb.Local("context"), SrcPath: "kernel", DstPath: "/.boot/kernel"},
+				{SrcState: llb.Image("foo"), SrcPath: "kernel", DstPath: "/.boot/kernel"},
+			},
+		}
+		require.ErrorContains(t, i.NormalizeCopies(), "Conflicting copies to /.boot/kernel")
+	})
+	t.Run("Conflicting paths", func(t *testing.T) {
+		i := &PackInstructions{
+			Copies: []PackCopies{
+				{SrcState: llb.Local("context"), SrcPath: "kernel", DstPath: "/.boot/kernel"},
+				{SrcState: llb.Local("context"), SrcPath: "other", DstPath: "/.boot/../.boot/kernel"},
+			},
+		}
+		require.ErrorContains(t, i.NormalizeCopies(), "Conflicting copies to /.boot/kernel")
+	})
+	t.Run("Conflicting flavor copies", func(t *testing.T) {
+		i := &PackInstructions{
+			Copies: []PackCopies{
+				{SrcState: llb.Local("context"), SrcPath: "kernel", DstPath: "/.boot/kernel"},
+			},
+			FlavorCopies: []PackCopies{
+				{SrcPath: "/unikraft/bin/kernel", DstPath: "/.boot/kernel"},
+			},
+		}
+		require.ErrorContains(t, i.NormalizeCopies(), "Conflicting copies to /.boot/kernel")
+	})
+	t.Run("Duplicate flavor copies", func(t *testing.T) {
+		i := &PackInstructions{
+			FlavorCopies: []PackCopies{
+				{SrcPath: "/.boot/kernel", DstPath: "/unikraft/bin/kernel"},
+				{SrcPath: "/.boot/kernel", DstPath: "/unikraft/bin/kernel"},
+			},
+		}
+		require.NoError(t, i.NormalizeCopies())
+		require.Len(t, i.FlavorCopies, 1)
+	})
+	t.Run("Invalid traversal", func(t *testing.T) {
+		i := &PackInstructions{
+			Copies: []PackCopies{
+				{SrcState: llb.Local("context"), SrcPath: "../kernel", DstPath: "/.boot/kernel"},
+			},
+		}
+		require.ErrorContains(t, i.NormalizeCopies(), "Invalid source of copy to /.boot/kernel")
+	})
+	t.Run("Invalid kernel path of bunnyfile", func(t *testing.T) {
+		hops := &Hops{
+			Platform: Platform{
+				Framework: "unikraft",
+				Monitor:   "qemu",
+			},
+			Kernel: Kernel{
+				From: "local",
+				Path: "../../kernel",
+			},
+		}
+		_, err := ToPack(context.TODO(), hops, "context")
+		require.ErrorContains(t, err, "Invalid copies in the final image")
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/moby/buildkit/frontend/gateway/client"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// CrashReportMetaKey is the key of the crash report in the metadata of the
+// result. Buildkit returns the metadata with the frontend. prefix to the
+// client, e.g. in the --metadata-file of buildctl.
+const CrashReportMetaKey string = "frontend.bunny.crash-report"
+
+// CrashReport describes a crash of the frontend with what a bug report
+// needs. It is also the error of the build that crashed.
+type CrashReport struct {
+	// The version of bunny
+	Version string `json:"version"`
+	// The digest of the bunnyfile, if the crash happened after reading it
+	File digest.Digest `json:"file,omitempty"`
+	// The names of the options of the frontend, without their values,
+	// which may contain secrets (e.g. in build arguments)
+	Options []string `json:"options,omitempty"`
+	// The value of the panic
+	Panic string `json:"panic"`
+	// The stack of the goroutine that panicked
+	Stack string `json:"stack"`
+}
+
+// NewCrashReport returns the report of a possible crash of a build of the
+// given version of bunny with the given options.
+func NewCrashReport(version string, opts map[string]string) *CrashReport {
+	if version == "" {
+		version = "unknown"
+	}
+
+	return &CrashReport{
+		Version: version,
+		Options: slices.Sorted(maps.Keys(opts)),
+	}
+}
+
+// SetFile records the digest of the bunnyfile of the build
+func (r *CrashReport) SetFile(fileBytes []byte) {
+	r.File = digest.FromBytes(fileBytes)
+}
+
+func (r *CrashReport) Error() string {
+	file := "not read yet"
+	if r.File != "" {
+		file = r.File.String()
+	}
+
+	return fmt.Sprintf("The frontend crashed: %s (bunny %s, bunnyfile %s). Please report it at https://github.com/nubificus/bunny/issues with this message.\n%s",
+		r.Panic, r.Version, file, r.Stack)
+}
+
+// Recover records the value of a panic and its stack and returns the crash
+// as an error or, with bundle, as a result with the report in its metadata
+// and without any references, so the report reaches the client.
+func (r *CrashReport) Recover(v any, stack []byte, bundle bool) (*client.Result, error) {
+	r.Panic = fmt.Sprint(v)
+	r.Stack = string(stack)
+	if !bundle {
+		return nil, r
+	}
+
+	dt, err := json.Marshal(r)
+	if err != nil {
+		return nil, r
+	}
+	res := client.NewResult()
+	res.AddMeta(CrashReportMetaKey, dt)
+
+	return res, nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"encoding/json"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrashReport(t *testing.T) {
+	file := []byte("version: v0.1\n")
+	newReport := func() *CrashReport {
+		r := NewCrashReport("v0.1.3", map[string]string{"filename": "bunnyfile", "build-arg:TOKEN": "secret"})
+		r.SetFile(file)
+		return r
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		res, err := newReport().Recover("index out of range", []byte("goroutine 1 [running]:\nmain.main()"), false)
+		require.Nil(t, res)
+		var r *CrashReport
+		require.ErrorAs(t, err, &r)
+		require.Equal(t, []string{"build-arg:TOKEN", "filename"}, r.Options)
+		require.ErrorContains(t, err, "The frontend crashed: index out of range (bunny v0.1.3, bunnyfile "+digest.FromBytes(file).String()+")")
+		require.ErrorContains(t, err, "goroutine 1 [running]:")
+		require.NotContains(t, err.Error(), "secret")
+	})
+	t.Run("Bundle", func(t *testing.T) {
+		res, err := newReport().Recover("index out of range", []byte("goroutine 1 [running]:"), true)
+		require.NoError(t, err)
+		require.Nil(t, res.Ref)
+		require.Empty(t, res.Refs)
+
+		var r CrashReport
+		require.NoError(t, json.Unmarshal(res.Metadata[CrashReportMetaKey], &r))
+		require.Equal(t, "v0.1.3", r.Version)
+		require.Equal(t, digest.FromBytes(file), r.File)
+		require.Equal(t, "index out of range", r.Panic)
+		require.Equal(t, "goroutine 1 [running]:", r.Stack)
+		require.NotContains(t, string(res.Metadata[CrashReportMetaKey]), "secret")
+	})
+	t.Run("Before reading the bunnyfile", func(t *testing.T) {
+		_, err := NewCrashReport("", nil).Recover(42, nil, false)
+		require.ErrorContains(t, err, "The frontend crashed: 42 (bunny unknown, bunnyfile not read yet)")
+	})
+}
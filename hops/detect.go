@@ -0,0 +1,113 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"fmt"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+const (
+	// Detect the framework from the kernel binary
+	FrameworkAuto string = "auto"
+)
+
+// The offset of the magic of the header of a Linux bzImage
+const bzImageMagicOffset = 0x202
+
+// DetectFramework inspects the given kernel binary and returns the framework
+// that built it. Unikraft kernels have sections with the .uk_ prefix, solo5
+// based kernels (mirage and rumprun) have solo5 notes, while Linux kernels
+// are either bzImages or ELF files with the banner of Linux.
+func DetectFramework(kernel []byte) (string, error) {
+	if len(kernel) > bzImageMagicOffset+4 &&
+		string(kernel[bzImageMagicOffset:bzImageMagicOffset+4]) == "HdrS" {
+		return "linux", nil
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(kernel))
+	if err != nil {
+		return "", fmt.Errorf("The kernel is neither an ELF file nor a bzImage: %v", err)
+	}
+	defer f.Close()
+
+	solo5 := false
+	for _, s := range f.Sections {
+		if strings.HasPrefix(s.Name, ".uk_") {
+			return unikraftName, nil
+		}
+		if strings.HasPrefix(s.Name, ".note.solo5") {
+			solo5 = true
+		}
+	}
+	switch {
+	case bytes.Contains(kernel, []byte("Powered by Unikraft")):
+		return unikraftName, nil
+	case bytes.Contains(kernel, []byte("rumprun")):
+		return "rumprun", nil
+	case solo5 || bytes.Contains(kernel, []byte("Solo5")):
+		return "mirage", nil
+	case bytes.Contains(kernel, []byte("Linux version ")):
+		return "linux", nil
+	default:
+		return "", fmt.Errorf("Could not detect the framework of the kernel")
+	}
+}
+
+// detectSource returns the state that contains the kernel of the bunnyfile.
+// A local kernel gets transferred on its own, rather than the whole context.
+func detectSource(h *Hops, buildContext string) llb.State {
+	if h.Kernel.From == "local" {
+		return llb.Local(buildContext, append(excludeOptions(h.Exclude),
+			llb.IncludePatterns([]string{h.Kernel.Path}),
+			llb.WithCustomName("Internal:Load kernel"))...)
+	}
+
+	return GetSourceState(h.Kernel.From, h.Platform.Monitor, h.Platform.Arch)
+}
+
+// DetectKernelFramework fetches the kernel of the bunnyfile and detects the
+// framework that built it.
+func DetectKernelFramework(ctx context.Context, c client.Client, h *Hops, buildContext string, opts SourceOpts) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("Detecting the framework requires bunny to run as a frontend")
+	}
+	def, err := marshalState(ctx, detectSource(h, buildContext), opts)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.Solve(ctx, client.SolveRequest{
+		Definition: def.ToPB(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to fetch the kernel: %v", err)
+	}
+	ref, err := res.SingleRef()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get reference of the kernel: %v", err)
+	}
+	kernel, err := ref.ReadFile(ctx, client.ReadRequest{Filename: h.Kernel.Path})
+	if err != nil {
+		return "", fmt.Errorf("Failed to read the kernel %s: %v", h.Kernel.Path, err)
+	}
+
+	return DetectFramework(kernel)
+}
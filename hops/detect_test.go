@@ -0,0 +1,178 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeELF creates a minimal ELF file with empty sections of the given names,
+// followed by the given content.
+func fakeELF(t *testing.T, sections []string, content string) []byte {
+	var shstrtab bytes.Buffer
+	shstrtab.WriteByte(0)
+	nameOffsets := make([]uint32, len(sections)+1)
+	for i, name := range append(sections, ".shstrtab") {
+		nameOffsets[i] = uint32(shstrtab.Len())
+		shstrtab.WriteString(name)
+		shstrtab.WriteByte(0)
+	}
+
+	const headerSize = 64
+	const sectionSize = 64
+	dataOff := uint64(headerSize)
+	shOff := dataOff + uint64(shstrtab.Len()) + uint64(len(content))
+	shOff = (shOff + 7) &^ 7
+
+	var buf bytes.Buffer
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     shOff,
+		Ehsize:    headerSize,
+		Shentsize: sectionSize,
+		Shnum:     uint16(len(sections) + 2),
+		Shstrndx:  uint16(len(sections) + 1),
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, hdr))
+	buf.Write(shstrtab.Bytes())
+	buf.WriteString(content)
+	buf.Write(make([]byte, int(shOff)-buf.Len()))
+
+	shdrs := []elf.Section64{{}}
+	for i := range sections {
+		shdrs = append(shdrs, elf.Section64{
+			Name: nameOffsets[i],
+			Type: uint32(elf.SHT_PROGBITS),
+			Off:  dataOff,
+		})
+	}
+	shdrs = append(shdrs, elf.Section64{
+		Name: nameOffsets[len(sections)],
+		Type: uint32(elf.SHT_STRTAB),
+		Off:  dataOff,
+		Size: uint64(shstrtab.Len()),
+	})
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, shdrs))
+
+	return buf.Bytes()
+}
+
+func TestDetectFramework(t *testing.T) {
+	bzImage := make([]byte, 1024)
+	copy(bzImage[0x202:], "HdrS")
+
+	tests := []struct {
+		name      string
+		kernel    []byte
+		framework string
+		errorText string
+	}{
+		{
+			name:      "Unikraft sections",
+			kernel:    fakeELF(t, []string{".text", ".uk_inittab"}, ""),
+			framework: "unikraft",
+		},
+		{
+			name:      "Unikraft banner",
+			kernel:    fakeELF(t, []string{".text"}, "Powered by Unikraft"),
+			framework: "unikraft",
+		},
+		{
+			name:      "Rumprun",
+			kernel:    fakeELF(t, []string{".text", ".note.solo5.manifest"}, "rumprun-solo5"),
+			framework: "rumprun",
+		},
+		{
+			name:      "Mirage",
+			kernel:    fakeELF(t, []string{".text", ".note.solo5.abi"}, ""),
+			framework: "mirage",
+		},
+		{
+			name:      "Linux ELF",
+			kernel:    fakeELF(t, []string{".text"}, "Linux version 6.6.0"),
+			framework: "linux",
+		},
+		{
+			name:      "Linux bzImage",
+			kernel:    bzImage,
+			framework: "linux",
+		},
+		{
+			name:      "Invalid unknown ELF",
+			kernel:    fakeELF(t, []string{".text"}, "hello"),
+			errorText: "Could not detect the framework of the kernel",
+		},
+		{
+			name:      "Invalid not a kernel",
+			kernel:    []byte("#!/bin/sh\necho hello\n"),
+			errorText: "The kernel is neither an ELF file nor a bzImage",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			framework, err := DetectFramework(tc.kernel)
+			if tc.errorText != "" {
+				require.ErrorContains(t, err, tc.errorText)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.framework, framework)
+		})
+	}
+}
+
+func TestDetectValidate(t *testing.T) {
+	h := &Hops{
+		Platform: Platform{Framework: FrameworkAuto, Monitor: "qemu"},
+		Rootfs:   Rootfs{From: "local", Path: "rootfs"},
+		Flavors:  []string{FlavorKraftkit},
+		Base:     "harbor.nbfc.io/base",
+	}
+	// The checks that depend on the framework wait for the detection
+	require.NoError(t, ValidateFlavors(h.Flavors, h.Platform))
+	require.NoError(t, ValidateBase(h.Base, h.Rootfs, h.Platform))
+
+	h.Platform.Framework = unikraftName
+	require.NoError(t, ValidateDetectedFramework(h))
+	h.Platform.Framework = "rumprun"
+	require.ErrorContains(t, ValidateDetectedFramework(h), "The kraftkit flavor is only supported for unikraft")
+}
+
+func TestDetectWithoutFrontend(t *testing.T) {
+	file := []byte(`#syntax=harbor.nbfc.io/nubificus/bunny:latest
+version: v0.1
+platforms:
+  framework: auto
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+`)
+	_, err := ParseFile(context.TODO(), file, "context", nil, SourceOpts{})
+	require.ErrorContains(t, err, "Detecting the framework requires bunny to run as a frontend")
+}
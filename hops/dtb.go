@@ -0,0 +1,71 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"github.com/moby/buildkit/client/llb"
+)
+
+const (
+	// The default path of the device tree blob in the final image, if it
+	// gets copied
+	DefaultDtbPath string = "/.boot/dtb"
+	// The annotation with the path of the device tree blob in the final
+	// image. urunc does not define one, so it belongs to bunny.
+	DtbAnnotation string = "io.bunny.dtb"
+)
+
+// Dtb defines the device tree blob that some monitors of arm64 need
+// alongside the kernel
+type Dtb struct {
+	// The source of the device tree blob, local or an OCI image
+	From string `yaml:"from"`
+	// The path of the device tree blob in its source
+	Path string `yaml:"path"`
+}
+
+// Enabled returns true if the bunnyfile defines a device tree blob
+func (d Dtb) Enabled() bool {
+	return d.From != ""
+}
+
+// handleDtb returns the entry of the device tree blob
+func handleDtb(in BuildInput, d Dtb) *PackEntry {
+	entry := &PackEntry{
+		SourceRef: d.From,
+		FilePath:  d.Path,
+	}
+	if d.From == "local" {
+		entry.SourceState = llb.Local(in.BuildContext, excludeOptions(in.Exclude)...)
+	} else {
+		entry.SourceState = GetSourceState(d.From, in.Monitor, in.Arch)
+	}
+
+	return entry
+}
+
+// SetDtbAndGetPath places the device tree blob in the final image and
+// returns its path. The device tree blob gets copied to the dtb path of Paths,
+// unless it already resides in the base of the final image and there is no
+// other path for it.
+func (i *PackInstructions) SetDtbAndGetPath(entry *PackEntry) string {
+	if entry.SourceRef != "local" && entry.SourceRef == i.BaseRef &&
+		(i.Paths.Dtb == "" || i.Paths.Dtb == entry.FilePath) {
+		return entry.FilePath
+	}
+	i.copyEntry("dtb", *entry, i.Paths.DtbPath())
+
+	return i.Paths.DtbPath()
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func dtbHops(kernelFrom string, dtb Dtb) *Hops {
+	return &Hops{
+		Platform: Platform{
+			Framework: "linux",
+			Monitor:   "qemu",
+			Arch:      "arm64",
+		},
+		Kernel: Kernel{
+			From: kernelFrom,
+			Path: "/kernel",
+		},
+		Dtb:     dtb,
+		Flavors: []string{FlavorLabels},
+	}
+}
+
+func TestDtbSetAndGetPath(t *testing.T) {
+	t.Run("Local dtb", func(t *testing.T) {
+		i := &PackInstructions{BaseRef: ""}
+		entry := handleDtb(BuildInput{BuildContext: "context"}, Dtb{From: "local", Path: "board.dtb"})
+		require.Equal(t, DefaultDtbPath, i.SetDtbAndGetPath(entry))
+		require.Len(t, i.Copies, 1)
+		require.Equal(t, "board.dtb", i.Copies[0].SrcPath)
+		require.Equal(t, DefaultDtbPath, i.Copies[0].DstPath)
+		require.Equal(t, []CopyDecision{{Name: "dtb", From: "local", Src: "board.dtb", Dst: DefaultDtbPath}},
+			i.BaseDecision.Copies)
+	})
+	t.Run("Dtb in the base", func(t *testing.T) {
+		i := &PackInstructions{BaseRef: "harbor.nbfc.io/kernel"}
+		entry := handleDtb(BuildInput{}, Dtb{From: "harbor.nbfc.io/kernel", Path: "/boot/board.dtb"})
+		require.Equal(t, "/boot/board.dtb", i.SetDtbAndGetPath(entry))
+		require.Empty(t, i.Copies)
+	})
+	t.Run("Dtb from another image", func(t *testing.T) {
+		i := &PackInstructions{BaseRef: "harbor.nbfc.io/kernel"}
+		entry := handleDtb(BuildInput{}, Dtb{From: "harbor.nbfc.io/dtbs", Path: "/board.dtb"})
+		require.Equal(t, DefaultDtbPath, i.SetDtbAndGetPath(entry))
+		require.Len(t, i.Copies, 1)
+	})
+}
+
+func TestDtbToPack(t *testing.T) {
+	t.Run("Local dtb", func(t *testing.T) {
+		i, err := ToPack(context.TODO(), dtbHops("local", Dtb{From: "local", Path: "board.dtb"}), "context")
+		require.NoError(t, err)
+		require.Equal(t, DefaultDtbPath, i.Annots[DtbAnnotation])
+		require.Equal(t, DefaultDtbPath, i.Annots[labelsPrefix+"dtb"])
+		var dsts []string
+		for _, c := range i.Copies {
+			dsts = append(dsts, c.DstPath)
+		}
+		require.Contains(t, dsts, DefaultDtbPath)
+
+		uruncJSON, err := UruncJSON(*i)
+		require.NoError(t, err)
+		// urunc does not understand the annotation
+		require.NotContains(t, string(uruncJSON), DtbAnnotation)
+		require.False(t, IsUruncAnnotation(DtbAnnotation))
+	})
+	t.Run("Dtb in the kernel image", func(t *testing.T) {
+		kernel := "harbor.nbfc.io/kernel:latest"
+		i, err := ToPack(context.TODO(), dtbHops(kernel, Dtb{From: kernel, Path: "/board.dtb"}), "context")
+		require.NoError(t, err)
+		require.Equal(t, "/board.dtb", i.Annots[DtbAnnotation])
+		require.Empty(t, i.Copies)
+	})
+	t.Run("No dtb", func(t *testing.T) {
+		i, err := ToPack(context.TODO(), dtbHops("local", Dtb{}), "context")
+		require.NoError(t, err)
+		require.NotContains(t, i.Annots, DtbAnnotation)
+		require.NotContains(t, i.Annots, labelsPrefix+"dtb")
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"path"
+)
+
+const (
+	// The elfloader kernel of the unikraft catalog
+	DefaultElfloaderImage      string = "unikraft.org/base:latest"
+	DefaultElfloaderKernelPath string = "/unikraft/bin/kernel"
+)
+
+// Binary is a Linux binary that runs on top of unikraft's elfloader
+type Binary struct {
+	From string `yaml:"from"`
+	Path string `yaml:"path"`
+}
+
+// Enabled returns true if the user asked for the elfloader mode
+func (b Binary) Enabled() bool {
+	return b.From != "" || b.Path != ""
+}
+
+// InitrdPath returns the path of the binary inside the initrd
+func (b Binary) InitrdPath() string {
+	return "/" + path.Base(b.Path)
+}
+
+// ApplyElfloader fills the kernel, the rootfs and the command of the
+// bunnyfile, so that the Linux binary runs on top of the elfloader kernel.
+// The binary is stored in an initrd and the elfloader gets its path as the
+// first argument of the command line.
+func ApplyElfloader(h *Hops) {
+	if !h.Binary.Enabled() {
+		return
+	}
+	from := h.Binary.From
+	if from == "" {
+		from = "local"
+	}
+
+	h.Kernel = Kernel{
+		From: DefaultElfloaderImage,
+		Path: DefaultElfloaderKernelPath,
+	}
+	h.Rootfs.From = "scratch"
+	h.Rootfs.Type = "initrd"
+	h.Rootfs.Includes = append([]FileToInclude{{
+		From: from,
+		Src:  h.Binary.Path,
+		Dst:  h.Binary.InitrdPath(),
+	}}, h.Rootfs.Includes...)
+	h.Cmd = append([]string{h.Binary.InitrdPath()}, h.Cmd...)
+}
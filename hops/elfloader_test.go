@@ -0,0 +1,82 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestElfloaderApply(t *testing.T) {
+	t.Run("Local binary", func(t *testing.T) {
+		h := &Hops{
+			Platform: Platform{Framework: "unikraft", Monitor: "qemu"},
+			Binary:   Binary{Path: "build/hello"},
+			Cmd:      []string{"-p", "8080"},
+		}
+		ApplyElfloader(h)
+		require.Equal(t, Kernel{From: DefaultElfloaderImage, Path: DefaultElfloaderKernelPath}, h.Kernel)
+		require.Equal(t, "scratch", h.Rootfs.From)
+		require.Equal(t, "initrd", h.Rootfs.Type)
+		require.Equal(t, []FileToInclude{{From: "local", Src: "build/hello", Dst: "/hello"}}, h.Rootfs.Includes)
+		require.Equal(t, []string{"/hello", "-p", "8080"}, h.Cmd)
+	})
+	t.Run("Binary from image with includes", func(t *testing.T) {
+		h := &Hops{
+			Platform: Platform{Framework: "unikraft", Monitor: "qemu"},
+			Binary:   Binary{From: "harbor.nbfc.io/foo", Path: "/usr/bin/hello"},
+			Rootfs: Rootfs{
+				Includes: []FileToInclude{{From: "local", Src: "index.html", Dst: "/index.html"}},
+			},
+		}
+		ApplyElfloader(h)
+		require.Equal(t, []FileToInclude{
+			{From: "harbor.nbfc.io/foo", Src: "/usr/bin/hello", Dst: "/hello"},
+			{From: "local", Src: "index.html", Dst: "/index.html"},
+		}, h.Rootfs.Includes)
+		require.Equal(t, []string{"/hello"}, h.Cmd)
+	})
+	t.Run("No binary", func(t *testing.T) {
+		h := &Hops{Kernel: Kernel{From: "local", Path: "kernel"}}
+		ApplyElfloader(h)
+		require.Equal(t, Kernel{From: "local", Path: "kernel"}, h.Kernel)
+		require.Equal(t, 0, len(h.Cmd))
+	})
+}
+
+func TestElfloaderParse(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(`
+version: v0.1
+platforms:
+  framework: unikraft
+  monitor: qemu
+binary:
+  path: hello
+cmdline: "-v"
+`))
+	require.NoError(t, err)
+	require.Equal(t, DefaultElfloaderImage, h.Kernel.From)
+	require.Equal(t, []string{"/hello", "-v"}, h.Cmd)
+
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.NotNil(t, i.KernelCheck)
+	require.Equal(t, DefaultElfloaderImage, i.BaseRef)
+	require.Equal(t, DefaultElfloaderKernelPath, i.Annots["com.urunc.unikernel.binary"])
+	require.Equal(t, DefaultRootfsPath, i.Annots["com.urunc.unikernel.initrd"])
+	require.Equal(t, "/hello -v", i.Annots["com.urunc.unikernel.cmdline"])
+}
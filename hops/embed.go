@@ -0,0 +1,212 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/opencontainers/go-digest"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Do not embed the bunnyfile
+	EmbedModeNone string = "none"
+	// Embed only the digest of the canonical bunnyfile
+	EmbedModeDigest string = "digest"
+	// Embed the digest and the content of the canonical bunnyfile in
+	// annotations
+	EmbedModeAnnotation string = "annotation"
+	// Embed the digest in an annotation and the content of the canonical
+	// bunnyfile in a file of the image
+	EmbedModeFile string = "file"
+
+	// The digest of the canonical bunnyfile
+	BunnyfileDigestAnnotation string = "io.bunny.bunnyfile.digest"
+	// The canonical bunnyfile, compressed with gzip and encoded in base64
+	BunnyfileContentAnnotation string = "io.bunny.bunnyfile.content"
+	// The path of the canonical bunnyfile in the image
+	DefaultBunnyfilePath string = "/.boot/bunnyfile"
+)
+
+// ParseEmbedMode checks that the given mode of embedding the bunnyfile in the
+// image is supported. An empty mode means the none mode.
+func ParseEmbedMode(mode string) (string, error) {
+	switch mode {
+	case "", EmbedModeNone:
+		return EmbedModeNone, nil
+	case EmbedModeDigest, EmbedModeAnnotation, EmbedModeFile:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Unknown embed mode %s, expected one of %s, %s, %s, %s",
+			mode, EmbedModeNone, EmbedModeDigest, EmbedModeAnnotation, EmbedModeFile)
+	}
+}
+
+// canonicalNode removes the comments and the styles (e.g. quotes or flow
+// collections) of the node and its children and sorts the keys of mappings,
+// so equivalent bunnyfiles get the same form. The encoder quotes any string
+// that would otherwise get another type.
+func canonicalNode(node *yaml.Node) {
+	node.HeadComment = ""
+	node.LineComment = ""
+	node.FootComment = ""
+	node.Style = 0
+	for _, child := range node.Content {
+		canonicalNode(child)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i][0].Value < pairs[j][0].Value
+	})
+	for i, pair := range pairs {
+		node.Content[2*i] = pair[0]
+		node.Content[2*i+1] = pair[1]
+	}
+}
+
+// CanonicalBunnyfile returns the bunnyfile in a canonical YAML form, without
+// comments (including the syntax directive) and with sorted keys, whether it
+// was written in YAML or JSON. Two bunnyfiles that differ only in formatting
+// have the same canonical form and hence the same digest.
+func CanonicalBunnyfile(fileBytes []byte) ([]byte, error) {
+	yamlBytes, err := bunnyfileYAML(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	err = yaml.Unmarshal(yamlBytes, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode bunnyfile: %v", err)
+	}
+	canonicalNode(&doc)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err = enc.Encode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode canonical bunnyfile: %v", err)
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode canonical bunnyfile: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EncodeBunnyfileAnnotation compresses the canonical bunnyfile with gzip and
+// encodes it in base64 for its annotation. The gzip header has no name or
+// time, so the same bunnyfile always gets the same annotation.
+func EncodeBunnyfileAnnotation(canonical []byte) (string, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	_, err = zw.Write(canonical)
+	if err != nil {
+		return "", fmt.Errorf("Failed to compress bunnyfile: %v", err)
+	}
+	err = zw.Close()
+	if err != nil {
+		return "", fmt.Errorf("Failed to compress bunnyfile: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeBunnyfileAnnotation returns the canonical bunnyfile of the content
+// annotation of an image.
+func DecodeBunnyfileAnnotation(value string) ([]byte, error) {
+	compressed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode the annotation of the bunnyfile: %v", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decompress the annotation of the bunnyfile: %v", err)
+	}
+	defer zr.Close()
+	canonical, err := io.ReadAll(io.LimitReader(zr, serviceMaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decompress the annotation of the bunnyfile: %v", err)
+	}
+	if int64(len(canonical)) > serviceMaxFileSize {
+		return nil, fmt.Errorf("The bunnyfile of the annotation is larger than %d bytes", serviceMaxFileSize)
+	}
+
+	return canonical, nil
+}
+
+// EmbedBunnyfile records the bunnyfile that produced the image, based on the
+// given mode: the digest of its canonical form in an annotation and a label,
+// and the canonical form itself in an annotation or in DefaultBunnyfilePath.
+// Operators can then reconstruct how any running unikernel was built. Only
+// bunnyfiles can get embedded, not Containerfiles.
+func (i *PackInstructions) EmbedBunnyfile(fileBytes []byte, mode string) error {
+	if mode == EmbedModeNone {
+		return nil
+	}
+	if i.FileVersion == "" {
+		return fmt.Errorf("Embedding the file in the image is only supported for bunnyfiles")
+	}
+	canonical, err := CanonicalBunnyfile(fileBytes)
+	if err != nil {
+		return err
+	}
+
+	if i.Annots == nil {
+		i.Annots = make(map[string]string)
+	}
+	if i.Img.Config.Labels == nil {
+		i.Img.Config.Labels = make(map[string]string)
+	}
+	dgst := digest.FromBytes(canonical).String()
+	i.Annots[BunnyfileDigestAnnotation] = dgst
+	i.Img.Config.Labels[BunnyfileDigestAnnotation] = dgst
+
+	switch mode {
+	case EmbedModeAnnotation:
+		content, err := EncodeBunnyfileAnnotation(canonical)
+		if err != nil {
+			return err
+		}
+		i.Annots[BunnyfileContentAnnotation] = content
+	case EmbedModeFile:
+		i.Copies = append(i.Copies, PackCopies{
+			SrcState: llb.Scratch().File(llb.Mkfile("/bunnyfile", 0644, canonical),
+				llb.WithCustomName("Internal:Embed bunnyfile")),
+			SrcPath: "/bunnyfile",
+			DstPath: DefaultBunnyfilePath,
+			Name:    "bunnyfile",
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+const embedBunnyfile = `#syntax=harbor.nbfc.io/nubificus/bunny:latest
+version: v0.1
+platforms:
+  framework: linux   # The framework
+  monitor: qemu
+kernel:
+  path: kernel
+  from: local
+rootfs:
+  type: initrd
+  include:
+    - src: "app"
+      dst: /app
+      mode: "0755"
+cmdline: "yes"
+`
+
+func TestEmbedParseMode(t *testing.T) {
+	mode, err := ParseEmbedMode("")
+	require.NoError(t, err)
+	require.Equal(t, EmbedModeNone, mode)
+	for _, m := range []string{EmbedModeNone, EmbedModeDigest, EmbedModeAnnotation, EmbedModeFile} {
+		mode, err = ParseEmbedMode(m)
+		require.NoError(t, err)
+		require.Equal(t, m, mode)
+	}
+	_, err = ParseEmbedMode("label")
+	require.ErrorContains(t, err, "Unknown embed mode label")
+}
+
+func TestEmbedCanonical(t *testing.T) {
+	canonical, err := CanonicalBunnyfile([]byte(embedBunnyfile))
+	require.NoError(t, err)
+	require.Equal(t, `cmdline: yes
+kernel:
+  from: local
+  path: kernel
+platforms:
+  framework: linux
+  monitor: qemu
+rootfs:
+  include:
+    - dst: /app
+      mode: "0755"
+      src: app
+  type: initrd
+version: v0.1
+`, string(canonical))
+
+	// The same bunnyfile in JSON has the same canonical form
+	fromJSON, err := CanonicalBunnyfile([]byte(`{
+  "version": "v0.1",
+  "platforms": {"monitor": "qemu", "framework": "linux"},
+  "kernel": {"from": "local", "path": "kernel"},
+  "rootfs": {"type": "initrd", "include": [{"src": "app", "dst": "/app", "mode": "0755"}]},
+  "cmdline": "yes"
+}`))
+	require.NoError(t, err)
+	require.Equal(t, string(canonical), string(fromJSON))
+
+	// The canonical form is still the same bunnyfile
+	h, err := ParseBunnyfile(canonical)
+	require.NoError(t, err)
+	require.Equal(t, "yes", h.Cmdline)
+	require.Equal(t, "0755", h.Rootfs.Includes[0].Mode)
+
+	_, err = CanonicalBunnyfile([]byte("{"))
+	require.ErrorContains(t, err, "Invalid JSON bunnyfile")
+}
+
+func TestEmbedAnnotation(t *testing.T) {
+	canonical, err := CanonicalBunnyfile([]byte(embedBunnyfile))
+	require.NoError(t, err)
+	value, err := EncodeBunnyfileAnnotation(canonical)
+	require.NoError(t, err)
+	again, err := EncodeBunnyfileAnnotation(canonical)
+	require.NoError(t, err)
+	require.Equal(t, value, again)
+
+	decoded, err := DecodeBunnyfileAnnotation(value)
+	require.NoError(t, err)
+	require.Equal(t, canonical, decoded)
+
+	_, err = DecodeBunnyfileAnnotation("not base64!")
+	require.ErrorContains(t, err, "Failed to decode the annotation of the bunnyfile")
+	_, err = DecodeBunnyfileAnnotation("aGVsbG8=")
+	require.ErrorContains(t, err, "Failed to decompress the annotation of the bunnyfile")
+}
+
+func TestEmbedBunnyfile(t *testing.T) {
+	canonical, err := CanonicalBunnyfile([]byte(embedBunnyfile))
+	require.NoError(t, err)
+	dgst := digest.FromBytes(canonical).String()
+	pack := func(t *testing.T) *PackInstructions {
+		h, err := ParseBunnyfile([]byte(embedBunnyfile))
+		require.NoError(t, err)
+		instr, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		return instr
+	}
+
+	t.Run("None", func(t *testing.T) {
+		instr := pack(t)
+		copies := len(instr.Copies)
+		require.NoError(t, instr.EmbedBunnyfile([]byte(embedBunnyfile), EmbedModeNone))
+		require.NotContains(t, instr.Annots, BunnyfileDigestAnnotation)
+		require.Len(t, instr.Copies, copies)
+	})
+	t.Run("Digest", func(t *testing.T) {
+		instr := pack(t)
+		require.NoError(t, instr.EmbedBunnyfile([]byte(embedBunnyfile), EmbedModeDigest))
+		require.Equal(t, dgst, instr.Annots[BunnyfileDigestAnnotation])
+		require.Equal(t, dgst, instr.Img.Config.Labels[BunnyfileDigestAnnotation])
+		require.NotContains(t, instr.Annots, BunnyfileContentAnnotation)
+	})
+	t.Run("Annotation", func(t *testing.T) {
+		instr := pack(t)
+		require.NoError(t, instr.EmbedBunnyfile([]byte(embedBunnyfile), EmbedModeAnnotation))
+		require.Equal(t, dgst, instr.Annots[BunnyfileDigestAnnotation])
+		decoded, err := DecodeBunnyfileAnnotation(instr.Annots[BunnyfileContentAnnotation])
+		require.NoError(t, err)
+		require.Equal(t, canonical, decoded)
+		// The content is too large for a label
+		require.NotContains(t, instr.Img.Config.Labels, BunnyfileContentAnnotation)
+		// Only the urunc annotations reach urunc.json
+		uruncJSON, err := UruncJSON(*instr)
+		require.NoError(t, err)
+		require.NotContains(t, string(uruncJSON), BunnyfileContentAnnotation)
+	})
+	t.Run("File", func(t *testing.T) {
+		instr := pack(t)
+		require.NoError(t, instr.EmbedBunnyfile([]byte(embedBunnyfile), EmbedModeFile))
+		require.Equal(t, dgst, instr.Annots[BunnyfileDigestAnnotation])
+		require.NotContains(t, instr.Annots, BunnyfileContentAnnotation)
+		last := instr.Copies[len(instr.Copies)-1]
+		require.Equal(t, DefaultBunnyfilePath, last.DstPath)
+
+		def, err := PackLLB(context.TODO(), *instr)
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var data []byte
+		for _, op := range arr {
+			for _, action := range op.GetFile().GetActions() {
+				if mf := action.GetMkfile(); mf != nil && mf.Path == "/bunnyfile" {
+					data = mf.Data
+				}
+			}
+		}
+		require.Equal(t, canonical, data)
+	})
+	t.Run("Containerfile", func(t *testing.T) {
+		err := (&PackInstructions{}).EmbedBunnyfile([]byte("FROM scratch\n"), EmbedModeDigest)
+		require.ErrorContains(t, err, "only supported for bunnyfiles")
+	})
+}
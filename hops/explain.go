@@ -0,0 +1,202 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// ExplainOp is an operation of the LLB that a field of the bunnyfile
+// generated
+type ExplainOp struct {
+	// The kind of the operation (source or copy)
+	Kind string `json:"kind"`
+	// The digest of the operation
+	Digest digest.Digest `json:"digest"`
+	// What the operation does, e.g. the identifier of a source
+	Label string `json:"label"`
+}
+
+// Provenance links a field of the bunnyfile to the operations of the LLB
+// that it generated
+type Provenance struct {
+	// The path of the field, e.g. rootfs.include[0]
+	Field string `json:"field"`
+	// The value of the field
+	Value string `json:"value"`
+	// The operations of the field, none if the field did not generate any
+	// (e.g. the kernel stays in the base of the final image)
+	Ops []ExplainOp `json:"ops"`
+}
+
+// explainMatcher tells if an operation of the LLB belongs to a field
+type explainMatcher func(op *pb.Op) (ExplainOp, bool)
+
+// sourceMatcher matches the source operations of the given reference, which
+// can be local for the build context or an OCI image
+func sourceMatcher(ref string, buildContext string) explainMatcher {
+	identifier := "local://" + buildContext
+	if ref != "local" {
+		named, err := reference.ParseNormalizedNamed(ref)
+		if err == nil {
+			ref = reference.TagNameOnly(named).String()
+		}
+		identifier = "docker-image://" + ref
+	}
+
+	return func(op *pb.Op) (ExplainOp, bool) {
+		src := op.GetSource()
+		if src == nil || src.Identifier != identifier {
+			return ExplainOp{}, false
+		}
+		return ExplainOp{Kind: "source", Label: src.Identifier}, true
+	}
+}
+
+// copyMatcher matches the copy operations of the given source path and, if
+// not empty, destination path
+func copyMatcher(src string, dst string) explainMatcher {
+	src = path.Join("/", src)
+	if dst != "" {
+		dst = path.Join("/", dst)
+	}
+
+	return func(op *pb.Op) (ExplainOp, bool) {
+		file := op.GetFile()
+		if file == nil {
+			return ExplainOp{}, false
+		}
+		for _, action := range file.Actions {
+			c := action.GetCopy()
+			if c == nil || path.Join("/", c.Src) != src {
+				continue
+			}
+			if dst != "" && path.Join("/", c.Dest) != dst {
+				continue
+			}
+			return ExplainOp{Kind: "copy", Label: c.Src + " -> " + c.Dest}, true
+		}
+		return ExplainOp{}, false
+	}
+}
+
+// explainField is a field of the bunnyfile and how to find its operations
+type explainField struct {
+	field   string
+	value   string
+	matcher explainMatcher
+}
+
+// explainFields returns the fields of the bunnyfile that generate
+// operations of the LLB
+func explainFields(h *Hops, buildContext string) []explainField {
+	var fields []explainField
+	addSource := func(field string, ref string) {
+		if ref == "" || ref == "scratch" || ref == KernelFromBuild {
+			return
+		}
+		fields = append(fields, explainField{field, ref, sourceMatcher(ref, buildContext)})
+	}
+	addCopy := func(field string, src string, dst string) {
+		if src == "" {
+			return
+		}
+		value := src
+		if dst != "" {
+			value += ":" + dst
+		}
+		fields = append(fields, explainField{field, value, copyMatcher(src, dst)})
+	}
+
+	addSource("kernel.from", h.Kernel.From)
+	addCopy("kernel.path", h.Kernel.Path, "")
+	addSource("rootfs.from", h.Rootfs.From)
+	addCopy("rootfs.path", h.Rootfs.Path, "")
+	includes := expandIncludes(h.Rootfs.Includes, h.Platform.Arch, h.Platform.Monitor)
+	for i, include := range includes {
+		field := fmt.Sprintf("rootfs.include[%d]", i)
+		addCopy(field, include.Src, include.Dst)
+		from := include.From
+		if from == "" {
+			from = "local"
+		}
+		addSource(field+".from", from)
+	}
+	addSource("base", h.Base)
+	if h.Dtb.Enabled() {
+		addSource("dtb.from", h.Dtb.From)
+		addCopy("dtb.path", h.Dtb.Path, "")
+	}
+	if h.Modules.Enabled() {
+		addSource("modules.from", h.Modules.From)
+		addSource("modules.image", h.Modules.Image)
+	}
+
+	return fields
+}
+
+// Explain returns the operations of the given definition that each field
+// of the bunnyfile generated, in the order of the fields in the bunnyfile
+func Explain(h *Hops, buildContext string, def *llb.Definition) ([]Provenance, error) {
+	var ops []*pb.Op
+	var digests []digest.Digest
+	for _, dt := range def.Def {
+		var op pb.Op
+		err := op.Unmarshal(dt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal LLB operation: %v", err)
+		}
+		ops = append(ops, &op)
+		digests = append(digests, digest.FromBytes(dt))
+	}
+
+	var provenance []Provenance
+	for _, f := range explainFields(h, buildContext) {
+		p := Provenance{Field: f.field, Value: f.value, Ops: []ExplainOp{}}
+		for i, op := range ops {
+			if explained, ok := f.matcher(op); ok {
+				explained.Digest = digests[i]
+				p.Ops = append(p.Ops, explained)
+			}
+		}
+		provenance = append(provenance, p)
+	}
+
+	return provenance, nil
+}
+
+// FormatExplain returns a human readable form of the provenance, with a
+// line for every operation of each field
+func FormatExplain(provenance []Provenance) string {
+	var b strings.Builder
+	for _, p := range provenance {
+		if len(p.Ops) == 0 {
+			fmt.Fprintf(&b, "%s (%s) → no operation\n", p.Field, p.Value)
+			continue
+		}
+		for _, op := range p.Ops {
+			fmt.Fprintf(&b, "%s (%s) → %s op %.12s %s\n", p.Field, p.Value, op.Kind, op.Digest.Encoded(), op.Label)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func explainBunnyfile(t *testing.T, file string) []Provenance {
+	h, err := ParseBunnyfile([]byte(file))
+	require.NoError(t, err)
+	instr, err := packHops(context.TODO(), h, "context", nil, SourceOpts{Arch: "amd64"})
+	require.NoError(t, err)
+	def, err := TargetLLB(context.TODO(), *instr, TargetImage)
+	require.NoError(t, err)
+	provenance, err := Explain(h, "context", def)
+	require.NoError(t, err)
+
+	return provenance
+}
+
+func TestExplainFields(t *testing.T) {
+	provenance := explainBunnyfile(t, `
+version: `+Version+`
+platforms:
+  framework: unikraft
+  monitor: qemu
+  architecture: amd64
+kernel:
+  from: unikraft.org/nginx:1.15
+  path: /unikraft/bin/kernel
+rootfs:
+  type: initrd
+  include:
+    - nginx.conf:/nginx/conf/{{arch}}/nginx.conf
+base: nginx
+cmd: ["-c", "/nginx/conf/nginx.conf"]
+`)
+	fields := map[string]Provenance{}
+	var order []string
+	for _, p := range provenance {
+		fields[p.Field] = p
+		order = append(order, p.Field)
+	}
+	require.Equal(t, []string{"kernel.from", "kernel.path", "rootfs.include[0]", "rootfs.include[0].from", "base"}, order)
+
+	require.Len(t, fields["kernel.from"].Ops, 1)
+	require.Equal(t, "source", fields["kernel.from"].Ops[0].Kind)
+	require.Equal(t, "docker-image://unikraft.org/nginx:1.15", fields["kernel.from"].Ops[0].Label)
+	require.NotEmpty(t, fields["kernel.from"].Ops[0].Digest)
+
+	// With a base, the kernel gets copied in the final image
+	require.Len(t, fields["kernel.path"].Ops, 1)
+	require.Equal(t, "/unikraft/bin/kernel -> /.boot/kernel", fields["kernel.path"].Ops[0].Label)
+
+	require.Equal(t, "nginx.conf:/nginx/conf/amd64/nginx.conf", fields["rootfs.include[0]"].Value)
+	require.Len(t, fields["rootfs.include[0]"].Ops, 1)
+	require.Equal(t, "copy", fields["rootfs.include[0]"].Ops[0].Kind)
+	require.Equal(t, "local://context", fields["rootfs.include[0].from"].Ops[0].Label)
+
+	require.Len(t, fields["base"].Ops, 1)
+	require.Equal(t, "docker-image://docker.io/library/nginx:latest", fields["base"].Ops[0].Label)
+}
+
+func TestExplainFormat(t *testing.T) {
+	provenance := explainBunnyfile(t, `
+version: `+Version+`
+platforms:
+  framework: unikraft
+  monitor: qemu
+  architecture: amd64
+kernel:
+  from: unikraft.org/nginx:1.15
+  path: /unikraft/bin/kernel
+rootfs:
+  type: initrd
+  include:
+    - nginx.conf:/nginx/conf/nginx.conf
+`)
+	out := FormatExplain(provenance)
+	require.Contains(t, out, "kernel.from (unikraft.org/nginx:1.15) → source op ")
+	require.Contains(t, out, " docker-image://unikraft.org/nginx:1.15\n")
+	// The kernel stays in the base of the final image
+	require.Contains(t, out, "kernel.path (/unikraft/bin/kernel) → no operation\n")
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Annotations and urunc.json for urunc, always part of the image
+	FlavorUrunc string = "urunc"
+	// The layout and the annotations of the OCI images of kraftkit
+	FlavorKraftkit string = "kraftkit"
+	// Runtime-agnostic labels that describe the unikernel
+	FlavorLabels string = "labels"
+)
+
+const (
+	// The paths where kraftkit looks for the kernel and the initrd
+	kraftkitKernelPath string = "/unikraft/bin/kernel"
+	kraftkitInitrdPath string = "/unikraft/bin/initrd"
+	// The annotations of kraftkit
+	kraftkitKernelPathAnnot    string = "org.unikraft.kernel.path"
+	kraftkitInitrdPathAnnot    string = "org.unikraft.kernel.initrd.path"
+	kraftkitKernelVersionAnnot string = "org.unikraft.kernel.version"
+	// The prefix of the runtime-agnostic labels
+	labelsPrefix string = "io.bunny.unikernel."
+)
+
+// FlavorInput contains the information about the unikernel that the output
+// flavors describe in the image
+type FlavorInput struct {
+	// The platform as specified by the user
+	Platform Platform
+	// The command line of the unikernel
+	Cmd []string
+	// The path of the kernel inside the final image
+	KernelPath string
+	// The path of the rootfs inside the final image, if any
+	RootfsPath string
+	// The type of the rootfs, empty if there is no rootfs
+	RootfsType string
+	// The path of the device tree blob inside the final image, if any
+	DtbPath string
+}
+
+// Flavor describes the unikernel in the final image, so a specific runtime
+// can consume it
+type Flavor interface {
+	Name() string
+	Apply(*PackInstructions, FlavorInput) error
+}
+
+type uruncFlavor struct{}
+
+func (uruncFlavor) Name() string {
+	return FlavorUrunc
+}
+
+func (uruncFlavor) Apply(i *PackInstructions, in FlavorInput) error {
+	err := i.SetAnnotations(in.Platform, in.Cmd, in.KernelPath, in.RootfsPath, in.RootfsType)
+	if err != nil {
+		return err
+	}
+	if in.DtbPath != "" {
+		i.Annots[DtbAnnotation] = in.DtbPath
+	}
+
+	return nil
+}
+
+type kraftkitFlavor struct{}
+
+func (kraftkitFlavor) Name() string {
+	return FlavorKraftkit
+}
+
+func (kraftkitFlavor) Apply(i *PackInstructions, in FlavorInput) error {
+	if in.Platform.Framework != unikraftName {
+		return fmt.Errorf("The %s flavor supports only %s", FlavorKraftkit, unikraftName)
+	}
+	if in.RootfsType != "" && in.RootfsType != "initrd" {
+		return fmt.Errorf("The %s flavor does not support %s rootfs", FlavorKraftkit, in.RootfsType)
+	}
+
+	i.Annots[kraftkitKernelPathAnnot] = kraftkitKernelPath
+	i.addFlavorCopy(FlavorKraftkit, in.KernelPath, kraftkitKernelPath)
+	if in.RootfsType == "initrd" {
+		i.Annots[kraftkitInitrdPathAnnot] = kraftkitInitrdPath
+		i.addFlavorCopy(FlavorKraftkit, in.RootfsPath, kraftkitInitrdPath)
+	}
+	if in.Platform.Version != "" {
+		i.Annots[kraftkitKernelVersionAnnot] = in.Platform.Version
+	}
+
+	return nil
+}
+
+type labelsFlavor struct{}
+
+func (labelsFlavor) Name() string {
+	return FlavorLabels
+}
+
+func (labelsFlavor) Apply(i *PackInstructions, in FlavorInput) error {
+	labels := map[string]string{
+		"framework":   in.Platform.Framework,
+		"version":     in.Platform.Version,
+		"monitor":     in.Platform.Monitor,
+		"arch":        in.Platform.Arch,
+		"kernel":      in.KernelPath,
+		"rootfs":      in.RootfsPath,
+		"rootfs.type": in.RootfsType,
+		"dtb":         in.DtbPath,
+		"cmdline":     strings.Join(in.Cmd, " "),
+	}
+	for k, v := range labels {
+		if v != "" {
+			i.Annots[labelsPrefix+k] = v
+		}
+	}
+
+	return nil
+}
+
+// NewFlavor returns the output flavor with the given name
+func NewFlavor(name string) (Flavor, error) {
+	switch name {
+	case FlavorUrunc:
+		return uruncFlavor{}, nil
+	case FlavorKraftkit:
+		return kraftkitFlavor{}, nil
+	case FlavorLabels:
+		return labelsFlavor{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown flavor %s, expected one of %s, %s, %s",
+			name, FlavorUrunc, FlavorKraftkit, FlavorLabels)
+	}
+}
+
+// ApplyFlavors describes the unikernel in the final image for urunc and any
+// other given flavor.
+func ApplyFlavors(i *PackInstructions, flavors []string, in FlavorInput) error {
+	applied := map[string]bool{}
+	for _, name := range append([]string{FlavorUrunc}, flavors...) {
+		if applied[name] {
+			continue
+		}
+		applied[name] = true
+		f, err := NewFlavor(name)
+		if err != nil {
+			return err
+		}
+		err = f.Apply(i, in)
+		if err != nil {
+			return fmt.Errorf("Could not apply %s flavor: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// addFlavorCopy copies a file of the final image to the path that the given
+// flavor expects.
+func (i *PackInstructions) addFlavorCopy(flavor string, src string, dst string) {
+	if src == dst {
+		return
+	}
+	i.FlavorCopies = append(i.FlavorCopies, PackCopies{
+		SrcPath: src,
+		DstPath: dst,
+		Name:    flavor + " flavor",
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func flavorInput() FlavorInput {
+	return FlavorInput{
+		Platform: Platform{
+			Framework: "unikraft",
+			Version:   "0.17.0",
+			Monitor:   "qemu",
+		},
+		Cmd:        []string{"/app", "-v"},
+		KernelPath: DefaultKernelPath,
+		RootfsPath: DefaultRootfsPath,
+		RootfsType: "initrd",
+	}
+}
+
+func TestFlavorsApply(t *testing.T) {
+	t.Run("Urunc by default", func(t *testing.T) {
+		i := &PackInstructions{Annots: map[string]string{}}
+		err := ApplyFlavors(i, nil, flavorInput())
+		require.NoError(t, err)
+		require.Equal(t, "unikraft", i.Annots["com.urunc.unikernel.unikernelType"])
+		require.Equal(t, DefaultRootfsPath, i.Annots["com.urunc.unikernel.initrd"])
+		require.Empty(t, i.FlavorCopies)
+		for k := range i.Annots {
+			require.True(t, IsUruncAnnotation(k), k)
+		}
+	})
+	t.Run("Kraftkit", func(t *testing.T) {
+		i := &PackInstructions{Annots: map[string]string{}}
+		err := ApplyFlavors(i, []string{FlavorKraftkit, FlavorUrunc}, flavorInput())
+		require.NoError(t, err)
+		require.Equal(t, DefaultKernelPath, i.Annots["com.urunc.unikernel.binary"])
+		require.Equal(t, "/unikraft/bin/kernel", i.Annots["org.unikraft.kernel.path"])
+		require.Equal(t, "/unikraft/bin/initrd", i.Annots["org.unikraft.kernel.initrd.path"])
+		require.Equal(t, "0.17.0", i.Annots["org.unikraft.kernel.version"])
+		require.Len(t, i.FlavorCopies, 2)
+		require.Equal(t, DefaultKernelPath, i.FlavorCopies[0].SrcPath)
+		require.Equal(t, "/unikraft/bin/kernel", i.FlavorCopies[0].DstPath)
+		require.Equal(t, DefaultRootfsPath, i.FlavorCopies[1].SrcPath)
+		require.Equal(t, "/unikraft/bin/initrd", i.FlavorCopies[1].DstPath)
+	})
+	t.Run("Kraftkit kernel already in place", func(t *testing.T) {
+		in := flavorInput()
+		in.KernelPath = "/unikraft/bin/kernel"
+		in.RootfsType = ""
+		in.RootfsPath = ""
+		i := &PackInstructions{Annots: map[string]string{}}
+		err := ApplyFlavors(i, []string{FlavorKraftkit}, in)
+		require.NoError(t, err)
+		require.Empty(t, i.FlavorCopies)
+		require.NotContains(t, i.Annots, "org.unikraft.kernel.initrd.path")
+	})
+	t.Run("Kraftkit with raw rootfs", func(t *testing.T) {
+		in := flavorInput()
+		in.RootfsType = "raw"
+		i := &PackInstructions{Annots: map[string]string{}}
+		err := ApplyFlavors(i, []string{FlavorKraftkit}, in)
+		require.ErrorContains(t, err, "The kraftkit flavor does not support raw rootfs")
+	})
+	t.Run("Kraftkit with linux", func(t *testing.T) {
+		in := flavorInput()
+		in.Platform.Framework = "linux"
+		i := &PackInstructions{Annots: map[string]string{}}
+		err := ApplyFlavors(i, []string{FlavorKraftkit}, in)
+		require.ErrorContains(t, err, "The kraftkit flavor supports only unikraft")
+	})
+	t.Run("Labels", func(t *testing.T) {
+		i := &PackInstructions{Annots: map[string]string{}}
+		err := ApplyFlavors(i, []string{FlavorLabels}, flavorInput())
+		require.NoError(t, err)
+		require.Equal(t, "unikraft", i.Annots["io.bunny.unikernel.framework"])
+		require.Equal(t, "qemu", i.Annots["io.bunny.unikernel.monitor"])
+		require.Equal(t, DefaultKernelPath, i.Annots["io.bunny.unikernel.kernel"])
+		require.Equal(t, "initrd", i.Annots["io.bunny.unikernel.rootfs.type"])
+		require.Equal(t, "/app -v", i.Annots["io.bunny.unikernel.cmdline"])
+		require.NotContains(t, i.Annots, "io.bunny.unikernel.arch")
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		i := &PackInstructions{Annots: map[string]string{}}
+		err := ApplyFlavors(i, []string{"docker"}, flavorInput())
+		require.ErrorContains(t, err, "Unknown flavor docker")
+	})
+}
+
+func TestFlavorsPackLLB(t *testing.T) {
+	h := targetHops()
+	h.Flavors = []string{FlavorKraftkit}
+	instr, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	def, err := PackLLB(context.TODO(), *instr)
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+
+	var dests []string
+	for _, op := range arr {
+		if file := op.GetFile(); file != nil {
+			for _, action := range file.Actions {
+				if cp := action.GetCopy(); cp != nil {
+					dests = append(dests, cp.Dest)
+				}
+			}
+		}
+	}
+	require.Contains(t, dests, "/unikraft/bin/kernel")
+	require.Contains(t, dests, "/unikraft/bin/initrd")
+}
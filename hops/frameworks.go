@@ -15,9 +15,51 @@
 package hops
 
 import (
+	"context"
+
 	"github.com/moby/buildkit/client/llb"
 )
 
+// BuildInput contains all the information that a framework needs to
+// create or update a rootfs and to build a kernel.
+type BuildInput struct {
+	// The name of the local build context
+	BuildContext string
+	// The monitor that will execute the unikernel
+	Monitor string
+	// The architecture of the unikernel, the host's if empty
+	Arch string
+	// The kernel as specified by the user
+	Kernel Kernel
+	// How network-bound steps should behave
+	Network NetworkPolicy
+	// Extra CA certificates in the build context for network-bound steps
+	Certificates []string
+	// Run the tools with a read-only root and without network, if possible
+	Hardened bool
+	// The options of the exec operations of each step
+	Build BuildOptions
+	// The kernel modules to package in the rootfs
+	Modules Modules
+	// The owner of the included files of the rootfs
+	Owner Owner
+	// The commands to run on the files of the rootfs, before packing
+	Hooks Hooks
+	// The patterns of the files of the build context to leave out, e.g. of
+	// the .bunnyignore file
+	Exclude []string
+}
+
+// excludeOptions returns the options of a local source of the build context
+// that leave out the files of the given exclude patterns, if any
+func excludeOptions(exclude []string) []llb.LocalOption {
+	if len(exclude) == 0 {
+		return nil
+	}
+
+	return []llb.LocalOption{llb.ExcludePatterns(exclude)}
+}
+
 type Framework interface {
 	Name() string
 	GetRootfsType() string
@@ -25,7 +67,7 @@ type Framework interface {
 	SupportsFsType(string) bool
 	SupportsMonitor(string) bool
 	SupportsArch(string) bool
-	CreateRootfs(string) (llb.State, error)
-	UpdateRootfs(string) (llb.State, error)
-	BuildKernel(string) llb.State
+	CreateRootfs(context.Context, BuildInput) (llb.State, error)
+	UpdateRootfs(context.Context, BuildInput) (llb.State, error)
+	BuildKernel(context.Context, BuildInput) (llb.State, error)
 }
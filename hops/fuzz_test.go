@@ -0,0 +1,149 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// The frontend parses files of its users, so the parsers must return an
+// error for any input instead of panicking. The seeds below and the corpus
+// in testdata/fuzz run as regular tests, while `go test -fuzz` explores
+// further from them.
+
+// offlineResolver fails every resolution, so the fuzz targets never reach a
+// registry for the images of the inputs
+type offlineResolver struct{}
+
+func (offlineResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	return "", "", nil, fmt.Errorf("%s: not found", ref)
+}
+
+// fuzzSourceOpts are the options of the fuzz targets, as bunny runs with
+// --LLB
+var fuzzSourceOpts = SourceOpts{Arch: "amd64", Resolver: offlineResolver{}}
+
+var fuzzBunnyfiles = []string{
+	`#syntax=harbor.nbfc.io/nubificus/bunny:latest
+version: v0.1
+platforms:
+  framework: unikraft
+  version: v0.15.0
+  monitor: qemu
+  arch: x86
+rootfs:
+  from: local
+  path: rootfs
+kernel:
+  from: local
+  path: kernel
+cmdline: "-c /nginx/conf/nginx.conf"
+`,
+	`version: v0.1
+platforms:
+  framework: linux
+  monitor: firecracker
+  arch: amd64
+rootfs:
+  from: harbor.nbfc.io/nubificus/urunc/nginx-rootfs:latest
+  type: initrd
+  include:
+    - nginx.conf:/nginx/conf/nginx.conf
+    - from: local
+      src: index.html
+      dst: /www/index.html
+kernel:
+  from: harbor.nbfc.io/nubificus/bunny/linux-kernel-firecracker:latest
+  path: /kernel
+cmdline: "console=ttyS0"
+`,
+	`{"version": "v0.1", "platforms": {"framework": "unikraft", "monitor": "qemu"}, "kernel": {"from": "unikraft.org/nginx:1.15", "path": "/unikraft/bin/kernel"}}`,
+	`version: v0.1
+platforms: [1, 2]
+kernel: &a
+  from: *a
+`,
+	"{}\n{}",
+	"",
+}
+
+var fuzzContainerfiles = []string{
+	`#syntax=harbor.nbfc.io/nubificus/bunny:latest
+FROM scratch
+COPY test-redis.hvt /unikernel/test-redis.hvt
+LABEL com.urunc.unikernel.binary=/unikernel/test-redis.hvt
+LABEL "com.urunc.unikernel.cmdline"='redis-server /data/conf/redis.conf'
+LABEL "com.urunc.unikernel.unikernelType"="rumprun"
+LABEL "com.urunc.unikernel.hypervisor"="hvt"
+LABEL "com.urunc.unikernel.useDMBlock"="true"
+`,
+	`ARG KERNEL=kernel
+FROM alpine:3.20 AS tools
+FROM scratch AS base
+COPY ${KERNEL} /.boot/kernel
+FROM base
+LABEL com.urunc.unikernel.binary=/.boot/kernel
+LABEL com.urunc.unikernel.hypervisor=qemu
+`,
+	`FROM scratch
+COPY --from=missing foo bar
+LABEL foo
+`,
+	"FROM\n",
+}
+
+func FuzzParseBunnyfile(f *testing.F) {
+	for _, seed := range fuzzBunnyfiles {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		h, err := ParseBunnyfile(input)
+		if err == nil && h == nil {
+			t.Fatalf("ParseBunnyfile returned neither instructions nor an error")
+		}
+	})
+}
+
+func FuzzParseContainerfile(f *testing.F) {
+	for _, seed := range fuzzContainerfiles {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		p, err := ParseContainerfile(context.TODO(), input, nil, fuzzSourceOpts)
+		if err == nil && p == nil {
+			t.Fatalf("ParseContainerfile returned neither instructions nor an error")
+		}
+	})
+}
+
+func FuzzParseFile(f *testing.F) {
+	for _, seed := range fuzzBunnyfiles {
+		f.Add([]byte(seed))
+	}
+	for _, seed := range fuzzContainerfiles {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		p, err := ParseFile(context.TODO(), input, "context", nil, fuzzSourceOpts)
+		if err == nil && p == nil {
+			t.Fatalf("ParseFile returned neither instructions nor an error")
+		}
+	})
+}
@@ -15,6 +15,7 @@
 package hops
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/moby/buildkit/client/llb"
@@ -76,32 +77,33 @@ func (i *GenericInfo) SupportsArch(_ string) bool {
 	return true
 }
 
-func (i *GenericInfo) CreateRootfs(buildContext string) (llb.State, error) {
+func (i *GenericInfo) CreateRootfs(_ context.Context, in BuildInput) (llb.State, error) {
 	switch i.Rootfs.Type {
 	case "initrd":
-		contentState := FilesLLB(i.Rootfs.Includes, buildContext, llb.Scratch())
-		return InitrdLLB(contentState), nil
+		contentState := RootfsFilesLLB(i.Rootfs.Includes, in, llb.Scratch())
+		initrdOpts := append(hardenedOptions(in.Hardened, false), in.Build.NetworkOptions(BuildStepInitrd)...)
+		return InitrdLLB(contentState, in.Owner, initrdOpts...), nil
 	case "raw":
-		return FilesLLB(i.Rootfs.Includes, buildContext, llb.Scratch()), nil
+		return RootfsFilesLLB(i.Rootfs.Includes, in, llb.Scratch()), nil
 	default:
 		// We should never reach this point
 		return llb.Scratch(), fmt.Errorf("Unsupported rootfs type %s", i.Rootfs.Type)
 	}
 }
 
-func (i *GenericInfo) UpdateRootfs(buildContext string) (llb.State, error) {
+func (i *GenericInfo) UpdateRootfs(_ context.Context, in BuildInput) (llb.State, error) {
 	base := llb.Image(i.Rootfs.From)
 	switch i.Rootfs.Type {
 	case "initrd":
 		return llb.Scratch(), fmt.Errorf("Can not update an initrd rootfs")
 	case "raw":
-		return FilesLLB(i.Rootfs.Includes, buildContext, base), nil
+		return RootfsFilesLLB(i.Rootfs.Includes, in, base), nil
 	default:
 		// We should never reach this point
 		return llb.Scratch(), fmt.Errorf("Unsupported rootfs type %s", i.Rootfs.Type)
 	}
 }
 
-func (i *GenericInfo) BuildKernel(_ string) llb.State {
-	return llb.Scratch()
+func (i *GenericInfo) BuildKernel(_ context.Context, _ BuildInput) (llb.State, error) {
+	return llb.Scratch(), nil
 }
@@ -155,7 +155,7 @@ func TestGenericCreateRootfs(t *testing.T) {
 		}
 
 		generic := NewGeneric(plat, rootfs)
-		state, err := generic.CreateRootfs("context")
+		state, err := generic.CreateRootfs(context.TODO(), BuildInput{BuildContext: "context"})
 		require.NoError(t, err)
 		def, err := state.Marshal(context.TODO())
 
@@ -204,22 +204,22 @@ func TestGenericCreateRootfs(t *testing.T) {
 		}
 
 		generic := NewGeneric(plat, rootfs)
-		state, err := generic.CreateRootfs("context")
+		state, err := generic.CreateRootfs(context.TODO(), BuildInput{BuildContext: "context"})
 		require.NoError(t, err)
 		def, err := state.Marshal(context.TODO())
 
 		require.NoError(t, err)
 		m, arr := parseDef(t, def.Def)
-		// We expect 8 output states: 3 for copy files, 4 for initrd and
+		// We expect 7 output states: 2 for copy files, 4 for initrd and
 		// 1 for final output
-		require.Equal(t, 8, len(arr))
+		require.Equal(t, 7, len(arr))
 		// The last one (final output) should just have a single input
 		last := arr[len(arr)-1]
 		require.Equal(t, 1, len(last.Inputs))
 		// which is the exec op of initrd
 		lastInputDgst := last.Inputs[0].Digest
-		require.Equal(t, m[lastInputDgst], arr[6])
-		e := arr[6]
+		require.Equal(t, m[lastInputDgst], arr[5])
+		e := arr[5]
 		exec := e.Op.(*pb.Op_Exec).Exec
 		require.Equal(t, 3, len(exec.Meta.Args))
 		// the exec should have three inputs
@@ -227,24 +227,19 @@ func TestGenericCreateRootfs(t *testing.T) {
 		// the last of which should be the state with the initrd content
 		// that we passed as argument
 		cDgst := e.Inputs[2].Digest
-		require.Equal(t, m[cDgst], arr[5])
-		c1 := arr[5]
-		cf1 := c1.Op.(*pb.Op_File).File
-		require.Equal(t, 1, len(cf1.Actions))
-		cp1 := cf1.Actions[0].Action.(*pb.FileAction_Copy).Copy
-		require.Equal(t, "/ka", cp1.Src)
-		require.Equal(t, "/ka", cp1.Dest)
-		require.Equal(t, 2, len(c1.Inputs))
-		c2Dgst := c1.Inputs[0].Digest
-		require.Equal(t, m[c2Dgst], arr[4])
-		c2 := arr[4]
-		cf2 := c2.Op.(*pb.Op_File).File
-		cp2 := cf2.Actions[0].Action.(*pb.FileAction_Copy).Copy
-		require.Equal(t, "/foo", cp2.Src)
-		require.Equal(t, "/bar", cp2.Dest)
-		require.Equal(t, 1, len(c2.Inputs))
-		require.Equal(t, c2.Inputs[0], c1.Inputs[1])
-		locDgst := c1.Inputs[1].Digest
+		require.Equal(t, m[cDgst], arr[4])
+		c := arr[4]
+		cf := c.Op.(*pb.Op_File).File
+		// Both files get copied by the same operation
+		require.Equal(t, 2, len(cf.Actions))
+		cp1 := cf.Actions[0].Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, "/foo", cp1.Src)
+		require.Equal(t, "/bar", cp1.Dest)
+		cp2 := cf.Actions[1].Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, "/ka", cp2.Src)
+		require.Equal(t, "/ka", cp2.Dest)
+		require.Equal(t, 1, len(c.Inputs))
+		locDgst := c.Inputs[0].Digest
 		require.Equal(t, m[locDgst], arr[3])
 		l := arr[3].Op.(*pb.Op_Source).Source
 		require.Equal(t, "local://context", l.Identifier)
@@ -280,14 +275,15 @@ func TestGenericCreateRootfs(t *testing.T) {
 		}
 
 		generic := NewGeneric(plat, rootfs)
-		_, err := generic.CreateRootfs("context")
+		_, err := generic.CreateRootfs(context.TODO(), BuildInput{BuildContext: "context"})
 		require.ErrorContains(t, err, "Unsupported rootfs type")
 	})
 }
 
 func TestGenericBuildKernel(t *testing.T) {
 	generic := &GenericInfo{}
-	state := generic.BuildKernel("ctx")
+	state, err := generic.BuildKernel(context.TODO(), BuildInput{})
+	require.NoError(t, err)
 	def, err := state.Marshal(context.TODO())
 
 	require.NoError(t, err)
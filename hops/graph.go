@@ -0,0 +1,379 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+const (
+	// The size of a node in the graph of the report, in pixels
+	graphNodeWidth  int = 280
+	graphNodeHeight int = 64
+	// The space between the nodes of the graph, in pixels
+	graphColumnGap int = 80
+	graphRowGap    int = 24
+	// The maximum length of the label of a node
+	graphMaxLabel int = 40
+)
+
+// GraphNode is an operation of the LLB graph of a build
+type GraphNode struct {
+	// The digest of the operation, as in the progress of buildkit
+	Digest digest.Digest `json:"digest"`
+	// The kind of the operation (source, exec, file, merge, diff or build)
+	Kind string `json:"kind"`
+	// What the operation does, e.g. the identifier of a source
+	Label string `json:"label"`
+	// The digests of the inputs of the operation
+	Inputs []digest.Digest `json:"inputs,omitempty"`
+	// If the operation was found in the cache, nil if it is not known
+	Cached *bool `json:"cached,omitempty"`
+	// How long the operation took
+	Duration time.Duration `json:"duration,omitempty"`
+	// The bytes that the operation transferred, e.g. the layers of an image
+	Size int64 `json:"size,omitempty"`
+	// The error of the operation, if it failed
+	Error string `json:"error,omitempty"`
+	// The column and row of the node in the graph
+	column int
+	row    int
+}
+
+// BuildGraph is the graph of the operations of a build, in the order of the
+// LLB definition, so every operation comes after its inputs
+type BuildGraph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	// The digest of the operation with the result of the build
+	Result digest.Digest `json:"result"`
+}
+
+// progressGraphStatus is a status of a vertex in the rawjson progress of
+// buildctl, e.g. the download of a layer
+type progressGraphStatus struct {
+	ID     string        `json:"id"`
+	Vertex digest.Digest `json:"vertex"`
+	Total  int64         `json:"total,omitempty"`
+}
+
+// progressGraphVertex is a vertex in the rawjson progress of buildctl, with
+// the fields that the graph needs
+type progressGraphVertex struct {
+	progressVertex
+	Error string `json:"error,omitempty"`
+}
+
+// fileActionLabel returns what a file action does
+func fileActionLabel(action *pb.FileAction) string {
+	switch {
+	case action.GetCopy() != nil:
+		return "copy " + action.GetCopy().Src + " " + action.GetCopy().Dest
+	case action.GetMkfile() != nil:
+		return "mkfile " + action.GetMkfile().Path
+	case action.GetMkdir() != nil:
+		return "mkdir " + action.GetMkdir().Path
+	case action.GetRm() != nil:
+		return "rm " + action.GetRm().Path
+	case action.GetSymlink() != nil:
+		return "symlink " + action.GetSymlink().Oldpath + " " + action.GetSymlink().Newpath
+	default:
+		return "file"
+	}
+}
+
+// opKindAndLabel returns the kind of the operation and what it does
+func opKindAndLabel(op *pb.Op) (string, string) {
+	switch {
+	case op.GetSource() != nil:
+		return "source", op.GetSource().Identifier
+	case op.GetExec() != nil:
+		return "exec", strings.Join(op.GetExec().GetMeta().GetArgs(), " ")
+	case op.GetFile() != nil:
+		var labels []string
+		for _, action := range op.GetFile().Actions {
+			labels = append(labels, fileActionLabel(action))
+		}
+		return "file", strings.Join(labels, ", ")
+	case op.GetMerge() != nil:
+		return "merge", fmt.Sprintf("merge %d inputs", len(op.Inputs))
+	case op.GetDiff() != nil:
+		return "diff", "diff"
+	case op.GetBuild() != nil:
+		return "build", "build"
+	default:
+		return "unknown", ""
+	}
+}
+
+// NewBuildGraph returns the graph of the operations of the given definition
+func NewBuildGraph(def *llb.Definition) (*BuildGraph, error) {
+	g := &BuildGraph{}
+	nodes := map[digest.Digest]*GraphNode{}
+	for _, dt := range def.Def {
+		var op pb.Op
+		err := op.Unmarshal(dt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal LLB operation: %v", err)
+		}
+		dgst := digest.FromBytes(dt)
+		// The last operation just points to the result
+		if op.Op == nil {
+			if len(op.Inputs) > 0 {
+				g.Result = digest.Digest(op.Inputs[0].Digest)
+			}
+			continue
+		}
+
+		kind, label := opKindAndLabel(&op)
+		if name := def.Metadata[dgst].Description["llb.customname"]; name != "" {
+			label = name
+		}
+		node := &GraphNode{Digest: dgst, Kind: kind, Label: label}
+		for _, input := range op.Inputs {
+			in := digest.Digest(input.Digest)
+			node.Inputs = append(node.Inputs, in)
+			if inNode, ok := nodes[in]; ok && inNode.column+1 > node.column {
+				node.column = inNode.column + 1
+			}
+		}
+		nodes[dgst] = node
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	rows := map[int]int{}
+	for _, node := range g.Nodes {
+		node.row = rows[node.column]
+		rows[node.column]++
+	}
+
+	return g, nil
+}
+
+// AddProgress adds the cache status, the duration, the transferred bytes
+// and the errors of the operations from the progress of the build, in the
+// rawjson format of buildctl
+func (g *BuildGraph) AddProgress(r io.Reader) error {
+	nodes := map[digest.Digest]*GraphNode{}
+	for _, node := range g.Nodes {
+		nodes[node.Digest] = node
+	}
+	sizes := map[digest.Digest]map[string]int64{}
+
+	dec := json.NewDecoder(r)
+	for {
+		var status struct {
+			Vertexes []progressGraphVertex `json:"vertexes,omitempty"`
+			Statuses []progressGraphStatus `json:"statuses,omitempty"`
+		}
+		err := dec.Decode(&status)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to decode build progress: %v", err)
+		}
+		for _, v := range status.Vertexes {
+			node, ok := nodes[v.Digest]
+			if !ok {
+				continue
+			}
+			cached := v.Cached
+			node.Cached = &cached
+			if v.Started != nil && v.Completed != nil {
+				node.Duration = v.Completed.Sub(*v.Started)
+			}
+			node.Error = v.Error
+		}
+		for _, s := range status.Statuses {
+			if _, ok := nodes[s.Vertex]; !ok || s.Total <= 0 {
+				continue
+			}
+			if sizes[s.Vertex] == nil {
+				sizes[s.Vertex] = map[string]int64{}
+			}
+			sizes[s.Vertex][s.ID] = s.Total
+		}
+	}
+	for dgst, ids := range sizes {
+		nodes[dgst].Size = 0
+		for _, size := range ids {
+			nodes[dgst].Size += size
+		}
+	}
+
+	return nil
+}
+
+// graphNodeView is a node as the HTML report draws it
+type graphNodeView struct {
+	*GraphNode
+	X, Y      int
+	ShortName string
+	Status    string
+	Time      string
+	Bytes     string
+}
+
+// graphEdgeView is an edge as the HTML report draws it
+type graphEdgeView struct {
+	X1, Y1, X2, Y2, Mid int
+}
+
+// formatSize returns a human readable form of the given bytes
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+var graphTemplate = template.Must(template.New("graph").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 24px; color: #222; }
+svg text { font-size: 12px; }
+.node rect { stroke: #555; rx: 6; }
+.source rect { fill: #dbeafe; }
+.exec rect { fill: #fde68a; }
+.file rect { fill: #dcfce7; }
+.merge rect, .diff rect, .build rect, .unknown rect { fill: #e5e7eb; }
+.failed rect { stroke: #dc2626; stroke-width: 3; }
+.result rect { stroke-width: 3; }
+.edge { fill: none; stroke: #999; }
+table { border-collapse: collapse; margin-top: 24px; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; font-size: 13px; }
+td.label { font-family: monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Nodes}} operations. Sources are blue, executions yellow and file operations green.</p>
+<svg width="{{.Width}}" height="{{.Height}}" xmlns="http://www.w3.org/2000/svg">
+{{- range .Edges}}
+<path class="edge" d="M{{.X1}},{{.Y1}} C{{.Mid}},{{.Y1}} {{.Mid}},{{.Y2}} {{.X2}},{{.Y2}}"/>
+{{- end}}
+{{- range .Nodes}}
+<g class="node {{.Kind}}{{if .Error}} failed{{end}}{{if eq .Digest $.Result}} result{{end}}">
+<title>{{.Label}}</title>
+<rect x="{{.X}}" y="{{.Y}}" width="{{$.NodeWidth}}" height="{{$.NodeHeight}}"/>
+<text x="{{.X}}" y="{{.Y}}" dx="8" dy="18" font-weight="bold">{{.Kind}}</text>
+<text x="{{.X}}" y="{{.Y}}" dx="8" dy="36">{{.ShortName}}</text>
+<text x="{{.X}}" y="{{.Y}}" dx="8" dy="54">{{.Status}}{{if .Time}}, {{.Time}}{{end}}{{if .Bytes}}, {{.Bytes}}{{end}}</text>
+</g>
+{{- end}}
+</svg>
+<table>
+<tr><th>Kind</th><th>Operation</th><th>Cache</th><th>Duration</th><th>Size</th><th>Digest</th></tr>
+{{- range .Nodes}}
+<tr><td>{{.Kind}}</td><td class="label">{{.Label}}{{if .Error}}<br>Error: {{.Error}}{{end}}</td><td>{{.Status}}</td><td>{{.Time}}</td><td>{{.Bytes}}</td><td>{{.Digest.Encoded | printf "%.12s"}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// HTML returns a self-contained HTML report of the graph, with the given
+// title. The operations are drawn in columns, so every operation is on the
+// right of its inputs.
+func (g *BuildGraph) HTML(title string) ([]byte, error) {
+	positions := map[digest.Digest]*graphNodeView{}
+	var nodes []*graphNodeView
+	width, height := 0, 0
+	for _, node := range g.Nodes {
+		view := &graphNodeView{
+			GraphNode: node,
+			X:         graphColumnGap/2 + node.column*(graphNodeWidth+graphColumnGap),
+			Y:         graphRowGap/2 + node.row*(graphNodeHeight+graphRowGap),
+			ShortName: node.Label,
+			Status:    "not built",
+		}
+		if len(view.ShortName) > graphMaxLabel {
+			view.ShortName = view.ShortName[:graphMaxLabel-3] + "..."
+		}
+		switch {
+		case node.Error != "":
+			view.Status = "failed"
+		case node.Cached != nil && *node.Cached:
+			view.Status = "cached"
+		case node.Cached != nil:
+			view.Status = "executed"
+		}
+		if node.Duration > 0 {
+			view.Time = node.Duration.Round(time.Millisecond).String()
+		}
+		if node.Size > 0 {
+			view.Bytes = formatSize(node.Size)
+		}
+		width = max(width, view.X+graphNodeWidth+graphColumnGap/2)
+		height = max(height, view.Y+graphNodeHeight+graphRowGap/2)
+		positions[node.Digest] = view
+		nodes = append(nodes, view)
+	}
+
+	var edges []graphEdgeView
+	for _, view := range nodes {
+		for _, in := range view.Inputs {
+			from, ok := positions[in]
+			if !ok {
+				continue
+			}
+			edges = append(edges, graphEdgeView{
+				X1:  from.X + graphNodeWidth,
+				Y1:  from.Y + graphNodeHeight/2,
+				X2:  view.X,
+				Y2:  view.Y + graphNodeHeight/2,
+				Mid: view.X - graphColumnGap/2,
+			})
+		}
+	}
+
+	var out bytes.Buffer
+	err := graphTemplate.Execute(&out, struct {
+		Title      string
+		Nodes      []*graphNodeView
+		Edges      []graphEdgeView
+		Result     digest.Digest
+		Width      int
+		Height     int
+		NodeWidth  int
+		NodeHeight int
+	}{title, nodes, edges, g.Result, width, height, graphNodeWidth, graphNodeHeight})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to render the build graph: %v", err)
+	}
+
+	return out.Bytes(), nil
+}
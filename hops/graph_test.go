@@ -0,0 +1,98 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func graphDef(t *testing.T) *llb.Definition {
+	base := llb.Image("harbor.nbfc.io/nubificus/urunc/busybox:latest")
+	built := base.Run(llb.Shlex("make kernel")).Root()
+	final := llb.Scratch().File(llb.Copy(built, "/kernel", "/.boot/kernel"),
+		llb.WithCustomName("copy kernel"))
+	def, err := final.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	return def
+}
+
+func TestGraphNodes(t *testing.T) {
+	g, err := NewBuildGraph(graphDef(t))
+	require.NoError(t, err)
+	require.Len(t, g.Nodes, 3)
+
+	kinds := map[string]*GraphNode{}
+	for _, node := range g.Nodes {
+		kinds[node.Kind] = node
+	}
+	require.Equal(t, "docker-image://harbor.nbfc.io/nubificus/urunc/busybox:latest", kinds["source"].Label)
+	require.Equal(t, "make kernel", kinds["exec"].Label)
+	require.Equal(t, "copy kernel", kinds["file"].Label)
+	require.Equal(t, kinds["file"].Digest, g.Result)
+	require.Equal(t, []digest.Digest{kinds["exec"].Digest}, kinds["file"].Inputs)
+	require.Equal(t, 0, kinds["source"].column)
+	require.Equal(t, 1, kinds["exec"].column)
+	require.Equal(t, 2, kinds["file"].column)
+}
+
+func TestGraphProgress(t *testing.T) {
+	g, err := NewBuildGraph(graphDef(t))
+	require.NoError(t, err)
+	var source, exec *GraphNode
+	for _, node := range g.Nodes {
+		switch node.Kind {
+		case "source":
+			source = node
+		case "exec":
+			exec = node
+		}
+	}
+
+	progress := fmt.Sprintf(`{"vertexes":[{"digest":"%[1]s","name":"source"}]}
+{"vertexes":[{"digest":"%[1]s","name":"source","started":"2026-01-01T00:00:00Z","completed":"2026-01-01T00:00:02Z","cached":true}]}
+{"statuses":[{"id":"layer1","vertex":"%[1]s","total":1024,"current":512},{"id":"layer2","vertex":"%[1]s","total":2048}]}
+{"statuses":[{"id":"layer1","vertex":"%[1]s","total":1024,"current":1024}]}
+{"vertexes":[{"digest":"%[2]s","name":"exec","started":"2026-01-01T00:00:02Z","completed":"2026-01-01T00:00:05Z","error":"exit code: 2"}]}
+{"vertexes":[{"digest":"sha256:0000","name":"Internal: other"}]}
+`, source.Digest, exec.Digest)
+	require.NoError(t, g.AddProgress(strings.NewReader(progress)))
+	require.NotNil(t, source.Cached)
+	require.True(t, *source.Cached)
+	require.Equal(t, int64(3072), source.Size)
+	require.Equal(t, "2s", source.Duration.String())
+	require.NotNil(t, exec.Cached)
+	require.False(t, *exec.Cached)
+	require.Equal(t, "exit code: 2", exec.Error)
+
+	report, err := g.HTML("Build graph of <bunnyfile>")
+	require.NoError(t, err)
+	html := string(report)
+	require.Contains(t, html, "<title>Build graph of &lt;bunnyfile&gt;</title>")
+	require.Contains(t, html, "cached, 2s, 3.0 KiB")
+	require.Contains(t, html, "Error: exit code: 2")
+	require.Contains(t, html, `class="node exec failed"`)
+	require.Contains(t, html, `class="node file result"`)
+	require.Equal(t, 2, strings.Count(html, `class="edge"`))
+
+	require.ErrorContains(t, g.AddProgress(strings.NewReader("foo")), "Failed to decode build progress")
+}
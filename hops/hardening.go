@@ -0,0 +1,151 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+const (
+	// The directory for temporary files of tool execs with a read-only root
+	hardenedTmpDir string = "/tmp"
+	// The writable output of tool execs that only check something
+	hardenedOutDir string = "/.out"
+)
+
+// hardenedOptions returns the options of a tool exec in hardened mode: a
+// read-only root filesystem, a tmpfs for temporary files and, unless the exec
+// is network-bound, no network.
+func hardenedOptions(hardened bool, network bool) []llb.RunOption {
+	if !hardened {
+		return nil
+	}
+	opts := []llb.RunOption{
+		llb.ReadonlyRootFS(),
+		llb.AddMount(hardenedTmpDir, llb.Scratch(), llb.Tmpfs()),
+	}
+	if !network {
+		opts = append(opts, llb.Network(llb.NetModeNone))
+	}
+
+	return opts
+}
+
+// execResult returns the state that makes the given exec part of the
+// definition. A read-only root has no output, so in hardened mode an empty
+// writable mount takes its place.
+func execResult(exec llb.ExecState, hardened bool) llb.State {
+	if !hardened {
+		return exec.Root()
+	}
+
+	return exec.AddMount(hardenedOutDir, llb.Scratch())
+}
+
+// toolImages returns the identifiers of the image sources that exec
+// operations of the definition use as their root filesystem.
+func toolImages(def *llb.Definition) (map[string]bool, error) {
+	ops := map[string]*pb.Op{}
+	for _, dt := range def.Def {
+		var op pb.Op
+		err := op.Unmarshal(dt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal LLB operation: %v", err)
+		}
+		ops[string(digest.FromBytes(dt))] = &op
+	}
+
+	images := map[string]bool{}
+	for _, op := range ops {
+		exec := op.GetExec()
+		if exec == nil {
+			continue
+		}
+		for _, m := range exec.Mounts {
+			if m.Dest != "/" || m.Input < 0 || int(m.Input) >= len(op.Inputs) {
+				continue
+			}
+			// Follow the file operations on top of the image (e.g.
+			// creating directories) up to the image itself
+			root := ops[op.Inputs[m.Input].Digest]
+			for root != nil && root.GetFile() != nil && len(root.Inputs) > 0 {
+				root = ops[root.Inputs[0].Digest]
+			}
+			if root != nil && root.GetSource() != nil {
+				images[root.GetSource().Identifier] = true
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// PinToolImages pins the images that the exec operations of the definition
+// run from to their current digest, so the build records exactly which tools
+// it used. Images that are already pinned are left as is. The digests of all
+// the tool images get recorded in tools, if it is not nil, which also rejects
+// the digests that are not in its allowlist.
+func PinToolImages(ctx context.Context, def *llb.Definition, resolver llb.ImageMetaResolver, arch string, tools *ToolDigests) (*llb.Definition, error) {
+	images, err := toolImages(def)
+	if err != nil {
+		return nil, err
+	}
+	platform, err := BuildPlatform(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	return rewriteSources(def, func(src *pb.SourceOp) (*llb.Definition, error) {
+		if !images[src.Identifier] || !strings.HasPrefix(src.Identifier, dockerImageScheme) {
+			return nil, nil
+		}
+		ref := strings.TrimPrefix(src.Identifier, dockerImageScheme)
+		if name, pinned, ok := strings.Cut(ref, "@"); ok {
+			if tools == nil {
+				return nil, nil
+			}
+			dgst, err := digest.Parse(pinned)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid digest of %s: %v", ref, err)
+			}
+			return nil, tools.Record(name, dgst)
+		}
+		_, dgst, _, err := resolver.ResolveImageConfig(ctx, ref, sourceresolver.Opt{
+			LogName: "pinning tool image " + ref,
+			ImageOpt: &sourceresolver.ResolveImageOpt{
+				Platform: &platform,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resolve the digest of %s: %w", ref, err)
+		}
+		if tools != nil {
+			err = tools.Record(ref, dgst)
+			if err != nil {
+				return nil, err
+			}
+		}
+		src.Identifier = dockerImageScheme + ref + "@" + dgst.String()
+
+		return nil, nil
+	})
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+const pinnedDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+type pinResolver struct {
+	refs []string
+	err  error
+}
+
+func (r *pinResolver) ResolveImageConfig(ctx context.Context, ref string, _ sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", nil, err
+	}
+	r.refs = append(r.refs, ref)
+	return ref, digest.Digest(pinnedDigest), []byte("{}"), r.err
+}
+
+// execMounts returns the mounts of an exec operation indexed by their
+// destination.
+func execMounts(exec *pb.ExecOp) map[string]*pb.Mount {
+	mounts := map[string]*pb.Mount{}
+	for _, mount := range exec.Mounts {
+		mounts[mount.Dest] = mount
+	}
+
+	return mounts
+}
+
+// requireHardened checks that the exec runs with a read-only root and a
+// tmpfs for temporary files.
+func requireHardened(t *testing.T, exec *pb.ExecOp) {
+	mounts := execMounts(exec)
+	require.True(t, mounts["/"].Readonly)
+	require.Contains(t, mounts, "/tmp")
+	require.Equal(t, pb.MountType_TMPFS, mounts["/tmp"].MountType)
+}
+
+func TestHardenedOptions(t *testing.T) {
+	require.Nil(t, hardenedOptions(false, false))
+	require.Len(t, hardenedOptions(true, true), 2)
+	require.Len(t, hardenedOptions(true, false), 3)
+}
+
+func TestHardenedInitrd(t *testing.T) {
+	t.Run("Not hardened", func(t *testing.T) {
+		exec, mounts, _ := kraftExec(t, InitrdLLB(llb.Local("context"), Owner{}, hardenedOptions(false, false)...))
+		require.False(t, mounts["/"].Readonly)
+		require.Equal(t, pb.NetMode_NONE, exec.Network)
+	})
+	t.Run("Hardened", func(t *testing.T) {
+		exec, _, _ := kraftExec(t, InitrdLLB(llb.Local("context"), Owner{}, hardenedOptions(true, false)...))
+		requireHardened(t, exec)
+		require.Equal(t, pb.NetMode_NONE, exec.Network)
+	})
+}
+
+func TestHardenedKraftBuild(t *testing.T) {
+	unikraft := &UnikraftInfo{Arch: "x86_64"}
+	state, err := unikraft.BuildKernel(context.TODO(), BuildInput{
+		BuildContext: "context",
+		Monitor:      "qemu",
+		Kernel:       Kernel{From: KernelFromBuild, Source: "app"},
+		Hardened:     true,
+	})
+	require.NoError(t, err)
+	exec, _, _ := kraftExec(t, state)
+	requireHardened(t, exec)
+	// Fetching the sources of the kernel needs network
+	require.NotEqual(t, pb.NetMode_NONE, exec.Network)
+}
+
+func TestHardenedSmokeTest(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("Smoke tests are not supported in", runtime.GOARCH)
+	}
+	instr := PackInstructions{
+		Annots: map[string]string{
+			"com.urunc.unikernel.hypervisor": "qemu",
+			"com.urunc.unikernel.binary":     DefaultKernelPath,
+		},
+		Test:    SmokeTest{Marker: "Hello"},
+		Sources: SourceOpts{Hardened: true},
+	}
+	def, err := SmokeTestLLB(context.TODO(), instr, llb.Image("foo"))
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var exec *pb.ExecOp
+	for _, op := range arr {
+		if e := op.GetExec(); e != nil {
+			exec = e
+		}
+	}
+	require.NotNil(t, exec)
+	requireHardened(t, exec)
+	require.Equal(t, pb.NetMode_NONE, exec.Network)
+}
+
+func TestHardenedPinToolImages(t *testing.T) {
+	tool := llb.Image("alpine:3.20").
+		Run(llb.Shlex("true"),
+			llb.AddMount("/data", llb.Image("busybox"), llb.Readonly)).Root()
+	pinned := llb.Image("debian@" + pinnedDigest).
+		File(llb.Mkdir("/out", 0755)).
+		Run(llb.Shlex("true")).Root()
+	st := llb.Merge([]llb.State{tool, pinned})
+
+	t.Run("Not hardened", func(t *testing.T) {
+		r := &pinResolver{}
+		def, err := marshalState(context.TODO(), st, SourceOpts{Resolver: r})
+		require.NoError(t, err)
+		require.Empty(t, r.refs)
+		require.Contains(t, sourceIdentifiers(t, def), "docker-image://docker.io/library/alpine:3.20")
+	})
+	t.Run("Hardened", func(t *testing.T) {
+		r := &pinResolver{}
+		def, err := marshalState(context.TODO(), st, SourceOpts{Hardened: true, Resolver: r})
+		require.NoError(t, err)
+		require.Equal(t, []string{"docker.io/library/alpine:3.20"}, r.refs)
+		ids := sourceIdentifiers(t, def)
+		require.Contains(t, ids, "docker-image://docker.io/library/alpine:3.20@"+pinnedDigest)
+		// Images that are not the root of an exec stay as they are
+		require.Contains(t, ids, "docker-image://docker.io/library/busybox:latest")
+		require.Contains(t, ids, "docker-image://docker.io/library/debian@"+pinnedDigest)
+	})
+	t.Run("Resolve error", func(t *testing.T) {
+		r := &pinResolver{err: fmt.Errorf("not found")}
+		_, err := marshalState(context.TODO(), st, SourceOpts{Hardened: true, Resolver: r})
+		require.ErrorContains(t, err, "Failed to resolve the digest of docker.io/library/alpine:3.20")
+	})
+}
+
+// sourceIdentifiers returns the identifiers of all the sources of the
+// definition.
+func sourceIdentifiers(t *testing.T, def *llb.Definition) []string {
+	_, arr := parseDef(t, def.Def)
+	var ids []string
+	for _, op := range arr {
+		if src := op.GetSource(); src != nil {
+			ids = append(ids, src.Identifier)
+		}
+	}
+
+	return ids
+}
+
+func TestHardenedKernelCheck(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("Kernel inspection is not supported in", runtime.GOARCH)
+	}
+	check := KernelCheck{
+		Source:  llb.Image("unikraft.org/nginx:latest"),
+		Path:    "/unikraft/bin/kernel",
+		Monitor: "qemu",
+		Arch:    "amd64",
+	}
+	def, err := KernelCheckLLB(context.TODO(), PackInstructions{
+		KernelCheck: &check,
+		Sources:     SourceOpts{Hardened: true},
+	})
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var exec *pb.ExecOp
+	for _, op := range arr {
+		if e := op.GetExec(); e != nil {
+			exec = e
+		}
+	}
+	require.NotNil(t, exec)
+	requireHardened(t, exec)
+	require.Equal(t, pb.NetMode_NONE, exec.Network)
+	require.Contains(t, execMounts(exec), "/.out")
+}
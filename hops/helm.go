@@ -0,0 +1,89 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/distribution/reference"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The key of the digest of the image in the metadata file of buildctl
+	metadataImageDigest string = "containerimage.digest"
+)
+
+// HelmImage is the image section of the Helm values
+type HelmImage struct {
+	Repository string `yaml:"repository"`
+	Tag        string `yaml:"tag"`
+	Digest     string `yaml:"digest,omitempty"`
+}
+
+// HelmValues are the values that charts of urunc deployments need for an
+// image that bunny built
+type HelmValues struct {
+	Image            HelmImage                    `yaml:"image"`
+	RuntimeClassName string                       `yaml:"runtimeClassName"`
+	Monitor          string                       `yaml:"monitor,omitempty"`
+	Resources        map[string]map[string]string `yaml:"resources,omitempty"`
+}
+
+// ImageDigestFromMetadata returns the digest of the image in the metadata
+// file that buildctl writes with --metadata-file, or an empty string if the
+// output of the build was not an image.
+func ImageDigestFromMetadata(data []byte) (string, error) {
+	var metadata map[string]any
+	err := json.Unmarshal(data, &metadata)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse the metadata of the build: %v", err)
+	}
+	digest, _ := metadata[metadataImageDigest].(string)
+
+	return digest, nil
+}
+
+// NewHelmValues returns the Helm values of the given image, with the digest
+// of the built image (if any), the monitor and the resources of the
+// unikernel.
+func NewHelmValues(image string, digest string, monitor string, res Resources) (HelmValues, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return HelmValues{}, fmt.Errorf("Failed to parse image name %s: %v", image, err)
+	}
+
+	return HelmValues{
+		Image: HelmImage{
+			Repository: reference.FamiliarName(named),
+			Tag:        imageTag(named),
+			Digest:     digest,
+		},
+		RuntimeClassName: kubernetesRuntimeClass,
+		Monitor:          monitor,
+		Resources:        kubernetesResources(res),
+	}, nil
+}
+
+// YAML returns the values in the form of a values.yaml file
+func (v HelmValues) YAML() ([]byte, error) {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal the Helm values: %v", err)
+	}
+
+	return out, nil
+}
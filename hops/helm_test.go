@@ -0,0 +1,77 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHelmValues(t *testing.T) {
+	t.Run("Image with digest", func(t *testing.T) {
+		values, err := NewHelmValues("registry.local/apps/nginx:v1", "sha256:0123", "qemu", Resources{Memory: "256Mi"})
+		require.NoError(t, err)
+		out, err := values.YAML()
+		require.NoError(t, err)
+		require.YAMLEq(t, `
+image:
+  repository: registry.local/apps/nginx
+  tag: v1
+  digest: sha256:0123
+runtimeClassName: urunc
+monitor: qemu
+resources:
+  requests:
+    memory: 256Mi
+  limits:
+    memory: 256Mi
+`, string(out))
+	})
+	t.Run("Docker Hub image without tag", func(t *testing.T) {
+		values, err := NewHelmValues("nginx", "", "firecracker", Resources{})
+		require.NoError(t, err)
+		out, err := values.YAML()
+		require.NoError(t, err)
+		require.YAMLEq(t, `
+image:
+  repository: nginx
+  tag: latest
+runtimeClassName: urunc
+monitor: firecracker
+`, string(out))
+	})
+	t.Run("Invalid image", func(t *testing.T) {
+		_, err := NewHelmValues("Invalid Image", "", "qemu", Resources{})
+		require.ErrorContains(t, err, "Failed to parse image name")
+	})
+}
+
+func TestHelmImageDigestFromMetadata(t *testing.T) {
+	digest, err := ImageDigestFromMetadata([]byte(`{
+  "containerimage.config.digest": "sha256:abcd",
+  "containerimage.digest": "sha256:0123",
+  "image.name": "registry.local/apps/nginx:v1"
+}`))
+	require.NoError(t, err)
+	require.Equal(t, "sha256:0123", digest)
+
+	digest, err = ImageDigestFromMetadata([]byte(`{"buildx.build.ref": "foo"}`))
+	require.NoError(t, err)
+	require.Empty(t, digest)
+
+	_, err = ImageDigestFromMetadata([]byte("foo"))
+	require.ErrorContains(t, err, "Failed to parse the metadata of the build")
+}
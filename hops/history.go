@@ -0,0 +1,101 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// HistoryComment is the comment of the history entries that bunny adds to
+// the final image
+const HistoryComment string = "bunny"
+
+// copyCreatedBy describes a copy of the final image, like a COPY instruction
+// of a Containerfile, with the field of the bunnyfile that it comes from.
+func copyCreatedBy(c PackCopies) string {
+	name := c.Name
+	if name == "" {
+		name = "copy"
+	}
+	from := ""
+	if c.From != "" {
+		from = "--from=" + c.From + " "
+	}
+
+	return fmt.Sprintf("bunny %s: COPY %s%s %s", name, from, c.SrcPath, c.DstPath)
+}
+
+// History returns the entries of the history of the final image for the
+// steps of packing, in the order that PackLLB creates their layers: the
+// copies, the copies of the flavors, the commands of the postPack hooks and
+// urunc.json. The entries get the creation time of the image, if it is set.
+func (i PackInstructions) History() []ocispecs.History {
+	var history []ocispecs.History
+	add := func(createdBy string) {
+		history = append(history, ocispecs.History{
+			Created:   i.Img.Created,
+			CreatedBy: createdBy,
+			Comment:   HistoryComment,
+		})
+	}
+
+	for _, c := range i.Copies {
+		add(copyCreatedBy(c))
+	}
+	for _, c := range i.FlavorCopies {
+		add(copyCreatedBy(c))
+	}
+	for _, command := range i.Hooks.PostPack {
+		add(fmt.Sprintf("bunny hooks.postPack: RUN %s", command))
+	}
+	add(fmt.Sprintf("bunny: ADD urunc.json %s", uruncJSONPath))
+
+	return history
+}
+
+// alignHistory makes the history of the config of a base image describe
+// exactly its layers. The exporters of buildkit match the entries that are not
+// empty to the layers in order, so a base with fewer entries than layers would
+// shift the entries of packing to the layers of the base. The missing entries
+// get added and the extra ones get marked as empty.
+func alignHistory(img ocispecs.Image) ocispecs.Image {
+	layers := len(img.RootFS.DiffIDs)
+	history := make([]ocispecs.History, 0, len(img.History))
+	for _, entry := range img.History {
+		if !entry.EmptyLayer {
+			if layers == 0 {
+				entry.EmptyLayer = true
+			} else {
+				layers--
+			}
+		}
+		history = append(history, entry)
+	}
+	for ; layers > 0; layers-- {
+		history = append(history, ocispecs.History{})
+	}
+	img.History = history
+
+	return img
+}
+
+// SetHistory appends the history of the steps of packing to the history of
+// the base image, so that the history describes every layer of the final
+// image.
+func (i *PackInstructions) SetHistory() {
+	i.Img.History = append(i.Img.History, i.History()...)
+}
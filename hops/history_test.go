@@ -0,0 +1,93 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	h := targetHops()
+	h.Flavors = []string{FlavorKraftkit}
+	h.Hooks = Hooks{Image: "alpine:3.20", PostPack: []string{"touch .boot/ready"}}
+	instr, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+
+	// The image of the rootfs is the base, so it has no copy
+	var createdBy []string
+	for _, entry := range instr.History() {
+		require.Equal(t, HistoryComment, entry.Comment)
+		require.False(t, entry.EmptyLayer)
+		require.Nil(t, entry.Created)
+		createdBy = append(createdBy, entry.CreatedBy)
+	}
+	require.Equal(t, []string{
+		"bunny kernel: COPY --from=local build/app_qemu-x86_64 /.boot/kernel",
+		"bunny kraftkit flavor: COPY /.boot/kernel /unikraft/bin/kernel",
+		"bunny kraftkit flavor: COPY /rootfs.cpio /unikraft/bin/initrd",
+		"bunny hooks.postPack: RUN touch .boot/ready",
+		"bunny: ADD urunc.json /urunc.json",
+	}, createdBy)
+
+	t.Run("Base history", func(t *testing.T) {
+		instr.Img.History = []ocispecs.History{{CreatedBy: "/bin/sh -c #(nop) ADD file:abc in /"}}
+		instr.SetHistory()
+		require.Len(t, instr.Img.History, 6)
+		require.Equal(t, "/bin/sh -c #(nop) ADD file:abc in /", instr.Img.History[0].CreatedBy)
+		require.Equal(t, "bunny: ADD urunc.json /urunc.json", instr.Img.History[5].CreatedBy)
+	})
+	t.Run("Unnamed copy", func(t *testing.T) {
+		c := PackCopies{SrcPath: "/a", DstPath: "/b"}
+		require.Equal(t, "bunny copy: COPY /a /b", copyCreatedBy(c))
+	})
+}
+
+func TestHistoryAlign(t *testing.T) {
+	layers := func(n int) ocispecs.RootFS {
+		rootfs := ocispecs.RootFS{Type: "layers"}
+		for i := 0; i < n; i++ {
+			rootfs.DiffIDs = append(rootfs.DiffIDs, digest.FromString(string(rune('a'+i))))
+		}
+		return rootfs
+	}
+	entry := func(createdBy string, empty bool) ocispecs.History {
+		return ocispecs.History{CreatedBy: createdBy, EmptyLayer: empty}
+	}
+
+	t.Run("Aligned", func(t *testing.T) {
+		history := []ocispecs.History{entry("ADD", false), entry("ENV", true), entry("RUN", false)}
+		img := alignHistory(ocispecs.Image{RootFS: layers(2), History: history})
+		require.Equal(t, history, img.History)
+	})
+	t.Run("Missing entries", func(t *testing.T) {
+		img := alignHistory(ocispecs.Image{RootFS: layers(3), History: []ocispecs.History{entry("ADD", false)}})
+		require.Equal(t, []ocispecs.History{entry("ADD", false), {}, {}}, img.History)
+	})
+	t.Run("Extra entries", func(t *testing.T) {
+		history := []ocispecs.History{entry("ADD", false), entry("RUN", false)}
+		img := alignHistory(ocispecs.Image{RootFS: layers(1), History: history})
+		require.Equal(t, []ocispecs.History{entry("ADD", false), entry("RUN", true)}, img.History)
+		// The history of the given config stays as it was
+		require.False(t, history[1].EmptyLayer)
+	})
+	t.Run("Scratch", func(t *testing.T) {
+		require.Empty(t, alignHistory(ocispecs.Image{}).History)
+	})
+}
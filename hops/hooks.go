@@ -0,0 +1,69 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+const (
+	// The directory where the hooks find the files of the rootfs, or the
+	// final image for the hooks after packing
+	HooksRootfsDir string = "/rootfs"
+)
+
+// Hooks defines commands that modify the rootfs or the final image, for
+// changes that the rest of the bunnyfile can not describe (e.g. running
+// ldconfig or generating caches)
+type Hooks struct {
+	// The image where the commands run, which should have a shell
+	Image string `yaml:"image"`
+	// The commands that run on the files of the rootfs, before they get
+	// packed (e.g. in an initrd)
+	PrePack []string `yaml:"prePack"`
+	// The commands that run on the final image, after packing
+	PostPack []string `yaml:"postPack"`
+}
+
+// Enabled returns true if there are commands to run
+func (h Hooks) Enabled() bool {
+	return len(h.PrePack) > 0 || len(h.PostPack) > 0
+}
+
+// HooksLLB runs the given commands with sh in the image of the hooks, one
+// after the other, with the given state mounted writable in HooksRootfsDir,
+// which is also the working directory. It returns the state with the changes
+// of the commands. The commands run without network, unless the network mode
+// of the hooks step is set, and any extra options are passed to each exec.
+func HooksLLB(st llb.State, image string, commands []string, name string, opts ...llb.RunOption) llb.State {
+	tool := llb.Image(image).Dir(HooksRootfsDir)
+	for _, command := range commands {
+		runOpts := append([]llb.RunOption{
+			llb.Args([]string{"/bin/sh", "-c", command}),
+			llb.Network(llb.NetModeNone),
+			llb.WithCustomName(fmt.Sprintf("Hook %s: %s", name, command)),
+		}, opts...)
+		st = tool.Run(runOpts...).AddMount(HooksRootfsDir, st)
+	}
+
+	return st
+}
+
+// hooksOptions returns the options of the execs of the hooks
+func hooksOptions(hardened bool, build BuildOptions) []llb.RunOption {
+	return append(hardenedOptions(hardened, false), build.NetworkOptions(BuildStepHooks)...)
+}
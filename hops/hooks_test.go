@@ -0,0 +1,131 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+// hookExecs returns the execs of the hooks in the given state, in the order
+// of the definition
+func hookExecs(t *testing.T, def *llb.Definition) []*pb.ExecOp {
+	_, arr := parseDef(t, def.Def)
+	var execs []*pb.ExecOp
+	for _, op := range arr {
+		exec := op.GetExec()
+		if exec == nil || exec.Meta.Cwd != HooksRootfsDir {
+			continue
+		}
+		execs = append(execs, exec)
+	}
+
+	return execs
+}
+
+func TestHooksValidate(t *testing.T) {
+	rootfs := Rootfs{From: "scratch", Includes: []FileToInclude{{From: "local", Src: "app", Dst: "/app"}}}
+	require.NoError(t, ValidateHooks(Hooks{}, Rootfs{}))
+	require.NoError(t, ValidateHooks(Hooks{Image: "alpine:3.20", PrePack: []string{"ldconfig -r ."}}, rootfs))
+	require.NoError(t, ValidateHooks(Hooks{Image: "alpine:3.20", PostPack: []string{"true"}}, Rootfs{}))
+
+	tests := []struct {
+		name      string
+		hooks     Hooks
+		rootfs    Rootfs
+		errorText string
+	}{
+		{"Image without commands", Hooks{Image: "alpine"}, rootfs, "The hooks field requires prePack or postPack commands"},
+		{"Missing image", Hooks{PostPack: []string{"true"}}, rootfs, "The image field of hooks is necessary"},
+		{"Local image", Hooks{Image: "local", PostPack: []string{"true"}}, rootfs, "The image field of hooks should be an OCI image"},
+		{"Invalid image", Hooks{Image: "Alpine", PostPack: []string{"true"}}, rootfs, `Invalid image field of hooks: Invalid image reference "Alpine"`},
+		{"Empty command", Hooks{Image: "alpine", PrePack: []string{" "}}, rootfs, "The commands of hooks can not be empty"},
+		{"PrePack without includes", Hooks{Image: "alpine", PrePack: []string{"true"}}, Rootfs{From: "local", Path: "rootfs"}, "The prePack hooks require a rootfs with files to include"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorContains(t, ValidateHooks(tc.hooks, tc.rootfs), tc.errorText)
+		})
+	}
+}
+
+func TestHooksLLB(t *testing.T) {
+	st := HooksLLB(llb.Image("foo"), "alpine:3.20", []string{"ldconfig -r .", "rm -rf var/cache"}, "prePack")
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+	execs := hookExecs(t, def)
+	require.Len(t, execs, 2)
+	for i, command := range []string{"ldconfig -r .", "rm -rf var/cache"} {
+		require.Equal(t, []string{"/bin/sh", "-c", command}, execs[i].Meta.Args)
+		require.Equal(t, HooksRootfsDir, execs[i].Meta.Cwd)
+		require.Equal(t, pb.NetMode_NONE, execs[i].Network)
+		mount := execMounts(execs[i])[HooksRootfsDir]
+		require.NotNil(t, mount)
+		require.False(t, mount.Readonly)
+	}
+
+	t.Run("Network and hardened", func(t *testing.T) {
+		opts := hooksOptions(true, BuildOptions{Network: map[string]string{BuildStepHooks: "host"}})
+		def, err := HooksLLB(llb.Scratch(), "alpine", []string{"apk fetch foo"}, "postPack", opts...).Marshal(context.TODO())
+		require.NoError(t, err)
+		execs := hookExecs(t, def)
+		require.Len(t, execs, 1)
+		requireHardened(t, execs[0])
+		require.Equal(t, pb.NetMode_HOST, execs[0].Network)
+	})
+}
+
+func TestHooksPack(t *testing.T) {
+	h := &Hops{
+		Platform: Platform{Framework: "linux", Monitor: "qemu"},
+		Kernel:   Kernel{From: "local", Path: "kernel"},
+		Rootfs: Rootfs{
+			From:     "scratch",
+			Type:     "initrd",
+			Includes: []FileToInclude{{From: "local", Src: "app", Dst: "/app"}},
+		},
+		Cmd: []string{"/app"},
+		Hooks: Hooks{
+			Image:    "alpine:3.20",
+			PrePack:  []string{"ldconfig -r ."},
+			PostPack: []string{"touch .boot/ready"},
+		},
+	}
+	instr, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	def, err := PackLLB(context.TODO(), *instr)
+	require.NoError(t, err)
+	var args [][]string
+	for _, exec := range hookExecs(t, def) {
+		args = append(args, exec.Meta.Args)
+	}
+	require.ElementsMatch(t, [][]string{
+		{"/bin/sh", "-c", "ldconfig -r ."},
+		{"/bin/sh", "-c", "touch .boot/ready"},
+	}, args)
+
+	t.Run("Without hooks", func(t *testing.T) {
+		h.Hooks = Hooks{}
+		instr, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		def, err := PackLLB(context.TODO(), *instr)
+		require.NoError(t, err)
+		require.Empty(t, hookExecs(t, def))
+	})
+}
@@ -19,19 +19,48 @@ import (
 	"encoding/json"
 	"fmt"
 	"runtime"
-	"strings"
 
 	"github.com/distribution/reference"
+	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/client/llb/sourceresolver"
 	"github.com/moby/buildkit/exporter/containerimage/exptypes"
 	"github.com/moby/buildkit/frontend/gateway/client"
+	digest "github.com/opencontainers/go-digest"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-func getBaseConfig(ctx context.Context, c client.Client, ref string, mon string) (ocispecs.Image, error) {
+const (
+	BunnyVersionAnnotation     string = "io.bunny.version"
+	HopsVersionAnnotation      string = "io.bunny.hops.version"
+	BunnyfileVersionAnnotation string = "io.bunny.bunnyfile.version"
+	LLBDigestAnnotation        string = "io.bunny.llb.digest"
+)
+
+// configPlatform returns the platform to resolve the config of an image for,
+// which has to be the platform that its state gets pulled for, or else the
+// layers of the config would not be the layers of the image. Images of
+// unikraft.org get the platform of the monitor and the architecture (see
+// ResolveSourceRef) and the rest the platform of the buildkit worker.
+func (o SourceOpts) configPlatform(ref string, mon string, arch string) (ocispecs.Platform, error) {
+	if isUnikraftRef(ref) {
+		return ocispecs.Platform{
+			OS:           monitorPlatformOS(mon),
+			Architecture: normalizeArch(arch),
+		}, nil
+	}
+
+	return BuildPlatform(o.Arch)
+}
+
+func getBaseConfig(ctx context.Context, c client.Client, ref string, plat ocispecs.Platform) (ocispecs.Image, error) {
 	if ref == "" || ref == "scratch" {
 		return ocispecs.Image{}, nil
 	}
+	// Without a client (e.g. when printing the LLB), the config of the base
+	// can not be fetched
+	if c == nil {
+		return ocispecs.Image{}, nil
+	}
 
 	baseRef, err := reference.ParseNormalizedNamed(ref)
 	if err != nil {
@@ -39,15 +68,6 @@ func getBaseConfig(ctx context.Context, c client.Client, ref string, mon string)
 	}
 	baseImageName := reference.TagNameOnly(baseRef).String()
 
-	plat := ocispecs.Platform{
-		Architecture: runtime.GOARCH,
-	}
-	if strings.HasPrefix(ref, unikraftHub) {
-		// Define the platform to qemu/amd64 so we can pull unikraft images
-		plat.OS = mon
-	} else {
-		plat.OS = "linux"
-	}
 	_, _, config, err := c.ResolveImageConfig(ctx, baseImageName,
 		sourceresolver.Opt{
 			LogName: "resolving image metadata for " + baseImageName,
@@ -74,10 +94,10 @@ func updateImage(img ocispecs.Image, annots map[string]string) ocispecs.Image {
 		OS:           "linux",
 	}
 
-	if img.RootFS.Type == "" {
-		img.RootFS = ocispecs.RootFS{
-			Type: "layers",
-		}
+	// The spec requires the list of the layers, even if it is empty
+	img.RootFS.Type = "layers"
+	if img.RootFS.DiffIDs == nil {
+		img.RootFS.DiffIDs = []digest.Digest{}
 	}
 
 	if img.Config.Labels == nil {
@@ -108,3 +128,39 @@ func ApplyConfig(res *client.Result, annots map[string]string, image ocispecs.Im
 
 	return nil
 }
+
+// SetBuildInfo adds annotations and labels with the version of bunny, the
+// version of the bunnyfile and the digest of the LLB definition that produced
+// the image. Since the digest is known only after the creation of the
+// LLB definition, these annotations do not reach urunc.json.
+func (i *PackInstructions) SetBuildInfo(bunnyVersion string, dt *llb.Definition) error {
+	dgst, err := dt.Head()
+	if err != nil {
+		return fmt.Errorf("Failed to get digest of LLB definition: %v", err)
+	}
+	if bunnyVersion == "" {
+		bunnyVersion = "unknown"
+	}
+
+	info := map[string]string{
+		BunnyVersionAnnotation: bunnyVersion,
+		HopsVersionAnnotation:  Version,
+		LLBDigestAnnotation:    dgst.String(),
+	}
+	if i.FileVersion != "" {
+		info[BunnyfileVersionAnnotation] = i.FileVersion
+	}
+
+	if i.Annots == nil {
+		i.Annots = make(map[string]string)
+	}
+	if i.Img.Config.Labels == nil {
+		i.Img.Config.Labels = make(map[string]string)
+	}
+	for k, v := range info {
+		i.Annots[k] = v
+		i.Img.Config.Labels[k] = v
+	}
+
+	return nil
+}
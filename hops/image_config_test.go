@@ -0,0 +1,135 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageConfigSetBuildInfo(t *testing.T) {
+	t.Run("Bunnyfile", func(t *testing.T) {
+		instr := PackInstructions{
+			Base:        llb.Scratch(),
+			Annots:      map[string]string{"foo": "bar"},
+			FileVersion: "0.1",
+		}
+		dt, err := PackLLB(context.TODO(), instr)
+		require.NoError(t, err)
+		head, err := dt.Head()
+		require.NoError(t, err)
+
+		err = instr.SetBuildInfo("v1.2.3", dt)
+		require.NoError(t, err)
+		for _, m := range []map[string]string{instr.Annots, instr.Img.Config.Labels} {
+			require.Equal(t, "v1.2.3", m[BunnyVersionAnnotation])
+			require.Equal(t, Version, m[HopsVersionAnnotation])
+			require.Equal(t, "0.1", m[BunnyfileVersionAnnotation])
+			require.Equal(t, head.String(), m[LLBDigestAnnotation])
+		}
+		require.Equal(t, "bar", instr.Annots["foo"])
+	})
+	t.Run("Containerfile without version", func(t *testing.T) {
+		instr := PackInstructions{
+			Base: llb.Scratch(),
+		}
+		dt, err := PackLLB(context.TODO(), instr)
+		require.NoError(t, err)
+
+		err = instr.SetBuildInfo("", dt)
+		require.NoError(t, err)
+		require.Equal(t, "unknown", instr.Annots[BunnyVersionAnnotation])
+		require.NotContains(t, instr.Annots, BunnyfileVersionAnnotation)
+		require.NotEmpty(t, instr.Annots[LLBDigestAnnotation])
+	})
+	t.Run("Same instructions same digest", func(t *testing.T) {
+		instr1 := PackInstructions{Base: llb.Image("foo"), Annots: map[string]string{"a": "b"}}
+		instr2 := PackInstructions{Base: llb.Image("foo"), Annots: map[string]string{"a": "b"}}
+		dt1, err := PackLLB(context.TODO(), instr1)
+		require.NoError(t, err)
+		dt2, err := PackLLB(context.TODO(), instr2)
+		require.NoError(t, err)
+		require.NoError(t, instr1.SetBuildInfo("v1", dt1))
+		require.NoError(t, instr2.SetBuildInfo("v1", dt2))
+		require.Equal(t, instr1.Annots[LLBDigestAnnotation], instr2.Annots[LLBDigestAnnotation])
+	})
+}
+
+func TestImageConfigPlatform(t *testing.T) {
+	o := SourceOpts{Arch: "arm"}
+	plat, err := o.configPlatform("unikraft.org/nginx:1.15", "firecracker", "aarch64")
+	require.NoError(t, err)
+	require.Equal(t, ocispecs.Platform{OS: "fc", Architecture: "arm64"}, plat)
+
+	// The rest of the images are pulled for the buildkit worker
+	plat, err = o.configPlatform("harbor.nbfc.io/foo", "firecracker", "aarch64")
+	require.NoError(t, err)
+	require.Equal(t, ocispecs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, plat)
+	plat, err = SourceOpts{}.configPlatform("scratch", "qemu", "")
+	require.NoError(t, err)
+	require.Equal(t, ocispecs.Platform{OS: "linux", Architecture: runtime.GOARCH}, plat)
+}
+
+func TestImageConfigRootFS(t *testing.T) {
+	img := updateImage(ocispecs.Image{}, nil)
+	require.Equal(t, "layers", img.RootFS.Type)
+	out, err := json.Marshal(img.RootFS)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "layers", "diff_ids": []}`, string(out))
+
+	// The layers of a base image stay
+	diffIDs := []digest.Digest{digest.FromString("a"), digest.FromString("b")}
+	img = updateImage(ocispecs.Image{RootFS: ocispecs.RootFS{Type: "layers", DiffIDs: diffIDs}}, nil)
+	require.Equal(t, diffIDs, img.RootFS.DiffIDs)
+}
+
+func TestImageConfigUser(t *testing.T) {
+	file := metadataBunnyfile + "user: \"1000:1000\"\nworkdir: /srv/app/\n"
+	h, err := ParseBunnyfile([]byte(file))
+	require.NoError(t, err)
+	require.Equal(t, "1000:1000", h.User)
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, "1000:1000", i.Img.Config.User)
+	require.Equal(t, "/srv/app", i.Img.Config.WorkingDir)
+
+	t.Run("Containerfile", func(t *testing.T) {
+		img := &dockerspec.DockerOCIImage{}
+		img.Config.User = "nginx"
+		img.Config.WorkingDir = "/nginx"
+		state := llb.Scratch()
+		i, err := containerfileToPack(&state, img)
+		require.NoError(t, err)
+		require.Equal(t, "nginx", i.Img.Config.User)
+		require.Equal(t, "/nginx", i.Img.Config.WorkingDir)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, user := range []string{":1000", "1000:", "a:b:c", "my user"} {
+			require.ErrorContains(t, ValidateUser(user), "The user field should be <user>[:<group>]", user)
+		}
+		require.NoError(t, ValidateUser("nginx:www-data"))
+		require.ErrorContains(t, ValidateWorkdir("srv"), "The workdir field should be an absolute path, got srv")
+		_, err := ParseBunnyfile([]byte(metadataBunnyfile + "workdir: srv\n"))
+		require.ErrorContains(t, err, "The workdir field should be an absolute path")
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+// localIncludes returns the sources of the local files of the rootfs and
+// whether any of them is optional
+func localIncludes(rootfs Rootfs) ([]string, bool) {
+	var paths []string
+	optional := false
+	add := func(includes []FileToInclude) {
+		for _, f := range includes {
+			if f.From != "local" {
+				continue
+			}
+			paths = append(paths, f.Src)
+			optional = optional || f.Optional
+		}
+	}
+	add(rootfs.Includes)
+	for _, initrd := range rootfs.Initrds {
+		add(initrd.Includes)
+	}
+
+	return ContextPatterns(paths...), optional
+}
+
+// filterIncludes removes the optional local files that the reference does
+// not have from the includes. If check is set, a missing file that is not
+// optional is an error.
+func filterIncludes(ctx context.Context, ref client.Reference, field string, includes []FileToInclude, check bool) ([]FileToInclude, error) {
+	if includes == nil {
+		return nil, nil
+	}
+	var errs []error
+	filtered := make([]FileToInclude, 0, len(includes))
+	for _, f := range includes {
+		if f.From == "local" && (f.Optional || check) {
+			_, err := ref.StatFile(ctx, client.StatRequest{Path: f.Src})
+			if err != nil && !f.Optional {
+				errs = append(errs, fmt.Errorf("The local file %s of %s does not exist in the build context", f.Src, field))
+			}
+			if err != nil {
+				continue
+			}
+		}
+		filtered = append(filtered, f)
+	}
+
+	return filtered, errors.Join(errs...)
+}
+
+// FilterLocalIncludes removes the optional local files of the rootfs that
+// the given reference of the build context does not have. If check is set,
+// it also fails for a missing local file that is not optional, instead of
+// letting the build fail later, when it copies the file.
+func FilterLocalIncludes(ctx context.Context, ref client.Reference, h *Hops, check bool) error {
+	var errs []error
+	var err error
+	h.Rootfs.Includes, err = filterIncludes(ctx, ref, "rootfs.include", h.Rootfs.Includes, check)
+	errs = append(errs, err)
+	for i, initrd := range h.Rootfs.Initrds {
+		field := fmt.Sprintf("rootfs.initrds[%s].include", initrd.Name)
+		h.Rootfs.Initrds[i].Includes, err = filterIncludes(ctx, ref, field, initrd.Includes, check)
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckLocalIncludes stats the local files of the rootfs in the build
+// context, as FilterLocalIncludes does. All of them get transferred together
+// and only if some file is optional or check is set.
+func CheckLocalIncludes(ctx context.Context, c client.Client, h *Hops, buildContext string, check bool) error {
+	paths, optional := localIncludes(h.Rootfs)
+	if len(paths) == 0 || (!optional && !check) {
+		return nil
+	}
+	// Without a client (e.g. when printing the LLB), the build context can
+	// not be read, so the optional files are kept
+	if c == nil {
+		return nil
+	}
+
+	def, err := llb.Local(buildContext, append(excludeOptions(h.Exclude), llb.IncludePatterns(paths),
+		llb.WithCustomName("Internal:Check local includes"))...).Marshal(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal state for checking local includes: %v", err)
+	}
+	res, err := c.Solve(ctx, client.SolveRequest{
+		Definition: def.ToPB(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to fetch local includes: %v", err)
+	}
+	ref, err := res.SingleRef()
+	if err != nil {
+		return fmt.Errorf("Failed to get reference of local includes: %v", err)
+	}
+
+	return FilterLocalIncludes(ctx, ref, h, check)
+}
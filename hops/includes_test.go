@@ -0,0 +1,102 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const includesBunnyfile = `version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+rootfs:
+  type: initrd
+  include:
+    - app:/app
+    - from: local
+      source: extra.conf
+      destination: /etc/extra.conf
+      optional: true
+    - from: harbor.nbfc.io/nubificus/urunc/busybox:latest
+      source: /bin/busybox
+      destination: /bin/busybox
+      optional: true
+`
+
+func TestIncludesOptional(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(includesBunnyfile))
+	require.NoError(t, err)
+	require.False(t, h.Rootfs.Includes[0].Optional)
+	require.True(t, h.Rootfs.Includes[1].Optional)
+
+	paths, optional := localIncludes(h.Rootfs)
+	require.Equal(t, []string{"app", "extra.conf"}, paths)
+	require.True(t, optional)
+
+	// The missing optional file gets dropped, the image is kept as it is
+	ref := &fakeRef{files: map[string][]byte{"app": []byte("app")}}
+	require.NoError(t, FilterLocalIncludes(context.TODO(), ref, h, false))
+	require.Len(t, h.Rootfs.Includes, 2)
+	require.Equal(t, "app", h.Rootfs.Includes[0].Src)
+	require.Equal(t, "/bin/busybox", h.Rootfs.Includes[1].Src)
+
+	t.Run("Existing optional file", func(t *testing.T) {
+		h, err := ParseBunnyfile([]byte(includesBunnyfile))
+		require.NoError(t, err)
+		ref := &fakeRef{files: map[string][]byte{"extra.conf": []byte("conf")}}
+		require.NoError(t, FilterLocalIncludes(context.TODO(), ref, h, false))
+		require.Len(t, h.Rootfs.Includes, 3)
+	})
+}
+
+func TestIncludesCheck(t *testing.T) {
+	h := &Hops{Rootfs: Rootfs{
+		Includes: []FileToInclude{{From: "local", Src: "app", Dst: "/app"}},
+		Initrds: []Initrd{{
+			Name: "modules",
+			Includes: []FileToInclude{
+				{From: "local", Src: "modules", Dst: "/lib/modules"},
+				{From: "local", Src: "firmware", Dst: "/lib/firmware", Optional: true},
+			},
+		}},
+	}}
+	paths, optional := localIncludes(h.Rootfs)
+	require.Equal(t, []string{"app", "modules", "firmware"}, paths)
+	require.True(t, optional)
+
+	ref := &fakeRef{files: map[string][]byte{}, dirs: map[string]bool{"modules": true}}
+	// Without the check, the build fails later for the missing file
+	require.NoError(t, FilterLocalIncludes(context.TODO(), ref, h, false))
+	require.Len(t, h.Rootfs.Includes, 1)
+	require.Len(t, h.Rootfs.Initrds[0].Includes, 1)
+
+	err := FilterLocalIncludes(context.TODO(), ref, h, true)
+	require.ErrorContains(t, err, "The local file app of rootfs.include does not exist in the build context")
+	require.NotContains(t, err.Error(), "modules")
+
+	t.Run("Without a client", func(t *testing.T) {
+		h, err := ParseBunnyfile([]byte(includesBunnyfile))
+		require.NoError(t, err)
+		require.NoError(t, CheckLocalIncludes(context.TODO(), nil, h, "context", true))
+		require.Len(t, h.Rootfs.Includes, 3)
+	})
+}
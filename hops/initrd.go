@@ -0,0 +1,246 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+const (
+	// Create the initrd with bsdcpio in an exec operation
+	InitrdModeExec string = "exec"
+	// Create the initrd in the frontend and store it with a file operation
+	InitrdModeFile string = "file"
+)
+
+const (
+	// The size of each read of a file, when creating the initrd in the
+	// frontend
+	initrdReadChunk int    = 1 << 20
+	cpioTrailer     string = "TRAILER!!!"
+	// The maximum size of an initrd in file mode, since the archive is part
+	// of the LLB definition, which has to fit in a single message
+	maxInitrdFileSize int64 = 8 << 20
+)
+
+// ParseInitrdMode checks that the given mode of creating an initrd is
+// supported. An empty mode means the exec mode.
+func ParseInitrdMode(mode string) (string, error) {
+	switch mode {
+	case "", InitrdModeExec:
+		return InitrdModeExec, nil
+	case InitrdModeFile:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Unknown initrd mode %s, expected one of %s, %s",
+			mode, InitrdModeExec, InitrdModeFile)
+	}
+}
+
+// InitrdContentLLB creates the LLB definition of the files of the initrd that
+// bunny creates, so they can be read before packing them.
+func InitrdContentLLB(ctx context.Context, instr PackInstructions) (*llb.Definition, error) {
+	if instr.Rootfs == nil || instr.Rootfs.InitrdContent == nil {
+		return nil, fmt.Errorf("No initrd to create")
+	}
+
+	return marshalState(ctx, *instr.Rootfs.InitrdContent, instr.Sources)
+}
+
+// InitrdFileLLB creates a LLB State that stores the given cpio archive in
+// DefaultRootfsPath, using only file operations.
+func InitrdFileLLB(cpio []byte) llb.State {
+	return llb.Scratch().
+		File(llb.Mkdir(path.Dir(DefaultRootfsPath), 0755)).
+		File(llb.Mkfile(DefaultRootfsPath, 0644, cpio),
+			llb.WithCustomName("Internal:Store initrd"))
+}
+
+// cpioMode converts the mode of a file, as reported by buildkit, to the mode
+// that cpio archives expect.
+func cpioMode(m os.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	switch {
+	case m&os.ModeDir != 0:
+		mode |= 0040000
+	case m&os.ModeSymlink != 0:
+		mode |= 0120000
+	case m&os.ModeNamedPipe != 0:
+		mode |= 0010000
+	case m&os.ModeSocket != 0:
+		mode |= 0140000
+	case m&os.ModeCharDevice != 0:
+		mode |= 0020000
+	case m&os.ModeDevice != 0:
+		mode |= 0060000
+	default:
+		mode |= 0100000
+	}
+	if m&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+
+	return mode
+}
+
+// writeCpioEntry appends an entry in the newc format to the archive. The
+// owner and the modification time are normalized to the given owner and the
+// epoch, so the archive does not depend on the builder.
+func writeCpioEntry(buf *bytes.Buffer, ino int, name string, st *fstypes.Stat, data []byte, owner Owner) {
+	mode := os.FileMode(st.Mode)
+	nlink := 1
+	if mode.IsDir() {
+		nlink = 2
+	}
+	fmt.Fprintf(buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+		ino, cpioMode(mode), owner.UID, owner.GID, nlink, 0, len(data),
+		0, 0, st.Devmajor, st.Devminor, len(name)+1, 0)
+	buf.WriteString(name)
+	buf.WriteByte(0)
+	cpioPad(buf)
+	buf.Write(data)
+	cpioPad(buf)
+}
+
+// cpioPad aligns the archive to 4 bytes.
+func cpioPad(buf *bytes.Buffer) {
+	for buf.Len()%4 != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+// readRefFile reads the whole file from the reference in chunks, so large
+// files do not exceed the size of a single message.
+func readRefFile(ctx context.Context, ref client.Reference, name string, size int64) ([]byte, error) {
+	data := make([]byte, 0, size)
+	for int64(len(data)) < size {
+		chunk, err := ref.ReadFile(ctx, client.ReadRequest{
+			Filename: name,
+			Range: &client.FileRange{
+				Offset: len(data),
+				Length: initrdReadChunk,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		data = append(data, chunk...)
+	}
+
+	return data, nil
+}
+
+// CpioFromRef creates a cpio archive in the newc format with all the files of
+// the given reference. Like "find . | LC_ALL=C sort", the entries are sorted
+// by their names, which are relative to the root of the reference, so
+// directories come before their contents and the order does not depend on
+// the builder. Every entry belongs to the given owner. Archives larger than
+// maxInitrdFileSize fail, before reading the file that exceeds it.
+func CpioFromRef(ctx context.Context, ref client.Reference, owner Owner) ([]byte, error) {
+	type cpioFile struct {
+		name string
+		path string
+		st   *fstypes.Stat
+	}
+	var files []cpioFile
+
+	var walk func(dir string, name string) error
+	walk = func(dir string, name string) error {
+		entries, err := ref.ReadDir(ctx, client.ReadDirRequest{Path: dir})
+		if err != nil {
+			return fmt.Errorf("Failed to read directory %s: %v", dir, err)
+		}
+		for _, entry := range entries {
+			entryPath := path.Join(dir, path.Base(entry.Path))
+			entryName := name + "/" + path.Base(entry.Path)
+			files = append(files, cpioFile{name: entryName, path: entryPath, st: entry})
+			if os.FileMode(entry.Mode).IsDir() {
+				err = walk(entryPath, entryName)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	root, err := ref.StatFile(ctx, client.StatRequest{Path: "/"})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to stat /: %v", err)
+	}
+	files = append(files, cpioFile{name: ".", path: "/", st: root})
+	err = walk("/", ".")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	var buf bytes.Buffer
+	for i, f := range files {
+		mode := os.FileMode(f.st.Mode)
+		var data []byte
+		switch {
+		case mode&os.ModeSymlink != 0:
+			data = []byte(f.st.Linkname)
+		case mode.IsRegular():
+			if int64(buf.Len())+f.st.Size > maxInitrdFileSize {
+				return nil, errInitrdTooLarge()
+			}
+			data, err = readRefFile(ctx, ref, f.path, f.st.Size)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read %s: %v", f.path, err)
+			}
+		}
+		writeCpioEntry(&buf, i+1, f.name, f.st, data, owner)
+	}
+	fmt.Fprintf(&buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+		0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, len(cpioTrailer)+1, 0)
+	buf.WriteString(cpioTrailer)
+	buf.WriteByte(0)
+	cpioPad(&buf)
+	if int64(buf.Len()) > maxInitrdFileSize {
+		return nil, errInitrdTooLarge()
+	}
+
+	return buf.Bytes(), nil
+}
+
+// errInitrdTooLarge returns the error of an initrd that does not fit in
+// the LLB definition
+func errInitrdTooLarge() error {
+	return fmt.Errorf("Initrd too large for file mode, it exceeds %d bytes, use initrd-mode=%s instead",
+		maxInitrdFileSize, InitrdModeExec)
+}
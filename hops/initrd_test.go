@@ -0,0 +1,259 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// cpioEntry is an entry of a newc cpio archive
+type cpioEntry struct {
+	name string
+	mode uint32
+	uid  int
+	gid  int
+	data string
+}
+
+// parseCpio decodes the entries of a newc cpio archive up to the trailer.
+func parseCpio(t *testing.T, archive []byte) []cpioEntry {
+	var entries []cpioEntry
+	field := func(off int, i int) int {
+		v, err := strconv.ParseUint(string(archive[off+6+i*8:off+6+(i+1)*8]), 16, 32)
+		require.NoError(t, err)
+		return int(v)
+	}
+	align := func(n int) int {
+		return (n + 3) &^ 3
+	}
+	off := 0
+	for {
+		require.Equal(t, "070701", string(archive[off:off+6]))
+		mode := field(off, 1)
+		uid := field(off, 2)
+		gid := field(off, 3)
+		size := field(off, 6)
+		nameSize := field(off, 11)
+		name := string(archive[off+110 : off+110+nameSize-1])
+		off = align(off + 110 + nameSize)
+		data := string(archive[off : off+size])
+		off = align(off + size)
+		if name == cpioTrailer {
+			require.Equal(t, len(archive), off)
+			return entries
+		}
+		entries = append(entries, cpioEntry{name: name, mode: uint32(mode), uid: uid, gid: gid, data: data})
+	}
+}
+
+// ownedRef is a fakeRef whose files belong to a user and have a
+// modification time.
+type ownedRef struct {
+	*fakeRef
+}
+
+func (r *ownedRef) own(st *fstypes.Stat) *fstypes.Stat {
+	st.Uid = 1000
+	st.Gid = 1000
+	st.ModTime = 1700000000 * 1e9
+
+	return st
+}
+
+func (r *ownedRef) StatFile(ctx context.Context, req client.StatRequest) (*fstypes.Stat, error) {
+	st, err := r.fakeRef.StatFile(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.own(st), nil
+}
+
+func (r *ownedRef) ReadDir(ctx context.Context, req client.ReadDirRequest) ([]*fstypes.Stat, error) {
+	entries, err := r.fakeRef.ReadDir(ctx, req)
+	for _, st := range entries {
+		r.own(st)
+	}
+
+	return entries, err
+}
+
+func TestInitrdParseMode(t *testing.T) {
+	mode, err := ParseInitrdMode("")
+	require.NoError(t, err)
+	require.Equal(t, InitrdModeExec, mode)
+	mode, err = ParseInitrdMode(InitrdModeFile)
+	require.NoError(t, err)
+	require.Equal(t, InitrdModeFile, mode)
+	_, err = ParseInitrdMode("cpio")
+	require.ErrorContains(t, err, "Unknown initrd mode cpio")
+}
+
+func TestInitrdCpioFromRef(t *testing.T) {
+	t.Run("Files and directories", func(t *testing.T) {
+		ref := &fakeRef{
+			files: map[string][]byte{
+				"/bin/app":   []byte("binary"),
+				"/etc/hosts": []byte("127.0.0.1 localhost\n"),
+				"/empty":     {},
+			},
+			dirs: map[string]bool{
+				"/":    true,
+				"/bin": true,
+				"/etc": true,
+			},
+		}
+		archive, err := CpioFromRef(context.TODO(), ref, Owner{})
+		require.NoError(t, err)
+		require.Equal(t, []cpioEntry{
+			{name: ".", mode: 040755},
+			{name: "./bin", mode: 040755},
+			{name: "./bin/app", mode: 0100644, data: "binary"},
+			{name: "./empty", mode: 0100644},
+			{name: "./etc", mode: 040755},
+			{name: "./etc/hosts", mode: 0100644, data: "127.0.0.1 localhost\n"},
+		}, parseCpio(t, archive))
+	})
+	t.Run("Large file in chunks", func(t *testing.T) {
+		large := make([]byte, initrdReadChunk*2+3)
+		for i := range large {
+			large[i] = byte(i)
+		}
+		ref := &fakeRef{
+			files: map[string][]byte{"/large": large},
+			dirs:  map[string]bool{"/": true},
+		}
+		archive, err := CpioFromRef(context.TODO(), ref, Owner{})
+		require.NoError(t, err)
+		entries := parseCpio(t, archive)
+		require.Len(t, entries, 2)
+		require.Equal(t, string(large), entries[1].data)
+	})
+	t.Run("Sorted names", func(t *testing.T) {
+		ref := &fakeRef{
+			files: map[string][]byte{
+				"/a-b": []byte("b"),
+				"/a/c": []byte("c"),
+			},
+			dirs: map[string]bool{
+				"/":  true,
+				"/a": true,
+			},
+		}
+		archive, err := CpioFromRef(context.TODO(), ref, Owner{})
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range parseCpio(t, archive) {
+			names = append(names, entry.name)
+		}
+		require.Equal(t, []string{".", "./a", "./a-b", "./a/c"}, names)
+	})
+	t.Run("Normalized owner and times", func(t *testing.T) {
+		ref := &fakeRef{
+			files: map[string][]byte{"/bin/app": []byte("binary")},
+			dirs: map[string]bool{
+				"/":    true,
+				"/bin": true,
+			},
+		}
+		archive, err := CpioFromRef(context.TODO(), ref, Owner{})
+		require.NoError(t, err)
+		owned, err := CpioFromRef(context.TODO(), &ownedRef{ref}, Owner{})
+		require.NoError(t, err)
+		require.Equal(t, archive, owned)
+	})
+	t.Run("Too large for file mode", func(t *testing.T) {
+		ref := &fakeRef{
+			files: map[string][]byte{
+				"/a": make([]byte, maxInitrdFileSize/2),
+				"/b": make([]byte, maxInitrdFileSize/2),
+			},
+			dirs: map[string]bool{"/": true},
+		}
+		_, err := CpioFromRef(context.TODO(), ref, Owner{})
+		require.ErrorContains(t, err, "Initrd too large for file mode")
+		require.ErrorContains(t, err, "use initrd-mode=exec instead")
+
+		delete(ref.files, "/b")
+		_, err = CpioFromRef(context.TODO(), ref, Owner{})
+		require.NoError(t, err)
+	})
+	t.Run("Missing root", func(t *testing.T) {
+		_, err := CpioFromRef(context.TODO(), &fakeRef{}, Owner{})
+		require.ErrorContains(t, err, "Failed to stat /")
+	})
+}
+
+func TestInitrdFileLLB(t *testing.T) {
+	def, err := InitrdFileLLB([]byte("cpio")).Marshal(context.TODO())
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var mkfile *pb.FileActionMkFile
+	for _, op := range arr {
+		// Only file operations, no exec
+		require.Nil(t, op.GetExec())
+		if file := op.GetFile(); file != nil {
+			if mf := file.Actions[0].GetMkfile(); mf != nil {
+				mkfile = mf
+			}
+		}
+	}
+	require.NotNil(t, mkfile)
+	require.Equal(t, DefaultRootfsPath, mkfile.Path)
+	require.Equal(t, "cpio", string(mkfile.Data))
+}
+
+func TestInitrdContentLLB(t *testing.T) {
+	t.Run("No initrd", func(t *testing.T) {
+		_, err := InitrdContentLLB(context.TODO(), PackInstructions{})
+		require.ErrorContains(t, err, "No initrd to create")
+	})
+	t.Run("Created initrd", func(t *testing.T) {
+		r := Rootfs{
+			From:     "scratch",
+			Type:     "initrd",
+			Includes: []FileToInclude{{Src: "foo", Dst: "/bar"}},
+		}
+		f := NewUnikraft(Platform{Framework: "unikraft", Monitor: "qemu"}, r)
+		entry, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context"}, r)
+		require.NoError(t, err)
+		require.NotNil(t, entry.InitrdContent)
+
+		def, err := InitrdContentLLB(context.TODO(), PackInstructions{Rootfs: entry})
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		for _, op := range arr {
+			require.Nil(t, op.GetExec())
+		}
+	})
+	t.Run("Raw rootfs", func(t *testing.T) {
+		r := Rootfs{
+			From:     "scratch",
+			Type:     "raw",
+			Includes: []FileToInclude{{Src: "foo", Dst: "/bar"}},
+		}
+		f := NewGeneric(Platform{Framework: "linux", Monitor: "qemu"}, r)
+		entry, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context"}, r)
+		require.NoError(t, err)
+		require.Nil(t, entry.InitrdContent)
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+const (
+	// The ordered list of the initrds in the initrd of the final image
+	InitrdsAnnotation string = "io.bunny.initrds"
+)
+
+const initrdsPartsDir string = "/parts"
+
+// Initrd defines one of the initrds that get concatenated in the initrd of
+// the final image, e.g. the kernel modules and the application
+type Initrd struct {
+	Name     string          `yaml:"name"`
+	Includes []FileToInclude `yaml:"include"`
+}
+
+// initrdNames returns the names of the initrds in their order, as the value
+// of the annotation that lists them.
+func initrdNames(initrds []Initrd) string {
+	names := make([]string, 0, len(initrds))
+	for _, initrd := range initrds {
+		names = append(names, initrd.Name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+// InitrdsLLB creates a cpio file for each of the initrds and concatenates
+// them, in their order, in the initrd of the returned state. Linux extracts
+// all the archives of a concatenated initrd, with later files replacing
+// earlier ones. Each initrd gets created by its own exec operation, so
+// buildkit creates them in parallel. Any extra options are passed to all exec
+// operations. It also returns the merged content of the initrds, which is the
+// content that the kernel sees. The files of every initrd belong to the owner
+// of the input.
+func InitrdsLLB(initrds []Initrd, in BuildInput, opts ...llb.RunOption) (llb.State, llb.State) {
+	outDir := "/.boot"
+	contents := make([]llb.State, 0, len(initrds))
+	parts := make([]string, 0, len(initrds))
+	runOpts := []llb.RunOption{
+		llb.Network(llb.NetModeNone),
+		llb.WithCustomName("Internal:Concatenate initrds"),
+	}
+	for i, initrd := range initrds {
+		content := FilesLLB(initrd.Includes, in.BuildContext, llb.Scratch(), excludeOptions(in.Exclude)...)
+		contents = append(contents, content)
+		partDir := path.Join(initrdsPartsDir, fmt.Sprintf("%d", i))
+		parts = append(parts, path.Join(partDir, DefaultRootfsPath))
+		runOpts = append(runOpts, llb.AddMount(partDir, InitrdLLB(content, in.Owner, opts...), llb.Readonly))
+	}
+	runOpts = append(runOpts,
+		llb.Shlexf("sh -c \"cat %s > %s\"", strings.Join(parts, " "), DefaultRootfsPath))
+	runOpts = append(runOpts, opts...)
+	catExec := llb.Image(defaultBsdcpioImage).
+		File(llb.Mkdir("/tmp", 0755)).
+		Run(runOpts...)
+	base := llb.Scratch().File(llb.Mkdir(outDir, 0755))
+
+	return base.With(getArtifacts(catExec, outDir)), llb.Merge(contents)
+}
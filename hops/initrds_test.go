@@ -0,0 +1,141 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func initrdsHops() *Hops {
+	return &Hops{
+		Platform: Platform{
+			Framework: "linux",
+			Monitor:   "qemu",
+			Arch:      "amd64",
+		},
+		Kernel: Kernel{
+			From: "local",
+			Path: "kernel",
+		},
+		Rootfs: Rootfs{
+			From: "scratch",
+			Type: "initrd",
+			Initrds: []Initrd{
+				{Name: "modules", Includes: []FileToInclude{{Src: "modules", Dst: "/lib/modules/{{arch}}"}}},
+				{Name: "app", Includes: []FileToInclude{{Src: "app", Dst: "/app"}}},
+			},
+		},
+	}
+}
+
+func TestInitrdsNames(t *testing.T) {
+	require.Equal(t, "", initrdNames(nil))
+	require.Equal(t, "modules,app", initrdNames(initrdsHops().Rootfs.Initrds))
+}
+
+func TestInitrdsLLB(t *testing.T) {
+	t.Run("Concatenated initrds", func(t *testing.T) {
+		state, _ := InitrdsLLB(initrdsHops().Rootfs.Initrds, BuildInput{BuildContext: "context"})
+		def, err := state.Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		execs := 0
+		for _, op := range arr {
+			if op.GetExec() != nil {
+				execs++
+			}
+		}
+		// One exec for each initrd and one that concatenates them
+		require.Equal(t, 3, execs)
+
+		exec, mounts, _ := kraftExec(t, state)
+		require.Equal(t, []string{"sh", "-c", "cat /parts/0/.boot/rootfs /parts/1/.boot/rootfs > /.boot/rootfs"}, exec.Meta.Args)
+		require.Equal(t, pb.NetMode_NONE, exec.Network)
+		require.True(t, mounts["/parts/0"].Readonly)
+		require.True(t, mounts["/parts/1"].Readonly)
+		require.Contains(t, mounts, "/.boot")
+	})
+	t.Run("Hardened", func(t *testing.T) {
+		state, _ := InitrdsLLB(initrdsHops().Rootfs.Initrds, BuildInput{BuildContext: "context"}, hardenedOptions(true, false)...)
+		exec, _, _ := kraftExec(t, state)
+		requireHardened(t, exec)
+		require.Equal(t, pb.NetMode_NONE, exec.Network)
+	})
+	t.Run("Merged content", func(t *testing.T) {
+		_, content := InitrdsLLB(initrdsHops().Rootfs.Initrds, BuildInput{BuildContext: "context"})
+		def, err := content.Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var dests []string
+		for _, op := range arr {
+			require.Nil(t, op.GetExec())
+			if f := op.GetFile(); f != nil {
+				for _, action := range f.Actions {
+					if c := action.GetCopy(); c != nil {
+						dests = append(dests, c.Dest)
+					}
+				}
+			}
+		}
+		require.ElementsMatch(t, []string{"/lib/modules/{{arch}}", "/app"}, dests)
+	})
+}
+
+func TestInitrdsToPack(t *testing.T) {
+	t.Run("Initrds", func(t *testing.T) {
+		h := initrdsHops()
+		i, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		require.Equal(t, "modules,app", i.Annots[InitrdsAnnotation])
+		require.Equal(t, DefaultRootfsPath, i.Annots["com.urunc.unikernel.initrd"])
+		require.Equal(t, DefaultRootfsPath, i.Rootfs.FilePath)
+		require.NotNil(t, i.Rootfs.InitrdContent)
+		// The bunnyfile stays as it was
+		require.Equal(t, "/lib/modules/{{arch}}", h.Rootfs.Initrds[0].Includes[0].Dst)
+
+		def, err := i.Rootfs.InitrdContent.Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var dests []string
+		for _, op := range arr {
+			if f := op.GetFile(); f != nil {
+				for _, action := range f.Actions {
+					if c := action.GetCopy(); c != nil {
+						dests = append(dests, c.Dest)
+					}
+				}
+			}
+		}
+		require.Contains(t, dests, "/lib/modules/amd64")
+	})
+	t.Run("No initrds", func(t *testing.T) {
+		h := initrdsHops()
+		h.Rootfs.Initrds = nil
+		h.Rootfs.Includes = []FileToInclude{{Src: "app", Dst: "/app"}}
+		i, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		require.NotContains(t, i.Annots, InitrdsAnnotation)
+	})
+	t.Run("Raw rootfs", func(t *testing.T) {
+		h := initrdsHops()
+		h.Rootfs.Type = "raw"
+		_, err := ToPack(context.TODO(), h, "context")
+		require.ErrorContains(t, err, "Cannot create initrds for a raw rootfs")
+	})
+}
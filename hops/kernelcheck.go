@@ -0,0 +1,227 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+const (
+	defaultInspectImage  string = "docker.io/library/busybox:latest"
+	kernelCheckDir       string = "/kernel"
+	kernelCheckScriptDir string = "/check"
+	// The length of the ELF header up to and including e_machine
+	elfHeaderMachineEnd int = 20
+)
+
+// The script that inspects the ELF header and the Unikraft libraries of the
+// kernel. The first argument is the kernel, the second the expected ELF
+// machine and the third the expected platform library of Unikraft, if any.
+// Kernels without the names of the platform libraries are not checked.
+const kernelCheckScript = `#!/bin/sh
+kernel="$1"
+machine="$2"
+plat="$3"
+if [ ! -f "$kernel" ]; then
+	echo "Kernel $kernel does not exist in the image" >&2
+	exit 1
+fi
+magic=$(od -An -tx1 -N4 "$kernel" | tr -d ' \n')
+if [ "$magic" != "7f454c46" ]; then
+	echo "Kernel $kernel is not an ELF file" >&2
+	exit 1
+fi
+found=$(od -An -tu2 -j18 -N2 "$kernel" | tr -d ' \n')
+if [ "$found" != "$machine" ]; then
+	echo "Kernel $kernel was built for ELF machine $found, expected $machine" >&2
+	exit 1
+fi
+[ -n "$plat" ] || exit 0
+libs=$(grep -a -o -E 'lib(kvm|xen|linuxu)plat' "$kernel" | sort -u | tr '\n' ' ')
+if [ -n "$libs" ] && ! echo " $libs" | grep -q " $plat "; then
+	echo "Kernel $kernel was built with ${libs% }, expected $plat" >&2
+	exit 1
+fi
+`
+
+// unikraftPlatforms maps the monitors to the library of the Unikraft
+// platform that their kernels get built with. The monitors that share a
+// platform (e.g. qemu and firecracker) can not be told apart this way.
+var unikraftPlatforms = map[string]string{
+	"qemu":                 "libkvmplat",
+	"firecracker":          "libkvmplat",
+	MonitorCloudHypervisor: "libkvmplat",
+	"xen":                  "libxenplat",
+}
+
+// elfMachines maps the supported architectures to the e_machine value of
+// the ELF header.
+var elfMachines = map[string]int{
+	"amd64": 62,
+	"arm64": 183,
+}
+
+// KernelCheck describes a prebuilt kernel that needs to be inspected, before
+// we pack it in the final image.
+type KernelCheck struct {
+	// The reference of the kernel image, empty for a kernel in the build
+	// context
+	Ref string
+	// The state that contains the kernel
+	Source llb.State
+	// The path of the kernel inside Source
+	Path string
+	// The monitor that the user declared
+	Monitor string
+	// The architecture that the user declared
+	Arch string
+}
+
+// normalizeArch returns the name of the given architecture as used in OCI
+// platforms. An empty architecture means the architecture of the host.
+func normalizeArch(arch string) string {
+	switch arch {
+	case "":
+		return runtime.GOARCH
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	default:
+		return arch
+	}
+}
+
+// KernelCheckLLB creates the LLB definition that inspects the kernel and
+// fails if it was not built for the declared architecture. A kernel from
+// unikraft.org also needs to be linked with the Unikraft platform of the
+// declared monitor, as the names of its libraries (uklibs) show.
+func KernelCheckLLB(ctx context.Context, instr PackInstructions) (*llb.Definition, error) {
+	if instr.KernelCheck == nil {
+		return nil, fmt.Errorf("No kernel to inspect")
+	}
+	check := *instr.KernelCheck
+	arch := normalizeArch(check.Arch)
+	machine, ok := elfMachines[arch]
+	if !ok {
+		return nil, fmt.Errorf("Inspecting kernels for %s is not supported", arch)
+	}
+	if check.Path == "" {
+		return nil, fmt.Errorf("The path of the kernel is empty")
+	}
+
+	checkFiles := llb.Scratch().
+		File(llb.Mkfile("/check.sh", 0755, []byte(kernelCheckScript)))
+	args := []string{
+		"/bin/sh", path.Join(kernelCheckScriptDir, "check.sh"),
+		path.Join(kernelCheckDir, check.Path),
+		strconv.Itoa(machine),
+	}
+	if isUnikraftRef(check.Ref) {
+		args = append(args, unikraftPlatforms[check.Monitor])
+	}
+	runOpts := append([]llb.RunOption{
+		llb.Args(args),
+		llb.AddMount(kernelCheckDir, check.Source, llb.Readonly),
+		llb.AddMount(kernelCheckScriptDir, checkFiles, llb.Readonly),
+		llb.WithCustomName("Internal:Inspect kernel"),
+	}, instr.Sources.Network.ProxyOptions()...)
+	runOpts = append(runOpts, hardenedOptions(instr.Sources.Hardened, false)...)
+	runOpts = append(runOpts, instr.Sources.Build.NetworkOptions(BuildStepCheck)...)
+	checkExec := llb.Image(defaultInspectImage).Run(runOpts...)
+
+	return marshalState(ctx, execResult(checkExec, instr.Sources.Hardened), instr.Sources)
+}
+
+// CheckKernelMachine compares the ELF machine in the given header of the
+// kernel with the one of the declared architecture. Kernels that are not
+// ELF files (e.g. a Linux bzImage) and architectures without a known ELF
+// machine are not checked.
+func CheckKernelMachine(header []byte, kernel string, arch string) error {
+	expected, ok := elfMachines[normalizeArch(arch)]
+	if !ok {
+		return nil
+	}
+	if len(header) < elfHeaderMachineEnd || string(header[:4]) != "\x7fELF" {
+		return nil
+	}
+
+	var machine uint16
+	switch header[5] {
+	case 1:
+		machine = binary.LittleEndian.Uint16(header[18:20])
+	case 2:
+		machine = binary.BigEndian.Uint16(header[18:20])
+	default:
+		return fmt.Errorf("Kernel %s has an invalid ELF header", kernel)
+	}
+	if int(machine) == expected {
+		return nil
+	}
+	for name, m := range elfMachines {
+		if m == int(machine) {
+			return fmt.Errorf("Kernel %s was built for %s, but the architecture is %s", kernel, name, normalizeArch(arch))
+		}
+	}
+
+	return fmt.Errorf("Kernel %s was built for ELF machine %d, but the architecture is %s", kernel, machine, normalizeArch(arch))
+}
+
+// checkKernelHeader reads the ELF header of the kernel from the given
+// reference and compares it with the declared architecture.
+func checkKernelHeader(ctx context.Context, ref client.Reference, check KernelCheck) error {
+	header, err := ref.ReadFile(ctx, client.ReadRequest{
+		Filename: check.Path,
+		Range:    &client.FileRange{Length: elfHeaderMachineEnd},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to read kernel %s: %v", check.Path, err)
+	}
+
+	return CheckKernelMachine(header, check.Path, check.Arch)
+}
+
+// CheckLocalKernel reads the ELF header of a kernel in the build context
+// and fails if it was not built for the declared architecture. Unlike the
+// inspection of kernel images, it runs in the frontend without any exec.
+func CheckLocalKernel(ctx context.Context, c client.Client, instr PackInstructions) error {
+	if instr.KernelCheck == nil {
+		return fmt.Errorf("No kernel to inspect")
+	}
+	def, err := marshalState(ctx, instr.KernelCheck.Source, instr.Sources)
+	if err != nil {
+		return err
+	}
+	res, err := c.Solve(ctx, client.SolveRequest{
+		Definition: def.ToPB(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to load kernel: %v", err)
+	}
+	ref, err := res.SingleRef()
+	if err != nil {
+		return fmt.Errorf("Failed to get reference of kernel: %v", err)
+	}
+
+	return checkKernelHeader(ctx, ref, *instr.KernelCheck)
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKernelCheckScript(t *testing.T) {
+	if _, err := exec.LookPath("od"); err != nil {
+		t.Skip("The script needs od")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "check.sh")
+	require.NoError(t, os.WriteFile(script, []byte(kernelCheckScript), 0o755))
+	unikraft := func(plat string) []byte {
+		return append(elfHeader(1, 62), []byte("\x00Info: ["+plat+"] Entering\x00[libukboot] Powered by Unikraft")...)
+	}
+	tests := []struct {
+		name      string
+		kernel    []byte
+		plat      string
+		errorText string
+	}{
+		{name: "Valid platform", kernel: unikraft("libkvmplat"), plat: unikraftPlatforms["firecracker"]},
+		{name: "Valid without uklibs", kernel: elfHeader(1, 62), plat: unikraftPlatforms["qemu"]},
+		{name: "Valid without platform", kernel: unikraft("libxenplat"), plat: ""},
+		{
+			name:      "Invalid xen kernel for qemu",
+			kernel:    unikraft("libxenplat"),
+			plat:      unikraftPlatforms["qemu"],
+			errorText: "was built with libxenplat, expected libkvmplat",
+		},
+		{
+			name:      "Invalid linuxu kernel for xen",
+			kernel:    unikraft("liblinuxuplat"),
+			plat:      unikraftPlatforms["xen"],
+			errorText: "was built with liblinuxuplat, expected libxenplat",
+		},
+		{
+			name:      "Invalid architecture",
+			kernel:    append(elfHeader(1, 183), []byte("libkvmplat")...),
+			plat:      unikraftPlatforms["qemu"],
+			errorText: "was built for ELF machine 183, expected 62",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			kernel := filepath.Join(dir, "kernel")
+			require.NoError(t, os.WriteFile(kernel, tc.kernel, 0o644))
+			out, err := exec.Command("/bin/sh", script, kernel, "62", tc.plat).CombinedOutput()
+			if tc.errorText != "" {
+				require.Error(t, err)
+				require.Contains(t, string(out), tc.errorText)
+				return
+			}
+			require.NoError(t, err, string(out))
+		})
+	}
+}
+
+func TestKernelCheckLLB(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("Kernel inspection is not supported in", runtime.GOARCH)
+	}
+	t.Run("Valid", func(t *testing.T) {
+		check := KernelCheck{
+			Source:  llb.Image("unikraft.org/nginx:latest"),
+			Path:    "/unikraft/bin/kernel",
+			Monitor: "qemu",
+			Arch:    "aarch64",
+		}
+		def, err := KernelCheckLLB(context.TODO(), PackInstructions{KernelCheck: &check})
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var exec *pb.ExecOp
+		var sources []string
+		for _, op := range arr {
+			switch o := op.Op.(type) {
+			case *pb.Op_Exec:
+				exec = o.Exec
+			case *pb.Op_Source:
+				sources = append(sources, o.Source.Identifier)
+			}
+		}
+		require.NotNil(t, exec)
+		require.Contains(t, sources, "docker-image://"+defaultInspectImage)
+		require.Contains(t, sources, "docker-image://unikraft.org/nginx:latest")
+		require.Equal(t, []string{"/bin/sh", "/check/check.sh", "/kernel/unikraft/bin/kernel", strconv.Itoa(elfMachines["arm64"])}, exec.Meta.Args)
+		require.Equal(t, 3, len(exec.Mounts))
+		require.Equal(t, "/check", exec.Mounts[1].Dest)
+		require.Equal(t, true, exec.Mounts[1].Readonly)
+		require.Equal(t, "/kernel", exec.Mounts[2].Dest)
+		require.Equal(t, true, exec.Mounts[2].Readonly)
+	})
+	t.Run("Valid unikraft.org", func(t *testing.T) {
+		check := KernelCheck{
+			Ref:     "unikraft.org/nginx:latest",
+			Source:  llb.Image("unikraft.org/nginx:latest"),
+			Path:    "/unikraft/bin/kernel",
+			Monitor: "xen",
+			Arch:    "amd64",
+		}
+		def, err := KernelCheckLLB(context.TODO(), PackInstructions{KernelCheck: &check})
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var args []string
+		for _, op := range arr {
+			if e, ok := op.Op.(*pb.Op_Exec); ok {
+				args = e.Exec.Meta.Args
+			}
+		}
+		require.Equal(t, []string{"/bin/sh", "/check/check.sh", "/kernel/unikraft/bin/kernel", strconv.Itoa(elfMachines["amd64"]), "libxenplat"}, args)
+	})
+	t.Run("Invalid architecture", func(t *testing.T) {
+		_, err := KernelCheckLLB(context.TODO(), PackInstructions{KernelCheck: &KernelCheck{Path: "kernel", Arch: "riscv64"}})
+		require.ErrorContains(t, err, "Inspecting kernels for riscv64 is not supported")
+	})
+	t.Run("Invalid no kernel", func(t *testing.T) {
+		_, err := KernelCheckLLB(context.TODO(), PackInstructions{})
+		require.ErrorContains(t, err, "No kernel to inspect")
+	})
+	t.Run("Invalid empty path", func(t *testing.T) {
+		_, err := KernelCheckLLB(context.TODO(), PackInstructions{KernelCheck: &KernelCheck{Arch: "amd64"}})
+		require.ErrorContains(t, err, "The path of the kernel is empty")
+	})
+}
+
+func TestKernelCheckToPack(t *testing.T) {
+	hops := &Hops{
+		Platform: Platform{
+			Framework: "unikraft",
+			Monitor:   "qemu",
+			Arch:      "x86_64",
+		},
+		Kernel: Kernel{
+			From: "unikraft.org/nginx:1.15",
+			Path: "/unikraft/bin/kernel",
+		},
+	}
+	i, err := ToPack(context.TODO(), hops, "context")
+	require.NoError(t, err)
+	require.NotNil(t, i.KernelCheck)
+	require.Equal(t, "unikraft.org/nginx:1.15", i.KernelCheck.Ref)
+	require.Equal(t, "/unikraft/bin/kernel", i.KernelCheck.Path)
+	require.Equal(t, "qemu", i.KernelCheck.Monitor)
+	require.Equal(t, "x86_64", i.KernelCheck.Arch)
+
+	hops.Kernel.From = "harbor.nbfc.io/foo"
+	i, err = ToPack(context.TODO(), hops, "context")
+	require.NoError(t, err)
+	require.Nil(t, i.KernelCheck)
+}
+
+// elfHeader returns the start of an ELF header with the given data encoding
+// and machine.
+func elfHeader(data byte, machine uint16) []byte {
+	header := make([]byte, 64)
+	copy(header, "\x7fELF")
+	header[4] = 2
+	header[5] = data
+	if data == 2 {
+		header[18] = byte(machine >> 8)
+		header[19] = byte(machine)
+	} else {
+		header[18] = byte(machine)
+		header[19] = byte(machine >> 8)
+	}
+
+	return header
+}
+
+func TestKernelCheckMachine(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    []byte
+		arch      string
+		errorText string
+	}{
+		{name: "Valid amd64", header: elfHeader(1, 62), arch: "x86_64"},
+		{name: "Valid arm64", header: elfHeader(1, 183), arch: "arm64"},
+		{name: "Valid big endian", header: elfHeader(2, 183), arch: "aarch64"},
+		{name: "Valid not an ELF", header: []byte("MZ bzImage of Linux with HdrS"), arch: "amd64"},
+		{name: "Valid unknown arch", header: elfHeader(1, 62), arch: "riscv64"},
+		{
+			name:      "Invalid arm64 for amd64",
+			header:    elfHeader(1, 183),
+			arch:      "amd64",
+			errorText: "Kernel kernel was built for arm64, but the architecture is amd64",
+		},
+		{
+			name:      "Invalid unknown machine",
+			header:    elfHeader(1, 243),
+			arch:      "arm64",
+			errorText: "Kernel kernel was built for ELF machine 243, but the architecture is arm64",
+		},
+		{
+			name:      "Invalid encoding",
+			header:    elfHeader(3, 62),
+			arch:      "amd64",
+			errorText: "Kernel kernel has an invalid ELF header",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckKernelMachine(tc.header, "kernel", tc.arch)
+			if tc.errorText != "" {
+				require.ErrorContains(t, err, tc.errorText)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestKernelCheckHeader(t *testing.T) {
+	check := KernelCheck{Path: "kernel", Arch: "amd64"}
+	t.Run("Matching kernel", func(t *testing.T) {
+		ref := &fakeRef{files: map[string][]byte{"kernel": elfHeader(1, 62)}}
+		require.NoError(t, checkKernelHeader(context.TODO(), ref, check))
+	})
+	t.Run("Mismatching kernel", func(t *testing.T) {
+		ref := &fakeRef{files: map[string][]byte{"kernel": elfHeader(1, 183)}}
+		err := checkKernelHeader(context.TODO(), ref, check)
+		require.ErrorContains(t, err, "Kernel kernel was built for arm64, but the architecture is amd64")
+	})
+	t.Run("Missing kernel", func(t *testing.T) {
+		err := checkKernelHeader(context.TODO(), &fakeRef{}, check)
+		require.ErrorContains(t, err, "Failed to read kernel kernel")
+	})
+}
+
+func TestKernelCheckLocalToPack(t *testing.T) {
+	hops := &Hops{
+		Platform: Platform{
+			Framework: "rumprun",
+			Monitor:   "qemu",
+			Arch:      "arm64",
+		},
+		Kernel: Kernel{
+			From: "local",
+			Path: "kernel",
+		},
+	}
+	i, err := ToPack(context.TODO(), hops, "context")
+	require.NoError(t, err)
+	require.NotNil(t, i.KernelCheck)
+	require.Empty(t, i.KernelCheck.Ref)
+	require.Equal(t, "kernel", i.KernelCheck.Path)
+	require.Equal(t, "arm64", i.KernelCheck.Arch)
+	def, err := i.KernelCheck.Source.Marshal(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, []string{"local://context"}, sourceIdentifiers(t, def))
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The prefix of the annotations with the path of the kernel of each
+	// monitor, when the image has a kernel per monitor. The monitor follows
+	// the prefix, e.g. io.bunny.binary.firecracker. urunc does not read
+	// them, so they belong to the namespace of bunny.
+	KernelVariantAnnotPrefix string = "io.bunny.binary."
+)
+
+// KernelVariant is the kernel of a single monitor, when the kernel field
+// is a list of kernels
+type KernelVariant struct {
+	// The monitor that boots the kernel
+	Monitor string   `yaml:"monitor"`
+	From    string   `yaml:"from"`
+	Path    string   `yaml:"path"`
+	Source  string   `yaml:"source"`
+	Config  []string `yaml:"config"`
+}
+
+// Kernel returns the kernel of the variant
+func (v KernelVariant) Kernel() Kernel {
+	return Kernel{
+		From:   v.From,
+		Path:   v.Path,
+		Source: v.Source,
+		Config: v.Config,
+	}
+}
+
+// UnmarshalYAML decodes the kernel field, either as a single kernel, along
+// with its overrides for each architecture, or as a list of kernels, one per
+// monitor. The kernel of the monitor of platforms gets selected by
+// SelectKernel, after the monitor gets normalized.
+func (k *Kernel) UnmarshalYAML(node *yaml.Node) error {
+	type plainKernel Kernel
+	if node.Kind != yaml.SequenceNode {
+		err := node.Decode((*plainKernel)(k))
+		if err != nil {
+			return err
+		}
+		k.Arches, err = decodeArchOverrides[KernelOverride](node, "kernel")
+		return err
+	}
+
+	var variants []KernelVariant
+	err := node.Decode(&variants)
+	if err != nil {
+		return err
+	}
+	if len(variants) == 0 {
+		return fmt.Errorf("invalid kernel list at line %d, column %d: the list is empty", node.Line, node.Column)
+	}
+	*k = Kernel{Variants: variants}
+
+	return nil
+}
+
+// KernelVariantPath returns the path of the kernel of the given monitor in
+// the final image, next to the kernel path of the image, for the kernels of
+// monitors other than the one of platforms
+func KernelVariantPath(kernelPath string, monitor string) string {
+	return kernelPath + "-" + monitor
+}
+
+// isKernelVariantAnnotation returns true, if the key is the path of the
+// kernel of one of the known monitors
+func isKernelVariantAnnotation(key string) bool {
+	monitor, ok := strings.CutPrefix(key, KernelVariantAnnotPrefix)
+
+	return ok && slices.Contains(schemaMonitors, monitor)
+}
+
+// SelectKernel checks the list of kernels of a bunnyfile, if any, and sets
+// the kernel to the one of the monitor of platforms. The list keeps all the
+// kernels, with their monitors normalized. The conditions are:
+// 1) every kernel of the list should have a monitor and the monitors should
+// be unique
+// 2) the list should have a kernel for the monitor of platforms
+// 3) the other monitors should support the framework and the architecture
+// 4) the kernels of the other monitors can not be built, since bunny builds
+// only the kernel of the monitor of platforms
+func SelectKernel(h *Hops) error {
+	if len(h.Kernel.Variants) == 0 {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	selected := -1
+	for j, v := range h.Kernel.Variants {
+		if v.Monitor == "" {
+			return fmt.Errorf("The monitor field of every kernel in the list is necessary")
+		}
+		monitor := NormalizeMonitor(v.Monitor)
+		if seen[monitor] {
+			return fmt.Errorf("The list of kernels has more than one kernel for %s", monitor)
+		}
+		seen[monitor] = true
+		h.Kernel.Variants[j].Monitor = monitor
+		if monitor == h.Platform.Monitor {
+			selected = j
+			continue
+		}
+		plat := h.Platform
+		plat.Monitor = monitor
+		err := ValidatePlatform(plat)
+		if err != nil {
+			return fmt.Errorf("Invalid kernel for %s: %v", monitor, err)
+		}
+		if v.From == KernelFromBuild {
+			return fmt.Errorf("The kernel for %s can not be built, only the kernel for %s can", monitor, h.Platform.Monitor)
+		}
+		err = ValidateKernel(v.Kernel())
+		if err != nil {
+			return fmt.Errorf("Invalid kernel for %s: %v", monitor, err)
+		}
+	}
+	if selected < 0 {
+		return fmt.Errorf("The list of kernels has no kernel for %s, the monitor of platforms", h.Platform.Monitor)
+	}
+
+	kernel := h.Kernel.Variants[selected].Kernel()
+	kernel.Variants = h.Kernel.Variants
+	h.Kernel = kernel
+
+	return nil
+}
+
+// setKernelVariants copies the kernels of the monitors other than the one of
+// platforms in the final image and annotates the path of the kernel of every
+// monitor, including the one of platforms in kernelPath.
+func (i *PackInstructions) setKernelVariants(ctx context.Context, f Framework, in BuildInput, k Kernel, kernelPath string) error {
+	if len(k.Variants) == 0 {
+		return nil
+	}
+
+	for _, v := range k.Variants {
+		if v.Monitor == in.Monitor {
+			i.Annots[KernelVariantAnnotPrefix+v.Monitor] = kernelPath
+			continue
+		}
+		variantIn := in
+		variantIn.Monitor = v.Monitor
+		entry, err := handleKernel(ctx, f, variantIn, v.Kernel())
+		if err != nil {
+			return fmt.Errorf("Error handling kernel for %s: %v", v.Monitor, err)
+		}
+		variantPath := KernelVariantPath(i.Paths.KernelPath(), v.Monitor)
+		i.copyEntry("kernel for "+v.Monitor, *entry, variantPath)
+		i.Annots[KernelVariantAnnotPrefix+v.Monitor] = variantPath
+	}
+
+	return nil
+}
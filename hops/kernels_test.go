@@ -0,0 +1,191 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const kernelsBunnyfile = `version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  - monitor: firecracker
+    from: harbor.nbfc.io/kernels/fc
+    path: /vmlinux
+  - monitor: qemu
+    from: local
+    path: vmlinux
+rootfs:
+  from: local
+  path: rootfs
+  type: initrd
+cmd: ["/init"]
+`
+
+func TestKernelsParse(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(kernelsBunnyfile))
+	require.NoError(t, err)
+	require.Equal(t, "local", h.Kernel.From)
+	require.Equal(t, "vmlinux", h.Kernel.Path)
+	require.Equal(t, []KernelVariant{
+		{Monitor: "firecracker", From: "harbor.nbfc.io/kernels/fc", Path: "/vmlinux"},
+		{Monitor: "qemu", From: "local", Path: "vmlinux"},
+	}, h.Kernel.Variants)
+
+	// Validating again keeps the same kernel
+	require.NoError(t, ValidateHops(h))
+	require.Equal(t, "vmlinux", h.Kernel.Path)
+
+	t.Run("Single kernel", func(t *testing.T) {
+		h, err := ParseBunnyfile([]byte(embedBunnyfile))
+		require.NoError(t, err)
+		require.Empty(t, h.Kernel.Variants)
+	})
+	t.Run("Empty list", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte("version: v0.1\nkernel: []\n"))
+		require.ErrorContains(t, err, "the list is empty")
+	})
+}
+
+func TestKernelsSelect(t *testing.T) {
+	plat := Platform{Framework: "linux", Monitor: "qemu"}
+	tests := []struct {
+		name      string
+		variants  []KernelVariant
+		errorText string
+	}{
+		{
+			name:      "Missing monitor",
+			variants:  []KernelVariant{{From: "local", Path: "vmlinux"}},
+			errorText: "The monitor field of every kernel in the list is necessary",
+		},
+		{
+			name: "Duplicate monitor",
+			variants: []KernelVariant{
+				{Monitor: "qemu", From: "local", Path: "vmlinux"},
+				{Monitor: "qemu", From: "local", Path: "other"},
+			},
+			errorText: "The list of kernels has more than one kernel for qemu",
+		},
+		{
+			name: "No kernel for the monitor of platforms",
+			variants: []KernelVariant{
+				{Monitor: "firecracker", From: "local", Path: "vmlinux"},
+			},
+			errorText: "The list of kernels has no kernel for qemu",
+		},
+		{
+			name: "Unsupported monitor",
+			variants: []KernelVariant{
+				{Monitor: "qemu", From: "local", Path: "vmlinux"},
+				{Monitor: MonitorSolo5Virtio, From: "local", Path: "kernel.virtio"},
+			},
+			errorText: "Invalid kernel for solo5-virtio: The solo5-virtio monitor is supported only for mirage",
+		},
+		{
+			name: "Build for another monitor",
+			variants: []KernelVariant{
+				{Monitor: "qemu", From: "local", Path: "vmlinux"},
+				{Monitor: "firecracker", From: KernelFromBuild, Source: "linux"},
+			},
+			errorText: "The kernel for firecracker can not be built",
+		},
+		{
+			name: "Missing path for another monitor",
+			variants: []KernelVariant{
+				{Monitor: "qemu", From: "local", Path: "vmlinux"},
+				{Monitor: "firecracker", From: "local"},
+			},
+			errorText: "Invalid kernel for firecracker: The path field of kernel is necessary",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Hops{Platform: plat, Kernel: Kernel{Variants: tc.variants}}
+			require.ErrorContains(t, SelectKernel(h), tc.errorText)
+		})
+	}
+	t.Run("Monitor alias", func(t *testing.T) {
+		h := &Hops{Platform: plat, Kernel: Kernel{Variants: []KernelVariant{
+			{Monitor: "qemu", From: "local", Path: "vmlinux"},
+			{Monitor: "clh", From: "local", Path: "vmlinux-clh"},
+		}}}
+		require.NoError(t, SelectKernel(h))
+		require.Equal(t, "vmlinux", h.Kernel.Path)
+		require.Equal(t, MonitorCloudHypervisor, h.Kernel.Variants[1].Monitor)
+	})
+}
+
+func TestKernelsToPack(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(kernelsBunnyfile))
+	require.NoError(t, err)
+	instr, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, "qemu", instr.Annots["com.urunc.unikernel.hypervisor"])
+	require.Equal(t, DefaultKernelPath, instr.Annots["com.urunc.unikernel.binary"])
+	require.Equal(t, DefaultKernelPath, instr.Annots[KernelVariantAnnotPrefix+"qemu"])
+	require.Equal(t, "/.boot/kernel-firecracker", instr.Annots[KernelVariantAnnotPrefix+"firecracker"])
+	require.Equal(t, []CopyDecision{
+		{Name: "kernel", From: "local", Src: "vmlinux", Dst: DefaultKernelPath},
+		{Name: "rootfs", From: "local", Src: "rootfs", Dst: DefaultRootfsPath},
+		{Name: "kernel for firecracker", From: "harbor.nbfc.io/kernels/fc", Src: "/vmlinux", Dst: "/.boot/kernel-firecracker"},
+	}, instr.BaseDecision.Copies)
+
+	uruncJSON, err := UruncJSON(*instr)
+	require.NoError(t, err)
+	// urunc does not read them, so they stay out of urunc.json
+	require.NotContains(t, string(uruncJSON), KernelVariantAnnotPrefix)
+}
+
+func TestKernelsAnnotations(t *testing.T) {
+	require.True(t, isKernelVariantAnnotation(KernelVariantAnnotPrefix+"firecracker"))
+	require.False(t, isKernelVariantAnnotation(KernelVariantAnnotPrefix))
+	require.False(t, isKernelVariantAnnotation(KernelVariantAnnotPrefix+"qemu.extra"))
+	require.False(t, isKernelVariantAnnotation(KernelVariantAnnotPrefix+"qmeu"))
+	require.False(t, IsUruncAnnotation(KernelVariantAnnotPrefix+"firecracker"))
+	require.False(t, IsUruncAnnotation("com.urunc.unikernel.binary.firecracker"))
+	require.NoError(t, ValidateAnnotations(map[string]string{KernelVariantAnnotPrefix + "qemu": DefaultKernelPath}))
+
+	err := ValidateAnnotations(map[string]string{KernelVariantAnnotPrefix + "qmeu": DefaultKernelPath})
+	require.ErrorContains(t, err, `Unknown monitor "qmeu" in io.bunny.binary.qmeu, did you mean qemu?`)
+	err = ValidateAnnotations(map[string]string{KernelVariantAnnotPrefix + "bochs": DefaultKernelPath})
+	require.ErrorContains(t, err, `Unknown monitor "bochs" in io.bunny.binary.bochs`)
+	err = ValidateAnnotations(map[string]string{"com.urunc.unikernel.binary.qemu": DefaultKernelPath})
+	require.ErrorContains(t, err, "Unknown urunc annotation com.urunc.unikernel.binary.qemu")
+}
+
+func TestKernelsContextReferences(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: firecracker
+kernel:
+  - monitor: qemu
+    from: local
+    path: vmlinux-qemu
+  - monitor: firecracker
+    from: local
+    path: vmlinux-fc
+cmd: ["/init"]
+`))
+	require.NoError(t, err)
+	required, _ := ContextReferences(h)
+	require.ElementsMatch(t, []string{"vmlinux-fc", "vmlinux-qemu"}, required)
+}
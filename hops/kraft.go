@@ -0,0 +1,199 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+const (
+	// The value of kernel's from field to build the kernel from source
+	KernelFromBuild string = "build"
+	// The kernel, the log and the exit code of the build in the built state
+	BuiltKernelPath       string = "/kernel"
+	BuiltKernelLogPath    string = "/build.log"
+	BuiltKernelStatusPath string = "/build.status"
+)
+
+const (
+	defaultKraftImage string = "harbor.nbfc.io/nubificus/bunny/kraft:latest"
+	kraftSrcDir       string = "/src"
+	kraftConfigDir    string = "/kconfig"
+	kraftOutDir       string = "/out"
+	kraftCcacheDir    string = "/ccache"
+	kraftPackagesDir  string = "/root/.local/share/kraftkit"
+	// The number of lines of the build log to show when the build fails
+	buildLogTailLines int = 50
+)
+
+// The script that builds a unikraft application with kraft. The arguments
+// are the platform, the architecture and optionally the path of the kernel
+// inside the application directory. KConfig fragments get appended to the
+// configuration of the first build and the second build picks them up. The
+// packages get fetched before the build, following the network policy and
+// trusting any extra CA certificates. The output of the build is stored in a
+// log file and the script does not fail, so the log can be read even if the
+// build failed. The exit code of the build is stored in a separate file.
+const kraftBuildScript = `#!/bin/sh
+plat="$1"
+arch="$2"
+kernel="$3"
+export PATH="/usr/lib/ccache:$PATH"
+` + retryFunction + `
+` + certificatesFunction + `
+setup_certificates
+build() {
+	retry kraft pkg update
+	retry kraft pkg pull --plat "$plat" --arch "$arch" .
+	kraft build --plat "$plat" --arch "$arch" --log-type basic --no-prompt --no-update
+	if [ -s ` + kraftConfigDir + `/fragment ]; then
+		for config in .config*; do
+			cat ` + kraftConfigDir + `/fragment >> "$config"
+		done
+		kraft build --plat "$plat" --arch "$arch" --log-type basic --no-prompt --no-update --no-configure --no-fetch
+	fi
+	if [ -z "$kernel" ]; then
+		kernel=$(find .unikraft/build -maxdepth 1 -type f -name "*_${plat}-${arch}" | head -n 1)
+	fi
+	if [ ! -f "$kernel" ]; then
+		echo "Could not find the kernel for ${plat}/${arch}" >&2
+		return 1
+	fi
+	cp "$kernel" ` + kraftOutDir + BuiltKernelPath + `
+}
+{ (set -e; build); echo $? > ` + kraftOutDir + BuiltKernelStatusPath + `; } 2>&1 | tee ` + kraftOutDir + BuiltKernelLogPath + `
+`
+
+// isGitSource returns true if the source of the kernel is a git repository
+// and not a directory in the build context.
+func isGitSource(source string) bool {
+	return strings.Contains(source, "://") ||
+		strings.HasPrefix(source, "git@") ||
+		strings.HasSuffix(strings.SplitN(source, "#", 2)[0], ".git")
+}
+
+// kernelSource returns the state with the source of the application and the
+// directory of the application inside that state. Git repositories can pin a
+// branch, tag or commit after a '#'.
+func kernelSource(source string, in BuildInput) (llb.State, string) {
+	if isGitSource(source) {
+		repo, ref, _ := strings.Cut(source, "#")
+		if ref == "" {
+			ref = "HEAD"
+		}
+		return llb.Git(repo, ref, llb.KeepGitDir()), kraftSrcDir
+	}
+
+	return llb.Local(in.BuildContext, excludeOptions(in.Exclude)...), path.Join(kraftSrcDir, source)
+}
+
+// kraftPlatform returns the platform and the architecture of the kernel as
+// kraft names them.
+func kraftPlatform(monitor string, arch string) (string, string) {
+	plat := monitorPlatformOS(monitor)
+	switch normalizeArch(arch) {
+	case "amd64":
+		arch = "x86_64"
+	case "arm64":
+		arch = "arm64"
+	}
+
+	return plat, arch
+}
+
+// KraftBuildLLB creates a LLB State that builds a unikraft kernel from source
+// with kraft. The kernel is stored in BuiltKernelPath of the resulting state,
+// along with the log and the exit code of the build.
+func KraftBuildLLB(in BuildInput, arch string) (llb.State, error) {
+	k := in.Kernel
+	if k.Source == "" {
+		return llb.Scratch(), fmt.Errorf("The source of the kernel is necessary to build it")
+	}
+
+	src, workDir := kernelSource(k.Source, in)
+	fragment := ""
+	if len(k.Config) > 0 {
+		fragment = strings.Join(k.Config, "\n") + "\n"
+	}
+	buildFiles := llb.Scratch().
+		File(llb.Mkfile("/build.sh", 0755, []byte(kraftBuildScript))).
+		File(llb.Mkfile("/fragment", 0644, []byte(fragment)))
+
+	plat, kraftArch := kraftPlatform(in.Monitor, arch)
+	args := []string{
+		"/bin/sh", path.Join(kraftConfigDir, "build.sh"),
+		plat, kraftArch, k.Path,
+	}
+	runOpts := []llb.RunOption{
+		llb.Args(args),
+		llb.Dir(workDir),
+		llb.AddEnv("CCACHE_DIR", kraftCcacheDir),
+		llb.AddMount(kraftSrcDir, src),
+		llb.AddMount(kraftConfigDir, buildFiles, llb.Readonly),
+		llb.AddMount(kraftCcacheDir, llb.Scratch(),
+			llb.AsPersistentCacheDir("bunny-unikraft-ccache", llb.CacheMountShared)),
+		llb.AddMount(kraftPackagesDir, llb.Scratch(),
+			llb.AsPersistentCacheDir("bunny-kraft-packages", llb.CacheMountLocked)),
+		llb.WithCustomName("Build unikraft kernel for " + plat + "/" + kraftArch),
+	}
+	runOpts = append(runOpts, in.Network.RunOptions()...)
+	runOpts = append(runOpts, certificateOptions(in.Certificates, in.BuildContext)...)
+	runOpts = append(runOpts, hardenedOptions(in.Hardened, true)...)
+	runOpts = append(runOpts, in.Build.NetworkOptions(BuildStepKernel)...)
+	buildExec := llb.Image(defaultKraftImage).Run(runOpts...)
+
+	return buildExec.AddMount(kraftOutDir, llb.Scratch()), nil
+}
+
+// KernelBuildLLB creates the LLB definition of building the kernel, so the
+// result of the build can be checked before packing the image.
+func KernelBuildLLB(ctx context.Context, instr PackInstructions) (*llb.Definition, error) {
+	if instr.Kernel == nil || instr.Kernel.SourceRef != KernelFromBuild {
+		return nil, fmt.Errorf("No kernel to build")
+	}
+
+	return marshalState(ctx, instr.Kernel.SourceState, instr.Sources)
+}
+
+// CheckKernelBuild reads the exit code of the kernel build from its result and
+// returns an error with the last lines of the build log, if the build failed.
+func CheckKernelBuild(ctx context.Context, ref client.Reference) error {
+	status, err := ref.ReadFile(ctx, client.ReadRequest{Filename: BuiltKernelStatusPath})
+	if err != nil {
+		return fmt.Errorf("Failed to read the status of the kernel build: %v", err)
+	}
+	if strings.TrimSpace(string(status)) == "0" {
+		return nil
+	}
+
+	log, err := ref.ReadFile(ctx, client.ReadRequest{Filename: BuiltKernelLogPath})
+	if err != nil {
+		return fmt.Errorf("The kernel build failed with exit code %s and its log is not available: %v",
+			strings.TrimSpace(string(status)), err)
+	}
+	lines := strings.Split(strings.TrimRight(string(log), "\n"), "\n")
+	if len(lines) > buildLogTailLines {
+		lines = lines[len(lines)-buildLogTailLines:]
+	}
+
+	return fmt.Errorf("The kernel build failed with exit code %s, last lines of %s:\n%s",
+		strings.TrimSpace(string(status)), BuiltKernelLogPath, strings.Join(lines, "\n"))
+}
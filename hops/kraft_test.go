@@ -0,0 +1,149 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKraftSource(t *testing.T) {
+	tests := []struct {
+		source string
+		git    bool
+	}{
+		{source: "https://github.com/unikraft/app-nginx.git", git: true},
+		{source: "https://github.com/unikraft/app-nginx#stable", git: true},
+		{source: "git@github.com:unikraft/app-nginx.git", git: true},
+		{source: "app.git#v0.1", git: true},
+		{source: "app", git: false},
+		{source: ".", git: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.source, func(t *testing.T) {
+			require.Equal(t, tc.git, isGitSource(tc.source))
+		})
+	}
+}
+
+func TestKraftPlatform(t *testing.T) {
+	plat, arch := kraftPlatform("firecracker", "amd64")
+	require.Equal(t, "fc", plat)
+	require.Equal(t, "x86_64", arch)
+	plat, arch = kraftPlatform("qemu", "aarch64")
+	require.Equal(t, "qemu", plat)
+	require.Equal(t, "arm64", arch)
+	plat, _ = kraftPlatform("xen", "amd64")
+	require.Equal(t, "xen", plat)
+}
+
+func TestKraftToPack(t *testing.T) {
+	h := &Hops{
+		Platform: Platform{
+			Framework: "unikraft",
+			Monitor:   "qemu",
+			Arch:      "x86_64",
+		},
+		Kernel: Kernel{
+			From:   KernelFromBuild,
+			Source: "app",
+		},
+	}
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, "", i.BaseRef)
+	require.Nil(t, i.KernelCheck)
+	require.Equal(t, 1, len(i.Copies))
+	require.Equal(t, BuiltKernelPath, i.Copies[0].SrcPath)
+	require.Equal(t, DefaultKernelPath, i.Copies[0].DstPath)
+	require.Equal(t, DefaultKernelPath, i.Annots["com.urunc.unikernel.binary"])
+
+	h.Kernel.Source = ""
+	_, err = ToPack(context.TODO(), h, "context")
+	require.ErrorContains(t, err, "Could not build kernel")
+}
+
+func TestKraftBuildLLB(t *testing.T) {
+	_, err := KernelBuildLLB(context.TODO(), PackInstructions{})
+	require.ErrorContains(t, err, "No kernel to build")
+
+	h := &Hops{
+		Platform: Platform{Framework: "unikraft", Monitor: "qemu"},
+		Kernel:   Kernel{From: KernelFromBuild, Source: "app"},
+	}
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	def, err := KernelBuildLLB(context.TODO(), *i)
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var exec int
+	for _, op := range arr {
+		if op.GetExec() != nil {
+			exec++
+		}
+	}
+	require.Equal(t, 1, exec)
+
+	// The kernel target contains the log of the build too
+	def, err = TargetLLB(context.TODO(), *i, TargetKernel)
+	require.NoError(t, err)
+	_, arr = parseDef(t, def.Def)
+	for _, op := range arr {
+		for _, action := range op.GetFile().GetActions() {
+			require.Nil(t, action.GetCopy(), "expected no copy of the kernel")
+		}
+	}
+}
+
+func TestKraftCheckBuild(t *testing.T) {
+	var log []string
+	for i := 1; i <= 60; i++ {
+		log = append(log, fmt.Sprintf("line %d", i))
+	}
+
+	t.Run("Successful build", func(t *testing.T) {
+		ref := &fakeRef{files: map[string][]byte{
+			BuiltKernelStatusPath: []byte("0\n"),
+		}}
+		require.NoError(t, CheckKernelBuild(context.TODO(), ref))
+	})
+	t.Run("Failed build", func(t *testing.T) {
+		ref := &fakeRef{files: map[string][]byte{
+			BuiltKernelStatusPath: []byte("2\n"),
+			BuiltKernelLogPath:    []byte(strings.Join(log, "\n") + "\n"),
+		}}
+		err := CheckKernelBuild(context.TODO(), ref)
+		require.ErrorContains(t, err, "The kernel build failed with exit code 2")
+		require.ErrorContains(t, err, "line 11\n")
+		require.ErrorContains(t, err, "line 60")
+		require.NotContains(t, err.Error(), "line 10\n")
+	})
+	t.Run("Failed build without log", func(t *testing.T) {
+		ref := &fakeRef{files: map[string][]byte{
+			BuiltKernelStatusPath: []byte("1\n"),
+		}}
+		err := CheckKernelBuild(context.TODO(), ref)
+		require.ErrorContains(t, err, "its log is not available")
+	})
+	t.Run("Missing status", func(t *testing.T) {
+		err := CheckKernelBuild(context.TODO(), &fakeRef{})
+		require.ErrorContains(t, err, "Failed to read the status of the kernel build")
+	})
+}
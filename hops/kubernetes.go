@@ -0,0 +1,199 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// A manifest with a single Pod
+	KubernetesKindPod string = "Pod"
+	// A manifest with a Deployment of one replica
+	KubernetesKindDeployment string = "Deployment"
+)
+
+const (
+	// The runtime class of urunc in Kubernetes
+	kubernetesRuntimeClass string = "urunc"
+	// The maximum length of a Kubernetes name
+	kubernetesMaxName int = 63
+)
+
+var (
+	memoryQuantityRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(k|M|G|T|Ki|Mi|Gi|Ti)?$`)
+	cpuQuantityRegexp    = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?|[0-9]+m)$`)
+	nonNameRegexp        = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// Resources defines the resources that the unikernel needs, as Kubernetes
+// quantities, for the manifests that bunny renders
+type Resources struct {
+	// The memory of the unikernel, e.g. 256Mi
+	Memory string `yaml:"memory"`
+	// The CPUs of the unikernel, e.g. 1 or 500m
+	CPU string `yaml:"cpu"`
+}
+
+// ParseKubernetesKind checks that the given kind of manifest is supported. An
+// empty kind means a Deployment.
+func ParseKubernetesKind(kind string) (string, error) {
+	switch kind {
+	case "", KubernetesKindDeployment:
+		return KubernetesKindDeployment, nil
+	case KubernetesKindPod:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("Unknown kind %s, expected one of %s, %s",
+			kind, KubernetesKindDeployment, KubernetesKindPod)
+	}
+}
+
+// OutputImageName returns the first name of the image in the given output
+// spec of buildctl (e.g. type=image,name=registry.local/app:v1,push=true), or
+// an empty string if the output has no name.
+func OutputImageName(output string) string {
+	for field := range strings.SplitSeq(output, ",") {
+		name, ok := strings.CutPrefix(strings.TrimSpace(field), "name=")
+		if ok {
+			return strings.Trim(name, `"`)
+		}
+	}
+
+	return ""
+}
+
+// kubernetesName returns a valid Kubernetes name for the workload of the
+// image, based on the last component of its repository.
+func kubernetesName(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse image name %s: %v", image, err)
+	}
+	repo := reference.Path(named)
+	name := nonNameRegexp.ReplaceAllString(strings.ToLower(repo[strings.LastIndex(repo, "/")+1:]), "-")
+	if len(name) > kubernetesMaxName {
+		name = name[:kubernetesMaxName]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "", fmt.Errorf("Could not derive a name from image %s", image)
+	}
+
+	return name, nil
+}
+
+// kubernetesResources returns the requests and limits of the container, or
+// nil if the unikernel defines no resources. The unikernel gets exactly the
+// resources it asks for, so the requests and the limits are the same.
+func kubernetesResources(res Resources) map[string]map[string]string {
+	quantities := map[string]string{}
+	if res.Memory != "" {
+		quantities["memory"] = res.Memory
+	}
+	if res.CPU != "" {
+		quantities["cpu"] = res.CPU
+	}
+	if len(quantities) == 0 {
+		return nil
+	}
+
+	return map[string]map[string]string{
+		"requests": quantities,
+		"limits":   quantities,
+	}
+}
+
+// KubernetesManifest renders a manifest of the given kind that runs the
+// given image with urunc, with the given resources.
+func KubernetesManifest(image string, kind string, res Resources) ([]byte, error) {
+	kind, err := ParseKubernetesKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	name, err := kubernetesName(image)
+	if err != nil {
+		return nil, err
+	}
+
+	type container struct {
+		Name      string                       `yaml:"name"`
+		Image     string                       `yaml:"image"`
+		Resources map[string]map[string]string `yaml:"resources,omitempty"`
+	}
+	type podSpec struct {
+		RuntimeClassName string      `yaml:"runtimeClassName"`
+		Containers       []container `yaml:"containers"`
+	}
+	type metadata struct {
+		Name   string            `yaml:"name,omitempty"`
+		Labels map[string]string `yaml:"labels"`
+	}
+	labels := map[string]string{"app": name}
+	spec := podSpec{
+		RuntimeClassName: kubernetesRuntimeClass,
+		Containers: []container{{
+			Name:      name,
+			Image:     image,
+			Resources: kubernetesResources(res),
+		}},
+	}
+
+	var manifest any
+	switch kind {
+	case KubernetesKindPod:
+		manifest = struct {
+			APIVersion string   `yaml:"apiVersion"`
+			Kind       string   `yaml:"kind"`
+			Metadata   metadata `yaml:"metadata"`
+			Spec       podSpec  `yaml:"spec"`
+		}{"v1", kind, metadata{Name: name, Labels: labels}, spec}
+	default:
+		type template struct {
+			Metadata metadata `yaml:"metadata"`
+			Spec     podSpec  `yaml:"spec"`
+		}
+		type selector struct {
+			MatchLabels map[string]string `yaml:"matchLabels"`
+		}
+		type deploymentSpec struct {
+			Replicas int      `yaml:"replicas"`
+			Selector selector `yaml:"selector"`
+			Template template `yaml:"template"`
+		}
+		manifest = struct {
+			APIVersion string         `yaml:"apiVersion"`
+			Kind       string         `yaml:"kind"`
+			Metadata   metadata       `yaml:"metadata"`
+			Spec       deploymentSpec `yaml:"spec"`
+		}{"apps/v1", kind, metadata{Name: name, Labels: labels}, deploymentSpec{
+			Replicas: 1,
+			Selector: selector{MatchLabels: labels},
+			Template: template{Metadata: metadata{Labels: labels}, Spec: spec},
+		}}
+	}
+
+	out, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal the manifest: %v", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestKubernetesManifest(t *testing.T) {
+	t.Run("Deployment", func(t *testing.T) {
+		out, err := KubernetesManifest("registry.local/apps/nginx_unikraft:v1", "", Resources{Memory: "256Mi", CPU: "500m"})
+		require.NoError(t, err)
+		require.YAMLEq(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-unikraft
+  labels:
+    app: nginx-unikraft
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: nginx-unikraft
+  template:
+    metadata:
+      labels:
+        app: nginx-unikraft
+    spec:
+      runtimeClassName: urunc
+      containers:
+        - name: nginx-unikraft
+          image: registry.local/apps/nginx_unikraft:v1
+          resources:
+            requests:
+              memory: 256Mi
+              cpu: 500m
+            limits:
+              memory: 256Mi
+              cpu: 500m
+`, string(out))
+	})
+	t.Run("Pod without resources", func(t *testing.T) {
+		out, err := KubernetesManifest("nginx", KubernetesKindPod, Resources{})
+		require.NoError(t, err)
+		require.YAMLEq(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  labels:
+    app: nginx
+spec:
+  runtimeClassName: urunc
+  containers:
+    - name: nginx
+      image: nginx
+`, string(out))
+	})
+	t.Run("Unknown kind", func(t *testing.T) {
+		_, err := KubernetesManifest("nginx", "Job", Resources{})
+		require.ErrorContains(t, err, "Unknown kind Job")
+	})
+	t.Run("Invalid image", func(t *testing.T) {
+		_, err := KubernetesManifest("Invalid Image", KubernetesKindPod, Resources{})
+		require.ErrorContains(t, err, "Failed to parse image name")
+	})
+}
+
+func TestKubernetesResources(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(`
+version: ` + Version + `
+platforms:
+  framework: unikraft
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+resources:
+  memory: 1Gi
+  cpu: 2
+`))
+	require.NoError(t, err)
+	require.Equal(t, Resources{Memory: "1Gi", CPU: "2"}, h.Resources)
+
+	out, err := KubernetesManifest("nginx", KubernetesKindPod, h.Resources)
+	require.NoError(t, err)
+	var pod struct {
+		Spec struct {
+			Containers []struct {
+				Resources map[string]map[string]string `yaml:"resources"`
+			} `yaml:"containers"`
+		} `yaml:"spec"`
+	}
+	require.NoError(t, yaml.Unmarshal(out, &pod))
+	require.Equal(t, "2", pod.Spec.Containers[0].Resources["limits"]["cpu"])
+
+	_, err = ParseBunnyfile([]byte(`
+version: ` + Version + `
+platforms:
+  framework: unikraft
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+resources:
+  memory: 1GB
+`))
+	require.ErrorIs(t, err, errInvalidBunnyfile)
+}
+
+func TestKubernetesOutputImageName(t *testing.T) {
+	require.Equal(t, "registry.local/app:v1", OutputImageName("type=image,name=registry.local/app:v1,push=true"))
+	require.Equal(t, "app", OutputImageName(`type=docker, name="app"`))
+	require.Equal(t, "", OutputImageName("type=oci,tar=false,dest=image"))
+}
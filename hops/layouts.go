@@ -0,0 +1,268 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Layouts maps an image reference to a directory in the build context,
+// which contains the image in OCI layout.
+type Layouts map[string]string
+
+// ParseLayouts parses a comma separated list of image=directory pairs.
+func ParseLayouts(s string) (Layouts, error) {
+	layouts := Layouts{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		ref, dir, found := strings.Cut(pair, "=")
+		if !found || ref == "" || dir == "" {
+			return nil, fmt.Errorf("Invalid OCI layout %q, expected image=directory", pair)
+		}
+		layouts[ref] = dir
+	}
+
+	return layouts, nil
+}
+
+// LayoutImage is an image stored in OCI layout inside the build context
+type LayoutImage struct {
+	// The name of the build context
+	BuildContext string
+	// The directory of the OCI layout inside the build context
+	Dir string
+	// The manifest of the image for the host architecture
+	Manifest ocispecs.Manifest
+	// The raw OCI config of the image
+	Config []byte
+}
+
+// LayoutImages maps the normalized reference of an image to its OCI layout
+type LayoutImages map[string]LayoutImage
+
+func normalizeRef(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", fmt.Errorf("Invalid image reference %s: %v", ref, err)
+	}
+
+	return reference.TagNameOnly(named).String(), nil
+}
+
+// LoadLayouts reads the index, the manifest and the config of every image in
+// layouts, using read to access the files of the build context.
+func LoadLayouts(layouts Layouts, buildContext string, read func(string) ([]byte, error)) (LayoutImages, error) {
+	images := LayoutImages{}
+	for ref, dir := range layouts {
+		name, err := normalizeRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		dir = path.Clean(dir)
+		if path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf("The OCI layout of %s should be inside the build context", ref)
+		}
+
+		content, err := read(path.Join(dir, ocispecs.ImageIndexFile))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read index of OCI layout %s: %v", dir, err)
+		}
+		var idx ocispecs.Index
+		err = json.Unmarshal(content, &idx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal index of OCI layout %s: %v", dir, err)
+		}
+		manifest, err := selectManifest(read, dir, idx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to find image manifest in %s: %v", dir, err)
+		}
+		configPath, err := blobPath(dir, manifest.Config.Digest)
+		if err != nil {
+			return nil, err
+		}
+		config, err := read(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read config of %s: %v", ref, err)
+		}
+
+		images[name] = LayoutImage{
+			BuildContext: buildContext,
+			Dir:          dir,
+			Manifest:     manifest,
+			Config:       config,
+		}
+	}
+
+	return images, nil
+}
+
+// Lookup returns the image in OCI layout for the given reference
+func (l LayoutImages) Lookup(ref string) (LayoutImage, bool) {
+	if len(l) == 0 {
+		return LayoutImage{}, false
+	}
+	name, err := normalizeRef(ref)
+	if err != nil {
+		return LayoutImage{}, false
+	}
+	img, ok := l[name]
+
+	return img, ok
+}
+
+// State returns the rootfs of the image, unpacking its layers from the
+// build context.
+func (i LayoutImage) State() llb.State {
+	blobs := llb.Local(i.BuildContext,
+		llb.IncludePatterns([]string{path.Join(i.Dir, "blobs")}),
+		llb.WithCustomName("Internal:Read OCI layout "+i.Dir))
+	st := llb.Scratch()
+	for _, layer := range i.Manifest.Layers {
+		// The digest was validated when we read the manifest
+		p, _ := blobPath(i.Dir, layer.Digest)
+		st = st.File(llb.Copy(blobs, p, "/", &llb.CopyInfo{
+			AttemptUnpack:  true,
+			CreateDestPath: true,
+		}))
+	}
+
+	return st
+}
+
+// layoutResolver resolves the image metadata from the OCI layouts and falls
+// back to the given resolver for any other image.
+type layoutResolver struct {
+	resolver llb.ImageMetaResolver
+	images   LayoutImages
+}
+
+func (r layoutResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	if img, ok := r.images.Lookup(ref); ok {
+		// Do not return the digest, so the reference does not get pinned
+		// and it still matches the OCI layout.
+		return ref, "", img.Config, nil
+	}
+	if r.resolver == nil {
+		return "", "", nil, fmt.Errorf("Can not resolve %s without access to a registry", ref)
+	}
+
+	return r.resolver.ResolveImageConfig(ctx, ref, opt)
+}
+
+// MetaResolver wraps the given resolver, so that the metadata of images in
+// OCI layouts are read from the build context.
+func (l LayoutImages) MetaResolver(resolver llb.ImageMetaResolver) llb.ImageMetaResolver {
+	if len(l) == 0 {
+		return resolver
+	}
+
+	return layoutResolver{resolver: resolver, images: l}
+}
+
+// ApplyLayouts replaces all image sources of the LLB definition that have an
+// OCI layout in the build context with the contents of the layout.
+func ApplyLayouts(ctx context.Context, def *llb.Definition, images LayoutImages, arch string) (*llb.Definition, error) {
+	if len(images) == 0 {
+		return def, nil
+	}
+
+	return rewriteSources(def, func(src *pb.SourceOp) (*llb.Definition, error) {
+		if !strings.HasPrefix(src.Identifier, dockerImageScheme) {
+			return nil, nil
+		}
+		img, ok := images.Lookup(strings.TrimPrefix(src.Identifier, dockerImageScheme))
+		if !ok {
+			return nil, nil
+		}
+
+		return marshalPlatform(ctx, img.State(), arch)
+	})
+}
+
+// SourceOpts contains the options that define where images come from and
+// how they get fetched
+type SourceOpts struct {
+	// The registry mirrors
+	Mirrors Mirrors
+	// The images that are stored in OCI layouts inside the build context
+	Layouts LayoutImages
+	// How network-bound steps should behave
+	Network NetworkPolicy
+	// The architecture of the buildkit worker, the host's by default
+	Arch string
+	// Run the tools from pinned images with a read-only root and without
+	// network, if possible
+	Hardened bool
+	// Resolves the digests of the tool images in hardened mode
+	Resolver llb.ImageMetaResolver
+	// Records, and optionally restricts, the digests of the tool images,
+	// which get pinned even without hardened mode
+	Tools *ToolDigests
+	// The options of the exec operations of each step
+	Build BuildOptions
+	// How to pull images from the unikraft.org catalog
+	UnikraftPull string
+	// Check bunnyfiles against the stricter typed schema
+	StrictSchema bool
+	// The build arguments of Containerfiles and of the conditions of
+	// bunnyfiles
+	BuildArgs map[string]string
+	// Fail before the build if a local file to include is missing
+	CheckIncludes bool
+	// The patterns of the files of the build context to leave out, e.g. of
+	// the .bunnyignore file
+	Exclude []string
+}
+
+// MetaResolver wraps the given resolver to take into account both the OCI
+// layouts and the mirrors.
+func (o SourceOpts) MetaResolver(resolver llb.ImageMetaResolver) llb.ImageMetaResolver {
+	return o.Layouts.MetaResolver(o.Mirrors.MetaResolver(resolver))
+}
+
+// imageConfig returns the OCI config of the given image, either from its
+// OCI layout or from the registry.
+func (o SourceOpts) imageConfig(ctx context.Context, c client.Client, ref string, mon string, arch string) (ocispecs.Image, error) {
+	if img, ok := o.Layouts.Lookup(ref); ok {
+		var cfg ocispecs.Image
+		err := json.Unmarshal(img.Config, &cfg)
+		if err != nil {
+			return ocispecs.Image{}, fmt.Errorf("failed to unmarshal image config of %s: %v", ref, err)
+		}
+		return cfg, nil
+	}
+
+	plat, err := o.configPlatform(ref, mon, arch)
+	if err != nil {
+		return ocispecs.Image{}, err
+	}
+
+	return getBaseConfig(ctx, c, o.Mirrors.Rewrite(ref), plat)
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// memLayout creates the files of an OCI layout under dir with a config
+// and the given number of layers.
+func memLayout(t *testing.T, files map[string][]byte, dir string, cfg ocispecs.Image, layers int) {
+	addBlob := func(content []byte) digest.Digest {
+		dgst := digest.FromBytes(content)
+		files[path.Join(dir, "blobs", dgst.Algorithm().String(), dgst.Encoded())] = content
+		return dgst
+	}
+	cfgBytes, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	manifest := ocispecs.Manifest{
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Config: ocispecs.Descriptor{
+			MediaType: ocispecs.MediaTypeImageConfig,
+			Digest:    addBlob(cfgBytes),
+		},
+	}
+	for i := 0; i < layers; i++ {
+		manifest.Layers = append(manifest.Layers, ocispecs.Descriptor{
+			MediaType: ocispecs.MediaTypeImageLayer,
+			Digest:    addBlob([]byte(fmt.Sprintf("%s layer %d", dir, i))),
+		})
+	}
+	mBytes, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	idx := ocispecs.Index{
+		Manifests: []ocispecs.Descriptor{
+			{
+				MediaType: ocispecs.MediaTypeImageManifest,
+				Digest:    addBlob(mBytes),
+			},
+		},
+	}
+	iBytes, err := json.Marshal(idx)
+	require.NoError(t, err)
+	files[path.Join(dir, ocispecs.ImageIndexFile)] = iBytes
+}
+
+func memReader(files map[string][]byte) func(string) ([]byte, error) {
+	return func(p string) ([]byte, error) {
+		content, ok := files[p]
+		if !ok {
+			return nil, fmt.Errorf("%s not found", p)
+		}
+		return content, nil
+	}
+}
+
+func TestLayoutsParse(t *testing.T) {
+	l, err := ParseLayouts("alpine:3.20=vendor/alpine, harbor.nbfc.io/nubificus/bunny/libarchive:latest=vendor/libarchive")
+	require.NoError(t, err)
+	require.Equal(t, Layouts{
+		"alpine:3.20": "vendor/alpine",
+		"harbor.nbfc.io/nubificus/bunny/libarchive:latest": "vendor/libarchive",
+	}, l)
+	l, err = ParseLayouts("")
+	require.NoError(t, err)
+	require.Equal(t, 0, len(l))
+	_, err = ParseLayouts("alpine")
+	require.ErrorContains(t, err, "Invalid OCI layout \"alpine\"")
+}
+
+func TestLayoutsLoad(t *testing.T) {
+	files := map[string][]byte{}
+	cfg := ocispecs.Image{Config: ocispecs.ImageConfig{Env: []string{"FOO=bar"}}}
+	memLayout(t, files, "vendor/alpine", cfg, 2)
+
+	t.Run("Valid", func(t *testing.T) {
+		images, err := LoadLayouts(Layouts{"alpine": "./vendor/alpine/"}, "context", memReader(files))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(images))
+		img, ok := images.Lookup("docker.io/library/alpine:latest")
+		require.True(t, ok)
+		require.Equal(t, "context", img.BuildContext)
+		require.Equal(t, "vendor/alpine", img.Dir)
+		require.Equal(t, 2, len(img.Manifest.Layers))
+		var got ocispecs.Image
+		require.NoError(t, json.Unmarshal(img.Config, &got))
+		require.Equal(t, cfg.Config.Env, got.Config.Env)
+		_, ok = images.Lookup("alpine:3.20")
+		require.False(t, ok)
+	})
+	t.Run("Invalid missing layout", func(t *testing.T) {
+		_, err := LoadLayouts(Layouts{"alpine": "vendor/foo"}, "context", memReader(files))
+		require.ErrorContains(t, err, "Failed to read index of OCI layout vendor/foo")
+	})
+	t.Run("Invalid outside of context", func(t *testing.T) {
+		_, err := LoadLayouts(Layouts{"alpine": "../alpine"}, "context", memReader(files))
+		require.ErrorContains(t, err, "should be inside the build context")
+		_, err = LoadLayouts(Layouts{"alpine": "/alpine"}, "context", memReader(files))
+		require.ErrorContains(t, err, "should be inside the build context")
+	})
+}
+
+func TestLayoutsMetaResolver(t *testing.T) {
+	files := map[string][]byte{}
+	memLayout(t, files, "vendor/alpine", ocispecs.Image{}, 1)
+	images, err := LoadLayouts(Layouts{"alpine": "vendor/alpine"}, "context", memReader(files))
+	require.NoError(t, err)
+
+	r := &fakeResolver{}
+	resolver := SourceOpts{
+		Mirrors: Mirrors{"docker.io": "mirror.local"},
+		Layouts: images,
+	}.MetaResolver(r)
+	ref, dgst, config, err := resolver.ResolveImageConfig(context.TODO(), "alpine", sourceresolver.Opt{})
+	require.NoError(t, err)
+	require.Equal(t, "alpine", ref)
+	require.Empty(t, dgst)
+	require.Equal(t, images["docker.io/library/alpine:latest"].Config, config)
+	require.Empty(t, r.refs)
+
+	_, _, _, err = resolver.ResolveImageConfig(context.TODO(), "busybox", sourceresolver.Opt{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"mirror.local/library/busybox"}, r.refs)
+
+	_, _, _, err = images.MetaResolver(nil).ResolveImageConfig(context.TODO(), "busybox", sourceresolver.Opt{})
+	require.ErrorContains(t, err, "Can not resolve busybox without access to a registry")
+}
+
+func TestLayoutsApply(t *testing.T) {
+	files := map[string][]byte{}
+	memLayout(t, files, "vendor/libarchive", ocispecs.Image{}, 2)
+	images, err := LoadLayouts(Layouts{defaultBsdcpioImage: "vendor/libarchive"}, "context", memReader(files))
+	require.NoError(t, err)
+
+	instr := PackInstructions{
+		Base: llb.Scratch(),
+		Copies: []PackCopies{
+			{
+				SrcState: InitrdLLB(llb.Local("context"), Owner{}),
+				SrcPath:  DefaultRootfsPath,
+				DstPath:  DefaultRootfsPath,
+			},
+			{
+				SrcState: llb.Image("alpine"),
+				SrcPath:  "/bin/sh",
+				DstPath:  "/bin/sh",
+			},
+		},
+		Annots: map[string]string{},
+		Sources: SourceOpts{
+			Mirrors: Mirrors{"docker.io": "mirror.local"},
+			Layouts: images,
+		},
+	}
+	result, err := PackLLB(context.TODO(), instr)
+	require.NoError(t, err)
+	m, arr := parseDef(t, result.Def)
+	var sources []string
+	unpacks := 0
+	for _, op := range arr {
+		switch o := op.Op.(type) {
+		case *pb.Op_Source:
+			sources = append(sources, o.Source.Identifier)
+		case *pb.Op_File:
+			for _, a := range o.File.Actions {
+				if c, ok := a.Action.(*pb.FileAction_Copy); ok && c.Copy.AttemptUnpackDockerCompatibility {
+					unpacks++
+				}
+			}
+		}
+		for _, input := range op.Inputs {
+			require.Contains(t, m, input.Digest)
+		}
+	}
+	require.NotContains(t, sources, "docker-image://"+defaultBsdcpioImage)
+	require.Contains(t, sources, "docker-image://mirror.local/library/alpine:latest")
+	require.Contains(t, sources, "local://context")
+	require.Equal(t, 2, unpacks)
+}
@@ -15,51 +15,121 @@
 package hops
 
 import (
-	"runtime"
+	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/distribution/reference"
 	"github.com/moby/buildkit/client/llb"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 const (
 	defaultBsdcpioImage string = "harbor.nbfc.io/nubificus/bunny/libarchive:latest"
+	// The command that writes the initrd of the current directory in its
+	// output, with the owner of the files as an argument
+	initrdCommand string = "find . -exec touch -h -d @0 {} + && " +
+		"find . -print | LC_ALL=C sort | bsdcpio -o -R %s --format newc"
+	// The most files that a single operation of FilesLLB copies
+	filesPerCopyOp int = 1000
 )
 
 // Create a LLB State that simply copies all the files in the include list inside
-// an empty image
-func FilesLLB(fileList []FileToInclude, buildContext string, toState llb.State) llb.State {
-	retState := llb.Scratch()
-	local := llb.Local(buildContext)
-	for i, file := range fileList {
-		var aCopy PackCopies
-
-		fromState := local
-		if file.From != "" && file.From != "local" {
-			fromState = llb.Image(file.From)
+// an empty image. All the copies are actions of a single file operation, since
+// every operation on top of another one makes marshaling the whole chain slower,
+// which adds up for include lists of thousands of files. Longer lists get split
+// in chunks of filesPerCopyOp files. Over scratch, each chunk gets copied in
+// scratch by its own operation and the chunks get merged in the order of the
+// list, so they get solved in parallel and a change to a file invalidates only
+// the cache of its own chunk. Over any other state, the operations of the
+// chunks run one after the other on top of it, as a single operation would,
+// since the parent directories that a copy in scratch creates would replace
+// the modes, the owners and the symlinks of the directories of toState. The
+// exclude patterns of a file leave out the matching files of its directory.
+func FilesLLB(fileList []FileToInclude, buildContext string, toState llb.State, opts ...llb.LocalOption) llb.State {
+	if len(fileList) == 0 {
+		return llb.Scratch()
+	}
+
+	local := llb.Local(buildContext, opts...)
+	// Files from the same image share its state
+	images := map[string]llb.State{}
+	copyInfo := &llb.CopyInfo{CreateDestPath: true}
+	copyFiles := func(files []FileToInclude) *llb.FileAction {
+		var copies *llb.FileAction
+		for _, file := range files {
+			fromState := local
+			if file.From != "" && file.From != "local" {
+				image, ok := images[file.From]
+				if !ok {
+					image = llb.Image(file.From)
+					images[file.From] = image
+				}
+				fromState = image
+			}
+			info := copyInfo
+			if len(file.Exclude) != 0 || file.Mode != "" || file.Owner != "" {
+				info = fileCopyInfo(file)
+			}
+			copies = copies.Copy(fromState, file.Src, file.Dst, info)
 		}
-		aCopy.SrcState = fromState
-		aCopy.SrcPath = file.Src
-		aCopy.DstPath = file.Dst
-		if i == 0 {
-			retState = CopyLLB(toState, aCopy)
-		} else {
-			retState = CopyLLB(retState, aCopy)
+		return copies
+	}
+	if len(fileList) <= filesPerCopyOp {
+		return toState.File(copyFiles(fileList))
+	}
+
+	if toState.Output() != nil {
+		for chunk := range slices.Chunk(fileList, filesPerCopyOp) {
+			toState = toState.File(copyFiles(chunk))
 		}
+		return toState
+	}
+
+	layers := make([]llb.State, 0, len(fileList)/filesPerCopyOp+1)
+	for chunk := range slices.Chunk(fileList, filesPerCopyOp) {
+		layers = append(layers, llb.Scratch().File(copyFiles(chunk)))
 	}
 
-	return retState
+	return llb.Merge(layers)
+}
+
+// fileCopyInfo returns the options of the copy of a file with exclude
+// patterns, a mode or an owner. The mode and the owner are already validated,
+// so they do not fail to parse.
+func fileCopyInfo(file FileToInclude) *llb.CopyInfo {
+	info := &llb.CopyInfo{CreateDestPath: true, ExcludePatterns: file.Exclude}
+	if file.Mode != "" {
+		mode, _ := ParseMode(file.Mode)
+		info.Mode = &llb.ChmodOpt{Mode: mode}
+	}
+	if file.Owner != "" {
+		owner, _ := ParseOwner(file.Owner)
+		owner.copyOption().SetCopyOption(info)
+	}
+
+	return info
 }
 
 // Create a LLB State that constructs a cpio file with the data in the content
-// State
-func InitrdLLB(content llb.State) llb.State {
+// State. The files are sorted by name and their owner and modification time
+// are normalized to the given owner (root for the zero value) and the epoch,
+// so the initrd does not depend on the builder. The content is mounted writable to normalize the times, but the
+// changes are discarded. Any extra options are passed to the exec operation.
+// The exec runs without a network namespace, since setting one up is what
+// usually fails with rootless buildkitd. Where the exec fails anyway,
+// InitrdFileLLB creates the same initrd with file operations only.
+func InitrdLLB(content llb.State, owner Owner, opts ...llb.RunOption) llb.State {
 	outDir := "/.boot"
 	workDir := "/workdir"
 	toolSet := llb.Image(defaultBsdcpioImage, llb.WithCustomName("Internal:Create initrd")).
 		File(llb.Mkdir("/tmp", 0755))
-	cpioExec := toolSet.Dir(workDir).
-		Run(llb.Shlexf("sh -c \"find . -depth -print | tac | bsdcpio -o --format newc > %s\"", DefaultRootfsPath), llb.AddMount(workDir, content, llb.Readonly))
+	runOpts := append([]llb.RunOption{
+		llb.Shlexf("sh -c \"%s > %s\"", fmt.Sprintf(initrdCommand, owner), DefaultRootfsPath),
+		llb.AddMount(workDir, content),
+		llb.Network(llb.NetModeNone),
+	}, opts...)
+	cpioExec := toolSet.Dir(workDir).Run(runOpts...)
 	base := llb.Scratch().File(llb.Mkdir(outDir, 0755))
 	return base.With(getArtifacts(cpioExec, outDir))
 }
@@ -78,23 +148,68 @@ func CopyLLB(to llb.State, from PackCopies) llb.State {
 	return copyState
 }
 
-// Set the source llb state from the sourceRef image and also set
-// the appropriate platform for unikraft images.
-func GetSourceState(sourceRef string, monitor string) llb.State {
-	if monitor == "firecracker" {
-		monitor = "fc"
+// ResolveSourceRef returns the canonical reference of a source image and the
+// platform to pull it for. The reference gets the registry and the latest
+// tag, if it has neither a tag nor a digest, e.g. foo becomes
+// docker.io/library/foo:latest, while digests and ports of the registry stay
+// as they are. Images of unikraft.org have a manifest per monitor, instead of
+// per OS, so they get the platform of the monitor and the architecture (the
+// host's if empty). Other images get a nil platform, which is the one of the
+// buildkit worker.
+func ResolveSourceRef(sourceRef string, monitor string, arch string) (string, *ocispecs.Platform, error) {
+	named, err := reference.ParseNormalizedNamed(sourceRef)
+	if err != nil {
+		return "", nil, fmt.Errorf("Invalid image reference %q: %v", sourceRef, err)
 	}
+	canonical := reference.TagNameOnly(named).String()
+	if !isUnikraftNamed(named) {
+		return canonical, nil, nil
+	}
+
+	return canonical, &ocispecs.Platform{
+		OS:           monitorPlatformOS(monitor),
+		Architecture: normalizeArch(arch),
+	}, nil
+}
+
+// isUnikraftNamed returns true if the registry of the reference is
+// unikraft.org, with or without a port. A prefix of the name is not enough,
+// e.g. unikraft.org.example.com/foo is another registry.
+func isUnikraftNamed(named reference.Named) bool {
+	host, _, _ := strings.Cut(reference.Domain(named), ":")
+
+	return host == unikraftHub
+}
+
+// isUnikraftRef returns true if the image of the reference comes from
+// unikraft.org. Invalid references and the special sources, e.g. local or
+// scratch, are not images of unikraft.org.
+func isUnikraftRef(ref string) bool {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return false
+	}
+
+	return isUnikraftNamed(named)
+}
+
+// Set the source llb state from the sourceRef image and also set
+// the appropriate platform for unikraft images, based on the monitor and the
+// architecture (the host's if empty). See ResolveSourceRef for the canonical
+// reference of the image.
+func GetSourceState(sourceRef string, monitor string, arch string) llb.State {
 	if sourceRef == "scratch" {
 		return llb.Scratch()
 	}
-	if strings.HasPrefix(sourceRef, unikraftHub) {
-		// Define the platform to qemu/amd64 so we can pull unikraft images
-		platform := ocispecs.Platform{
-			OS:           monitor,
-			Architecture: runtime.GOARCH,
-		}
-		return llb.Image(sourceRef, llb.Platform(platform))
+	ref, platform, err := ResolveSourceRef(sourceRef, monitor, arch)
+	if err != nil {
+		// The validation of the bunnyfile catches invalid references,
+		// but the state still carries the error for marshaling
+		return llb.Image(sourceRef)
+	}
+	if platform != nil {
+		return llb.Image(ref, llb.Platform(*platform))
 	}
 
-	return llb.Image(sourceRef)
+	return llb.Image(ref)
 }
@@ -16,12 +16,15 @@ package hops
 
 import (
 	"context"
+	"fmt"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/solver/pb"
 	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/require"
 )
 
@@ -80,35 +83,178 @@ func TestLLBFiles(t *testing.T) {
 
 		require.NoError(t, err)
 		m, arr := parseDef(t, def.Def)
-		// We expect 5 steps
-		require.Equal(t, 5, len(arr))
+		// We expect 4 steps
+		require.Equal(t, 4, len(arr))
 		// The last one should just have a single input
 		last := arr[len(arr)-1]
 		require.Equal(t, 1, len(last.Inputs))
-		// which is the second copy
+		// which is the copy operation
 		lastInputDgst := last.Inputs[0].Digest
-		require.Equal(t, m[lastInputDgst], arr[3])
-		c2 := arr[3]
-		// the second copy should have two inputs
-		require.Equal(t, 2, len(c2.Inputs))
-		// The first input should be the first copy
-		c1Dgst := c2.Inputs[0].Digest
-		require.Equal(t, m[c1Dgst], arr[2])
-		// The second input should be the source state we defined before
-		sDgst := c2.Inputs[1].Digest
-		require.Equal(t, m[sDgst], arr[1])
-		c1 := arr[2]
-		// the first copy should have two inputs
-		require.Equal(t, 2, len(c2.Inputs))
+		require.Equal(t, m[lastInputDgst], arr[2])
+		c := arr[2]
+		// the copy should have two inputs
+		require.Equal(t, 2, len(c.Inputs))
 		// The first input should be the destination source we defined before
-		dDgst := c1.Inputs[0].Digest
+		dDgst := c.Inputs[0].Digest
 		require.Equal(t, m[dDgst], arr[0])
 		// The second input should be the source state we defined before
-		require.Equal(t, sDgst, c1.Inputs[1].Digest)
+		sDgst := c.Inputs[1].Digest
+		require.Equal(t, m[sDgst], arr[1])
 		s := arr[1].Op.(*pb.Op_Source).Source
 		require.Equal(t, "local://context", s.Identifier)
 		d := arr[0].Op.(*pb.Op_Source).Source
 		require.Equal(t, "docker-image://docker.io/library/foo:latest", d.Identifier)
+		// Both files get copied by the same operation, the second one on
+		// top of the output of the first one
+		cf := c.Op.(*pb.Op_File).File
+		require.Equal(t, 2, len(cf.Actions))
+		a1 := cf.Actions[0]
+		require.Equal(t, 0, int(a1.Input))
+		require.Equal(t, 1, int(a1.SecondaryInput))
+		require.Equal(t, -1, int(a1.Output))
+		cp1 := a1.Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, "/foo1", cp1.Src)
+		require.Equal(t, "/bar1", cp1.Dest)
+		a2 := cf.Actions[1]
+		require.Equal(t, 2, int(a2.Input))
+		require.Equal(t, 1, int(a2.SecondaryInput))
+		require.Equal(t, 0, int(a2.Output))
+		cp2 := a2.Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, "/foo2", cp2.Src)
+		require.Equal(t, "/bar2", cp2.Dest)
+	})
+	t.Run("Files with exclude patterns", func(t *testing.T) {
+		files := []FileToInclude{
+			{
+				Src:     "app",
+				Dst:     "/app",
+				Exclude: []string{"**/*.log", "tmp"},
+			},
+			{
+				Src: "conf",
+				Dst: "/etc/conf",
+			},
+		}
+
+		state := FilesLLB(files, "context", llb.Scratch())
+		def, err := state.Marshal(context.TODO())
+
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		cf := arr[len(arr)-2].Op.(*pb.Op_File).File
+		require.Equal(t, 2, len(cf.Actions))
+		cp1 := cf.Actions[0].Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, "/app", cp1.Src)
+		require.Equal(t, []string{"**/*.log", "tmp"}, cp1.ExcludePatterns)
+		// Only the directory with the patterns excludes any files
+		cp2 := cf.Actions[1].Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, "/conf", cp2.Src)
+		require.Empty(t, cp2.ExcludePatterns)
+	})
+	t.Run("Files with mode and owner", func(t *testing.T) {
+		files := []FileToInclude{
+			{
+				Src:   "my app:v1",
+				Dst:   "/opt/my app",
+				Mode:  "0750",
+				Owner: "1000:100",
+			},
+			{
+				Src: "conf",
+				Dst: "/etc/conf",
+			},
+		}
+
+		state := FilesLLB(files, "context", llb.Scratch())
+		def, err := state.Marshal(context.TODO())
+
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		cf := arr[len(arr)-2].Op.(*pb.Op_File).File
+		cp1 := cf.Actions[0].Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, "/my app:v1", cp1.Src)
+		require.Equal(t, "/opt/my app", cp1.Dest)
+		require.Equal(t, int32(0750), cp1.Mode)
+		require.Equal(t, uint32(1000), cp1.Owner.User.GetByID())
+		require.Equal(t, uint32(100), cp1.Owner.Group.GetByID())
+		cp2 := cf.Actions[1].Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, int32(-1), cp2.Mode)
+		require.Nil(t, cp2.Owner)
+	})
+	t.Run("Files in chunks", func(t *testing.T) {
+		files := benchIncludes(2*filesPerCopyOp + 1)
+
+		state := FilesLLB(files, "context", llb.Scratch())
+		def, err := state.Marshal(context.TODO())
+
+		require.NoError(t, err)
+		m, arr := parseDef(t, def.Def)
+		// The last one should be the merge of the chunks, in the order of
+		// the include list
+		last := arr[len(arr)-1]
+		require.Equal(t, 1, len(last.Inputs))
+		merge := m[last.Inputs[0].Digest]
+		require.NotNil(t, merge.GetMerge())
+		require.Equal(t, 3, len(merge.Inputs))
+		var dests []string
+		for _, input := range merge.Inputs {
+			f := m[input.Digest].GetFile()
+			require.NotNil(t, f)
+			require.LessOrEqual(t, len(f.Actions), filesPerCopyOp)
+			for _, action := range f.Actions {
+				dests = append(dests, action.GetCopy().Dest)
+			}
+		}
+		require.Equal(t, len(files), len(dests))
+		for i, file := range files {
+			require.Equal(t, file.Dst, dests[i])
+		}
+		// Every image and the build context appear once
+		var sources []string
+		for _, op := range arr {
+			if src := op.GetSource(); src != nil {
+				sources = append(sources, src.Identifier)
+			}
+		}
+		require.ElementsMatch(t, []string{
+			"local://context",
+			"docker-image://harbor.nbfc.io/files:0",
+			"docker-image://harbor.nbfc.io/files:1",
+			"docker-image://harbor.nbfc.io/files:2",
+		}, sources)
+	})
+	t.Run("Files in chunks over an image", func(t *testing.T) {
+		files := benchIncludes(2*filesPerCopyOp + 1)
+
+		def, err := FilesLLB(files, "context", llb.Image("foo")).Marshal(context.TODO())
+		require.NoError(t, err)
+		m, arr := parseDef(t, def.Def)
+
+		// The chunks get copied on top of the image one after the other, as
+		// a single operation would, without any merge in scratch
+		var chain []*pb.Op
+		op := m[string(arr[len(arr)-1].Inputs[0].Digest)]
+		for op.GetFile() != nil {
+			chain = append([]*pb.Op{op}, chain...)
+			op = m[string(op.Inputs[0].Digest)]
+		}
+		require.Equal(t, "docker-image://docker.io/library/foo:latest", op.GetSource().Identifier)
+		require.Len(t, chain, 3)
+		var dests []string
+		for _, f := range chain {
+			// Every chunk writes on top of the previous state
+			require.Equal(t, int64(0), f.GetFile().Actions[0].Input)
+			for _, action := range f.GetFile().Actions {
+				dests = append(dests, action.GetCopy().Dest)
+			}
+		}
+		require.Equal(t, len(files), len(dests))
+		for i, file := range files {
+			require.Equal(t, file.Dst, dests[i])
+		}
+		for _, op := range arr {
+			require.Nil(t, op.GetMerge())
+		}
 	})
 	t.Run("Empty files list", func(t *testing.T) {
 		dst := llb.Scratch()
@@ -126,7 +272,7 @@ func TestLLBFiles(t *testing.T) {
 func TestLLBInitrd(t *testing.T) {
 	content := llb.Image("foo")
 
-	state := InitrdLLB(content)
+	state := InitrdLLB(content, Owner{})
 	def, err := state.Marshal(context.TODO())
 
 	require.NoError(t, err)
@@ -167,14 +313,15 @@ func TestLLBInitrd(t *testing.T) {
 		require.Equal(t, 3, len(exec.Meta.Args))
 		require.Equal(t, "sh", exec.Meta.Args[0])
 		require.Equal(t, "-c", exec.Meta.Args[1])
-		expectedCmd := "find . -depth -print | tac | bsdcpio -o --format newc > " + DefaultRootfsPath
+		expectedCmd := "find . -exec touch -h -d @0 {} + && find . -print | LC_ALL=C sort | bsdcpio -o -R 0:0 --format newc > " + DefaultRootfsPath
 		require.Equal(t, expectedCmd, exec.Meta.Args[2])
+		require.Equal(t, pb.NetMode_NONE, exec.Network)
 		require.Equal(t, 3, len(exec.Mounts))
 		require.Equal(t, "/", exec.Mounts[0].Dest)
 		require.Equal(t, "/.boot", exec.Mounts[1].Dest)
 		require.Equal(t, false, exec.Mounts[1].Readonly)
 		require.Equal(t, "/workdir", exec.Mounts[2].Dest)
-		require.Equal(t, true, exec.Mounts[2].Readonly)
+		require.Equal(t, false, exec.Mounts[2].Readonly)
 	})
 	t.Run("Input state", func(t *testing.T) {
 		src := s.Op.(*pb.Op_Source).Source
@@ -238,9 +385,50 @@ func TestLLBCopy(t *testing.T) {
 	require.Equal(t, "docker-image://docker.io/library/foo:latest", d.Identifier)
 }
 
+func TestLLBResolveSourceRef(t *testing.T) {
+	dgst := "sha256:" + strings.Repeat("a", 64)
+	for input, expected := range map[string]string{
+		"foo":                             "docker.io/library/foo:latest",
+		"foo:1.0":                         "docker.io/library/foo:1.0",
+		"foo@" + dgst:                     "docker.io/library/foo@" + dgst,
+		"foo:1.0@" + dgst:                 "docker.io/library/foo:1.0@" + dgst,
+		"localhost:5000/foo":              "localhost:5000/foo:latest",
+		"registry.example.com:443/a/b:v1": "registry.example.com:443/a/b:v1",
+		"unikraft.org/nginx:1.15":         "unikraft.org/nginx:1.15",
+	} {
+		ref, _, err := ResolveSourceRef(input, "qemu", "amd64")
+		require.NoError(t, err, input)
+		require.Equal(t, expected, ref, input)
+	}
+
+	for input, unikraft := range map[string]bool{
+		"unikraft.org/nginx:1.15":        true,
+		"unikraft.org:443/nginx@" + dgst: true,
+		"unikraft.org.example.com/nginx": false,
+		"unikraft.orgfoo/nginx":          false,
+		"docker.io/unikraft.org/nginx":   false,
+		"nginx":                          false,
+	} {
+		_, platform, err := ResolveSourceRef(input, "firecracker", "aarch64")
+		require.NoError(t, err, input)
+		require.Equal(t, unikraft, isUnikraftRef(input), input)
+		if !unikraft {
+			require.Nil(t, platform, input)
+			continue
+		}
+		require.Equal(t, &ocispecs.Platform{OS: "fc", Architecture: "arm64"}, platform, input)
+	}
+
+	_, _, err := ResolveSourceRef("Foo", "", "")
+	require.ErrorContains(t, err, `Invalid image reference "Foo"`)
+	_, _, err = ResolveSourceRef("foo@sha256:123", "", "")
+	require.ErrorContains(t, err, `Invalid image reference "foo@sha256:123"`)
+	require.False(t, isUnikraftRef("local"))
+}
+
 func TestLLBBase(t *testing.T) {
 	t.Run("From scratch", func(t *testing.T) {
-		state := GetSourceState("scratch", "")
+		state := GetSourceState("scratch", "", "")
 		def, err := state.Marshal(context.TODO())
 
 		require.NoError(t, err)
@@ -248,7 +436,7 @@ func TestLLBBase(t *testing.T) {
 		require.Equal(t, 0, len(arr))
 	})
 	t.Run("From scratch and monitor", func(t *testing.T) {
-		state := GetSourceState("scratch", "foo")
+		state := GetSourceState("scratch", "foo", "")
 		def, err := state.Marshal(context.TODO())
 
 		require.NoError(t, err)
@@ -256,7 +444,7 @@ func TestLLBBase(t *testing.T) {
 		require.Equal(t, 0, len(arr))
 	})
 	t.Run("From unikraft and qemu", func(t *testing.T) {
-		state := GetSourceState("unikraft.org/foo", "qemu")
+		state := GetSourceState("unikraft.org/foo", "qemu", "")
 		def, err := state.Marshal(context.TODO())
 
 		require.NoError(t, err)
@@ -270,7 +458,7 @@ func TestLLBBase(t *testing.T) {
 		require.Equal(t, "qemu", p.OS)
 	})
 	t.Run("From unikraft and firecracker", func(t *testing.T) {
-		state := GetSourceState("unikraft.org/foo", "firecracker")
+		state := GetSourceState("unikraft.org/foo", "firecracker", "")
 		def, err := state.Marshal(context.TODO())
 
 		require.NoError(t, err)
@@ -283,8 +471,29 @@ func TestLLBBase(t *testing.T) {
 		require.Equal(t, runtime.GOARCH, p.Architecture)
 		require.Equal(t, "fc", p.OS)
 	})
+	t.Run("From unikraft and xen", func(t *testing.T) {
+		state := GetSourceState("unikraft.org/foo", "xen", "")
+		def, err := state.Marshal(context.TODO())
+
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		p := arr[0].Platform
+		require.NotNil(t, p)
+		require.Equal(t, "xen", p.OS)
+	})
+	t.Run("From unikraft with architecture", func(t *testing.T) {
+		state := GetSourceState("unikraft.org/foo", "qemu", "aarch64")
+		def, err := state.Marshal(context.TODO())
+
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		p := arr[0].Platform
+		require.NotNil(t, p)
+		require.Equal(t, "arm64", p.Architecture)
+		require.Equal(t, "qemu", p.OS)
+	})
 	t.Run("From foo", func(t *testing.T) {
-		state := GetSourceState("foo", "")
+		state := GetSourceState("foo", "", "")
 		def, err := state.Marshal(context.TODO())
 
 		require.NoError(t, err)
@@ -297,8 +506,33 @@ func TestLLBBase(t *testing.T) {
 		require.Equal(t, runtime.GOARCH, p.Architecture)
 		require.Equal(t, "linux", p.OS)
 	})
+	t.Run("From a lookalike of unikraft", func(t *testing.T) {
+		state := GetSourceState("unikraft.org.example.com/foo", "qemu", "")
+		def, err := state.Marshal(context.TODO())
+
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		s := arr[0].Op.(*pb.Op_Source).Source
+		require.Equal(t, "docker-image://unikraft.org.example.com/foo:latest", s.Identifier)
+		require.Equal(t, "linux", arr[0].Platform.OS)
+	})
+	t.Run("From registry with port and digest", func(t *testing.T) {
+		ref := "localhost:5000/foo@sha256:" + strings.Repeat("a", 64)
+		state := GetSourceState(ref, "qemu", "")
+		def, err := state.Marshal(context.TODO())
+
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		s := arr[0].Op.(*pb.Op_Source).Source
+		require.Equal(t, "docker-image://"+ref, s.Identifier)
+		require.Equal(t, "linux", arr[0].Platform.OS)
+	})
+	t.Run("From invalid reference", func(t *testing.T) {
+		_, err := GetSourceState("Foo:bar:baz", "", "").Marshal(context.TODO())
+		require.Error(t, err)
+	})
 	t.Run("From foo and monitor", func(t *testing.T) {
-		state := GetSourceState("foo", "bar")
+		state := GetSourceState("foo", "bar", "")
 		def, err := state.Marshal(context.TODO())
 
 		require.NoError(t, err)
@@ -329,3 +563,38 @@ func parseDef(t *testing.T, def [][]byte) (map[string]*pb.Op, []*pb.Op) {
 
 	return m, arr
 }
+
+// benchIncludes returns an include list of n files, every tenth one coming
+// from an image
+func benchIncludes(n int) []FileToInclude {
+	files := make([]FileToInclude, 0, n)
+	for i := range n {
+		file := FileToInclude{
+			From: "local",
+			Src:  fmt.Sprintf("rootfs/dir%d/file%d", i%100, i),
+			Dst:  fmt.Sprintf("/dir%d/file%d", i%100, i),
+		}
+		if i%10 == 0 {
+			file.From = fmt.Sprintf("harbor.nbfc.io/files:%d", i%3)
+		}
+		files = append(files, file)
+	}
+
+	return files
+}
+
+func BenchmarkFilesLLB(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000, 10000} {
+		files := benchIncludes(n)
+		b.Run(fmt.Sprintf("%d files", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				state := FilesLLB(files, "context", llb.Scratch())
+				_, err := state.Marshal(context.TODO())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// MetaCache is an on-disk cache of the image metadata that bunny resolves
+// outside of buildkit, so repeated runs do not query the registries again.
+// The configs of the images are stored by their digest and get verified when
+// they are read. The digest of a reference gets resolved again after TTL,
+// unless the reference is pinned to a digest.
+type MetaCache struct {
+	// The directory of the cache
+	Dir string
+	// How long the digest of a tag is considered current
+	TTL time.Duration
+}
+
+// metaCacheEntry is the resolved metadata of a reference for a platform
+type metaCacheEntry struct {
+	Ref      string        `json:"ref"`
+	Platform string        `json:"platform,omitempty"`
+	Digest   digest.Digest `json:"digest"`
+	Config   digest.Digest `json:"config"`
+	Resolved time.Time     `json:"resolved"`
+}
+
+// metaCacheResolver resolves the image metadata from the cache and falls
+// back to the given resolver for anything missing or expired
+type metaCacheResolver struct {
+	resolver llb.ImageMetaResolver
+	cache    MetaCache
+}
+
+// MetaResolver wraps the given resolver with the cache
+func (m MetaCache) MetaResolver(resolver llb.ImageMetaResolver) llb.ImageMetaResolver {
+	return metaCacheResolver{resolver: resolver, cache: m}
+}
+
+// metaCachePlatform returns the platform of the options in the os/arch
+// form, empty if none is set
+func metaCachePlatform(opt sourceresolver.Opt) string {
+	if opt.ImageOpt == nil || opt.ImageOpt.Platform == nil {
+		return ""
+	}
+
+	return formatPlatform(*opt.ImageOpt.Platform)
+}
+
+func (m MetaCache) entryPath(ref string, platform string) string {
+	return filepath.Join(m.Dir, "refs", digest.FromString(ref+" "+platform).Encoded()+".json")
+}
+
+func (m MetaCache) blobPath(dgst digest.Digest) string {
+	return filepath.Join(m.Dir, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+// writeFile writes the file atomically, so concurrent runs never read a
+// partial file
+func (m MetaCache) writeFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// lookup returns the cached entry and config of the reference, if they
+// exist, are current and the config matches its digest
+func (m MetaCache) lookup(ref string, platform string) (metaCacheEntry, []byte, bool) {
+	var entry metaCacheEntry
+	data, err := os.ReadFile(m.entryPath(ref, platform))
+	if err != nil {
+		return entry, nil, false
+	}
+	err = json.Unmarshal(data, &entry)
+	if err != nil || entry.Ref != ref || entry.Platform != platform || entry.Config.Validate() != nil {
+		return entry, nil, false
+	}
+	pinned := strings.Contains(ref, "@")
+	if !pinned && time.Since(entry.Resolved) > m.TTL {
+		return entry, nil, false
+	}
+	config, err := os.ReadFile(m.blobPath(entry.Config))
+	if err != nil || entry.Config.Algorithm().FromBytes(config) != entry.Config {
+		return entry, nil, false
+	}
+
+	return entry, config, true
+}
+
+// store adds the resolved metadata of the reference in the cache
+func (m MetaCache) store(entry metaCacheEntry, config []byte) error {
+	err := m.writeFile(m.blobPath(entry.Config), config)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return m.writeFile(m.entryPath(entry.Ref, entry.Platform), data)
+}
+
+func (r metaCacheResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	platform := metaCachePlatform(opt)
+	if entry, config, ok := r.cache.lookup(ref, platform); ok {
+		return ref, entry.Digest, config, nil
+	}
+	if r.resolver == nil {
+		return "", "", nil, fmt.Errorf("Can not resolve %s without access to a registry", ref)
+	}
+
+	resolved, dgst, config, err := r.resolver.ResolveImageConfig(ctx, ref, opt)
+	if err != nil {
+		return "", "", nil, err
+	}
+	// A cache that can not be written (e.g. a read-only home) should not
+	// fail the resolution, it just does not save the next one
+	_ = r.cache.store(metaCacheEntry{
+		Ref:      ref,
+		Platform: platform,
+		Digest:   dgst,
+		Config:   digest.FromBytes(config),
+		Resolved: time.Now(),
+	}, config)
+
+	return resolved, dgst, config, nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// countingResolver resolves every image to the same digest and counts the
+// resolutions
+type countingResolver struct {
+	calls int
+	err   error
+}
+
+func (r *countingResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	r.calls++
+	if r.err != nil {
+		return "", "", nil, r.err
+	}
+
+	return ref, pinnedDigest, []byte(fmt.Sprintf(`{"architecture":"%s"}`, opt.ImageOpt.Platform.Architecture)), nil
+}
+
+func metaCacheOpt(arch string) sourceresolver.Opt {
+	return sourceresolver.Opt{
+		ImageOpt: &sourceresolver.ResolveImageOpt{
+			Platform: &ocispecs.Platform{OS: "linux", Architecture: arch},
+		},
+	}
+}
+
+func TestMetaCacheResolve(t *testing.T) {
+	inner := &countingResolver{}
+	cache := MetaCache{Dir: t.TempDir(), TTL: time.Hour}
+	resolver := cache.MetaResolver(inner)
+
+	for range 2 {
+		ref, dgst, config, err := resolver.ResolveImageConfig(context.TODO(), "alpine:3.20", metaCacheOpt("amd64"))
+		require.NoError(t, err)
+		require.Equal(t, "alpine:3.20", ref)
+		require.Equal(t, digest.Digest(pinnedDigest), dgst)
+		require.JSONEq(t, `{"architecture":"amd64"}`, string(config))
+	}
+	require.Equal(t, 1, inner.calls)
+
+	// Every platform has its own config
+	_, _, config, err := resolver.ResolveImageConfig(context.TODO(), "alpine:3.20", metaCacheOpt("arm64"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"architecture":"arm64"}`, string(config))
+	require.Equal(t, 2, inner.calls)
+
+	// A new run with the same directory uses the cache too
+	inner.err = fmt.Errorf("no network")
+	_, dgst, _, err := cache.MetaResolver(inner).ResolveImageConfig(context.TODO(), "alpine:3.20", metaCacheOpt("amd64"))
+	require.NoError(t, err)
+	require.Equal(t, digest.Digest(pinnedDigest), dgst)
+	require.Equal(t, 2, inner.calls)
+}
+
+func TestMetaCacheExpiry(t *testing.T) {
+	inner := &countingResolver{}
+	cache := MetaCache{Dir: t.TempDir(), TTL: -time.Second}
+	resolver := cache.MetaResolver(inner)
+
+	t.Run("Tags expire", func(t *testing.T) {
+		for range 2 {
+			_, _, _, err := resolver.ResolveImageConfig(context.TODO(), "alpine:3.20", metaCacheOpt("amd64"))
+			require.NoError(t, err)
+		}
+		require.Equal(t, 2, inner.calls)
+	})
+	t.Run("Digests do not expire", func(t *testing.T) {
+		ref := "alpine:3.20@" + pinnedDigest
+		for range 2 {
+			_, _, _, err := resolver.ResolveImageConfig(context.TODO(), ref, metaCacheOpt("amd64"))
+			require.NoError(t, err)
+		}
+		require.Equal(t, 3, inner.calls)
+	})
+}
+
+func TestMetaCacheCorrupted(t *testing.T) {
+	inner := &countingResolver{}
+	cache := MetaCache{Dir: t.TempDir(), TTL: time.Hour}
+	resolver := cache.MetaResolver(inner)
+
+	_, _, config, err := resolver.ResolveImageConfig(context.TODO(), "alpine:3.20", metaCacheOpt("amd64"))
+	require.NoError(t, err)
+	blob := cache.blobPath(digest.FromBytes(config))
+	require.FileExists(t, blob)
+	require.NoError(t, os.WriteFile(blob, []byte(`{"architecture":"foo"}`), 0644))
+
+	// The config does not match its digest, so it gets resolved again
+	_, _, config, err = resolver.ResolveImageConfig(context.TODO(), "alpine:3.20", metaCacheOpt("amd64"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"architecture":"amd64"}`, string(config))
+	require.Equal(t, 2, inner.calls)
+
+	t.Run("Without resolver", func(t *testing.T) {
+		_, _, _, err := MetaCache{Dir: filepath.Join(t.TempDir(), "missing")}.MetaResolver(nil).
+			ResolveImageConfig(context.TODO(), "alpine:3.20", metaCacheOpt("amd64"))
+		require.ErrorContains(t, err, "Can not resolve alpine:3.20 without access to a registry")
+	})
+}
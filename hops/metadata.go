@@ -0,0 +1,107 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// SourceDateEpochArg is the build argument with the time of the sources, in
+// seconds since the epoch, that reproducible builds use as the creation time
+const SourceDateEpochArg string = "SOURCE_DATE_EPOCH"
+
+// Metadata describes the image for its users, with the standard annotations
+// of the OCI image spec and the author and the creation time of the image
+// config
+type Metadata struct {
+	// The people or the organization that maintain the image
+	Authors []string `yaml:"authors,omitempty"`
+	// A human-readable description of the image
+	Description string `yaml:"description,omitempty"`
+	// The URL of the documentation of the image
+	Documentation string `yaml:"documentation,omitempty"`
+	// The author of the image config, the authors if empty
+	Author string `yaml:"author,omitempty"`
+	// When the image was created, as an RFC 3339 timestamp
+	Created string `yaml:"created,omitempty"`
+}
+
+// Annotations returns the OCI annotations of the metadata. The authors are
+// joined with commas, as the annotation holds a single string.
+func (m Metadata) Annotations() map[string]string {
+	annots := map[string]string{}
+	if len(m.Authors) != 0 {
+		annots[ocispecs.AnnotationAuthors] = strings.Join(m.Authors, ", ")
+	}
+	if m.Description != "" {
+		annots[ocispecs.AnnotationDescription] = m.Description
+	}
+	if m.Documentation != "" {
+		annots[ocispecs.AnnotationDocumentation] = m.Documentation
+	}
+
+	return annots
+}
+
+// ConfigAuthor returns the author of the image config, which is the author
+// field or else the authors joined with commas.
+func (m Metadata) ConfigAuthor() string {
+	if m.Author != "" {
+		return m.Author
+	}
+
+	return strings.Join(m.Authors, ", ")
+}
+
+// CreatedTime returns the created field as a time in UTC, or nil if it is
+// not set.
+func (m Metadata) CreatedTime() (*time.Time, error) {
+	if m.Created == "" {
+		return nil, nil
+	}
+	created, err := time.Parse(time.RFC3339, m.Created)
+	if err != nil {
+		return nil, err
+	}
+	created = created.UTC()
+
+	return &created, nil
+}
+
+// SetCreated sets the creation time of the image config. It is the created
+// field of the metadata, if set, or else the given SOURCE_DATE_EPOCH, if not
+// empty, or else now. The creation time of the base image never carries
+// over, as the image is not created along with its base.
+func (i *PackInstructions) SetCreated(epoch string, now time.Time) error {
+	created := now.UTC()
+	switch {
+	case i.Created != nil:
+		created = *i.Created
+	case epoch != "":
+		secs, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid %s %s: should be seconds since the epoch", SourceDateEpochArg, epoch)
+		}
+		created = time.Unix(secs, 0).UTC()
+	}
+	i.Img.Created = &created
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const metadataBunnyfile = `# The web server of the examples
+version: v0.1
+platforms:
+  framework: unikraft
+  monitor: qemu
+  architecture: amd64
+metadata:
+  # Who to ask about the image
+  authors:
+    - Nubificus LTD <info@nubificus.co.uk>
+    - Jane Doe
+  description: Nginx on Unikraft
+  documentation: https://github.com/nubificus/bunny
+kernel:
+  from: local
+  path: kernel
+cmd: ["-c", "/nginx/conf/nginx.conf"]
+`
+
+func TestMetadataAnnotations(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(metadataBunnyfile))
+	require.NoError(t, err)
+	require.Equal(t, Metadata{
+		Authors:       []string{"Nubificus LTD <info@nubificus.co.uk>", "Jane Doe"},
+		Description:   "Nginx on Unikraft",
+		Documentation: "https://github.com/nubificus/bunny",
+	}, h.Metadata)
+
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, "Nubificus LTD <info@nubificus.co.uk>, Jane Doe", i.Annots[ocispecs.AnnotationAuthors])
+	require.Equal(t, "Nginx on Unikraft", i.Annots[ocispecs.AnnotationDescription])
+	require.Equal(t, "https://github.com/nubificus/bunny", i.Annots[ocispecs.AnnotationDocumentation])
+	// The metadata are not for urunc
+	uruncJSON, err := UruncJSON(*i)
+	require.NoError(t, err)
+	require.NotContains(t, string(uruncJSON), ocispecs.AnnotationDescription)
+
+	t.Run("Without metadata", func(t *testing.T) {
+		require.Empty(t, Metadata{}.Annotations())
+		require.Equal(t, map[string]string{
+			ocispecs.AnnotationDescription: "Nginx on Unikraft",
+		}, Metadata{Description: "Nginx on Unikraft"}.Annotations())
+	})
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(metadataBunnyfile))
+	require.NoError(t, err)
+	out, err := yaml.Marshal(h)
+	require.NoError(t, err)
+	again, err := ParseBunnyfile(out)
+	require.NoError(t, err)
+	require.Equal(t, h.Metadata, again.Metadata)
+	require.Equal(t, h.Platform, again.Platform)
+
+	// Empty fields of the metadata are not written back
+	h.Metadata = Metadata{Description: "Nginx on Unikraft"}
+	out, err = yaml.Marshal(h.Metadata)
+	require.NoError(t, err)
+	require.Equal(t, "description: Nginx on Unikraft\n", string(out))
+}
+
+func TestMetadataConfig(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(metadataBunnyfile))
+	require.NoError(t, err)
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, "Nubificus LTD <info@nubificus.co.uk>, Jane Doe", i.Img.Author)
+	require.Nil(t, i.Created)
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("EET", 2*60*60))
+	require.NoError(t, i.SetCreated("", now))
+	require.Equal(t, now.UTC(), *i.Img.Created)
+	require.NoError(t, i.SetCreated("1700000000", now))
+	require.Equal(t, time.Unix(1700000000, 0).UTC(), *i.Img.Created)
+	require.ErrorContains(t, i.SetCreated("yesterday", now), "Invalid SOURCE_DATE_EPOCH yesterday")
+
+	t.Run("Author and created", func(t *testing.T) {
+		// An unquoted timestamp is still read as a string
+		file := strings.Replace(metadataBunnyfile, "  description:",
+			"  author: Jane Doe\n  created: 2024-05-06T07:08:09+03:00\n  description:", 1)
+		h, err := ParseBunnyfile([]byte(file))
+		require.NoError(t, err)
+		require.Equal(t, "2024-05-06T07:08:09+03:00", h.Metadata.Created)
+		i, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		require.Equal(t, "Jane Doe", i.Img.Author)
+
+		// The created field wins over SOURCE_DATE_EPOCH
+		require.NoError(t, i.SetCreated("1700000000", now))
+		require.Equal(t, time.Date(2024, 5, 6, 4, 8, 9, 0, time.UTC), *i.Img.Created)
+		i.SetHistory()
+		require.Equal(t, i.Img.Created, i.Img.History[0].Created)
+	})
+	t.Run("Invalid created", func(t *testing.T) {
+		err := ValidateMetadata(Metadata{Created: "2024-05-06"})
+		require.ErrorContains(t, err, "The created field of metadata should be an RFC 3339 timestamp")
+	})
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+const (
+	dockerImageScheme string = "docker-image://"
+)
+
+// Mirrors maps a registry (e.g. docker.io) to the registry, with an
+// optional path prefix, that should be used instead (e.g.
+// mirror.local:5000/dockerhub).
+type Mirrors map[string]string
+
+// ParseMirrors parses a comma separated list of registry=mirror pairs.
+func ParseMirrors(s string) (Mirrors, error) {
+	mirrors := Mirrors{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		registry, mirror, found := strings.Cut(pair, "=")
+		if !found || registry == "" || mirror == "" {
+			return nil, fmt.Errorf("Invalid mirror %q, expected registry=mirror", pair)
+		}
+		mirrors[registry] = mirror
+	}
+
+	return mirrors, nil
+}
+
+// Merge returns a new Mirrors with the entries of both m and other. The
+// entries of other take precedence.
+func (m Mirrors) Merge(other Mirrors) Mirrors {
+	merged := Mirrors{}
+	for k, v := range m {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// Rewrite returns the reference of the image in the respective mirror. If
+// there is no mirror for the registry of the image or the reference is not
+// valid, the reference is returned as is.
+func (m Mirrors) Rewrite(ref string) string {
+	if len(m) == 0 || ref == "" || ref == "scratch" {
+		return ref
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ref
+	}
+	mirror, ok := m[reference.Domain(named)]
+	if !ok {
+		return ref
+	}
+
+	newRef := strings.TrimSuffix(mirror, "/") + "/" + reference.Path(named)
+	if tagged, ok := named.(reference.Tagged); ok {
+		newRef += ":" + tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		newRef += "@" + digested.Digest().String()
+	}
+
+	return newRef
+}
+
+// mirrorResolver resolves the image metadata from the mirrors.
+type mirrorResolver struct {
+	resolver llb.ImageMetaResolver
+	mirrors  Mirrors
+}
+
+func (r mirrorResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	return r.resolver.ResolveImageConfig(ctx, r.mirrors.Rewrite(ref), opt)
+}
+
+// MetaResolver wraps the given resolver, so that the metadata of images are
+// fetched from the mirrors.
+func (m Mirrors) MetaResolver(resolver llb.ImageMetaResolver) llb.ImageMetaResolver {
+	if resolver == nil || len(m) == 0 {
+		return resolver
+	}
+
+	return mirrorResolver{resolver: resolver, mirrors: m}
+}
+
+// ApplyMirrors rewrites all image sources of the LLB definition to point
+// to the respective mirrors.
+func ApplyMirrors(def *llb.Definition, mirrors Mirrors) (*llb.Definition, error) {
+	if len(mirrors) == 0 {
+		return def, nil
+	}
+
+	return rewriteSources(def, func(src *pb.SourceOp) (*llb.Definition, error) {
+		if strings.HasPrefix(src.Identifier, dockerImageScheme) {
+			ref := strings.TrimPrefix(src.Identifier, dockerImageScheme)
+			src.Identifier = dockerImageScheme + mirrors.Rewrite(ref)
+		}
+		return nil, nil
+	})
+}
+
+// sourceRewriter gets a source operation of an LLB definition and either
+// modifies it in place or returns a definition to use in its place.
+type sourceRewriter func(*pb.SourceOp) (*llb.Definition, error)
+
+// rewriteSources passes all source operations of the LLB definition to
+// rewrite. Since the digests of the operations depend on their content, all
+// operations that depend on a rewritten source get a new digest too.
+func rewriteSources(def *llb.Definition, rewrite sourceRewriter) (*llb.Definition, error) {
+	// The new input for each operation of the old definition. A source
+	// that got replaced by a definition is mapped to its result.
+	newInputs := map[digest.Digest]*pb.Input{}
+	replaced := map[digest.Digest]bool{}
+	seen := map[digest.Digest]bool{}
+	newDef := &llb.Definition{
+		Metadata:    map[digest.Digest]llb.OpMetadata{},
+		Constraints: def.Constraints,
+	}
+	if def.Source != nil {
+		newDef.Source = &pb.Source{
+			Locations: map[string]*pb.Locations{},
+			Infos:     def.Source.Infos,
+		}
+	}
+	addOp := func(dt []byte, md llb.OpMetadata, hasMd bool) digest.Digest {
+		dgst := digest.FromBytes(dt)
+		if !seen[dgst] {
+			seen[dgst] = true
+			newDef.Def = append(newDef.Def, dt)
+			if hasMd {
+				newDef.Metadata[dgst] = md
+			}
+		}
+		return dgst
+	}
+
+	// The operations are sorted, so every operation comes after its inputs
+	for _, dt := range def.Def {
+		var op pb.Op
+		err := op.Unmarshal(dt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal LLB operation: %v", err)
+		}
+		oldDgst := digest.FromBytes(dt)
+
+		for _, input := range op.Inputs {
+			old := digest.Digest(input.Digest)
+			in, ok := newInputs[old]
+			if !ok {
+				continue
+			}
+			input.Digest = in.Digest
+			if replaced[old] {
+				input.Index = in.Index
+			}
+		}
+		if src := op.GetSource(); src != nil {
+			replacement, err := rewrite(src)
+			if err != nil {
+				return nil, err
+			}
+			if replacement != nil {
+				root, err := spliceDefinition(replacement, addOp)
+				if err != nil {
+					return nil, err
+				}
+				newInputs[oldDgst] = root
+				replaced[oldDgst] = true
+				continue
+			}
+		}
+
+		newDt, err := op.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to marshal LLB operation: %v", err)
+		}
+		md, hasMd := def.Metadata[oldDgst]
+		newDgst := addOp(newDt, md, hasMd)
+		newInputs[oldDgst] = &pb.Input{Digest: string(newDgst)}
+		if newDef.Source != nil {
+			if loc, ok := def.Source.Locations[string(oldDgst)]; ok {
+				newDef.Source.Locations[string(newDgst)] = loc
+			}
+		}
+	}
+
+	return newDef, nil
+}
+
+// spliceDefinition adds all operations of def, except the terminal one,
+// and returns the input that points to the result of def.
+func spliceDefinition(def *llb.Definition, addOp func([]byte, llb.OpMetadata, bool) digest.Digest) (*pb.Input, error) {
+	if len(def.Def) == 0 {
+		return nil, fmt.Errorf("Can not use an empty LLB definition as a source")
+	}
+	for _, dt := range def.Def[:len(def.Def)-1] {
+		md, hasMd := def.Metadata[digest.FromBytes(dt)]
+		addOp(dt, md, hasMd)
+	}
+
+	var terminal pb.Op
+	err := terminal.Unmarshal(def.Def[len(def.Def)-1])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal LLB operation: %v", err)
+	}
+	if len(terminal.Inputs) != 1 {
+		return nil, fmt.Errorf("Can not use an LLB definition without a result as a source")
+	}
+
+	return terminal.Inputs[0], nil
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorsParse(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		m, err := ParseMirrors("docker.io=mirror.local:5000, harbor.nbfc.io=mirror.local:5000/nbfc,")
+		require.NoError(t, err)
+		require.Equal(t, Mirrors{
+			"docker.io":      "mirror.local:5000",
+			"harbor.nbfc.io": "mirror.local:5000/nbfc",
+		}, m)
+	})
+	t.Run("Valid empty", func(t *testing.T) {
+		m, err := ParseMirrors("")
+		require.NoError(t, err)
+		require.Equal(t, 0, len(m))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParseMirrors("docker.io")
+		require.ErrorContains(t, err, "Invalid mirror \"docker.io\"")
+		_, err = ParseMirrors("docker.io=")
+		require.ErrorContains(t, err, "Invalid mirror \"docker.io=\"")
+	})
+}
+
+func TestMirrorsMerge(t *testing.T) {
+	var m Mirrors
+	merged := m.Merge(Mirrors{"docker.io": "a"})
+	require.Equal(t, Mirrors{"docker.io": "a"}, merged)
+	merged = Mirrors{"docker.io": "a", "quay.io": "b"}.Merge(Mirrors{"docker.io": "c"})
+	require.Equal(t, Mirrors{"docker.io": "c", "quay.io": "b"}, merged)
+}
+
+func TestMirrorsRewrite(t *testing.T) {
+	m := Mirrors{
+		"docker.io":      "mirror.local:5000",
+		"harbor.nbfc.io": "mirror.local:5000/nbfc/",
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"alpine", "mirror.local:5000/library/alpine"},
+		{"alpine:3.20", "mirror.local:5000/library/alpine:3.20"},
+		{"docker.io/foo/bar:latest", "mirror.local:5000/foo/bar:latest"},
+		{"harbor.nbfc.io/nubificus/urunit:latest", "mirror.local:5000/nbfc/nubificus/urunit:latest"},
+		{"harbor.nbfc.io/foo@sha256:0123456789012345678901234567890123456789012345678901234567890123",
+			"mirror.local:5000/nbfc/foo@sha256:0123456789012345678901234567890123456789012345678901234567890123"},
+		{"unikraft.org/nginx:1.15", "unikraft.org/nginx:1.15"},
+		{"scratch", "scratch"},
+		{"", ""},
+		{"Invalid:Ref:", "Invalid:Ref:"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			require.Equal(t, tc.expected, m.Rewrite(tc.input))
+		})
+	}
+	require.Equal(t, "alpine", Mirrors{}.Rewrite("alpine"))
+}
+
+type fakeResolver struct {
+	refs []string
+}
+
+func (r *fakeResolver) ResolveImageConfig(_ context.Context, ref string, _ sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	r.refs = append(r.refs, ref)
+	return ref, "", []byte("{}"), nil
+}
+
+func TestMirrorsMetaResolver(t *testing.T) {
+	r := &fakeResolver{}
+	require.Nil(t, Mirrors{"docker.io": "a"}.MetaResolver(nil))
+	require.Equal(t, r, Mirrors{}.MetaResolver(r))
+
+	resolver := Mirrors{"docker.io": "mirror.local"}.MetaResolver(r)
+	_, _, _, err := resolver.ResolveImageConfig(context.TODO(), "docker.io/library/alpine:latest", sourceresolver.Opt{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"mirror.local/library/alpine:latest"}, r.refs)
+}
+
+func TestMirrorsApply(t *testing.T) {
+	instr := PackInstructions{
+		Base: llb.Image("harbor.nbfc.io/foo"),
+		Copies: []PackCopies{
+			{
+				SrcState: llb.Image("alpine"),
+				SrcPath:  "/bin/sh",
+				DstPath:  "/bin/sh",
+			},
+			{
+				SrcState: llb.Local("context"),
+				SrcPath:  "foo",
+				DstPath:  "bar",
+			},
+		},
+		Annots:  map[string]string{},
+		Sources: SourceOpts{Mirrors: Mirrors{"docker.io": "mirror.local:5000"}},
+	}
+
+	result, err := PackLLB(context.TODO(), instr)
+	require.NoError(t, err)
+	m, arr := parseDef(t, result.Def)
+	var sources []string
+	for _, op := range arr {
+		if s, ok := op.Op.(*pb.Op_Source); ok {
+			sources = append(sources, s.Source.Identifier)
+		}
+		// All inputs should point to operations of the new definition
+		for _, input := range op.Inputs {
+			require.Contains(t, m, input.Digest)
+		}
+	}
+	require.ElementsMatch(t, []string{
+		"docker-image://harbor.nbfc.io/foo:latest",
+		"docker-image://mirror.local:5000/library/alpine:latest",
+		"local://context",
+	}, sources)
+	for dgst := range result.Metadata {
+		require.Contains(t, m, string(dgst))
+	}
+
+	// Without mirrors the definition stays as is
+	instr.Sources.Mirrors = nil
+	result, err = PackLLB(context.TODO(), instr)
+	require.NoError(t, err)
+	_, arr = parseDef(t, result.Def)
+	for _, op := range arr {
+		if s, ok := op.Op.(*pb.Op_Source); ok {
+			require.NotContains(t, s.Source.Identifier, "mirror.local")
+		}
+	}
+}
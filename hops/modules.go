@@ -0,0 +1,141 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"path"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+const (
+	// The directory of the kernel modules in the rootfs
+	ModulesDir string = "/lib/modules"
+)
+
+const (
+	defaultModulesImage string = "harbor.nbfc.io/nubificus/bunny/kmod:latest"
+	modulesSrcDir       string = "/src"
+	modulesScriptDir    string = "/modules-script"
+	modulesOutDir       string = "/out"
+)
+
+// The script that extracts the given kernel modules and the modules they
+// depend on from the image with the modules and generates their metadata with
+// depmod. The first argument is the version of the kernel, which can be empty
+// if the image has the modules of a single kernel, and the rest are the names
+// of the modules. The modules image is mounted writable, so depmod can
+// generate the dependencies of all its modules, but the changes are discarded.
+const modulesScript = `#!/bin/sh
+set -e
+version="$1"
+shift
+if [ -z "$version" ]; then
+	version=$(ls ` + modulesSrcDir + ModulesDir + `)
+	if [ -z "$version" ] || [ "$(echo "$version" | wc -l)" -ne 1 ]; then
+		echo "Expected the modules of exactly one kernel in ` + ModulesDir + `, found: $version" >&2
+		exit 1
+	fi
+fi
+moddir=` + ModulesDir + `/"$version"
+if [ ! -d ` + modulesSrcDir + `"$moddir" ]; then
+	echo "Could not find $moddir in the modules image" >&2
+	exit 1
+fi
+depmod -b ` + modulesSrcDir + ` "$version"
+mkdir -p ` + modulesOutDir + `"$moddir"
+for module in "$@"; do
+	deps=$(modprobe -d ` + modulesSrcDir + ` -S "$version" --show-depends "$module")
+	echo "$deps" | while read -r kind file _; do
+		[ "$kind" = "insmod" ] || continue
+		file="${file#` + modulesSrcDir + `}"
+		mkdir -p ` + modulesOutDir + `"$(dirname "$file")"
+		cp ` + modulesSrcDir + `"$file" ` + modulesOutDir + `"$file"
+	done
+done
+for f in modules.order modules.builtin modules.builtin.modinfo; do
+	if [ -f ` + modulesSrcDir + `"$moddir/$f" ]; then
+		cp ` + modulesSrcDir + `"$moddir/$f" ` + modulesOutDir + `"$moddir/$f"
+	fi
+done
+depmod -b ` + modulesOutDir + ` "$version"
+`
+
+// Modules defines the kernel modules to package in the rootfs of a Linux
+// unikernel
+type Modules struct {
+	// The image with the modules in /lib/modules, the kernel's if empty
+	From string `yaml:"from"`
+	// The version of the kernel, if the image has modules of many kernels
+	Version string `yaml:"version"`
+	// The tools image with a shell and the depmod and modprobe of kmod
+	Image string `yaml:"image"`
+	// The names of the modules to package, along with their dependencies
+	Include []string `yaml:"include"`
+}
+
+// Enabled returns true if there are modules to package
+func (m Modules) Enabled() bool {
+	return len(m.Include) > 0
+}
+
+// ModulesLLB creates a LLB State with the modules of the input and the
+// modules they depend on in /lib/modules, along with their metadata, so
+// modprobe can load them in the guest.
+func ModulesLLB(in BuildInput) llb.State {
+	m := in.Modules
+	toolImage := m.Image
+	if toolImage == "" {
+		toolImage = defaultModulesImage
+	}
+	scriptFiles := llb.Scratch().
+		File(llb.Mkfile("/modules.sh", 0755, []byte(modulesScript)))
+
+	args := append([]string{
+		"/bin/sh", path.Join(modulesScriptDir, "modules.sh"), m.Version,
+	}, m.Include...)
+	runOpts := []llb.RunOption{
+		llb.Args(args),
+		llb.AddMount(modulesSrcDir, GetSourceState(m.From, in.Monitor, in.Arch)),
+		llb.AddMount(modulesScriptDir, scriptFiles, llb.Readonly),
+		llb.Network(llb.NetModeNone),
+		llb.WithCustomName("Internal:Package kernel modules"),
+	}
+	runOpts = append(runOpts, hardenedOptions(in.Hardened, false)...)
+	runOpts = append(runOpts, in.Build.NetworkOptions(BuildStepModules)...)
+	modulesExec := llb.Image(toolImage).Run(runOpts...)
+
+	return modulesExec.AddMount(modulesOutDir, llb.Scratch())
+}
+
+// RootfsFilesLLB copies the files of the include list in toState, like
+// FilesLLB, along with the kernel modules of the input, if any. The prePack
+// hooks of the input run on the result.
+func RootfsFilesLLB(fileList []FileToInclude, in BuildInput, toState llb.State) llb.State {
+	files := FilesLLB(fileList, in.BuildContext, toState, excludeOptions(in.Exclude)...)
+	if in.Modules.Enabled() {
+		files = CopyLLB(files, PackCopies{
+			SrcState: ModulesLLB(in),
+			SrcPath:  ModulesDir,
+			DstPath:  ModulesDir,
+		})
+	}
+	if len(in.Hooks.PrePack) == 0 {
+		return files
+	}
+
+	return HooksLLB(files, in.Hooks.Image, in.Hooks.PrePack, "prePack",
+		hooksOptions(in.Hardened, in.Build)...)
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func modulesInput() BuildInput {
+	return BuildInput{
+		BuildContext: "context",
+		Monitor:      "qemu",
+		Arch:         "amd64",
+		Modules: Modules{
+			From:    "harbor.nbfc.io/nubificus/linux-modules:6.6",
+			Include: []string{"virtio_net", "ext4"},
+		},
+	}
+}
+
+// copyDests returns the destinations of all the copies in the state.
+func copyDests(t *testing.T, state llb.State) []string {
+	def, err := state.Marshal(context.TODO())
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var dests []string
+	for _, op := range arr {
+		if f := op.GetFile(); f != nil {
+			for _, action := range f.Actions {
+				if c := action.GetCopy(); c != nil {
+					dests = append(dests, c.Dest)
+				}
+			}
+		}
+	}
+
+	return dests
+}
+
+func TestModulesLLB(t *testing.T) {
+	t.Run("Default image", func(t *testing.T) {
+		exec, mounts, _ := kraftExec(t, ModulesLLB(modulesInput()))
+		require.Equal(t, []string{"/bin/sh", "/modules-script/modules.sh", "", "virtio_net", "ext4"}, exec.Meta.Args)
+		require.Equal(t, pb.NetMode_NONE, exec.Network)
+		require.False(t, mounts["/src"].Readonly)
+		require.True(t, mounts["/modules-script"].Readonly)
+		require.Contains(t, mounts, "/out")
+		require.False(t, mounts["/"].Readonly)
+
+		def, err := ModulesLLB(modulesInput()).Marshal(context.TODO())
+		require.NoError(t, err)
+		ids := sourceIdentifiers(t, def)
+		require.Contains(t, ids, "docker-image://"+defaultModulesImage)
+		require.Contains(t, ids, "docker-image://harbor.nbfc.io/nubificus/linux-modules:6.6")
+	})
+	t.Run("Version and image", func(t *testing.T) {
+		in := modulesInput()
+		in.Modules.Version = "6.6.0"
+		in.Modules.Image = "alpine:3.20"
+		exec, _, _ := kraftExec(t, ModulesLLB(in))
+		require.Equal(t, "6.6.0", exec.Meta.Args[2])
+
+		def, err := ModulesLLB(in).Marshal(context.TODO())
+		require.NoError(t, err)
+		require.Contains(t, sourceIdentifiers(t, def), "docker-image://docker.io/library/alpine:3.20")
+	})
+	t.Run("Hardened", func(t *testing.T) {
+		in := modulesInput()
+		in.Hardened = true
+		in.Build = BuildOptions{Network: map[string]string{BuildStepModules: "host"}}
+		exec, _, _ := kraftExec(t, ModulesLLB(in))
+		requireHardened(t, exec)
+		require.Equal(t, pb.NetMode_HOST, exec.Network)
+	})
+}
+
+func TestModulesRootfsFiles(t *testing.T) {
+	includes := []FileToInclude{{Src: "app", Dst: "/app"}}
+	t.Run("With modules", func(t *testing.T) {
+		dests := copyDests(t, RootfsFilesLLB(includes, modulesInput(), llb.Scratch()))
+		require.ElementsMatch(t, []string{"/app", ModulesDir}, dests)
+	})
+	t.Run("Without modules", func(t *testing.T) {
+		in := modulesInput()
+		in.Modules = Modules{}
+		dests := copyDests(t, RootfsFilesLLB(includes, in, llb.Scratch()))
+		require.Equal(t, []string{"/app"}, dests)
+	})
+}
+
+func TestModulesToPack(t *testing.T) {
+	h := &Hops{
+		Platform: Platform{
+			Framework: "linux",
+			Monitor:   "qemu",
+			Arch:      "amd64",
+		},
+		Kernel: Kernel{
+			From: "harbor.nbfc.io/nubificus/linux-kernel:6.6",
+			Path: "/kernel",
+		},
+		Rootfs: Rootfs{
+			From:     "scratch",
+			Type:     "initrd",
+			Includes: []FileToInclude{{Src: "app", Dst: "/app"}},
+		},
+		Modules: Modules{Include: []string{"virtio_net"}},
+	}
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.NotNil(t, i.Rootfs.InitrdContent)
+	require.Contains(t, copyDests(t, *i.Rootfs.InitrdContent), ModulesDir)
+
+	// The modules come from the image of the kernel
+	def, err := i.Rootfs.SourceState.Marshal(context.TODO())
+	require.NoError(t, err)
+	require.Contains(t, sourceIdentifiers(t, def), "docker-image://harbor.nbfc.io/nubificus/linux-kernel:6.6")
+	// The bunnyfile stays as it was
+	require.Empty(t, h.Modules.From)
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The monitor name of cloud-hypervisor, as urunc expects it
+	MonitorCloudHypervisor string = "cloud-hypervisor"
+	// The virtio target of solo5, for monitors with virtio devices
+	MonitorSolo5Virtio string = "solo5-virtio"
+	// The xen hypervisor
+	MonitorXen string = "xen"
+)
+
+const (
+	defaultMonitorMemory int = 512
+)
+
+// monitorAliases maps other common names of monitors to the ones that urunc
+// expects
+var monitorAliases = map[string]string{
+	"clh":             MonitorCloudHypervisor,
+	"ch":              MonitorCloudHypervisor,
+	"cloudhypervisor": MonitorCloudHypervisor,
+	"virtio":          MonitorSolo5Virtio,
+	"solo5virtio":     MonitorSolo5Virtio,
+	"xen-pvh":         MonitorXen,
+}
+
+// NormalizeMonitor returns the name of the given monitor, as urunc expects
+// it. Unknown monitors stay as they are.
+func NormalizeMonitor(monitor string) string {
+	if m, ok := monitorAliases[strings.ToLower(monitor)]; ok {
+		return m
+	}
+
+	return monitor
+}
+
+// monitorArches returns the architectures that the given monitor supports, or
+// nil if bunny does not restrict them.
+func monitorArches(monitor string) []string {
+	switch monitor {
+	case MonitorCloudHypervisor, MonitorXen:
+		return []string{"amd64", "arm64"}
+	case MonitorSolo5Virtio:
+		return []string{"amd64"}
+	default:
+		return nil
+	}
+}
+
+// monitorPlatformOS returns the OS of the platform of kernel images for the
+// given monitor, as unikraft.org and kraft name it.
+func monitorPlatformOS(monitor string) string {
+	if monitor == "firecracker" {
+		return "fc"
+	}
+
+	return monitor
+}
+
+// MonitorOpts holds the options to boot an image under a monitor
+type MonitorOpts struct {
+	// The directory where the rootfs of the image resides
+	RootDir string
+	// The memory of the VM in MiB
+	Memory int
+	// Use KVM acceleration
+	KVM bool
+}
+
+type fcBootSource struct {
+	KernelImagePath string `json:"kernel_image_path"`
+	BootArgs        string `json:"boot_args,omitempty"`
+	InitrdPath      string `json:"initrd_path,omitempty"`
+}
+
+type fcDrive struct {
+	DriveID      string `json:"drive_id"`
+	PathOnHost   string `json:"path_on_host"`
+	IsRootDevice bool   `json:"is_root_device"`
+	IsReadOnly   bool   `json:"is_read_only"`
+}
+
+type fcMachineConfig struct {
+	VcpuCount  int `json:"vcpu_count"`
+	MemSizeMib int `json:"mem_size_mib"`
+}
+
+type fcConfig struct {
+	BootSource    fcBootSource    `json:"boot-source"`
+	Drives        []fcDrive       `json:"drives"`
+	MachineConfig fcMachineConfig `json:"machine-config"`
+}
+
+func (o MonitorOpts) memory() int {
+	if o.Memory <= 0 {
+		return defaultMonitorMemory
+	}
+
+	return o.Memory
+}
+
+// bootFiles returns the paths of the kernel, initrd and block files
+// under the root directory, based on the annotations of the image. The
+// kernel is the one of the given monitor, if the image has a kernel per
+// monitor, or else the kernel of the image.
+func bootFiles(annots map[string]string, rootDir string, monitor string) (string, string, string, error) {
+	kernel := annots[KernelVariantAnnotPrefix+monitor]
+	if kernel == "" {
+		kernel = annots["com.urunc.unikernel.binary"]
+	}
+	if kernel == "" {
+		return "", "", "", fmt.Errorf("Can not find the kernel of the image")
+	}
+	if annots["com.urunc.unikernel.mountRootfs"] == "true" {
+		return "", "", "", fmt.Errorf("Booting images with raw rootfs is not supported yet")
+	}
+	kernel = path.Join(rootDir, kernel)
+
+	initrd := annots["com.urunc.unikernel.initrd"]
+	if initrd != "" {
+		initrd = path.Join(rootDir, initrd)
+	}
+	block := annots["com.urunc.unikernel.block"]
+	if block != "" {
+		block = path.Join(rootDir, block)
+	}
+
+	return kernel, initrd, block, nil
+}
+
+// QemuCmd constructs the qemu command line to boot the unikernel,
+// based on the annotations of the image.
+func QemuCmd(annots map[string]string, opts MonitorOpts) ([]string, error) {
+	var cmd []string
+
+	switch runtime.GOARCH {
+	case "amd64":
+		cmd = []string{"qemu-system-x86_64"}
+	case "arm64":
+		cmd = []string{"qemu-system-aarch64", "-M", "virt"}
+	default:
+		return nil, fmt.Errorf("Unsupported architecture for qemu: %s", runtime.GOARCH)
+	}
+	if opts.KVM {
+		cmd = append(cmd, "-enable-kvm", "-cpu", "host")
+	} else if runtime.GOARCH == "arm64" {
+		cmd = append(cmd, "-cpu", "max")
+	}
+	cmd = append(cmd, "-m", strconv.Itoa(opts.memory()), "-nographic", "-no-reboot")
+
+	kernel, initrd, block, err := bootFiles(annots, opts.RootDir, "qemu")
+	if err != nil {
+		return nil, err
+	}
+	cmd = append(cmd, "-kernel", kernel)
+	if dtb := annots[DtbAnnotation]; dtb != "" {
+		cmd = append(cmd, "-dtb", path.Join(opts.RootDir, dtb))
+	}
+	if initrd != "" {
+		cmd = append(cmd, "-initrd", initrd)
+	}
+	if block != "" {
+		cmd = append(cmd, "-drive", "file="+block+",format=raw,if=virtio,readonly=on")
+	}
+	if cmdline := annots["com.urunc.unikernel.cmdline"]; cmdline != "" {
+		cmd = append(cmd, "-append", cmdline)
+	}
+
+	return cmd, nil
+}
+
+// FirecrackerConfig constructs the configuration file of firecracker to boot
+// the unikernel, based on the annotations of the image.
+func FirecrackerConfig(annots map[string]string, opts MonitorOpts) ([]byte, error) {
+	kernel, initrd, block, err := bootFiles(annots, opts.RootDir, "firecracker")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := fcConfig{
+		BootSource: fcBootSource{
+			KernelImagePath: kernel,
+			BootArgs:        annots["com.urunc.unikernel.cmdline"],
+			InitrdPath:      initrd,
+		},
+		Drives: []fcDrive{},
+		MachineConfig: fcMachineConfig{
+			VcpuCount:  1,
+			MemSizeMib: opts.memory(),
+		},
+	}
+	if block != "" {
+		cfg.Drives = append(cfg.Drives, fcDrive{
+			DriveID:    "rootfs",
+			PathOnHost: block,
+			IsReadOnly: true,
+		})
+	}
+
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+// CloudHypervisorCmd constructs the cloud-hypervisor command line to boot the
+// unikernel, based on the annotations of the image.
+func CloudHypervisorCmd(annots map[string]string, opts MonitorOpts) ([]string, error) {
+	kernel, initrd, block, err := bootFiles(annots, opts.RootDir, MonitorCloudHypervisor)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := []string{
+		"cloud-hypervisor",
+		"--kernel", kernel,
+		"--cpus", "boot=1",
+		"--memory", "size=" + strconv.Itoa(opts.memory()) + "M",
+		"--console", "off",
+		"--serial", "tty",
+	}
+	if initrd != "" {
+		cmd = append(cmd, "--initramfs", initrd)
+	}
+	if block != "" {
+		cmd = append(cmd, "--disk", "path="+block+",readonly=on")
+	}
+	if cmdline := annots["com.urunc.unikernel.cmdline"]; cmdline != "" {
+		cmd = append(cmd, "--cmdline", cmdline)
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorsQemuCmd(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("Qemu is not supported in", runtime.GOARCH)
+	}
+	t.Run("Kernel and initrd", func(t *testing.T) {
+		cmd, err := QemuCmd(map[string]string{
+			"com.urunc.unikernel.binary":  DefaultKernelPath,
+			"com.urunc.unikernel.initrd":  DefaultRootfsPath,
+			"com.urunc.unikernel.cmdline": "foo bar",
+		}, MonitorOpts{RootDir: "/image"})
+		require.NoError(t, err)
+		require.Contains(t, cmd[0], "qemu-system-")
+		require.NotContains(t, cmd, "-enable-kvm")
+		require.Subset(t, cmd, []string{"-m", "512"})
+		require.Subset(t, cmd, []string{"-kernel", "/image" + DefaultKernelPath})
+		require.Subset(t, cmd, []string{"-initrd", "/image" + DefaultRootfsPath})
+		require.Equal(t, []string{"-append", "foo bar"}, cmd[len(cmd)-2:])
+	})
+	t.Run("Relative kernel, block, memory and kvm", func(t *testing.T) {
+		cmd, err := QemuCmd(map[string]string{
+			"com.urunc.unikernel.binary": "kernel",
+			"com.urunc.unikernel.block":  "disk.img",
+		}, MonitorOpts{RootDir: "/image", Memory: 1024, KVM: true})
+		require.NoError(t, err)
+		require.Contains(t, cmd, "-enable-kvm")
+		require.Subset(t, cmd, []string{"-m", "1024"})
+		require.Subset(t, cmd, []string{"-kernel", "/image/kernel"})
+		require.Contains(t, cmd, "file=/image/disk.img,format=raw,if=virtio,readonly=on")
+		require.NotContains(t, cmd, "-append")
+	})
+	t.Run("Kernel and dtb", func(t *testing.T) {
+		cmd, err := QemuCmd(map[string]string{
+			"com.urunc.unikernel.binary": DefaultKernelPath,
+			DtbAnnotation:                DefaultDtbPath,
+		}, MonitorOpts{RootDir: "/image"})
+		require.NoError(t, err)
+		require.Subset(t, cmd, []string{"-dtb", "/image" + DefaultDtbPath})
+		require.NotContains(t, cmd, "-initrd")
+	})
+	t.Run("Invalid raw rootfs", func(t *testing.T) {
+		_, err := QemuCmd(map[string]string{
+			"com.urunc.unikernel.binary":      "kernel",
+			"com.urunc.unikernel.mountRootfs": "true",
+		}, MonitorOpts{})
+		require.ErrorContains(t, err, "raw rootfs is not supported")
+	})
+	t.Run("Invalid no kernel", func(t *testing.T) {
+		_, err := QemuCmd(map[string]string{}, MonitorOpts{})
+		require.ErrorContains(t, err, "Can not find the kernel")
+	})
+}
+
+func TestMonitorsFirecrackerConfig(t *testing.T) {
+	t.Run("Kernel, initrd and block", func(t *testing.T) {
+		cfgBytes, err := FirecrackerConfig(map[string]string{
+			"com.urunc.unikernel.binary":  DefaultKernelPath,
+			"com.urunc.unikernel.initrd":  DefaultRootfsPath,
+			"com.urunc.unikernel.block":   "/disk.img",
+			"com.urunc.unikernel.cmdline": "foo bar",
+		}, MonitorOpts{RootDir: "/tmp/image"})
+		require.NoError(t, err)
+		var cfg fcConfig
+		err = json.Unmarshal(cfgBytes, &cfg)
+		require.NoError(t, err)
+		require.Equal(t, "/tmp/image"+DefaultKernelPath, cfg.BootSource.KernelImagePath)
+		require.Equal(t, "/tmp/image"+DefaultRootfsPath, cfg.BootSource.InitrdPath)
+		require.Equal(t, "foo bar", cfg.BootSource.BootArgs)
+		require.Equal(t, 1, len(cfg.Drives))
+		require.Equal(t, "/tmp/image/disk.img", cfg.Drives[0].PathOnHost)
+		require.Equal(t, 512, cfg.MachineConfig.MemSizeMib)
+	})
+	t.Run("Kernel only", func(t *testing.T) {
+		cfgBytes, err := FirecrackerConfig(map[string]string{
+			"com.urunc.unikernel.binary": "kernel",
+		}, MonitorOpts{RootDir: "/tmp/image", Memory: 128})
+		require.NoError(t, err)
+		var cfg fcConfig
+		err = json.Unmarshal(cfgBytes, &cfg)
+		require.NoError(t, err)
+		require.Equal(t, "/tmp/image/kernel", cfg.BootSource.KernelImagePath)
+		require.Empty(t, cfg.BootSource.InitrdPath)
+		require.Equal(t, 0, len(cfg.Drives))
+		require.Equal(t, 128, cfg.MachineConfig.MemSizeMib)
+	})
+	t.Run("Invalid no kernel", func(t *testing.T) {
+		_, err := FirecrackerConfig(map[string]string{}, MonitorOpts{})
+		require.ErrorContains(t, err, "Can not find the kernel")
+	})
+	t.Run("Kernel per monitor", func(t *testing.T) {
+		annots := map[string]string{
+			"com.urunc.unikernel.binary":             DefaultKernelPath,
+			KernelVariantAnnotPrefix + "qemu":        DefaultKernelPath,
+			KernelVariantAnnotPrefix + "firecracker": "/.boot/kernel-firecracker",
+		}
+		cfgBytes, err := FirecrackerConfig(annots, MonitorOpts{RootDir: "/tmp/image"})
+		require.NoError(t, err)
+		var cfg fcConfig
+		require.NoError(t, json.Unmarshal(cfgBytes, &cfg))
+		require.Equal(t, "/tmp/image/.boot/kernel-firecracker", cfg.BootSource.KernelImagePath)
+
+		// The other monitors get their own kernel or the kernel of the image
+		cmd, err := QemuCmd(annots, MonitorOpts{RootDir: "/tmp/image"})
+		if runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64" {
+			require.NoError(t, err)
+			require.Contains(t, strings.Join(cmd, " "), "-kernel /tmp/image"+DefaultKernelPath)
+		}
+		cmd, err = CloudHypervisorCmd(annots, MonitorOpts{RootDir: "/tmp/image"})
+		require.NoError(t, err)
+		require.Contains(t, cmd, "/tmp/image"+DefaultKernelPath)
+	})
+}
+
+func TestMonitorsNormalize(t *testing.T) {
+	require.Equal(t, MonitorCloudHypervisor, NormalizeMonitor("clh"))
+	require.Equal(t, MonitorCloudHypervisor, NormalizeMonitor("CloudHypervisor"))
+	require.Equal(t, MonitorCloudHypervisor, NormalizeMonitor(MonitorCloudHypervisor))
+	require.Equal(t, "qemu", NormalizeMonitor("qemu"))
+	require.Equal(t, "hvt", NormalizeMonitor("hvt"))
+	require.Equal(t, MonitorSolo5Virtio, NormalizeMonitor("virtio"))
+	require.Equal(t, MonitorXen, NormalizeMonitor("xen-pvh"))
+	require.Equal(t, MonitorXen, NormalizeMonitor("xen"))
+}
+
+func TestMonitorsPlatformOS(t *testing.T) {
+	require.Equal(t, "fc", monitorPlatformOS("firecracker"))
+	require.Equal(t, "qemu", monitorPlatformOS("qemu"))
+	require.Equal(t, "xen", monitorPlatformOS(MonitorXen))
+}
+
+func TestMonitorsCloudHypervisorCmd(t *testing.T) {
+	t.Run("Kernel and initrd", func(t *testing.T) {
+		cmd, err := CloudHypervisorCmd(map[string]string{
+			"com.urunc.unikernel.binary":  DefaultKernelPath,
+			"com.urunc.unikernel.initrd":  DefaultRootfsPath,
+			"com.urunc.unikernel.cmdline": "foo bar",
+		}, MonitorOpts{RootDir: "/image"})
+		require.NoError(t, err)
+		require.Equal(t, "cloud-hypervisor", cmd[0])
+		require.Subset(t, cmd, []string{"--kernel", "/image" + DefaultKernelPath})
+		require.Subset(t, cmd, []string{"--memory", "size=512M"})
+		require.Subset(t, cmd, []string{"--initramfs", "/image" + DefaultRootfsPath})
+		require.Equal(t, []string{"--cmdline", "foo bar"}, cmd[len(cmd)-2:])
+		require.NotContains(t, cmd, "--disk")
+	})
+	t.Run("Block and memory", func(t *testing.T) {
+		cmd, err := CloudHypervisorCmd(map[string]string{
+			"com.urunc.unikernel.binary": "kernel",
+			"com.urunc.unikernel.block":  "disk.img",
+		}, MonitorOpts{RootDir: "/image", Memory: 1024})
+		require.NoError(t, err)
+		require.Subset(t, cmd, []string{"--memory", "size=1024M"})
+		require.Subset(t, cmd, []string{"--disk", "path=/image/disk.img,readonly=on"})
+		require.NotContains(t, cmd, "--initramfs")
+		require.NotContains(t, cmd, "--cmdline")
+	})
+	t.Run("Invalid no kernel", func(t *testing.T) {
+		_, err := CloudHypervisorCmd(map[string]string{}, MonitorOpts{})
+		require.ErrorContains(t, err, "Can not find the kernel")
+	})
+}
+
+func TestMonitorsParseBunnyfile(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(`
+version: 0.1
+platforms:
+  framework: linux
+  monitor: clh
+kernel:
+  from: local
+  path: vmlinux
+`))
+	require.NoError(t, err)
+	require.Equal(t, MonitorCloudHypervisor, h.Platform.Monitor)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+// Replaced by the variant of the image in the names of the image, e.g. qemu
+const PlaceholderVariant string = "{{variant}}"
+
+// ImageNames expands the given templates of the names of the image,
+// separated by commas (e.g. harbor.nbfc.io/app:${TAG}-{{monitor}}-{{arch}}),
+// with the build arguments, the architecture and the monitor of the image and
+// its variant, if any. It returns the names separated by commas, as the image
+// exporter of buildkit expects them.
+func (i *PackInstructions) ImageNames(templates string, args map[string]string) (string, error) {
+	var names []string
+	for _, tmpl := range strings.Split(templates, ",") {
+		tmpl = strings.TrimSpace(tmpl)
+		if tmpl == "" {
+			continue
+		}
+		name, err := expandBuildArgs(tmpl, args)
+		if err != nil {
+			return "", fmt.Errorf("Invalid template %s: %v", tmpl, err)
+		}
+		name = strings.ReplaceAll(name, PlaceholderVariant, i.Annots[VariantAnnotation])
+		name = expandPlaceholders(name, i.Img.Architecture, i.Annots["com.urunc.unikernel.hypervisor"])
+		_, err = reference.ParseNormalizedNamed(name)
+		if err != nil {
+			return "", fmt.Errorf("The template %s gives the invalid name %q: %v", tmpl, name, err)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("No templates of image names")
+	}
+
+	return strings.Join(names, ","), nil
+}
+
+// SetImageNames sets the names of the image of the result, which the image
+// exporter uses when its name is *.
+func SetImageNames(res *client.Result, names string) {
+	res.AddMeta(exptypes.ExporterImageNameKey, []byte(names))
+}
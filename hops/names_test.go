@@ -0,0 +1,85 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func namesInstructions(variant string) *PackInstructions {
+	i := &PackInstructions{
+		Annots: map[string]string{"com.urunc.unikernel.hypervisor": "firecracker"},
+		Img:    ocispecs.Image{Platform: ocispecs.Platform{OS: "linux", Architecture: "arm64"}},
+	}
+	if variant != "" {
+		i.Annots[VariantAnnotation] = variant
+	}
+
+	return i
+}
+
+func TestNamesExpand(t *testing.T) {
+	args := map[string]string{"TAG": "v1.2"}
+	i := namesInstructions("debug")
+	names, err := i.ImageNames("harbor.nbfc.io/app:${TAG}-{{monitor}}-{{arch}}", args)
+	require.NoError(t, err)
+	require.Equal(t, "harbor.nbfc.io/app:v1.2-firecracker-arm64", names)
+
+	names, err = i.ImageNames("harbor.nbfc.io/app:${TAG}-{{variant}}, harbor.nbfc.io/app:{{variant}},", args)
+	require.NoError(t, err)
+	require.Equal(t, "harbor.nbfc.io/app:v1.2-debug,harbor.nbfc.io/app:debug", names)
+
+	tests := []struct {
+		name      string
+		templates string
+		errorText string
+	}{
+		{"Missing build argument", "app:${VERSION}-{{monitor}}", `The template app:${VERSION}-{{monitor}} gives the invalid name "app:-firecracker"`},
+		{"Unterminated build argument", "app:${TAG", "Invalid template app:${TAG: unterminated ${"},
+		{"Uppercase name", "App:${TAG}", `gives the invalid name "App:v1.2"`},
+		{"No templates", " , ", "No templates of image names"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := i.ImageNames(tc.templates, args)
+			require.ErrorContains(t, err, tc.errorText)
+		})
+	}
+	t.Run("Without variant", func(t *testing.T) {
+		names, err := namesInstructions("").ImageNames("app:latest{{variant}}", nil)
+		require.NoError(t, err)
+		require.Equal(t, "app:latest", names)
+	})
+}
+
+func TestNamesVariants(t *testing.T) {
+	named := func(names string) *client.Result {
+		res := variantResult(&fakeRef{}, "amd64", nil)
+		SetImageNames(res, names)
+		return res
+	}
+
+	res, err := ApplyVariants([]string{"qemu", "firecracker"}, []*client.Result{named("app:v1"), named("app:v1")})
+	require.NoError(t, err)
+	require.Equal(t, "app:v1", string(res.Metadata[exptypes.ExporterImageNameKey]))
+
+	_, err = ApplyVariants([]string{"qemu", "firecracker"}, []*client.Result{named("app:qemu"), named("app:firecracker")})
+	require.ErrorContains(t, err, "The variants qemu and firecracker have different image names")
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+const (
+	networkRetriesEnv string = "BUNNY_NETWORK_RETRIES"
+	networkTimeoutEnv string = "BUNNY_NETWORK_TIMEOUT"
+)
+
+// The shell function that runs network-bound commands inside the generated
+// exec operations. It retries the command as many times as the retries
+// environment variable defines and kills every attempt that exceeds the
+// timeout (in seconds), if any.
+const retryFunction = `retry() {
+	attempt=0
+	while :; do
+		if [ "${` + networkTimeoutEnv + `:-0}" -gt 0 ]; then
+			timeout "${` + networkTimeoutEnv + `}" "$@" && return 0
+		else
+			"$@" && return 0
+		fi
+		attempt=$((attempt + 1))
+		if [ "$attempt" -gt "${` + networkRetriesEnv + `:-0}" ]; then
+			echo "Giving up on $* after $attempt attempts" >&2
+			return 1
+		fi
+		echo "Retrying $* ($attempt/${` + networkRetriesEnv + `})" >&2
+		sleep $((attempt * 2))
+	done
+}
+`
+
+// NetworkPolicy defines how network-bound steps inside the build behave
+type NetworkPolicy struct {
+	// How many times to retry a failed step
+	Retries int `yaml:"retries"`
+	// The maximum duration of each attempt
+	Timeout string `yaml:"timeout"`
+	// The proxies to use, as given in the build arguments
+	Proxy *llb.ProxyEnv `yaml:"-"`
+}
+
+// ProxyFromBuildArgs returns the proxies that are defined in the build
+// arguments, if any. Like the names of the variables, the names of the build
+// arguments are case insensitive.
+func ProxyFromBuildArgs(args map[string]string) *llb.ProxyEnv {
+	var proxy llb.ProxyEnv
+	found := false
+	for k, v := range args {
+		switch strings.ToLower(k) {
+		case "http_proxy":
+			proxy.HTTPProxy = v
+		case "https_proxy":
+			proxy.HTTPSProxy = v
+		case "ftp_proxy":
+			proxy.FTPProxy = v
+		case "no_proxy":
+			proxy.NoProxy = v
+		case "all_proxy":
+			proxy.AllProxy = v
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+
+	return &proxy
+}
+
+// ParseNetworkPolicy parses the retries and the timeout as given in the
+// frontend options. Empty values are left unset.
+func ParseNetworkPolicy(retries string, timeout string) (NetworkPolicy, error) {
+	p := NetworkPolicy{Timeout: timeout}
+	if retries != "" {
+		r, err := strconv.Atoi(retries)
+		if err != nil {
+			return NetworkPolicy{}, fmt.Errorf("Invalid retries %s: %v", retries, err)
+		}
+		p.Retries = r
+	}
+	err := ValidateNetwork(p)
+	if err != nil {
+		return NetworkPolicy{}, err
+	}
+
+	return p, nil
+}
+
+// Merge returns a policy with the fields of p, overridden by any field that
+// is set in other.
+func (p NetworkPolicy) Merge(other NetworkPolicy) NetworkPolicy {
+	if other.Retries != 0 {
+		p.Retries = other.Retries
+	}
+	if other.Timeout != "" {
+		p.Timeout = other.Timeout
+	}
+	if other.Proxy != nil {
+		p.Proxy = other.Proxy
+	}
+
+	return p
+}
+
+// GetTimeout returns the timeout of each attempt in seconds. Zero means no
+// timeout.
+func (p NetworkPolicy) GetTimeout() (int, error) {
+	if p.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(p.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid timeout %s: %v", p.Timeout, err)
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("Timeout %s should be at least 1s", p.Timeout)
+	}
+
+	return int(d.Seconds()), nil
+}
+
+// ProxyOptions returns the options that pass the proxies to an exec
+// operation. The proxies do not affect the cache key of the operation.
+func (p NetworkPolicy) ProxyOptions() []llb.RunOption {
+	if p.Proxy == nil {
+		return nil
+	}
+
+	return []llb.RunOption{llb.WithProxy(*p.Proxy)}
+}
+
+// ProxyBuildArgs returns the proxies as build arguments, so the dockerfile
+// frontend passes them to the RUN instructions of Containerfiles.
+func (p NetworkPolicy) ProxyBuildArgs() map[string]string {
+	if p.Proxy == nil {
+		return nil
+	}
+	args := map[string]string{}
+	for k, v := range map[string]string{
+		"HTTP_PROXY":  p.Proxy.HTTPProxy,
+		"HTTPS_PROXY": p.Proxy.HTTPSProxy,
+		"FTP_PROXY":   p.Proxy.FTPProxy,
+		"NO_PROXY":    p.Proxy.NoProxy,
+		"ALL_PROXY":   p.Proxy.AllProxy,
+	} {
+		if v != "" {
+			args[k] = v
+		}
+	}
+
+	return args
+}
+
+// RunOptions returns the options that pass the policy to an exec operation,
+// which uses the retry function.
+func (p NetworkPolicy) RunOptions() []llb.RunOption {
+	// The policy was validated, when we parsed it
+	timeout, _ := p.GetTimeout()
+
+	return append([]llb.RunOption{
+		llb.AddEnv(networkRetriesEnv, strconv.Itoa(p.Retries)),
+		llb.AddEnv(networkTimeoutEnv, strconv.Itoa(timeout)),
+	}, p.ProxyOptions()...)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkParse(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		p, err := ParseNetworkPolicy("3", "5m")
+		require.NoError(t, err)
+		require.Equal(t, NetworkPolicy{Retries: 3, Timeout: "5m"}, p)
+	})
+	t.Run("Valid empty", func(t *testing.T) {
+		p, err := ParseNetworkPolicy("", "")
+		require.NoError(t, err)
+		require.Equal(t, NetworkPolicy{}, p)
+	})
+	t.Run("Invalid retries", func(t *testing.T) {
+		_, err := ParseNetworkPolicy("many", "")
+		require.ErrorContains(t, err, "Invalid retries many")
+	})
+	t.Run("Invalid negative retries", func(t *testing.T) {
+		_, err := ParseNetworkPolicy("-1", "")
+		require.ErrorContains(t, err, "can not be negative")
+	})
+	t.Run("Invalid timeout", func(t *testing.T) {
+		_, err := ParseNetworkPolicy("", "100ms")
+		require.ErrorContains(t, err, "should be at least 1s")
+	})
+}
+
+func TestNetworkMerge(t *testing.T) {
+	p := NetworkPolicy{Retries: 2, Timeout: "1m"}
+	require.Equal(t, p, p.Merge(NetworkPolicy{}))
+	require.Equal(t, NetworkPolicy{Retries: 5, Timeout: "1m"}, p.Merge(NetworkPolicy{Retries: 5}))
+	require.Equal(t, NetworkPolicy{Retries: 2, Timeout: "10s"}, p.Merge(NetworkPolicy{Timeout: "10s"}))
+}
+
+func TestNetworkKraftBuild(t *testing.T) {
+	unikraft := &UnikraftInfo{Arch: "x86_64"}
+	state, err := unikraft.BuildKernel(context.TODO(), BuildInput{
+		BuildContext: "context",
+		Monitor:      "qemu",
+		Kernel:       Kernel{From: KernelFromBuild, Source: "app"},
+		Network:      NetworkPolicy{Retries: 3, Timeout: "2m"},
+	})
+	require.NoError(t, err)
+	exec, _, _ := kraftExec(t, state)
+	require.Contains(t, exec.Meta.Env, "BUNNY_NETWORK_RETRIES=3")
+	require.Contains(t, exec.Meta.Env, "BUNNY_NETWORK_TIMEOUT=120")
+}
+
+func TestNetworkProxy(t *testing.T) {
+	t.Run("No proxy", func(t *testing.T) {
+		require.Nil(t, ProxyFromBuildArgs(map[string]string{"FOO": "bar"}))
+		require.Nil(t, NetworkPolicy{}.ProxyOptions())
+		require.Nil(t, NetworkPolicy{}.ProxyBuildArgs())
+	})
+	t.Run("Proxy from build args", func(t *testing.T) {
+		proxy := ProxyFromBuildArgs(map[string]string{
+			"HTTP_PROXY":  "http://proxy:3128",
+			"https_proxy": "http://proxy:3129",
+			"NO_PROXY":    "localhost",
+			"FOO":         "bar",
+		})
+		require.Equal(t, &llb.ProxyEnv{
+			HTTPProxy:  "http://proxy:3128",
+			HTTPSProxy: "http://proxy:3129",
+			NoProxy:    "localhost",
+		}, proxy)
+		require.Equal(t, map[string]string{
+			"HTTP_PROXY":  "http://proxy:3128",
+			"HTTPS_PROXY": "http://proxy:3129",
+			"NO_PROXY":    "localhost",
+		}, NetworkPolicy{Proxy: proxy}.ProxyBuildArgs())
+	})
+	t.Run("Proxy in initrd", func(t *testing.T) {
+		p := NetworkPolicy{Proxy: &llb.ProxyEnv{HTTPProxy: "http://proxy:3128"}}
+		state := InitrdLLB(llb.Local("context"), Owner{}, p.ProxyOptions()...)
+		def, err := state.Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var found bool
+		for _, op := range arr {
+			if exec := op.GetExec(); exec != nil {
+				require.Equal(t, "http://proxy:3128", exec.Meta.ProxyEnv.HttpProxy)
+				found = true
+			}
+		}
+		require.True(t, found)
+	})
+	t.Run("Proxy in kernel build", func(t *testing.T) {
+		unikraft := &UnikraftInfo{}
+		state, err := unikraft.BuildKernel(context.TODO(), BuildInput{
+			Monitor: "qemu",
+			Kernel:  Kernel{From: KernelFromBuild, Source: "app"},
+			Network: NetworkPolicy{Proxy: &llb.ProxyEnv{HTTPSProxy: "http://proxy:3129"}},
+		})
+		require.NoError(t, err)
+		exec, _, _ := kraftExec(t, state)
+		require.Equal(t, "http://proxy:3129", exec.Meta.ProxyEnv.HttpsProxy)
+	})
+	t.Run("Proxy from frontend options", func(t *testing.T) {
+		h := &Hops{Network: NetworkPolicy{Retries: 1}}
+		proxy := &llb.ProxyEnv{NoProxy: "localhost"}
+		h.Network = h.Network.Merge(NetworkPolicy{Proxy: proxy})
+		require.Equal(t, NetworkPolicy{Retries: 1, Proxy: proxy}, h.Network)
+	})
+}
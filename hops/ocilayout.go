@@ -0,0 +1,289 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	whiteoutPrefix string = ".wh."
+)
+
+func blobPath(layoutDir string, dgst digest.Digest) (string, error) {
+	err := dgst.Validate()
+	if err != nil {
+		return "", fmt.Errorf("Invalid digest %s: %v", dgst, err)
+	}
+
+	return filepath.Join(layoutDir, "blobs", dgst.Algorithm().String(), dgst.Encoded()), nil
+}
+
+// layoutReader reads a file of an OCI layout
+type layoutReader func(string) ([]byte, error)
+
+func readBlobJSON(read layoutReader, layoutDir string, dgst digest.Digest, v any) error {
+	p, err := blobPath(layoutDir, dgst)
+	if err != nil {
+		return err
+	}
+	content, err := read(p)
+	if err != nil {
+		return fmt.Errorf("Failed to read blob %s: %v", dgst, err)
+	}
+	err = json.Unmarshal(content, v)
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshal blob %s: %v", dgst, err)
+	}
+
+	return nil
+}
+
+// resolveManifest follows the given descriptor until it finds an image
+// manifest. In the case of an image index, it prefers the manifest for
+// the host architecture.
+func resolveManifest(read layoutReader, layoutDir string, desc ocispecs.Descriptor) (ocispecs.Manifest, error) {
+	switch desc.MediaType {
+	case ocispecs.MediaTypeImageManifest, "application/vnd.docker.distribution.manifest.v2+json":
+		var m ocispecs.Manifest
+		err := readBlobJSON(read, layoutDir, desc.Digest, &m)
+		return m, err
+	case ocispecs.MediaTypeImageIndex, "application/vnd.docker.distribution.manifest.list.v2+json":
+		var idx ocispecs.Index
+		err := readBlobJSON(read, layoutDir, desc.Digest, &idx)
+		if err != nil {
+			return ocispecs.Manifest{}, err
+		}
+		return selectManifest(read, layoutDir, idx)
+	default:
+		return ocispecs.Manifest{}, fmt.Errorf("Unsupported media type %s", desc.MediaType)
+	}
+}
+
+func selectManifest(read layoutReader, layoutDir string, idx ocispecs.Index) (ocispecs.Manifest, error) {
+	if len(idx.Manifests) == 0 {
+		return ocispecs.Manifest{}, fmt.Errorf("The image index is empty")
+	}
+	for _, m := range idx.Manifests {
+		if m.Platform != nil && m.Platform.Architecture == runtime.GOARCH {
+			return resolveManifest(read, layoutDir, m)
+		}
+	}
+
+	return resolveManifest(read, layoutDir, idx.Manifests[0])
+}
+
+// extractTar extracts the contents of a tar stream under dst, applying
+// any whiteout files it encounters. Every entry is extracted through a root
+// at dst, so neither the names nor the symlinks of earlier entries can write
+// outside of it.
+func extractTar(r io.Reader, dst string) error {
+	root, err := os.OpenRoot(dst)
+	if err != nil {
+		return fmt.Errorf("Failed to open %s: %v", dst, err)
+	}
+	defer root.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read tar entry: %v", err)
+		}
+
+		// Clean the name against the root, so it is relative to it
+		name := filepath.Clean("/" + hdr.Name)
+		target := rootPath(name)
+		base := filepath.Base(name)
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			hidden := filepath.Join(filepath.Dir(target), strings.TrimPrefix(base, whiteoutPrefix))
+			err = root.RemoveAll(hidden)
+			if err != nil {
+				return fmt.Errorf("Failed to apply whiteout %s: %v", name, err)
+			}
+			continue
+		}
+
+		err = root.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return fmt.Errorf("Failed to create parent directory of %s: %v", name, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = root.MkdirAll(target, os.FileMode(hdr.Mode)&os.ModePerm|0700)
+		case tar.TypeReg:
+			err = writeFile(root, tr, target, os.FileMode(hdr.Mode)&os.ModePerm)
+		case tar.TypeSymlink:
+			_ = root.Remove(target)
+			err = root.Symlink(hdr.Linkname, target)
+		case tar.TypeLink:
+			_ = root.Remove(target)
+			err = root.Link(rootPath(filepath.Clean("/"+hdr.Linkname)), target)
+		default:
+			// Device files, fifos etc. are not required to boot the image
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to extract %s: %v", name, err)
+		}
+	}
+}
+
+// rootPath returns the given absolute path relative to the root of the
+// extraction
+func rootPath(name string) string {
+	if name == "/" {
+		return "."
+	}
+
+	return strings.TrimPrefix(name, "/")
+}
+
+func writeFile(root *os.Root, r io.Reader, target string, mode os.FileMode) error {
+	f, err := root.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func extractLayer(layoutDir string, desc ocispecs.Descriptor, dst string) error {
+	p, err := blobPath(layoutDir, desc.Digest)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("Failed to open layer %s: %v", desc.Digest, err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(desc.MediaType, "gzip") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("Failed to decompress layer %s: %v", desc.Digest, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return extractTar(r, dst)
+}
+
+// readUruncJSON reads and decodes the urunc.json file of an extracted image.
+func readUruncJSON(rootDir string) (map[string]string, error) {
+	content, err := os.ReadFile(filepath.Join(rootDir, uruncJSONPath))
+	if err != nil {
+		return nil, err
+	}
+	encoded := map[string]string{}
+	err = json.Unmarshal(content, &encoded)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal %s: %v", uruncJSONPath, err)
+	}
+	annots := make(map[string]string, len(encoded))
+	for k, v := range encoded {
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode value of %s in %s: %v", k, uruncJSONPath, err)
+		}
+		annots[k] = string(decoded)
+	}
+
+	return annots, nil
+}
+
+// ExtractOCIImage extracts the rootfs of the image stored in an OCI image
+// layout to dst. The layout can be either a directory or a tar archive, as
+// produced by buildkit's oci exporter. It returns the annotations of the
+// image, giving precedence to the values in urunc.json.
+func ExtractOCIImage(src string, dst string) (map[string]string, error) {
+	st, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to access %s: %v", src, err)
+	}
+	layoutDir := src
+	if !st.IsDir() {
+		layoutDir, err = os.MkdirTemp("", "bunny-oci-")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create temporary directory: %v", err)
+		}
+		defer os.RemoveAll(layoutDir)
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open %s: %v", src, err)
+		}
+		defer f.Close()
+		err = extractTar(f, layoutDir)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to extract %s: %v", src, err)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(layoutDir, ocispecs.ImageIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read index of OCI layout: %v", err)
+	}
+	var idx ocispecs.Index
+	err = json.Unmarshal(content, &idx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal index of OCI layout: %v", err)
+	}
+	manifest, err := selectManifest(os.ReadFile, layoutDir, idx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to find image manifest: %v", err)
+	}
+
+	for _, layer := range manifest.Layers {
+		err = extractLayer(layoutDir, layer, dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	annots := map[string]string{}
+	for k, v := range manifest.Annotations {
+		annots[k] = v
+	}
+	uruncAnnots, err := readUruncJSON(dst)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for k, v := range uruncAnnots {
+		annots[k] = v
+	}
+
+	return annots, nil
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	name    string
+	content string
+	dir     bool
+	// The target of a symlink
+	link string
+}
+
+func makeTar(t *testing.T, entries []tarEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name: e.name,
+			Mode: 0644,
+			Size: int64(len(e.content)),
+		}
+		if e.dir {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+			hdr.Size = 0
+		}
+		if e.link != "" {
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.link
+			hdr.Size = 0
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if !e.dir && e.link == "" {
+			_, err := tw.Write([]byte(e.content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	return buf.Bytes()
+}
+
+func writeBlob(t *testing.T, layoutDir string, content []byte) digest.Digest {
+	dgst := digest.FromBytes(content)
+	p := filepath.Join(layoutDir, "blobs", "sha256", dgst.Encoded())
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+	require.NoError(t, os.WriteFile(p, content, 0644))
+
+	return dgst
+}
+
+// makeLayout creates an OCI image layout with one layer per list of entries
+func makeLayout(t *testing.T, annots map[string]string, layers ...[]tarEntry) string {
+	layoutDir := t.TempDir()
+	manifest := ocispecs.Manifest{
+		MediaType:   ocispecs.MediaTypeImageManifest,
+		Annotations: annots,
+	}
+	for _, entries := range layers {
+		var gzBuf bytes.Buffer
+		gz := gzip.NewWriter(&gzBuf)
+		_, err := gz.Write(makeTar(t, entries))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		dgst := writeBlob(t, layoutDir, gzBuf.Bytes())
+		manifest.Layers = append(manifest.Layers, ocispecs.Descriptor{
+			MediaType: ocispecs.MediaTypeImageLayerGzip,
+			Digest:    dgst,
+			Size:      int64(gzBuf.Len()),
+		})
+	}
+	mBytes, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	mDgst := writeBlob(t, layoutDir, mBytes)
+	idx := ocispecs.Index{
+		MediaType: ocispecs.MediaTypeImageIndex,
+		Manifests: []ocispecs.Descriptor{
+			{
+				MediaType: ocispecs.MediaTypeImageManifest,
+				Digest:    mDgst,
+				Size:      int64(len(mBytes)),
+			},
+		},
+	}
+	iBytes, err := json.Marshal(idx)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(layoutDir, ocispecs.ImageIndexFile), iBytes, 0644))
+
+	return layoutDir
+}
+
+func encodedUruncJSON(t *testing.T, annots map[string]string) string {
+	encoded := map[string]string{}
+	for k, v := range annots {
+		encoded[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	content, err := json.Marshal(encoded)
+	require.NoError(t, err)
+
+	return string(content)
+}
+
+func TestOCILayoutExtract(t *testing.T) {
+	uruncJSON := encodedUruncJSON(t, map[string]string{
+		"com.urunc.unikernel.binary":     DefaultKernelPath,
+		"com.urunc.unikernel.hypervisor": "qemu",
+	})
+	t.Run("Layout directory", func(t *testing.T) {
+		layoutDir := makeLayout(t, map[string]string{
+			"com.urunc.unikernel.hypervisor": "firecracker",
+			"foo":                            "bar",
+		}, []tarEntry{
+			{name: ".boot", dir: true},
+			{name: ".boot/kernel", content: "kernel"},
+			{name: "urunc.json", content: uruncJSON},
+		})
+		dst := t.TempDir()
+
+		annots, err := ExtractOCIImage(layoutDir, dst)
+		require.NoError(t, err)
+		require.Equal(t, "bar", annots["foo"])
+		require.Equal(t, "qemu", annots["com.urunc.unikernel.hypervisor"])
+		require.Equal(t, DefaultKernelPath, annots["com.urunc.unikernel.binary"])
+		content, err := os.ReadFile(filepath.Join(dst, DefaultKernelPath))
+		require.NoError(t, err)
+		require.Equal(t, "kernel", string(content))
+	})
+	t.Run("Layout archive with whiteout", func(t *testing.T) {
+		layoutDir := makeLayout(t, nil, []tarEntry{
+			{name: ".boot/kernel", content: "kernel"},
+			{name: ".boot/rootfs", content: "rootfs"},
+			{name: "urunc.json", content: uruncJSON},
+		}, []tarEntry{
+			{name: ".boot/.wh.rootfs"},
+		})
+		var entries []tarEntry
+		err := filepath.Walk(layoutDir, func(p string, info os.FileInfo, err error) error {
+			require.NoError(t, err)
+			if info.IsDir() {
+				return nil
+			}
+			content, err := os.ReadFile(p)
+			require.NoError(t, err)
+			rel, err := filepath.Rel(layoutDir, p)
+			require.NoError(t, err)
+			entries = append(entries, tarEntry{name: rel, content: string(content)})
+			return nil
+		})
+		require.NoError(t, err)
+		archive := filepath.Join(t.TempDir(), "image.tar")
+		require.NoError(t, os.WriteFile(archive, makeTar(t, entries), 0644))
+		dst := t.TempDir()
+
+		annots, err := ExtractOCIImage(archive, dst)
+		require.NoError(t, err)
+		require.Equal(t, "qemu", annots["com.urunc.unikernel.hypervisor"])
+		_, err = os.Stat(filepath.Join(dst, DefaultKernelPath))
+		require.NoError(t, err)
+		_, err = os.Stat(filepath.Join(dst, DefaultRootfsPath))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+	t.Run("Path traversal stays in destination", func(t *testing.T) {
+		layoutDir := makeLayout(t, nil, []tarEntry{
+			{name: "../../escape", content: "foo"},
+		})
+		dst := t.TempDir()
+
+		_, err := ExtractOCIImage(layoutDir, dst)
+		require.NoError(t, err)
+		_, err = os.Stat(filepath.Join(dst, "escape"))
+		require.NoError(t, err)
+	})
+	t.Run("Symlink stays in destination", func(t *testing.T) {
+		outside := t.TempDir()
+		layoutDir := makeLayout(t, nil, []tarEntry{
+			{name: "out", link: outside},
+			{name: "out/escape", content: "foo"},
+		})
+
+		_, err := ExtractOCIImage(layoutDir, t.TempDir())
+		require.ErrorContains(t, err, "path escapes from parent")
+		_, err = os.Stat(filepath.Join(outside, "escape"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		// The same for an archive of a layout
+		archive := makeTar(t, []tarEntry{
+			{name: "up", link: "../.."},
+			{name: "up/escape", content: "foo"},
+		})
+		dst := filepath.Join(t.TempDir(), "a", "b")
+		require.NoError(t, os.MkdirAll(dst, 0755))
+		err = extractTar(bytes.NewReader(archive), dst)
+		require.ErrorContains(t, err, "path escapes from parent")
+		_, err = os.Stat(filepath.Join(dst, "..", "..", "escape"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+	t.Run("Symlink inside destination", func(t *testing.T) {
+		archive := makeTar(t, []tarEntry{
+			{name: "usr/lib", dir: true},
+			{name: "lib", link: "usr/lib"},
+			{name: "lib/libc.so", content: "libc"},
+		})
+		dst := t.TempDir()
+		require.NoError(t, extractTar(bytes.NewReader(archive), dst))
+		content, err := os.ReadFile(filepath.Join(dst, "usr", "lib", "libc.so"))
+		require.NoError(t, err)
+		require.Equal(t, "libc", string(content))
+	})
+	t.Run("Invalid missing layout", func(t *testing.T) {
+		_, err := ExtractOCIImage(filepath.Join(t.TempDir(), "foo"), t.TempDir())
+		require.ErrorContains(t, err, "Failed to access")
+	})
+	t.Run("Invalid missing index", func(t *testing.T) {
+		_, err := ExtractOCIImage(t.TempDir(), t.TempDir())
+		require.ErrorContains(t, err, "Failed to read index")
+	})
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+// Owner is the numeric user and group that own files. The zero value is
+// root, the owner of the files of an initrd by default.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// ParseOwner parses an owner in the uid:gid form. An empty value means root.
+func ParseOwner(value string) (Owner, error) {
+	if value == "" {
+		return Owner{}, nil
+	}
+	uid, gid, found := strings.Cut(value, ":")
+	if !found {
+		return Owner{}, fmt.Errorf("Invalid owner %q, expected uid:gid", value)
+	}
+	var o Owner
+	var err error
+	o.UID, err = strconv.Atoi(uid)
+	if err != nil || o.UID < 0 {
+		return Owner{}, fmt.Errorf("Invalid user %q of owner %s, expected a numeric uid", uid, value)
+	}
+	o.GID, err = strconv.Atoi(gid)
+	if err != nil || o.GID < 0 {
+		return Owner{}, fmt.Errorf("Invalid group %q of owner %s, expected a numeric gid", gid, value)
+	}
+
+	return o, nil
+}
+
+// ParseMode parses the permissions of copied files in octal, e.g. 0755 or
+// 644.
+func ParseMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 07777 {
+		return 0, fmt.Errorf("Invalid mode %q, expected octal permissions, e.g. 0755", value)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// String returns the owner in the uid:gid form, as bsdcpio -R expects
+func (o Owner) String() string {
+	return fmt.Sprintf("%d:%d", o.UID, o.GID)
+}
+
+// copyOption returns the option of a copy that chowns the files to the owner
+func (o Owner) copyOption() llb.ChownOpt {
+	return llb.ChownOpt{
+		User:  &llb.UserOpt{UID: o.UID},
+		Group: &llb.UserOpt{UID: o.GID},
+	}
+}
+
+// applyOwner sets the owner of the copies of the given includes, unless an
+// include has its own owner. Without an owner, the copies keep the owner of
+// their source.
+func applyOwner(includes []FileToInclude, owner Owner) {
+	for i := range includes {
+		if includes[i].Owner == "" {
+			includes[i].Owner = owner.String()
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnerParse(t *testing.T) {
+	for input, expected := range map[string]Owner{
+		"":          {},
+		"0:0":       {},
+		"1000:1000": {UID: 1000, GID: 1000},
+		"65534:100": {UID: 65534, GID: 100},
+	} {
+		owner, err := ParseOwner(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, owner, input)
+	}
+	require.Equal(t, "65534:100", Owner{UID: 65534, GID: 100}.String())
+
+	_, err := ParseOwner("1000")
+	require.ErrorContains(t, err, `Invalid owner "1000", expected uid:gid`)
+	_, err = ParseOwner("root:0")
+	require.ErrorContains(t, err, `Invalid user "root" of owner root:0, expected a numeric uid`)
+	_, err = ParseOwner("0:-1")
+	require.ErrorContains(t, err, `Invalid group "-1" of owner 0:-1, expected a numeric gid`)
+}
+
+func TestOwnerLLB(t *testing.T) {
+	owner := Owner{UID: 1000, GID: 100}
+	t.Run("Copies", func(t *testing.T) {
+		files := []FileToInclude{
+			{Src: "app", Dst: "/app", Owner: owner.String()},
+			{Src: "conf", Dst: "/conf"},
+		}
+		def, err := FilesLLB(files, "context", llb.Scratch()).Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		cf := arr[len(arr)-2].Op.(*pb.Op_File).File
+		cp1 := cf.Actions[0].Action.(*pb.FileAction_Copy).Copy
+		require.Equal(t, uint32(1000), cp1.Owner.User.GetByID())
+		require.Equal(t, uint32(100), cp1.Owner.Group.GetByID())
+		cp2 := cf.Actions[1].Action.(*pb.FileAction_Copy).Copy
+		require.Nil(t, cp2.Owner)
+	})
+	t.Run("Initrd", func(t *testing.T) {
+		def, err := InitrdLLB(llb.Local("context"), owner).Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var args []string
+		for _, op := range arr {
+			if exec := op.GetExec(); exec != nil {
+				args = exec.Meta.Args
+			}
+		}
+		require.Contains(t, args[2], "bsdcpio -o -R 1000:100 --format newc")
+	})
+	t.Run("Initrd in the frontend", func(t *testing.T) {
+		ref := &fakeRef{
+			files: map[string][]byte{"/app": []byte("app")},
+			dirs:  map[string]bool{"/": true},
+		}
+		archive, err := CpioFromRef(context.TODO(), &ownedRef{ref}, owner)
+		require.NoError(t, err)
+		for _, entry := range parseCpio(t, archive) {
+			require.Equal(t, 1000, entry.uid, entry.name)
+			require.Equal(t, 100, entry.gid, entry.name)
+		}
+	})
+}
+
+func TestOwnerToPack(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+rootfs:
+  type: initrd
+  owner: 1000:100
+  include:
+    - app:/app
+`))
+	require.NoError(t, err)
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, Owner{UID: 1000, GID: 100}, i.Rootfs.InitrdOwner)
+	// The bunnyfile itself does not change
+	require.Empty(t, h.Rootfs.Includes[0].Owner)
+
+	def, err := i.Rootfs.InitrdContent.Marshal(context.TODO())
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var copies int
+	for _, op := range arr {
+		for _, action := range op.GetFile().GetActions() {
+			require.Equal(t, uint32(1000), action.GetCopy().Owner.User.GetByID())
+			copies++
+		}
+	}
+	require.Equal(t, 1, copies)
+
+	t.Run("Owner of an include", func(t *testing.T) {
+		includes := []FileToInclude{
+			{Src: "app", Dst: "/app"},
+			{Src: "conf", Dst: "/conf", Owner: "0:0"},
+		}
+		applyOwner(includes, Owner{UID: 1000, GID: 100})
+		require.Equal(t, "1000:100", includes[0].Owner)
+		require.Equal(t, "0:0", includes[1].Owner)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		err := ValidateRootfs(Rootfs{From: "scratch", Owner: "1000"})
+		require.ErrorContains(t, err, `Invalid owner field of rootfs: Invalid owner "1000", expected uid:gid`)
+		err = ValidateRootfs(Rootfs{From: "scratch", Owner: "1000:1000"})
+		require.ErrorContains(t, err, "The owner field of rootfs requires files to include")
+	})
+}
@@ -19,8 +19,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"runtime"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/moby/buildkit/client/llb"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
@@ -44,6 +45,15 @@ type FileToInclude struct {
 	From string `yaml:"from"`
 	Src  string `yaml:"source"`
 	Dst  string `yaml:"destination"`
+	// Skip the file if the build context does not have it
+	Optional bool `yaml:"optional"`
+	// The files of a directory to leave out, relative to the directory
+	Exclude []string `yaml:"exclude"`
+	// The permissions of the copied files in octal, e.g. 0755
+	Mode string `yaml:"mode"`
+	// The owner of the copied files in the uid:gid form, by default the
+	// owner of the rootfs
+	Owner string `yaml:"owner"`
 }
 
 type Rootfs struct {
@@ -51,22 +61,80 @@ type Rootfs struct {
 	Path     string          `yaml:"path"`
 	Type     string          `yaml:"type"`
 	Includes []FileToInclude `yaml:"include"`
+	Initrds  []Initrd        `yaml:"initrds"`
+	Owner    string          `yaml:"owner"`
+	// The fields that differ for each architecture
+	Arches map[string]RootfsOverride `yaml:"-"`
+}
+
+// ReusesImage returns true if the rootfs is the filesystem of an image as it
+// is, i.e. from is an image without a path, files to include or initrds.
+// Such a rootfs is always raw, since there is no rootfs file to pass to the
+// guest and urunc mounts the filesystem of the container instead.
+func (r Rootfs) ReusesImage() bool {
+	switch r.From {
+	case "", "scratch", "local":
+		return false
+	}
+
+	return r.Path == "" && len(r.Includes) == 0 && len(r.Initrds) == 0
 }
 
 type Kernel struct {
-	From string `yaml:"from"`
-	Path string `yaml:"path"`
+	From   string   `yaml:"from"`
+	Path   string   `yaml:"path"`
+	Source string   `yaml:"source"`
+	Config []string `yaml:"config"`
+	// The kernels of all monitors, when the kernel field is a list
+	Variants []KernelVariant `yaml:"-"`
+	// The fields that differ for each architecture
+	Arches map[string]KernelOverride `yaml:"-"`
+}
+
+type SmokeTest struct {
+	Image   string `yaml:"image"`
+	Marker  string `yaml:"marker"`
+	Timeout string `yaml:"timeout"`
+	KVM     bool   `yaml:"kvm"`
 }
 
 type Hops struct {
-	Version    string   `yaml:"version"`
-	Platform   Platform `yaml:"platforms"`
-	Rootfs     Rootfs   `yaml:"rootfs"`
-	Kernel     Kernel   `yaml:"kernel"`
-	Cmdline    string   `yaml:"cmdline"`
-	Cmd        []string `yaml:"cmd"`
-	Entrypoint []string `yaml:"entrypoint"`
-	Envs       []string `yaml:"envs"`
+	Version      string        `yaml:"version"`
+	Platform     Platform      `yaml:"platforms"`
+	Rootfs       Rootfs        `yaml:"rootfs"`
+	Kernel       Kernel        `yaml:"kernel"`
+	Cmdline      string        `yaml:"cmdline"`
+	Cmd          []string      `yaml:"cmd"`
+	Entrypoint   []string      `yaml:"entrypoint"`
+	Envs         []string      `yaml:"envs"`
+	Test         SmokeTest     `yaml:"test"`
+	Mirrors      Mirrors       `yaml:"mirrors"`
+	Artifacts    Artifacts     `yaml:"artifacts"`
+	Binary       Binary        `yaml:"binary"`
+	Network      NetworkPolicy `yaml:"network"`
+	Certificates []string      `yaml:"certificates"`
+	Flavors      []string      `yaml:"flavors"`
+	Hardened     bool          `yaml:"hardened"`
+	Build        BuildOptions  `yaml:"build"`
+	Scan         Scan          `yaml:"scan"`
+	Base         string        `yaml:"base"`
+	Modules      Modules       `yaml:"modules"`
+	Dtb          Dtb           `yaml:"dtb"`
+	Resources    Resources     `yaml:"resources"`
+	Metadata     Metadata      `yaml:"metadata"`
+	Shutdown     Shutdown      `yaml:"shutdown"`
+	Hooks        Hooks         `yaml:"hooks"`
+	Paths        Paths         `yaml:"paths"`
+	Variant      string        `yaml:"variant"`
+	Args         []BuildArg    `yaml:"args"`
+	User         string        `yaml:"user"`
+	Workdir      string        `yaml:"workdir"`
+	Target       Target        `yaml:"target"`
+	// The build arguments with the defaults of args, as the conditions saw
+	// them
+	BuildArgs map[string]string `yaml:"-"`
+	// The patterns of the files of the build context to leave out
+	Exclude []string `yaml:"-"`
 }
 
 // A struct to represent a copy operation in the final image
@@ -77,6 +145,10 @@ type PackCopies struct {
 	SrcPath string
 	// The destination path to copy the file inside the final image
 	DstPath string
+	// What the copy is, e.g. kernel, and the reference of its source, for
+	// the history of the final image
+	Name string
+	From string
 }
 
 type PackInstructions struct {
@@ -90,28 +162,72 @@ type PackInstructions struct {
 	Annots map[string]string
 	// OCI ImageConfig with standard image configuration fields
 	Img ocispecs.Image
+	// The smoke test to run against the final image
+	Test SmokeTest
+	// The vulnerability scan of the rootfs of the final image
+	Scan Scan
+	// The version of the bunnyfile, if any
+	FileVersion string
+	// Store all annotations in urunc.json and not only the ones that urunc
+	// understands, as older versions of bunny did
+	AllAnnotsInUruncJSON bool
+	// The prebuilt kernel to inspect before packing, if any
+	KernelCheck *KernelCheck
+	// Where to get the images from
+	Sources SourceOpts
+	// The kernel and the rootfs of a bunnyfile, to build them on their own
+	Kernel *PackEntry
+	Rootfs *PackEntry
+	// The intermediate artifacts to export alongside the image
+	Artifacts Artifacts
+	// The files of the final image to copy to the paths that the output
+	// flavors expect. The source state is the final image itself.
+	FlavorCopies []PackCopies
+	// Why the base of a bunnyfile was chosen, for the build output
+	BaseDecision *BaseDecision
+	// The commands to run on the final image, after packing
+	Hooks Hooks
+	// Where the kernel, the rootfs and the device tree blob get placed in the
+	// final image
+	Paths Paths
+	// The creation time of the image from the bunnyfile, if any
+	Created *time.Time
+	// The release of urunc that the image targets, empty for the latest
+	UruncVersion string
 }
 
 type PackEntry struct {
 	SourceState llb.State // the state where the files live
 	SourceRef   string    // the reference of the state
 	FilePath    string    // path to the file within the state
+	// The files of an initrd that bunny creates, before packing them
+	InitrdContent *llb.State
+	// The owner of the files in the initrd that bunny creates
+	InitrdOwner Owner
 }
 
-func handleKernel(_ Framework, buildContext string, mon string, k Kernel) (*PackEntry, error) {
+func handleKernel(ctx context.Context, f Framework, in BuildInput, k Kernel) (*PackEntry, error) {
 	entry := &PackEntry{}
 	entry.SourceRef = k.From
-	if k.From == "local" {
-		entry.SourceState = llb.Local(buildContext)
-	} else {
-		entry.SourceState = GetSourceState(k.From, mon)
-	}
 	entry.FilePath = k.Path
+	switch k.From {
+	case "local":
+		entry.SourceState = llb.Local(in.BuildContext, excludeOptions(in.Exclude)...)
+	case KernelFromBuild:
+		var err error
+		entry.SourceState, err = f.BuildKernel(ctx, in)
+		if err != nil {
+			return nil, fmt.Errorf("Could not build kernel: %v", err)
+		}
+		entry.FilePath = BuiltKernelPath
+	default:
+		entry.SourceState = GetSourceState(k.From, in.Monitor, in.Arch)
+	}
 
 	return entry, nil
 }
 
-func handleRootfs(f Framework, buildContext string, mon string, r Rootfs) (*PackEntry, error) {
+func handleRootfs(ctx context.Context, f Framework, in BuildInput, r Rootfs) (*PackEntry, error) {
 	entry := &PackEntry{}
 
 	// Make sure that the specified rootfs type is supported
@@ -120,27 +236,53 @@ func handleRootfs(f Framework, buildContext string, mon string, r Rootfs) (*Pack
 		return nil, fmt.Errorf("Cannot set %s rootfs type for %s",
 			r.Type, f.Name())
 	}
+	if r.ReusesImage() {
+		if r.Type != "" && r.Type != "raw" {
+			return nil, fmt.Errorf("The path field of rootfs is necessary for a %s rootfs from an image", r.Type)
+		}
+		if !f.SupportsRootfsType("raw") {
+			return nil, fmt.Errorf("Cannot reuse the filesystem of an image as a raw rootfs for %s", f.Name())
+		}
+	}
 
 	entry.SourceRef = r.From
 	switch r.From {
 	case "local":
-		entry.SourceState = llb.Local(buildContext)
-		// TODO: Be aware of the case r.Path is empty, which means we have a
-		// raw rootfs that we reuse.
+		if r.Path == "" {
+			return nil, fmt.Errorf("The path field of rootfs is necessary, if from is local")
+		}
+		entry.SourceState = llb.Local(in.BuildContext, excludeOptions(in.Exclude)...)
 		entry.FilePath = r.Path
 	case "scratch", "":
 		// The from field of rootfs is scratch or empty, hence we need to create
 		// a rootfs or just here is no rootfs entry. This depends on the contents
 		// of Includes.
-		if len(r.Includes) != 0 {
+		entry.InitrdOwner = in.Owner
+		if len(r.Initrds) != 0 {
+			if f.GetRootfsType() != "initrd" {
+				return nil, fmt.Errorf("Cannot create initrds for a %s rootfs", f.GetRootfsType())
+			}
+			initrdOpts := append(hardenedOptions(in.Hardened, false), in.Build.NetworkOptions(BuildStepInitrd)...)
+			state, content := InitrdsLLB(r.Initrds, in, initrdOpts...)
+			entry.SourceRef = "scratch"
+			entry.SourceState = state
+			entry.InitrdContent = &content
+			entry.FilePath = DefaultRootfsPath
+		} else if len(r.Includes) != 0 {
 			// If the user has not specified a type, then CreateRootfs
 			// will build the default rootfs type for the specified framework.
 			var err error
 			entry.SourceRef = "scratch"
-			entry.SourceState, err = f.CreateRootfs(buildContext)
+			entry.SourceState, err = f.CreateRootfs(ctx, in)
 			if err != nil {
 				return nil, fmt.Errorf("Could not create rootfs: %v", err)
 			}
+			if f.GetRootfsType() == "initrd" {
+				// Keep the files, in case the initrd gets created in the
+				// frontend instead
+				content := RootfsFilesLLB(r.Includes, in, llb.Scratch())
+				entry.InitrdContent = &content
+			}
 			if f.GetRootfsType() != "raw" {
 				entry.FilePath = DefaultRootfsPath
 			} else {
@@ -155,7 +297,7 @@ func handleRootfs(f Framework, buildContext string, mon string, r Rootfs) (*Pack
 				return nil, fmt.Errorf("Updating a %s rootfs type is not supported yet", r.Type)
 			}
 			var err error
-			entry.SourceState, err = f.UpdateRootfs(buildContext)
+			entry.SourceState, err = f.UpdateRootfs(ctx, in)
 			if err != nil {
 				return nil, fmt.Errorf("Could not update rootfs: %v", err)
 			}
@@ -163,9 +305,9 @@ func handleRootfs(f Framework, buildContext string, mon string, r Rootfs) (*Pack
 			// more rootfs types
 			entry.FilePath = ""
 		} else {
-			entry.SourceState = GetSourceState(r.From, mon)
-			// TODO: Be aware of the case r.Path is empty,
-			// which means we have a raw rootfs from an image.
+			// Without a path, the filesystem of the image is the rootfs
+			// and it becomes the base of the final image, with no copies
+			entry.SourceState = GetSourceState(r.From, in.Monitor, in.Arch)
 			entry.FilePath = r.Path
 		}
 	}
@@ -178,6 +320,7 @@ func makeCopy(entry PackEntry, dst string) PackCopies {
 		SrcState: entry.SourceState,
 		SrcPath:  entry.FilePath,
 		DstPath:  dst,
+		From:     entry.SourceRef,
 	}
 }
 
@@ -202,15 +345,14 @@ func (i *PackInstructions) SetBaseAndGetPaths(kEntry *PackEntry, rEntry *PackEnt
 	switch kEntry.SourceRef {
 	case "":
 		return "", "", fmt.Errorf("Source of kernel State is empty")
-	case "local":
-		i.Copies = append(i.Copies,
-			makeCopy(*kEntry, DefaultKernelPath))
-		i.Base = llb.Scratch()
-		i.BaseRef = ""
+	case "local", KernelFromBuild:
+		i.copyEntry("kernel", *kEntry, i.Paths.KernelPath())
+		i.setBase(llb.Scratch(), "", "scratch",
+			"the kernel does not come from an image")
 		kernelCopy = true
 	default:
-		i.Base = kEntry.SourceState
-		i.BaseRef = kEntry.SourceRef
+		i.setBase(kEntry.SourceState, kEntry.SourceRef, kEntry.SourceRef,
+			"the kernel image already contains the kernel")
 	}
 
 	rootfsCopy := false
@@ -221,35 +363,46 @@ func (i *PackInstructions) SetBaseAndGetPaths(kEntry *PackEntry, rEntry *PackEnt
 		// no-op
 	case "scratch":
 		if rEntry.FilePath != "" {
-			i.Copies = append(i.Copies,
-				makeCopy(*rEntry, DefaultRootfsPath))
+			i.copyEntry("rootfs", *rEntry, i.Paths.RootfsPath())
 			rootfsCopy = true
 		} else {
-			i.Base = rEntry.SourceState
-			i.BaseRef = rEntry.SourceRef
+			i.setBase(rEntry.SourceState, rEntry.SourceRef, "the created rootfs",
+				"a raw rootfs is the filesystem of the image")
 		}
 	case "local":
-		i.Copies = append(i.Copies,
-			makeCopy(*rEntry, DefaultRootfsPath))
+		i.copyEntry("rootfs", *rEntry, i.Paths.RootfsPath())
 		rootfsCopy = true
 	default:
-		i.Base = rEntry.SourceState
-		i.BaseRef = rEntry.SourceRef
+		reason := "the rootfs image already contains the rootfs"
+		if rEntry.FilePath == "" {
+			reason = "a raw rootfs is the filesystem of the image"
+		}
+		i.setBase(rEntry.SourceState, rEntry.SourceRef, rEntry.SourceRef, reason)
 	}
 
 	// There are cases where both kernel and rootfs come from an existing
 	// State (e.g. remote or scratch). In these scenarios, the base changes
 	// to the rootfs state and hence we need to add a new copy for the kernel
 	if !rootfsCopy && !kernelCopy && rEntry.SourceRef != "" {
-		i.Copies = append(i.Copies,
-			makeCopy(*kEntry, DefaultKernelPath))
+		i.copyEntry("kernel", *kEntry, i.Paths.KernelPath())
 		kernelCopy = true
 	}
 
+	// A kernel or a rootfs file that already resides in the base still
+	// moves to the path that the user chose
+	if !kernelCopy && i.Paths.Kernel != "" && kEntry.FilePath != i.Paths.Kernel {
+		i.copyEntry("kernel", *kEntry, i.Paths.Kernel)
+		kernelCopy = true
+	}
+	if !rootfsCopy && i.Paths.Rootfs != "" && rEntry.FilePath != "" && rEntry.FilePath != i.Paths.Rootfs {
+		i.copyEntry("rootfs", *rEntry, i.Paths.Rootfs)
+		rootfsCopy = true
+	}
+
 	if kernelCopy {
 		// We had to copy the kernel and hence the path will
-		// always be DefaultKernelPath
-		kPath = DefaultKernelPath
+		// always be the one of Paths
+		kPath = i.Paths.KernelPath()
 	} else {
 		// We did not have to copy the kernel
 		kPath = kEntry.FilePath
@@ -257,8 +410,8 @@ func (i *PackInstructions) SetBaseAndGetPaths(kEntry *PackEntry, rEntry *PackEnt
 
 	if rootfsCopy {
 		// We had to copy the rootfs and hence the path will
-		// always be DefaultRootfsPath
-		rPath = DefaultRootfsPath
+		// always be the one of Paths
+		rPath = i.Paths.RootfsPath()
 	} else {
 		// We did not have to copy the rootfs
 		rPath = rEntry.FilePath
@@ -267,6 +420,35 @@ func (i *PackInstructions) SetBaseAndGetPaths(kEntry *PackEntry, rEntry *PackEnt
 	return kPath, rPath, nil
 }
 
+// SetUserBaseAndGetPaths sets the base llb.State to the one that the user
+// chose and copies the kernel and the rootfs (if exists) on top of it. It
+// returns the path to the kernel and rootfs files or an error if something
+// went wrong.
+func (i *PackInstructions) SetUserBaseAndGetPaths(bEntry *PackEntry, kEntry *PackEntry, rEntry *PackEntry) (string, string, error) {
+	if kEntry.SourceRef == "" {
+		return "", "", fmt.Errorf("Source of kernel State is empty")
+	}
+	// A raw rootfs is the filesystem of the image itself, so there is no
+	// file to copy on top of the base.
+	if rEntry.SourceRef != "" && rEntry.FilePath == "" {
+		return "", "", fmt.Errorf("A raw rootfs can not be combined with a base image")
+	}
+
+	baseRef := bEntry.SourceRef
+	if baseRef == "scratch" {
+		baseRef = ""
+	}
+	i.setBase(bEntry.SourceState, baseRef, bEntry.SourceRef, "set by the base field")
+
+	i.copyEntry("kernel", *kEntry, i.Paths.KernelPath())
+	if rEntry.SourceRef == "" {
+		return i.Paths.KernelPath(), "", nil
+	}
+	i.copyEntry("rootfs", *rEntry, i.Paths.RootfsPath())
+
+	return i.Paths.KernelPath(), i.Paths.RootfsPath(), nil
+}
+
 // SetAnnotations set all annotations required for urunc.
 // It returns an error if something went wrong
 func (i *PackInstructions) SetAnnotations(p Platform, cmd []string, kernelPath string, rootfsPath string, rootfsType string) error {
@@ -314,60 +496,203 @@ func (i *PackInstructions) UpdateConfig(cmd []string, entryp []string, ev []stri
 }
 
 // ToPack converts Hops into PackInstructions
-func ToPack(h *Hops, buildContext string) (*PackInstructions, error) {
+func ToPack(ctx context.Context, h *Hops, buildContext string) (*PackInstructions, error) {
 	var framework Framework
 	instr := &PackInstructions{
 		Annots: map[string]string{},
 	}
 
+	// The destinations of the files in the rootfs can depend on the
+	// platform
+	rootfs := h.Rootfs
+	rootfs.Includes = expandIncludes(h.Rootfs.Includes, h.Platform.Arch, h.Platform.Monitor)
+	if h.Rootfs.Initrds != nil {
+		rootfs.Initrds = make([]Initrd, len(h.Rootfs.Initrds))
+		for i, initrd := range h.Rootfs.Initrds {
+			initrd.Includes = expandIncludes(initrd.Includes, h.Platform.Arch, h.Platform.Monitor)
+			rootfs.Initrds[i] = initrd
+		}
+	}
+	// The included files of the rootfs belong to its owner, if it has one
+	owner, err := ParseOwner(rootfs.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if rootfs.Owner != "" {
+		applyOwner(rootfs.Includes, owner)
+		for _, initrd := range rootfs.Initrds {
+			applyOwner(initrd.Includes, owner)
+		}
+	}
+
 	// Get the framework and call the respective function to create the
 	// rootfs.
 	switch h.Platform.Framework {
 	case unikraftName:
-		framework = NewUnikraft(h.Platform, h.Rootfs)
+		framework = NewUnikraft(h.Platform, rootfs)
 	default:
-		framework = NewGeneric(h.Platform, h.Rootfs)
+		framework = NewGeneric(h.Platform, rootfs)
 	}
 
-	kernelEntry, err := handleKernel(framework, buildContext, h.Platform.Monitor, h.Kernel)
+	in := BuildInput{
+		BuildContext: buildContext,
+		Monitor:      h.Platform.Monitor,
+		Arch:         h.Platform.Arch,
+		Kernel:       h.Kernel,
+		Network:      h.Network,
+		Certificates: h.Certificates,
+		Hardened:     h.Hardened,
+		Build:        h.Build,
+		Modules:      h.Modules,
+		Hooks:        h.Hooks,
+		Owner:        owner,
+		Exclude:      h.Exclude,
+	}
+	// Without an image, the modules come with the kernel
+	if in.Modules.Enabled() && in.Modules.From == "" {
+		in.Modules.From = h.Kernel.From
+	}
+
+	kernelEntry, err := handleKernel(ctx, framework, in, h.Kernel)
 	if err != nil {
 		return nil, fmt.Errorf("Error handling kernel entry: %v", err)
 	}
 
-	rootfsEntry, err := handleRootfs(framework, buildContext, h.Platform.Monitor, h.Rootfs)
+	// Kernels from unikraft.org are pulled based on the monitor, so make
+	// sure that we got the one the user asked for.
+	if isUnikraftRef(h.Kernel.From) {
+		instr.KernelCheck = &KernelCheck{
+			Ref:     h.Kernel.From,
+			Source:  kernelEntry.SourceState,
+			Path:    kernelEntry.FilePath,
+			Monitor: h.Platform.Monitor,
+			Arch:    h.Platform.Arch,
+		}
+	}
+	// A kernel from the build context can be for any architecture, so
+	// make sure that it matches the declared one.
+	if h.Kernel.From == "local" {
+		instr.KernelCheck = &KernelCheck{
+			Source: llb.Local(buildContext, append(excludeOptions(in.Exclude),
+				llb.IncludePatterns([]string{h.Kernel.Path}),
+				llb.WithCustomName("Internal:Load kernel"))...),
+			Path:    h.Kernel.Path,
+			Monitor: h.Platform.Monitor,
+			Arch:    h.Platform.Arch,
+		}
+	}
+
+	rootfsEntry, err := handleRootfs(ctx, framework, in, rootfs)
 	if err != nil {
 		return nil, fmt.Errorf("Error handling rootfs entry: %v", err)
 	}
 
-	kPath, rPath, err := instr.SetBaseAndGetPaths(kernelEntry, rootfsEntry)
+	instr.Paths = h.Paths
+	var kPath, rPath string
+	if h.Base != "" {
+		baseEntry := &PackEntry{
+			SourceRef:   h.Base,
+			SourceState: GetSourceState(h.Base, h.Platform.Monitor, h.Platform.Arch),
+		}
+		kPath, rPath, err = instr.SetUserBaseAndGetPaths(baseEntry, kernelEntry, rootfsEntry)
+	} else {
+		kPath, rPath, err = instr.SetBaseAndGetPaths(kernelEntry, rootfsEntry)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Error choosing base state: %v", err)
 	}
 
+	// A raw rootfs is the filesystem of the image itself, so there is no
+	// file to place
+	if h.Paths.Rootfs != "" && rootfsEntry.SourceRef != "" && rPath == "" {
+		return nil, fmt.Errorf("The rootfs field of paths can not be set for a raw rootfs")
+	}
+
+	dtbPath := ""
+	if h.Dtb.Enabled() {
+		dtbPath = instr.SetDtbAndGetPath(handleDtb(in, h.Dtb))
+	}
+
 	// Handle the empty rootfs case. In that case, we do not need to set up
 	// any annotations for rootfs and hence the type is set to empty.string
 	rType := ""
 	if rootfsEntry.SourceRef != "" {
 		rType = framework.GetRootfsType()
 	}
-	err = instr.SetAnnotations(h.Platform, h.Cmd, kPath, rPath, rType)
+	if rootfs.ReusesImage() {
+		rType = "raw"
+	}
+	err = ApplyFlavors(instr, h.Flavors, FlavorInput{
+		Platform:   h.Platform,
+		Cmd:        h.Cmd,
+		KernelPath: kPath,
+		RootfsPath: rPath,
+		RootfsType: rType,
+		DtbPath:    dtbPath,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("Error setting annotations: %v", err)
 	}
+	err = instr.setKernelVariants(ctx, framework, in, h.Kernel, kPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(rootfs.Initrds) != 0 {
+		instr.Annots[InitrdsAnnotation] = initrdNames(rootfs.Initrds)
+	}
+	for k, v := range h.Metadata.Annotations() {
+		instr.Annots[k] = v
+	}
+	for k, v := range h.Shutdown.Annotations() {
+		instr.Annots[k] = v
+	}
+	if h.Variant != "" {
+		instr.Annots[VariantAnnotation] = h.Variant
+	}
 
 	instr.UpdateConfig(h.Cmd, h.Entrypoint, h.Envs)
+	instr.Img.Config.StopSignal = h.Shutdown.Signal
+	instr.UruncVersion = h.Target.UruncVersion
+	instr.Img.Config.User = h.User
+	if h.Workdir != "" {
+		instr.Img.Config.WorkingDir = path.Clean(h.Workdir)
+	}
+	instr.Img.Author = h.Metadata.ConfigAuthor()
+	instr.Created, err = h.Metadata.CreatedTime()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid created field of metadata: %v", err)
+	}
+	instr.Test = h.Test
+	instr.Scan = h.Scan
+	instr.FileVersion = h.Version
+	instr.Sources.Mirrors = h.Mirrors
+	instr.Sources.Network = h.Network
+	instr.Sources.Hardened = h.Hardened
+	instr.Sources.Build = h.Build
+	instr.Sources.BuildArgs = h.BuildArgs
+	instr.Sources.Exclude = h.Exclude
+	instr.Kernel = kernelEntry
+	instr.Rootfs = rootfsEntry
+	instr.Artifacts = h.Artifacts
+	instr.Hooks = h.Hooks
+	instr.ExpandPlaceholders(h.Platform.Arch, h.Platform.Monitor)
+
+	err = instr.NormalizeCopies()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid copies in the final image: %v", err)
+	}
 
 	return instr, nil
 }
 
-// PackLLB gets a PackInstructions struct and transforms it to an LLB definition
-func PackLLB(instr PackInstructions) (*llb.Definition, error) {
-	var base llb.State
+// UruncJSON returns the content of the urunc.json file of the final image,
+// with the base64-encoded values of the annotations.
+func UruncJSON(instr PackInstructions) ([]byte, error) {
 	uruncJSON := make(map[string]string)
-	base = instr.Base
-
-	// Create urunc.json file, since annotations do not reach urunc
 	for annot, val := range instr.Annots {
+		if !instr.AllAnnotsInUruncJSON && !IsUruncAnnotation(annot) && !isLegacyUruncAnnotation(annot) {
+			continue
+		}
 		encoded := base64.StdEncoding.EncodeToString([]byte(val))
 		uruncJSON[annot] = string(encoded)
 	}
@@ -376,25 +701,71 @@ func PackLLB(instr PackInstructions) (*llb.Definition, error) {
 		return nil, fmt.Errorf("Failed to marshal urunc json: %v", err)
 	}
 
+	return uruncJSONBytes, nil
+}
+
+// PackLLB gets a PackInstructions struct and transforms it to an LLB definition
+func PackLLB(ctx context.Context, instr PackInstructions) (*llb.Definition, error) {
+	var base llb.State
+	base = instr.Base
+
+	// Create urunc.json file, since annotations do not reach urunc
+	uruncJSONBytes, err := UruncJSON(instr)
+	if err != nil {
+		return nil, err
+	}
+
 	// Perform any copies inside the image
 	for _, aCopy := range instr.Copies {
 		base = CopyLLB(base, aCopy)
 	}
+	for _, aCopy := range instr.FlavorCopies {
+		aCopy.SrcState = base
+		base = CopyLLB(base, aCopy)
+	}
+	if len(instr.Hooks.PostPack) > 0 {
+		base = HooksLLB(base, instr.Hooks.Image, instr.Hooks.PostPack, "postPack",
+			hooksOptions(instr.Sources.Hardened, instr.Sources.Build)...)
+	}
 
 	// Create the urunc.json file in the rootfs
 	base = base.File(llb.Mkfile(uruncJSONPath, 0644, uruncJSONBytes))
 
-	var dt *llb.Definition
-	switch runtime.GOARCH {
-	case "amd64":
-		dt, err = base.Marshal(context.TODO(), llb.LinuxAmd64)
-	case "arm":
-		dt, err = base.Marshal(context.TODO(), llb.LinuxArm)
-	case "arm64":
-		dt, err = base.Marshal(context.TODO(), llb.LinuxArm64)
-	default:
-		return nil, fmt.Errorf("Unsupported architecture: %s", runtime.GOARCH)
+	return marshalState(ctx, base, instr.Sources)
+}
+
+// marshalState marshals the given state for the architecture of the worker and
+// rewrites the image sources based on the given options. In hardened mode, or
+// when the digests of the tools get recorded, the tool images also get pinned,
+// if there is a resolver.
+func marshalState(ctx context.Context, st llb.State, opts SourceOpts) (*llb.Definition, error) {
+	dt, err := marshalPlatform(ctx, st, opts.Arch)
+	if err != nil {
+		return nil, err
+	}
+	dt, err = ApplyLayouts(ctx, dt, opts.Layouts, opts.Arch)
+	if err != nil {
+		return nil, err
+	}
+	dt, err = ApplyMirrors(dt, opts.Mirrors)
+	if err != nil {
+		return nil, err
+	}
+	if (!opts.Hardened && opts.Tools == nil) || opts.Resolver == nil {
+		return dt, nil
+	}
+
+	return PinToolImages(ctx, dt, opts.Resolver, opts.Arch, opts.Tools)
+}
+
+// marshalPlatform marshals the given state for a linux worker of the given
+// architecture, which is the host's if empty
+func marshalPlatform(ctx context.Context, st llb.State, arch string) (*llb.Definition, error) {
+	platform, err := BuildPlatform(arch)
+	if err != nil {
+		return nil, err
 	}
+	dt, err := st.Marshal(ctx, llb.Platform(platform))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to marshal LLB state: %v", err)
 	}
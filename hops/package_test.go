@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"runtime"
 	"strings"
 	"testing"
@@ -59,7 +60,7 @@ func TestPackHandleKernel(t *testing.T) {
 		}
 		f := NewGeneric(p, r)
 
-		e, err := handleKernel(f, "context", "mon", k)
+		e, err := handleKernel(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, k)
 		require.NoError(t, err)
 		require.NotNil(t, e)
 		require.Equal(t, k.From, e.SourceRef)
@@ -84,7 +85,7 @@ func TestPackHandleKernel(t *testing.T) {
 		}
 		f := NewGeneric(p, r)
 
-		e, err := handleKernel(f, "context", "mon", k)
+		e, err := handleKernel(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, k)
 		require.NoError(t, err)
 		require.NotNil(t, e)
 		require.Equal(t, k.From, e.SourceRef)
@@ -107,7 +108,7 @@ func TestPackHandleRootfs(t *testing.T) {
 		r := Rootfs{}
 		f := NewGeneric(p, r)
 
-		e, err := handleRootfs(f, "context", "mon", r)
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
 		require.NoError(t, err)
 		require.NotNil(t, e)
 		require.Empty(t, e.SourceRef)
@@ -128,7 +129,7 @@ func TestPackHandleRootfs(t *testing.T) {
 		}
 		f := NewGeneric(p, r)
 
-		e, err := handleRootfs(f, "context", "mon", r)
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
 		require.NoError(t, err)
 		require.NotNil(t, e)
 		require.Equal(t, r.From, e.SourceRef)
@@ -157,7 +158,7 @@ func TestPackHandleRootfs(t *testing.T) {
 		}
 		f := NewGeneric(p, r)
 
-		e, err := handleRootfs(f, "context", "mon", r)
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
 		require.NoError(t, err)
 		require.NotNil(t, e)
 		require.Equal(t, r.From, e.SourceRef)
@@ -185,7 +186,7 @@ func TestPackHandleRootfs(t *testing.T) {
 		}
 		f := NewUnikraft(p, r)
 
-		e, err := handleRootfs(f, "context", "mon", r)
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
 		require.NoError(t, err)
 		require.NotNil(t, e)
 		require.Equal(t, r.From, e.SourceRef)
@@ -217,7 +218,7 @@ func TestPackHandleRootfs(t *testing.T) {
 		}
 		f := NewGeneric(p, r)
 
-		e, err := handleRootfs(f, "context", "mon", r)
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
 		require.NoError(t, err)
 		require.NotNil(t, e)
 		require.Equal(t, "scratch", e.SourceRef)
@@ -255,10 +256,62 @@ func TestPackHandleRootfs(t *testing.T) {
 		}
 		f := NewGeneric(p, r)
 
-		e, err := handleRootfs(f, "context", "mon", r)
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
 		require.Nil(t, e)
 		require.ErrorContains(t, err, "Cannot set foo")
 	})
+	t.Run("Registry without path reuses the image", func(t *testing.T) {
+		p := Platform{
+			Framework: "unikraft",
+			Monitor:   "qemu",
+		}
+		r := Rootfs{
+			From: "harbor.nbfc.io/foo",
+		}
+		f := NewUnikraft(p, r)
+
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
+		require.NoError(t, err)
+		require.Equal(t, r.From, e.SourceRef)
+		require.Empty(t, e.FilePath)
+		require.Nil(t, e.InitrdContent)
+		def, err := e.SourceState.Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		require.Equal(t, 2, len(arr))
+		s := arr[0].Op.(*pb.Op_Source).Source
+		require.Equal(t, "docker-image://harbor.nbfc.io/foo:latest", s.Identifier)
+	})
+	t.Run("Invalid registry without path type initrd", func(t *testing.T) {
+		p := Platform{
+			Framework: "linux",
+			Monitor:   "qemu",
+		}
+		r := Rootfs{
+			From: "harbor.nbfc.io/foo",
+			Type: "initrd",
+		}
+		f := NewGeneric(p, r)
+
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
+		require.Nil(t, e)
+		require.ErrorContains(t, err, "The path field of rootfs is necessary for a initrd rootfs from an image")
+	})
+	t.Run("Invalid local without path", func(t *testing.T) {
+		p := Platform{
+			Framework: "linux",
+			Monitor:   "qemu",
+		}
+		r := Rootfs{
+			From: "local",
+			Type: "initrd",
+		}
+		f := NewGeneric(p, r)
+
+		e, err := handleRootfs(context.TODO(), f, BuildInput{BuildContext: "context", Monitor: "mon"}, r)
+		require.Nil(t, e)
+		require.ErrorContains(t, err, "The path field of rootfs is necessary, if from is local")
+	})
 }
 
 func TestPackSetAnnotations(t *testing.T) {
@@ -660,6 +713,98 @@ func TestPackSetBaseAndGetPaths(t *testing.T) {
 	})
 }
 
+func TestPackSetUserBaseAndGetPaths(t *testing.T) {
+	t.Run("Kernel registry Rootfs local", func(t *testing.T) {
+		b := &PackEntry{
+			SourceRef:   "harbor.nbfc.io/base",
+			SourceState: llb.Image("harbor.nbfc.io/base"),
+		}
+		k := &PackEntry{
+			SourceRef:   "harbor.nbfc.io/foo",
+			SourceState: llb.Image("harbor.nbfc.io/foo"),
+			FilePath:    "/kernel",
+		}
+		r := &PackEntry{
+			SourceRef:   "local",
+			SourceState: llb.Local("context"),
+			FilePath:    "rootfs",
+		}
+		i := &PackInstructions{}
+
+		kp, rp, err := i.SetUserBaseAndGetPaths(b, k, r)
+		require.NoError(t, err)
+		require.Equal(t, DefaultKernelPath, kp)
+		require.Equal(t, DefaultRootfsPath, rp)
+		require.Equal(t, b.SourceRef, i.BaseRef)
+		def, err := i.Base.Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		require.Equal(t, 2, len(arr))
+		s := arr[0].Op.(*pb.Op_Source).Source
+		require.Equal(t, "docker-image://harbor.nbfc.io/base:latest", s.Identifier)
+		require.Equal(t, 2, len(i.Copies))
+		require.Equal(t, k.FilePath, i.Copies[0].SrcPath)
+		require.Equal(t, DefaultKernelPath, i.Copies[0].DstPath)
+		require.Equal(t, r.FilePath, i.Copies[1].SrcPath)
+		require.Equal(t, DefaultRootfsPath, i.Copies[1].DstPath)
+	})
+	t.Run("Kernel registry Rootfs empty base scratch", func(t *testing.T) {
+		b := &PackEntry{
+			SourceRef:   "scratch",
+			SourceState: llb.Scratch(),
+		}
+		k := &PackEntry{
+			SourceRef:   "harbor.nbfc.io/foo",
+			SourceState: llb.Image("harbor.nbfc.io/foo"),
+			FilePath:    "/kernel",
+		}
+		r := &PackEntry{}
+		i := &PackInstructions{}
+
+		kp, rp, err := i.SetUserBaseAndGetPaths(b, k, r)
+		require.NoError(t, err)
+		require.Equal(t, DefaultKernelPath, kp)
+		require.Empty(t, rp)
+		require.Empty(t, i.BaseRef)
+		def, err := i.Base.Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		require.Equal(t, 0, len(arr))
+		require.Equal(t, 1, len(i.Copies))
+		require.Equal(t, DefaultKernelPath, i.Copies[0].DstPath)
+	})
+	t.Run("Invalid Rootfs raw", func(t *testing.T) {
+		b := &PackEntry{
+			SourceRef:   "harbor.nbfc.io/base",
+			SourceState: llb.Image("harbor.nbfc.io/base"),
+		}
+		k := &PackEntry{
+			SourceRef:   "local",
+			SourceState: llb.Local("context"),
+			FilePath:    "kernel",
+		}
+		r := &PackEntry{
+			SourceRef:   "harbor.nbfc.io/rootfs",
+			SourceState: llb.Image("harbor.nbfc.io/rootfs"),
+		}
+		i := &PackInstructions{}
+
+		_, _, err := i.SetUserBaseAndGetPaths(b, k, r)
+		require.ErrorContains(t, err, "A raw rootfs can not be combined with a base image")
+		require.Equal(t, 0, len(i.Copies))
+	})
+	t.Run("Invalid Kernel empty", func(t *testing.T) {
+		b := &PackEntry{
+			SourceRef:   "scratch",
+			SourceState: llb.Scratch(),
+		}
+		i := &PackInstructions{}
+
+		_, _, err := i.SetUserBaseAndGetPaths(b, &PackEntry{}, &PackEntry{})
+		require.ErrorContains(t, err, "Source of kernel State is empty")
+	})
+}
+
 func TestPackToPack(t *testing.T) {
 	t.Run("Kernel local Rootfs none", func(t *testing.T) {
 		hops := &Hops{
@@ -673,7 +818,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -713,7 +858,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "foo")
+		i, err := ToPack(context.TODO(), hops, "foo")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -734,6 +879,31 @@ func TestPackToPack(t *testing.T) {
 		s := arr[0].Op.(*pb.Op_Source).Source
 		require.Equal(t, "docker-image://harbor.nbfc.io/foo:latest", s.Identifier)
 	})
+	t.Run("Kernel registry Rootfs none base", func(t *testing.T) {
+		hops := &Hops{
+			Platform: Platform{
+				Framework: "unikraft",
+				Monitor:   "qemu",
+			},
+			Kernel: Kernel{
+				From: "harbor.nbfc.io/foo",
+				Path: "/kernel",
+			},
+			Base: "harbor.nbfc.io/base",
+			Cmd:  []string{"cmd"},
+		}
+		i, err := ToPack(context.TODO(), hops, "context")
+		require.NoError(t, err)
+		require.Equal(t, DefaultKernelPath, i.Annots["com.urunc.unikernel.binary"])
+		require.Equal(t, hops.Base, i.BaseRef)
+		require.Equal(t, 1, len(i.Copies))
+		require.Equal(t, hops.Kernel.Path, i.Copies[0].SrcPath)
+		def, err := i.Base.Marshal(context.TODO())
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		s := arr[0].Op.(*pb.Op_Source).Source
+		require.Equal(t, "docker-image://harbor.nbfc.io/base:latest", s.Identifier)
+	})
 	t.Run("Kernel local Rootfs local type none implies initrd", func(t *testing.T) {
 		hops := &Hops{
 			Platform: Platform{
@@ -750,7 +920,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -804,7 +974,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -858,7 +1028,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -903,7 +1073,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "true", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -953,7 +1123,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -1015,7 +1185,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "true", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -1063,7 +1233,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -1110,7 +1280,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -1155,7 +1325,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "true", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -1205,7 +1375,7 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.NoError(t, err)
 		require.NotNil(t, i)
 		require.Equal(t, "false", i.Annots["com.urunc.unikernel.mountRootfs"])
@@ -1256,10 +1426,54 @@ func TestPackToPack(t *testing.T) {
 			},
 			Cmd: []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.ErrorContains(t, err, "Error handling rootfs entry")
 		require.Nil(t, i)
 	})
+	t.Run("Kernel local Rootfs remote unikraft type none reuses raw", func(t *testing.T) {
+		hops := &Hops{
+			Platform: Platform{
+				Framework: "unikraft",
+				Monitor:   "qemu",
+			},
+			Kernel: Kernel{
+				From: "local",
+				Path: "kernel",
+			},
+			Rootfs: Rootfs{
+				From: "harbor.nbfc.io/foo",
+			},
+			Cmd: []string{"cmd"},
+		}
+		i, err := ToPack(context.TODO(), hops, "context")
+		require.NoError(t, err)
+		require.Equal(t, "true", i.Annots["com.urunc.unikernel.mountRootfs"])
+		require.NotContains(t, i.Annots, "com.urunc.unikernel.initrd")
+		require.Equal(t, "harbor.nbfc.io/foo", i.BaseRef)
+		// Only the kernel gets copied on top of the image
+		require.Equal(t, 1, len(i.Copies))
+		require.Equal(t, DefaultKernelPath, i.Copies[0].DstPath)
+	})
+	t.Run("Invalid Rootfs remote reused with base", func(t *testing.T) {
+		hops := &Hops{
+			Platform: Platform{
+				Framework: "unikraft",
+				Monitor:   "qemu",
+			},
+			Kernel: Kernel{
+				From: "local",
+				Path: "kernel",
+			},
+			Rootfs: Rootfs{
+				From: "harbor.nbfc.io/foo",
+			},
+			Base: "scratch",
+			Cmd:  []string{"cmd"},
+		}
+		i, err := ToPack(context.TODO(), hops, "context")
+		require.ErrorContains(t, err, "A raw rootfs can not be combined with a base image")
+		require.Nil(t, i)
+	})
 	// TODO: Resume below test when a new framework that does not support
 	// raw rootfs is introduced (e.g. Mewz, Rumprun)
 	// t.Run("Invalid Rootfs from registry implies unsupported raw rootfs type", func(t *testing.T) {
@@ -1277,7 +1491,7 @@ func TestPackToPack(t *testing.T) {
 	//		},
 	//		Cmd: []string{"cmd"},
 	//	}
-	//	i, err := ToPack(hops, "context")
+	//	i, err := ToPack(context.TODO(), hops, "context")
 	//	require.ErrorContains(t, err, "unikraft does not support raw rootfs")
 	//	require.Nil(t, i)
 	// })
@@ -1291,7 +1505,7 @@ func TestPackToPack(t *testing.T) {
 			Rootfs: Rootfs{},
 			Cmd:    []string{"cmd"},
 		}
-		i, err := ToPack(hops, "context")
+		i, err := ToPack(context.TODO(), hops, "context")
 		require.ErrorContains(t, err, "Error choosing base state")
 		require.Nil(t, i)
 	})
@@ -1300,11 +1514,11 @@ func TestPackToPack(t *testing.T) {
 func TestPackLLB(t *testing.T) {
 	t.Run("Base scratch annots no copies", func(t *testing.T) {
 		annotations := map[string]string{
-			"foo":           "bar",
-			"unikernelType": "unikraft",
-			"cmdline":       "test-cmd",
-			"hypervisor":    "qemu",
-			"binary":        "/boot/kernel",
+			"foo":                               "bar",
+			"com.urunc.unikernel.unikernelType": "unikraft",
+			"com.urunc.unikernel.cmdline":       "test-cmd",
+			"com.urunc.unikernel.hypervisor":    "qemu",
+			"com.urunc.unikernel.binary":        "/boot/kernel",
 		}
 
 		instr := PackInstructions{
@@ -1313,7 +1527,7 @@ func TestPackLLB(t *testing.T) {
 			Annots: annotations,
 		}
 
-		result, err := PackLLB(instr)
+		result, err := PackLLB(context.TODO(), instr)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		m, arr := parseDef(t, result.Def)
@@ -1336,6 +1550,41 @@ func TestPackLLB(t *testing.T) {
 		var annotJSON map[string]string
 		err = json.Unmarshal(mkfile.Data, &annotJSON)
 		require.NoError(t, err)
+		require.Equal(t, len(instr.Annots)-1, len(annotJSON))
+		require.NotContains(t, annotJSON, "foo")
+		for an, val := range instr.Annots {
+			if an == "foo" {
+				continue
+			}
+			encoded := base64.StdEncoding.EncodeToString([]byte(val))
+			require.Equal(t, string(encoded), annotJSON[an])
+		}
+	})
+	t.Run("Base scratch annots all in urunc.json", func(t *testing.T) {
+		annotations := map[string]string{
+			"foo":                        "bar",
+			"com.urunc.unikernel.binary": "/boot/kernel",
+		}
+
+		instr := PackInstructions{
+			Base:                 llb.Scratch(),
+			Copies:               []PackCopies{},
+			Annots:               annotations,
+			AllAnnotsInUruncJSON: true,
+		}
+
+		result, err := PackLLB(context.TODO(), instr)
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		_, arr := parseDef(t, result.Def)
+		require.Equal(t, 2, len(arr))
+		ujs := arr[0].Op.(*pb.Op_File).File
+		mkfile := ujs.Actions[0].Action.(*pb.FileAction_Mkfile).Mkfile
+		require.Equal(t, "/urunc.json", mkfile.Path)
+		var annotJSON map[string]string
+		err = json.Unmarshal(mkfile.Data, &annotJSON)
+		require.NoError(t, err)
+		require.Equal(t, len(instr.Annots), len(annotJSON))
 		for an, val := range instr.Annots {
 			encoded := base64.StdEncoding.EncodeToString([]byte(val))
 			require.Equal(t, string(encoded), annotJSON[an])
@@ -1350,7 +1599,7 @@ func TestPackLLB(t *testing.T) {
 			Annots: annotations,
 		}
 
-		result, err := PackLLB(instr)
+		result, err := PackLLB(context.TODO(), instr)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		m, arr := parseDef(t, result.Def)
@@ -1377,11 +1626,11 @@ func TestPackLLB(t *testing.T) {
 	})
 	t.Run("Base scratch annots with copies", func(t *testing.T) {
 		annotations := map[string]string{
-			"foo":           "bar",
-			"unikernelType": "unikraft",
-			"cmdline":       "test-cmd",
-			"hypervisor":    "qemu",
-			"binary":        "/boot/kernel",
+			"foo":                               "bar",
+			"com.urunc.unikernel.unikernelType": "unikraft",
+			"com.urunc.unikernel.cmdline":       "test-cmd",
+			"com.urunc.unikernel.hypervisor":    "qemu",
+			"com.urunc.unikernel.binary":        "/boot/kernel",
 		}
 
 		copies := []PackCopies{
@@ -1402,7 +1651,7 @@ func TestPackLLB(t *testing.T) {
 			Annots: annotations,
 		}
 
-		result, err := PackLLB(instr)
+		result, err := PackLLB(context.TODO(), instr)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		m, arr := parseDef(t, result.Def)
@@ -1424,7 +1673,12 @@ func TestPackLLB(t *testing.T) {
 		var annotJSON map[string]string
 		err = json.Unmarshal(mkfile.Data, &annotJSON)
 		require.NoError(t, err)
+		require.Equal(t, len(instr.Annots)-1, len(annotJSON))
+		require.NotContains(t, annotJSON, "foo")
 		for an, val := range instr.Annots {
+			if an == "foo" {
+				continue
+			}
 			encoded := base64.StdEncoding.EncodeToString([]byte(val))
 			require.Equal(t, string(encoded), annotJSON[an])
 		}
@@ -1465,7 +1719,7 @@ func TestPackLLB(t *testing.T) {
 			Annots: annotations,
 		}
 
-		result, err := PackLLB(instr)
+		result, err := PackLLB(context.TODO(), instr)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		m, arr := parseDef(t, result.Def)
@@ -1504,7 +1758,7 @@ func TestPackLLB(t *testing.T) {
 			Annots: annotations,
 		}
 
-		result, err := PackLLB(instr)
+		result, err := PackLLB(context.TODO(), instr)
 		switch runtime.GOARCH {
 		case "amd64", "arm", "arm64":
 			require.NoError(t, err)
@@ -1525,7 +1779,7 @@ func TestPackLLB(t *testing.T) {
 			Annots: annotations,
 		}
 
-		result, err := PackLLB(instr)
+		result, err := PackLLB(context.TODO(), instr)
 		require.Error(t, err)
 		require.Nil(t, result)
 		require.ErrorContains(t, err, "Failed to marshal")
@@ -1551,9 +1805,63 @@ func TestPackLLB(t *testing.T) {
 			Annots: annotations,
 		}
 
-		result, err := PackLLB(instr)
+		result, err := PackLLB(context.TODO(), instr)
 		require.Error(t, err)
 		require.Nil(t, result)
 		require.ErrorContains(t, err, "Failed to marshal")
 	})
 }
+
+// benchHops returns a bunnyfile with an initrd of the given files
+func benchHops(files []FileToInclude) *Hops {
+	return &Hops{
+		Platform: Platform{
+			Framework: "linux",
+			Monitor:   "qemu",
+			Arch:      "amd64",
+		},
+		Kernel: Kernel{
+			From: "harbor.nbfc.io/foo",
+			Path: "kernel",
+		},
+		Rootfs: Rootfs{
+			From:     "scratch",
+			Type:     "initrd",
+			Includes: files,
+		},
+		Cmd: []string{"cmd"},
+	}
+}
+
+func BenchmarkToPack(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000} {
+		files := benchIncludes(n)
+		b.Run(fmt.Sprintf("%d files", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_, err := ToPack(context.TODO(), benchHops(files), "context")
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPackLLB(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000} {
+		instr, err := ToPack(context.TODO(), benchHops(benchIncludes(n)), "context")
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("%d files", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_, err := PackLLB(context.TODO(), *instr)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -15,15 +15,23 @@
 package hops
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"strings"
+	"unicode"
 
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/frontend/dockerfile/dockerfile2llb"
+	"github.com/moby/buildkit/frontend/dockerui"
 	"github.com/moby/buildkit/frontend/gateway/client"
 	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"gopkg.in/yaml.v3"
 )
 
@@ -37,12 +45,16 @@ const (
 var (
 	errInvalidFileFormat = errors.New("invalid format of input file")
 	errInvalidBunnyfile  = errors.New("invalid bunnyfile format")
+	// The dockerfile frontend could not convert the file
+	errInvalidContainerfile = errors.New("error while parsing as containerfile")
 )
 
 func (f *FileToInclude) UnmarshalYAML(node *yaml.Node) error {
 	switch node.Kind {
 	case yaml.ScalarNode:
-		parts := strings.SplitN(node.Value, ":", 2)
+		// Any exclude patterns follow the destination, each one after :!
+		mapping, excludes, _ := strings.Cut(node.Value, ":!")
+		parts := strings.SplitN(mapping, ":", 2)
 		if len(parts[0]) == 0 {
 			return fmt.Errorf("invalid file mapping %q, src is empty", node.Value)
 		}
@@ -53,16 +65,38 @@ func (f *FileToInclude) UnmarshalYAML(node *yaml.Node) error {
 		if len(parts) == 2 && len(parts[1]) != 0 {
 			f.Dst = parts[1]
 		}
+		if strings.Contains(node.Value, ":!") {
+			f.Exclude = strings.Split(excludes, ":!")
+		}
 
 		return nil
 	case yaml.MappingNode:
+		// The short src and dst keys are aliases of source and
+		// destination, since paths with colons or spaces only fit in
+		// this form
 		type auxInclude FileToInclude
-		var tmp auxInclude
+		var tmp struct {
+			auxInclude `yaml:",inline"`
+			ShortSrc   string `yaml:"src"`
+			ShortDst   string `yaml:"dst"`
+		}
 
 		err := node.Decode(&tmp)
 		if err != nil {
 			return err
 		}
+		if len(tmp.ShortSrc) != 0 {
+			if len(tmp.Src) != 0 {
+				return fmt.Errorf("invalid file mapping at line %d, column %d: both source and src are set", node.Line, node.Column)
+			}
+			tmp.Src = tmp.ShortSrc
+		}
+		if len(tmp.ShortDst) != 0 {
+			if len(tmp.Dst) != 0 {
+				return fmt.Errorf("invalid file mapping at line %d, column %d: both destination and dst are set", node.Line, node.Column)
+			}
+			tmp.Dst = tmp.ShortDst
+		}
 		if len(tmp.Src) == 0 {
 			return fmt.Errorf("invalid file mapping at line %d, column %d: source is empty (from=%q, destination=%q)", node.Line, node.Column, tmp.From, tmp.Dst)
 		}
@@ -76,35 +110,235 @@ func (f *FileToInclude) UnmarshalYAML(node *yaml.Node) error {
 		}
 		f.Src = tmp.Src
 		f.Dst = tmp.Dst
+		f.Optional = tmp.Optional
+		f.Exclude = tmp.Exclude
+		f.Mode = tmp.Mode
+		f.Owner = tmp.Owner
 		return nil
 	default:
 		return fmt.Errorf("invalid Include file format")
 	}
 }
 
-// ParseBunnyfile reads a yaml file which contains instructions for
-// bunny.
+// unmarshalBunnyfile decodes a bunnyfile in YAML or, if its first non-space
+// byte is {, in JSON. A JSON bunnyfile gets converted to YAML, so both
+// formats share the names of the fields and the rest of the parsing. The
+// conditions of the entries of lists get evaluated with the given build
+// arguments. It returns the lines of the fields of a YAML bunnyfile, for the
+// errors of the validation.
+func unmarshalBunnyfile(fileBytes []byte, args map[string]string, h *Hops) (positions, error) {
+	fileBytes = NormalizeFile(fileBytes)
+	yamlBytes, err := bunnyfileYAML(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	err = yaml.Unmarshal(yamlBytes, &doc)
+	if err != nil {
+		return nil, err
+	}
+	decls, err := decodeBuildArgs(&doc)
+	if err != nil {
+		return nil, err
+	}
+	err = ValidateArgs(decls)
+	if err != nil {
+		return nil, err
+	}
+	if len(decls) > 0 {
+		err = checkDeclaredArgs(&doc, decls)
+		if err != nil {
+			return nil, err
+		}
+	}
+	args, err = ResolveBuildArgs(decls, args)
+	if err != nil {
+		return nil, err
+	}
+	err = applyConditions(&doc, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		// An empty file decodes to the zero value, as with yaml.Unmarshal
+		return nil, nil
+	}
+	h.BuildArgs = args
+
+	var pos positions
+	// The lines of a JSON bunnyfile are the ones of its YAML conversion,
+	// so they do not point anywhere useful
+	if !isJSONBunnyfile(fileBytes) {
+		pos = nodePositions(&doc)
+	}
+
+	return pos, doc.Decode(h)
+}
+
+// utf8BOM is the byte order mark that some editors, mostly on Windows, add at
+// the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// NormalizeFile removes the UTF-8 byte order mark of the file, if any, and
+// converts its CRLF line endings to LF, so files edited on Windows get parsed
+// as any other file, e.g. the detection of JSON and of the syntax directive.
+func NormalizeFile(fileBytes []byte) []byte {
+	fileBytes = bytes.TrimPrefix(fileBytes, utf8BOM)
+	if !bytes.Contains(fileBytes, []byte("\r\n")) {
+		return fileBytes
+	}
+
+	return bytes.ReplaceAll(fileBytes, []byte("\r\n"), []byte("\n"))
+}
+
+// isJSONBunnyfile returns true, if the first non-space byte of the bunnyfile
+// is {
+func isJSONBunnyfile(fileBytes []byte) bool {
+	trimmed := bytes.TrimLeftFunc(fileBytes, unicode.IsSpace)
+
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// bunnyfileYAML returns the bunnyfile in YAML, converting it from JSON if its
+// first non-space byte is {
+func bunnyfileYAML(fileBytes []byte) ([]byte, error) {
+	if !isJSONBunnyfile(fileBytes) {
+		return fileBytes, nil
+	}
+
+	var content map[string]any
+	dec := json.NewDecoder(bytes.NewReader(fileBytes))
+	err := dec.Decode(&content)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid JSON bunnyfile: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("Invalid JSON bunnyfile: unexpected content after the top-level object")
+	}
+	yamlBytes, err := yaml.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to convert JSON bunnyfile: %v", err)
+	}
+
+	return yamlBytes, nil
+}
+
+// DecodeInlineBunnyfile decodes a bunnyfile that was passed in base64 instead
+// of a file in the build context (e.g. as a frontend option). Both the
+// standard and the URL-safe alphabet are accepted, with or without padding,
+// and whitespace (e.g. the line wraps of base64) is ignored.
+func DecodeInlineBunnyfile(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.Join(strings.Fields(value), ""), "=")
+	if value == "" {
+		return nil, fmt.Errorf("The inline bunnyfile is empty")
+	}
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(value, "-_") {
+		encoding = base64.RawURLEncoding
+	}
+	fileBytes, err := encoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode the inline bunnyfile: %v", err)
+	}
+
+	return fileBytes, nil
+}
+
+// ParseBunnyfile reads a yaml (or json) file which contains instructions for
+// bunny. The conditions of the file see no build arguments.
 func ParseBunnyfile(fileBytes []byte) (*Hops, error) {
+	return ParseBunnyfileArgs(fileBytes, nil)
+}
+
+// ParseBunnyfileArgs reads a bunnyfile as ParseBunnyfile does, evaluating the
+// conditions of the entries of its lists with the given build arguments.
+func ParseBunnyfileArgs(fileBytes []byte, args map[string]string) (*Hops, error) {
+	fileBytes = NormalizeFile(fileBytes)
 	bunnyHops := &Hops{}
 
-	err := yaml.Unmarshal(fileBytes, bunnyHops)
+	pos, err := unmarshalBunnyfile(fileBytes, args, bunnyHops)
 	if err != nil {
-		return nil, errors.Join(errInvalidFileFormat, err)
+		return nil, withSyntaxWarning(fileBytes, errors.Join(errInvalidFileFormat, err))
 	}
 
-	err = CheckBunnyfileVersion(bunnyHops.Version)
+	err = ValidateHops(bunnyHops)
 	if err != nil {
-		return nil, errors.Join(errInvalidBunnyfile, err)
+		pos.annotate(err)
+		return nil, withSyntaxWarning(fileBytes, err)
+	}
+
+	return bunnyHops, nil
+}
+
+// withSyntaxWarning adds the warning about the syntax directive of the file,
+// if any, to the error, since a frontend that does not match the version of
+// the bunnyfile is a common cause of errors.
+func withSyntaxWarning(fileBytes []byte, err error) error {
+	warning := SyntaxWarning(fileBytes)
+	if warning == "" {
+		return err
+	}
+
+	return errors.Join(err, errors.New(warning))
+}
+
+// ValidateHops checks the instructions of a bunnyfile and fills in the
+// values that depend on other fields (e.g. the normalized monitor, or the
+// kernel and rootfs of a binary), as ParseBunnyfile does.
+func ValidateHops(bunnyHops *Hops) error {
+	err := CheckBunnyfileVersion(bunnyHops.Version)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
 	}
 
 	err = ValidatePlatform(bunnyHops.Platform)
 	if err != nil {
-		return nil, errors.Join(errInvalidBunnyfile, err)
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+	bunnyHops.Platform.Monitor = NormalizeMonitor(bunnyHops.Platform.Monitor)
+
+	// A list of kernels has one kernel per monitor and the one of platforms
+	// is the kernel that bunny packs as usual.
+	err = SelectKernel(bunnyHops)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	// TODO: Remove this in next release.
+	// Keep backwards compatibility and if cmd is empty, then
+	// use cmdline. Otherwise, the Cmdline is ignored.
+	if len(bunnyHops.Cmd) == 0 && bunnyHops.Cmdline != "" {
+		bunnyHops.Cmd = strings.Split(bunnyHops.Cmdline, " ")
+	}
+
+	// A Linux binary runs on top of the elfloader, which defines the
+	// kernel, the rootfs and the command line.
+	err = ValidateBinary(bunnyHops)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+	ApplyElfloader(bunnyHops)
+
+	// With an architecture in platforms, its overrides of the kernel and
+	// the rootfs apply right away. Otherwise, they apply once the
+	// architecture of the worker is known, so each one gets checked here.
+	if bunnyHops.Platform.Arch != "" {
+		ApplyArchOverrides(bunnyHops)
+	}
+	err = ValidateArchOverrides(bunnyHops)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
 	}
 
 	err = ValidateKernel(bunnyHops.Kernel)
 	if err != nil {
-		return nil, errors.Join(errInvalidBunnyfile, err)
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateKernelBuild(bunnyHops.Kernel, bunnyHops.Platform)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
 	}
 
 	// Set default value of from to scratch
@@ -114,34 +348,191 @@ func ParseBunnyfile(fileBytes []byte) (*Hops, error) {
 	}
 	err = ValidateRootfs(bunnyHops.Rootfs)
 	if err != nil {
-		return nil, errors.Join(errInvalidBunnyfile, err)
+		return errors.Join(errInvalidBunnyfile, err)
 	}
 
-	// TODO: Remove this in next release.
-	// Keep backwards compatibility and if cmd is empty, then
-	// use cmdline. Otherwise, the Cmdline is ignored.
-	if len(bunnyHops.Cmd) == 0 && bunnyHops.Cmdline != "" {
-		bunnyHops.Cmd = strings.Split(bunnyHops.Cmdline, " ")
+	err = ValidateBase(bunnyHops.Base, bunnyHops.Rootfs, bunnyHops.Platform)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
 	}
 
-	return bunnyHops, nil
+	err = ValidateTest(bunnyHops.Test, bunnyHops.Platform)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateMirrors(bunnyHops.Mirrors)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateArtifacts(bunnyHops.Artifacts, bunnyHops.Rootfs)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateNetwork(bunnyHops.Network)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateCertificates(bunnyHops.Certificates)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateFlavors(bunnyHops.Flavors, bunnyHops.Platform)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateBuild(bunnyHops.Build)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateScan(bunnyHops.Scan)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateModules(bunnyHops.Modules, bunnyHops.Kernel, bunnyHops.Rootfs, bunnyHops.Platform)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateDtb(bunnyHops.Dtb, bunnyHops.Platform)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateHooks(bunnyHops.Hooks, bunnyHops.Rootfs)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidatePaths(bunnyHops.Paths, bunnyHops.Rootfs, bunnyHops.Dtb)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateVariant(bunnyHops.Variant)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateArgs(bunnyHops.Args)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateResources(bunnyHops.Resources)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateMetadata(bunnyHops.Metadata)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateShutdown(bunnyHops.Shutdown)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+	if bunnyHops.Shutdown.Signal != "" {
+		bunnyHops.Shutdown.Signal, _ = NormalizeSignal(bunnyHops.Shutdown.Signal)
+	}
+
+	err = ValidateUser(bunnyHops.User)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateWorkdir(bunnyHops.Workdir)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	err = ValidateTarget(bunnyHops.Target)
+	if err != nil {
+		return errors.Join(errInvalidBunnyfile, err)
+	}
+
+	return nil
 }
 
-func hopsToPack(ctx context.Context, fileBytes []byte, buildContext string, c client.Client) (*PackInstructions, error) {
+func hopsToPack(ctx context.Context, fileBytes []byte, buildContext string, c client.Client, opts SourceOpts) (*PackInstructions, error) {
 	// Could not parse Containerfile-like syntax file.
 	// Try bunnyfile syntax.
-	hops, err := ParseBunnyfile(fileBytes)
+	hops, err := ParseBunnyfileArgs(fileBytes, opts.BuildArgs)
 	if err != nil {
 		return nil, fmt.Errorf("failed while parsing as bunnyfile: %w", err)
 	}
+	if opts.StrictSchema {
+		err = ValidateSchema(fileBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed while parsing as bunnyfile: %w", errors.Join(errInvalidBunnyfile, err))
+		}
+	}
+
+	return packHops(ctx, hops, buildContext, c, opts)
+}
 
-	packInst, err := ToPack(hops, buildContext)
+// packHops creates the packing instructions of the validated instructions of
+// a bunnyfile.
+func packHops(ctx context.Context, hops *Hops, buildContext string, c client.Client, opts SourceOpts) (*PackInstructions, error) {
+	hops.Network = hops.Network.Merge(opts.Network)
+	hops.Hardened = hops.Hardened || opts.Hardened
+	hops.Exclude = opts.Exclude
+	// Without an architecture, the unikernel targets the worker
+	if hops.Platform.Arch == "" {
+		hops.Platform.Arch = opts.Arch
+	}
+	ApplyArchOverrides(hops)
+	if hops.Platform.Framework == FrameworkAuto {
+		framework, err := DetectKernelFramework(ctx, c, hops, buildContext, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to detect the framework: %w", err)
+		}
+		hops.Platform.Framework = framework
+		err = ValidateDetectedFramework(hops)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bunnyfile for the detected framework %s: %w", framework, err)
+		}
+	}
+	err := ApplyUnikraftPull(ctx, hops, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pull from %s: %w", unikraftHub, err)
+	}
+	err = CheckLocalIncludes(ctx, c, hops, buildContext, opts.CheckIncludes)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid local includes: %w", err)
+	}
+	packInst, err := ToPack(ctx, hops, buildContext)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert hops to pack instructions: %w", err)
 	}
+	packInst.Sources.Mirrors = packInst.Sources.Mirrors.Merge(opts.Mirrors)
+	packInst.Sources.Layouts = opts.Layouts
+	packInst.Sources.Arch = opts.Arch
+	packInst.Sources.Resolver = opts.Resolver
+	packInst.Sources.Tools = opts.Tools
+	packInst.Sources.UnikraftPull = opts.UnikraftPull
+	packInst.Sources.StrictSchema = opts.StrictSchema
+	packInst.Sources.CheckIncludes = opts.CheckIncludes
+
+	// Make sure that the images exist for the platform they are pulled for
+	if c != nil {
+		err = CheckSourcePlatforms(ctx, hops, packInst.Sources.MetaResolver(c), opts.Arch)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid platform of images: %w", err)
+		}
+	}
 
 	// Get the OCI Image config of the base Image if there is any
-	baseImg, err := getBaseConfig(ctx, c, packInst.BaseRef, packInst.Annots["com.urunc.unikernel.hypervisor"])
+	baseImg, err := packInst.Sources.imageConfig(ctx, c, packInst.BaseRef,
+		packInst.Annots["com.urunc.unikernel.hypervisor"], hops.Platform.Arch)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get OCI config of base image %s: %w", packInst.BaseRef, err)
 	}
@@ -153,7 +544,19 @@ func hopsToPack(ctx context.Context, fileBytes []byte, buildContext string, c cl
 		baseImg.Config.Entrypoint = packInst.Img.Config.Entrypoint
 	}
 	baseImg.Config.Env = append(baseImg.Config.Env, packInst.Img.Config.Env...)
-	packInst.Img = baseImg
+	if packInst.Img.Config.StopSignal != "" {
+		baseImg.Config.StopSignal = packInst.Img.Config.StopSignal
+	}
+	if packInst.Img.Config.User != "" {
+		baseImg.Config.User = packInst.Img.Config.User
+	}
+	if packInst.Img.Config.WorkingDir != "" {
+		baseImg.Config.WorkingDir = packInst.Img.Config.WorkingDir
+	}
+	if packInst.Img.Author != "" {
+		baseImg.Author = packInst.Img.Author
+	}
+	packInst.Img = alignHistory(baseImg)
 
 	// Get the OCI Image config of the base Image if there is any
 	packInst.Img = updateImage(packInst.Img, packInst.Annots)
@@ -162,6 +565,13 @@ func hopsToPack(ctx context.Context, fileBytes []byte, buildContext string, c cl
 }
 
 func containerfileToPack(state *llb.State, img *dockerspec.DockerOCIImage) (*PackInstructions, error) {
+	// Rewrite any urunc labels of older tools (e.g. bima), so both labels
+	// and annotations use the names that urunc understands.
+	err := NormalizeAnnotations(img.Config.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid urunc labels: %w", err)
+	}
+
 	instr := new(PackInstructions)
 	instr.Base = *state
 	instr.Img = img.Image
@@ -171,6 +581,18 @@ func containerfileToPack(state *llb.State, img *dockerspec.DockerOCIImage) (*Pac
 		instr.Annots[k] = v
 	}
 
+	// Let urunc know how to stop the unikernel, e.g. from STOPSIGNAL
+	shutdown, err := shutdownFromImage(instr.Annots, instr.Img.Config.StopSignal)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid shutdown: %w", err)
+	}
+	for k, v := range shutdown.Annotations() {
+		instr.Annots[k] = v
+	}
+	if shutdown.Signal != "" {
+		instr.Img.Config.StopSignal = shutdown.Signal
+	}
+
 	// Set default annotations if they are not set
 	if instr.Annots["com.urunc.unikernel.unikernelType"] == "" {
 		instr.Annots["com.urunc.unikernel.unikernelType"] = "linux"
@@ -182,6 +604,8 @@ func containerfileToPack(state *llb.State, img *dockerspec.DockerOCIImage) (*Pac
 		aCopy.SrcState = llb.Image(defaultUrunitImage)
 		aCopy.SrcPath = defaultUrunitPath
 		aCopy.DstPath = defaultUrunitPath
+		aCopy.Name = "urunit"
+		aCopy.From = defaultUrunitImage
 		instr.Copies = append(instr.Copies, aCopy)
 		instr.Img.Config.Entrypoint = append([]string{"/urunit"}, img.Config.ImageConfig.Entrypoint...)
 	}
@@ -196,34 +620,85 @@ func containerfileToPack(state *llb.State, img *dockerspec.DockerOCIImage) (*Pac
 	if instr.Annots["com.urunc.unikernel.binary"] == "" {
 		var aCopy PackCopies
 
+		aCopy.From = defaultFirecrackerKernelImage
 		if instr.Annots["com.urunc.unikernel.hypervisor"] == "qemu" {
-			aCopy.SrcState = llb.Image(defaultQemuKernelImage)
-		} else {
-			aCopy.SrcState = llb.Image(defaultFirecrackerKernelImage)
+			aCopy.From = defaultQemuKernelImage
 		}
+		aCopy.SrcState = llb.Image(aCopy.From)
 		aCopy.SrcPath = DefaultKernelPath
 		aCopy.DstPath = DefaultKernelPath
+		aCopy.Name = "kernel"
 		instr.Copies = append(instr.Copies, aCopy)
 
 		instr.Annots["com.urunc.unikernel.binary"] = DefaultKernelPath
 	}
 
+	err = instr.NormalizeCopies()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid copies in the final image: %v", err)
+	}
+
 	return instr, nil
 }
 
+// ParseContainerfile converts a Containerfile with the dockerfile frontend
+// and creates the packing instructions of the resulting image.
+func ParseContainerfile(ctx context.Context, fileBytes []byte, c client.Client, opts SourceOpts) (*PackInstructions, error) {
+	fileBytes = NormalizeFile(fileBytes)
+	// The build arguments take precedence over the proxies of the network
+	buildArgs := map[string]string{}
+	maps.Copy(buildArgs, opts.Network.ProxyBuildArgs())
+	maps.Copy(buildArgs, opts.BuildArgs)
+	config := dockerui.Config{
+		BuildArgs: buildArgs,
+	}
+	// Without an architecture, the dockerfile frontend uses the host
+	if opts.Arch != "" {
+		platform, err := BuildPlatform(opts.Arch)
+		if err != nil {
+			return nil, err
+		}
+		config.BuildPlatforms = []ocispecs.Platform{platform}
+		config.TargetPlatforms = []ocispecs.Platform{platform}
+	}
+
+	// Outside of a frontend, the images get resolved as the tools do,
+	// instead of with the default resolver of the dockerfile frontend
+	var resolver llb.ImageMetaResolver = c
+	if c == nil && opts.Resolver != nil {
+		resolver = opts.Resolver
+	}
+	state, img, _, _, err := dockerfile2llb.Dockerfile2LLB(ctx, fileBytes, dockerfile2llb.ConvertOpt{
+		Config:       config,
+		MetaResolver: opts.MetaResolver(resolver),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidContainerfile, err)
+	}
+	pInstr, err := containerfileToPack(state, img)
+	if err != nil {
+		return nil, err
+	}
+	pInstr.Sources = opts
+	pInstr.ExpandPlaceholders(opts.Arch, pInstr.Annots["com.urunc.unikernel.hypervisor"])
+
+	return pInstr, nil
+}
+
 // ParseFile tries to first parse the given file using dockerfile2LLB.
 // If that fails, then it attempts to read it using the bunnyfile format.
-func ParseFile(ctx context.Context, fileBytes []byte, buildContext string, c client.Client) (*PackInstructions, error) {
+// The mirrors in opts take precedence over the ones in the bunnyfile.
+func ParseFile(ctx context.Context, fileBytes []byte, buildContext string, c client.Client, opts SourceOpts) (*PackInstructions, error) {
 	// Try to parse the file with dockerfile2LLB
-	state, img, _, _, derr := dockerfile2llb.Dockerfile2LLB(ctx, fileBytes, dockerfile2llb.ConvertOpt{
-		MetaResolver: c,
-	})
+	pInstr, derr := ParseContainerfile(ctx, fileBytes, c, opts)
 	if derr == nil {
-		return containerfileToPack(state, img)
+		return pInstr, nil
+	}
+	if !errors.Is(derr, errInvalidContainerfile) {
+		return nil, derr
 	}
-	derr = fmt.Errorf("error while parsing as containerfile: %w", derr)
 
-	pInstr, berr := hopsToPack(ctx, fileBytes, buildContext, c)
+	pInstr, berr := hopsToPack(ctx, fileBytes, buildContext, c, opts)
 	if berr != nil {
 		if errors.Is(berr, errInvalidFileFormat) {
 			return nil, errors.Join(berr, derr)
@@ -16,9 +16,12 @@ package hops
 
 import (
 	"context"
+	"encoding/base64"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 type ParseTestInfo struct {
@@ -166,6 +169,39 @@ cmdline: "foo bar"
 			expectError: true,
 			errorText:   "The from field of rootfs can not be empty or scratch",
 		},
+		{
+			name: "Valid JSON",
+			input: []byte(`
+{
+  "version": "0.1",
+  "platforms": {"framework": "foo", "monitor": "bar"},
+  "rootfs": {"from": "scratch", "type": "initrd", "include": ["foo:/bar", {"source": "baz", "destination": "/baz"}]},
+  "kernel": {"from": "local", "path": "foo"},
+  "cmd": ["foo", "bar"]
+}
+`),
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name: "Invalid JSON missing platform",
+			input: []byte(`{"version": "0.1", "kernel": {"from": "local", "path": "foo"}}
+`),
+			expectError: true,
+			errorText:   "The framework field of platforms is necessary",
+		},
+		{
+			name:        "Invalid JSON syntax",
+			input:       []byte(`{"version": "0.1",}`),
+			expectError: true,
+			errorText:   "Invalid JSON bunnyfile",
+		},
+		{
+			name:        "Invalid JSON trailing content",
+			input:       []byte(`{"version": "0.1"} {"version": "0.1"}`),
+			expectError: true,
+			errorText:   "unexpected content after the top-level object",
+		},
 	}
 
 	for _, tc := range tests {
@@ -246,6 +282,20 @@ kernel:
   from: local
   path: foo
 cmdline: "foo bar"
+`),
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name: "Valid JSON bunnyfile",
+			input: []byte(`{
+  "syntax": "foo",
+  "version": "0.1",
+  "platforms": {"framework": "foo", "monitor": "bar"},
+  "rootfs": {"from": "local", "path": "foo"},
+  "kernel": {"from": "local", "path": "foo"},
+  "cmdline": "foo bar"
+}
 `),
 			expectError: false,
 			errorText:   "",
@@ -285,7 +335,7 @@ cmdline: "foo bar"
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			i, err := ParseFile(context.TODO(), tc.input, "foo", nil)
+			i, err := ParseFile(context.TODO(), tc.input, "foo", nil, SourceOpts{})
 			if tc.expectError {
 				require.Error(t, err, "Expected an error, got nil")
 				require.Nil(t, i)
@@ -297,3 +347,230 @@ cmdline: "foo bar"
 		})
 	}
 }
+
+func TestParseLegacyContainerfile(t *testing.T) {
+	input := []byte(`#syntax=foo
+FROM scratch
+COPY test-redis.hvt /unikernel/test-redis.hvt
+LABEL com.urunc.unikernel.binary=/unikernel/test-redis.hvt
+LABEL "com.urunc.unikernel.cmdline"='redis-server /data/conf/redis.conf'
+LABEL "com.urunc.unikernel.unikernelType"="rumprun"
+LABEL "com.urunc.unikernel.hypervisor"="hvt"
+LABEL "com.urunc.unikernel.useDMBlock"="true"
+`)
+
+	i, err := ParseFile(context.TODO(), input, "foo", nil, SourceOpts{})
+	require.NoError(t, err)
+	require.NotNil(t, i)
+	require.Equal(t, "true", i.Annots["com.urunc.unikernel.mountRootfs"])
+	require.NotContains(t, i.Annots, "com.urunc.unikernel.useDMBlock")
+	require.Equal(t, "true", i.Img.Config.Labels["com.urunc.unikernel.mountRootfs"])
+	require.NotContains(t, i.Img.Config.Labels, "com.urunc.unikernel.useDMBlock")
+	require.Equal(t, "/unikernel/test-redis.hvt", i.Annots["com.urunc.unikernel.binary"])
+	require.Equal(t, "redis-server /data/conf/redis.conf", i.Annots["com.urunc.unikernel.cmdline"])
+	require.Equal(t, "rumprun", i.Annots["com.urunc.unikernel.unikernelType"])
+	require.Equal(t, "hvt", i.Annots["com.urunc.unikernel.hypervisor"])
+	// No extra copies for the kernel or urunit
+	require.Equal(t, 0, len(i.Copies))
+}
+
+func TestParseBunnyfileJSON(t *testing.T) {
+	yamlHops, err := ParseBunnyfile([]byte(`
+version: 0.1
+platforms:
+  framework: unikraft
+  monitor: qemu
+  architecture: amd64
+kernel:
+  from: local
+  path: kernel
+rootfs:
+  from: scratch
+  type: initrd
+  include:
+    - app:/app
+hardened: true
+cmd: ["/app", "-v"]
+`))
+	require.NoError(t, err)
+	jsonHops, err := ParseBunnyfile([]byte(`  {
+  "version": "0.1",
+  "platforms": {"framework": "unikraft", "monitor": "qemu", "architecture": "amd64"},
+  "kernel": {"from": "local", "path": "kernel"},
+  "rootfs": {"from": "scratch", "type": "initrd", "include": ["app:/app"]},
+  "hardened": true,
+  "cmd": ["/app", "-v"]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, yamlHops, jsonHops)
+}
+
+func TestParseWindowsFiles(t *testing.T) {
+	windows := func(file string) []byte {
+		return append([]byte("\uFEFF"), strings.ReplaceAll(file, "\n", "\r\n")...)
+	}
+	bunnyfile := `#syntax=harbor.nbfc.io/nubificus/bunny:latest
+version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+hooks:
+  image: alpine
+  postPack:
+    - |
+      echo one
+      echo two
+cmd: ["/init"]
+`
+
+	require.Equal(t, []byte("a\nb\n"), NormalizeFile([]byte("\uFEFFa\r\nb\r\n")))
+	require.Equal(t, []byte("a\rb"), NormalizeFile([]byte("a\rb")))
+
+	t.Run("Bunnyfile", func(t *testing.T) {
+		expected, err := ParseBunnyfile([]byte(bunnyfile))
+		require.NoError(t, err)
+		h, err := ParseBunnyfile(windows(bunnyfile))
+		require.NoError(t, err)
+		require.Equal(t, expected, h)
+		require.Equal(t, []string{"echo one\necho two\n"}, h.Hooks.PostPack)
+	})
+	t.Run("JSON bunnyfile", func(t *testing.T) {
+		file := windows(`{"version": "v0.1",
+"platforms": {"framework": "linux", "monitor": "qemu"},
+"kernel": {"from": "local", "path": "kernel"},
+"cmd": ["/init"]}`)
+		require.True(t, isJSONBunnyfile(NormalizeFile(file)))
+		h, err := ParseBunnyfile(file)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/init"}, h.Cmd)
+	})
+	t.Run("Containerfile", func(t *testing.T) {
+		i, err := ParseFile(context.TODO(), windows(`#syntax=harbor.nbfc.io/nubificus/bunny:latest
+FROM scratch
+COPY kernel /kernel
+LABEL com.urunc.unikernel.binary=/kernel
+LABEL com.urunc.unikernel.cmdline="/init -v"
+LABEL com.urunc.unikernel.unikernelType=linux
+LABEL com.urunc.unikernel.hypervisor=qemu
+`), "context", nil, SourceOpts{})
+		require.NoError(t, err)
+		require.Equal(t, "/kernel", i.Annots["com.urunc.unikernel.binary"])
+		require.Equal(t, "/init -v", i.Annots["com.urunc.unikernel.cmdline"])
+		require.Equal(t, "qemu", i.Annots["com.urunc.unikernel.hypervisor"])
+	})
+}
+
+func TestParseInlineBunnyfile(t *testing.T) {
+	content := []byte(`version: v0.1
+platforms:
+  framework: unikraft
+  monitor: qemu
+  architecture: x86
+kernel:
+  from: harbor.nbfc.io/nubificus/urunc/nginx-qemu-unikraft:latest
+  path: /unikernel/kernel
+cmd: ["-c", "/nginx/conf/nginx.conf"]
+`)
+	urlSafe := []byte("cmd: [\"~~~\"]\n")
+	folded := base64.StdEncoding.EncodeToString(content)
+	folded = folded[:40] + "\n" + folded[40:] + "\n"
+	tests := []struct {
+		name   string
+		input  string
+		output []byte
+	}{
+		{"Standard", base64.StdEncoding.EncodeToString(content), content},
+		{"URL-safe", base64.URLEncoding.EncodeToString(urlSafe), urlSafe},
+		{"Without padding", base64.RawStdEncoding.EncodeToString(content), content},
+		{"Line wraps", folded, content},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fileBytes, err := DecodeInlineBunnyfile(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.output, fileBytes)
+		})
+	}
+	_, err := ParseBunnyfile(content)
+	require.NoError(t, err)
+
+	_, err = DecodeInlineBunnyfile("  ")
+	require.ErrorContains(t, err, "The inline bunnyfile is empty")
+	_, err = DecodeInlineBunnyfile("version: v0.1")
+	require.ErrorContains(t, err, "Failed to decode the inline bunnyfile")
+}
+
+func TestParseIncludeExcludes(t *testing.T) {
+	for input, expected := range map[string]FileToInclude{
+		"./app:/app:!**/*.log": {
+			From: "local", Src: "./app", Dst: "/app",
+			Exclude: []string{"**/*.log"},
+		},
+		"app:/app:!**/*.log:!tmp": {
+			From: "local", Src: "app", Dst: "/app",
+			Exclude: []string{"**/*.log", "tmp"},
+		},
+		"app:!build": {
+			From: "local", Src: "app", Dst: "app",
+			Exclude: []string{"build"},
+		},
+		"app:/app": {
+			From: "local", Src: "app", Dst: "/app",
+		},
+	} {
+		var f FileToInclude
+		require.NoError(t, yaml.Unmarshal([]byte(`"`+input+`"`), &f), input)
+		require.Equal(t, expected, f, input)
+	}
+
+	var f FileToInclude
+	require.NoError(t, yaml.Unmarshal([]byte(`
+from: local
+source: app
+destination: /app
+exclude:
+  - "**/*.log"
+  - tmp
+`), &f))
+	require.Equal(t, []string{"**/*.log", "tmp"}, f.Exclude)
+}
+
+func TestParseIncludeStructured(t *testing.T) {
+	var files []FileToInclude
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- src: "my app:v1"
+  dst: "/opt/my app/app:v1"
+  mode: "0755"
+  owner: "1000:100"
+- source: "données/é.conf"
+  destination: /etc/é.conf
+- "app:/app"
+`), &files))
+	require.Equal(t, []FileToInclude{
+		{From: "local", Src: "my app:v1", Dst: "/opt/my app/app:v1", Mode: "0755", Owner: "1000:100"},
+		{From: "local", Src: "données/é.conf", Dst: "/etc/é.conf"},
+		{From: "local", Src: "app", Dst: "/app"},
+	}, files)
+
+	var f FileToInclude
+	err := yaml.Unmarshal([]byte(`
+src: app
+source: app
+dst: /app
+`), &f)
+	require.ErrorContains(t, err, "both source and src are set")
+	err = yaml.Unmarshal([]byte(`
+src: app
+dst: /app
+destination: /app
+`), &f)
+	require.ErrorContains(t, err, "both destination and dst are set")
+	err = yaml.Unmarshal([]byte(`
+src: app
+mode: "0644"
+`), &f)
+	require.ErrorContains(t, err, "destination is empty")
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+// Paths defines where the kernel, the rootfs and the device tree blob get
+// placed in the final image, for frameworks and tools that expect them in
+// specific locations. The defaults apply to the empty fields.
+type Paths struct {
+	Kernel string `yaml:"kernel"`
+	Rootfs string `yaml:"rootfs"`
+	Dtb    string `yaml:"dtb"`
+}
+
+// KernelPath returns the path of the kernel in the final image
+func (p Paths) KernelPath() string {
+	if p.Kernel == "" {
+		return DefaultKernelPath
+	}
+
+	return p.Kernel
+}
+
+// RootfsPath returns the path of the rootfs file in the final image
+func (p Paths) RootfsPath() string {
+	if p.Rootfs == "" {
+		return DefaultRootfsPath
+	}
+
+	return p.Rootfs
+}
+
+// DtbPath returns the path of the device tree blob in the final image
+func (p Paths) DtbPath() string {
+	if p.Dtb == "" {
+		return DefaultDtbPath
+	}
+
+	return p.Dtb
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const pathsBunnyfile = `version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+  architecture: arm64
+kernel:
+  from: local
+  path: vmlinux
+rootfs:
+  from: local
+  path: rootfs
+  type: initrd
+dtb:
+  from: local
+  path: board.dtb
+paths:
+  kernel: /boot/vmlinuz
+  rootfs: /boot/initrd.img
+  dtb: /boot/board.dtb
+cmd: ["/init"]
+`
+
+func TestPathsDefaults(t *testing.T) {
+	p := Paths{}
+	require.Equal(t, DefaultKernelPath, p.KernelPath())
+	require.Equal(t, DefaultRootfsPath, p.RootfsPath())
+	require.Equal(t, DefaultDtbPath, p.DtbPath())
+
+	p = Paths{Kernel: "/boot/vmlinuz", Rootfs: "/boot/initrd.img", Dtb: "/boot/board.dtb"}
+	require.Equal(t, "/boot/vmlinuz", p.KernelPath())
+	require.Equal(t, "/boot/initrd.img", p.RootfsPath())
+	require.Equal(t, "/boot/board.dtb", p.DtbPath())
+}
+
+func TestPathsValidate(t *testing.T) {
+	rootfs := Rootfs{From: "local", Path: "rootfs"}
+	dtb := Dtb{From: "local", Path: "board.dtb"}
+	require.NoError(t, ValidatePaths(Paths{}, Rootfs{}, Dtb{}))
+	require.NoError(t, ValidatePaths(Paths{Kernel: "/boot/vmlinuz"}, Rootfs{}, Dtb{}))
+	require.NoError(t, ValidatePaths(Paths{Rootfs: "/boot/initrd.img", Dtb: "/boot/board.dtb"}, rootfs, dtb))
+
+	tests := []struct {
+		name      string
+		paths     Paths
+		rootfs    Rootfs
+		errorText string
+	}{
+		{"Relative path", Paths{Kernel: "boot/vmlinuz"}, rootfs, "The kernel field of paths should be a clean absolute path"},
+		{"Unclean path", Paths{Rootfs: "/boot/../initrd"}, rootfs, "The rootfs field of paths should be a clean absolute path"},
+		{"Root", Paths{Kernel: "/"}, rootfs, "The kernel field of paths can not be /"},
+		{"urunc.json", Paths{Dtb: uruncJSONPath}, rootfs, "The dtb field of paths can not be /urunc.json"},
+		{"Same paths", Paths{Kernel: "/boot/image", Rootfs: "/boot/image"}, rootfs, "The kernel and rootfs fields of paths can not be the same"},
+		{"No rootfs", Paths{Rootfs: "/boot/initrd.img"}, Rootfs{}, "The rootfs field of paths requires a rootfs"},
+		{"Raw rootfs", Paths{Rootfs: "/boot/initrd.img"}, Rootfs{From: "scratch", Type: "raw", Includes: []FileToInclude{{Src: "app", Dst: "/app"}}}, "The rootfs field of paths can not be set for a raw rootfs"},
+		{"Rootfs from an image", Paths{Rootfs: "/boot/initrd.img"}, Rootfs{From: "harbor.nbfc.io/app"}, "The rootfs field of paths can not be set for a raw rootfs"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorContains(t, ValidatePaths(tc.paths, tc.rootfs, dtb), tc.errorText)
+		})
+	}
+	t.Run("Dtb without dtb", func(t *testing.T) {
+		require.ErrorContains(t, ValidatePaths(Paths{Dtb: "/boot/board.dtb"}, rootfs, Dtb{}), "The dtb field of paths requires a dtb")
+	})
+}
+
+func TestPathsToPack(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(pathsBunnyfile))
+	require.NoError(t, err)
+	instr, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, "/boot/vmlinuz", instr.Annots["com.urunc.unikernel.binary"])
+	require.Equal(t, "/boot/initrd.img", instr.Annots["com.urunc.unikernel.initrd"])
+	require.Equal(t, "/boot/board.dtb", instr.Annots[DtbAnnotation])
+	require.Equal(t, []CopyDecision{
+		{Name: "kernel", From: "local", Src: "vmlinux", Dst: "/boot/vmlinuz"},
+		{Name: "rootfs", From: "local", Src: "rootfs", Dst: "/boot/initrd.img"},
+		{Name: "dtb", From: "local", Src: "board.dtb", Dst: "/boot/board.dtb"},
+	}, instr.BaseDecision.Copies)
+
+	t.Run("Kernel in the base", func(t *testing.T) {
+		h := dtbHops("harbor.nbfc.io/kernel:latest", Dtb{From: "harbor.nbfc.io/kernel:latest", Path: "/board.dtb"})
+		h.Paths = Paths{Kernel: "/boot/vmlinuz", Dtb: "/boot/board.dtb"}
+		instr, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		require.Equal(t, "harbor.nbfc.io/kernel:latest", instr.BaseRef)
+		require.Equal(t, "/boot/vmlinuz", instr.Annots["com.urunc.unikernel.binary"])
+		require.Equal(t, "/boot/board.dtb", instr.Annots[DtbAnnotation])
+		require.Equal(t, []CopyDecision{
+			{Name: "kernel", From: "harbor.nbfc.io/kernel:latest", Src: "/kernel", Dst: "/boot/vmlinuz"},
+			{Name: "dtb", From: "harbor.nbfc.io/kernel:latest", Src: "/board.dtb", Dst: "/boot/board.dtb"},
+		}, instr.BaseDecision.Copies)
+	})
+	t.Run("Kernels of other monitors", func(t *testing.T) {
+		h, err := ParseBunnyfile([]byte(kernelsBunnyfile + "paths:\n  kernel: /boot/vmlinuz\n"))
+		require.NoError(t, err)
+		instr, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		require.Equal(t, "/boot/vmlinuz", instr.Annots[KernelVariantAnnotPrefix+"qemu"])
+		require.Equal(t, "/boot/vmlinuz-firecracker", instr.Annots[KernelVariantAnnotPrefix+"firecracker"])
+	})
+	t.Run("Raw rootfs", func(t *testing.T) {
+		h := &Hops{
+			Platform: Platform{Framework: "unikraft", Monitor: "qemu"},
+			Kernel:   Kernel{From: "local", Path: "kernel"},
+			Rootfs:   Rootfs{From: "harbor.nbfc.io/app"},
+			Paths:    Paths{Rootfs: "/boot/rootfs"},
+		}
+		_, err := ToPack(context.TODO(), h, "context")
+		require.ErrorContains(t, err, "The rootfs field of paths can not be set for a raw rootfs")
+	})
+	t.Run("Invalid bunnyfile", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte(kernelsBunnyfile + "paths:\n  dtb: /boot/board.dtb\n"))
+		require.ErrorContains(t, err, "The dtb field of paths requires a dtb")
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"strings"
+)
+
+const (
+	// Replaced by the target architecture, e.g. amd64
+	PlaceholderArch string = "{{arch}}"
+	// Replaced by the monitor, e.g. qemu
+	PlaceholderMonitor string = "{{monitor}}"
+)
+
+// expandPlaceholders replaces the placeholders in the given string with the
+// target architecture, the host's if empty, and the monitor.
+func expandPlaceholders(s string, arch string, monitor string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	r := strings.NewReplacer(PlaceholderArch, normalizeArch(arch),
+		PlaceholderMonitor, monitor)
+
+	return r.Replace(s)
+}
+
+// expandIncludes returns a copy of the given files to include, with the
+// placeholders in their destinations expanded.
+func expandIncludes(includes []FileToInclude, arch string, monitor string) []FileToInclude {
+	if includes == nil {
+		return nil
+	}
+	expanded := make([]FileToInclude, len(includes))
+	for i, f := range includes {
+		f.Dst = expandPlaceholders(f.Dst, arch, monitor)
+		expanded[i] = f
+	}
+
+	return expanded
+}
+
+// ExpandPlaceholders replaces the placeholders in the destinations of the
+// copies and in the values of the annotations and the labels with the
+// given architecture and monitor. This way, the same file can describe
+// images for many platforms.
+func (i *PackInstructions) ExpandPlaceholders(arch string, monitor string) {
+	for j := range i.Copies {
+		i.Copies[j].DstPath = expandPlaceholders(i.Copies[j].DstPath, arch, monitor)
+	}
+	for j := range i.FlavorCopies {
+		i.FlavorCopies[j].DstPath = expandPlaceholders(i.FlavorCopies[j].DstPath, arch, monitor)
+	}
+	for k, v := range i.Annots {
+		i.Annots[k] = expandPlaceholders(v, arch, monitor)
+	}
+	for k, v := range i.Img.Config.Labels {
+		i.Img.Config.Labels[k] = expandPlaceholders(v, arch, monitor)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaceholdersExpand(t *testing.T) {
+	require.Equal(t, "/lib/amd64/qemu/app", expandPlaceholders("/lib/{{arch}}/{{monitor}}/app", "x86_64", "qemu"))
+	require.Equal(t, "/app-arm64", expandPlaceholders("/app-{{arch}}", "aarch64", "qemu"))
+	require.Equal(t, "/app/{{os}}", expandPlaceholders("/app/{{os}}", "amd64", "qemu"))
+	require.Equal(t, "/app", expandPlaceholders("/app", "amd64", "qemu"))
+}
+
+func TestPlaceholdersInstructions(t *testing.T) {
+	i := &PackInstructions{
+		Copies: []PackCopies{
+			{SrcPath: "kernel", DstPath: "/boot/{{monitor}}/kernel"},
+		},
+		FlavorCopies: []PackCopies{
+			{SrcPath: "/boot/qemu/kernel", DstPath: "/unikraft/bin/kernel-{{arch}}"},
+		},
+		Annots: map[string]string{
+			"com.urunc.unikernel.cmdline": "app --arch {{arch}}",
+		},
+	}
+	i.Img.Config.Labels = map[string]string{
+		"org.example.platform": "{{monitor}}/{{arch}}",
+	}
+	i.ExpandPlaceholders("arm64", "firecracker")
+	require.Equal(t, "/boot/firecracker/kernel", i.Copies[0].DstPath)
+	require.Equal(t, "/unikraft/bin/kernel-arm64", i.FlavorCopies[0].DstPath)
+	require.Equal(t, "app --arch arm64", i.Annots["com.urunc.unikernel.cmdline"])
+	require.Equal(t, "firecracker/arm64", i.Img.Config.Labels["org.example.platform"])
+}
+
+func TestPlaceholdersToPack(t *testing.T) {
+	includes := []FileToInclude{{From: "local", Src: "app", Dst: "/app-{{arch}}"}}
+	hops := &Hops{
+		Platform: Platform{
+			Framework: "linux",
+			Monitor:   "qemu",
+			Arch:      "amd64",
+		},
+		Kernel: Kernel{
+			From: "local",
+			Path: "kernel",
+		},
+		Rootfs: Rootfs{
+			From:     "scratch",
+			Type:     "initrd",
+			Includes: includes,
+		},
+		Cmd: []string{"/app-{{arch}}", "{{monitor}}"},
+	}
+	i, err := ToPack(context.TODO(), hops, "context")
+	require.NoError(t, err)
+	require.Equal(t, "/app-amd64 qemu", i.Annots["com.urunc.unikernel.cmdline"])
+	require.Equal(t, "/app-{{arch}}", includes[0].Dst)
+
+	def, err := i.Rootfs.InitrdContent.Marshal(context.TODO())
+	require.NoError(t, err)
+	_, arr := parseDef(t, def.Def)
+	var dests []string
+	for _, op := range arr {
+		if f := op.GetFile(); f != nil {
+			for _, action := range f.Actions {
+				if c := action.GetCopy(); c != nil {
+					dests = append(dests, c.Dest)
+				}
+			}
+		}
+	}
+	require.Contains(t, dests, "/app-amd64")
+}
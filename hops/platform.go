@@ -0,0 +1,70 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"strings"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// The OS of the tools that run inside the build, regardless of the OS
+	// of the machine that creates the LLB
+	buildOS string = "linux"
+)
+
+// BuildPlatform returns the platform of the buildkit worker that runs the
+// LLB for the given architecture. An empty architecture means the
+// architecture of the host, which is always the case for the frontend.
+func BuildPlatform(arch string) (ocispecs.Platform, error) {
+	p := ocispecs.Platform{
+		OS:           buildOS,
+		Architecture: normalizeArch(arch),
+	}
+	switch p.Architecture {
+	case "amd64", "arm64":
+	case "arm":
+		p.Variant = "v7"
+	default:
+		return ocispecs.Platform{}, fmt.Errorf("Unsupported architecture: %s", p.Architecture)
+	}
+
+	return p, nil
+}
+
+// ParsePlatform parses the platform of the buildkit worker, as given in the
+// command line (e.g. linux/amd64), and returns its architecture.
+func ParsePlatform(platform string) (string, error) {
+	if platform == "" {
+		return "", nil
+	}
+	os, arch, found := strings.Cut(platform, "/")
+	if !found {
+		return "", fmt.Errorf("Invalid platform %s, expected <os>/<arch>", platform)
+	}
+	if os != buildOS {
+		return "", fmt.Errorf("Unsupported OS %s, only %s workers can run the build", os, buildOS)
+	}
+	// Ignore any variant, we only use v7 for arm
+	arch, _, _ = strings.Cut(arch, "/")
+	_, err := BuildPlatform(arch)
+	if err != nil {
+		return "", err
+	}
+
+	return normalizeArch(arch), nil
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlatformBuild(t *testing.T) {
+	tests := []struct {
+		arch     string
+		expected ocispecs.Platform
+	}{
+		{"x86_64", ocispecs.Platform{OS: "linux", Architecture: "amd64"}},
+		{"aarch64", ocispecs.Platform{OS: "linux", Architecture: "arm64"}},
+		{"arm", ocispecs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.arch, func(t *testing.T) {
+			p, err := BuildPlatform(tc.arch)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, p)
+		})
+	}
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := BuildPlatform("riscv64")
+		require.ErrorContains(t, err, "Unsupported architecture: riscv64")
+	})
+}
+
+func TestPlatformParse(t *testing.T) {
+	tests := []testInfo{
+		{name: "Empty means host", input: ""},
+		{name: "Valid amd64", input: "linux/amd64"},
+		{name: "Valid arm with variant", input: "linux/arm/v7"},
+		{
+			name:        "Invalid without OS",
+			input:       "amd64",
+			expectError: true,
+			errorText:   "Invalid platform amd64, expected <os>/<arch>",
+		},
+		{
+			name:        "Invalid darwin",
+			input:       "darwin/arm64",
+			expectError: true,
+			errorText:   "Unsupported OS darwin",
+		},
+		{
+			name:        "Invalid architecture",
+			input:       "linux/s390x",
+			expectError: true,
+			errorText:   "Unsupported architecture: s390x",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePlatform(tc.input)
+			if tc.expectError {
+				require.ErrorContains(t, err, tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPlatformMarshal(t *testing.T) {
+	bunnyfile := []byte(`#syntax=harbor.nbfc.io/nubificus/bunny:latest
+version: v0.1
+
+platforms:
+  framework: unikraft
+  monitor: qemu
+
+kernel:
+  from: unikraft.org/nginx:latest
+  path: /unikraft/bin/kernel
+`)
+	otherArch := "amd64"
+	if runtime.GOARCH == "amd64" {
+		otherArch = "arm64"
+	}
+	for _, arch := range []string{"", otherArch} {
+		t.Run("Worker "+arch, func(t *testing.T) {
+			expected := normalizeArch(arch)
+			instr, err := ParseFile(context.TODO(), bunnyfile, "context", nil, SourceOpts{Arch: arch})
+			require.NoError(t, err)
+			def, err := PackLLB(context.TODO(), *instr)
+			require.NoError(t, err)
+			_, arr := parseDef(t, def.Def)
+			for _, op := range arr {
+				if op.Platform == nil {
+					continue
+				}
+				// The kernel image targets the monitor and every other
+				// operation the worker
+				if op.Platform.OS != "qemu" {
+					require.Equal(t, "linux", op.Platform.OS)
+				}
+				require.Equal(t, expected, op.Platform.Architecture)
+			}
+		})
+	}
+	t.Run("Unsupported worker", func(t *testing.T) {
+		_, err := marshalPlatform(context.TODO(), llb.Scratch(), "riscv64")
+		require.ErrorContains(t, err, "Unsupported architecture")
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// The error of buildkit for an image without a manifest for the platform
+var noPlatformMatchRegexp = regexp.MustCompile(`(?i)no match for platform|has no manifest for`)
+
+// platformSource is an image of the bunnyfile and the platform that the build
+// pulls it for
+type platformSource struct {
+	field    string
+	ref      string
+	platform ocispecs.Platform
+}
+
+// formatPlatform returns the platform in the os/arch[/variant] form
+func formatPlatform(p ocispecs.Platform) string {
+	platform := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		platform += "/" + p.Variant
+	}
+
+	return platform
+}
+
+// platformSources returns the images of the kernel, the rootfs and the base,
+// with the platform that GetSourceState pulls each one for: the monitor and
+// the architecture for images of unikraft.org and the platform of the worker
+// for the rest.
+func platformSources(h *Hops, workerArch string) ([]platformSource, error) {
+	worker, err := BuildPlatform(workerArch)
+	if err != nil {
+		return nil, err
+	}
+	var sources []platformSource
+	add := func(field string, ref string) {
+		switch ref {
+		case "", "scratch", "local", KernelFromBuild:
+			return
+		}
+		platform := worker
+		if isUnikraftRef(ref) {
+			platform = ocispecs.Platform{
+				OS:           monitorPlatformOS(h.Platform.Monitor),
+				Architecture: normalizeArch(h.Platform.Arch),
+			}
+		}
+		sources = append(sources, platformSource{field: field, ref: ref, platform: platform})
+	}
+	add("kernel.from", h.Kernel.From)
+	add("rootfs.from", h.Rootfs.From)
+	add("base", h.Base)
+
+	return sources, nil
+}
+
+// CheckSourcePlatforms makes sure that the images of the kernel, the rootfs
+// and the base have a manifest for the platform that the build pulls them
+// for, so a mismatch fails before the build with the field of the bunnyfile
+// to fix, instead of the generic error of buildkit.
+func CheckSourcePlatforms(ctx context.Context, h *Hops, resolver llb.ImageMetaResolver, workerArch string) error {
+	sources, err := platformSources(h, workerArch)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	checked := map[string]bool{}
+	for _, src := range sources {
+		key := src.ref + " " + formatPlatform(src.platform)
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+		_, _, _, err := resolver.ResolveImageConfig(ctx, src.ref, sourceresolver.Opt{
+			LogName: "checking the platforms of " + src.ref,
+			ImageOpt: &sourceresolver.ResolveImageOpt{
+				Platform: &src.platform,
+			},
+		})
+		switch {
+		case err == nil:
+		case !noPlatformMatchRegexp.MatchString(err.Error()):
+			errs = append(errs, fmt.Errorf("Failed to resolve %s of %s: %v", src.ref, src.field, err))
+		case isUnikraftRef(src.ref):
+			errs = append(errs, fmt.Errorf("The image %s of %s has no manifest for %s, which the monitor %s and the architecture of platforms require",
+				src.ref, src.field, formatPlatform(src.platform), h.Platform.Monitor))
+		default:
+			errs = append(errs, fmt.Errorf("The image %s of %s has no manifest for %s, the platform of the buildkit worker",
+				src.ref, src.field, formatPlatform(src.platform)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
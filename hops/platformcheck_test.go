@@ -0,0 +1,100 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// platformResolver resolves only the images that have a manifest for the
+// requested platform, as buildkit does
+type platformResolver struct {
+	// The platforms of each image
+	platforms map[string][]string
+	// The resolved images with their platform
+	resolved []string
+}
+
+func (r *platformResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	platform := formatPlatform(*opt.ImageOpt.Platform)
+	r.resolved = append(r.resolved, ref+" "+platform)
+	platforms, ok := r.platforms[ref]
+	if !ok {
+		return "", "", nil, fmt.Errorf("%s: not found", ref)
+	}
+	for _, p := range platforms {
+		if p == platform {
+			return ref, pinnedDigest, []byte("{}"), nil
+		}
+	}
+
+	return "", "", nil, fmt.Errorf("no match for platform in manifest: not found")
+}
+
+func TestPlatformCheckSources(t *testing.T) {
+	h := &Hops{
+		Platform: Platform{Framework: "unikraft", Monitor: "qemu", Arch: "arm64"},
+		Kernel:   Kernel{From: "unikraft.org/nginx:1.15", Path: "/unikraft/bin/kernel"},
+		Rootfs:   Rootfs{From: "unikraft.org/nginx:1.15"},
+		Base:     "harbor.nbfc.io/nubificus/urunc/busybox:latest",
+	}
+	resolver := &platformResolver{platforms: map[string][]string{
+		"unikraft.org/nginx:1.15":                       {"qemu/arm64"},
+		"harbor.nbfc.io/nubificus/urunc/busybox:latest": {"linux/amd64"},
+	}}
+	require.NoError(t, CheckSourcePlatforms(context.TODO(), h, resolver, "amd64"))
+	// The same image for the same platform gets resolved once
+	require.Equal(t, []string{
+		"unikraft.org/nginx:1.15 qemu/arm64",
+		"harbor.nbfc.io/nubificus/urunc/busybox:latest linux/amd64",
+	}, resolver.resolved)
+
+	t.Run("Local and scratch", func(t *testing.T) {
+		resolver := &platformResolver{}
+		local := &Hops{
+			Platform: Platform{Framework: "unikraft", Monitor: "qemu", Arch: "amd64"},
+			Kernel:   Kernel{From: "local", Path: "kernel"},
+			Rootfs:   Rootfs{From: "scratch"},
+		}
+		require.NoError(t, CheckSourcePlatforms(context.TODO(), local, resolver, "amd64"))
+		require.Empty(t, resolver.resolved)
+	})
+}
+
+func TestPlatformCheckErrors(t *testing.T) {
+	h := &Hops{
+		Platform: Platform{Framework: "unikraft", Monitor: "firecracker", Arch: "amd64"},
+		Kernel:   Kernel{From: "unikraft.org/nginx:1.15", Path: "/unikraft/bin/kernel"},
+		Rootfs:   Rootfs{From: "harbor.nbfc.io/nubificus/urunc/nginx-rootfs:latest"},
+		Base:     "harbor.nbfc.io/nubificus/urunc/missing:latest",
+	}
+	resolver := &platformResolver{platforms: map[string][]string{
+		"unikraft.org/nginx:1.15":                            {"qemu/amd64", "fc/arm64"},
+		"harbor.nbfc.io/nubificus/urunc/nginx-rootfs:latest": {"linux/amd64"},
+	}}
+	err := CheckSourcePlatforms(context.TODO(), h, resolver, "arm64")
+	require.ErrorContains(t, err, "The image unikraft.org/nginx:1.15 of kernel.from has no manifest for fc/amd64, which the monitor firecracker and the architecture of platforms require")
+	require.ErrorContains(t, err, "The image harbor.nbfc.io/nubificus/urunc/nginx-rootfs:latest of rootfs.from has no manifest for linux/arm64, the platform of the buildkit worker")
+	require.ErrorContains(t, err, "Failed to resolve harbor.nbfc.io/nubificus/urunc/missing:latest of base: harbor.nbfc.io/nubificus/urunc/missing:latest: not found")
+
+	_, err = platformSources(h, "mips")
+	require.ErrorContains(t, err, "Unsupported architecture: mips")
+}
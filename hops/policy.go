@@ -0,0 +1,114 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AnnotationPolicy contains the annotations that the operators of a cluster
+// expect in every image, regardless of the bunnyfile or the Containerfile
+type AnnotationPolicy struct {
+	// Annotations to set, if the image does not set them
+	Defaults map[string]string `yaml:"defaults"`
+	// Annotations to set with exactly these values. A different value in
+	// the image is a conflict.
+	Enforce map[string]string `yaml:"enforce"`
+	// Annotations that the image should set, with any value
+	Required []string `yaml:"required"`
+}
+
+// ParseAnnotationPolicy parses a policy file. The urunc annotations of the
+// policy are normalized, like the labels of Containerfiles.
+func ParseAnnotationPolicy(data []byte) (AnnotationPolicy, error) {
+	var p AnnotationPolicy
+	err := yaml.Unmarshal(data, &p)
+	if err != nil {
+		return AnnotationPolicy{}, fmt.Errorf("Failed to parse policy: %v", err)
+	}
+	if p.Defaults == nil {
+		p.Defaults = map[string]string{}
+	}
+	if p.Enforce == nil {
+		p.Enforce = map[string]string{}
+	}
+	err = NormalizeAnnotations(p.Defaults)
+	if err != nil {
+		return AnnotationPolicy{}, fmt.Errorf("Invalid defaults of policy: %v", err)
+	}
+	err = NormalizeAnnotations(p.Enforce)
+	if err != nil {
+		return AnnotationPolicy{}, fmt.Errorf("Invalid enforced annotations of policy: %v", err)
+	}
+	for k, v := range p.Enforce {
+		if d, ok := p.Defaults[k]; ok && d != v {
+			return AnnotationPolicy{}, fmt.Errorf("The policy enforces %q for %s, but its default is %q", v, k, d)
+		}
+	}
+	for _, k := range p.Required {
+		if k == "" {
+			return AnnotationPolicy{}, fmt.Errorf("A required annotation of the policy can not be empty")
+		}
+	}
+
+	return p, nil
+}
+
+// ApplyPolicy sets the annotations and the labels of the policy. It returns
+// an error with all the annotations that conflict with the policy and all
+// the required annotations that are missing.
+func (i *PackInstructions) ApplyPolicy(p AnnotationPolicy) error {
+	var errs []error
+
+	if i.Annots == nil {
+		i.Annots = make(map[string]string)
+	}
+	if i.Img.Config.Labels == nil {
+		i.Img.Config.Labels = make(map[string]string)
+	}
+
+	keys := make([]string, 0, len(p.Enforce))
+	for k := range p.Enforce {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := p.Enforce[k]
+		if existing, ok := i.Annots[k]; ok && existing != v {
+			errs = append(errs, fmt.Errorf("Annotation %s is %q, but the policy enforces %q", k, existing, v))
+			continue
+		}
+		i.Annots[k] = v
+		i.Img.Config.Labels[k] = v
+	}
+	for k, v := range p.Defaults {
+		if _, ok := i.Annots[k]; ok {
+			continue
+		}
+		i.Annots[k] = v
+		i.Img.Config.Labels[k] = v
+	}
+	for _, k := range p.Required {
+		if i.Annots[k] == "" {
+			errs = append(errs, fmt.Errorf("Annotation %s is required by the policy", k))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyParse(t *testing.T) {
+	tests := []testInfo{
+		{
+			name: "Valid policy",
+			input: `
+defaults:
+  org.example.team: unikernels
+enforce:
+  com.urunc.unikernel.useDMBlock: "False"
+required:
+  - org.example.owner
+`,
+			expectError: false,
+		},
+		{
+			name:        "Valid empty policy",
+			input:       "",
+			expectError: false,
+		},
+		{
+			name:        "Invalid yaml",
+			input:       "defaults: [a",
+			expectError: true,
+			errorText:   "Failed to parse policy",
+		},
+		{
+			name: "Invalid boolean",
+			input: `
+enforce:
+  com.urunc.unikernel.mountRootfs: "no way"
+`,
+			expectError: true,
+			errorText:   "Invalid enforced annotations of policy",
+		},
+		{
+			name: "Invalid conflicting default",
+			input: `
+defaults:
+  com.urunc.unikernel.mountRootfs: "true"
+enforce:
+  com.urunc.unikernel.mountRootfs: "false"
+`,
+			expectError: true,
+			errorText:   "The policy enforces \"false\" for com.urunc.unikernel.mountRootfs, but its default is \"true\"",
+		},
+		{
+			name: "Invalid empty required",
+			input: `
+required:
+  - ""
+`,
+			expectError: true,
+			errorText:   "A required annotation of the policy can not be empty",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseAnnotationPolicy([]byte(tc.input))
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	p, err := ParseAnnotationPolicy([]byte(tests[0].input))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"com.urunc.unikernel.mountRootfs": "false"}, p.Enforce)
+	require.Equal(t, map[string]string{"org.example.team": "unikernels"}, p.Defaults)
+	require.Equal(t, []string{"org.example.owner"}, p.Required)
+}
+
+func TestPolicyApply(t *testing.T) {
+	policy := AnnotationPolicy{
+		Defaults: map[string]string{
+			"org.example.team":                  "unikernels",
+			"com.urunc.unikernel.unikernelType": "rumprun",
+		},
+		Enforce: map[string]string{
+			"com.urunc.unikernel.mountRootfs": "false",
+		},
+		Required: []string{"com.urunc.unikernel.binary"},
+	}
+	t.Run("Valid", func(t *testing.T) {
+		instr := &PackInstructions{Annots: map[string]string{}}
+		err := instr.SetAnnotations(Platform{Framework: "linux", Monitor: "qemu"}, []string{"/bin/app"}, DefaultKernelPath, DefaultRootfsPath, "initrd")
+		require.NoError(t, err)
+		err = instr.ApplyPolicy(policy)
+		require.NoError(t, err)
+		// The defaults do not override the annotations of the image
+		require.Equal(t, "linux", instr.Annots["com.urunc.unikernel.unikernelType"])
+		require.Equal(t, "unikernels", instr.Annots["org.example.team"])
+		require.Equal(t, "unikernels", instr.Img.Config.Labels["org.example.team"])
+		require.Equal(t, "false", instr.Img.Config.Labels["com.urunc.unikernel.mountRootfs"])
+	})
+	t.Run("Enforce missing annotation", func(t *testing.T) {
+		instr := &PackInstructions{}
+		err := instr.ApplyPolicy(AnnotationPolicy{Enforce: policy.Enforce})
+		require.NoError(t, err)
+		require.Equal(t, "false", instr.Annots["com.urunc.unikernel.mountRootfs"])
+	})
+	t.Run("Invalid conflict and missing annotation", func(t *testing.T) {
+		instr := &PackInstructions{Annots: map[string]string{}}
+		err := instr.SetAnnotations(Platform{Framework: "linux", Monitor: "qemu"}, []string{"/bin/app"}, "", DefaultRootfsPath, "raw")
+		require.NoError(t, err)
+		err = instr.ApplyPolicy(policy)
+		require.ErrorContains(t, err, "Annotation com.urunc.unikernel.mountRootfs is \"true\", but the policy enforces \"false\"")
+		require.ErrorContains(t, err, "Annotation com.urunc.unikernel.binary is required by the policy")
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fieldError is an error of a field of the bunnyfile, which points at the
+// line of the field, once the positions of the fields are known.
+type fieldError struct {
+	// The path of the field, e.g. rootfs.from or rootfs.include[1].mode
+	field string
+	// The line of the field in the bunnyfile, 0 if it is unknown
+	line int
+	err  error
+}
+
+func (e *fieldError) Error() string {
+	if e.line == 0 {
+		return e.err.Error()
+	}
+
+	return fmt.Sprintf("%s (line %d): %v", e.field, e.line, e.err)
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// fieldErrorf returns an error of the field at the given path.
+func fieldErrorf(field string, format string, a ...any) error {
+	return &fieldError{field: field, err: fmt.Errorf(format, a...)}
+}
+
+// withField returns err as the error of the field at the given path, or nil
+// if err is nil.
+func withField(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &fieldError{field: field, err: err}
+}
+
+// positions maps the path of each field of a bunnyfile to its line
+type positions map[string]int
+
+// nodePositions returns the lines of all the fields under the given node.
+// The fields of a mapping get the line of their key and the entries of a
+// list get their own line.
+func nodePositions(node *yaml.Node) positions {
+	pos := positions{}
+	pos.add("", node)
+
+	return pos
+}
+
+func (p positions) add(prefix string, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			p.add(prefix, n)
+		}
+	case yaml.MappingNode:
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			field := node.Content[j].Value
+			if prefix != "" {
+				field = prefix + "." + field
+			}
+			p[field] = node.Content[j].Line
+			p.add(field, node.Content[j+1])
+		}
+	case yaml.SequenceNode:
+		for j, entry := range node.Content {
+			field := fmt.Sprintf("%s[%d]", prefix, j)
+			p[field] = entry.Line
+			p.add(field, entry)
+		}
+	}
+}
+
+// line returns the line of the field at the given path. A field that is not
+// in the bunnyfile, e.g. a missing one, gets the line of its closest parent.
+func (p positions) line(field string) int {
+	for field != "" {
+		if line, ok := p[field]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(field, ".[")
+		if i < 0 {
+			break
+		}
+		field = field[:i]
+	}
+
+	return 0
+}
+
+// annotate sets the line of the field of the error, if it is the error of a
+// field.
+func (p positions) annotate(err error) {
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		fe.line = p.line(fe.field)
+	}
+}
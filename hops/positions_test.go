@@ -0,0 +1,110 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPositionsLines(t *testing.T) {
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`version: v0.1
+rootfs:
+  from: scratch
+  include:
+    - source: app
+      destination: /app
+    - source: lib
+      destination: /lib
+`), &doc))
+
+	pos := nodePositions(&doc)
+	require.Equal(t, 2, pos.line("rootfs"))
+	require.Equal(t, 3, pos.line("rootfs.from"))
+	require.Equal(t, 7, pos.line("rootfs.include[1]"))
+	require.Equal(t, 8, pos.line("rootfs.include[1].destination"))
+	// Missing fields get the line of their closest parent
+	require.Equal(t, 7, pos.line("rootfs.include[1].mode"))
+	require.Equal(t, 2, pos.line("rootfs.path"))
+	require.Equal(t, 0, pos.line("kernel.from"))
+}
+
+func TestPositionsParse(t *testing.T) {
+	t.Run("Rootfs", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+rootfs:
+  from: scratch
+  path: rootfs.img
+`))
+		require.ErrorContains(t, err, "rootfs.from (line 9): The from field of rootfs can not be empty or scratch, if path is set")
+		require.ErrorIs(t, err, errInvalidBunnyfile)
+	})
+	t.Run("Include", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+rootfs:
+  from: scratch
+  include:
+    - source: app
+      destination: /app
+    - source: lib
+      destination: /lib
+      mode: rwx
+`))
+		require.ErrorContains(t, err, `rootfs.include[1].mode (line 15): Invalid mode of lib`)
+	})
+	t.Run("Missing field", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+`))
+		require.ErrorContains(t, err, "kernel.path (line 5): The path field of kernel is necessary")
+	})
+	t.Run("JSON", func(t *testing.T) {
+		_, err := ParseBunnyfile([]byte(`{"version": "v0.1",
+"platforms": {"framework": "linux", "monitor": "qemu"},
+"kernel": {"from": "local"}}`))
+		require.ErrorContains(t, err, "The path field of kernel is necessary")
+		require.NotContains(t, err.Error(), "line")
+	})
+}
+
+func TestPositionsFieldError(t *testing.T) {
+	err := ValidateKernel(Kernel{From: "local"})
+	require.EqualError(t, err, "The path field of kernel is necessary")
+
+	var fe *fieldError
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "kernel.path", fe.field)
+	require.Nil(t, withField("kernel.from", nil))
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+const (
+	// DefaultResolveRetries is how many times a failed resolution is retried
+	DefaultResolveRetries int = 3
+	// DefaultResolveConcurrency is how many resolutions run at the same time
+	DefaultResolveConcurrency int = 4
+	// The backoff before the first retry, which doubles on every retry
+	defaultResolveBackoff time.Duration = time.Second
+	// The maximum backoff between two attempts
+	defaultResolveMaxBackoff time.Duration = 30 * time.Second
+)
+
+var (
+	// Errors of a registry that asks to slow down
+	rateLimitErrorRegexp = regexp.MustCompile(`(?i)\b429\b|too ?many ?requests`)
+	// Errors that a later attempt may not hit
+	transientErrorRegexp = regexp.MustCompile(`(?i)\b429\b|too ?many ?requests|\b50[234]\b|bad gateway|service unavailable|gateway timeout|i/o timeout|tls handshake timeout|connection reset|connection refused|unexpected EOF`)
+)
+
+// ResolveLimits limits how many resolutions of image metadata run at the same
+// time and retries the ones that fail with a transient error (e.g. the rate
+// limit of Docker Hub) with an exponential backoff. A rate limit pauses every
+// resolution that shares the limits, not just the one that hit it.
+type ResolveLimits struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	slots      chan struct{}
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewResolveLimits returns the limits for the given number of retries and
+// concurrent resolutions
+func NewResolveLimits(retries int, concurrency int) *ResolveLimits {
+	return &ResolveLimits{
+		retries:    retries,
+		backoff:    defaultResolveBackoff,
+		maxBackoff: defaultResolveMaxBackoff,
+		slots:      make(chan struct{}, concurrency),
+	}
+}
+
+// ParseResolveLimits parses the retries and the concurrency of the
+// resolutions, as given in the frontend options. Empty values take the
+// defaults.
+func ParseResolveLimits(retries string, concurrency string) (*ResolveLimits, error) {
+	r := DefaultResolveRetries
+	if retries != "" {
+		n, err := strconv.Atoi(retries)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("Invalid number of retries %q", retries)
+		}
+		r = n
+	}
+	c := DefaultResolveConcurrency
+	if concurrency != "" {
+		n, err := strconv.Atoi(concurrency)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("Invalid concurrency %q, it should be a positive number", concurrency)
+		}
+		c = n
+	}
+
+	return NewResolveLimits(r, c), nil
+}
+
+// sleepContext waits for the given duration, unless the context ends earlier
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// acquire waits for any pause to end and for a free slot
+func (l *ResolveLimits) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		wait := time.Until(l.pausedUntil)
+		l.mu.Unlock()
+		if wait <= 0 {
+			break
+		}
+		err := sleepContext(ctx, wait)
+		if err != nil {
+			return err
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case l.slots <- struct{}{}:
+		return nil
+	}
+}
+
+// delay returns the backoff after the given attempt, with a random jitter,
+// so the retries of concurrent builds do not hit the registry together
+func (l *ResolveLimits) delay(attempt int) time.Duration {
+	d := l.maxBackoff
+	if attempt < 32 && l.backoff<<attempt < l.maxBackoff {
+		d = l.backoff << attempt
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// pause stops every resolution until the given time
+func (l *ResolveLimits) pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// do runs the given resolution within the limits
+func (l *ResolveLimits) do(ctx context.Context, resolve func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := l.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		err = resolve()
+		<-l.slots
+		if err == nil || !transientErrorRegexp.MatchString(err.Error()) {
+			return err
+		}
+		if attempt >= l.retries {
+			if attempt > 0 {
+				return fmt.Errorf("%w (gave up after %d attempts)", err, attempt+1)
+			}
+			return err
+		}
+		d := l.delay(attempt)
+		if rateLimitErrorRegexp.MatchString(err.Error()) {
+			l.pause(time.Now().Add(d))
+		}
+		err = sleepContext(ctx, d)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// limitedResolver resolves the image metadata with the given resolver within
+// the limits
+type limitedResolver struct {
+	resolver llb.ImageMetaResolver
+	limits   *ResolveLimits
+}
+
+func (r limitedResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	var resolved string
+	var dgst digest.Digest
+	var config []byte
+	err := r.limits.do(ctx, func() error {
+		var err error
+		resolved, dgst, config, err = r.resolver.ResolveImageConfig(ctx, ref, opt)
+		return err
+	})
+
+	return resolved, dgst, config, err
+}
+
+// MetaResolver wraps the given resolver, so that its resolutions happen
+// within the limits
+func (l *ResolveLimits) MetaResolver(resolver llb.ImageMetaResolver) llb.ImageMetaResolver {
+	return limitedResolver{resolver: resolver, limits: l}
+}
+
+// limitedClient is a buildkit client, whose resolutions of metadata happen
+// within the limits
+type limitedClient struct {
+	client.Client
+	limits *ResolveLimits
+}
+
+func (c limitedClient) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	return limitedResolver{resolver: c.Client, limits: c.limits}.ResolveImageConfig(ctx, ref, opt)
+}
+
+func (c limitedClient) ResolveSourceMetadata(ctx context.Context, op *pb.SourceOp, opt sourceresolver.Opt) (*sourceresolver.MetaResponse, error) {
+	var resp *sourceresolver.MetaResponse
+	err := c.limits.do(ctx, func() error {
+		var err error
+		resp, err = c.Client.ResolveSourceMetadata(ctx, op, opt)
+		return err
+	})
+
+	return resp, err
+}
+
+// Client wraps the given buildkit client, so that every resolution of
+// metadata through it (e.g. of the base image or the tool images) happens
+// within the limits
+func (l *ResolveLimits) Client(c client.Client) client.Client {
+	return limitedClient{Client: c, limits: l}
+}
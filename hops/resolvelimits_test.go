@@ -0,0 +1,180 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyResolver fails with the given errors, one per resolution, before it
+// succeeds
+type flakyResolver struct {
+	mu      sync.Mutex
+	errs    []error
+	calls   int
+	running atomic.Int32
+	maxRun  atomic.Int32
+}
+
+func (r *flakyResolver) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt) (string, digest.Digest, []byte, error) {
+	running := r.running.Add(1)
+	defer r.running.Add(-1)
+	for {
+		current := r.maxRun.Load()
+		if running <= current || r.maxRun.CompareAndSwap(current, running) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		return "", "", nil, err
+	}
+
+	return ref, pinnedDigest, []byte("{}"), nil
+}
+
+// testResolveLimits returns limits with a short backoff for the tests
+func testResolveLimits(retries int, concurrency int) *ResolveLimits {
+	l := NewResolveLimits(retries, concurrency)
+	l.backoff = time.Millisecond
+	l.maxBackoff = 4 * time.Millisecond
+
+	return l
+}
+
+func TestResolveLimitsParse(t *testing.T) {
+	l, err := ParseResolveLimits("", "")
+	require.NoError(t, err)
+	require.Equal(t, DefaultResolveRetries, l.retries)
+	require.Equal(t, DefaultResolveConcurrency, cap(l.slots))
+
+	l, err = ParseResolveLimits("0", "1")
+	require.NoError(t, err)
+	require.Equal(t, 0, l.retries)
+	require.Equal(t, 1, cap(l.slots))
+
+	_, err = ParseResolveLimits("-1", "")
+	require.ErrorContains(t, err, `Invalid number of retries "-1"`)
+	_, err = ParseResolveLimits("", "0")
+	require.ErrorContains(t, err, `Invalid concurrency "0"`)
+	_, err = ParseResolveLimits("", "foo")
+	require.ErrorContains(t, err, `Invalid concurrency "foo"`)
+}
+
+func TestResolveLimitsRetries(t *testing.T) {
+	t.Run("Transient errors", func(t *testing.T) {
+		inner := &flakyResolver{errs: []error{
+			fmt.Errorf("unexpected status from GET request: 429 Too Many Requests"),
+			fmt.Errorf("failed to do request: dial tcp: i/o timeout"),
+		}}
+		_, dgst, _, err := testResolveLimits(3, 1).MetaResolver(inner).
+			ResolveImageConfig(context.TODO(), "alpine:3.20", sourceresolver.Opt{})
+		require.NoError(t, err)
+		require.Equal(t, digest.Digest(pinnedDigest), dgst)
+		require.Equal(t, 3, inner.calls)
+	})
+	t.Run("Other errors", func(t *testing.T) {
+		inner := &flakyResolver{errs: []error{
+			fmt.Errorf("docker.io/library/foo:latest: not found"),
+		}}
+		_, _, _, err := testResolveLimits(3, 1).MetaResolver(inner).
+			ResolveImageConfig(context.TODO(), "foo", sourceresolver.Opt{})
+		require.EqualError(t, err, "docker.io/library/foo:latest: not found")
+		require.Equal(t, 1, inner.calls)
+	})
+	t.Run("Digests are not status codes", func(t *testing.T) {
+		inner := &flakyResolver{errs: []error{
+			fmt.Errorf("sha256:0429ab: not found"),
+		}}
+		_, _, _, err := testResolveLimits(3, 1).MetaResolver(inner).
+			ResolveImageConfig(context.TODO(), "foo", sourceresolver.Opt{})
+		require.Error(t, err)
+		require.Equal(t, 1, inner.calls)
+	})
+	t.Run("Give up", func(t *testing.T) {
+		inner := &flakyResolver{}
+		for range 3 {
+			inner.errs = append(inner.errs, fmt.Errorf("toomanyrequests: You have reached your pull rate limit"))
+		}
+		_, _, _, err := testResolveLimits(2, 1).MetaResolver(inner).
+			ResolveImageConfig(context.TODO(), "alpine:3.20", sourceresolver.Opt{})
+		require.EqualError(t, err, "toomanyrequests: You have reached your pull rate limit (gave up after 3 attempts)")
+		require.Equal(t, 3, inner.calls)
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		inner := &flakyResolver{errs: []error{fmt.Errorf("503 Service Unavailable")}}
+		l := testResolveLimits(3, 1)
+		l.backoff = time.Hour
+		l.maxBackoff = time.Hour
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+		defer cancel()
+		_, _, _, err := l.MetaResolver(inner).ResolveImageConfig(ctx, "alpine:3.20", sourceresolver.Opt{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 1, inner.calls)
+	})
+}
+
+func TestResolveLimitsConcurrency(t *testing.T) {
+	inner := &flakyResolver{}
+	resolver := testResolveLimits(0, 2).MetaResolver(inner)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			_, _, _, err := resolver.ResolveImageConfig(context.TODO(), "alpine:3.20", sourceresolver.Opt{})
+			require.NoError(t, err)
+		})
+	}
+	wg.Wait()
+	require.Equal(t, 10, inner.calls)
+	require.LessOrEqual(t, inner.maxRun.Load(), int32(2))
+}
+
+func TestResolveLimitsRateLimit(t *testing.T) {
+	l := testResolveLimits(1, 2)
+	inner := &flakyResolver{errs: []error{fmt.Errorf("429 Too Many Requests")}}
+	_, _, _, err := l.MetaResolver(inner).ResolveImageConfig(context.TODO(), "alpine:3.20", sourceresolver.Opt{})
+	require.NoError(t, err)
+	require.False(t, l.pausedUntil.IsZero())
+
+	// Other errors do not pause the rest of the resolutions
+	l = testResolveLimits(1, 2)
+	inner = &flakyResolver{errs: []error{fmt.Errorf("502 Bad Gateway")}}
+	_, _, _, err = l.MetaResolver(inner).ResolveImageConfig(context.TODO(), "alpine:3.20", sourceresolver.Opt{})
+	require.NoError(t, err)
+	require.True(t, l.pausedUntil.IsZero())
+
+	// A pause holds every resolution back
+	l.pause(time.Now().Add(20 * time.Millisecond))
+	start := time.Now()
+	_, _, _, err = l.MetaResolver(&flakyResolver{}).ResolveImageConfig(context.TODO(), "alpine:3.20", sourceresolver.Opt{})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+const (
+	// The report, the log and the exit code of the scan in its result
+	ScanReportPath string = "/report.json"
+	ScanLogPath    string = "/scan.log"
+	ScanStatusPath string = "/scan.status"
+	// The predicate type of the attestation with the report of the scan
+	ScanPredicateType string = "https://github.com/nubificus/bunny/scan/v1"
+)
+
+const (
+	defaultScanImage string = "docker.io/aquasec/trivy:latest"
+	scanContentDir   string = "/scan"
+	scanScriptDir    string = "/scan-script"
+	scanOutDir       string = "/out"
+	scanCacheDir     string = "/trivy-cache"
+	// The number of vulnerabilities to list, when the scan fails
+	scanListedVulns int = 10
+)
+
+// The severities of vulnerabilities, from the lowest to the highest, as
+// trivy reports them
+var scanSeverities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// The script that scans the content of the rootfs with trivy. Only fetching
+// the vulnerability database needs the network, so only that is retried,
+// following the network policy. The database is kept in a cache mount between
+// builds. Like the kernel build, the script does not fail and it stores the
+// exit code of the scan in a separate file.
+const scanScript = `#!/bin/sh
+` + retryFunction + `
+scan() {
+	retry trivy image --quiet --download-db-only
+	trivy rootfs --quiet --skip-db-update --format json --output ` + scanOutDir + ScanReportPath + ` ` + scanContentDir + `
+}
+{ (set -e; scan); echo $? > ` + scanOutDir + ScanStatusPath + `; } 2>&1 | tee ` + scanOutDir + ScanLogPath + `
+`
+
+// Scan defines the vulnerability scan of the rootfs of the final image
+type Scan struct {
+	// Run the scan
+	Enabled bool `yaml:"enabled"`
+	// The tools image with trivy
+	Image string `yaml:"image"`
+	// Fail on any vulnerability of this severity or higher
+	Severity string `yaml:"severity"`
+}
+
+// threshold returns the index of the severity that fails the scan in
+// scanSeverities, or -1 if the scan never fails due to vulnerabilities.
+func (s Scan) threshold() (int, error) {
+	if s.Severity == "" {
+		return -1, nil
+	}
+	for i, severity := range scanSeverities {
+		if strings.EqualFold(severity, s.Severity) {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("Unknown severity %s, expected one of %s", s.Severity,
+		strings.Join(scanSeverities, ", "))
+}
+
+// ScanLLB creates the LLB definition that scans the rootfs of the given final
+// image for vulnerabilities. Unikernel images are not understood by standard
+// scanners, so the files of an initrd that bunny creates get scanned before
+// packing them, while any other image gets scanned as a whole.
+func ScanLLB(ctx context.Context, instr PackInstructions, image llb.State) (*llb.Definition, error) {
+	s := instr.Scan
+	if !s.Enabled {
+		return nil, fmt.Errorf("No scan was defined")
+	}
+
+	content := image
+	if instr.Rootfs != nil && instr.Rootfs.InitrdContent != nil {
+		content = *instr.Rootfs.InitrdContent
+	}
+	toolImage := s.Image
+	if toolImage == "" {
+		toolImage = defaultScanImage
+	}
+	scanFiles := llb.Scratch().
+		File(llb.Mkfile("/scan.sh", 0755, []byte(scanScript)))
+
+	runOpts := []llb.RunOption{
+		llb.Args([]string{"/bin/sh", path.Join(scanScriptDir, "scan.sh")}),
+		llb.AddMount(scanContentDir, content, llb.Readonly),
+		llb.AddMount(scanScriptDir, scanFiles, llb.Readonly),
+		llb.AddEnv("TRIVY_CACHE_DIR", scanCacheDir),
+		llb.AddMount(scanCacheDir, llb.Scratch(),
+			llb.AsPersistentCacheDir("bunny-trivy-cache", llb.CacheMountLocked)),
+		llb.WithCustomName("Internal:Scan rootfs"),
+	}
+	runOpts = append(runOpts, instr.Sources.Network.RunOptions()...)
+	runOpts = append(runOpts, hardenedOptions(instr.Sources.Hardened, true)...)
+	runOpts = append(runOpts, instr.Sources.Build.NetworkOptions(BuildStepScan)...)
+	scanExec := llb.Image(toolImage).Run(runOpts...)
+
+	return marshalState(ctx, scanExec.AddMount(scanOutDir, llb.Scratch()), instr.Sources)
+}
+
+// scanReport contains the fields of the JSON report of trivy that bunny uses
+type scanReport struct {
+	Results []struct {
+		Target          string `json:"Target"`
+		Vulnerabilities []struct {
+			VulnerabilityID string `json:"VulnerabilityID"`
+			PkgName         string `json:"PkgName"`
+			Severity        string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// CheckScanReport returns an error with the vulnerabilities of the report,
+// whose severity is the one of the scan or higher.
+func CheckScanReport(report []byte, s Scan) error {
+	threshold, err := s.threshold()
+	if err != nil {
+		return err
+	}
+	var r scanReport
+	err = json.Unmarshal(report, &r)
+	if err != nil {
+		return fmt.Errorf("Failed to parse the report of the scan: %v", err)
+	}
+	if threshold < 0 {
+		return nil
+	}
+
+	var found []string
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			t := Scan{Severity: v.Severity}
+			idx, err := t.threshold()
+			if err != nil || idx < threshold {
+				continue
+			}
+			found = append(found, fmt.Sprintf("%s (%s) in %s of %s", v.VulnerabilityID, v.Severity, v.PkgName, res.Target))
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	listed := found
+	if len(listed) > scanListedVulns {
+		listed = listed[:scanListedVulns]
+	}
+
+	return fmt.Errorf("Found %d vulnerabilities of severity %s or higher:\n%s",
+		len(found), strings.ToUpper(s.Severity), strings.Join(listed, "\n"))
+}
+
+// CheckScan reads the exit code and the report of the scan from its result
+// and returns an error, if the scan failed or it found vulnerabilities of the
+// severity of the scan or higher.
+func CheckScan(ctx context.Context, ref client.Reference, s Scan) error {
+	status, err := ref.ReadFile(ctx, client.ReadRequest{Filename: ScanStatusPath})
+	if err != nil {
+		return fmt.Errorf("Failed to read the status of the scan: %v", err)
+	}
+	if strings.TrimSpace(string(status)) != "0" {
+		log, err := ref.ReadFile(ctx, client.ReadRequest{Filename: ScanLogPath})
+		if err != nil {
+			return fmt.Errorf("The scan failed with exit code %s and its log is not available: %v",
+				strings.TrimSpace(string(status)), err)
+		}
+		lines := strings.Split(strings.TrimRight(string(log), "\n"), "\n")
+		if len(lines) > buildLogTailLines {
+			lines = lines[len(lines)-buildLogTailLines:]
+		}
+		return fmt.Errorf("The scan failed with exit code %s, last lines of %s:\n%s",
+			strings.TrimSpace(string(status)), ScanLogPath, strings.Join(lines, "\n"))
+	}
+
+	report, err := ref.ReadFile(ctx, client.ReadRequest{Filename: ScanReportPath})
+	if err != nil {
+		return fmt.Errorf("Failed to read the report of the scan: %v", err)
+	}
+
+	return CheckScanReport(report, s)
+}
+
+// AddScanAttestation attaches the report in the result of the scan to the
+// image of the result.
+func AddScanAttestation(res *client.Result, ref client.Reference) error {
+	return AddAttestation(res, ref, ScanReportPath, ScanPredicateType)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+// scanExec returns the exec operation of a scan, its mounts indexed by their
+// destination and the identifiers of the sources of the definition.
+func scanExec(t *testing.T, def *llb.Definition) (*pb.ExecOp, map[string]*pb.Mount, []string) {
+	m, arr := parseDef(t, def.Def)
+	var exec *pb.ExecOp
+	var execOp *pb.Op
+	for _, op := range arr {
+		if e := op.GetExec(); e != nil {
+			exec = e
+			execOp = op
+		}
+	}
+	require.NotNil(t, exec)
+	mounts := execMounts(exec)
+	var sources []string
+	for _, mount := range exec.Mounts {
+		if mount.Input < 0 {
+			continue
+		}
+		input := m[execOp.Inputs[mount.Input].Digest]
+		if src := input.GetSource(); src != nil {
+			sources = append(sources, mount.Dest+"="+src.Identifier)
+		}
+	}
+
+	return exec, mounts, sources
+}
+
+func TestScanLLB(t *testing.T) {
+	t.Run("Final image", func(t *testing.T) {
+		def, err := ScanLLB(context.TODO(), PackInstructions{Scan: Scan{Enabled: true}}, llb.Image("foo"))
+		require.NoError(t, err)
+		exec, mounts, sources := scanExec(t, def)
+		require.Equal(t, []string{"/bin/sh", "/scan-script/scan.sh"}, exec.Meta.Args)
+		require.Contains(t, sources, "/="+"docker-image://"+defaultScanImage)
+		require.Contains(t, sources, "/scan=docker-image://docker.io/library/foo:latest")
+		require.True(t, mounts["/scan"].Readonly)
+		require.Equal(t, pb.MountType_CACHE, mounts["/trivy-cache"].MountType)
+		require.Contains(t, exec.Meta.Env, "TRIVY_CACHE_DIR=/trivy-cache")
+		require.Equal(t, pb.NetMode_UNSET, exec.Network)
+	})
+	t.Run("Initrd content and custom image", func(t *testing.T) {
+		content := llb.Local("context")
+		def, err := ScanLLB(context.TODO(), PackInstructions{
+			Scan:   Scan{Enabled: true, Image: "trivy:local"},
+			Rootfs: &PackEntry{InitrdContent: &content},
+			Sources: SourceOpts{
+				Build: BuildOptions{Network: map[string]string{BuildStepScan: "host"}},
+			},
+		}, llb.Image("foo"))
+		require.NoError(t, err)
+		exec, _, sources := scanExec(t, def)
+		require.Contains(t, sources, "/=docker-image://docker.io/library/trivy:local")
+		require.Contains(t, sources, "/scan=local://context")
+		require.Equal(t, pb.NetMode_HOST, exec.Network)
+	})
+	t.Run("Invalid no scan", func(t *testing.T) {
+		_, err := ScanLLB(context.TODO(), PackInstructions{}, llb.Image("foo"))
+		require.ErrorContains(t, err, "No scan was defined")
+	})
+}
+
+// trivyReport returns a report of trivy with a vulnerability of each of the
+// given severities
+func trivyReport(severities ...string) []byte {
+	var vulns []string
+	for i, s := range severities {
+		vulns = append(vulns, fmt.Sprintf(`{"VulnerabilityID":"CVE-2026-%04d","PkgName":"pkg%d","Severity":"%s"}`, i, i, s))
+	}
+
+	return []byte(`{"Results":[{"Target":"scan","Vulnerabilities":[` + strings.Join(vulns, ",") + `]}]}`)
+}
+
+func TestScanCheckReport(t *testing.T) {
+	report := trivyReport("LOW", "HIGH", "CRITICAL", "MEDIUM")
+	t.Run("No severity", func(t *testing.T) {
+		require.NoError(t, CheckScanReport(report, Scan{Enabled: true}))
+	})
+	t.Run("Below severity", func(t *testing.T) {
+		require.NoError(t, CheckScanReport(trivyReport("LOW", "MEDIUM"), Scan{Enabled: true, Severity: "high"}))
+	})
+	t.Run("Severity or higher", func(t *testing.T) {
+		err := CheckScanReport(report, Scan{Enabled: true, Severity: "high"})
+		require.ErrorContains(t, err, "Found 2 vulnerabilities of severity HIGH or higher")
+		require.ErrorContains(t, err, "CVE-2026-0001 (HIGH) in pkg1 of scan")
+		require.ErrorContains(t, err, "CVE-2026-0002 (CRITICAL) in pkg2 of scan")
+		require.NotContains(t, err.Error(), "CVE-2026-0003")
+	})
+	t.Run("Listed vulnerabilities", func(t *testing.T) {
+		var severities []string
+		for i := 0; i < 12; i++ {
+			severities = append(severities, "CRITICAL")
+		}
+		err := CheckScanReport(trivyReport(severities...), Scan{Enabled: true, Severity: "critical"})
+		require.ErrorContains(t, err, "Found 12 vulnerabilities")
+		require.ErrorContains(t, err, "CVE-2026-0009")
+		require.NotContains(t, err.Error(), "CVE-2026-0010")
+	})
+	t.Run("Invalid report", func(t *testing.T) {
+		err := CheckScanReport([]byte("{"), Scan{Enabled: true})
+		require.ErrorContains(t, err, "Failed to parse the report of the scan")
+	})
+}
+
+func TestScanCheck(t *testing.T) {
+	t.Run("Successful scan", func(t *testing.T) {
+		ref := &fakeRef{files: map[string][]byte{
+			ScanStatusPath: []byte("0\n"),
+			ScanReportPath: trivyReport("LOW"),
+		}}
+		require.NoError(t, CheckScan(context.TODO(), ref, Scan{Enabled: true, Severity: "medium"}))
+	})
+	t.Run("Vulnerabilities", func(t *testing.T) {
+		ref := &fakeRef{files: map[string][]byte{
+			ScanStatusPath: []byte("0\n"),
+			ScanReportPath: trivyReport("MEDIUM"),
+		}}
+		err := CheckScan(context.TODO(), ref, Scan{Enabled: true, Severity: "medium"})
+		require.ErrorContains(t, err, "Found 1 vulnerabilities of severity MEDIUM or higher")
+	})
+	t.Run("Failed scan", func(t *testing.T) {
+		ref := &fakeRef{files: map[string][]byte{
+			ScanStatusPath: []byte("1\n"),
+			ScanLogPath:    []byte("FATAL: failed to download the database\n"),
+		}}
+		err := CheckScan(context.TODO(), ref, Scan{Enabled: true})
+		require.ErrorContains(t, err, "The scan failed with exit code 1")
+		require.ErrorContains(t, err, "failed to download the database")
+	})
+	t.Run("Missing status", func(t *testing.T) {
+		err := CheckScan(context.TODO(), &fakeRef{}, Scan{Enabled: true})
+		require.ErrorContains(t, err, "Failed to read the status of the scan")
+	})
+}
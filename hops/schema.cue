@@ -0,0 +1,187 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The schema of the bunnyfile. It is stricter than the checks of bunny, e.g.
+// it accepts only the known frameworks and monitors, and it is what the
+// strict-schema frontend option enforces. A bunnyfile can be validated with:
+//
+//     cue vet -d '#Bunnyfile' schema.cue bunnyfile
+package bunny
+
+#Framework: "unikraft" | "linux" | "rumprun" | "mirage" | "auto"
+
+#Monitor: "qemu" | "firecracker" | "cloud-hypervisor" | "xen" | "hvt" | "spt" | "solo5-virtio"
+
+#Arch: "amd64" | "arm64" | "arm" | "x86_64" | "aarch64"
+
+#RootfsType: "initrd" | "block" | "raw"
+
+#Flavor: "urunc" | "kraftkit" | "labels"
+
+#Severity: "unknown" | "low" | "medium" | "high" | "critical"
+
+#NetworkMode: "sandbox" | "host" | "none"
+
+#ShutdownMethod: "acpi" | "kill"
+
+#Path: =~"^/"
+
+// An entry of a list that is kept only if its condition, e.g.
+// "${DEBUG} == 1", is true with the build arguments
+#Conditional: {
+	value!: string
+	when!:  string
+}
+
+#Include: string | #Conditional | {
+	when?:     string
+	from?:     string
+	optional?: bool
+	exclude?: [...string]
+	mode?:  =~"^[0-7]{1,4}$"
+	owner?: =~"^[0-9]+:[0-9]+$"
+
+	// src and dst are aliases of source and destination
+	source?:      string
+	src?:         string
+	destination?: string
+	dst?:         string
+}
+
+#KernelArch: {
+	from?:   string
+	path?:   string
+	source?: string
+	config?: [...(string | #Conditional)]
+}
+
+#Kernel: {
+	#KernelArch
+	monitor?: #Monitor
+
+	// The fields that differ for an architecture, e.g. arm64
+	[#Arch]: #KernelArch
+}
+
+#Bunnyfile: {
+	// The syntax directive of a JSON bunnyfile
+	syntax?:  string
+	version!: string | number
+	platforms!: {
+		framework!:    #Framework
+		monitor!:      #Monitor
+		version?:      string
+		architecture?: #Arch
+	}
+	rootfs?: {
+		from?:  string
+		path?:  string
+		type?:  #RootfsType
+		owner?: =~"^[0-9]+:[0-9]+$"
+		include?: [...#Include]
+
+		// The fields that differ for an architecture, e.g. arm64
+		[#Arch]: {
+			from?:  string
+			path?:  string
+			type?:  #RootfsType
+			owner?: =~"^[0-9]+:[0-9]+$"
+			include?: [...#Include]
+		}
+		initrds?: [...{
+			name!: string
+			include!: [_, ...#Include]
+		}]
+
+		// include and initrds are mutually exclusive, as are include and path
+		if include != _|_ {
+			initrds?: _|_
+			path?:    _|_
+		}
+	}
+	// A list of kernels has one kernel per monitor
+	kernel?: #Kernel | [#Kernel & {monitor!: #Monitor}, ...#Kernel & {monitor!: #Monitor}]
+	cmdline?: string
+	cmd?: [...(string | #Conditional)]
+	entrypoint?: [...(string | #Conditional)]
+	envs?: [...(string | #Conditional)]
+	test?: {...}
+	mirrors?: [string]: string
+	artifacts?: {
+		kernel?: bool
+		rootfs?: bool
+	}
+	binary?: {
+		from?: string
+		path?: string
+	}
+	network?: {...}
+	certificates?: [...string]
+	flavors?: [...#Flavor]
+	hardened?: bool
+	build?: {
+		network?: [string]: #NetworkMode
+		timeout?: string
+	}
+	scan?: {
+		enabled?:  bool
+		image?:    string
+		severity?: #Severity
+	}
+	base?: string
+	modules?: {...}
+	dtb?: {...}
+	resources?: {
+		memory?: string
+		cpu?:    string | number
+	}
+	metadata?: {
+		authors?: [...string]
+		description?:   string
+		documentation?: string
+		author?:        string
+		created?:       string
+	}
+	shutdown?: {
+		method?: #ShutdownMethod
+		signal?: string | number
+	}
+	hooks?: {
+		image?: string
+		prePack?: [...string]
+		postPack?: [...string]
+	}
+	variant?: =~"^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$"
+	args?: [...{
+		name:      string
+		default?:  string
+		required?: bool
+	}]
+	user?:    =~"^[^:\\s]+(:[^:\\s]+)?$"
+	workdir?: #Path
+	target?: {
+		// The release of urunc that runs the image, e.g. v0.5.0
+		uruncVersion?: =~"^v?[0-9]+(\\.[0-9]+){0,2}$"
+	}
+	paths?: {
+		kernel?: #Path
+		rootfs?: #Path
+		dtb?:    #Path
+	}
+
+	// cmd replaces the deprecated cmdline
+	if cmd != _|_ {
+		cmdline?: _|_
+	}
+}
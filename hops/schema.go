@@ -0,0 +1,110 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// BunnyfileSchema is the CUE schema of the bunnyfile, for validating
+// bunnyfiles outside of bunny
+//
+//go:embed schema.cue
+var BunnyfileSchema string
+
+// The enums of the schema, which should match the ones of schema.cue
+var (
+	schemaFrameworks  = []string{unikraftName, "linux", "rumprun", "mirage", FrameworkAuto}
+	schemaMonitors    = []string{"qemu", "firecracker", MonitorCloudHypervisor, MonitorXen, "hvt", "spt", MonitorSolo5Virtio}
+	schemaArches      = []string{"amd64", "arm64", "arm", "x86_64", "aarch64"}
+	schemaRootfsTypes = []string{"initrd", "block", "raw"}
+	schemaFlavors     = []string{FlavorUrunc, FlavorKraftkit, FlavorLabels}
+	schemaSeverities  = []string{"unknown", "low", "medium", "high", "critical"}
+	schemaNetModes    = []string{"sandbox", "host", "none"}
+	schemaShutdowns   = []string{ShutdownACPI, ShutdownKill}
+)
+
+// schemaEnum returns an error for the field at the given path, if its value
+// is not one of the given values.
+func schemaEnum(path string, value string, values []string) error {
+	if slices.Contains(values, value) {
+		return nil
+	}
+
+	return fmt.Errorf("%s: %q is not one of %s", path, value, strings.Join(values, ", "))
+}
+
+// ValidateSchema checks the bunnyfile against the typed schema of
+// BunnyfileSchema, which is stricter than the rest of the checks. It reports
+// all the violations at once, each one with the path of its field. The
+// conditions are:
+// 1) framework, monitor, architecture, the type of rootfs, flavors, the
+// severity of scan and the network modes of build are one of the known values
+// 2) include of rootfs can not be combined with initrds or path
+// 3) cmd can not be combined with the deprecated cmdline
+func ValidateSchema(fileBytes []byte) error {
+	var h Hops
+	_, err := unmarshalBunnyfile(fileBytes, nil, &h)
+	if err != nil {
+		return errors.Join(errInvalidFileFormat, err)
+	}
+
+	var errs []error
+	errs = append(errs, schemaEnum("platforms.framework", h.Platform.Framework, schemaFrameworks))
+	errs = append(errs, schemaEnum("platforms.monitor", h.Platform.Monitor, schemaMonitors))
+	if h.Platform.Arch != "" {
+		errs = append(errs, schemaEnum("platforms.architecture", h.Platform.Arch, schemaArches))
+	}
+	if h.Rootfs.Type != "" {
+		errs = append(errs, schemaEnum("rootfs.type", h.Rootfs.Type, schemaRootfsTypes))
+	}
+	for _, arch := range slices.Sorted(maps.Keys(h.Rootfs.Arches)) {
+		if t := h.Rootfs.Arches[arch].Type; t != "" {
+			errs = append(errs, schemaEnum("rootfs."+arch+".type", t, schemaRootfsTypes))
+		}
+	}
+	for i, v := range h.Kernel.Variants {
+		errs = append(errs, schemaEnum(fmt.Sprintf("kernel[%d].monitor", i), v.Monitor, schemaMonitors))
+	}
+	for i, flavor := range h.Flavors {
+		errs = append(errs, schemaEnum(fmt.Sprintf("flavors[%d]", i), flavor, schemaFlavors))
+	}
+	if h.Scan.Severity != "" {
+		errs = append(errs, schemaEnum("scan.severity", h.Scan.Severity, schemaSeverities))
+	}
+	if h.Shutdown.Method != "" {
+		errs = append(errs, schemaEnum("shutdown.method", h.Shutdown.Method, schemaShutdowns))
+	}
+	for _, step := range slices.Sorted(maps.Keys(h.Build.Network)) {
+		errs = append(errs, schemaEnum("build.network."+step, h.Build.Network[step], schemaNetModes))
+	}
+
+	if len(h.Rootfs.Includes) > 0 && len(h.Rootfs.Initrds) > 0 {
+		errs = append(errs, fmt.Errorf("rootfs: include and initrds are mutually exclusive"))
+	}
+	if len(h.Rootfs.Includes) > 0 && h.Rootfs.Path != "" {
+		errs = append(errs, fmt.Errorf("rootfs: include and path are mutually exclusive"))
+	}
+	if len(h.Cmd) > 0 && h.Cmdline != "" {
+		errs = append(errs, fmt.Errorf("cmd and cmdline are mutually exclusive"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// schemaCueEnum returns the values of the given definition of schema.cue
+func schemaCueEnum(t *testing.T, def string) []string {
+	re := regexp.MustCompile(`(?m)^#` + def + `: (.*)$`)
+	m := re.FindStringSubmatch(BunnyfileSchema)
+	require.NotNil(t, m, "Missing definition #%s", def)
+	var values []string
+	for _, v := range strings.Split(m[1], "|") {
+		values = append(values, strings.Trim(strings.TrimSpace(v), `"`))
+	}
+
+	return values
+}
+
+func TestSchemaCueEnums(t *testing.T) {
+	require.Equal(t, schemaFrameworks, schemaCueEnum(t, "Framework"))
+	require.Equal(t, schemaMonitors, schemaCueEnum(t, "Monitor"))
+	require.Equal(t, schemaArches, schemaCueEnum(t, "Arch"))
+	require.Equal(t, schemaRootfsTypes, schemaCueEnum(t, "RootfsType"))
+	require.Equal(t, schemaFlavors, schemaCueEnum(t, "Flavor"))
+	require.Equal(t, schemaSeverities, schemaCueEnum(t, "Severity"))
+	require.Equal(t, schemaNetModes, schemaCueEnum(t, "NetworkMode"))
+}
+
+// schemaTests are the bunnyfiles that both ValidateSchema and the CUE schema
+// are checked against
+var schemaTests = []testInfo{
+	{
+		name:        "Valid",
+		input:       "version: v0.1\nplatforms:\n  framework: unikraft\n  monitor: qemu\n  architecture: amd64\nrootfs:\n  type: initrd\n  include:\n    - app:/app\ncmd: [\"/app\"]\n",
+		expectError: false,
+	},
+	{
+		name:        "Valid JSON",
+		input:       `{"version": "v0.1", "platforms": {"framework": "linux", "monitor": "firecracker"}, "flavors": ["urunc"]}`,
+		expectError: false,
+	},
+	{
+		name:        "Invalid unknown framework",
+		input:       "version: v0.1\nplatforms:\n  framework: foo\n  monitor: qemu\n",
+		expectError: true,
+		errorText:   `platforms.framework: "foo" is not one of unikraft, linux, rumprun, mirage, auto`,
+	},
+	{
+		name:        "Invalid monitor alias",
+		input:       "version: v0.1\nplatforms:\n  framework: linux\n  monitor: clh\n",
+		expectError: true,
+		errorText:   `platforms.monitor: "clh" is not one of`,
+	},
+	{
+		name:        "Invalid enums",
+		input:       "version: v0.1\nplatforms:\n  framework: linux\n  monitor: qemu\n  architecture: riscv64\nrootfs:\n  type: ext4\nflavors: [foo]\nscan:\n  severity: HIGH\nbuild:\n  network:\n    kernel: bridge\n",
+		expectError: true,
+		errorText:   `platforms.architecture: "riscv64"|rootfs.type: "ext4"|flavors[0]: "foo"|scan.severity: "HIGH"|build.network.kernel: "bridge"`,
+	},
+	{
+		name:        "Invalid rootfs include and initrds",
+		input:       "version: v0.1\nplatforms:\n  framework: linux\n  monitor: qemu\nrootfs:\n  path: /rootfs\n  include:\n    - app:/app\n  initrds:\n    - name: app\n      include:\n        - app:/app\n",
+		expectError: true,
+		errorText:   "include and initrds are mutually exclusive|include and path are mutually exclusive",
+	},
+	{
+		name:        "Invalid cmd and cmdline",
+		input:       "version: v0.1\nplatforms:\n  framework: linux\n  monitor: qemu\ncmdline: /app\ncmd: [\"/app\"]\n",
+		expectError: true,
+		errorText:   "cmd and cmdline are mutually exclusive",
+	},
+	{
+		name:        "Invalid kernel list without monitor",
+		input:       "version: v0.1\nplatforms:\n  framework: linux\n  monitor: qemu\nkernel:\n  - from: local\n    path: vmlinux\n  - from: local\n    path: vmlinux.fc\n    monitor: firecracker\n",
+		expectError: true,
+		errorText:   `kernel[0].monitor: "" is not one of`,
+	},
+}
+
+func TestSchemaValidate(t *testing.T) {
+	for _, tc := range schemaTests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSchema([]byte(tc.input))
+			if !tc.expectError {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, text := range strings.Split(tc.errorText, "|") {
+				require.Contains(t, err.Error(), text)
+			}
+		})
+	}
+}
+
+// TestSchemaCue evaluates schema.cue with the cue tool against the same
+// bunnyfiles as ValidateSchema, since bunny itself does not depend on CUE.
+func TestSchemaCue(t *testing.T) {
+	cue, err := exec.LookPath("cue")
+	if err != nil {
+		t.Skip("The cue tool is not installed")
+	}
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.cue")
+	require.NoError(t, os.WriteFile(schema, []byte(BunnyfileSchema), 0o644))
+
+	for _, tc := range schemaTests {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(dir, "bunnyfile.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tc.input), 0o644))
+			out, err := exec.Command(cue, "vet", "-d", "#Bunnyfile", schema, file).CombinedOutput()
+			if !tc.expectError {
+				require.NoError(t, err, string(out))
+				return
+			}
+			require.Error(t, err, "cue vet accepted the bunnyfile")
+		})
+	}
+}
+
+func TestSchemaStrictOption(t *testing.T) {
+	file := []byte(`
+version: v0.1
+platforms:
+  framework: foo
+  monitor: bar
+kernel:
+  from: local
+  path: kernel
+`)
+	_, err := ParseFile(context.TODO(), file, "context", nil, SourceOpts{})
+	require.NoError(t, err)
+	_, err = ParseFile(context.TODO(), file, "context", nil, SourceOpts{StrictSchema: true})
+	require.ErrorContains(t, err, `platforms.framework: "foo" is not one of`)
+	require.ErrorContains(t, err, `platforms.monitor: "bar" is not one of`)
+}
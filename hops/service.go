@@ -0,0 +1,185 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/moby/buildkit/client/llb"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// The maximum size of a file that the service accepts
+	serviceMaxFileSize int64 = 1 << 20
+)
+
+// Plan describes the image that a file produces, without building it
+type Plan struct {
+	// The annotations of the image
+	Annotations map[string]string `json:"annotations"`
+	// The content of the urunc.json file of the image
+	UruncJSON json.RawMessage `json:"uruncJSON"`
+	// The config of the image
+	Config ocispecs.ImageConfig `json:"config"`
+	// Why the base of a bunnyfile was chosen
+	Base *BaseDecision `json:"base,omitempty"`
+}
+
+// serviceError is the body of the responses of failed requests
+type serviceError struct {
+	Error string `json:"error"`
+}
+
+// NewPlan returns the plan of the given packing instructions
+func NewPlan(instr PackInstructions) (Plan, error) {
+	uruncJSON, err := UruncJSON(instr)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return Plan{
+		Annotations: instr.Annots,
+		UruncJSON:   uruncJSON,
+		Config:      instr.Img.Config,
+		Base:        instr.BaseDecision,
+	}, nil
+}
+
+func writeServiceJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeServiceError(w http.ResponseWriter, status int, err error) {
+	writeServiceJSON(w, status, serviceError{Error: err.Error()})
+}
+
+// readServiceFile reads the file in the body of the request. It writes the
+// error response and returns false, if the request is not valid.
+func readServiceFile(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeServiceError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %s is not allowed", r.Method))
+		return nil, false
+	}
+	file, err := io.ReadAll(http.MaxBytesReader(w, r.Body, serviceMaxFileSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeServiceError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("The file is larger than %d bytes", serviceMaxFileSize))
+			return nil, false
+		}
+		writeServiceError(w, http.StatusBadRequest, fmt.Errorf("Failed to read the file: %v", err))
+		return nil, false
+	}
+
+	return file, true
+}
+
+// NewService returns the handler of the HTTP service that exposes the
+// parsing and planning of bunny, so other tools can use it without
+// embedding the hops package. Every endpoint gets a bunnyfile or a
+// Containerfile in the body of a POST request:
+//   - /v1/validate checks a bunnyfile, strictly with strict=true
+//   - /v1/plan returns the Plan of the image
+//   - /v1/llb returns the LLB definition of the target in the target
+//     parameter, as bunny --LLB prints it
+//
+// The plan and llb endpoints take the platform of the buildkit worker in the
+// platform parameter (e.g. linux/arm64) and use the given name for the build
+// context.
+func NewService(buildContext string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v1/validate", func(w http.ResponseWriter, r *http.Request) {
+		file, ok := readServiceFile(w, r)
+		if !ok {
+			return
+		}
+		_, err := ParseBunnyfile(file)
+		if err == nil {
+			if strict, _ := strconv.ParseBool(r.URL.Query().Get("strict")); strict {
+				err = ValidateSchema(file)
+			}
+		}
+		if err != nil {
+			writeServiceError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		writeServiceJSON(w, http.StatusOK, struct {
+			Valid bool `json:"valid"`
+		}{Valid: true})
+	})
+
+	packFile := func(w http.ResponseWriter, r *http.Request) (*PackInstructions, bool) {
+		file, ok := readServiceFile(w, r)
+		if !ok {
+			return nil, false
+		}
+		arch, err := ParsePlatform(r.URL.Query().Get("platform"))
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, fmt.Errorf("Invalid platform: %v", err))
+			return nil, false
+		}
+		instr, err := ParseFile(r.Context(), file, buildContext, nil, SourceOpts{Arch: arch})
+		if err != nil {
+			writeServiceError(w, http.StatusUnprocessableEntity, err)
+			return nil, false
+		}
+
+		return instr, true
+	}
+
+	mux.HandleFunc("/v1/plan", func(w http.ResponseWriter, r *http.Request) {
+		instr, ok := packFile(w, r)
+		if !ok {
+			return
+		}
+		plan, err := NewPlan(*instr)
+		if err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeServiceJSON(w, http.StatusOK, plan)
+	})
+
+	mux.HandleFunc("/v1/llb", func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if _, err := ParseTarget(target); err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		instr, ok := packFile(w, r)
+		if !ok {
+			return
+		}
+		dt, err := TargetLLB(r.Context(), *instr, target)
+		if err != nil {
+			writeServiceError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_ = llb.WriteTo(dt, w)
+	})
+
+	return mux
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/require"
+)
+
+const serviceBunnyfile = `#syntax=harbor.nbfc.io/nubificus/bunny:latest
+version: v0.1
+platforms:
+  framework: unikraft
+  monitor: qemu
+  architecture: amd64
+kernel:
+  from: unikraft.org/nginx:1.15
+  path: /unikraft/bin/kernel
+rootfs:
+  from: harbor.nbfc.io/nubificus/nginx-rootfs:latest
+  path: /rootfs.cpio
+cmd: ["-c", "/nginx/conf/nginx.conf"]
+`
+
+func serviceRequest(method string, target string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	NewService("context").ServeHTTP(rec, req)
+
+	return rec
+}
+
+func serviceErrorText(t *testing.T, rec *httptest.ResponseRecorder) string {
+	var e serviceError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+
+	return e.Error
+}
+
+func TestServiceValidate(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		rec := serviceRequest(http.MethodPost, "/v1/validate", serviceBunnyfile)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"valid": true}`, rec.Body.String())
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		rec := serviceRequest(http.MethodPost, "/v1/validate", "version: v0.1\n")
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		require.Contains(t, serviceErrorText(t, rec), "The framework field of platforms is necessary")
+	})
+	t.Run("Strict", func(t *testing.T) {
+		file := strings.Replace(serviceBunnyfile, "monitor: qemu", "monitor: foo", 1)
+		rec := serviceRequest(http.MethodPost, "/v1/validate", file)
+		require.Equal(t, http.StatusOK, rec.Code)
+		rec = serviceRequest(http.MethodPost, "/v1/validate?strict=true", file)
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		require.Contains(t, serviceErrorText(t, rec), `platforms.monitor: "foo" is not one of`)
+	})
+	t.Run("Wrong method", func(t *testing.T) {
+		rec := serviceRequest(http.MethodGet, "/v1/validate", "")
+		require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		require.Equal(t, http.MethodPost, rec.Header().Get("Allow"))
+	})
+	t.Run("Too large", func(t *testing.T) {
+		rec := serviceRequest(http.MethodPost, "/v1/validate", strings.Repeat("#", int(serviceMaxFileSize)+1))
+		require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func TestServicePlan(t *testing.T) {
+	t.Run("Bunnyfile", func(t *testing.T) {
+		rec := serviceRequest(http.MethodPost, "/v1/plan", serviceBunnyfile)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var plan Plan
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &plan))
+		require.Equal(t, "unikraft", plan.Annotations["com.urunc.unikernel.unikernelType"])
+		require.Equal(t, "/.boot/kernel", plan.Annotations["com.urunc.unikernel.binary"])
+		require.NotNil(t, plan.Base)
+		require.Equal(t, "harbor.nbfc.io/nubificus/nginx-rootfs:latest", plan.Base.Base)
+
+		var uruncJSON map[string]string
+		require.NoError(t, json.Unmarshal(plan.UruncJSON, &uruncJSON))
+		require.Contains(t, uruncJSON, "com.urunc.unikernel.hypervisor")
+	})
+	t.Run("Invalid platform", func(t *testing.T) {
+		rec := serviceRequest(http.MethodPost, "/v1/plan?platform=linux/mips", serviceBunnyfile)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, serviceErrorText(t, rec), "Invalid platform")
+	})
+}
+
+func TestServiceLLB(t *testing.T) {
+	t.Run("Image", func(t *testing.T) {
+		rec := serviceRequest(http.MethodPost, "/v1/llb?platform=linux/amd64", serviceBunnyfile)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+		def, err := llb.ReadFrom(rec.Body)
+		require.NoError(t, err)
+		require.NotEmpty(t, def.Def)
+	})
+	t.Run("Unknown target", func(t *testing.T) {
+		rec := serviceRequest(http.MethodPost, "/v1/llb?target=foo", serviceBunnyfile)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, serviceErrorText(t, rec), "Unknown target foo")
+	})
+}
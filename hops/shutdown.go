@@ -0,0 +1,97 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The annotation with how the guest should get shut down. urunc does
+	// not define one, so it belongs to bunny.
+	ShutdownAnnotation string = "io.bunny.shutdown"
+	// The annotation with the signal that stops the container
+	StopSignalAnnotation string = "io.bunny.stopSignal"
+	// Ask the guest to power off, e.g. with the ACPI power button
+	ShutdownACPI string = "acpi"
+	// Kill the monitor, without waiting for the guest
+	ShutdownKill string = "kill"
+)
+
+var signalNameRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9]*(\+[0-9]+)?$`)
+
+// Shutdown defines how urunc stops the unikernel
+type Shutdown struct {
+	// How to shut down the guest, acpi or kill
+	Method string `yaml:"method"`
+	// The signal that stops the container, as with STOPSIGNAL
+	Signal string `yaml:"signal"`
+}
+
+// NormalizeSignal returns the signal in the form of STOPSIGNAL of the image
+// config, i.e. an upper case name with the SIG prefix (e.g. SIGTERM for term)
+// or a number.
+func NormalizeSignal(signal string) (string, error) {
+	if n, err := strconv.Atoi(signal); err == nil {
+		if n <= 0 || n > 64 {
+			return "", fmt.Errorf("Invalid signal number %d", n)
+		}
+		return signal, nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+	if !signalNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("Invalid signal %q", signal)
+	}
+
+	return "SIG" + name, nil
+}
+
+// Annotations returns the annotations of bunny for the shutdown. The signal
+// should be already normalized.
+func (s Shutdown) Annotations() map[string]string {
+	annots := map[string]string{}
+	if s.Method != "" {
+		annots[ShutdownAnnotation] = s.Method
+	}
+	if s.Signal != "" {
+		annots[StopSignalAnnotation] = s.Signal
+	}
+
+	return annots
+}
+
+// shutdownFromImage returns the shutdown of a Containerfile, from the
+// labels of bunny and the STOPSIGNAL of the image config
+func shutdownFromImage(annots map[string]string, stopSignal string) (Shutdown, error) {
+	s := Shutdown{
+		Method: annots[ShutdownAnnotation],
+		Signal: annots[StopSignalAnnotation],
+	}
+	if s.Signal == "" {
+		s.Signal = stopSignal
+	}
+	err := ValidateShutdown(s)
+	if err != nil {
+		return Shutdown{}, err
+	}
+	if s.Signal != "" {
+		s.Signal, _ = NormalizeSignal(s.Signal)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownSignal(t *testing.T) {
+	for input, expected := range map[string]string{
+		"SIGTERM":    "SIGTERM",
+		"term":       "SIGTERM",
+		"SigInt":     "SIGINT",
+		"SIGRTMIN+3": "SIGRTMIN+3",
+		"15":         "15",
+		"sigpwr":     "SIGPWR",
+	} {
+		signal, err := NormalizeSignal(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, signal, input)
+	}
+	for _, input := range []string{"", "0", "65", "-9", "SIG", "TERM!", "SIGRTMIN+"} {
+		_, err := NormalizeSignal(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestShutdownBunnyfile(t *testing.T) {
+	h, err := ParseBunnyfile([]byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+  architecture: amd64
+kernel:
+  from: local
+  path: kernel
+shutdown:
+  method: acpi
+  signal: pwr
+`))
+	require.NoError(t, err)
+	require.Equal(t, Shutdown{Method: ShutdownACPI, Signal: "SIGPWR"}, h.Shutdown)
+
+	i, err := packHops(context.TODO(), h, "context", nil, SourceOpts{Arch: "amd64"})
+	require.NoError(t, err)
+	require.Equal(t, ShutdownACPI, i.Annots[ShutdownAnnotation])
+	require.Equal(t, "SIGPWR", i.Annots[StopSignalAnnotation])
+	require.Equal(t, "SIGPWR", i.Img.Config.StopSignal)
+	require.Equal(t, "SIGPWR", i.Img.Config.Labels[StopSignalAnnotation])
+	// urunc does not define them, so they stay out of urunc.json
+	uruncJSON, err := UruncJSON(*i)
+	require.NoError(t, err)
+	require.NotContains(t, string(uruncJSON), ShutdownAnnotation)
+	require.NotContains(t, string(uruncJSON), StopSignalAnnotation)
+
+	t.Run("Without shutdown", func(t *testing.T) {
+		h.Shutdown = Shutdown{}
+		i, err := packHops(context.TODO(), h, "context", nil, SourceOpts{Arch: "amd64"})
+		require.NoError(t, err)
+		require.NotContains(t, i.Annots, ShutdownAnnotation)
+		require.NotContains(t, i.Annots, StopSignalAnnotation)
+		require.Empty(t, i.Img.Config.StopSignal)
+	})
+}
+
+func TestShutdownContainerfile(t *testing.T) {
+	containerfile := `FROM scratch
+COPY kernel /.boot/kernel
+LABEL com.urunc.unikernel.binary=/.boot/kernel
+LABEL com.urunc.unikernel.hypervisor=qemu
+LABEL io.bunny.shutdown=kill
+STOPSIGNAL SIGQUIT
+`
+	i, err := ParseContainerfile(context.TODO(), []byte(containerfile), nil, fuzzSourceOpts)
+	require.NoError(t, err)
+	require.Equal(t, ShutdownKill, i.Annots[ShutdownAnnotation])
+	require.Equal(t, "SIGQUIT", i.Annots[StopSignalAnnotation])
+	require.Equal(t, "SIGQUIT", i.Img.Config.StopSignal)
+
+	t.Run("Invalid method", func(t *testing.T) {
+		_, err := ParseContainerfile(context.TODO(), []byte(`FROM scratch
+LABEL io.bunny.shutdown=poweroff
+`), nil, fuzzSourceOpts)
+		require.ErrorContains(t, err, "The method field of shutdown should be acpi or kill, got poweroff")
+	})
+}
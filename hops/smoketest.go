@@ -0,0 +1,135 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+)
+
+const (
+	defaultQemuImage        string = "harbor.nbfc.io/nubificus/bunny/qemu:latest"
+	defaultSmokeTestTimeout string = "60s"
+	smokeTestImageDir       string = "/image"
+	smokeTestDir            string = "/test"
+)
+
+// The script that boots the unikernel and waits for the boot marker.
+// The first argument is the timeout in seconds and the rest of the
+// arguments is the monitor's command line.
+const smokeTestScript = `#!/bin/sh
+timeout="$1"
+shift
+"$@" > /tmp/boot.log 2>&1 &
+pid=$!
+elapsed=0
+while [ "$elapsed" -lt "$timeout" ]; do
+	if grep -qF -f /test/marker /tmp/boot.log; then
+		kill "$pid" 2>/dev/null
+		exit 0
+	fi
+	if ! kill -0 "$pid" 2>/dev/null; then
+		break
+	fi
+	sleep 1
+	elapsed=$((elapsed + 1))
+done
+kill "$pid" 2>/dev/null
+if grep -qF -f /test/marker /tmp/boot.log; then
+	exit 0
+fi
+cat /tmp/boot.log
+echo "Boot marker was not found within ${timeout}s" >&2
+exit 1
+`
+
+// Enabled returns true if the user has requested a smoke test
+func (t SmokeTest) Enabled() bool {
+	return t.Marker != ""
+}
+
+// GetTimeout returns the timeout of the smoke test in seconds
+func (t SmokeTest) GetTimeout() (int, error) {
+	timeout := t.Timeout
+	if timeout == "" {
+		timeout = defaultSmokeTestTimeout
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid timeout %s: %v", t.Timeout, err)
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("Timeout %s should be at least 1s", t.Timeout)
+	}
+
+	return int(d.Seconds()), nil
+}
+
+// SmokeTestLLB creates the LLB definition that boots the final image
+// under the target monitor and checks that the boot marker appears in the
+// output of the unikernel.
+func SmokeTestLLB(ctx context.Context, instr PackInstructions, image llb.State) (*llb.Definition, error) {
+	t := instr.Test
+	if !t.Enabled() {
+		return nil, fmt.Errorf("No smoke test was defined")
+	}
+	if instr.Annots["com.urunc.unikernel.hypervisor"] != "qemu" {
+		return nil, fmt.Errorf("Smoke tests are not supported for %s yet",
+			instr.Annots["com.urunc.unikernel.hypervisor"])
+	}
+
+	timeout, err := t.GetTimeout()
+	if err != nil {
+		return nil, err
+	}
+	monCmd, err := QemuCmd(instr.Annots, MonitorOpts{
+		RootDir: smokeTestImageDir,
+		KVM:     t.KVM,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	toolImage := t.Image
+	if toolImage == "" {
+		toolImage = defaultQemuImage
+	}
+	testFiles := llb.Scratch().
+		File(llb.Mkfile("/run.sh", 0755, []byte(smokeTestScript)).
+			Mkfile("/marker", 0644, []byte(t.Marker)))
+
+	args := append([]string{"/bin/sh", path.Join(smokeTestDir, "run.sh"), strconv.Itoa(timeout)}, monCmd...)
+	runOpts := []llb.RunOption{
+		llb.Args(args),
+		llb.AddMount(smokeTestImageDir, image, llb.Readonly),
+		llb.AddMount(smokeTestDir, testFiles, llb.Readonly),
+		llb.WithCustomName("Internal:Smoke test"),
+	}
+	if t.KVM {
+		runOpts = append(runOpts, llb.Security(pb.SecurityMode_INSECURE))
+	}
+	runOpts = append(runOpts, instr.Sources.Network.ProxyOptions()...)
+	runOpts = append(runOpts, hardenedOptions(instr.Sources.Hardened, false)...)
+	runOpts = append(runOpts, instr.Sources.Build.NetworkOptions(BuildStepTest)...)
+	testExec := llb.Image(toolImage).Run(runOpts...)
+
+	return marshalState(ctx, execResult(testExec, instr.Sources.Hardened), instr.Sources)
+}
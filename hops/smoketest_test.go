@@ -0,0 +1,126 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmokeTestGetTimeout(t *testing.T) {
+	t.Run("Default timeout", func(t *testing.T) {
+		timeout, err := SmokeTest{}.GetTimeout()
+		require.NoError(t, err)
+		require.Equal(t, 60, timeout)
+	})
+	t.Run("User timeout", func(t *testing.T) {
+		timeout, err := SmokeTest{Timeout: "2m"}.GetTimeout()
+		require.NoError(t, err)
+		require.Equal(t, 120, timeout)
+	})
+	t.Run("Invalid timeout", func(t *testing.T) {
+		_, err := SmokeTest{Timeout: "foo"}.GetTimeout()
+		require.ErrorContains(t, err, "Invalid timeout")
+	})
+}
+
+func TestSmokeTestLLB(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("Smoke tests are not supported in", runtime.GOARCH)
+	}
+	annots := map[string]string{
+		"com.urunc.unikernel.hypervisor": "qemu",
+		"com.urunc.unikernel.binary":     DefaultKernelPath,
+	}
+	t.Run("Default image", func(t *testing.T) {
+		instr := PackInstructions{
+			Annots: annots,
+			Test: SmokeTest{
+				Marker: "Hello",
+			},
+		}
+		def, err := SmokeTestLLB(context.TODO(), instr, llb.Image("foo"))
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var exec *pb.ExecOp
+		var sources []string
+		for _, op := range arr {
+			switch o := op.Op.(type) {
+			case *pb.Op_Exec:
+				exec = o.Exec
+			case *pb.Op_Source:
+				sources = append(sources, o.Source.Identifier)
+			}
+		}
+		require.NotNil(t, exec)
+		require.Contains(t, sources, "docker-image://"+defaultQemuImage)
+		require.Contains(t, sources, "docker-image://docker.io/library/foo:latest")
+		require.Equal(t, []string{"/bin/sh", "/test/run.sh", "60"}, exec.Meta.Args[:3])
+		require.Equal(t, pb.SecurityMode_SANDBOX, exec.Security)
+		require.Equal(t, 3, len(exec.Mounts))
+		require.Equal(t, "/image", exec.Mounts[1].Dest)
+		require.Equal(t, true, exec.Mounts[1].Readonly)
+		require.Equal(t, "/test", exec.Mounts[2].Dest)
+		require.Equal(t, true, exec.Mounts[2].Readonly)
+	})
+	t.Run("User image and kvm", func(t *testing.T) {
+		instr := PackInstructions{
+			Annots: annots,
+			Test: SmokeTest{
+				Image:  "harbor.nbfc.io/bar",
+				Marker: "Hello",
+				KVM:    true,
+			},
+		}
+		def, err := SmokeTestLLB(context.TODO(), instr, llb.Image("foo"))
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		found := false
+		for _, op := range arr {
+			if e, ok := op.Op.(*pb.Op_Exec); ok {
+				require.Equal(t, pb.SecurityMode_INSECURE, e.Exec.Security)
+				found = true
+			}
+			if s, ok := op.Op.(*pb.Op_Source); ok {
+				require.NotEqual(t, "docker-image://"+defaultQemuImage, s.Source.Identifier)
+			}
+		}
+		require.True(t, found)
+	})
+	t.Run("Invalid no marker", func(t *testing.T) {
+		instr := PackInstructions{
+			Annots: annots,
+		}
+		_, err := SmokeTestLLB(context.TODO(), instr, llb.Image("foo"))
+		require.ErrorContains(t, err, "No smoke test was defined")
+	})
+	t.Run("Invalid monitor", func(t *testing.T) {
+		instr := PackInstructions{
+			Annots: map[string]string{
+				"com.urunc.unikernel.hypervisor": "firecracker",
+			},
+			Test: SmokeTest{
+				Marker: "Hello",
+			},
+		}
+		_, err := SmokeTestLLB(context.TODO(), instr, llb.Image("foo"))
+		require.ErrorContains(t, err, "not supported for firecracker")
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+const (
+	// The key of the result metadata that contains the build summary
+	BuildSummaryMetaKey string = "bunny.build-summary"
+)
+
+// progressVertex is a vertex in the rawjson progress of buildctl
+type progressVertex struct {
+	Digest    digest.Digest `json:"digest"`
+	Name      string        `json:"name"`
+	Started   *time.Time    `json:"started,omitempty"`
+	Completed *time.Time    `json:"completed,omitempty"`
+	Cached    bool          `json:"cached,omitempty"`
+}
+
+// progressStatus is a status update in the rawjson progress of buildctl
+type progressStatus struct {
+	Vertexes []progressVertex `json:"vertexes,omitempty"`
+}
+
+// StepSummary contains the information of a single build step
+type StepSummary struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// BuildSummary contains statistics about a build
+type BuildSummary struct {
+	// The number of steps in the build
+	Steps int `json:"steps"`
+	// The number of steps that were found in the cache. It is -1, if
+	// the cache information is not available.
+	Cached int `json:"cached"`
+	// The number of steps that were executed
+	Executed int `json:"executed"`
+	// The total time of the build
+	Duration time.Duration `json:"duration"`
+	// The executed steps, the slowest first
+	Slowest []StepSummary `json:"slowest,omitempty"`
+	// How the base of the final image was chosen, if known
+	Base *BaseDecision `json:"base,omitempty"`
+	// The tool images of the build, pinned to their digests, if recorded
+	Tools []string `json:"tools,omitempty"`
+}
+
+// DefinitionSummary creates the summary of solving the given definition.
+// Buildkit does not share the cache information of each step with
+// frontends, hence only the number of steps and the duration are known.
+func DefinitionSummary(def *llb.Definition, duration time.Duration) BuildSummary {
+	steps := 0
+	if len(def.Def) > 0 {
+		// The last operation just points to the result
+		steps = len(def.Def) - 1
+	}
+
+	return BuildSummary{
+		Steps:    steps,
+		Cached:   -1,
+		Executed: -1,
+		Duration: duration,
+	}
+}
+
+// SummarizeProgress reads the progress of a build in the rawjson format of
+// buildctl and creates the summary of the build. The slowest executed steps
+// are limited to maxSlowest.
+func SummarizeProgress(r io.Reader, maxSlowest int) (BuildSummary, error) {
+	var summary BuildSummary
+	var first, last time.Time
+	vertexes := map[digest.Digest]progressVertex{}
+
+	dec := json.NewDecoder(r)
+	for {
+		var status progressStatus
+		err := dec.Decode(&status)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return summary, fmt.Errorf("Failed to decode build progress: %v", err)
+		}
+		for _, v := range status.Vertexes {
+			vertexes[v.Digest] = v
+		}
+	}
+
+	for _, v := range vertexes {
+		// Internal steps are not part of the build instructions
+		if strings.HasPrefix(v.Name, "Internal:") {
+			continue
+		}
+		summary.Steps++
+		if v.Started != nil && (first.IsZero() || v.Started.Before(first)) {
+			first = *v.Started
+		}
+		if v.Completed != nil && v.Completed.After(last) {
+			last = *v.Completed
+		}
+		if v.Cached {
+			summary.Cached++
+			continue
+		}
+		summary.Executed++
+		step := StepSummary{Name: v.Name}
+		if v.Started != nil && v.Completed != nil {
+			step.Duration = v.Completed.Sub(*v.Started)
+		}
+		summary.Slowest = append(summary.Slowest, step)
+	}
+	if !first.IsZero() && last.After(first) {
+		summary.Duration = last.Sub(first)
+	}
+
+	sort.Slice(summary.Slowest, func(i, j int) bool {
+		if summary.Slowest[i].Duration == summary.Slowest[j].Duration {
+			return summary.Slowest[i].Name < summary.Slowest[j].Name
+		}
+		return summary.Slowest[i].Duration > summary.Slowest[j].Duration
+	})
+	if len(summary.Slowest) > maxSlowest {
+		summary.Slowest = summary.Slowest[:maxSlowest]
+	}
+
+	return summary, nil
+}
+
+// String returns a human readable form of the summary
+func (s BuildSummary) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Build summary: %d steps", s.Steps)
+	if s.Cached >= 0 {
+		fmt.Fprintf(&b, ", %d cached, %d executed", s.Cached, s.Executed)
+	}
+	fmt.Fprintf(&b, " in %s\n", s.Duration.Round(time.Millisecond))
+	for _, step := range s.Slowest {
+		fmt.Fprintf(&b, "\t%s\t%s\n", step.Duration.Round(time.Millisecond), step.Name)
+	}
+	if s.Base != nil {
+		b.WriteString(s.Base.String())
+	}
+	for _, tool := range s.Tools {
+		fmt.Fprintf(&b, "Tool image: %s\n", tool)
+	}
+
+	return b.String()
+}
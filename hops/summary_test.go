@@ -0,0 +1,108 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/require"
+)
+
+// The progress of a build in the rawjson format of buildctl. Each vertex
+// gets reported multiple times, while the build goes on.
+const testProgress = `{"vertexes":[{"digest":"sha256:aaa","name":"[1/3] FROM docker.io/library/alpine","started":"2026-01-01T10:00:00Z"}]}
+{"vertexes":[{"digest":"sha256:aaa","name":"[1/3] FROM docker.io/library/alpine","started":"2026-01-01T10:00:00Z","completed":"2026-01-01T10:00:01Z","cached":true}]}
+{"vertexes":[{"digest":"sha256:bbb","name":"[2/3] COPY kernel /kernel","started":"2026-01-01T10:00:01Z","completed":"2026-01-01T10:00:03Z"},{"digest":"sha256:ccc","name":"[3/3] COPY app /app","started":"2026-01-01T10:00:01Z","completed":"2026-01-01T10:00:06Z"}]}
+{"vertexes":[{"digest":"sha256:ddd","name":"Internal:Set annotations","started":"2026-01-01T10:00:06Z","completed":"2026-01-01T10:00:30Z"}]}
+`
+
+func TestSummaryProgress(t *testing.T) {
+	t.Run("Cached and executed steps", func(t *testing.T) {
+		s, err := SummarizeProgress(strings.NewReader(testProgress), 5)
+		require.NoError(t, err)
+		require.Equal(t, 3, s.Steps)
+		require.Equal(t, 1, s.Cached)
+		require.Equal(t, 2, s.Executed)
+		require.Equal(t, 6*time.Second, s.Duration)
+		require.Equal(t, []StepSummary{
+			{Name: "[3/3] COPY app /app", Duration: 5 * time.Second},
+			{Name: "[2/3] COPY kernel /kernel", Duration: 2 * time.Second},
+		}, s.Slowest)
+	})
+	t.Run("Limit slowest steps", func(t *testing.T) {
+		s, err := SummarizeProgress(strings.NewReader(testProgress), 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(s.Slowest))
+		require.Equal(t, "[3/3] COPY app /app", s.Slowest[0].Name)
+	})
+	t.Run("Empty progress", func(t *testing.T) {
+		s, err := SummarizeProgress(strings.NewReader(""), 5)
+		require.NoError(t, err)
+		require.Equal(t, BuildSummary{}, s)
+	})
+	t.Run("Invalid progress", func(t *testing.T) {
+		_, err := SummarizeProgress(strings.NewReader("error: failed to solve\n"), 5)
+		require.ErrorContains(t, err, "Failed to decode build progress")
+	})
+}
+
+func TestSummaryDefinition(t *testing.T) {
+	st := llb.Scratch().File(llb.Mkfile("/foo", 0644, []byte("foo")))
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	s := DefinitionSummary(def, 2*time.Second)
+	require.Equal(t, 1, s.Steps)
+	require.Equal(t, -1, s.Cached)
+	require.Equal(t, -1, s.Executed)
+	require.Equal(t, 2*time.Second, s.Duration)
+}
+
+func TestSummaryString(t *testing.T) {
+	t.Run("With cache information", func(t *testing.T) {
+		s := BuildSummary{
+			Steps:    3,
+			Cached:   1,
+			Executed: 2,
+			Duration: 6 * time.Second,
+			Slowest: []StepSummary{
+				{Name: "[3/3] COPY app /app", Duration: 5 * time.Second},
+			},
+		}
+		require.Equal(t, "Build summary: 3 steps, 1 cached, 2 executed in 6s\n\t5s\t[3/3] COPY app /app\n", s.String())
+	})
+	t.Run("Without cache information", func(t *testing.T) {
+		s := BuildSummary{Steps: 4, Cached: -1, Executed: -1, Duration: 1500 * time.Millisecond}
+		require.Equal(t, "Build summary: 4 steps in 1.5s\n", s.String())
+	})
+	t.Run("With base", func(t *testing.T) {
+		s := BuildSummary{
+			Steps:    1,
+			Cached:   -1,
+			Executed: -1,
+			Duration: time.Second,
+			Base:     &BaseDecision{Base: "scratch", Reason: "set by the base field"},
+		}
+		require.Equal(t, "Build summary: 1 steps in 1s\nBase of the final image: scratch (set by the base field)\n", s.String())
+	})
+	t.Run("With tools", func(t *testing.T) {
+		s := BuildSummary{Steps: 1, Cached: -1, Executed: -1, Duration: time.Second, Tools: []string{"alpine@" + pinnedDigest}}
+		require.Equal(t, "Build summary: 1 steps in 1s\nTool image: alpine@"+pinnedDigest+"\n", s.String())
+	})
+}
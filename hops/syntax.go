@@ -0,0 +1,166 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	"github.com/distribution/reference"
+	"github.com/hashicorp/go-version"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// SyntaxImage returns the frontend image of the syntax directive of the
+// file, if there is one.
+func SyntaxImage(file []byte) (string, bool) {
+	ref, _, _, ok := parser.DetectSyntax(file)
+
+	return ref, ok
+}
+
+// SetSyntaxImage sets the frontend image of the syntax directive of the file
+// to the given image. The rest of the line of the directive stays as it is.
+// A file without a syntax directive gets one in its first line.
+func SetSyntaxImage(file []byte, image string) []byte {
+	old, _, ranges, ok := parser.DetectSyntax(file)
+	if !ok || len(ranges) == 0 {
+		return append([]byte("#syntax="+image+"\n"), file...)
+	}
+
+	lines := bytes.SplitAfter(file, []byte("\n"))
+	i := ranges[0].Start.Line - 1
+	if i < 0 || i >= len(lines) {
+		return file
+	}
+	lines[i] = bytes.Replace(lines[i], []byte(old), []byte(image), 1)
+
+	return bytes.Join(lines, nil)
+}
+
+// PinnedSyntaxImage returns the frontend image pinned to the given digest,
+// keeping the tag of the image, latest if it has none. Any previous digest
+// gets replaced.
+func PinnedSyntaxImage(image string, dgst string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse image name %s: %v", image, err)
+	}
+
+	return fmt.Sprintf("%s:%s@%s", reference.FamiliarName(named), imageTag(named), dgst), nil
+}
+
+// imageTag returns the tag of the image, latest if it has none
+func imageTag(named reference.Named) string {
+	if tagged, ok := named.(reference.Tagged); ok {
+		return tagged.Tag()
+	}
+
+	return "latest"
+}
+
+// bunnyfileVersion returns the version of the bunnyfile, or nil if the file
+// is not a bunnyfile or its version is invalid.
+func bunnyfileVersion(file []byte) *version.Version {
+	var h struct {
+		Version string `yaml:"version"`
+	}
+	if yaml.Unmarshal(file, &h) != nil || h.Version == "" {
+		return nil
+	}
+	fileVer, err := version.NewVersion(h.Version)
+	if err != nil {
+		return nil
+	}
+
+	return fileVer
+}
+
+// compatibleVersions returns true, if the major versions are the same and,
+// for a major version of 0, the minor ones too.
+func compatibleVersions(a *version.Version, b *version.Version) bool {
+	aSeg := a.Segments()
+	bSeg := b.Segments()
+
+	return aSeg[0] == bSeg[0] && (aSeg[0] != 0 || aSeg[1] == bSeg[1])
+}
+
+// SchemaWarning returns a warning, if the version of the bunnyfile is older
+// than the version that the frontend supports and they are not compatible,
+// i.e. their major versions differ, or their minor versions for a major
+// version of 0. Files that are not bunnyfiles get no warning.
+func SchemaWarning(file []byte) string {
+	fileVer := bunnyfileVersion(file)
+	if fileVer == nil {
+		return ""
+	}
+	hVer, err := version.NewVersion(Version)
+	if err != nil || !fileVer.LessThan(hVer) {
+		return ""
+	}
+
+	if !compatibleVersions(fileVer, hVer) {
+		return fmt.Sprintf("The bunnyfile uses version %s, which may not be compatible with version %s of the frontend",
+			fileVer.Original(), Version)
+	}
+
+	return ""
+}
+
+// SyntaxWarning returns a warning with what to do, if the syntax directive
+// of a bunnyfile points at a bunny image with a version tag that does not
+// match the version of the bunnyfile. The image of version vX.Y.Z supports
+// bunnyfiles up to version vX.Y. Images without a version tag (e.g. latest)
+// and files that are not bunnyfiles get no warning.
+func SyntaxWarning(file []byte) string {
+	image, ok := SyntaxImage(file)
+	if !ok {
+		return ""
+	}
+	fileVer := bunnyfileVersion(file)
+	if fileVer == nil {
+		return ""
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil || path.Base(reference.Path(named)) != "bunny" {
+		return ""
+	}
+	tagged, ok := named.(reference.Tagged)
+	if !ok {
+		return ""
+	}
+	tagVer, err := version.NewVersion(tagged.Tag())
+	if err != nil {
+		return ""
+	}
+
+	fSeg := fileVer.Segments()
+	tSeg := tagVer.Segments()
+	fileTag := fmt.Sprintf("v%d.%d", fSeg[0], fSeg[1])
+	if tSeg[0] < fSeg[0] || (tSeg[0] == fSeg[0] && tSeg[1] < fSeg[1]) {
+		return fmt.Sprintf("The bunnyfile uses version %s, but the syntax directive points at %s, which supports bunnyfiles up to version v%d.%d. "+
+			"Point the syntax directive at a newer image (e.g. with bunny pin --tag %s) or lower the version of the bunnyfile",
+			fileVer.Original(), image, tSeg[0], tSeg[1], fileTag)
+	}
+	if !compatibleVersions(fileVer, tagVer) {
+		return fmt.Sprintf("The bunnyfile uses version %s, but the syntax directive points at %s, which may not be compatible with it. "+
+			"Update the bunnyfile to version v%d.%d or point the syntax directive at an older image (e.g. with bunny pin --tag %s)",
+			fileVer.Original(), image, tSeg[0], tSeg[1], fileTag)
+	}
+
+	return ""
+}
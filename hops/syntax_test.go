@@ -0,0 +1,109 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const syntaxBunnyfile = `#syntax=harbor.nbfc.io/nubificus/bunny:latest   # [1] Set bunnyfile syntax
+version: v0.1
+
+platforms:
+  framework: unikraft
+  monitor: qemu
+`
+
+func TestSyntaxImage(t *testing.T) {
+	image, ok := SyntaxImage([]byte(syntaxBunnyfile))
+	require.True(t, ok)
+	require.Equal(t, "harbor.nbfc.io/nubificus/bunny:latest", image)
+
+	_, ok = SyntaxImage([]byte("version: v0.1\n"))
+	require.False(t, ok)
+}
+
+func TestSyntaxSetImage(t *testing.T) {
+	t.Run("Replace directive", func(t *testing.T) {
+		out := SetSyntaxImage([]byte(syntaxBunnyfile), "harbor.nbfc.io/nubificus/bunny:v0.2")
+		expected := `#syntax=harbor.nbfc.io/nubificus/bunny:v0.2   # [1] Set bunnyfile syntax
+version: v0.1
+`
+		require.Equal(t, expected, string(out[:len(expected)]))
+		image, _ := SyntaxImage(out)
+		require.Equal(t, "harbor.nbfc.io/nubificus/bunny:v0.2", image)
+	})
+	t.Run("Add directive", func(t *testing.T) {
+		out := SetSyntaxImage([]byte("version: v0.1\n"), "harbor.nbfc.io/nubificus/bunny:v0.2")
+		require.Equal(t, "#syntax=harbor.nbfc.io/nubificus/bunny:v0.2\nversion: v0.1\n", string(out))
+	})
+}
+
+func TestSyntaxPinnedImage(t *testing.T) {
+	dgst := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	pinned, err := PinnedSyntaxImage("harbor.nbfc.io/nubificus/bunny:latest", dgst)
+	require.NoError(t, err)
+	require.Equal(t, "harbor.nbfc.io/nubificus/bunny:latest@"+dgst, pinned)
+
+	// An older digest gets replaced
+	pinned, err = PinnedSyntaxImage("harbor.nbfc.io/nubificus/bunny:v0.1@"+dgst, dgst)
+	require.NoError(t, err)
+	require.Equal(t, "harbor.nbfc.io/nubificus/bunny:v0.1@"+dgst, pinned)
+
+	pinned, err = PinnedSyntaxImage("harbor.nbfc.io/nubificus/bunny", dgst)
+	require.NoError(t, err)
+	require.Equal(t, "harbor.nbfc.io/nubificus/bunny:latest@"+dgst, pinned)
+
+	_, err = PinnedSyntaxImage("Invalid Image", dgst)
+	require.ErrorContains(t, err, "Failed to parse image name")
+}
+
+func TestSyntaxSchemaWarning(t *testing.T) {
+	require.Empty(t, SchemaWarning([]byte(syntaxBunnyfile)))
+	require.Empty(t, SchemaWarning([]byte("FROM scratch\n")))
+	require.Empty(t, SchemaWarning([]byte("version: v0.1.3\n")))
+	require.Contains(t, SchemaWarning([]byte("version: v0.0.9\n")), "version v0.0.9, which may not be compatible")
+}
+
+func TestSyntaxWarning(t *testing.T) {
+	file := func(image string, version string) []byte {
+		return []byte("#syntax=" + image + "\nversion: " + version + "\n")
+	}
+
+	require.Empty(t, SyntaxWarning([]byte(syntaxBunnyfile)))
+	require.Empty(t, SyntaxWarning([]byte("version: v0.2\n")))
+	require.Empty(t, SyntaxWarning(file("docker/dockerfile:1.4", "v0.2")))
+	require.Empty(t, SyntaxWarning([]byte("#syntax=harbor.nbfc.io/nubificus/bunny:v0.1\nFROM scratch\n")))
+	require.Empty(t, SyntaxWarning(file("harbor.nbfc.io/nubificus/bunny:v0.1.3", "v0.1")))
+	require.Empty(t, SyntaxWarning(file("harbor.nbfc.io/nubificus/bunny:v0.1.3@sha256:"+strings.Repeat("a", 64), "v0.1")))
+	require.Empty(t, SyntaxWarning(file("harbor.nbfc.io/nubificus/bunny:v1.3", "v1.1")))
+
+	require.Equal(t, "The bunnyfile uses version v0.2, but the syntax directive points at harbor.nbfc.io/nubificus/bunny:v0.1.3, "+
+		"which supports bunnyfiles up to version v0.1. Point the syntax directive at a newer image (e.g. with bunny pin --tag v0.2) "+
+		"or lower the version of the bunnyfile", SyntaxWarning(file("harbor.nbfc.io/nubificus/bunny:v0.1.3", "v0.2")))
+	require.Equal(t, "The bunnyfile uses version v0.1, but the syntax directive points at harbor.nbfc.io/nubificus/bunny:v0.3, "+
+		"which may not be compatible with it. Update the bunnyfile to version v0.3 or point the syntax directive at an older image "+
+		"(e.g. with bunny pin --tag v0.1)", SyntaxWarning(file("harbor.nbfc.io/nubificus/bunny:v0.3", "v0.1")))
+}
+
+func TestSyntaxWarningParse(t *testing.T) {
+	_, err := ParseBunnyfile([]byte("#syntax=harbor.nbfc.io/nubificus/bunny:v0.1\nversion: v0.2\n"))
+	require.ErrorContains(t, err, "Unsupported version v0.2")
+	require.ErrorContains(t, err, "bunny pin --tag v0.2")
+	require.ErrorIs(t, err, errInvalidBunnyfile)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+const (
+	// Build the final image
+	TargetImage string = "image"
+	// Build only the kernel
+	TargetKernel string = "kernel"
+	// Build only the rootfs
+	TargetRootfs string = "rootfs"
+)
+
+// ParseTarget checks that the given target is supported. An empty target
+// means the final image.
+func ParseTarget(target string) (string, error) {
+	switch target {
+	case "", TargetImage:
+		return TargetImage, nil
+	case TargetKernel, TargetRootfs:
+		return target, nil
+	default:
+		return "", fmt.Errorf("Unknown target %s, expected one of %s, %s, %s",
+			target, TargetImage, TargetKernel, TargetRootfs)
+	}
+}
+
+// entryState returns a state that contains only the file of the given entry
+// in its root directory. An entry without a file path is already a directory
+// tree (e.g. a raw rootfs) and it is returned as is. A built kernel is also
+// returned as is, to keep the log of the build next to it.
+func entryState(entry PackEntry) llb.State {
+	if entry.FilePath == "" || entry.SourceRef == KernelFromBuild {
+		return entry.SourceState
+	}
+
+	return CopyLLB(llb.Scratch(), PackCopies{
+		SrcState: entry.SourceState,
+		SrcPath:  entry.FilePath,
+		DstPath:  "/" + path.Base(entry.FilePath),
+	})
+}
+
+// TargetLLB creates the LLB definition of the given target. For the kernel
+// and rootfs targets, the result contains only the respective artifact.
+func TargetLLB(ctx context.Context, instr PackInstructions, target string) (*llb.Definition, error) {
+	target, err := ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var st llb.State
+	switch target {
+	case TargetImage:
+		return PackLLB(ctx, instr)
+	case TargetKernel:
+		if instr.Kernel == nil {
+			return nil, fmt.Errorf("The %s target is only supported for bunnyfiles", target)
+		}
+		st = entryState(*instr.Kernel)
+	case TargetRootfs:
+		if instr.Rootfs == nil {
+			return nil, fmt.Errorf("The %s target is only supported for bunnyfiles", target)
+		}
+		if instr.Rootfs.SourceRef == "" {
+			return nil, fmt.Errorf("The bunnyfile does not define a rootfs")
+		}
+		st = entryState(*instr.Rootfs)
+	}
+
+	return marshalState(ctx, st, instr.Sources)
+}
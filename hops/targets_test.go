@@ -0,0 +1,158 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetsParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{name: "Empty", input: "", expected: TargetImage},
+		{name: "Image", input: "image", expected: TargetImage},
+		{name: "Kernel", input: "kernel", expected: TargetKernel},
+		{name: "Rootfs", input: "rootfs", expected: TargetRootfs},
+		{name: "Unknown", input: "initrd", expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := ParseTarget(tc.input)
+			if tc.expectError {
+				require.ErrorContains(t, err, "Unknown target initrd")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, target)
+		})
+	}
+}
+
+func targetHops() *Hops {
+	return &Hops{
+		Platform: Platform{
+			Framework: "unikraft",
+			Monitor:   "qemu",
+		},
+		Kernel: Kernel{
+			From: "local",
+			Path: "build/app_qemu-x86_64",
+		},
+		Rootfs: Rootfs{
+			From: "harbor.nbfc.io/foo",
+			Path: "/rootfs.cpio",
+		},
+	}
+}
+
+// targetCopy returns the single copy operation of the definition
+func targetCopy(t *testing.T, def [][]byte) *pb.FileActionCopy {
+	_, arr := parseDef(t, def)
+	var copies []*pb.FileActionCopy
+	for _, op := range arr {
+		file := op.GetFile()
+		if file == nil {
+			continue
+		}
+		for _, action := range file.Actions {
+			if c := action.GetCopy(); c != nil {
+				copies = append(copies, c)
+			}
+		}
+	}
+	require.Equal(t, 1, len(copies))
+
+	return copies[0]
+}
+
+func TestTargetsLLB(t *testing.T) {
+	t.Run("Kernel", func(t *testing.T) {
+		i, err := ToPack(context.TODO(), targetHops(), "context")
+		require.NoError(t, err)
+		def, err := TargetLLB(context.TODO(), *i, TargetKernel)
+		require.NoError(t, err)
+		c := targetCopy(t, def.Def)
+		require.Equal(t, "/build/app_qemu-x86_64", c.Src)
+		require.Equal(t, "/app_qemu-x86_64", c.Dest)
+		m, _ := parseDef(t, def.Def)
+		for _, op := range m {
+			if src := op.GetSource(); src != nil {
+				require.Equal(t, "local://context", src.Identifier)
+			}
+		}
+	})
+	t.Run("Rootfs", func(t *testing.T) {
+		i, err := ToPack(context.TODO(), targetHops(), "context")
+		require.NoError(t, err)
+		def, err := TargetLLB(context.TODO(), *i, TargetRootfs)
+		require.NoError(t, err)
+		c := targetCopy(t, def.Def)
+		require.Equal(t, "/rootfs.cpio", c.Src)
+		require.Equal(t, "/rootfs.cpio", c.Dest)
+	})
+	t.Run("Raw rootfs", func(t *testing.T) {
+		h := targetHops()
+		h.Rootfs.Path = ""
+		h.Rootfs.Type = "raw"
+		i, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		def, err := TargetLLB(context.TODO(), *i, TargetRootfs)
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		require.Equal(t, 2, len(arr))
+		require.Equal(t, "docker-image://harbor.nbfc.io/foo:latest", arr[0].GetSource().Identifier)
+	})
+	t.Run("No rootfs", func(t *testing.T) {
+		h := targetHops()
+		h.Rootfs = Rootfs{}
+		i, err := ToPack(context.TODO(), h, "context")
+		require.NoError(t, err)
+		_, err = TargetLLB(context.TODO(), *i, TargetRootfs)
+		require.ErrorContains(t, err, "does not define a rootfs")
+	})
+	t.Run("Image", func(t *testing.T) {
+		i, err := ToPack(context.TODO(), targetHops(), "context")
+		require.NoError(t, err)
+		def, err := TargetLLB(context.TODO(), *i, TargetImage)
+		require.NoError(t, err)
+		_, arr := parseDef(t, def.Def)
+		var files []string
+		for _, op := range arr {
+			if file := op.GetFile(); file != nil {
+				if mkfile := file.Actions[0].GetMkfile(); mkfile != nil {
+					files = append(files, mkfile.Path)
+				}
+			}
+		}
+		require.Equal(t, []string{"/urunc.json"}, files)
+	})
+	t.Run("Containerfile", func(t *testing.T) {
+		_, err := TargetLLB(context.TODO(), PackInstructions{}, TargetKernel)
+		require.ErrorContains(t, err, "only supported for bunnyfiles")
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := TargetLLB(context.TODO(), PackInstructions{}, "foo")
+		require.ErrorContains(t, err, "Unknown target foo")
+	})
+}
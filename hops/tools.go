@@ -0,0 +1,142 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+const (
+	// The annotation with the tool images of the build, pinned to the
+	// digests that the build used, e.g.
+	// harbor.nbfc.io/nubificus/bunny/libarchive:latest@sha256:...
+	ToolsAnnotation string = "io.bunny.tools"
+)
+
+// ToolDigests records the digests of the tool images that a build runs and,
+// with an allowlist, rejects the digests that are not in it. All the steps of
+// a build share it, so it is safe for concurrent use.
+type ToolDigests struct {
+	mu sync.Mutex
+	// The digests that the tool images can have, any digest if nil
+	allowed map[digest.Digest]bool
+	// The digest of each tool image
+	pinned map[string]digest.Digest
+}
+
+// NewToolDigests returns a recorder of the digests of the tool images, which
+// allows only the given digests, unless they are nil.
+func NewToolDigests(allowed map[digest.Digest]bool) *ToolDigests {
+	return &ToolDigests{
+		allowed: allowed,
+		pinned:  map[string]digest.Digest{},
+	}
+}
+
+// ParseToolAllowlist parses a file with the allowed digests of the tool
+// images, one in each line, either as a digest or as a pinned reference
+// (e.g. alpine:3.20@sha256:...). Empty lines and lines starting with # are
+// skipped.
+func ParseToolAllowlist(data []byte) (map[digest.Digest]bool, error) {
+	allowed := map[digest.Digest]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(NormalizeFile(data)))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.LastIndex(line, "@"); i >= 0 {
+			line = line[i+1:]
+		}
+		dgst, err := digest.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: Invalid digest %q: %v", lineNum, line, err)
+		}
+		allowed[dgst] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("The allowlist of tool digests is empty")
+	}
+
+	return allowed, nil
+}
+
+// Empty returns a new record of tool digests with the same allowlist, for
+// another build. It returns nil, if t is nil.
+func (t *ToolDigests) Empty() *ToolDigests {
+	if t == nil {
+		return nil
+	}
+
+	return NewToolDigests(t.allowed)
+}
+
+// Record keeps the digest of the given tool image. It returns an error, if
+// the digest is not in the allowlist.
+func (t *ToolDigests) Record(ref string, dgst digest.Digest) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.allowed != nil && !t.allowed[dgst] {
+		return fmt.Errorf("The digest %s of the tool image %s is not in the allowlist", dgst, ref)
+	}
+	t.pinned[ref] = dgst
+
+	return nil
+}
+
+// Pinned returns the recorded tool images, pinned to their digests and
+// sorted
+func (t *ToolDigests) Pinned() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pinned := make([]string, 0, len(t.pinned))
+	for ref, dgst := range t.pinned {
+		pinned = append(pinned, ref+"@"+dgst.String())
+	}
+	slices.Sort(pinned)
+
+	return pinned
+}
+
+// SetToolsAnnotation records the tool images of the build, pinned to their
+// digests, in the annotations of the image. Like the rest of the build
+// information, the annotation does not reach urunc.json.
+func (i *PackInstructions) SetToolsAnnotation(t *ToolDigests) {
+	if t == nil {
+		return
+	}
+	pinned := t.Pinned()
+	if len(pinned) == 0 {
+		return
+	}
+	if i.Annots == nil {
+		i.Annots = make(map[string]string)
+	}
+	i.Annots[ToolsAnnotation] = strings.Join(pinned, ",")
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+const otherDigest = "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+
+func TestToolsAllowlist(t *testing.T) {
+	allowed, err := ParseToolAllowlist([]byte(`# The tools of the release
+` + pinnedDigest + `
+
+  harbor.nbfc.io/nubificus/bunny/libarchive:latest@` + otherDigest + `
+`))
+	require.NoError(t, err)
+	require.Equal(t, map[digest.Digest]bool{pinnedDigest: true, otherDigest: true}, allowed)
+
+	_, err = ParseToolAllowlist([]byte("# nothing\n"))
+	require.ErrorContains(t, err, "The allowlist of tool digests is empty")
+	_, err = ParseToolAllowlist([]byte(pinnedDigest + "\nalpine:latest\n"))
+	require.ErrorContains(t, err, `line 2: Invalid digest "alpine:latest"`)
+}
+
+func TestToolsRecord(t *testing.T) {
+	tools := NewToolDigests(nil)
+	require.NoError(t, tools.Record("docker.io/library/busybox:latest", otherDigest))
+	require.NoError(t, tools.Record("docker.io/library/alpine:3.20", pinnedDigest))
+	require.Equal(t, []string{
+		"docker.io/library/alpine:3.20@" + pinnedDigest,
+		"docker.io/library/busybox:latest@" + otherDigest,
+	}, tools.Pinned())
+
+	instr := PackInstructions{}
+	instr.SetToolsAnnotation(tools)
+	require.Equal(t, "docker.io/library/alpine:3.20@"+pinnedDigest+",docker.io/library/busybox:latest@"+otherDigest,
+		instr.Annots[ToolsAnnotation])
+	require.False(t, IsUruncAnnotation(ToolsAnnotation))
+
+	t.Run("No tools", func(t *testing.T) {
+		instr := PackInstructions{}
+		instr.SetToolsAnnotation(nil)
+		instr.SetToolsAnnotation(NewToolDigests(nil))
+		require.NotContains(t, instr.Annots, ToolsAnnotation)
+	})
+	t.Run("Allowlist", func(t *testing.T) {
+		tools := NewToolDigests(map[digest.Digest]bool{pinnedDigest: true})
+		require.NoError(t, tools.Record("alpine", pinnedDigest))
+		require.ErrorContains(t, tools.Record("busybox", otherDigest),
+			"The digest "+otherDigest+" of the tool image busybox is not in the allowlist")
+	})
+}
+
+func TestToolsPin(t *testing.T) {
+	tool := llb.Image("alpine:3.20").Run(llb.Shlex("true")).Root()
+	pinned := llb.Image("debian@" + otherDigest).Run(llb.Shlex("true")).Root()
+	st := llb.Merge([]llb.State{tool, pinned})
+
+	t.Run("Without hardened mode", func(t *testing.T) {
+		r := &pinResolver{}
+		tools := NewToolDigests(nil)
+		def, err := marshalState(context.TODO(), st, SourceOpts{Resolver: r, Tools: tools})
+		require.NoError(t, err)
+		require.Equal(t, []string{"docker.io/library/alpine:3.20"}, r.refs)
+		require.Contains(t, sourceIdentifiers(t, def), "docker-image://docker.io/library/alpine:3.20@"+pinnedDigest)
+		require.Equal(t, []string{
+			"docker.io/library/alpine:3.20@" + pinnedDigest,
+			"docker.io/library/debian@" + otherDigest,
+		}, tools.Pinned())
+	})
+	t.Run("Not in the allowlist", func(t *testing.T) {
+		tools := NewToolDigests(map[digest.Digest]bool{pinnedDigest: true})
+		_, err := marshalState(context.TODO(), st, SourceOpts{Resolver: &pinResolver{}, Tools: tools})
+		require.ErrorContains(t, err, "The digest "+otherDigest+" of the tool image docker.io/library/debian is not in the allowlist")
+	})
+}
+
+func TestToolsParseFile(t *testing.T) {
+	file := []byte(`
+version: v0.1
+platforms:
+  framework: unikraft
+  monitor: qemu
+  architecture: amd64
+kernel:
+  from: local
+  path: kernel
+`)
+	tools := NewToolDigests(map[digest.Digest]bool{pinnedDigest: true})
+	i, err := ParseFile(context.TODO(), file, "context", nil, SourceOpts{Tools: tools, StrictSchema: true})
+	require.NoError(t, err)
+	require.Same(t, tools, i.Sources.Tools)
+	require.True(t, i.Sources.StrictSchema)
+}
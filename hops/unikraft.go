@@ -15,6 +15,7 @@
 package hops
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/moby/buildkit/client/llb"
@@ -68,7 +69,7 @@ func (i *UnikraftInfo) SupportsFsType(string) bool {
 
 func (i *UnikraftInfo) SupportsMonitor(monitor string) bool {
 	switch monitor {
-	case "qemu", "firecracker":
+	case "qemu", "firecracker", MonitorXen:
 		return true
 	default:
 		return false
@@ -86,32 +87,33 @@ func (i *UnikraftInfo) SupportsArch(arch string) bool {
 	}
 }
 
-func (i *UnikraftInfo) CreateRootfs(buildContext string) (llb.State, error) {
+func (i *UnikraftInfo) CreateRootfs(_ context.Context, in BuildInput) (llb.State, error) {
 	switch i.Rootfs.Type {
 	case "initrd":
-		contentState := FilesLLB(i.Rootfs.Includes, buildContext, llb.Scratch())
-		return InitrdLLB(contentState), nil
+		contentState := FilesLLB(i.Rootfs.Includes, in.BuildContext, llb.Scratch(), excludeOptions(in.Exclude)...)
+		initrdOpts := append(hardenedOptions(in.Hardened, false), in.Build.NetworkOptions(BuildStepInitrd)...)
+		return InitrdLLB(contentState, in.Owner, initrdOpts...), nil
 	case "raw":
-		return FilesLLB(i.Rootfs.Includes, buildContext, llb.Scratch()), nil
+		return FilesLLB(i.Rootfs.Includes, in.BuildContext, llb.Scratch(), excludeOptions(in.Exclude)...), nil
 	default:
 		// We should never reach this point
 		return llb.Scratch(), fmt.Errorf("Unsupported rootfs type")
 	}
 }
 
-func (i *UnikraftInfo) UpdateRootfs(buildContext string) (llb.State, error) {
+func (i *UnikraftInfo) UpdateRootfs(_ context.Context, in BuildInput) (llb.State, error) {
 	base := llb.Image(i.Rootfs.From)
 	switch i.Rootfs.Type {
 	case "initrd":
 		return llb.Scratch(), fmt.Errorf("Can not update an initrd rootfs")
 	case "raw":
-		return FilesLLB(i.Rootfs.Includes, buildContext, base), nil
+		return FilesLLB(i.Rootfs.Includes, in.BuildContext, base, excludeOptions(in.Exclude)...), nil
 	default:
 		// We should never reach this point
 		return llb.Scratch(), fmt.Errorf("Unsupported rootfs type")
 	}
 }
 
-func (i *UnikraftInfo) BuildKernel(_ string) llb.State {
-	return llb.Scratch()
+func (i *UnikraftInfo) BuildKernel(_ context.Context, in BuildInput) (llb.State, error) {
+	return KraftBuildLLB(in, i.Arch)
 }
@@ -0,0 +1,119 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// Pull the latest tag of images without one
+	UnikraftPullLatest string = "latest"
+	// Require an explicit tag or digest
+	UnikraftPullTagged string = "tagged"
+	// Resolve the tag of every image to its current digest
+	UnikraftPullPinned string = "pinned"
+)
+
+// ParseUnikraftPull checks that the given mode of pulling images from the
+// unikraft.org catalog is supported. An empty mode means the latest mode.
+func ParseUnikraftPull(mode string) (string, error) {
+	switch mode {
+	case "", UnikraftPullLatest:
+		return UnikraftPullLatest, nil
+	case UnikraftPullTagged, UnikraftPullPinned:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Unknown unikraft pull mode %s, expected one of %s, %s, %s",
+			mode, UnikraftPullLatest, UnikraftPullTagged, UnikraftPullPinned)
+	}
+}
+
+// unikraftPullRef applies the pull mode to a reference of the unikraft.org
+// catalog. It returns the reference to pull and its tag, if the reference
+// has an explicit one. References outside the catalog are left as is.
+func unikraftPullRef(ctx context.Context, ref string, p Platform, opts SourceOpts) (string, string, error) {
+	if !isUnikraftRef(ref) {
+		return ref, "", nil
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to parse image name %s: %v", ref, err)
+	}
+	tag := ""
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+	_, digested := named.(reference.Digested)
+
+	switch opts.UnikraftPull {
+	case UnikraftPullTagged:
+		if tag == "" && !digested {
+			return "", "", fmt.Errorf("The image %s from %s needs an explicit tag or digest", ref, unikraftHub)
+		}
+	case UnikraftPullPinned:
+		if digested {
+			break
+		}
+		if opts.Resolver == nil {
+			return "", "", fmt.Errorf("Pinning the images of %s requires bunny to run as a frontend", unikraftHub)
+		}
+		tagged := reference.TagNameOnly(named).String()
+		platform := ocispecs.Platform{
+			OS:           monitorPlatformOS(p.Monitor),
+			Architecture: normalizeArch(p.Arch),
+		}
+		_, dgst, _, err := opts.MetaResolver(opts.Resolver).ResolveImageConfig(ctx, tagged, sourceresolver.Opt{
+			LogName: "pinning unikraft image " + tagged,
+			ImageOpt: &sourceresolver.ResolveImageOpt{
+				Platform: &platform,
+			},
+		})
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to resolve the digest of %s: %v", tagged, err)
+		}
+		ref = ref + "@" + dgst.String()
+	}
+
+	return ref, tag, nil
+}
+
+// ApplyUnikraftPull applies the pull mode of the options to the images of
+// the bunnyfile that come from the unikraft.org catalog. Unless the bunnyfile
+// sets the version of the platform, the tag of the kernel becomes the version
+// of the unikernel.
+func ApplyUnikraftPull(ctx context.Context, h *Hops, opts SourceOpts) error {
+	from, tag, err := unikraftPullRef(ctx, h.Kernel.From, h.Platform, opts)
+	if err != nil {
+		return err
+	}
+	h.Kernel.From = from
+	if h.Platform.Version == "" && tag != "" && tag != "latest" {
+		h.Platform.Version = tag
+	}
+
+	h.Rootfs.From, _, err = unikraftPullRef(ctx, h.Rootfs.From, h.Platform, opts)
+	if err != nil {
+		return err
+	}
+	h.Base, _, err = unikraftPullRef(ctx, h.Base, h.Platform, opts)
+
+	return err
+}
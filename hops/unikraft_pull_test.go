@@ -0,0 +1,118 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func unikraftPullHops(from string) *Hops {
+	return &Hops{
+		Platform: Platform{
+			Framework: "unikraft",
+			Monitor:   "qemu",
+			Arch:      "amd64",
+		},
+		Kernel: Kernel{
+			From: from,
+			Path: "/unikraft/bin/kernel",
+		},
+		Rootfs: Rootfs{
+			From: "scratch",
+		},
+	}
+}
+
+func TestUnikraftPullParse(t *testing.T) {
+	mode, err := ParseUnikraftPull("")
+	require.NoError(t, err)
+	require.Equal(t, UnikraftPullLatest, mode)
+	for _, m := range []string{UnikraftPullLatest, UnikraftPullTagged, UnikraftPullPinned} {
+		mode, err = ParseUnikraftPull(m)
+		require.NoError(t, err)
+		require.Equal(t, m, mode)
+	}
+	_, err = ParseUnikraftPull("always")
+	require.ErrorContains(t, err, "Unknown unikraft pull mode always")
+}
+
+func TestUnikraftPullApply(t *testing.T) {
+	t.Run("Latest", func(t *testing.T) {
+		h := unikraftPullHops("unikraft.org/nginx")
+		err := ApplyUnikraftPull(context.TODO(), h, SourceOpts{UnikraftPull: UnikraftPullLatest})
+		require.NoError(t, err)
+		require.Equal(t, "unikraft.org/nginx", h.Kernel.From)
+		require.Empty(t, h.Platform.Version)
+	})
+	t.Run("Version from tag", func(t *testing.T) {
+		h := unikraftPullHops("unikraft.org/nginx:1.25")
+		err := ApplyUnikraftPull(context.TODO(), h, SourceOpts{UnikraftPull: UnikraftPullLatest})
+		require.NoError(t, err)
+		require.Equal(t, "1.25", h.Platform.Version)
+
+		h = unikraftPullHops("unikraft.org/nginx:1.25")
+		h.Platform.Version = "v2"
+		err = ApplyUnikraftPull(context.TODO(), h, SourceOpts{})
+		require.NoError(t, err)
+		require.Equal(t, "v2", h.Platform.Version)
+	})
+	t.Run("Tagged", func(t *testing.T) {
+		opts := SourceOpts{UnikraftPull: UnikraftPullTagged}
+		err := ApplyUnikraftPull(context.TODO(), unikraftPullHops("unikraft.org/nginx"), opts)
+		require.ErrorContains(t, err, "needs an explicit tag or digest")
+		err = ApplyUnikraftPull(context.TODO(), unikraftPullHops("unikraft.org/nginx:1.25"), opts)
+		require.NoError(t, err)
+		err = ApplyUnikraftPull(context.TODO(), unikraftPullHops("unikraft.org/nginx@"+pinnedDigest), opts)
+		require.NoError(t, err)
+		// Images outside the catalog are left as is
+		err = ApplyUnikraftPull(context.TODO(), unikraftPullHops("harbor.nbfc.io/nubificus/nginx"), opts)
+		require.NoError(t, err)
+	})
+	t.Run("Pinned", func(t *testing.T) {
+		r := &pinResolver{}
+		h := unikraftPullHops("unikraft.org/nginx")
+		h.Base = "unikraft.org/base:0.1"
+		err := ApplyUnikraftPull(context.TODO(), h, SourceOpts{UnikraftPull: UnikraftPullPinned, Resolver: r})
+		require.NoError(t, err)
+		require.Equal(t, []string{"unikraft.org/nginx:latest", "unikraft.org/base:0.1"}, r.refs)
+		require.Equal(t, "unikraft.org/nginx@"+pinnedDigest, h.Kernel.From)
+		require.Equal(t, "unikraft.org/base:0.1@"+pinnedDigest, h.Base)
+		require.Equal(t, "scratch", h.Rootfs.From)
+		require.Empty(t, h.Platform.Version)
+	})
+	t.Run("Pinned without resolver", func(t *testing.T) {
+		err := ApplyUnikraftPull(context.TODO(), unikraftPullHops("unikraft.org/nginx:1.25"), SourceOpts{UnikraftPull: UnikraftPullPinned})
+		require.ErrorContains(t, err, "requires bunny to run as a frontend")
+	})
+	t.Run("Pinned resolver error", func(t *testing.T) {
+		r := &pinResolver{err: fmt.Errorf("not found")}
+		err := ApplyUnikraftPull(context.TODO(), unikraftPullHops("unikraft.org/nginx:1.25"), SourceOpts{UnikraftPull: UnikraftPullPinned, Resolver: r})
+		require.ErrorContains(t, err, "Failed to resolve the digest of unikraft.org/nginx:1.25")
+	})
+}
+
+func TestUnikraftPullAnnotation(t *testing.T) {
+	h := unikraftPullHops("unikraft.org/nginx:1.25")
+	err := ApplyUnikraftPull(context.TODO(), h, SourceOpts{UnikraftPull: UnikraftPullPinned, Resolver: &pinResolver{}})
+	require.NoError(t, err)
+	i, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, "1.25", i.Annots["com.urunc.unikernel.unikernelVersion"])
+	require.Equal(t, "unikraft.org/nginx:1.25@"+pinnedDigest, i.KernelCheck.Ref)
+}
@@ -16,8 +16,10 @@ package hops
 
 import (
 	"context"
+	"strings"
 	"testing"
 
+	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/stretchr/testify/require"
 )
@@ -177,7 +179,7 @@ func TestUnikraftCreateRootfs(t *testing.T) {
 		}
 
 		unikraft := NewUnikraft(plat, rootfs)
-		state, err := unikraft.CreateRootfs("context")
+		state, err := unikraft.CreateRootfs(context.TODO(), BuildInput{BuildContext: "context"})
 		require.NoError(t, err)
 		def, err := state.Marshal(context.TODO())
 
@@ -226,12 +228,87 @@ func TestUnikraftCreateRootfs(t *testing.T) {
 	})
 }
 
-func TestUnikraftBuildKernel(t *testing.T) {
-	unikraft := &UnikraftInfo{}
-	state := unikraft.BuildKernel("ctx")
+// kraftExec returns the exec operation of a kernel build and its mounts
+// indexed by their destination.
+func kraftExec(t *testing.T, state llb.State) (*pb.ExecOp, map[string]*pb.Mount, map[string]*pb.Op) {
 	def, err := state.Marshal(context.TODO())
-
 	require.NoError(t, err)
-	_, arr := parseDef(t, def.Def)
-	require.Equal(t, 0, len(arr))
+	m, arr := parseDef(t, def.Def)
+	var exec *pb.ExecOp
+	for _, op := range arr {
+		if e := op.GetExec(); e != nil {
+			exec = e
+		}
+	}
+	require.NotNil(t, exec)
+	mounts := map[string]*pb.Mount{}
+	for _, mount := range exec.Mounts {
+		mounts[mount.Dest] = mount
+	}
+
+	return exec, mounts, m
+}
+
+func TestUnikraftBuildKernel(t *testing.T) {
+	t.Run("Missing source", func(t *testing.T) {
+		unikraft := &UnikraftInfo{}
+		_, err := unikraft.BuildKernel(context.TODO(), BuildInput{})
+		require.ErrorContains(t, err, "The source of the kernel is necessary")
+	})
+	t.Run("Git source with config", func(t *testing.T) {
+		unikraft := &UnikraftInfo{Arch: "x86_64"}
+		state, err := unikraft.BuildKernel(context.TODO(), BuildInput{
+			BuildContext: "context",
+			Monitor:      "firecracker",
+			Kernel: Kernel{
+				From:   KernelFromBuild,
+				Source: "https://github.com/unikraft/app-helloworld.git#v0.17.0",
+				Config: []string{"CONFIG_LIBUKDEBUG=y"},
+			},
+		})
+		require.NoError(t, err)
+		exec, mounts, m := kraftExec(t, state)
+		require.Equal(t, []string{"/bin/sh", "/kconfig/build.sh", "fc", "x86_64", ""}, exec.Meta.Args)
+		require.Equal(t, "/src", exec.Meta.Cwd)
+		require.Contains(t, exec.Meta.Env, "CCACHE_DIR=/ccache")
+		require.Equal(t, pb.MountType_CACHE, mounts["/ccache"].MountType)
+		require.Equal(t, "bunny-unikraft-ccache", mounts["/ccache"].CacheOpt.ID)
+		require.Equal(t, pb.MountType_CACHE, mounts["/root/.local/share/kraftkit"].MountType)
+		require.True(t, mounts["/kconfig"].Readonly)
+		require.False(t, mounts["/src"].Readonly)
+		require.NotEqual(t, int64(-1), int64(mounts["/out"].Output))
+
+		for _, op := range m {
+			if src := op.GetSource(); src != nil && strings.HasPrefix(src.Identifier, "git://") {
+				require.Equal(t, "git://github.com/unikraft/app-helloworld.git#v0.17.0", src.Identifier)
+			}
+			if file := op.GetFile(); file != nil {
+				mkfile := file.Actions[0].GetMkfile()
+				if mkfile != nil && mkfile.Path == "/fragment" {
+					require.Equal(t, "CONFIG_LIBUKDEBUG=y\n", string(mkfile.Data))
+				}
+			}
+		}
+	})
+	t.Run("Local source with path", func(t *testing.T) {
+		unikraft := &UnikraftInfo{Arch: "aarch64"}
+		state, err := unikraft.BuildKernel(context.TODO(), BuildInput{
+			BuildContext: "context",
+			Monitor:      "qemu",
+			Kernel: Kernel{
+				From:   KernelFromBuild,
+				Source: "app",
+				Path:   ".unikraft/build/app_qemu-arm64",
+			},
+		})
+		require.NoError(t, err)
+		exec, _, m := kraftExec(t, state)
+		require.Equal(t, []string{"/bin/sh", "/kconfig/build.sh", "qemu", "arm64", ".unikraft/build/app_qemu-arm64"}, exec.Meta.Args)
+		require.Equal(t, "/src/app", exec.Meta.Cwd)
+		for _, op := range m {
+			if s := op.GetSource(); s != nil && strings.HasPrefix(s.Identifier, "local://") {
+				require.Equal(t, "local://context", s.Identifier)
+			}
+		}
+	})
 }
@@ -16,6 +16,11 @@ package hops
 
 import (
 	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/go-version"
 )
@@ -49,6 +54,10 @@ func CheckBunnyfileVersion(fileVersion string) error {
 // field. The conditions are:
 // 1) framework can not be empty or not set
 // 2) monitor can not be empty or not set
+// 3) cloud-hypervisor and xen support only amd64 and arm64 and solo5-virtio
+// only amd64
+// 4) cloud-hypervisor is not supported for unikraft
+// 5) solo5-virtio is supported only for mirage
 func ValidatePlatform(plat Platform) error {
 	if plat.Framework == "" {
 		return fmt.Errorf("The framework field of platforms is necessary")
@@ -56,6 +65,17 @@ func ValidatePlatform(plat Platform) error {
 	if plat.Monitor == "" {
 		return fmt.Errorf("The monitor field of platforms is necessary")
 	}
+	monitor := NormalizeMonitor(plat.Monitor)
+	arches := monitorArches(monitor)
+	if plat.Arch != "" && arches != nil && !slices.Contains(arches, normalizeArch(plat.Arch)) {
+		return fmt.Errorf("The %s monitor supports only %s", monitor, strings.Join(arches, " and "))
+	}
+	if monitor == MonitorCloudHypervisor && plat.Framework == unikraftName {
+		return fmt.Errorf("The %s monitor is not supported for %s", MonitorCloudHypervisor, unikraftName)
+	}
+	if monitor == MonitorSolo5Virtio && plat.Framework != "mirage" && plat.Framework != FrameworkAuto {
+		return fmt.Errorf("The %s monitor is supported only for mirage", MonitorSolo5Virtio)
+	}
 
 	return nil
 }
@@ -63,26 +83,134 @@ func ValidatePlatform(plat Platform) error {
 // ValidateRootfs checks if user input meets all conditions regarding the rootfs
 // field. The conditions are:
 // 1) if from is empty/scratch then path should also be empty
-// 2) if path is empty then from should also be empty
+// 2) if from is local then path is necessary, while an image without a path
+// and files to include is reused as a raw rootfs, so type should be raw or
+// empty
 // 3) if from is not scratch or empty, include should not be set
 // 4) An entry in include can not have the first part (before ":" empty
+// 5) initrds can only be set for an initrd rootfs from scratch without include
+// 6) each entry in initrds should have a unique name without commas and
+// at least one file to include
+// 7) from and the from of each file to include should be valid image
+// references, unless they are local or scratch
 func ValidateRootfs(rootfs Rootfs) error {
+	err := validateSourceRef("rootfs", rootfs.From)
+	if err != nil {
+		return withField("rootfs.from", err)
+	}
 	if (rootfs.From == "scratch" || rootfs.From == "") && rootfs.Path != "" {
-		return fmt.Errorf("The from field of rootfs can not be empty or scratch, if path is set")
+		return fieldErrorf("rootfs.from", "The from field of rootfs can not be empty or scratch, if path is set")
 	}
 	if rootfs.Path != "" && rootfs.Type == "raw" {
-		return fmt.Errorf("The path field in rootfs can not be combined with a raw rootfs")
+		return fieldErrorf("rootfs.path", "The path field in rootfs can not be combined with a raw rootfs")
 	}
 	if rootfs.From == "local" && rootfs.Type == "raw" {
-		return fmt.Errorf("If type of rootfs is raw, then from can not be local")
+		return fieldErrorf("rootfs.type", "If type of rootfs is raw, then from can not be local")
+	}
+	if rootfs.From == "local" && rootfs.Path == "" {
+		return fieldErrorf("rootfs.path", "The path field of rootfs is necessary, if from is local")
+	}
+	if rootfs.ReusesImage() && rootfs.Type != "" && rootfs.Type != "raw" {
+		return fieldErrorf("rootfs.path", "The path field of rootfs is necessary for a %s rootfs from an image", rootfs.Type)
 	}
 
 	if len(rootfs.Includes) > 0 && rootfs.From == "local" {
-		return fmt.Errorf("Invalid combination of includes and from fields")
+		return fieldErrorf("rootfs.include", "Invalid combination of includes and from fields")
 	}
 
 	if len(rootfs.Includes) > 0 && rootfs.From != "" && rootfs.From != "scratch" && rootfs.Type != "raw" {
-		return fmt.Errorf("Adding files to an existing non-raw rootfs is not yet supported")
+		return fieldErrorf("rootfs.include", "Adding files to an existing non-raw rootfs is not yet supported")
+	}
+
+	err = validateIncludes("rootfs.include", rootfs.Includes)
+	if err != nil {
+		return err
+	}
+
+	if rootfs.Owner != "" {
+		_, err = ParseOwner(rootfs.Owner)
+		if err != nil {
+			return fieldErrorf("rootfs.owner", "Invalid owner field of rootfs: %v", err)
+		}
+		if len(rootfs.Includes) == 0 && len(rootfs.Initrds) == 0 {
+			return fieldErrorf("rootfs.owner", "The owner field of rootfs requires files to include")
+		}
+	}
+
+	return validateInitrds(rootfs)
+}
+
+// validateIncludes checks the exclude patterns, the mode and the owner of the
+// files to include, whose errors point at the entries of the given field. The
+// patterns use the syntax of .dockerignore, where ** matches any number of
+// directories.
+func validateIncludes(field string, includes []FileToInclude) error {
+	for i, f := range includes {
+		entry := fmt.Sprintf("%s[%d]", field, i)
+		err := validateSourceRef("the include "+f.Src, f.From)
+		if err != nil {
+			return withField(entry+".from", err)
+		}
+		if f.Mode != "" {
+			_, err = ParseMode(f.Mode)
+			if err != nil {
+				return fieldErrorf(entry+".mode", "Invalid mode of %s: %v", f.Src, err)
+			}
+		}
+		if f.Owner != "" {
+			_, err = ParseOwner(f.Owner)
+			if err != nil {
+				return fieldErrorf(entry+".owner", "Invalid owner of %s: %v", f.Src, err)
+			}
+		}
+		for j, pattern := range f.Exclude {
+			exclude := fmt.Sprintf("%s.exclude[%d]", entry, j)
+			if pattern == "" {
+				return fieldErrorf(exclude, "Empty exclude pattern for %s", f.Src)
+			}
+			_, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), "")
+			if err != nil {
+				return fieldErrorf(exclude, "Invalid exclude pattern %q for %s: %v", pattern, f.Src, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateInitrds(rootfs Rootfs) error {
+	if len(rootfs.Initrds) == 0 {
+		return nil
+	}
+	if rootfs.From != "" && rootfs.From != "scratch" {
+		return fieldErrorf("rootfs.initrds", "The initrds field of rootfs can only be set, if from is scratch")
+	}
+	if rootfs.Type != "initrd" {
+		return fieldErrorf("rootfs.initrds", "The initrds field of rootfs can only be set, if type is initrd")
+	}
+	if len(rootfs.Includes) > 0 {
+		return fieldErrorf("rootfs.initrds", "The initrds field of rootfs can not be combined with include")
+	}
+	names := map[string]bool{}
+	for i, initrd := range rootfs.Initrds {
+		entry := fmt.Sprintf("rootfs.initrds[%d]", i)
+		if initrd.Name == "" {
+			return fieldErrorf(entry+".name", "The name field of an initrd is necessary")
+		}
+		if strings.Contains(initrd.Name, ",") {
+			return fieldErrorf(entry+".name", "The name of initrd %s can not contain commas", initrd.Name)
+		}
+		if names[initrd.Name] {
+			return fieldErrorf(entry+".name", "Duplicate initrd %s", initrd.Name)
+		}
+		names[initrd.Name] = true
+		if len(initrd.Includes) == 0 {
+			return fieldErrorf(entry+".include", "The include field of initrd %s is necessary", initrd.Name)
+		}
+		err := validateIncludes(entry+".include", initrd.Includes)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -91,14 +219,635 @@ func ValidateRootfs(rootfs Rootfs) error {
 // ValidateKernel checks if user input meets all conditions regarding the kernel
 // field. The conditions are:
 // 1) from can not be empty or not set
-// 2) path not be empty or not set
+// 2) path not be empty or not set, unless the kernel gets built
+// 3) from should be a valid image reference, unless it is local or build
 func ValidateKernel(kernel Kernel) error {
 	if kernel.From == "" {
-		return fmt.Errorf("The from field of kernel is necessary")
+		return fieldErrorf("kernel.from", "The from field of kernel is necessary")
+	}
+	if kernel.Path == "" && kernel.From != KernelFromBuild {
+		return fieldErrorf("kernel.path", "The path field of kernel is necessary")
+	}
+
+	return withField("kernel.from", validateSourceRef("kernel", kernel.From))
+}
+
+// validateSourceRef checks that the from field of the given field is a valid
+// image reference, unless it is one of the special sources, so an invalid
+// reference fails before the build, instead of when buildkit solves it.
+func validateSourceRef(field string, ref string) error {
+	switch ref {
+	case "", "local", "scratch", KernelFromBuild:
+		return nil
+	}
+	_, _, err := ResolveSourceRef(ref, "", "")
+	if err != nil {
+		return fmt.Errorf("Invalid from field of %s: %v", field, err)
+	}
+
+	return nil
+}
+
+// ValidateKernelBuild checks if user input meets all conditions regarding
+// building the kernel from source. The conditions are:
+// 1) source and config can be set only if from is build
+// 2) source can not be empty, if from is build
+// 3) the framework should support building the kernel
+func ValidateKernelBuild(kernel Kernel, plat Platform) error {
+	if kernel.From != KernelFromBuild {
+		if kernel.Source != "" || len(kernel.Config) > 0 {
+			return fmt.Errorf("The source and config fields of kernel require from to be %s", KernelFromBuild)
+		}
+		return nil
+	}
+	if kernel.Source == "" {
+		return fmt.Errorf("The source field of kernel is necessary to build it")
+	}
+	if plat.Framework != unikraftName {
+		return fmt.Errorf("Building the kernel is not supported for %s", plat.Framework)
+	}
+
+	return nil
+}
+
+// ValidateTest checks if user input meets all conditions regarding the test
+// field. The conditions are:
+// 1) marker can not be empty, if any other field of test is set
+// 2) timeout should be a valid duration of at least one second
+// 3) the monitor should be qemu
+func ValidateTest(t SmokeTest, plat Platform) error {
+	if !t.Enabled() {
+		if t.Image != "" || t.Timeout != "" || t.KVM {
+			return fmt.Errorf("The marker field of test is necessary")
+		}
+		return nil
+	}
+	_, err := t.GetTimeout()
+	if err != nil {
+		return fmt.Errorf("Invalid test field: %v", err)
+	}
+	if plat.Monitor != "qemu" {
+		return fmt.Errorf("Smoke tests are not supported for %s yet", plat.Monitor)
+	}
+
+	return nil
+}
+
+// ValidateMirrors checks if user input meets all conditions regarding the
+// mirrors field. The conditions are:
+// 1) the registry and the mirror can not be empty
+// 2) the registry should not contain a path
+func ValidateMirrors(mirrors Mirrors) error {
+	for registry, mirror := range mirrors {
+		if registry == "" || mirror == "" {
+			return fmt.Errorf("The registry and the mirror of mirrors can not be empty")
+		}
+		if strings.Contains(registry, "/") {
+			return fmt.Errorf("Invalid registry %s in mirrors, expected only a host", registry)
+		}
+	}
+
+	return nil
+}
+
+// ValidateArtifacts checks if user input meets all conditions regarding the
+// artifacts field. The conditions are:
+// 1) the rootfs artifact requires a rootfs
+func ValidateArtifacts(artifacts Artifacts, rootfs Rootfs) error {
+	if artifacts.Rootfs && rootfs.From == "scratch" && len(rootfs.Includes) == 0 && len(rootfs.Initrds) == 0 {
+		return fmt.Errorf("The rootfs artifact can not be exported without a rootfs")
+	}
+
+	return nil
+}
+
+// ValidateBinary checks if user input meets all conditions regarding the
+// binary field. The conditions are:
+// 1) path can not be empty, if binary is set
+// 2) the framework should be unikraft
+// 3) kernel can not be set, since the elfloader is the kernel
+// 4) rootfs can only have files to include in the initrd
+func ValidateBinary(h *Hops) error {
+	if !h.Binary.Enabled() {
+		return nil
+	}
+	if h.Binary.Path == "" {
+		return fmt.Errorf("The path field of binary is necessary")
+	}
+	if h.Platform.Framework != unikraftName {
+		return fmt.Errorf("Running a binary is only supported for %s", unikraftName)
 	}
-	if kernel.Path == "" {
-		return fmt.Errorf("The path field of kernel is necessary")
+	if h.Kernel.From != "" || h.Kernel.Path != "" {
+		return fmt.Errorf("The kernel field can not be combined with binary")
+	}
+	if (h.Rootfs.From != "" && h.Rootfs.From != "scratch") || h.Rootfs.Path != "" {
+		return fmt.Errorf("The binary field can only be combined with a rootfs from scratch")
+	}
+	if h.Rootfs.Type != "" && h.Rootfs.Type != "initrd" {
+		return fmt.Errorf("The binary field requires an initrd rootfs")
 	}
 
 	return nil
 }
+
+// ValidateNetwork checks if user input meets all conditions regarding the
+// network field. The conditions are:
+// 1) retries can not be negative
+// 2) timeout should be a valid duration of at least one second
+func ValidateNetwork(p NetworkPolicy) error {
+	if p.Retries < 0 {
+		return fmt.Errorf("The retries field of network can not be negative")
+	}
+	_, err := p.GetTimeout()
+	if err != nil {
+		return fmt.Errorf("Invalid network field: %v", err)
+	}
+
+	return nil
+}
+
+// ValidateCertificates checks if user input meets all conditions regarding the
+// certificates field. The conditions are:
+// 1) a certificate can not be empty
+// 2) a certificate should be a file inside the build context
+func ValidateCertificates(certs []string) error {
+	for _, cert := range certs {
+		if cert == "" {
+			return fmt.Errorf("The path of a certificate can not be empty")
+		}
+		clean := path.Clean(cert)
+		if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("The certificate %s should be a file inside the build context", cert)
+		}
+	}
+
+	return nil
+}
+
+// ValidateFlavors checks if user input meets all conditions regarding the
+// flavors field. The conditions are:
+// 1) every flavor should be known
+// 2) the kraftkit flavor requires the unikraft framework, once it is known
+func ValidateFlavors(flavors []string, plat Platform) error {
+	for _, flavor := range flavors {
+		_, err := NewFlavor(flavor)
+		if err != nil {
+			return err
+		}
+		if flavor == FlavorKraftkit && plat.Framework != unikraftName && plat.Framework != FrameworkAuto {
+			return fmt.Errorf("The %s flavor is only supported for %s", FlavorKraftkit, unikraftName)
+		}
+	}
+
+	return nil
+}
+
+// ValidateBuild checks if user input meets all conditions regarding the
+// build field. The conditions are:
+// 1) the network mode can be set only for known steps
+// 2) every network mode should be one of none, host or sandbox
+// 3) timeout should be a valid duration of at least one second
+func ValidateBuild(b BuildOptions) error {
+	steps := make([]string, 0, len(b.Network))
+	for step := range b.Network {
+		steps = append(steps, step)
+	}
+	sort.Strings(steps)
+
+	_, err := b.GetTimeout()
+	if err != nil {
+		return fmt.Errorf("Invalid timeout field of build: %v", err)
+	}
+
+	for _, step := range steps {
+		switch step {
+		case BuildStepKernel, BuildStepInitrd, BuildStepTest, BuildStepCheck, BuildStepScan, BuildStepModules, BuildStepHooks:
+		default:
+			return fmt.Errorf("Unknown build step %s, expected one of %s, %s, %s, %s, %s, %s, %s",
+				step, BuildStepKernel, BuildStepInitrd, BuildStepTest, BuildStepCheck, BuildStepScan, BuildStepModules, BuildStepHooks)
+		}
+		_, ok := netModes[b.Network[step]]
+		if !ok {
+			return fmt.Errorf("Unknown network mode %s for step %s, expected one of none, host, sandbox",
+				b.Network[step], step)
+		}
+	}
+
+	return nil
+}
+
+// ValidateScan checks if user input meets all conditions regarding the scan
+// field. The conditions are:
+// 1) enabled is necessary, if any other field of scan is set
+// 2) severity should be one of the severities of trivy
+func ValidateScan(s Scan) error {
+	if !s.Enabled {
+		if s.Image != "" || s.Severity != "" {
+			return fmt.Errorf("The enabled field of scan is necessary")
+		}
+		return nil
+	}
+	_, err := s.threshold()
+	if err != nil {
+		return fmt.Errorf("Invalid scan field: %v", err)
+	}
+
+	return nil
+}
+
+// ValidateBase checks if user input meets all conditions regarding the base
+// field. The conditions are:
+// 1) base can not be local and it should be scratch or a valid image
+// reference
+// 2) base can not be combined with a raw rootfs, which is the default type
+// of rootfs for frameworks other than unikraft, once the framework is known,
+// and the type of a rootfs that reuses the filesystem of an image
+func ValidateBase(base string, rootfs Rootfs, plat Platform) error {
+	if base == "" {
+		return nil
+	}
+	if base == "local" {
+		return fmt.Errorf("The base field can not be local, it should be scratch or an OCI image")
+	}
+	_, _, err := ResolveSourceRef(base, "", "")
+	if base != "scratch" && err != nil {
+		return fmt.Errorf("Invalid base field: %v", err)
+	}
+	if rootfs.ReusesImage() {
+		return fmt.Errorf("The base field can not be combined with a raw rootfs")
+	}
+	if plat.Framework == FrameworkAuto && rootfs.Type == "" {
+		return nil
+	}
+	if rootfs.From == "scratch" && len(rootfs.Includes) == 0 && len(rootfs.Initrds) == 0 {
+		// There is no rootfs
+		return nil
+	}
+	var framework Framework = NewGeneric(plat, rootfs)
+	if plat.Framework == unikraftName {
+		framework = NewUnikraft(plat, rootfs)
+	}
+	if framework.GetRootfsType() == "raw" {
+		return fmt.Errorf("The base field can not be combined with a raw rootfs")
+	}
+
+	return nil
+}
+
+// ValidateModules checks if user input meets all conditions regarding the
+// modules field. The conditions are:
+// 1) include is necessary, if any other field of modules is set
+// 2) the names of the modules can not be empty, contain spaces or start with -
+// 3) modules are only supported for linux
+// 4) from should be a valid image reference, not local, and it is necessary,
+// if the kernel is not an image
+// 5) modules require a rootfs with files to include
+func ValidateModules(m Modules, kernel Kernel, rootfs Rootfs, plat Platform) error {
+	if !m.Enabled() {
+		if m.From != "" || m.Version != "" || m.Image != "" {
+			return fmt.Errorf("The include field of modules is necessary")
+		}
+		return nil
+	}
+	for _, name := range m.Include {
+		if name == "" || strings.ContainsAny(name, " \t\n") || strings.HasPrefix(name, "-") {
+			return fmt.Errorf("Invalid module name %q", name)
+		}
+	}
+	if plat.Framework != "linux" && plat.Framework != FrameworkAuto {
+		return fmt.Errorf("The modules field is only supported for linux")
+	}
+	if m.From == "local" {
+		return fmt.Errorf("The from field of modules can not be local, it should be an OCI image")
+	}
+	err := validateSourceRef("modules", m.From)
+	if err != nil {
+		return err
+	}
+	if m.From == "" && (kernel.From == "local" || kernel.From == KernelFromBuild) {
+		return fmt.Errorf("The from field of modules is necessary, if the kernel is not an OCI image")
+	}
+	if len(rootfs.Includes) == 0 {
+		return fmt.Errorf("The modules field requires a rootfs with files to include")
+	}
+
+	return nil
+}
+
+// ValidateHooks checks if user input meets all conditions regarding the hooks
+// field. The conditions are:
+// 1) image is necessary, if there are commands, and it should be a valid image
+// reference, not local or scratch
+// 2) image can not be set without commands
+// 3) the commands can not be empty
+// 4) the hooks before packing require a rootfs with files to include
+func ValidateHooks(hooks Hooks, rootfs Rootfs) error {
+	if !hooks.Enabled() {
+		if hooks.Image != "" {
+			return fmt.Errorf("The hooks field requires prePack or postPack commands")
+		}
+		return nil
+	}
+	if hooks.Image == "" {
+		return fmt.Errorf("The image field of hooks is necessary")
+	}
+	if hooks.Image == "local" || hooks.Image == "scratch" || hooks.Image == KernelFromBuild {
+		return fmt.Errorf("The image field of hooks should be an OCI image")
+	}
+	_, _, err := ResolveSourceRef(hooks.Image, "", "")
+	if err != nil {
+		return fmt.Errorf("Invalid image field of hooks: %v", err)
+	}
+	for _, commands := range [][]string{hooks.PrePack, hooks.PostPack} {
+		for _, command := range commands {
+			if strings.TrimSpace(command) == "" {
+				return fmt.Errorf("The commands of hooks can not be empty")
+			}
+		}
+	}
+	if len(hooks.PrePack) > 0 && len(rootfs.Includes) == 0 {
+		return fmt.Errorf("The prePack hooks require a rootfs with files to include")
+	}
+
+	return nil
+}
+
+// ValidateDtb checks if user input meets all conditions regarding the dtb
+// field. The conditions are:
+// 1) from and path should be both set or both empty
+// 2) from should be local or a valid image reference
+// 3) the device tree blob is only supported for arm64, if the architecture
+// is set
+// 4) the device tree blob is not supported for firecracker and
+// cloud-hypervisor
+func ValidateDtb(d Dtb, plat Platform) error {
+	if d.From == "" && d.Path == "" {
+		return nil
+	}
+	if d.From == "" {
+		return fmt.Errorf("The from field of dtb is necessary")
+	}
+	if d.Path == "" {
+		return fmt.Errorf("The path field of dtb is necessary")
+	}
+	if d.From == "scratch" || d.From == KernelFromBuild {
+		return fmt.Errorf("The from field of dtb should be local or an OCI image")
+	}
+	err := validateSourceRef("dtb", d.From)
+	if err != nil {
+		return err
+	}
+	if plat.Arch != "" && normalizeArch(plat.Arch) != "arm64" {
+		return fmt.Errorf("The dtb field is only supported for arm64")
+	}
+	if plat.Monitor == "firecracker" || plat.Monitor == MonitorCloudHypervisor {
+		return fmt.Errorf("The dtb field is not supported for %s", plat.Monitor)
+	}
+
+	return nil
+}
+
+// ValidatePaths checks if user input meets all conditions regarding the paths
+// field. The conditions are:
+// 1) every path should be absolute and clean, and not the root or the path of
+// urunc.json
+// 2) the paths should be different from each other
+// 3) the rootfs path requires a rootfs that is not raw
+// 4) the dtb path requires a device tree blob
+func ValidatePaths(p Paths, rootfs Rootfs, d Dtb) error {
+	seen := map[string]string{}
+	for _, field := range []struct {
+		name string
+		path string
+	}{
+		{"kernel", p.Kernel},
+		{"rootfs", p.Rootfs},
+		{"dtb", p.Dtb},
+	} {
+		if field.path == "" {
+			continue
+		}
+		if !path.IsAbs(field.path) || path.Clean(field.path) != field.path {
+			return fmt.Errorf("The %s field of paths should be a clean absolute path", field.name)
+		}
+		if field.path == "/" || field.path == uruncJSONPath {
+			return fmt.Errorf("The %s field of paths can not be %s", field.name, field.path)
+		}
+		if other, ok := seen[field.path]; ok {
+			return fmt.Errorf("The %s and %s fields of paths can not be the same", other, field.name)
+		}
+		seen[field.path] = field.name
+	}
+	if p.Rootfs != "" {
+		if rootfs.From == "" && len(rootfs.Includes) == 0 && len(rootfs.Initrds) == 0 {
+			return fmt.Errorf("The rootfs field of paths requires a rootfs")
+		}
+		if rootfs.Type == "raw" || rootfs.ReusesImage() {
+			return fmt.Errorf("The rootfs field of paths can not be set for a raw rootfs")
+		}
+	}
+	if p.Dtb != "" && !d.Enabled() {
+		return fmt.Errorf("The dtb field of paths requires a dtb")
+	}
+
+	return nil
+}
+
+// ValidateVariant checks if user input meets all conditions regarding the
+// variant field. The conditions are:
+// 1) the name should be a valid tag of an image, i.e. up to 128 letters,
+// digits, underscores, periods and dashes, not starting with a period or a
+// dash
+func ValidateVariant(name string) error {
+	if name == "" {
+		return nil
+	}
+	if !variantNameRegexp.MatchString(name) {
+		return fmt.Errorf("Invalid variant %q: it should be a valid tag of an image", name)
+	}
+
+	return nil
+}
+
+// ValidateArgs checks if user input meets all conditions regarding the args
+// field. The conditions are:
+// 1) every build argument should have a name without spaces, $, { or }
+// 2) a build argument should be declared only once
+// 3) a required build argument can not have a default value
+func ValidateArgs(decls []BuildArg) error {
+	seen := map[string]bool{}
+	for _, d := range decls {
+		if d.Name == "" || strings.ContainsAny(d.Name, " \t${}=") {
+			return fmt.Errorf("Invalid name %q of build argument in args", d.Name)
+		}
+		if seen[d.Name] {
+			return fmt.Errorf("The build argument %s is declared more than once in args", d.Name)
+		}
+		seen[d.Name] = true
+		if d.Required && d.Default != "" {
+			return fmt.Errorf("The required build argument %s can not have a default value", d.Name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateArchOverrides checks the kernel and the rootfs of every
+// architecture with an override. The conditions are:
+// 1) the kernel and the rootfs of the architecture, with the fields of the
+// override, should be valid as the ones without overrides
+func ValidateArchOverrides(h *Hops) error {
+	for _, arch := range overrideArches(h) {
+		plat := h.Platform
+		plat.Arch = arch
+		kernel := h.Kernel.ForArch(arch)
+		err := ValidateKernel(kernel)
+		if err != nil {
+			return fmt.Errorf("Invalid kernel for %s: %w", arch, err)
+		}
+		err = ValidateKernelBuild(kernel, plat)
+		if err != nil {
+			return fmt.Errorf("Invalid kernel for %s: %w", arch, err)
+		}
+		rootfs := h.Rootfs.ForArch(arch)
+		if rootfs.From == "" {
+			rootfs.From = "scratch"
+		}
+		err = ValidateRootfs(rootfs)
+		if err != nil {
+			return fmt.Errorf("Invalid rootfs for %s: %w", arch, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateResources checks if user input meets all conditions regarding the
+// resources field. The conditions are:
+// 1) memory should be a Kubernetes quantity of bytes (e.g. 256Mi or 1G)
+// 2) cpu should be a number of CPUs or millicpus (e.g. 1, 0.5 or 500m)
+func ValidateResources(r Resources) error {
+	if r.Memory != "" && !memoryQuantityRegexp.MatchString(r.Memory) {
+		return fmt.Errorf("The memory field of resources should be a quantity like 256Mi, got %s", r.Memory)
+	}
+	if r.CPU != "" && !cpuQuantityRegexp.MatchString(r.CPU) {
+		return fmt.Errorf("The cpu field of resources should be a quantity like 1 or 500m, got %s", r.CPU)
+	}
+
+	return nil
+}
+
+// ValidateMetadata checks if user input meets all conditions regarding the
+// metadata field. The conditions are:
+// 1) every author should be non-empty
+// 2) documentation should be an http or https URL
+// 3) created should be an RFC 3339 timestamp
+func ValidateMetadata(m Metadata) error {
+	for _, author := range m.Authors {
+		if strings.TrimSpace(author) == "" {
+			return fmt.Errorf("The authors field of metadata should not have empty entries")
+		}
+	}
+	if m.Documentation != "" {
+		u, err := url.Parse(m.Documentation)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("The documentation field of metadata should be an http or https URL, got %s", m.Documentation)
+		}
+	}
+	_, err := m.CreatedTime()
+	if err != nil {
+		return fmt.Errorf("The created field of metadata should be an RFC 3339 timestamp, e.g. 2024-01-02T15:04:05Z, got %s", m.Created)
+	}
+
+	return nil
+}
+
+// ValidateShutdown checks if user input meets all conditions regarding the
+// shutdown field. The conditions are:
+// 1) method should be acpi or kill, if set
+// 2) signal should be the name or the number of a signal, if set
+func ValidateShutdown(s Shutdown) error {
+	switch s.Method {
+	case "", ShutdownACPI, ShutdownKill:
+	default:
+		return fmt.Errorf("The method field of shutdown should be %s or %s, got %s", ShutdownACPI, ShutdownKill, s.Method)
+	}
+	if s.Signal != "" {
+		_, err := NormalizeSignal(s.Signal)
+		if err != nil {
+			return fmt.Errorf("The signal field of shutdown should be a signal: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateUser checks if user input meets all conditions regarding the user
+// field. The conditions are:
+// 1) the user should be a name or a uid, optionally followed by a colon and
+// a group name or a gid, as USER of a Containerfile
+// 2) neither the user nor the group can have spaces
+func ValidateUser(user string) error {
+	if user == "" {
+		return nil
+	}
+	name, group, hasGroup := strings.Cut(user, ":")
+	if name == "" || (hasGroup && group == "") || strings.Contains(group, ":") ||
+		strings.ContainsAny(user, " \t\n") {
+		return fmt.Errorf("The user field should be <user>[:<group>], with names or ids, got %q", user)
+	}
+
+	return nil
+}
+
+// ValidateWorkdir checks if user input meets all conditions regarding the
+// workdir field. The conditions are:
+// 1) workdir should be an absolute path
+func ValidateWorkdir(workdir string) error {
+	if workdir != "" && !path.IsAbs(workdir) {
+		return fmt.Errorf("The workdir field should be an absolute path, got %s", workdir)
+	}
+
+	return nil
+}
+
+// ValidateTarget checks if user input meets all conditions regarding the
+// target field. The conditions are:
+// 1) uruncVersion should be a version, not older than the oldest release of
+// urunc that images can target
+func ValidateTarget(t Target) error {
+	if t.UruncVersion == "" {
+		return nil
+	}
+	_, err := parseUruncVersion(t.UruncVersion)
+	if err != nil {
+		return fmt.Errorf("The uruncVersion field of target is invalid: %v", err)
+	}
+
+	return nil
+}
+
+// ValidateDetectedFramework checks the fields that depend on the framework,
+// after detecting it from the kernel. The conditions are:
+// 1) the conditions of the flavors field
+// 2) the conditions of the platforms field
+// 3) the conditions of the base field
+// 4) the conditions of the modules field
+func ValidateDetectedFramework(h *Hops) error {
+	err := ValidateFlavors(h.Flavors, h.Platform)
+	if err != nil {
+		return err
+	}
+
+	err = ValidatePlatform(h.Platform)
+	if err != nil {
+		return err
+	}
+
+	err = ValidateBase(h.Base, h.Rootfs, h.Platform)
+	if err != nil {
+		return err
+	}
+
+	return ValidateModules(h.Modules, h.Kernel, h.Rootfs, h.Platform)
+}
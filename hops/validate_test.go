@@ -15,6 +15,7 @@
 package hops
 
 import (
+	"strconv"
 	"strings"
 	"testing"
 
@@ -104,12 +105,75 @@ func TestValidateBunnyfilePlatform(t *testing.T) {
 			expectError: true,
 			errorText:   "framework",
 		},
+		{
+			name:        "Valid cloud-hypervisor",
+			input:       "linux/cloud-hypervisor/arm64",
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name:        "Valid alias of cloud-hypervisor",
+			input:       "linux/clh/x86_64",
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name:        "Invalid cloud-hypervisor architecture",
+			input:       "linux/cloud-hypervisor/riscv64",
+			expectError: true,
+			errorText:   "The cloud-hypervisor monitor supports only amd64 and arm64",
+		},
+		{
+			name:        "Valid xen",
+			input:       "mirage/xen/arm64",
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name:        "Valid solo5-virtio",
+			input:       "mirage/solo5-virtio/x86_64",
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name:        "Valid alias of solo5-virtio without arch",
+			input:       "mirage/virtio",
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name:        "Invalid xen architecture",
+			input:       "linux/xen/riscv64",
+			expectError: true,
+			errorText:   "The xen monitor supports only amd64 and arm64",
+		},
+		{
+			name:        "Invalid solo5-virtio architecture",
+			input:       "mirage/solo5-virtio/arm64",
+			expectError: true,
+			errorText:   "The solo5-virtio monitor supports only amd64",
+		},
+		{
+			name:        "Invalid solo5-virtio framework",
+			input:       "rumprun/virtio/amd64",
+			expectError: true,
+			errorText:   "The solo5-virtio monitor is supported only for mirage",
+		},
+		{
+			name:        "Invalid cloud-hypervisor with unikraft",
+			input:       "unikraft/ch/amd64",
+			expectError: true,
+			errorText:   "The cloud-hypervisor monitor is not supported for unikraft",
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			fields := strings.Split(tc.input, "/")
 			plat := Platform{Framework: fields[0], Monitor: fields[1]}
+			if len(fields) > 2 {
+				plat.Arch = fields[2]
+			}
 			err := ValidatePlatform(plat)
 			if tc.expectError {
 				require.Error(t, err, "Expected an error, got nil")
@@ -184,6 +248,30 @@ func TestValidateBunnyfileRootfs(t *testing.T) {
 			expectError: true,
 			errorText:   "If type of rootfs is raw, then from can not",
 		},
+		{
+			name:        "Valid from registry without path reuses the image",
+			input:       "foo///",
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name:        "Valid from registry without path, type raw",
+			input:       "foo//raw/",
+			expectError: false,
+			errorText:   "",
+		},
+		{
+			name:        "Invalid from registry without path, type initrd",
+			input:       "foo//initrd/",
+			expectError: true,
+			errorText:   "The path field of rootfs is necessary for a initrd rootfs from an image",
+		},
+		{
+			name:        "Invalid from local without path",
+			input:       "local//initrd/",
+			expectError: true,
+			errorText:   "The path field of rootfs is necessary, if from is local",
+		},
 		{
 			name:        "Invalid from local with includes",
 			input:       "local/path//foo:bar",
@@ -252,12 +340,28 @@ func TestValidateBunnyfileKernel(t *testing.T) {
 			expectError: true,
 			errorText:   "The path field of kernel is necessary",
 		},
+		{
+			name:        "Valid build without path",
+			input:       "build/",
+			expectError: false,
+		},
 		{
 			name:        "Invalid empty from and path",
 			input:       "/",
 			expectError: true,
 			errorText:   "The from field of kernel is necessary",
 		},
+		{
+			name:        "Valid local from",
+			input:       "local/kernel",
+			expectError: false,
+		},
+		{
+			name:        "Invalid reference in from",
+			input:       "Foo/kernel",
+			expectError: true,
+			errorText:   `Invalid from field of kernel: Invalid image reference "Foo"`,
+		},
 	}
 
 	for _, tc := range tests {
@@ -274,3 +378,1061 @@ func TestValidateBunnyfileKernel(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateBunnyfileKernelBuild(t *testing.T) {
+	// The input has the form <from>/<source>/<config>/<framework>
+	tests := []testInfo{
+		{
+			name:        "Valid build with source",
+			input:       "build/app//unikraft",
+			expectError: false,
+		},
+		{
+			name:        "Valid build with source and config",
+			input:       "build/app/CONFIG_LIBUKDEBUG=y/unikraft",
+			expectError: false,
+		},
+		{
+			name:        "Valid prebuilt kernel",
+			input:       "local///unikraft",
+			expectError: false,
+		},
+		{
+			name:        "Invalid build without source",
+			input:       "build///unikraft",
+			expectError: true,
+			errorText:   "The source field of kernel is necessary to build it",
+		},
+		{
+			name:        "Invalid source without build",
+			input:       "local/app//unikraft",
+			expectError: true,
+			errorText:   "The source and config fields of kernel require from to be build",
+		},
+		{
+			name:        "Invalid config without build",
+			input:       "local//CONFIG_LIBUKDEBUG=y/unikraft",
+			expectError: true,
+			errorText:   "The source and config fields of kernel require from to be build",
+		},
+		{
+			name:        "Invalid build for unsupported framework",
+			input:       "build/app//linux",
+			expectError: true,
+			errorText:   "Building the kernel is not supported for linux",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "/")
+			k := Kernel{From: fields[0], Source: fields[1]}
+			if fields[2] != "" {
+				k.Config = []string{fields[2]}
+			}
+			err := ValidateKernelBuild(k, Platform{Framework: fields[3]})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileTest(t *testing.T) {
+	// The input has the form <image>/<marker>/<timeout>/<monitor>
+	tests := []testInfo{
+		{
+			name:        "Valid no test",
+			input:       "///qemu",
+			expectError: false,
+		},
+		{
+			name:        "Valid no test and firecracker",
+			input:       "///firecracker",
+			expectError: false,
+		},
+		{
+			name:        "Valid marker only",
+			input:       "/Hello//qemu",
+			expectError: false,
+		},
+		{
+			name:        "Valid marker, image and timeout",
+			input:       "foo/Hello/2m/qemu",
+			expectError: false,
+		},
+		{
+			name:        "Invalid missing marker",
+			input:       "foo//2m/qemu",
+			expectError: true,
+			errorText:   "The marker field of test is necessary",
+		},
+		{
+			name:        "Invalid timeout",
+			input:       "/Hello/foo/qemu",
+			expectError: true,
+			errorText:   "Invalid timeout",
+		},
+		{
+			name:        "Invalid short timeout",
+			input:       "/Hello/10ms/qemu",
+			expectError: true,
+			errorText:   "should be at least 1s",
+		},
+		{
+			name:        "Invalid monitor",
+			input:       "/Hello//firecracker",
+			expectError: true,
+			errorText:   "Smoke tests are not supported for firecracker",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "/")
+			st := SmokeTest{
+				Image:   fields[0],
+				Marker:  fields[1],
+				Timeout: fields[2],
+			}
+			plat := Platform{Framework: "foo", Monitor: fields[3]}
+			err := ValidateTest(st, plat)
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileMirrors(t *testing.T) {
+	// The input has the form <registry>=<mirror>
+	tests := []testInfo{
+		{
+			name:        "Valid mirror",
+			input:       "docker.io=mirror.local:5000",
+			expectError: false,
+		},
+		{
+			name:        "Valid mirror with path",
+			input:       "harbor.nbfc.io=mirror.local:5000/nbfc",
+			expectError: false,
+		},
+		{
+			name:        "Invalid empty registry",
+			input:       "=mirror.local:5000",
+			expectError: true,
+			errorText:   "The registry and the mirror of mirrors can not be empty",
+		},
+		{
+			name:        "Invalid empty mirror",
+			input:       "docker.io=",
+			expectError: true,
+			errorText:   "The registry and the mirror of mirrors can not be empty",
+		},
+		{
+			name:        "Invalid registry with path",
+			input:       "docker.io/library=mirror.local:5000",
+			expectError: true,
+			errorText:   "Invalid registry docker.io/library in mirrors",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			registry, mirror, _ := strings.Cut(tc.input, "=")
+			err := ValidateMirrors(Mirrors{registry: mirror})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileArtifacts(t *testing.T) {
+	// The input has the form <artifacts>|<rootfs from>|<rootfs include>
+	tests := []testInfo{
+		{
+			name:        "Valid kernel artifact without rootfs",
+			input:       "kernel|scratch|",
+			expectError: false,
+		},
+		{
+			name:        "Valid rootfs artifact from image",
+			input:       "rootfs|harbor.nbfc.io/foo|",
+			expectError: false,
+		},
+		{
+			name:        "Valid rootfs artifact with includes",
+			input:       "kernel,rootfs|scratch|app:/app",
+			expectError: false,
+		},
+		{
+			name:        "Invalid rootfs artifact without rootfs",
+			input:       "rootfs|scratch|",
+			expectError: true,
+			errorText:   "The rootfs artifact can not be exported without a rootfs",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parts := strings.Split(tc.input, "|")
+			artifacts := Artifacts{
+				Kernel: strings.Contains(parts[0], "kernel"),
+				Rootfs: strings.Contains(parts[0], "rootfs"),
+			}
+			rootfs := Rootfs{From: parts[1]}
+			if parts[2] != "" {
+				src, dst, _ := strings.Cut(parts[2], ":")
+				rootfs.Includes = []FileToInclude{{Src: src, Dst: dst}}
+			}
+			err := ValidateArtifacts(artifacts, rootfs)
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileBinary(t *testing.T) {
+	// The input has the form <binary from>:<binary path>|<framework>|<kernel from>|<rootfs from>|<rootfs type>
+	tests := []testInfo{
+		{
+			name:        "Valid no binary",
+			input:       "|linux|local||",
+			expectError: false,
+		},
+		{
+			name:        "Valid binary",
+			input:       "local:hello|unikraft|||",
+			expectError: false,
+		},
+		{
+			name:        "Valid binary with initrd from scratch",
+			input:       "local:hello|unikraft||scratch|initrd",
+			expectError: false,
+		},
+		{
+			name:        "Invalid binary without path",
+			input:       "local:|unikraft|||",
+			expectError: true,
+			errorText:   "The path field of binary is necessary",
+		},
+		{
+			name:        "Invalid binary for linux",
+			input:       "local:hello|linux|||",
+			expectError: true,
+			errorText:   "Running a binary is only supported for unikraft",
+		},
+		{
+			name:        "Invalid binary with kernel",
+			input:       "local:hello|unikraft|local||",
+			expectError: true,
+			errorText:   "The kernel field can not be combined with binary",
+		},
+		{
+			name:        "Invalid binary with rootfs from image",
+			input:       "local:hello|unikraft||harbor.nbfc.io/foo|",
+			expectError: true,
+			errorText:   "The binary field can only be combined with a rootfs from scratch",
+		},
+		{
+			name:        "Invalid binary with raw rootfs",
+			input:       "local:hello|unikraft|||raw",
+			expectError: true,
+			errorText:   "The binary field requires an initrd rootfs",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "|")
+			h := &Hops{
+				Platform: Platform{Framework: fields[1]},
+				Kernel:   Kernel{From: fields[2]},
+				Rootfs:   Rootfs{From: fields[3], Type: fields[4]},
+			}
+			from, path, _ := strings.Cut(fields[0], ":")
+			h.Binary = Binary{From: from, Path: path}
+			err := ValidateBinary(h)
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileNetwork(t *testing.T) {
+	// The input has the form <retries>/<timeout>
+	tests := []testInfo{
+		{
+			name:        "Valid empty",
+			input:       "0/",
+			expectError: false,
+		},
+		{
+			name:        "Valid retries and timeout",
+			input:       "3/10m",
+			expectError: false,
+		},
+		{
+			name:        "Invalid negative retries",
+			input:       "-1/",
+			expectError: true,
+			errorText:   "The retries field of network can not be negative",
+		},
+		{
+			name:        "Invalid timeout",
+			input:       "1/soon",
+			expectError: true,
+			errorText:   "Invalid network field: Invalid timeout soon",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "/")
+			retries, err := strconv.Atoi(fields[0])
+			require.NoError(t, err)
+			err = ValidateNetwork(NetworkPolicy{Retries: retries, Timeout: fields[1]})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileCertificates(t *testing.T) {
+	// The input has the form <cert>|<cert>...
+	tests := []testInfo{
+		{
+			name:        "Valid certificates",
+			input:       "certs/corp.crt|ca.pem",
+			expectError: false,
+		},
+		{
+			name:        "Invalid empty certificate",
+			input:       "certs/corp.crt|",
+			expectError: true,
+			errorText:   "The path of a certificate can not be empty",
+		},
+		{
+			name:        "Invalid absolute certificate",
+			input:       "/etc/ssl/corp.crt",
+			expectError: true,
+			errorText:   "The certificate /etc/ssl/corp.crt should be a file inside the build context",
+		},
+		{
+			name:        "Invalid certificate outside the context",
+			input:       "certs/../../corp.crt",
+			expectError: true,
+			errorText:   "should be a file inside the build context",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCertificates(strings.Split(tc.input, "|"))
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileFlavors(t *testing.T) {
+	// The input has the form <framework>|<flavor>|<flavor>...
+	tests := []testInfo{
+		{
+			name:        "Valid no flavors",
+			input:       "linux",
+			expectError: false,
+		},
+		{
+			name:        "Valid labels with linux",
+			input:       "linux|urunc|labels",
+			expectError: false,
+		},
+		{
+			name:        "Valid kraftkit with unikraft",
+			input:       "unikraft|kraftkit",
+			expectError: false,
+		},
+		{
+			name:        "Invalid kraftkit with linux",
+			input:       "linux|kraftkit",
+			expectError: true,
+			errorText:   "The kraftkit flavor is only supported for unikraft",
+		},
+		{
+			name:        "Invalid unknown flavor",
+			input:       "unikraft|kraft",
+			expectError: true,
+			errorText:   "Unknown flavor kraft",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "|")
+			err := ValidateFlavors(fields[1:], Platform{Framework: fields[0]})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileBuild(t *testing.T) {
+	// The input has the form <step>:<mode>|<step>:<mode>...
+	tests := []testInfo{
+		{
+			name:        "Valid no network modes",
+			input:       "",
+			expectError: false,
+		},
+		{
+			name:        "Valid all steps",
+			input:       "kernel:host|initrd:none|test:sandbox|check:none",
+			expectError: false,
+		},
+		{
+			name:        "Invalid unknown step",
+			input:       "kernel:host|rootfs:none",
+			expectError: true,
+			errorText:   "Unknown build step rootfs",
+		},
+		{
+			name:        "Invalid unknown mode",
+			input:       "kernel:bridge",
+			expectError: true,
+			errorText:   "Unknown network mode bridge for step kernel",
+		},
+		{
+			name:        "Invalid empty mode",
+			input:       "test:",
+			expectError: true,
+			errorText:   "Unknown network mode  for step test",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := BuildOptions{Network: map[string]string{}}
+			if tc.input != "" {
+				for _, entry := range strings.Split(tc.input, "|") {
+					step, mode, _ := strings.Cut(entry, ":")
+					b.Network[step] = mode
+				}
+			}
+			err := ValidateBuild(b)
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileBase(t *testing.T) {
+	// The input has the form <framework>|<base>|<rootfs from>|<rootfs type>
+	tests := []testInfo{
+		{
+			name:        "Valid no base",
+			input:       "rumprun||harbor.nbfc.io/rootfs|",
+			expectError: false,
+		},
+		{
+			name:        "Valid base without rootfs",
+			input:       "rumprun|harbor.nbfc.io/base|scratch|",
+			expectError: false,
+		},
+		{
+			name:        "Valid base with initrd",
+			input:       "unikraft|harbor.nbfc.io/base|local|",
+			expectError: false,
+		},
+		{
+			name:        "Valid scratch base with block",
+			input:       "rumprun|scratch|local|block",
+			expectError: false,
+		},
+		{
+			name:        "Invalid local base",
+			input:       "rumprun|local|scratch|",
+			expectError: true,
+			errorText:   "The base field can not be local",
+		},
+		{
+			name:        "Invalid raw rootfs",
+			input:       "unikraft|harbor.nbfc.io/base|harbor.nbfc.io/rootfs|raw",
+			expectError: true,
+			errorText:   "The base field can not be combined with a raw rootfs",
+		},
+		{
+			name:        "Invalid default raw rootfs",
+			input:       "linux|harbor.nbfc.io/base|harbor.nbfc.io/rootfs|",
+			expectError: true,
+			errorText:   "The base field can not be combined with a raw rootfs",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "|")
+			err := ValidateBase(fields[1],
+				Rootfs{From: fields[2], Type: fields[3]},
+				Platform{Framework: fields[0], Monitor: "qemu"})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileScan(t *testing.T) {
+	// The input has the form <enabled>|<image>|<severity>
+	tests := []testInfo{
+		{
+			name:        "Valid no scan",
+			input:       "false||",
+			expectError: false,
+		},
+		{
+			name:        "Valid default scan",
+			input:       "true||",
+			expectError: false,
+		},
+		{
+			name:        "Valid image and severity",
+			input:       "true|trivy:local|Critical",
+			expectError: false,
+		},
+		{
+			name:        "Invalid severity",
+			input:       "true||severe",
+			expectError: true,
+			errorText:   "Unknown severity severe",
+		},
+		{
+			name:        "Invalid severity without enabled",
+			input:       "false||high",
+			expectError: true,
+			errorText:   "The enabled field of scan is necessary",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "|")
+			err := ValidateScan(Scan{
+				Enabled:  fields[0] == "true",
+				Image:    fields[1],
+				Severity: fields[2],
+			})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileInitrds(t *testing.T) {
+	// The input has the form <from>|<type>|<include>|<initrds>, where each
+	// initrd has the form <name>:<include> and they are separated by spaces
+	tests := []testInfo{
+		{
+			name:        "Valid initrds",
+			input:       "scratch|initrd||modules:lib/modules app:app",
+			expectError: false,
+		},
+		{
+			name:        "Valid empty from",
+			input:       "|initrd||app:app",
+			expectError: false,
+		},
+		{
+			name:        "Invalid from image",
+			input:       "harbor.nbfc.io/rootfs|initrd||app:app",
+			expectError: true,
+			errorText:   "The initrds field of rootfs can only be set, if from is scratch",
+		},
+		{
+			name:        "Invalid type",
+			input:       "scratch|raw||app:app",
+			expectError: true,
+			errorText:   "The initrds field of rootfs can only be set, if type is initrd",
+		},
+		{
+			name:        "Invalid empty type",
+			input:       "scratch|||app:app",
+			expectError: true,
+			errorText:   "The initrds field of rootfs can only be set, if type is initrd",
+		},
+		{
+			name:        "Invalid include",
+			input:       "scratch|initrd|foo|app:app",
+			expectError: true,
+			errorText:   "The initrds field of rootfs can not be combined with include",
+		},
+		{
+			name:        "Invalid empty name",
+			input:       "scratch|initrd||:app",
+			expectError: true,
+			errorText:   "The name field of an initrd is necessary",
+		},
+		{
+			name:        "Invalid name with comma",
+			input:       "scratch|initrd||mod,app:app",
+			expectError: true,
+			errorText:   "The name of initrd mod,app can not contain commas",
+		},
+		{
+			name:        "Invalid duplicate name",
+			input:       "scratch|initrd||app:app app:foo",
+			expectError: true,
+			errorText:   "Duplicate initrd app",
+		},
+		{
+			name:        "Invalid empty include",
+			input:       "scratch|initrd||modules: app:app",
+			expectError: true,
+			errorText:   "The include field of initrd modules is necessary",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "|")
+			rfs := Rootfs{From: fields[0], Type: fields[1]}
+			if fields[2] != "" {
+				rfs.Includes = []FileToInclude{{Src: fields[2], Dst: fields[2]}}
+			}
+			for _, part := range strings.Split(fields[3], " ") {
+				name, src, _ := strings.Cut(part, ":")
+				initrd := Initrd{Name: name}
+				if src != "" {
+					initrd.Includes = []FileToInclude{{Src: src, Dst: src}}
+				}
+				rfs.Initrds = append(rfs.Initrds, initrd)
+			}
+			err := ValidateRootfs(rfs)
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileModules(t *testing.T) {
+	// The input has the form <framework>|<kernel from>|<modules from>|<version>|<include>|<rootfs include>,
+	// where the modules to include are separated by spaces
+	tests := []testInfo{
+		{
+			name:        "Valid no modules",
+			input:       "unikraft|local||||",
+			expectError: false,
+		},
+		{
+			name:        "Valid modules of the kernel image",
+			input:       "linux|harbor.nbfc.io/kernel|||virtio_net ext4|app",
+			expectError: false,
+		},
+		{
+			name:        "Valid modules image with local kernel",
+			input:       "linux|local|harbor.nbfc.io/modules|6.6.0|virtio_net|app",
+			expectError: false,
+		},
+		{
+			name:        "Valid auto framework",
+			input:       "auto|harbor.nbfc.io/kernel|||virtio_net|app",
+			expectError: false,
+		},
+		{
+			name:        "Invalid missing include",
+			input:       "linux|harbor.nbfc.io/kernel|harbor.nbfc.io/modules|||app",
+			expectError: true,
+			errorText:   "The include field of modules is necessary",
+		},
+		{
+			name:        "Invalid module name",
+			input:       "linux|harbor.nbfc.io/kernel|||-v|app",
+			expectError: true,
+			errorText:   "Invalid module name \"-v\"",
+		},
+		{
+			name:        "Invalid framework",
+			input:       "unikraft|harbor.nbfc.io/kernel|||virtio_net|app",
+			expectError: true,
+			errorText:   "The modules field is only supported for linux",
+		},
+		{
+			name:        "Invalid local from",
+			input:       "linux|harbor.nbfc.io/kernel|local||virtio_net|app",
+			expectError: true,
+			errorText:   "The from field of modules can not be local",
+		},
+		{
+			name:        "Invalid missing from with local kernel",
+			input:       "linux|local|||virtio_net|app",
+			expectError: true,
+			errorText:   "The from field of modules is necessary, if the kernel is not an OCI image",
+		},
+		{
+			name:        "Invalid missing from with built kernel",
+			input:       "linux|build|||virtio_net|app",
+			expectError: true,
+			errorText:   "The from field of modules is necessary, if the kernel is not an OCI image",
+		},
+		{
+			name:        "Invalid missing rootfs",
+			input:       "linux|harbor.nbfc.io/kernel|||virtio_net|",
+			expectError: true,
+			errorText:   "The modules field requires a rootfs with files to include",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "|")
+			m := Modules{From: fields[2], Version: fields[3]}
+			if fields[4] != "" {
+				m.Include = strings.Split(fields[4], " ")
+			}
+			rfs := Rootfs{From: "scratch"}
+			if fields[5] != "" {
+				rfs.Includes = []FileToInclude{{Src: fields[5], Dst: fields[5]}}
+			}
+			err := ValidateModules(m, Kernel{From: fields[1]}, rfs,
+				Platform{Framework: fields[0], Monitor: "qemu"})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileDtb(t *testing.T) {
+	// The input has the form <from>|<path>|<arch>|<monitor>
+	tests := []testInfo{
+		{
+			name:        "Valid no dtb",
+			input:       "||amd64|qemu",
+			expectError: false,
+		},
+		{
+			name:        "Valid local dtb",
+			input:       "local|board.dtb|arm64|qemu",
+			expectError: false,
+		},
+		{
+			name:        "Valid image dtb with aarch64",
+			input:       "harbor.nbfc.io/dtbs|/board.dtb|aarch64|qemu",
+			expectError: false,
+		},
+		{
+			name:        "Valid dtb without arch",
+			input:       "local|board.dtb||qemu",
+			expectError: false,
+		},
+		{
+			name:        "Invalid missing from",
+			input:       "|board.dtb|arm64|qemu",
+			expectError: true,
+			errorText:   "The from field of dtb is necessary",
+		},
+		{
+			name:        "Invalid missing path",
+			input:       "local||arm64|qemu",
+			expectError: true,
+			errorText:   "The path field of dtb is necessary",
+		},
+		{
+			name:        "Invalid scratch from",
+			input:       "scratch|board.dtb|arm64|qemu",
+			expectError: true,
+			errorText:   "The from field of dtb should be local or an OCI image",
+		},
+		{
+			name:        "Invalid arch",
+			input:       "local|board.dtb|amd64|qemu",
+			expectError: true,
+			errorText:   "The dtb field is only supported for arm64",
+		},
+		{
+			name:        "Invalid firecracker",
+			input:       "local|board.dtb|arm64|firecracker",
+			expectError: true,
+			errorText:   "The dtb field is not supported for firecracker",
+		},
+		{
+			name:        "Invalid cloud-hypervisor",
+			input:       "local|board.dtb|arm64|cloud-hypervisor",
+			expectError: true,
+			errorText:   "The dtb field is not supported for cloud-hypervisor",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "|")
+			err := ValidateDtb(Dtb{From: fields[0], Path: fields[1]},
+				Platform{Framework: "linux", Arch: fields[2], Monitor: fields[3]})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileResources(t *testing.T) {
+	// The input has the form <memory>|<cpu>
+	tests := []testInfo{
+		{
+			name:        "Valid no resources",
+			input:       "|",
+			expectError: false,
+		},
+		{
+			name:        "Valid binary memory and cpus",
+			input:       "256Mi|1",
+			expectError: false,
+		},
+		{
+			name:        "Valid decimal memory and millicpus",
+			input:       "1G|500m",
+			expectError: false,
+		},
+		{
+			name:        "Valid fractional cpus",
+			input:       "|0.5",
+			expectError: false,
+		},
+		{
+			name:        "Invalid memory unit",
+			input:       "256MB|1",
+			expectError: true,
+			errorText:   "The memory field of resources should be a quantity like 256Mi, got 256MB",
+		},
+		{
+			name:        "Invalid cpu",
+			input:       "256Mi|two",
+			expectError: true,
+			errorText:   "The cpu field of resources should be a quantity like 1 or 500m, got two",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := strings.Split(tc.input, "|")
+			err := ValidateResources(Resources{Memory: fields[0], CPU: fields[1]})
+			if tc.expectError {
+				require.Error(t, err, "Expected an error, got nil")
+				require.Contains(t, err.Error(), tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileMetadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		metadata  Metadata
+		errorText string
+	}{
+		{
+			name: "Valid no metadata",
+		},
+		{
+			name: "Valid all fields",
+			metadata: Metadata{
+				Authors:       []string{"Nubificus LTD <info@nubificus.co.uk>"},
+				Description:   "Nginx on Unikraft",
+				Documentation: "https://github.com/nubificus/bunny",
+			},
+		},
+		{
+			name:      "Invalid empty author",
+			metadata:  Metadata{Authors: []string{"Jane Doe", " "}},
+			errorText: "The authors field of metadata should not have empty entries",
+		},
+		{
+			name:      "Invalid documentation without scheme",
+			metadata:  Metadata{Documentation: "github.com/nubificus/bunny"},
+			errorText: "The documentation field of metadata should be an http or https URL, got github.com/nubificus/bunny",
+		},
+		{
+			name:      "Invalid documentation scheme",
+			metadata:  Metadata{Documentation: "file:///README.md"},
+			errorText: "The documentation field of metadata should be an http or https URL, got file:///README.md",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMetadata(tc.metadata)
+			if tc.errorText != "" {
+				require.ErrorContains(t, err, tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileShutdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		shutdown  Shutdown
+		errorText string
+	}{
+		{
+			name: "Valid no shutdown",
+		},
+		{
+			name:     "Valid acpi with signal",
+			shutdown: Shutdown{Method: "acpi", Signal: "SIGTERM"},
+		},
+		{
+			name:     "Valid kill with signal number",
+			shutdown: Shutdown{Method: "kill", Signal: "9"},
+		},
+		{
+			name:      "Invalid method",
+			shutdown:  Shutdown{Method: "reboot"},
+			errorText: "The method field of shutdown should be acpi or kill, got reboot",
+		},
+		{
+			name:      "Invalid signal",
+			shutdown:  Shutdown{Signal: "SIGTERM!"},
+			errorText: "The signal field of shutdown should be a signal: Invalid signal \"SIGTERM!\"",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateShutdown(tc.shutdown)
+			if tc.errorText != "" {
+				require.ErrorContains(t, err, tc.errorText)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBunnyfileExcludes(t *testing.T) {
+	rootfs := Rootfs{
+		From: "scratch",
+		Type: "initrd",
+		Includes: []FileToInclude{
+			{From: "local", Src: "app", Dst: "/app", Exclude: []string{"**/*.log", "cache/*"}},
+		},
+	}
+	require.NoError(t, ValidateRootfs(rootfs))
+
+	rootfs.Includes[0].Exclude = []string{"**/*.log", ""}
+	require.ErrorContains(t, ValidateRootfs(rootfs), "Empty exclude pattern for app")
+
+	rootfs.Includes[0].Exclude = []string{"[a-"}
+	require.ErrorContains(t, ValidateRootfs(rootfs), `Invalid exclude pattern "[a-" for app: syntax error in pattern`)
+
+	_, err := ParseBunnyfile([]byte(`version: v0.1
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: kernel
+rootfs:
+  type: initrd
+  initrds:
+    - name: app
+      include:
+        - "app:/app:!"
+`))
+	require.ErrorContains(t, err, "Empty exclude pattern for app")
+}
+
+func TestValidateBunnyfileIncludeModeOwner(t *testing.T) {
+	rootfs := Rootfs{
+		From: "scratch",
+		Type: "raw",
+		Includes: []FileToInclude{
+			{From: "local", Src: "app", Dst: "/app", Mode: "0755", Owner: "1000:100"},
+		},
+	}
+	require.NoError(t, ValidateRootfs(rootfs))
+
+	rootfs.Includes[0].Mode = "rwx"
+	require.ErrorContains(t, ValidateRootfs(rootfs), `Invalid mode of app: Invalid mode "rwx", expected octal permissions`)
+	rootfs.Includes[0].Mode = "17777"
+	require.ErrorContains(t, ValidateRootfs(rootfs), `Invalid mode "17777"`)
+
+	rootfs.Includes[0].Mode = "644"
+	rootfs.Includes[0].Owner = "nobody"
+	require.ErrorContains(t, ValidateRootfs(rootfs), `Invalid owner of app: Invalid owner "nobody", expected uid:gid`)
+}
+
+func TestValidateBunnyfileSourceRefs(t *testing.T) {
+	rootfs := Rootfs{From: "registry:5000/rootfs@sha256:" + strings.Repeat("a", 64), Path: "/rootfs"}
+	require.NoError(t, ValidateRootfs(rootfs))
+	rootfs.From = "registry:port/rootfs"
+	require.ErrorContains(t, ValidateRootfs(rootfs), `Invalid from field of rootfs: Invalid image reference "registry:port/rootfs"`)
+
+	rootfs = Rootfs{
+		From:     "scratch",
+		Type:     "initrd",
+		Includes: []FileToInclude{{From: "alpine:3.20:x", Src: "/bin/sh", Dst: "/bin/sh"}},
+	}
+	require.ErrorContains(t, ValidateRootfs(rootfs), `Invalid from field of the include /bin/sh`)
+
+	require.ErrorContains(t, ValidateDtb(Dtb{From: "Dtb", Path: "/a.dtb"}, Platform{Arch: "arm64"}), "Invalid from field of dtb")
+	require.NoError(t, ValidateBase("scratch", Rootfs{From: "scratch"}, Platform{}))
+	require.ErrorContains(t, ValidateBase("base image", Rootfs{}, Platform{}), `Invalid base field: Invalid image reference "base image"`)
+	require.ErrorContains(t, ValidateBase("scratch", Rootfs{From: "harbor.nbfc.io/foo"}, Platform{Framework: unikraftName}),
+		"The base field can not be combined with a raw rootfs")
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"gopkg.in/yaml.v3"
+)
+
+// The annotation and label with the name of the variant of an image
+const VariantAnnotation string = "io.bunny.variant"
+
+// The names of variants are valid tags of images and directories
+var variantNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// Variant is one of the documents of a bunnyfile with many YAML documents.
+// Each document is a bunnyfile of its own and builds one image of a family
+// (e.g. for qemu and for firecracker, or a debug and a release build).
+type Variant struct {
+	// The name of the variant, from the variant field of the document
+	Name string
+	// The document of the variant
+	File []byte
+}
+
+// ParseVariants splits a bunnyfile with many YAML documents to its variants.
+// Every document should have a unique variant field. Files with a single
+// document, as well as files that are not YAML (e.g. Containerfiles), have no
+// variants, so it returns nil for them.
+func ParseVariants(fileBytes []byte) ([]Variant, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(fileBytes))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if len(docs) == 0 {
+				// The parsing of the file reports the error
+				return nil, nil
+			}
+			return nil, fmt.Errorf("Invalid document %d of the bunnyfile: %v", len(docs)+1, err)
+		}
+		// Skip empty documents, e.g. after a trailing ---
+		if len(doc.Content) == 0 || doc.Content[0].ShortTag() == "!!null" {
+			continue
+		}
+		docs = append(docs, &doc)
+	}
+	if len(docs) < 2 {
+		return nil, nil
+	}
+
+	variants := make([]Variant, 0, len(docs))
+	seen := map[string]bool{}
+	for j, doc := range docs {
+		var v struct {
+			Variant string `yaml:"variant"`
+		}
+		err := doc.Decode(&v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid document %d of the bunnyfile: %v", j+1, err)
+		}
+		if v.Variant == "" {
+			return nil, fmt.Errorf("The variant field of document %d is necessary in a bunnyfile with many documents", j+1)
+		}
+		err = ValidateVariant(v.Variant)
+		if err != nil {
+			return nil, err
+		}
+		if seen[v.Variant] {
+			return nil, fmt.Errorf("The bunnyfile has more than one document for variant %s", v.Variant)
+		}
+		seen[v.Variant] = true
+		file, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to marshal document %d of the bunnyfile: %v", j+1, err)
+		}
+		variants = append(variants, Variant{Name: v.Variant, File: file})
+	}
+
+	return variants, nil
+}
+
+// variantNames returns the names of the given variants, separated by commas
+func variantNames(variants []Variant) string {
+	names := make([]string, len(variants))
+	for j, v := range variants {
+		names[j] = v.Name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// SelectVariant returns the document of the variant with the given name. A
+// file with a single document is returned as is, if no variant is requested.
+// A file with many documents always needs a name.
+func SelectVariant(fileBytes []byte, name string) ([]byte, error) {
+	variants, err := ParseVariants(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("Cannot select variant %s of a file without variants", name)
+		}
+		return fileBytes, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("The bunnyfile has the variants %s, choose one of them", variantNames(variants))
+	}
+	for _, v := range variants {
+		if v.Name == name {
+			return v.File, nil
+		}
+	}
+
+	return nil, fmt.Errorf("The bunnyfile has no variant %s, only %s", name, variantNames(variants))
+}
+
+// ApplyVariants combines the results of the variants of a bunnyfile, in the
+// order of the given names, into a result with a reference per variant under
+// the name of the variant. The artifacts of a variant, if any, get the name of
+// the variant and their target (e.g. qemu-kernel). Exporters that support
+// multiple references (e.g. local) store each one in a separate directory,
+// while the image exporter creates an index with all of them. Buildkit
+// matches the annotations of manifests to the references by platform, which
+// is the same for all variants, so the manifests keep only the annotations
+// that all the variants share. The rest remain in the urunc.json of each
+// variant. Similarly, the variants should have the same image names, if any.
+func ApplyVariants(names []string, results []*client.Result) (*client.Result, error) {
+	combined := client.NewResult()
+	ps := exptypes.Platforms{}
+	var annotations map[string][]byte
+	var imageName []byte
+	for j, res := range results {
+		name := names[j]
+		resPs, err := exptypes.ParsePlatforms(res.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get platforms of variant %s: %v", name, err)
+		}
+		for k, p := range resPs.Platforms {
+			id := name
+			if k > 0 {
+				id = name + "-" + p.ID
+			}
+			ref, ok := res.FindRef(p.ID)
+			if !ok {
+				return nil, fmt.Errorf("Failed to find reference %s of variant %s", p.ID, name)
+			}
+			combined.AddRef(id, ref)
+			ps.Platforms = append(ps.Platforms, exptypes.Platform{ID: id, Platform: p.Platform})
+			config, ok := res.Metadata[exptypes.ExporterImageConfigKey+"/"+p.ID]
+			if !ok {
+				config, ok = res.Metadata[exptypes.ExporterImageConfigKey]
+			}
+			if ok {
+				combined.AddMeta(exptypes.ExporterImageConfigKey+"/"+id, config)
+			}
+			for _, att := range res.Attestations[p.ID] {
+				combined.AddAttestation(id, att)
+			}
+		}
+
+		variantAnnotations := map[string][]byte{}
+		for k, v := range res.Metadata {
+			switch {
+			case k == exptypes.ExporterPlatformsKey || strings.HasPrefix(k, exptypes.ExporterImageConfigKey):
+				// Already per reference
+			case strings.HasPrefix(k, "annotation"):
+				variantAnnotations[k] = v
+			case k == exptypes.ExporterImageNameKey:
+				// The index of the family can have only one set of names
+				if j > 0 && !bytes.Equal(v, imageName) {
+					return nil, fmt.Errorf("The variants %s and %s have different image names, but they share the index of the family", names[0], name)
+				}
+				imageName = v
+			default:
+				combined.AddMeta(k+"/"+name, v)
+			}
+		}
+		if j == 0 {
+			annotations = variantAnnotations
+			continue
+		}
+		for k, v := range annotations {
+			w, ok := variantAnnotations[k]
+			if !ok || !bytes.Equal(v, w) {
+				delete(annotations, k)
+			}
+		}
+	}
+	for k, v := range annotations {
+		combined.AddMeta(k, v)
+	}
+	if imageName != nil {
+		combined.AddMeta(exptypes.ExporterImageNameKey, imageName)
+	}
+
+	psBytes, err := json.Marshal(ps)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal platforms of variants: %v", err)
+	}
+	combined.AddMeta(exptypes.ExporterPlatformsKey, psBytes)
+
+	return combined, nil
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+const variantsBunnyfile = `#syntax=harbor.nbfc.io/nubificus/bunny:latest
+version: v0.1
+variant: qemu
+platforms:
+  framework: linux
+  monitor: qemu
+kernel:
+  from: local
+  path: vmlinux-qemu
+cmd: ["/init"]
+---
+version: v0.1
+variant: firecracker
+platforms:
+  framework: linux
+  monitor: firecracker
+kernel:
+  from: local
+  path: vmlinux-fc
+cmd: ["/init"]
+---
+`
+
+func TestVariantsParse(t *testing.T) {
+	variants, err := ParseVariants([]byte(variantsBunnyfile))
+	require.NoError(t, err)
+	require.Len(t, variants, 2)
+	require.Equal(t, "qemu", variants[0].Name)
+	require.Equal(t, "firecracker", variants[1].Name)
+	for j, monitor := range []string{"qemu", "firecracker"} {
+		h, err := ParseBunnyfile(variants[j].File)
+		require.NoError(t, err)
+		require.Equal(t, monitor, h.Platform.Monitor)
+		require.Equal(t, monitor, h.Variant)
+	}
+
+	t.Run("Single document", func(t *testing.T) {
+		variants, err := ParseVariants([]byte("version: v0.1\nvariant: qemu\n---\n"))
+		require.NoError(t, err)
+		require.Nil(t, variants)
+	})
+	t.Run("Containerfile", func(t *testing.T) {
+		variants, err := ParseVariants([]byte("FROM alpine:3.20\nRUN echo: hello\n"))
+		require.NoError(t, err)
+		require.Nil(t, variants)
+	})
+
+	tests := []struct {
+		name      string
+		file      string
+		errorText string
+	}{
+		{"Missing variant", "variant: a\n---\nversion: v0.1\n", "The variant field of document 2 is necessary in a bunnyfile with many documents"},
+		{"Same variant", "variant: a\n---\nvariant: a\n", "The bunnyfile has more than one document for variant a"},
+		{"Invalid variant", "variant: a\n---\nvariant: -b\n", `Invalid variant "-b": it should be a valid tag of an image`},
+		{"Invalid document", "variant: a\n---\nvariant: [b\n", "Invalid document 2 of the bunnyfile"},
+		{"Not a mapping", "variant: a\n---\n- b\n", "Invalid document 2 of the bunnyfile"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseVariants([]byte(tc.file))
+			require.ErrorContains(t, err, tc.errorText)
+		})
+	}
+}
+
+func TestVariantsSelect(t *testing.T) {
+	file, err := SelectVariant([]byte(variantsBunnyfile), "firecracker")
+	require.NoError(t, err)
+	h, err := ParseBunnyfile(file)
+	require.NoError(t, err)
+	require.Equal(t, "vmlinux-fc", h.Kernel.Path)
+
+	_, err = SelectVariant([]byte(variantsBunnyfile), "")
+	require.ErrorContains(t, err, "The bunnyfile has the variants qemu, firecracker, choose one of them")
+	_, err = SelectVariant([]byte(variantsBunnyfile), "xen")
+	require.ErrorContains(t, err, "The bunnyfile has no variant xen, only qemu, firecracker")
+
+	t.Run("Without variants", func(t *testing.T) {
+		file := []byte(kernelsBunnyfile)
+		selected, err := SelectVariant(file, "")
+		require.NoError(t, err)
+		require.Equal(t, file, selected)
+		_, err = SelectVariant(file, "qemu")
+		require.ErrorContains(t, err, "Cannot select variant qemu of a file without variants")
+	})
+}
+
+func TestVariantsValidate(t *testing.T) {
+	require.NoError(t, ValidateVariant(""))
+	require.NoError(t, ValidateVariant("debug_1.2-qemu"))
+	require.ErrorContains(t, ValidateVariant(".debug"), "Invalid variant")
+	require.ErrorContains(t, ValidateVariant("qemu/debug"), "Invalid variant")
+}
+
+func TestVariantsToPack(t *testing.T) {
+	file, err := SelectVariant([]byte(variantsBunnyfile), "qemu")
+	require.NoError(t, err)
+	h, err := ParseBunnyfile(file)
+	require.NoError(t, err)
+	instr, err := ToPack(context.TODO(), h, "context")
+	require.NoError(t, err)
+	require.Equal(t, "qemu", instr.Annots[VariantAnnotation])
+	require.False(t, IsUruncAnnotation(VariantAnnotation))
+}
+
+// variantResult returns the result of a variant with an image for the given
+// architecture and the given annotations
+func variantResult(ref client.Reference, arch string, annots map[string]string) *client.Result {
+	res := client.NewResult()
+	res.SetRef(ref)
+	res.AddMeta(exptypes.ExporterImageConfigKey, []byte(`{"os":"linux","architecture":"`+arch+`"}`))
+	for k, v := range annots {
+		res.AddMeta(exptypes.AnnotationManifestKey(nil, k), []byte(v))
+	}
+	res.AddMeta(BuildSummaryMetaKey, []byte(arch))
+
+	return res
+}
+
+func TestVariantsApply(t *testing.T) {
+	qemu := &fakeRef{dirs: map[string]bool{"qemu": true}}
+	firecracker := &fakeRef{dirs: map[string]bool{"firecracker": true}}
+	rootfs := &fakeRef{dirs: map[string]bool{"rootfs": true}}
+	qemuRes := variantResult(qemu, "amd64", map[string]string{
+		"com.urunc.unikernel.hypervisor": "qemu",
+		"com.urunc.unikernel.binary":     DefaultKernelPath,
+	})
+	err := AddAttestation(qemuRes, &fakeRef{}, "/urunc.json", UruncPredicateType)
+	require.NoError(t, err)
+	fcRes := variantResult(firecracker, "amd64", map[string]string{
+		"com.urunc.unikernel.hypervisor": "firecracker",
+		"com.urunc.unikernel.binary":     DefaultKernelPath,
+	})
+	err = ApplyArtifacts(fcRes, map[string]client.Reference{TargetRootfs: rootfs}, ocispecs.Image{})
+	require.NoError(t, err)
+
+	res, err := ApplyVariants([]string{"qemu", "firecracker"}, []*client.Result{qemuRes, fcRes})
+	require.NoError(t, err)
+	require.Nil(t, res.Ref)
+	require.Equal(t, map[string]client.Reference{
+		"qemu":               qemu,
+		"firecracker":        firecracker,
+		"firecracker-rootfs": rootfs,
+	}, res.Refs)
+
+	var ps exptypes.Platforms
+	err = json.Unmarshal(res.Metadata[exptypes.ExporterPlatformsKey], &ps)
+	require.NoError(t, err)
+	var ids []string
+	for _, p := range ps.Platforms {
+		ids = append(ids, p.ID)
+		require.Equal(t, "linux", p.Platform.OS)
+	}
+	require.Equal(t, []string{"qemu", "firecracker", "firecracker-rootfs"}, ids)
+	require.Equal(t, `{"os":"linux","architecture":"amd64"}`, string(res.Metadata[exptypes.ExporterImageConfigKey+"/qemu"]))
+	require.Contains(t, res.Metadata, exptypes.ExporterImageConfigKey+"/firecracker")
+	require.Contains(t, res.Metadata, exptypes.ExporterImageConfigKey+"/firecracker-rootfs")
+	require.NotContains(t, res.Metadata, exptypes.ExporterImageConfigKey)
+
+	// Only the annotations that all the variants share reach the manifests
+	require.Equal(t, DefaultKernelPath, string(res.Metadata[exptypes.AnnotationManifestKey(nil, "com.urunc.unikernel.binary")]))
+	require.NotContains(t, res.Metadata, exptypes.AnnotationManifestKey(nil, "com.urunc.unikernel.hypervisor"))
+
+	// The rest of the metadata is per variant
+	require.Equal(t, "amd64", string(res.Metadata[BuildSummaryMetaKey+"/qemu"]))
+	require.Contains(t, res.Metadata, BuildSummaryMetaKey+"/firecracker")
+
+	require.Len(t, res.Attestations["qemu"], 1)
+	require.Empty(t, res.Attestations["firecracker"])
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+
+	"github.com/moby/buildkit/frontend/gateway/client"
+)
+
+// requiredFiles returns the list of files that the final image must contain
+// for urunc to be able to execute it, based on the given annotations. The
+// kernels of the monitors follow, sorted by monitor, unless they are files
+// of the list already.
+func requiredFiles(annots map[string]string) []string {
+	files := []string{uruncJSONPath}
+
+	for _, annot := range []string{
+		"com.urunc.unikernel.binary",
+		"com.urunc.unikernel.initrd",
+		"com.urunc.unikernel.block",
+		DtbAnnotation,
+	} {
+		if annots[annot] != "" {
+			files = append(files, path.Join("/", annots[annot]))
+		}
+	}
+
+	var variants []string
+	for annot := range annots {
+		if isKernelVariantAnnotation(annot) {
+			variants = append(variants, annot)
+		}
+	}
+	sort.Strings(variants)
+	for _, annot := range variants {
+		file := path.Join("/", annots[annot])
+		if annots[annot] != "" && !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// VerifyResult checks that all files required by urunc exist in the solved
+// result and that they are regular, non-empty files. All failed checks are
+// reported together.
+func VerifyResult(ctx context.Context, ref client.Reference, annots map[string]string) error {
+	var errs []error
+
+	if ref == nil {
+		return fmt.Errorf("No reference to verify")
+	}
+
+	for _, file := range requiredFiles(annots) {
+		st, err := ref.StatFile(ctx, client.StatRequest{
+			Path: file,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Required file %s is missing: %v", file, err))
+			continue
+		}
+		if os.FileMode(st.Mode).IsDir() {
+			errs = append(errs, fmt.Errorf("Required file %s is a directory", file))
+			continue
+		}
+		if st.Size == 0 {
+			errs = append(errs, fmt.Errorf("Required file %s is empty", file))
+		}
+	}
+
+	return errors.Join(errs...)
+}
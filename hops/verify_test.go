@@ -0,0 +1,183 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// fakeRef is a client.Reference backed by an in-memory map of
+// file paths to their contents.
+type fakeRef struct {
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+func (r *fakeRef) ToState() (llb.State, error) {
+	return llb.Scratch(), nil
+}
+
+func (r *fakeRef) Evaluate(_ context.Context) error {
+	return nil
+}
+
+func (r *fakeRef) ReadFile(_ context.Context, req client.ReadRequest) ([]byte, error) {
+	content, ok := r.files[req.Filename]
+	if !ok {
+		return nil, fmt.Errorf("%s: no such file or directory", req.Filename)
+	}
+	if req.Range != nil {
+		start := min(req.Range.Offset, len(content))
+		end := min(req.Range.Offset+req.Range.Length, len(content))
+		return content[start:end], nil
+	}
+
+	return content, nil
+}
+
+func (r *fakeRef) StatFile(_ context.Context, req client.StatRequest) (*fstypes.Stat, error) {
+	if r.dirs[req.Path] {
+		return &fstypes.Stat{
+			Path: req.Path,
+			Mode: uint32(os.ModeDir | 0755),
+		}, nil
+	}
+	content, ok := r.files[req.Path]
+	if !ok {
+		return nil, fmt.Errorf("%s: no such file or directory", req.Path)
+	}
+
+	return &fstypes.Stat{
+		Path: req.Path,
+		Mode: 0644,
+		Size: int64(len(content)),
+	}, nil
+}
+
+func (r *fakeRef) ReadDir(ctx context.Context, req client.ReadDirRequest) ([]*fstypes.Stat, error) {
+	var names []string
+	for name := range r.dirs {
+		names = append(names, name)
+	}
+	for name := range r.files {
+		names = append(names, name)
+	}
+	var entries []*fstypes.Stat
+	for _, name := range names {
+		if name == req.Path || path.Dir(name) != req.Path {
+			continue
+		}
+		st, err := r.StatFile(ctx, client.StatRequest{Path: name})
+		if err != nil {
+			return nil, err
+		}
+		st.Path = path.Base(name)
+		entries = append(entries, st)
+	}
+
+	return entries, nil
+}
+
+func TestVerifyRequiredFiles(t *testing.T) {
+	t.Run("No annotations", func(t *testing.T) {
+		files := requiredFiles(map[string]string{})
+		require.Equal(t, []string{uruncJSONPath}, files)
+	})
+	t.Run("Kernel and initrd", func(t *testing.T) {
+		files := requiredFiles(map[string]string{
+			"com.urunc.unikernel.binary": "kernel",
+			"com.urunc.unikernel.initrd": DefaultRootfsPath,
+		})
+		require.Equal(t, []string{uruncJSONPath, "/kernel", DefaultRootfsPath}, files)
+	})
+	t.Run("Kernel and block", func(t *testing.T) {
+		files := requiredFiles(map[string]string{
+			"com.urunc.unikernel.binary": DefaultKernelPath,
+			"com.urunc.unikernel.block":  "/disk.img",
+		})
+		require.Equal(t, []string{uruncJSONPath, DefaultKernelPath, "/disk.img"}, files)
+	})
+	t.Run("Kernel and dtb", func(t *testing.T) {
+		files := requiredFiles(map[string]string{
+			"com.urunc.unikernel.binary": DefaultKernelPath,
+			DtbAnnotation:                DefaultDtbPath,
+		})
+		require.Equal(t, []string{uruncJSONPath, DefaultKernelPath, DefaultDtbPath}, files)
+	})
+	t.Run("Kernel per monitor", func(t *testing.T) {
+		files := requiredFiles(map[string]string{
+			"com.urunc.unikernel.binary":             DefaultKernelPath,
+			KernelVariantAnnotPrefix + "qemu":        DefaultKernelPath,
+			KernelVariantAnnotPrefix + "firecracker": "/.boot/kernel-firecracker",
+			KernelVariantAnnotPrefix + "solo5.hvt":   "/not-a-monitor",
+		})
+		require.Equal(t, []string{uruncJSONPath, DefaultKernelPath, "/.boot/kernel-firecracker"}, files)
+	})
+}
+
+func TestVerifyResult(t *testing.T) {
+	annots := map[string]string{
+		"com.urunc.unikernel.binary": DefaultKernelPath,
+		"com.urunc.unikernel.initrd": DefaultRootfsPath,
+	}
+	t.Run("Valid all files present", func(t *testing.T) {
+		ref := &fakeRef{
+			files: map[string][]byte{
+				uruncJSONPath:     []byte("{}"),
+				DefaultKernelPath: []byte("kernel"),
+				DefaultRootfsPath: []byte("rootfs"),
+			},
+		}
+		err := VerifyResult(context.TODO(), ref, annots)
+		require.NoError(t, err)
+	})
+	t.Run("Invalid missing kernel", func(t *testing.T) {
+		ref := &fakeRef{
+			files: map[string][]byte{
+				uruncJSONPath:     []byte("{}"),
+				DefaultRootfsPath: []byte("rootfs"),
+			},
+		}
+		err := VerifyResult(context.TODO(), ref, annots)
+		require.ErrorContains(t, err, "Required file "+DefaultKernelPath+" is missing")
+	})
+	t.Run("Invalid empty rootfs and directory kernel", func(t *testing.T) {
+		ref := &fakeRef{
+			files: map[string][]byte{
+				uruncJSONPath:     []byte("{}"),
+				DefaultRootfsPath: []byte{},
+			},
+			dirs: map[string]bool{
+				DefaultKernelPath: true,
+			},
+		}
+		err := VerifyResult(context.TODO(), ref, annots)
+		require.ErrorContains(t, err, "Required file "+DefaultKernelPath+" is a directory")
+		require.ErrorContains(t, err, "Required file "+DefaultRootfsPath+" is empty")
+	})
+	t.Run("Invalid nil reference", func(t *testing.T) {
+		err := VerifyResult(context.TODO(), nil, annots)
+		require.ErrorContains(t, err, "No reference to verify")
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// fileState is the state of a watched file that tells if it changed
+type fileState struct {
+	ModTime time.Time
+	Size    int64
+	Mode    fs.FileMode
+}
+
+// Snapshot is the state of the files under the watched paths, keyed by
+// their path
+type Snapshot map[string]fileState
+
+// TakeSnapshot walks the given paths (files or directories) and returns the
+// state of every file under them. The paths in exclude and the .git
+// directories get skipped, e.g. so the output of a build inside the build
+// context does not trigger a new build.
+func TakeSnapshot(paths []string, exclude []string) (Snapshot, error) {
+	excluded := map[string]bool{}
+	for _, p := range exclude {
+		excluded[filepath.Clean(p)] = true
+	}
+
+	s := Snapshot{}
+	for _, root := range paths {
+		err := filepath.WalkDir(filepath.Clean(root), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Files can get removed while walking
+				if os.IsNotExist(err) && path != filepath.Clean(root) {
+					return nil
+				}
+				return err
+			}
+			if excluded[path] || (d.IsDir() && d.Name() == ".git") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// The files tell if something changed, not the directories
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			s[path] = fileState{ModTime: info.ModTime(), Size: info.Size(), Mode: info.Mode()}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to watch %s: %v", root, err)
+		}
+	}
+
+	return s, nil
+}
+
+// Changed returns the sorted paths that were added, removed or modified in
+// the given snapshot, compared to this one
+func (s Snapshot) Changed(other Snapshot) []string {
+	var changed []string
+	for path, state := range s {
+		newState, ok := other[path]
+		if !ok || !newState.ModTime.Equal(state.ModTime) ||
+			newState.Size != state.Size || newState.Mode != state.Mode {
+			changed = append(changed, path)
+		}
+	}
+	for path := range maps.Keys(other) {
+		if _, ok := s[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	slices.Sort(changed)
+
+	return changed
+}
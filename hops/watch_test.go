@@ -0,0 +1,76 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	bunnyfile := filepath.Join(t.TempDir(), "bunnyfile")
+	ctxDir := filepath.Join(dir, "context")
+	for _, d := range []string{"app", "image", ".git"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(ctxDir, d), 0755))
+	}
+	write := func(path string, content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write(bunnyfile, "version: v0.1\n")
+	write(filepath.Join(ctxDir, "app", "main"), "main")
+	write(filepath.Join(ctxDir, "image", "index.json"), "{}")
+	write(filepath.Join(ctxDir, ".git", "HEAD"), "ref")
+
+	paths := []string{bunnyfile, ctxDir}
+	exclude := []string{filepath.Join(ctxDir, "image")}
+	first, err := TakeSnapshot(paths, exclude)
+	require.NoError(t, err)
+	require.Contains(t, first, bunnyfile)
+	require.Contains(t, first, filepath.Join(ctxDir, "app", "main"))
+	require.NotContains(t, first, filepath.Join(ctxDir, "image", "index.json"))
+	require.NotContains(t, first, filepath.Join(ctxDir, ".git", "HEAD"))
+
+	t.Run("No changes", func(t *testing.T) {
+		write(filepath.Join(ctxDir, "image", "index.json"), "{\"manifests\": []}")
+		write(filepath.Join(ctxDir, ".git", "HEAD"), "other ref")
+		current, err := TakeSnapshot(paths, exclude)
+		require.NoError(t, err)
+		require.Empty(t, first.Changed(current))
+	})
+	t.Run("Changes", func(t *testing.T) {
+		write(filepath.Join(ctxDir, "app", "main"), "new main")
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(bunnyfile, later, later))
+		write(filepath.Join(ctxDir, "app", "lib"), "lib")
+		current, err := TakeSnapshot(paths, exclude)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(ctxDir, "app", "lib"), filepath.Join(ctxDir, "app", "main"), bunnyfile},
+			first.Changed(current))
+
+		require.NoError(t, os.Remove(filepath.Join(ctxDir, "app", "lib")))
+		removed, err := TakeSnapshot(paths, exclude)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(ctxDir, "app", "lib")}, current.Changed(removed))
+	})
+	t.Run("Missing path", func(t *testing.T) {
+		_, err := TakeSnapshot([]string{filepath.Join(dir, "missing")}, nil)
+		require.ErrorContains(t, err, "Failed to watch")
+	})
+}